@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/logging"
+)
+
+// commandNames lists the subcommands; serve runs when none is given
+const commandNames = "serve, logs, check-config, ctl"
+
+// runCommand runs the subcommand named by the first argument. It returns the remaining
+// arguments for the serve command, or exits for the others.
+func runCommand(args []string) []string {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return args
+	}
+
+	switch args[0] {
+	case "serve":
+		return args[1:]
+	case "logs":
+		os.Exit(runLogsCommand(args[1:], os.Stdout, os.Stderr))
+	case "check-config":
+		os.Exit(runCheckConfigCommand(args[1:], os.Stdout, os.Stderr))
+	case "ctl":
+		os.Exit(runCtlCommand(args[1:], os.Stdout, os.Stderr))
+	case "help":
+		flag.Usage()
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q (commands: %s)\n", args[0], commandNames)
+	os.Exit(2)
+	return nil
+}
+
+// usage prints the serve flags and the other subcommands
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [serve] [flags]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s logs [-follow] [-level warn] [-since 1h] [-grep text] [-config file]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s check-config [-config file] [-online] [-timeout 5s]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s ctl [-socket path] endpoint|group|config|metrics ... (see ctl -h)\n\n", os.Args[0])
+	fmt.Fprintf(out, "Serve flags:\n")
+	flag.PrintDefaults()
+}
+
+// runCheckConfigCommand loads and validates a config file without starting the server
+func runCheckConfigCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", "config/example.yaml", "Path to configuration file")
+	online := fs.Bool("online", false, "Also probe each endpoint's health path")
+	timeout := fs.Duration("timeout", defaultCheckTimeout, "Timeout of each -online probe")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return runConfigCheck(*path, *online, *timeout, stdout)
+}
+
+// defaultCheckTimeout bounds each endpoint probe of an online config check
+const defaultCheckTimeout = 5 * time.Second
+
+// Values of the --check-config flag
+const (
+	checkConfigOffline = "offline"
+	checkConfigOnline  = "online"
+)
+
+// checkConfigMode is the value of --check-config: empty when the flag is not given,
+// "offline" when it is given bare and "online" to also probe every endpoint
+type checkConfigMode string
+
+// checkConfigFlag registers the --check-config flag, which may be given with or without a value
+func checkConfigFlag(name, usage string) *checkConfigMode {
+	mode := new(checkConfigMode)
+	flag.Var(mode, name, usage)
+	return mode
+}
+
+func (m *checkConfigMode) String() string {
+	if m == nil {
+		return ""
+	}
+	return string(*m)
+}
+
+func (m *checkConfigMode) Set(value string) error {
+	switch value {
+	case "true", checkConfigOffline:
+		*m = checkConfigOffline
+	case checkConfigOnline:
+		*m = checkConfigOnline
+	case "false":
+		*m = ""
+	default:
+		return fmt.Errorf("must be %q or %q", checkConfigOffline, checkConfigOnline)
+	}
+	return nil
+}
+
+// IsBoolFlag lets --check-config be given without a value
+func (m *checkConfigMode) IsBoolFlag() bool { return true }
+
+// runConfigCheck prints a report of a config file, optionally probing each endpoint, and
+// returns a non-zero exit code when it found an error
+func runConfigCheck(path string, online bool, timeout time.Duration, stdout io.Writer) int {
+	report := config.CheckConfigFile(path)
+	fmt.Fprintf(stdout, "🔍 %s\n", path)
+
+	var probes []endpoint.ProbeResult
+	if report.Config != nil && online && len(report.Config.Endpoints) > 0 {
+		fmt.Fprintf(stdout, "🌐 Probing %s on each endpoint (timeout %v)...\n", report.Config.Health.HealthPath, timeout)
+		probes = endpoint.NewManager(report.Config).CheckConnectivity(context.Background(), timeout)
+	}
+
+	errorCount, warningCount := 0, 0
+	count := func(issue config.CheckIssue) {
+		if issue.Severity == config.CheckError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	printIssue := func(indent string, issue config.CheckIssue) {
+		icon := "⚠️"
+		if issue.Severity == config.CheckError {
+			icon = "❌"
+		}
+		fmt.Fprintf(stdout, "%s%s %s\n", indent, icon, issue.Message)
+	}
+
+	if report.Config != nil && len(report.Config.Endpoints) > 0 {
+		fmt.Fprintf(stdout, "\nEndpoints (strategy %s):\n", report.Config.Strategy.Type)
+		for i, ep := range report.Config.Endpoints {
+			issues := report.EndpointIssues(ep.Name)
+			status := "✅"
+			for _, issue := range issues {
+				if issue.Severity == config.CheckError {
+					status = "❌"
+				} else if status == "✅" {
+					status = "⚠️"
+				}
+			}
+			probeText := ""
+			if i < len(probes) {
+				result := probes[i]
+				switch {
+				case result.Error != nil:
+					status = "❌"
+					probeText = fmt.Sprintf(" - unreachable: %v", result.Error)
+					errorCount++
+				case !result.Healthy:
+					status = "❌"
+					probeText = fmt.Sprintf(" - HTTP %d in %dms", result.StatusCode, result.ResponseTime.Milliseconds())
+					errorCount++
+				default:
+					probeText = fmt.Sprintf(" - HTTP %d in %dms", result.StatusCode, result.ResponseTime.Milliseconds())
+				}
+			}
+			fmt.Fprintf(stdout, "  %s %s (%s, group %s, priority %d)%s\n", status, ep.Name, ep.DisplayURL(), ep.Group, ep.Priority, probeText)
+			for _, issue := range issues {
+				printIssue("      ", issue)
+			}
+		}
+	}
+
+	var global []config.CheckIssue
+	for _, issue := range report.Issues {
+		count(issue)
+		if issue.Endpoint == "" {
+			global = append(global, issue)
+		}
+	}
+	if len(global) > 0 {
+		fmt.Fprintf(stdout, "\nConfiguration:\n")
+		for _, issue := range global {
+			printIssue("  ", issue)
+		}
+	}
+
+	fmt.Fprintln(stdout)
+	if errorCount > 0 {
+		fmt.Fprintf(stdout, "❌ %d error(s), %d warning(s)\n", errorCount, warningCount)
+		return 1
+	}
+	fmt.Fprintf(stdout, "✅ OK: %d endpoints, %d warning(s)\n", len(report.Config.Endpoints), warningCount)
+	return 0
+}
+
+// runLogsCommand prints the forwarder's file log, filtered and colorized, and optionally follows it
+func runLogsCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", "config/example.yaml", "Path to configuration file (for the log file path)")
+	file := fs.String("file", "", "Log file to read instead of the one in the config")
+	follow := fs.Bool("follow", false, "Keep printing new lines, across rotations")
+	fs.BoolVar(follow, "f", false, "Shorthand for -follow")
+	level := fs.String("level", "", "Minimum level: debug, info, warn or error")
+	since := fs.String("since", "", "Only lines newer than a duration (1h) or time (2006-01-02 15:04:05); includes rotated files")
+	grep := fs.String("grep", "", "Only lines containing this text (case-insensitive)")
+	lines := fs.Int("lines", 10, "Recent lines to print first when -since is not set")
+	fs.IntVar(lines, "n", 10, "Shorthand for -lines")
+	noColor := fs.Bool("no-color", false, "Disable colored output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	filename := *file
+	if filename == "" {
+		cfg, err := config.LoadSetupConfig(*path)
+		if err != nil {
+			fmt.Fprintf(stderr, "❌ %s: %v\n", *path, err)
+			return 1
+		}
+		if !cfg.Logging.FileEnabled {
+			fmt.Fprintf(stderr, "❌ File logging is not enabled in %s (logging.file_enabled); use -file to read a log directly\n", *path)
+			return 1
+		}
+		filename = cfg.Logging.FilePath
+	}
+
+	opts := logging.TailOptions{
+		Query:  logging.SearchQuery{Text: *grep},
+		Lines:  *lines,
+		Follow: *follow,
+	}
+	if *level != "" {
+		levels, err := logging.LevelsAtLeast(*level)
+		if err != nil {
+			fmt.Fprintf(stderr, "❌ %v\n", err)
+			return 2
+		}
+		opts.Query.Levels = levels
+	}
+	if *since != "" {
+		sinceTime, err := parseSince(*since, time.Now())
+		if err != nil {
+			fmt.Fprintf(stderr, "❌ %v\n", err)
+			return 2
+		}
+		opts.Query.Since = sinceTime
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	color := !*noColor && isTerminal(stdout)
+	err := logging.Tail(ctx, filename, opts, func(line logging.LogLine) {
+		fmt.Fprintln(stdout, formatLogLine(line, color))
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "❌ %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseSince accepts a duration before now or a local time
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q: expected a duration such as 1h or a time such as 2006-01-02 15:04:05", value)
+}
+
+// ANSI colors of the log levels
+var levelColors = map[string]string{
+	"DEBUG": "\033[90m",
+	"INFO":  "\033[32m",
+	"WARN":  "\033[33m",
+	"ERROR": "\033[31m",
+}
+
+// formatLogLine prints a log line in the file format, with the level colored
+func formatLogLine(line logging.LogLine, color bool) string {
+	if line.Level == "" {
+		return line.Message
+	}
+	level := fmt.Sprintf("[%s]", line.Level)
+	if code, ok := levelColors[line.Level]; ok && color {
+		level = code + level + "\033[0m"
+	}
+	if line.Time == "" {
+		return level + " " + line.Message
+	}
+	if color {
+		return fmt.Sprintf("\033[2m[%s]\033[0m %s %s", line.Time, level, line.Message)
+	}
+	return fmt.Sprintf("[%s] %s %s", line.Time, level, line.Message)
+}
+
+// isTerminal reports whether w is a terminal rather than a file or pipe
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
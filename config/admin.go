@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+// maxSocketPathLength is the longest Unix socket path accepted on all supported platforms
+// (sun_path holds 104 bytes on macOS and 108 on Linux, including the terminating NUL)
+const maxSocketPathLength = 103
+
+// AdminConfig configures the local control socket used by scripts and the ctl command
+type AdminConfig struct {
+	SocketPath string `yaml:"socket_path"` // Unix socket serving the admin API, empty = disabled (default); access is controlled by file permissions
+}
+
+// validateAdmin validates the admin socket settings
+func (c *Config) validateAdmin() error {
+	if len(c.Admin.SocketPath) > maxSocketPathLength {
+		return fmt.Errorf("admin: socket_path is longer than %d bytes: %s", maxSocketPathLength, c.Admin.SocketPath)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// EndpointAuthConfig selects how requests to an endpoint are authenticated
+type EndpointAuthConfig struct {
+	Type          string        `yaml:"type"`                     // "static" (token/api-key, default) or "oauth2"
+	TokenURL      string        `yaml:"token_url,omitempty"`      // OAuth2 token endpoint
+	ClientID      string        `yaml:"client_id,omitempty"`      // OAuth2 client ID
+	ClientSecret  string        `yaml:"client_secret,omitempty"`  // OAuth2 client secret
+	Scopes        []string      `yaml:"scopes,omitempty"`         // Requested scopes
+	RefreshMargin time.Duration `yaml:"refresh_margin,omitempty"` // Refresh this long before expiry, default: 1m
+}
+
+// Endpoint authentication types
+const (
+	AuthTypeStatic = "static"
+	AuthTypeOAuth2 = "oauth2"
+)
+
+// defaultRefreshMargin is how long before expiry an OAuth2 token is refreshed by default
+const defaultRefreshMargin = time.Minute
+
+// IsOAuth2 reports whether the endpoint obtains its token via OAuth2 client credentials
+func (e EndpointConfig) IsOAuth2() bool {
+	return e.Auth != nil && e.Auth.Type == AuthTypeOAuth2
+}
+
+// setAuthDefaults fills in defaults for endpoint authentication
+func (c *Config) setAuthDefaults() {
+	for i := range c.Endpoints {
+		auth := c.Endpoints[i].Auth
+		if auth == nil {
+			continue
+		}
+		if auth.Type == "" {
+			auth.Type = AuthTypeStatic
+		}
+		if auth.Type == AuthTypeOAuth2 && auth.RefreshMargin == 0 {
+			auth.RefreshMargin = defaultRefreshMargin
+		}
+	}
+}
+
+// validateClientAuth validates the tokens clients use to access the forwarder
+func (c *Config) validateClientAuth() error {
+	if !c.Auth.Enabled {
+		return nil
+	}
+	if c.Auth.Token == "" && len(c.Auth.Tokens) == 0 {
+		return fmt.Errorf("auth: token or tokens is required when auth is enabled")
+	}
+	names := make(map[string]bool)
+	tokens := map[string]bool{c.Auth.Token: c.Auth.Token != ""}
+	for i, token := range c.Auth.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("auth.tokens[%d]: name is required", i)
+		}
+		if token.Token == "" {
+			return fmt.Errorf("auth.tokens[%d] (%s): token is required", i, token.Name)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("auth.tokens[%d]: duplicate name %q", i, token.Name)
+		}
+		// Exported configs carry placeholders for every token until they are filled in
+		if tokens[token.Token] && token.Token != RedactedPlaceholder {
+			return fmt.Errorf("auth.tokens[%d] (%s): token is already used by another client", i, token.Name)
+		}
+		names[token.Name] = true
+		tokens[token.Token] = true
+	}
+	return nil
+}
+
+// validateAuth validates endpoint authentication blocks
+func (c *Config) validateAuth() error {
+	for _, endpoint := range c.Endpoints {
+		auth := endpoint.Auth
+		if auth == nil {
+			continue
+		}
+		switch auth.Type {
+		case AuthTypeStatic:
+		case AuthTypeOAuth2:
+			if auth.TokenURL == "" {
+				return fmt.Errorf("endpoint %s: auth token_url is required for oauth2", endpoint.Name)
+			}
+			if u, err := url.Parse(auth.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("endpoint %s: auth token_url must be an http(s) URL", endpoint.Name)
+			}
+			if auth.ClientID == "" {
+				return fmt.Errorf("endpoint %s: auth client_id is required for oauth2", endpoint.Name)
+			}
+			if auth.RefreshMargin < 0 {
+				return fmt.Errorf("endpoint %s: auth refresh_margin must be non-negative", endpoint.Name)
+			}
+		default:
+			return fmt.Errorf("endpoint %s: auth type must be 'static' or 'oauth2'", endpoint.Name)
+		}
+	}
+	return nil
+}
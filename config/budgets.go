@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// BudgetConfig caps the tokens an endpoint or a group may use per window, e.g. to stay
+// within a provider's monthly quota
+type BudgetConfig struct {
+	Endpoint string        `yaml:"endpoint,omitempty"` // Endpoint the budget applies to; set endpoint or group
+	Group    string        `yaml:"group,omitempty"`    // Group the budget applies to, counting all of its endpoints
+	Window   time.Duration `yaml:"window,omitempty"`   // Period usage is counted over, starting with the first request, default: 24h
+	Limit    int64         `yaml:"limit"`              // Input and output tokens allowed per window, required
+	Action   string        `yaml:"action,omitempty"`   // "warn" (default): alert only; "block": stop selecting the endpoint or group until the window resets
+}
+
+// Actions when a token budget is used up
+const (
+	BudgetActionWarn  = "warn"
+	BudgetActionBlock = "block"
+)
+
+// defaultBudgetWindow is the window of a budget without one
+const defaultBudgetWindow = 24 * time.Hour
+
+// Subject returns what the budget applies to, e.g. "endpoint api" or "group main"
+func (b BudgetConfig) Subject() string {
+	if b.Endpoint != "" {
+		return "endpoint " + b.Endpoint
+	}
+	return "group " + b.Group
+}
+
+// Covers reports whether tokens used by an endpoint of a group count toward the budget
+func (b BudgetConfig) Covers(endpoint, group string) bool {
+	if b.Endpoint != "" {
+		return b.Endpoint == endpoint
+	}
+	return b.Group == group
+}
+
+// setBudgetDefaults fills in defaults for token budgets
+func (c *Config) setBudgetDefaults() {
+	for i := range c.Budgets {
+		budget := &c.Budgets[i]
+		if budget.Window == 0 {
+			budget.Window = defaultBudgetWindow
+		}
+		if budget.Action == "" {
+			budget.Action = BudgetActionWarn
+		}
+	}
+}
+
+// validateBudgets validates token budgets against the configured endpoints and groups
+func (c *Config) validateBudgets() error {
+	endpoints := make(map[string]bool, len(c.Endpoints))
+	groups := make(map[string]bool)
+	for _, endpoint := range c.Endpoints {
+		endpoints[endpoint.Name] = true
+		groups[endpoint.Group] = true
+	}
+
+	seen := make(map[string]bool, len(c.Budgets))
+	for i, budget := range c.Budgets {
+		if (budget.Endpoint == "") == (budget.Group == "") {
+			return fmt.Errorf("budget %d: endpoint or group must be set, but not both", i)
+		}
+		if budget.Endpoint != "" && !endpoints[budget.Endpoint] {
+			return fmt.Errorf("budget %d: endpoint %q does not exist", i, budget.Endpoint)
+		}
+		if budget.Group != "" && !groups[budget.Group] {
+			return fmt.Errorf("budget %d: group %q has no endpoints", i, budget.Group)
+		}
+		if budget.Limit <= 0 {
+			return fmt.Errorf("budget %d: limit must be positive", i)
+		}
+		if budget.Window < 0 {
+			return fmt.Errorf("budget %d: window must be positive", i)
+		}
+		if budget.Action != BudgetActionWarn && budget.Action != BudgetActionBlock {
+			return fmt.Errorf("budget %d: action must be %q or %q", i, BudgetActionWarn, BudgetActionBlock)
+		}
+		key := fmt.Sprintf("%s/%s", budget.Subject(), budget.Window)
+		if seen[key] {
+			return fmt.Errorf("budget %d: duplicate budget for %s with window %s", i, budget.Subject(), budget.Window)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Check issue severities
+const (
+	CheckError   = "error"
+	CheckWarning = "warning"
+)
+
+// CheckIssue is one finding of a config check
+type CheckIssue struct {
+	Severity string
+	Endpoint string // Endpoint the issue belongs to, empty for global settings
+	Message  string
+}
+
+// CheckReport is the result of checking a config file without starting the server
+type CheckReport struct {
+	Path   string
+	Config *Config // Parsed config with defaults applied, nil when the file could not be parsed
+	Issues []CheckIssue
+}
+
+// HasErrors reports whether the check found any error
+func (r *CheckReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == CheckError {
+			return true
+		}
+	}
+	return false
+}
+
+// EndpointIssues returns the issues of one endpoint
+func (r *CheckReport) EndpointIssues(name string) []CheckIssue {
+	var issues []CheckIssue
+	for _, issue := range r.Issues {
+		if issue.Endpoint == name {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *CheckReport) add(severity, endpoint, format string, args ...any) {
+	r.Issues = append(r.Issues, CheckIssue{Severity: severity, Endpoint: endpoint, Message: fmt.Sprintf(format, args...)})
+}
+
+// CheckConfigFile loads a config file, applies defaults and validates it like LoadConfig,
+// then runs the additional checks validate() does not enforce at runtime. Unlike
+// LoadConfig it keeps going after a validation error so every finding is reported.
+func CheckConfigFile(path string) *CheckReport {
+	report := &CheckReport{Path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		report.add(CheckError, "", "failed to read config file: %v", err)
+		return report
+	}
+
+	var config Config
+	if err := unmarshalConfig(data, &config); err != nil {
+		report.add(CheckError, "", "failed to parse config file: %v", err)
+		return report
+	}
+	config.setDefaults()
+	report.Config = &config
+
+	if err := config.validate(); err != nil {
+		if errors.Is(err, ErrNoEndpoints) && config.EndpointsSource.Enabled() {
+			report.add(CheckWarning, "", "no endpoints in the file: all endpoints must come from endpoints_source (%s), which the check does not fetch", config.EndpointsSource.URL)
+		} else if errors.Is(err, ErrNoEndpoints) {
+			report.add(CheckError, "", "%v (the forwarder would start in setup mode)", err)
+		} else {
+			report.add(CheckError, "", "invalid configuration: %v", err)
+		}
+	}
+	config.checkEndpointNames(report)
+	config.checkGroupPriorities(report)
+	config.checkFastTest(report)
+	config.checkPortCollisions(report)
+	config.checkTLSFiles(report)
+	return report
+}
+
+// unmarshalConfig parses YAML into a config, explaining malformed durations
+func unmarshalConfig(data []byte, config *Config) error {
+	err := yaml.Unmarshal(data, config)
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	messages := make([]string, len(typeErr.Errors))
+	for i, message := range typeErr.Errors {
+		if strings.Contains(message, "time.Duration") {
+			message += ` (durations need a unit, such as "500ms", "30s", "5m" or "1h30m")`
+		}
+		messages[i] = message
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// checkEndpointNames reports endpoints sharing a name; only the first would be reachable
+// by name in the TUI, WebUI, admin API and saved state
+func (c *Config) checkEndpointNames(report *CheckReport) {
+	seen := make(map[string]int)
+	for i, ep := range c.Endpoints {
+		if ep.Name == "" {
+			continue
+		}
+		if first, ok := seen[ep.Name]; ok {
+			report.add(CheckError, ep.Name, "duplicate endpoint name (endpoints %d and %d)", first+1, i+1)
+			continue
+		}
+		seen[ep.Name] = i
+	}
+}
+
+// checkGroupPriorities reports endpoints of one group sharing a priority, which leaves
+// their order under the priority strategy up to config order
+func (c *Config) checkGroupPriorities(report *CheckReport) {
+	type groupPriority struct {
+		group    string
+		priority int
+	}
+	seen := make(map[groupPriority]string)
+	for _, ep := range c.Endpoints {
+		key := groupPriority{ep.Group, ep.Priority}
+		if other, ok := seen[key]; ok && other != ep.Name {
+			report.add(CheckWarning, ep.Name, "priority %d is also used by %s in group %s", ep.Priority, other, ep.Group)
+			continue
+		}
+		seen[key] = ep.Name
+	}
+}
+
+// checkFastTest reports fast-test settings that never take effect
+func (c *Config) checkFastTest(report *CheckReport) {
+	s := c.Strategy
+	if s.FastTestEnabled && s.Type != "fastest" {
+		report.add(CheckWarning, "", "strategy fast_test_enabled has no effect with strategy %q (fast tests only run with \"fastest\")", s.Type)
+	}
+	if s.FastTestEnabled && s.FastTestCacheTTL < s.FastTestTimeout {
+		report.add(CheckWarning, "", "strategy fast_test_cache_ttl (%v) is shorter than fast_test_timeout (%v), so cached results expire before a new test can finish", s.FastTestCacheTTL, s.FastTestTimeout)
+	}
+}
+
+// checkPortCollisions reports a WebUI address that a proxy listener also binds
+func (c *Config) checkPortCollisions(report *CheckReport) {
+	if !c.WebUI.Enabled || c.WebUI.Listen != "" {
+		return
+	}
+	for _, listener := range c.Server.GetListeners() {
+		if listener.Listen != "" {
+			continue
+		}
+		if listener.Port == c.WebUI.Port && hostsOverlap(listener.Host, c.WebUI.Host) {
+			report.add(CheckError, "", "webui %s:%d collides with server listener %s", c.WebUI.Host, c.WebUI.Port, listener.Address())
+		}
+	}
+}
+
+// checkTLSFiles reports TLS certificates, keys and client CA bundles that cannot be loaded;
+// the forwarder would refuse to start with them
+func (c *Config) checkTLSFiles(report *CheckReport) {
+	check := func(name string, t ListenerTLSConfig) {
+		if !t.Enabled || t.CertFile == "" || t.KeyFile == "" {
+			return
+		}
+		if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			report.add(CheckError, "", "%s tls: cannot load certificate %s with key %s: %v", name, t.CertFile, t.KeyFile, err)
+		}
+		if t.ClientCAFile == "" {
+			return
+		}
+		if data, err := os.ReadFile(t.ClientCAFile); err != nil {
+			report.add(CheckError, "", "%s tls: cannot read client_ca_file: %v", name, err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			report.add(CheckError, "", "%s tls: client_ca_file %s contains no PEM certificates", name, t.ClientCAFile)
+		}
+	}
+	for _, listener := range c.Server.GetListeners() {
+		check("server listener "+listener.Address(), listener.TLS)
+	}
+	if c.WebUI.Enabled {
+		check("webui", c.WebUI.TLS)
+	}
+}
+
+// hostsOverlap reports whether two listen hosts would bind the same address
+func hostsOverlap(a, b string) bool {
+	wildcard := func(host string) bool {
+		return host == "" || host == "0.0.0.0" || host == "::" || host == "[::]"
+	}
+	loopback := func(host string) string {
+		if host == "localhost" {
+			return "127.0.0.1"
+		}
+		return host
+	}
+	return loopback(a) == loopback(b) || wildcard(a) || wildcard(b)
+}
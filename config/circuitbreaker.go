@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// CircuitBreakerConfig takes endpoints that keep failing real requests out of selection
+// before health checks notice, then lets a few probe requests through to detect recovery
+type CircuitBreakerConfig struct {
+	Enabled             bool          `yaml:"enabled"`                // Enable per-endpoint circuit breakers, default: false
+	FailureThreshold    int           `yaml:"failure_threshold"`      // Consecutive failed requests (network errors, 5xx) that open the breaker, default: 5
+	OpenDuration        time.Duration `yaml:"open_duration"`          // How long an open breaker excludes the endpoint, default: 30s
+	HalfOpenMaxRequests int           `yaml:"half_open_max_requests"` // Concurrent probe requests allowed once open_duration has passed, default: 1
+}
+
+// setCircuitBreakerDefaults fills in circuit breaker defaults
+func (c *Config) setCircuitBreakerDefaults() {
+	if c.CircuitBreaker.FailureThreshold == 0 {
+		c.CircuitBreaker.FailureThreshold = 5
+	}
+	if c.CircuitBreaker.OpenDuration == 0 {
+		c.CircuitBreaker.OpenDuration = 30 * time.Second
+	}
+	if c.CircuitBreaker.HalfOpenMaxRequests == 0 {
+		c.CircuitBreaker.HalfOpenMaxRequests = 1
+	}
+}
+
+// validateCircuitBreaker validates the circuit breaker settings
+func (c *Config) validateCircuitBreaker() error {
+	cb := c.CircuitBreaker
+	if cb.FailureThreshold < 0 {
+		return fmt.Errorf("circuit_breaker: failure_threshold must be positive")
+	}
+	if cb.OpenDuration < 0 {
+		return fmt.Errorf("circuit_breaker: open_duration must be positive")
+	}
+	if cb.HalfOpenMaxRequests < 0 {
+		return fmt.Errorf("circuit_breaker: half_open_max_requests must be positive")
+	}
+	return nil
+}
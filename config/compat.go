@@ -0,0 +1,42 @@
+package config
+
+import "fmt"
+
+// CompatConfig configures translation of other API flavors to Anthropic's Messages API
+type CompatConfig struct {
+	OpenAIEnabled    bool              `yaml:"openai_enabled"`     // Translate OpenAI /v1/chat/completions requests and responses, default: false
+	ModelMap         map[string]string `yaml:"model_map"`          // OpenAI model name -> Anthropic model name, unmapped names are passed through
+	DefaultMaxTokens int               `yaml:"default_max_tokens"` // max_tokens sent when the request has none (required by Anthropic), default: 4096
+	AnthropicVersion string            `yaml:"anthropic_version"`  // anthropic-version header added when the client sends none, default: 2023-06-01
+}
+
+// MapModel returns the Anthropic model for an OpenAI model name
+func (c CompatConfig) MapModel(model string) string {
+	if mapped, ok := c.ModelMap[model]; ok {
+		return mapped
+	}
+	return model
+}
+
+// setCompatDefaults fills in defaults for API compatibility settings
+func (c *Config) setCompatDefaults() {
+	if c.Compat.DefaultMaxTokens == 0 {
+		c.Compat.DefaultMaxTokens = 4096
+	}
+	if c.Compat.AnthropicVersion == "" {
+		c.Compat.AnthropicVersion = "2023-06-01"
+	}
+}
+
+// validateCompat validates API compatibility settings
+func (c *Config) validateCompat() error {
+	if c.Compat.DefaultMaxTokens < 0 {
+		return fmt.Errorf("compat: default_max_tokens cannot be negative")
+	}
+	for from, to := range c.Compat.ModelMap {
+		if from == "" || to == "" {
+			return fmt.Errorf("compat: model_map entries need both an OpenAI and an Anthropic model name (got %q: %q)", from, to)
+		}
+	}
+	return nil
+}
@@ -1,14 +1,23 @@
 package config
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"endpoint_forwarder/internal/clientip"
+	"endpoint_forwarder/internal/logging"
 	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
 )
@@ -36,22 +45,200 @@ type Config struct {
 	Strategy      StrategyConfig   `yaml:"strategy"`
 	Retry         RetryConfig      `yaml:"retry"`
 	Health        HealthConfig     `yaml:"health"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // Per-endpoint breakers opened by consecutive request failures
+	LoadShedding  LoadSheddingConfig `yaml:"load_shedding"`  // Fail-fast 503s while no endpoint can take requests
 	Logging       LoggingConfig    `yaml:"logging"`
 	Streaming     StreamingConfig  `yaml:"streaming"`
 	Group         GroupConfig      `yaml:"group"` // Group configuration
+	Groups        map[string]GroupSettings `yaml:"groups,omitempty"` // Per-group settings keyed by group name
+	Rules         []RuleConfig     `yaml:"rules,omitempty"` // Request filters applied before endpoint selection
 	Proxy         ProxyConfig      `yaml:"proxy"`
 	Auth          AuthConfig       `yaml:"auth"`
 	TUI           TUIConfig        `yaml:"tui"`            // TUI configuration
 	WebUI         WebUIConfig      `yaml:"webui"`          // WebUI configuration
+	Monitoring    MonitoringConfig `yaml:"monitoring"`     // Monitoring/statistics configuration
+	State         StateConfig      `yaml:"state"`          // Runtime state persistence configuration
+	Notifications NotificationsConfig `yaml:"notifications"` // Health and failure alerts sent to webhooks or email
+	Budgets       []BudgetConfig   `yaml:"budgets,omitempty"` // Token budgets per endpoint or group
+	Compat        CompatConfig     `yaml:"compat"`         // Translation of other API flavors (OpenAI) to the Messages API
+	Forwarding    ForwardingConfig `yaml:"forwarding"`     // Headers added to upstream requests (request ID)
+	Transport     TransportConfig  `yaml:"transport"`      // Connection pool and HTTP/2 settings for upstream connections
+	Admin         AdminConfig      `yaml:"admin"`          // Local control socket for scripts and the ctl command
 	GlobalTimeout time.Duration    `yaml:"global_timeout"` // Global timeout for non-streaming requests
+	GlobalTimeoutCeiling time.Duration `yaml:"global_timeout_ceiling"` // Upper bound for timeouts requested with X-Forwarder-Timeout, default: global_timeout
 	Endpoints     []EndpointConfig `yaml:"endpoints"`
+	EndpointsSource EndpointsSourceConfig `yaml:"endpoints_source,omitempty"` // Remote document with more endpoints, fetched periodically
 	// Runtime priority override (not serialized to YAML)
 	PrimaryEndpoint string `yaml:"-"` // Primary endpoint name from command line
 }
 
+
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host                  string            `yaml:"host"`
+	Port                  int               `yaml:"port"`
+	Listen                string            `yaml:"listen"`                  // unix:///path/to.sock or systemd[:name] instead of host/port, empty = host/port
+	SocketMode            string            `yaml:"socket_mode"`             // Octal permissions of the listen socket file, default: "0660"
+	Listeners             []ListenerConfig  `yaml:"listeners"`               // Additional listen addresses; when set, host/port are ignored
+	RequireAllListeners   bool              `yaml:"require_all_listeners"`   // Exit if any listener fails to bind, default: false (exit only if all fail)
+	MaxConcurrentRequests int               `yaml:"max_concurrent_requests"` // Global limit on in-flight proxied requests, 0 = unlimited
+	CORS                  CORSConfig        `yaml:"cors"`                    // CORS handling for browser-based clients
+	AllowRoutingOverrides bool              `yaml:"allow_routing_overrides"` // Honor X-Forwarder-Endpoint/Group request headers, default: false
+	MaxRequestBodySize    string            `yaml:"max_request_body_size"`   // Largest request body buffered in memory (e.g. "10MB"), empty = unlimited
+	OnLargeBody           string            `yaml:"on_large_body"`           // Larger bodies: "reject" (413, default) or "stream" to the upstream without buffering or retries
+	TLS                   ListenerTLSConfig `yaml:"tls"`                     // Serve HTTPS on host/port or listen; listeners entries have their own tls
+	TrustedProxies        []string          `yaml:"trusted_proxies"`         // CIDRs or IPs of reverse proxies whose X-Forwarded-For / X-Real-IP are believed
+	ShutdownDrainTimeout  time.Duration     `yaml:"shutdown_drain_timeout"`  // How long shutdown waits for in-flight requests and streams, default: 30s
+	Compression           string            `yaml:"compression"`             // Compress non-streaming responses for clients that accept it: "gzip" or "off" (default)
+	CompressionMinSize    string            `yaml:"compression_min_size"`    // Smallest response body that is compressed (e.g. "1KB"), default: "1KB"
+}
+
+// Behaviors for request bodies larger than server.max_request_body_size
+const (
+	OnLargeBodyReject = "reject"
+	OnLargeBodyStream = "stream"
+)
+
+// Response compression settings for server.compression
+const (
+	CompressionOff  = "off"
+	CompressionGzip = "gzip"
+)
+
+// CompressionMinBytes returns server.compression_min_size in bytes
+func (s ServerConfig) CompressionMinBytes() int64 {
+	size, err := logging.ParseSize(s.CompressionMinSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// MaxRequestBodyBytes returns server.max_request_body_size in bytes, 0 when unlimited
+func (s ServerConfig) MaxRequestBodyBytes() int64 {
+	if s.MaxRequestBodySize == "" {
+		return 0
+	}
+	size, err := logging.ParseSize(s.MaxRequestBodySize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+type ListenerConfig struct {
+	Host       string            `yaml:"host"`        // Listen address, default: server.host
+	Port       int               `yaml:"port"`        // Listen port, required unless listen or address is set
+	Addr       string            `yaml:"address"`     // host:port in one string (e.g. "[::1]:8080") instead of host/port
+	Listen     string            `yaml:"listen"`      // unix:///path/to.sock or systemd[:name] instead of host/port
+	SocketMode string            `yaml:"socket_mode"` // Octal permissions of the listen socket file, default: "0660"
+	TLS        ListenerTLSConfig `yaml:"tls"`         // Serve HTTPS on this listener
+}
+
+type ListenerTLSConfig struct {
+	Enabled      bool   `yaml:"enabled"`        // Enable TLS for this listener, default: false
+	CertFile     string `yaml:"cert_file"`      // PEM certificate file, required when enabled
+	KeyFile      string `yaml:"key_file"`       // PEM private key file, required when enabled
+	ClientCAFile string `yaml:"client_ca_file"` // PEM CA bundle; when set, clients must present a certificate it signed (mutual TLS)
+	MinVersion   string `yaml:"min_version"`    // Lowest TLS version accepted: "1.0", "1.1", "1.2" or "1.3", default: "1.2"
+}
+
+// tlsVersions maps min_version values to their crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSMinVersion returns the crypto/tls constant of min_version, TLS 1.2 when unset
+func (t ListenerTLSConfig) TLSMinVersion() uint16 {
+	if version, ok := tlsVersions[t.MinVersion]; ok {
+		return version
+	}
+	return tls.VersionTLS12
+}
+
+// validate checks that an enabled TLS config names its files and a known version
+func (t ListenerTLSConfig) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("tls cert_file and key_file are required when tls is enabled")
+	}
+	if _, ok := tlsVersions[t.MinVersion]; !ok && t.MinVersion != "" {
+		return fmt.Errorf("tls min_version must be one of 1.0, 1.1, 1.2 or 1.3, got %q", t.MinVersion)
+	}
+	return nil
+}
+
+// Address returns the host:port string for the listener, or the socket path for a Unix socket
+func (l ListenerConfig) Address() string {
+	if addr, ok := l.ListenAddress(); ok {
+		if addr.SocketPath != "" {
+			return addr.SocketPath
+		}
+		return addr.String()
+	}
+	return net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+}
+
+// URL returns the base URL clients should use to reach the listener,
+// or the listen setting for sockets that have no URL of their own
+func (l ListenerConfig) URL() string {
+	if addr, ok := l.ListenAddress(); ok {
+		return addr.String()
+	}
+	scheme := "http"
+	if l.TLS.Enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, l.Address())
+}
+
+// IsLocal reports whether the listener only accepts loopback connections
+func (l ListenerConfig) IsLocal() bool {
+	if addr, ok := l.ListenAddress(); ok {
+		return addr.SocketPath != ""
+	}
+	if l.Host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(l.Host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// splitAddress parses the listener's address setting into host and port
+func (l ListenerConfig) splitAddress() (string, int, error) {
+	host, portText, err := net.SplitHostPort(l.Addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("port must be between 1 and 65535")
+	}
+	return host, port, nil
+}
+
+// GetListeners returns the effective listeners, falling back to the single host/port
+func (s ServerConfig) GetListeners() []ListenerConfig {
+	if len(s.Listeners) > 0 {
+		return s.Listeners
+	}
+	if s.Listen != "" {
+		return []ListenerConfig{{Listen: s.Listen, SocketMode: s.SocketMode, TLS: s.TLS}}
+	}
+	return []ListenerConfig{{Host: s.Host, Port: s.Port, TLS: s.TLS}}
+}
+
+type CORSConfig struct {
+	Enabled        bool          `yaml:"enabled"`         // Enable CORS handling, default: false
+	AllowedOrigins []string      `yaml:"allowed_origins"` // Allowed origins, "*" allows any origin
+	AllowedHeaders []string      `yaml:"allowed_headers"` // Request headers allowed in preflight responses
+	AllowedMethods []string      `yaml:"allowed_methods"` // Methods allowed in preflight responses
+	MaxAge         time.Duration `yaml:"max_age"`         // How long browsers may cache preflight results, default: 10m
+	Strict         bool          `yaml:"strict"`          // Reject requests from disallowed origins with 403
 }
 
 type StrategyConfig struct {
@@ -60,36 +247,108 @@ type StrategyConfig struct {
 	FastTestCacheTTL time.Duration `yaml:"fast_test_cache_ttl"` // Cache TTL for fast test results
 	FastTestTimeout  time.Duration `yaml:"fast_test_timeout"`   // Timeout for individual fast tests
 	FastTestPath     string        `yaml:"fast_test_path"`      // Path for fast testing (default: health path)
+	EWMAAlpha        float64       `yaml:"ewma_alpha"`          // Weight of the newest latency sample in the moving average, default: 0.3
+	MinSamples       int           `yaml:"min_samples"`         // Samples needed before an endpoint is ranked by its moving average, default: 3
+	StickyFactor     *float64      `yaml:"sticky_factor"`       // Switch from the current fastest endpoint only to one at least this much faster (0.1 = 10%), default: 0.1
+}
+
+// Default latency averaging settings of the fastest strategy
+const (
+	DefaultEWMAAlpha    = 0.3
+	DefaultStickyFactor = 0.1
+)
+
+// LatencyAlpha returns the moving average weight of the newest latency sample
+func (s StrategyConfig) LatencyAlpha() float64 {
+	if s.EWMAAlpha <= 0 || s.EWMAAlpha > 1 {
+		return DefaultEWMAAlpha
+	}
+	return s.EWMAAlpha
+}
+
+// Stickiness returns how much faster another endpoint must be to replace the current fastest one
+func (s StrategyConfig) Stickiness() float64 {
+	if s.StickyFactor == nil {
+		return DefaultStickyFactor
+	}
+	return *s.StickyFactor
 }
 
 type RetryConfig struct {
-	MaxAttempts int           `yaml:"max_attempts"`
-	BaseDelay   time.Duration `yaml:"base_delay"`
-	MaxDelay    time.Duration `yaml:"max_delay"`
-	Multiplier  float64       `yaml:"multiplier"`
+	MaxAttempts                int           `yaml:"max_attempts"`
+	BaseDelay                  time.Duration `yaml:"base_delay"`
+	MaxDelay                   time.Duration `yaml:"max_delay"`
+	Multiplier                 float64       `yaml:"multiplier"`
+	IdempotencyHeader          string        `yaml:"idempotency_header"`            // Header carrying a per-request idempotency key on every upstream attempt, empty disables
+	RetryNonIdempotent         *bool         `yaml:"retry_non_idempotent"`          // Allow cross-endpoint retries for POST/PATCH bodies above the threshold, default: true
+	NonIdempotentBodyThreshold int64         `yaml:"non_idempotent_body_threshold"` // Body size in bytes above which retry_non_idempotent applies, default: 0
+	MaxAttemptsCeiling         int           `yaml:"max_attempts_ceiling"`          // Upper bound for attempts requested with X-Forwarder-Max-Retries, default: max_attempts
+	BudgetPerMinute            int           `yaml:"budget_per_minute"`             // Retries allowed per minute across all requests, 0 = unlimited
+	MaxBufferedBodySize        string        `yaml:"max_buffered_body_size"`        // Largest body buffered for retries (e.g. "32MB"); larger ones are streamed once, empty = unlimited
+}
+
+// MaxBufferedBodyBytes returns retry.max_buffered_body_size in bytes, 0 when unlimited
+func (r RetryConfig) MaxBufferedBodyBytes() int64 {
+	if r.MaxBufferedBodySize == "" {
+		return 0
+	}
+	size, err := logging.ParseSize(r.MaxBufferedBodySize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// AllowNonIdempotentRetry reports whether large non-idempotent requests may fail over to other endpoints
+func (r RetryConfig) AllowNonIdempotentRetry() bool {
+	return r.RetryNonIdempotent == nil || *r.RetryNonIdempotent
 }
 
 type HealthConfig struct {
-	CheckInterval time.Duration `yaml:"check_interval"`
-	Timeout       time.Duration `yaml:"timeout"`
-	HealthPath    string        `yaml:"health_path"`
+	CheckInterval             time.Duration `yaml:"check_interval"`
+	Timeout                   time.Duration `yaml:"timeout"`
+	HealthPath                string        `yaml:"health_path"`
+	ReadinessExcludeEndpoints []string      `yaml:"readiness_exclude_endpoints"` // Endpoints that don't count toward /health/ready (e.g. mirrors)
+	ReadinessExcludeGroups    []string      `yaml:"readiness_exclude_groups"`    // Groups that don't count toward /health/ready
+	PassiveMode               bool          `yaml:"passive_mode"`                // Real request outcomes refresh health; probes only run for idle endpoints
+	PassiveIdleWindow         time.Duration `yaml:"passive_idle_window"`         // Passive mode: probe an endpoint after this long without traffic, default: 2m
+	AcceptableStatusCodes     []int         `yaml:"acceptable_status_codes"`     // Probe statuses besides 2xx that count as healthy, default: any 4xx
+	HealthyThreshold          int           `yaml:"healthy_threshold"`           // Consecutive good results that mark an unhealthy endpoint healthy, default: 2
+	UnhealthyThreshold        int           `yaml:"unhealthy_threshold"`         // Consecutive failed checks or requests that mark an endpoint unhealthy, default: 3
 }
 
 type LoggingConfig struct {
-	Level                string `yaml:"level"`
-	Format               string `yaml:"format"`                 // "json" or "text"
-	FileEnabled          bool   `yaml:"file_enabled"`           // Enable file logging
-	FilePath             string `yaml:"file_path"`              // Log file path
-	MaxFileSize          string `yaml:"max_file_size"`          // Max file size (e.g., "100MB")
-	MaxFiles             int    `yaml:"max_files"`              // Max number of rotated files to keep
-	CompressRotated      bool   `yaml:"compress_rotated"`       // Compress rotated log files
-	DisableResponseLimit bool   `yaml:"disable_response_limit"` // Disable response content output limit when file logging is enabled
+	Level                string        `yaml:"level"`
+	Format               string        `yaml:"format"`                 // "json" or "text"; json also writes a JSON access log line per proxied request
+	FileEnabled          bool          `yaml:"file_enabled"`           // Enable file logging
+	FilePath             string        `yaml:"file_path"`              // Log file path
+	MaxFileSize          string        `yaml:"max_file_size"`          // Max file size (e.g., "100MB")
+	MaxFiles             int           `yaml:"max_files"`              // Max number of rotated files to keep
+	CompressRotated      bool          `yaml:"compress_rotated"`       // Compress rotated log files
+	RotateInterval       time.Duration `yaml:"rotate_interval"`        // Also rotate every interval, aligned to local midnight (e.g. "24h" for daily), default: 0 (size only)
+	RotateOnStartup      bool          `yaml:"rotate_on_startup"`      // Start a new log file for every process run
+	DisableResponseLimit bool          `yaml:"disable_response_limit"` // Disable response content output limit when file logging is enabled
+	AccessLogFields      []string      `yaml:"access_log_fields"`      // Fields of the JSON access log, default: all of AccessLogFieldNames
+}
+
+// AccessLogFieldNames lists the fields the JSON access log can contain, in output order
+var AccessLogFieldNames = []string{
+	"timestamp", "conn_id", "request_id", "client_ip", "method", "path", "endpoint", "group",
+	"status", "retries", "duration_ms", "bytes_sent", "tokens",
 }
 
 type StreamingConfig struct {
 	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
 	ReadTimeout       time.Duration `yaml:"read_timeout"`
 	MaxIdleTime       time.Duration `yaml:"max_idle_time"`
+	PassthroughMode   bool          `yaml:"passthrough_mode"` // Stream SSE responses chunk by chunk without line processing, default: false
+	ReplayBufferKB    int           `yaml:"replay_buffer_kb"` // Recent events kept per passthrough stream for clients that reconnect, in KB, default: 0 (off)
+	ReplayWindow      time.Duration `yaml:"replay_window"`    // How long a stream whose client went away waits for it to reconnect, default: 30s
+}
+
+// ReplayEnabled reports whether passthrough streams can be resumed by reconnecting clients
+func (s StreamingConfig) ReplayEnabled() bool {
+	return s.ReplayBufferKB > 0
 }
 
 type GroupConfig struct {
@@ -97,6 +356,31 @@ type GroupConfig struct {
 	MaxRetries     int           `yaml:"max_retries"` // Maximum retry attempts per group before cooldown
 }
 
+// GroupSettings holds settings for a single endpoint group
+type GroupSettings struct {
+	Strategy   string            `yaml:"strategy"`              // Selection strategy within the group: "priority", "round-robin" or "least-busy"
+	Token      string            `yaml:"token,omitempty"`       // Bearer token for endpoints of the group that have no token of their own
+	Retry      *GroupRetryConfig `yaml:"retry,omitempty"`       // Retry settings for the group's endpoints, default: the retry section
+	Cooldown   time.Duration     `yaml:"cooldown,omitempty"`    // Cooldown of the group, default: group.cooldown
+	MaxRetries *int              `yaml:"max_retries,omitempty"` // Failures before the group cools down, default: group.max_retries
+}
+
+// Group selection strategies
+const (
+	GroupStrategyPriority   = "priority"
+	GroupStrategyRoundRobin = "round-robin"
+	GroupStrategyLeastBusy  = "least-busy"
+)
+
+// isValidGroupStrategy reports whether s is a supported group strategy (empty means unset)
+func isValidGroupStrategy(s string) bool {
+	switch s {
+	case "", GroupStrategyPriority, GroupStrategyRoundRobin, GroupStrategyLeastBusy:
+		return true
+	}
+	return false
+}
+
 type ProxyConfig struct {
 	Enabled  bool   `yaml:"enabled"`
 	Type     string `yaml:"type"`     // "http", "https", "socks5"
@@ -108,21 +392,98 @@ type ProxyConfig struct {
 }
 
 type AuthConfig struct {
-	Enabled bool   `yaml:"enabled"`         // Enable authentication, default: false
-	Token   string `yaml:"token,omitempty"` // Bearer token for authentication
+	Enabled bool        `yaml:"enabled"`          // Enable authentication, default: false
+	Token   string      `yaml:"token,omitempty"`  // Bearer token for authentication
+	Label   string      `yaml:"label,omitempty"`  // Client label used in per-client statistics instead of the token hash
+	Tokens  []AuthToken `yaml:"tokens,omitempty"` // Named per-client tokens, accepted alongside token
+
+	ProtectHealth bool `yaml:"protect_health,omitempty"` // Also require a token on /healthz, /readyz and /health/*, default: false (open to probes)
+}
+
+// AuthToken is a named client token; the name is used in per-client statistics
+type AuthToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
 }
 
 type TUIConfig struct {
 	Enabled           bool          `yaml:"enabled"`             // Enable TUI interface, default: true
 	UpdateInterval    time.Duration `yaml:"update_interval"`     // TUI refresh interval, default: 1s
-	SavePriorityEdits bool          `yaml:"save_priority_edits"` // Save priority edits to config file, default: false
+	OverviewInterval    time.Duration `yaml:"overview_interval"`    // Overview tab refresh interval, default: update_interval
+	ConnectionsInterval time.Duration `yaml:"connections_interval"` // Connections tab refresh interval, default: update_interval
+	SavePriorityEdits bool          `yaml:"save_priority_edits"` // Deprecated alias of save_edits, kept for existing configs
+	SaveEdits         bool          `yaml:"save_edits"`          // Save TUI/WebUI endpoint edits (priority, timeout, group, URL) to config file, default: false
+	NarrowWidth       int           `yaml:"narrow_width"`        // Terminal width (columns) below which views stack vertically, default: 120
+}
+
+// SaveEditsEnabled reports whether endpoint edits are written back to the config file
+func (t TUIConfig) SaveEditsEnabled() bool {
+	return t.SaveEdits || t.SavePriorityEdits
 }
 
 type WebUIConfig struct {
-	Enabled  bool   `yaml:"enabled"`  // Enable WebUI interface, default: false
-	Host     string `yaml:"host"`     // WebUI host, default: "127.0.0.1"
-	Port     int    `yaml:"port"`     // WebUI port, default: 8003
-	Password string `yaml:"password"` // WebUI access password, if empty no authentication required
+	Enabled  bool        `yaml:"enabled"`  // Enable WebUI interface, default: false
+	Host     string      `yaml:"host"`     // WebUI host, default: "127.0.0.1"
+	Port     int         `yaml:"port"`     // WebUI port, default: 8003
+	Listen   string      `yaml:"listen"`   // unix:///path/to.sock or systemd[:name] instead of host/port, empty = host/port
+
+	SocketMode string `yaml:"socket_mode"` // Octal permissions of the listen socket file, default: "0660"
+	Password string      `yaml:"password"` // WebUI access password, if empty no authentication required
+	Users    []WebUIUser `yaml:"users"`    // Named users with their own passwords, logging in with a username; may be combined with password
+
+	SessionTTL       time.Duration `yaml:"session_ttl"`        // Idle time after which a login session expires, renewed on every request, default: 24h
+	SessionMaxAge    time.Duration `yaml:"session_max_age"`    // Time after login at which a session expires even if active, 0 = no limit (default)
+	LoginMaxAttempts int           `yaml:"login_max_attempts"` // Failed logins per IP within a minute before lockout, default: 5
+	LoginLockout     time.Duration `yaml:"login_lockout"`      // How long an IP is locked out after too many failed logins, default: 1m
+
+	CaptureEnabled     bool   `yaml:"capture_enabled"`       // Record recent requests and responses for the Inspector tab, default: false
+	CaptureMaxRequests int    `yaml:"capture_max_requests"`  // Number of captured requests kept, default: 50
+	CaptureMaxBodySize string `yaml:"capture_max_body_size"` // Bytes kept of each request and response body (e.g. "8KB"), default: 8KB
+
+	TLS ListenerTLSConfig `yaml:"tls"` // Serve the WebUI over HTTPS
+}
+
+// WebUIUser is a named WebUI login
+type WebUIUser struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
+}
+
+// AuthEnabled reports whether the WebUI requires a login
+func (w WebUIConfig) AuthEnabled() bool {
+	return w.Password != "" || len(w.Users) > 0
+}
+
+// defaultCaptureMaxBodySize is how much of each body the inspector keeps by default
+const defaultCaptureMaxBodySize = 8 * 1024
+
+// CaptureMaxBodyBytes returns webui.capture_max_body_size in bytes
+func (w WebUIConfig) CaptureMaxBodyBytes() int {
+	size, err := logging.ParseSize(w.CaptureMaxBodySize)
+	if err != nil || size <= 0 {
+		return defaultCaptureMaxBodySize
+	}
+	return int(size)
+}
+
+type MonitoringConfig struct {
+	MaxClients            int           `yaml:"max_clients"`             // Max number of clients tracked in per-client statistics, default: 100
+	TokenHistoryInterval  time.Duration `yaml:"token_history_interval"`  // Token usage history bucket interval, default: 1m
+	TokenHistoryWindow    time.Duration `yaml:"token_history_window"`    // Token usage history retention window, default: 24h
+	TokenHistoryPoints    int           `yaml:"token_history_points"`    // Max number of token usage history buckets kept, default: 1440
+	ConnectionHistorySize int           `yaml:"connection_history_size"` // Max number of finished connections kept, default: 1000
+	HistoryRetention      time.Duration `yaml:"history_retention"`       // How long finished connections are kept, default: 1h
+	ParseStreamTokens     *bool         `yaml:"parse_stream_tokens"`     // Extract token usage from passthrough streams, default: true
+}
+
+// StreamTokenParsing reports whether token usage is extracted from passthrough streams
+func (m MonitoringConfig) StreamTokenParsing() bool {
+	return m.ParseStreamTokens == nil || *m.ParseStreamTokens
+}
+
+type StateConfig struct {
+	File      string        `yaml:"file"`       // Runtime state file path, default: state.yaml next to the config file
+	SaveDelay time.Duration `yaml:"save_delay"` // Debounce delay before writing the state file, default: 2s
 }
 
 type EndpointConfig struct {
@@ -131,27 +492,136 @@ type EndpointConfig struct {
 	Priority      int               `yaml:"priority"`
 	Group         string            `yaml:"group,omitempty"`
 	GroupPriority int               `yaml:"group-priority,omitempty"`
+	GroupStrategy string            `yaml:"group-strategy,omitempty"` // Group selection strategy, read from the first endpoint of the group
 	Token         string            `yaml:"token,omitempty"`
+	Tokens        []string          `yaml:"tokens,omitempty"`         // Bearer tokens rotated per request, instead of token
+	TokenCooldown time.Duration     `yaml:"token_cooldown,omitempty"` // How long a token rejected with 401/429 leaves the rotation, default: 5m
 	ApiKey        string            `yaml:"api-key,omitempty"`
 	Timeout       time.Duration     `yaml:"timeout"`
 	Headers       map[string]string `yaml:"headers,omitempty"`
+	Disabled      bool              `yaml:"disabled,omitempty"` // Start in maintenance mode (skipped by selection)
+	HealthMethod  string            `yaml:"health_method,omitempty"` // Probe method: "GET" (default) or "HEAD", which falls back to GET when unsupported
+
+	MaxConcurrentRequests int           `yaml:"max_concurrent_requests,omitempty"` // Max in-flight requests (including streams), 0 = unlimited
+	MaxQueueWait          time.Duration `yaml:"max_queue_wait,omitempty"`          // How long a request waits for a free slot before moving on, 0 = move on at once
+
+	RateLimit *EndpointRateLimitConfig `yaml:"rate_limit,omitempty"` // Requests-per-minute limit, default: unlimited
+
+	UnixPathPrefix string `yaml:"unix_path_prefix,omitempty"` // HTTP path prefix for unix:// endpoints (e.g. /api)
+
+	Models []string `yaml:"models,omitempty"` // Model names or globs (e.g. claude-3-5-*) this endpoint serves, default: all models
+
+	APIFormat string `yaml:"api_format,omitempty"` // Response format token usage is parsed from: "anthropic" (default) or "openai"
+
+	Transform *TransformConfig `yaml:"transform,omitempty"` // Request body rewrites for this endpoint only, default: none
+
+	Auth *EndpointAuthConfig `yaml:"auth,omitempty"` // Authentication method, default: static token/api-key
+
+	// Retry and cooldown overrides of the group, read from the first endpoint of the group like group-strategy
+	GroupRetry      *GroupRetryConfig `yaml:"group-retry,omitempty"`       // Default: the retry section
+	GroupCooldown   time.Duration     `yaml:"group-cooldown,omitempty"`    // Default: group.cooldown
+	GroupMaxRetries *int              `yaml:"group-max-retries,omitempty"` // Default: group.max_retries
+
+	Remote bool `yaml:"-"` // Fetched from endpoints_source rather than defined in the config file
+}
+
+// Response formats of endpoints (api_format)
+const (
+	APIFormatAnthropic = "anthropic" // Claude Messages API usage events
+	APIFormatOpenAI    = "openai"    // OpenAI-compatible usage (prompt_tokens/completion_tokens)
+)
+
+// ResponseFormat returns the format of the endpoint's responses, anthropic when unset
+func (e EndpointConfig) ResponseFormat() string {
+	if e.APIFormat == "" {
+		return APIFormatAnthropic
+	}
+	return strings.ToLower(e.APIFormat)
+}
+
+// unixSocketScheme is the URL scheme for endpoints served over a Unix domain socket
+const unixSocketScheme = "unix://"
+
+// unixSocketHost is the Host used for requests sent over a Unix domain socket
+const unixSocketHost = "localhost"
+
+// IsUnixSocket reports whether the endpoint is reached through a Unix domain socket (unix:///path.sock)
+func (e EndpointConfig) IsUnixSocket() bool {
+	return strings.HasPrefix(e.URL, unixSocketScheme)
+}
+
+// SocketPath returns the filesystem path of a unix:// endpoint's socket
+func (e EndpointConfig) SocketPath() string {
+	if !e.IsUnixSocket() {
+		return ""
+	}
+	return strings.TrimPrefix(e.URL, unixSocketScheme)
+}
+
+// BaseURL returns the HTTP base URL that request paths are appended to. For unix://
+// endpoints this is http://localhost plus the optional unix_path_prefix; the socket
+// itself is dialed by the transport.
+func (e EndpointConfig) BaseURL() string {
+	if !e.IsUnixSocket() {
+		return e.URL
+	}
+	prefix := strings.TrimSuffix(e.UnixPathPrefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return "http://" + unixSocketHost + prefix
+}
+
+// DisplayURL returns the endpoint address for display, showing the socket path for unix:// endpoints
+func (e EndpointConfig) DisplayURL() string {
+	if !e.IsUnixSocket() {
+		return e.URL
+	}
+	return "unix:" + e.SocketPath() + strings.TrimSuffix(e.UnixPathPrefix, "/")
+}
+
+// SupportsModel reports whether the endpoint serves the given model. Endpoints without a
+// models list, and requests that name no model, always match.
+func (e EndpointConfig) SupportsModel(model string) bool {
+	if len(e.Models) == 0 || model == "" {
+		return true
+	}
+	for _, pattern := range e.Models {
+		if matched, _ := path.Match(pattern, model); matched {
+			return true
+		}
+	}
+	return false
 }
 
 // LoadConfig loads configuration from file
 func LoadConfig(path string) (*Config, error) {
+	return loadConfig(path, nil)
+}
+
+// loadConfig loads configuration from file, adding the endpoints of an endpoints_source
+// document (nil for none) before validation
+func loadConfig(path string, remote *remoteEndpoints) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := unmarshalConfig(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
 	// Set defaults
 	config.setDefaults()
 
+	// A document fetched from another URL than the configured one no longer applies
+	if remote != nil && config.EndpointsSource.Enabled() && remote.url == config.EndpointsSource.URL {
+		if err := config.addRemoteEndpoints(remote.document); err != nil {
+			return nil, fmt.Errorf("invalid endpoints_source document: %w", err)
+		}
+	}
+
 	// Validate configuration
 	if err := config.validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -168,9 +638,36 @@ func (c *Config) setDefaults() {
 	if c.Server.Port == 0 {
 		c.Server.Port = 8080
 	}
+	for i := range c.Server.Listeners {
+		listener := &c.Server.Listeners[i]
+		// IPv6 hosts may be written in brackets as in URLs; Address adds them back
+		if strings.HasPrefix(listener.Host, "[") && strings.HasSuffix(listener.Host, "]") {
+			listener.Host = listener.Host[1 : len(listener.Host)-1]
+		}
+		if listener.Addr != "" {
+			// An address without a host (":8080") listens on all interfaces
+			if host, port, err := listener.splitAddress(); err == nil && listener.Host == "" && listener.Port == 0 {
+				listener.Host, listener.Port = host, port
+			}
+			continue
+		}
+		if listener.Host == "" {
+			listener.Host = c.Server.Host
+		}
+	}
 	if c.Strategy.Type == "" {
 		c.Strategy.Type = "priority"
 	}
+	// Set CORS defaults
+	if len(c.Server.CORS.AllowedMethods) == 0 {
+		c.Server.CORS.AllowedMethods = []string{"GET", "POST", "OPTIONS"}
+	}
+	if len(c.Server.CORS.AllowedHeaders) == 0 {
+		c.Server.CORS.AllowedHeaders = []string{"Content-Type", "Authorization", "X-Api-Key", "Anthropic-Version", "Anthropic-Beta", "Cache-Control"}
+	}
+	if c.Server.CORS.MaxAge == 0 {
+		c.Server.CORS.MaxAge = 10 * time.Minute
+	}
 	// Set fast test defaults
 	if c.Strategy.FastTestCacheTTL == 0 {
 		c.Strategy.FastTestCacheTTL = 3 * time.Second // Default 3 seconds cache
@@ -181,6 +678,12 @@ func (c *Config) setDefaults() {
 	if c.Strategy.FastTestPath == "" {
 		c.Strategy.FastTestPath = c.Health.HealthPath // Default to health path
 	}
+	if c.Strategy.EWMAAlpha == 0 {
+		c.Strategy.EWMAAlpha = DefaultEWMAAlpha
+	}
+	if c.Strategy.MinSamples == 0 {
+		c.Strategy.MinSamples = 3
+	}
 	if c.Retry.MaxAttempts == 0 {
 		c.Retry.MaxAttempts = 3
 	}
@@ -193,6 +696,9 @@ func (c *Config) setDefaults() {
 	if c.Retry.Multiplier == 0 {
 		c.Retry.Multiplier = 2.0
 	}
+	if c.Retry.MaxAttemptsCeiling == 0 {
+		c.Retry.MaxAttemptsCeiling = c.Retry.MaxAttempts
+	}
 	if c.Health.CheckInterval == 0 {
 		c.Health.CheckInterval = 30 * time.Second
 	}
@@ -202,6 +708,15 @@ func (c *Config) setDefaults() {
 	if c.Health.HealthPath == "" {
 		c.Health.HealthPath = "/v1/models"
 	}
+	if c.Health.PassiveIdleWindow == 0 {
+		c.Health.PassiveIdleWindow = 2 * time.Minute
+	}
+	if c.Health.HealthyThreshold == 0 {
+		c.Health.HealthyThreshold = 2
+	}
+	if c.Health.UnhealthyThreshold == 0 {
+		c.Health.UnhealthyThreshold = 3
+	}
 	if c.Logging.Level == "" {
 		c.Logging.Level = "info"
 	}
@@ -212,6 +727,18 @@ func (c *Config) setDefaults() {
 	if c.Logging.FileEnabled && c.Logging.FilePath == "" {
 		c.Logging.FilePath = "logs/app.log"
 	}
+	if c.Server.OnLargeBody == "" {
+		c.Server.OnLargeBody = OnLargeBodyReject
+	}
+	if c.Server.ShutdownDrainTimeout == 0 {
+		c.Server.ShutdownDrainTimeout = 30 * time.Second
+	}
+	if c.Server.Compression == "" {
+		c.Server.Compression = CompressionOff
+	}
+	if c.Server.CompressionMinSize == "" {
+		c.Server.CompressionMinSize = "1KB"
+	}
 	if c.Logging.FileEnabled && c.Logging.MaxFileSize == "" {
 		c.Logging.MaxFileSize = "100MB"
 	}
@@ -227,11 +754,17 @@ func (c *Config) setDefaults() {
 	if c.Streaming.MaxIdleTime == 0 {
 		c.Streaming.MaxIdleTime = 120 * time.Second
 	}
+	if c.Streaming.ReplayWindow == 0 {
+		c.Streaming.ReplayWindow = 30 * time.Second
+	}
 
 	// Set global timeout default
 	if c.GlobalTimeout == 0 {
 		c.GlobalTimeout = 300 * time.Second // Default 5 minutes for non-streaming requests
 	}
+	if c.GlobalTimeoutCeiling == 0 {
+		c.GlobalTimeoutCeiling = c.GlobalTimeout
+	}
 
 	// Set group defaults
 	if c.Group.Cooldown == 0 {
@@ -245,6 +778,15 @@ func (c *Config) setDefaults() {
 	if c.TUI.UpdateInterval == 0 {
 		c.TUI.UpdateInterval = 2 * time.Second // Default 2 second refresh (reduced from 1s)
 	}
+	if c.TUI.OverviewInterval == 0 {
+		c.TUI.OverviewInterval = c.TUI.UpdateInterval
+	}
+	if c.TUI.ConnectionsInterval == 0 {
+		c.TUI.ConnectionsInterval = c.TUI.UpdateInterval
+	}
+	if c.TUI.NarrowWidth == 0 {
+		c.TUI.NarrowWidth = 120 // Default: stack views on terminals narrower than 120 columns
+	}
 	// TUI enabled defaults to true if not explicitly set in YAML
 	// This will be handled by the application logic
 	// Save priority edits defaults to false for safety
@@ -257,8 +799,61 @@ func (c *Config) setDefaults() {
 	if c.WebUI.Port == 0 {
 		c.WebUI.Port = 8003
 	}
+	if c.WebUI.SessionTTL == 0 {
+		c.WebUI.SessionTTL = 24 * time.Hour
+	}
+	if c.WebUI.LoginMaxAttempts == 0 {
+		c.WebUI.LoginMaxAttempts = 5
+	}
+	if c.WebUI.LoginLockout == 0 {
+		c.WebUI.LoginLockout = time.Minute
+	}
+	if c.WebUI.CaptureMaxRequests == 0 {
+		c.WebUI.CaptureMaxRequests = 50
+	}
+	if c.WebUI.CaptureMaxBodySize == "" {
+		c.WebUI.CaptureMaxBodySize = "8KB"
+	}
 	// WebUI enabled defaults to false if not explicitly set in YAML
 
+	// Set monitoring defaults
+	if c.Monitoring.MaxClients == 0 {
+		c.Monitoring.MaxClients = 100
+	}
+	if c.Monitoring.TokenHistoryInterval == 0 {
+		c.Monitoring.TokenHistoryInterval = time.Minute
+	}
+	if c.Monitoring.TokenHistoryWindow == 0 {
+		c.Monitoring.TokenHistoryWindow = 24 * time.Hour
+	}
+	if c.Monitoring.TokenHistoryPoints == 0 {
+		c.Monitoring.TokenHistoryPoints = 1440
+	}
+	if c.Monitoring.ConnectionHistorySize == 0 {
+		c.Monitoring.ConnectionHistorySize = 1000
+	}
+	if c.Monitoring.HistoryRetention == 0 {
+		c.Monitoring.HistoryRetention = time.Hour
+	}
+
+	// Set runtime state defaults (file path is resolved relative to the config file by the caller)
+	if c.State.SaveDelay == 0 {
+		c.State.SaveDelay = 2 * time.Second
+	}
+
+	// Set request rule, endpoint auth, rate limit, circuit breaker and load shedding, notification, budget, API compatibility, forwarding, transport and endpoints source defaults
+	c.setRuleDefaults()
+	c.setAuthDefaults()
+	c.setRateLimitDefaults()
+	c.setCircuitBreakerDefaults()
+	c.setLoadSheddingDefaults()
+	c.setNotificationDefaults()
+	c.setBudgetDefaults()
+	c.setCompatDefaults()
+	c.setForwardingDefaults()
+	c.setTransportDefaults()
+	c.setEndpointsSourceDefaults()
+
 	// Set default timeouts for endpoints and handle parameter inheritance (except tokens)
 	var defaultEndpoint *EndpointConfig
 	if len(c.Endpoints) > 0 {
@@ -299,6 +894,10 @@ func (c *Config) setDefaults() {
 			}
 		}
 
+		if len(c.Endpoints[i].Tokens) > 0 && c.Endpoints[i].TokenCooldown == 0 {
+			c.Endpoints[i].TokenCooldown = 5 * time.Minute
+		}
+
 		// NOTE: We do NOT inherit tokens here - tokens will be resolved dynamically at runtime
 		// This allows for proper group-based token switching when groups fail
 
@@ -342,16 +941,9 @@ func (c *Config) ApplyPrimaryEndpoint(logger *slog.Logger) error {
 	// Find the specified endpoint
 	primaryIndex := c.findEndpointIndex(c.PrimaryEndpoint)
 	if primaryIndex == -1 {
-		// Create list of available endpoints for better error message
-		var availableEndpoints []string
-		for _, endpoint := range c.Endpoints {
-			availableEndpoints = append(availableEndpoints, endpoint.Name)
-		}
-
-		err := fmt.Errorf("指定的主端点 '%s' 未找到，可用端点: %v", c.PrimaryEndpoint, availableEndpoints)
+		err := c.primaryNotFound(c.PrimaryEndpoint)
 		if logger != nil {
-			logger.Error(fmt.Sprintf("❌ 主端点设置失败 - 端点: %s, 可用端点: %v",
-				c.PrimaryEndpoint, availableEndpoints))
+			logger.Error(fmt.Sprintf("❌ 主端点设置失败 - %v", err))
 		}
 		return err
 	}
@@ -359,72 +951,421 @@ func (c *Config) ApplyPrimaryEndpoint(logger *slog.Logger) error {
 	// Store original priority for logging
 	originalPriority := c.Endpoints[primaryIndex].Priority
 
-	// Set the primary endpoint to priority 1
-	c.Endpoints[primaryIndex].Priority = 1
+	changed, _ := c.PromoteEndpoint(c.PrimaryEndpoint)
+	adjustedCount := len(changed)
+	if _, promoted := changed[c.PrimaryEndpoint]; promoted {
+		adjustedCount--
+	}
+
+	if logger != nil {
+		logger.Info(fmt.Sprintf("✅ 主端点优先级设置成功 - 端点: %s, 原优先级: %d → 新优先级: %d, 调整了%d个其他端点",
+			c.PrimaryEndpoint, originalPriority, 1, adjustedCount))
+	}
+
+	return nil
+}
+
+// PromoteEndpoint gives the named endpoint priority 1 and moves the other endpoints with
+// priority 1 or less behind it. It returns the original priorities of the endpoints it changed.
+func (c *Config) PromoteEndpoint(name string) (map[string]int, error) {
+	primaryIndex := c.findEndpointIndex(name)
+	if primaryIndex == -1 {
+		return nil, c.primaryNotFound(name)
+	}
+
+	changed := make(map[string]int)
+	if c.Endpoints[primaryIndex].Priority != 1 {
+		changed[name] = c.Endpoints[primaryIndex].Priority
+		c.Endpoints[primaryIndex].Priority = 1
+	}
+
+	// Adjust other endpoints' priorities to ensure they are lower than primary
+	for i := range c.Endpoints {
+		if i != primaryIndex && c.Endpoints[i].Priority <= 1 {
+			changed[c.Endpoints[i].Name] = c.Endpoints[i].Priority
+			c.Endpoints[i].Priority = c.Endpoints[i].Priority + 2 // Use consistent increment
+		}
+	}
+	return changed, nil
+}
+
+// primaryNotFound describes a primary endpoint missing from the config
+func (c *Config) primaryNotFound(name string) error {
+	// Create list of available endpoints for better error message
+	var availableEndpoints []string
+	for _, endpoint := range c.Endpoints {
+		availableEndpoints = append(availableEndpoints, endpoint.Name)
+	}
+	return fmt.Errorf("指定的主端点 '%s' 未找到，可用端点: %v", name, availableEndpoints)
+}
+
+// ParsePrimaryEndpoint splits the -p flag value into the endpoint name and how long the
+// override lasts: "backup" lasts for the process lifetime, "backup=2h" for two hours
+func ParsePrimaryEndpoint(value string) (string, time.Duration, error) {
+	name, after, found := strings.Cut(value, "=")
+	if !found {
+		return value, 0, nil
+	}
+	duration, err := time.ParseDuration(after)
+	if err != nil || duration <= 0 {
+		return "", 0, fmt.Errorf("invalid primary endpoint duration %q, expected e.g. %s=2h", after, name)
+	}
+	if name == "" {
+		return "", 0, fmt.Errorf("primary endpoint name is required before '='")
+	}
+	return name, duration, nil
+}
+
+// StateFilePath returns the runtime state file path, defaulting to state.yaml next to the config file
+func (c *Config) StateFilePath(configPath string) string {
+	if c.State.File != "" {
+		return c.State.File
+	}
+	return filepath.Join(filepath.Dir(configPath), "state.yaml")
+}
+
+// findEndpointIndex finds the index of an endpoint by name
+func (c *Config) findEndpointIndex(name string) int {
+	for i, endpoint := range c.Endpoints {
+		if endpoint.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// validate validates the configuration
+func (c *Config) validate() error {
+	if c.Strategy.Type != "priority" && c.Strategy.Type != "fastest" && c.Strategy.Type != "round-robin" {
+		return fmt.Errorf("strategy type must be 'priority', 'fastest', or 'round-robin'")
+	}
+	if c.Strategy.EWMAAlpha <= 0 || c.Strategy.EWMAAlpha > 1 {
+		return fmt.Errorf("strategy ewma_alpha must be greater than 0 and at most 1, got %v", c.Strategy.EWMAAlpha)
+	}
+	if c.Strategy.MinSamples < 0 {
+		return fmt.Errorf("strategy min_samples must be non-negative")
+	}
+	if sticky := c.Strategy.Stickiness(); sticky < 0 || sticky >= 1 {
+		return fmt.Errorf("strategy sticky_factor must be at least 0 and below 1, got %v", sticky)
+	}
+
+	// Validate proxy configuration
+	if c.Proxy.Enabled {
+		if c.Proxy.Type == "" {
+			return fmt.Errorf("proxy type is required when proxy is enabled")
+		}
+		if c.Proxy.Type != "http" && c.Proxy.Type != "https" && c.Proxy.Type != "socks5" {
+			return fmt.Errorf("proxy type must be 'http', 'https', or 'socks5'")
+		}
+		if c.Proxy.URL == "" && (c.Proxy.Host == "" || c.Proxy.Port == 0) {
+			return fmt.Errorf("proxy URL or host:port must be specified when proxy is enabled")
+		}
+	}
+
+	if c.Monitoring.MaxClients < 0 {
+		return fmt.Errorf("monitoring max_clients must be non-negative")
+	}
+	if c.Monitoring.TokenHistoryInterval < 0 || c.Monitoring.TokenHistoryWindow < c.Monitoring.TokenHistoryInterval {
+		return fmt.Errorf("monitoring token_history_window must be greater than or equal to token_history_interval")
+	}
+	if c.Monitoring.TokenHistoryPoints < 0 || c.Monitoring.ConnectionHistorySize < 0 {
+		return fmt.Errorf("monitoring token_history_points and connection_history_size must be non-negative")
+	}
+	if c.Monitoring.HistoryRetention < 0 {
+		return fmt.Errorf("monitoring history_retention must be non-negative")
+	}
+	if c.Retry.NonIdempotentBodyThreshold < 0 {
+		return fmt.Errorf("retry non_idempotent_body_threshold must be non-negative")
+	}
+	if c.Logging.RotateInterval != 0 && c.Logging.RotateInterval < time.Minute {
+		return fmt.Errorf("logging rotate_interval must be at least 1m")
+	}
+	for _, field := range c.Logging.AccessLogFields {
+		if !slices.Contains(AccessLogFieldNames, field) {
+			return fmt.Errorf("logging access_log_fields: unknown field %q (available: %s)", field, strings.Join(AccessLogFieldNames, ", "))
+		}
+	}
+	if c.Retry.MaxAttemptsCeiling < 0 {
+		return fmt.Errorf("retry max_attempts_ceiling must be non-negative")
+	}
+	if c.GlobalTimeoutCeiling < 0 {
+		return fmt.Errorf("global_timeout_ceiling must be non-negative")
+	}
+	if c.Retry.BudgetPerMinute < 0 {
+		return fmt.Errorf("retry budget_per_minute must be non-negative")
+	}
+	if err := c.Server.TLS.validate(); err != nil {
+		return fmt.Errorf("server %w", err)
+	}
+	if err := c.WebUI.TLS.validate(); err != nil {
+		return fmt.Errorf("webui %w", err)
+	}
+	seenListeners := make(map[string]bool)
+	for i, listener := range c.Server.Listeners {
+		if listener.Addr != "" {
+			host, port, err := listener.splitAddress()
+			if err != nil {
+				return fmt.Errorf("server listener %d: invalid address %q: %w", i, listener.Addr, err)
+			}
+			if listener.Listen != "" || listener.Host != host || listener.Port != port {
+				return fmt.Errorf("server listener %d: address cannot be combined with host, port or listen", i)
+			}
+		}
+		if listener.Listen == "" && (listener.Port <= 0 || listener.Port > 65535) {
+			return fmt.Errorf("server listener %d: port must be between 1 and 65535", i)
+		}
+		if err := listener.TLS.validate(); err != nil {
+			return fmt.Errorf("server listener %d: %w", i, err)
+		}
+		if seenListeners[listener.Address()] {
+			return fmt.Errorf("server listener %d: duplicate address %s", i, listener.Address())
+		}
+		seenListeners[listener.Address()] = true
+	}
+	if c.Server.CORS.Enabled && len(c.Server.CORS.AllowedOrigins) == 0 {
+		return fmt.Errorf("server cors allowed_origins must be set when cors is enabled")
+	}
+	if c.Server.CORS.MaxAge < 0 {
+		return fmt.Errorf("server cors max_age must be non-negative")
+	}
+	if c.State.SaveDelay < 0 {
+		return fmt.Errorf("state save_delay must be non-negative")
+	}
+	if c.Health.PassiveIdleWindow < 0 {
+		return fmt.Errorf("health passive_idle_window must be non-negative")
+	}
+	if c.Health.HealthyThreshold < 0 {
+		return fmt.Errorf("health healthy_threshold must be positive")
+	}
+	if c.Health.UnhealthyThreshold < 0 {
+		return fmt.Errorf("health unhealthy_threshold must be positive")
+	}
+	for _, code := range c.Health.AcceptableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("health acceptable_status_codes must be HTTP status codes (100-599), got %d", code)
+		}
+	}
+	if c.Server.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("server max_concurrent_requests must be non-negative")
+	}
+	if c.Server.ShutdownDrainTimeout < 0 {
+		return fmt.Errorf("server shutdown_drain_timeout must be non-negative")
+	}
+	if c.Server.MaxRequestBodySize != "" {
+		if size, err := logging.ParseSize(c.Server.MaxRequestBodySize); err != nil || size <= 0 {
+			return fmt.Errorf("server max_request_body_size must be a positive size such as \"10MB\", got %q", c.Server.MaxRequestBodySize)
+		}
+	}
+	if c.Retry.MaxBufferedBodySize != "" {
+		if size, err := logging.ParseSize(c.Retry.MaxBufferedBodySize); err != nil || size <= 0 {
+			return fmt.Errorf("retry max_buffered_body_size must be a positive size such as \"32MB\", got %q", c.Retry.MaxBufferedBodySize)
+		}
+	}
+	if _, err := clientip.New(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server trusted_proxies: %w", err)
+	}
+	if c.Server.OnLargeBody != OnLargeBodyReject && c.Server.OnLargeBody != OnLargeBodyStream {
+		return fmt.Errorf("server on_large_body must be 'reject' or 'stream'")
+	}
+	if c.Server.Compression != CompressionOff && c.Server.Compression != CompressionGzip {
+		return fmt.Errorf("server compression must be 'gzip' or 'off'")
+	}
+	if size, err := logging.ParseSize(c.Server.CompressionMinSize); err != nil || size < 0 {
+		return fmt.Errorf("server compression_min_size must be a size such as \"1KB\", got %q", c.Server.CompressionMinSize)
+	}
+	if c.Streaming.ReplayBufferKB < 0 || c.Streaming.ReplayWindow < 0 {
+		return fmt.Errorf("streaming replay_buffer_kb and replay_window must be positive")
+	}
+	if c.TUI.UpdateInterval < 0 || c.TUI.OverviewInterval < 0 || c.TUI.ConnectionsInterval < 0 {
+		return fmt.Errorf("tui update_interval, overview_interval and connections_interval must be positive")
+	}
+	if c.TUI.NarrowWidth < 0 {
+		return fmt.Errorf("tui narrow_width must be positive")
+	}
+	if c.WebUI.SessionTTL < 0 || c.WebUI.LoginMaxAttempts < 0 || c.WebUI.LoginLockout < 0 {
+		return fmt.Errorf("webui session_ttl, login_max_attempts and login_lockout must be positive")
+	}
+	if c.WebUI.SessionMaxAge < 0 {
+		return fmt.Errorf("webui session_max_age must be non-negative")
+	}
+	seenUsers := make(map[string]bool)
+	for i, user := range c.WebUI.Users {
+		if strings.TrimSpace(user.Name) == "" {
+			return fmt.Errorf("webui user %d: name is required", i)
+		}
+		if user.Password == "" {
+			return fmt.Errorf("webui user %s: password is required", user.Name)
+		}
+		if seenUsers[user.Name] {
+			return fmt.Errorf("webui user %s: duplicate name", user.Name)
+		}
+		seenUsers[user.Name] = true
+	}
+	if c.WebUI.CaptureMaxRequests < 0 {
+		return fmt.Errorf("webui capture_max_requests must be positive")
+	}
+	if c.WebUI.CaptureMaxBodySize != "" {
+		if size, err := logging.ParseSize(c.WebUI.CaptureMaxBodySize); err != nil || size <= 0 {
+			return fmt.Errorf("webui capture_max_body_size must be a positive size such as \"8KB\", got %q", c.WebUI.CaptureMaxBodySize)
+		}
+	}
+	for name, settings := range c.Groups {
+		if !isValidGroupStrategy(settings.Strategy) {
+			return fmt.Errorf("group %s: strategy must be 'priority', 'round-robin', or 'least-busy'", name)
+		}
+	}
+
+	for i, endpoint := range c.Endpoints {
+		if endpoint.Name == "" {
+			return fmt.Errorf("endpoint %d: name is required", i)
+		}
+		if endpoint.URL == "" {
+			return fmt.Errorf("endpoint %s: URL is required", endpoint.Name)
+		}
+		if endpoint.Priority < 0 {
+			return fmt.Errorf("endpoint %s: priority must be non-negative", endpoint.Name)
+		}
+		if endpoint.MaxConcurrentRequests < 0 {
+			return fmt.Errorf("endpoint %s: max_concurrent_requests must be non-negative", endpoint.Name)
+		}
+		if endpoint.MaxQueueWait < 0 {
+			return fmt.Errorf("endpoint %s: max_queue_wait must be non-negative", endpoint.Name)
+		}
+		if !isValidGroupStrategy(endpoint.GroupStrategy) {
+			return fmt.Errorf("endpoint %s: group-strategy must be 'priority', 'round-robin', or 'least-busy'", endpoint.Name)
+		}
+		if len(endpoint.Tokens) > 0 {
+			if endpoint.Token != "" || endpoint.IsOAuth2() {
+				return fmt.Errorf("endpoint %s: tokens cannot be combined with token or oauth2 auth", endpoint.Name)
+			}
+			for _, token := range endpoint.Tokens {
+				if strings.TrimSpace(token) == "" {
+					return fmt.Errorf("endpoint %s: tokens must not contain empty entries", endpoint.Name)
+				}
+			}
+		}
+		if endpoint.TokenCooldown < 0 {
+			return fmt.Errorf("endpoint %s: token_cooldown must be non-negative", endpoint.Name)
+		}
+		if method := strings.ToUpper(endpoint.HealthMethod); method != "" && method != "GET" && method != "HEAD" {
+			return fmt.Errorf("endpoint %s: health_method must be 'GET' or 'HEAD'", endpoint.Name)
+		}
+		for _, pattern := range endpoint.Models {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("endpoint %s: models must not contain empty entries", endpoint.Name)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("endpoint %s: invalid model pattern %q: %w", endpoint.Name, pattern, err)
+			}
+		}
+		if endpoint.IsUnixSocket() {
+			if !filepath.IsAbs(endpoint.SocketPath()) {
+				return fmt.Errorf("endpoint %s: unix socket URL must use an absolute path (unix:///path/to/socket.sock)", endpoint.Name)
+			}
+			// The socket may be created later by the upstream service, so only warn
+			if _, err := os.Stat(endpoint.SocketPath()); err != nil {
+				slog.Warn(fmt.Sprintf("⚠️ [配置验证] 端点 %s 的Unix套接字不存在: %s (将在套接字出现后自动可用)",
+					endpoint.Name, endpoint.SocketPath()))
+			}
+		} else if endpoint.UnixPathPrefix != "" {
+			return fmt.Errorf("endpoint %s: unix_path_prefix requires a unix:// URL", endpoint.Name)
+		}
+	}
+
+	if err := c.validateClientAuth(); err != nil {
+		return err
+	}
+
+	if err := c.validateAuth(); err != nil {
+		return err
+	}
+
+	if err := c.validateRateLimits(); err != nil {
+		return err
+	}
+
+	if err := c.validateCircuitBreaker(); err != nil {
+		return err
+	}
+
+	if err := c.validateLoadShedding(); err != nil {
+		return err
+	}
+
+	if err := c.validateGroupPolicies(); err != nil {
+		return err
+	}
+
+	if err := c.validateEndpointsSource(); err != nil {
+		return err
+	}
+
+	if err := c.validateRules(); err != nil {
+		return err
+	}
+
+	if err := c.validateNotifications(); err != nil {
+		return err
+	}
+
+	if err := c.validateBudgets(); err != nil {
+		return err
+	}
 
-	// Adjust other endpoints' priorities to ensure they are lower than primary
-	adjustedCount := 0
-	for i := range c.Endpoints {
-		if i != primaryIndex && c.Endpoints[i].Priority <= 1 {
-			c.Endpoints[i].Priority = c.Endpoints[i].Priority + 2 // Use consistent increment
-			adjustedCount++
-		}
+	if err := c.validateCompat(); err != nil {
+		return err
 	}
 
-	if logger != nil {
-		logger.Info(fmt.Sprintf("✅ 主端点优先级设置成功 - 端点: %s, 原优先级: %d → 新优先级: %d, 调整了%d个其他端点",
-			c.PrimaryEndpoint, originalPriority, 1, adjustedCount))
+	if err := c.validateTransforms(); err != nil {
+		return err
 	}
 
-	return nil
-}
+	if err := c.validateForwarding(); err != nil {
+		return err
+	}
 
-// findEndpointIndex finds the index of an endpoint by name
-func (c *Config) findEndpointIndex(name string) int {
-	for i, endpoint := range c.Endpoints {
-		if endpoint.Name == name {
-			return i
-		}
+	if err := c.validateTransport(); err != nil {
+		return err
 	}
-	return -1
-}
 
-// validate validates the configuration
-func (c *Config) validate() error {
-	if len(c.Endpoints) == 0 {
-		return fmt.Errorf("at least one endpoint must be configured")
+	if err := c.validateListen(); err != nil {
+		return err
 	}
 
-	if c.Strategy.Type != "priority" && c.Strategy.Type != "fastest" && c.Strategy.Type != "round-robin" {
-		return fmt.Errorf("strategy type must be 'priority', 'fastest', or 'round-robin'")
+	if err := c.validateAdmin(); err != nil {
+		return err
 	}
 
-	// Validate proxy configuration
-	if c.Proxy.Enabled {
-		if c.Proxy.Type == "" {
-			return fmt.Errorf("proxy type is required when proxy is enabled")
-		}
-		if c.Proxy.Type != "http" && c.Proxy.Type != "https" && c.Proxy.Type != "socks5" {
-			return fmt.Errorf("proxy type must be 'http', 'https', or 'socks5'")
-		}
-		if c.Proxy.URL == "" && (c.Proxy.Host == "" || c.Proxy.Port == 0) {
-			return fmt.Errorf("proxy URL or host:port must be specified when proxy is enabled")
-		}
+	// Checked last so setup mode can validate every other setting
+	if len(c.Endpoints) == 0 {
+		return ErrNoEndpoints
 	}
 
-	for i, endpoint := range c.Endpoints {
-		if endpoint.Name == "" {
-			return fmt.Errorf("endpoint %d: name is required", i)
-		}
-		if endpoint.URL == "" {
-			return fmt.Errorf("endpoint %s: URL is required", endpoint.Name)
+	return nil
+}
+
+// GetGroupStrategy returns the selection strategy configured for a group. The groups section
+// takes precedence over group-strategy on the group's first endpoint; an empty result means
+// the group follows the global strategy.
+func (c *Config) GetGroupStrategy(groupName string) string {
+	if settings, ok := c.Groups[groupName]; ok && settings.Strategy != "" {
+		return settings.Strategy
+	}
+	for _, endpoint := range c.Endpoints {
+		name := endpoint.Group
+		if name == "" {
+			name = "Default"
 		}
-		if endpoint.Priority < 0 {
-			return fmt.Errorf("endpoint %s: priority must be non-negative", endpoint.Name)
+		if name == groupName {
+			return endpoint.GroupStrategy
 		}
 	}
+	return ""
+}
 
-	return nil
+// GetGroupToken returns the token set for a group in the groups section, or "" when none is set
+func (c *Config) GetGroupToken(groupName string) string {
+	return c.Groups[groupName].Token
 }
 
 // ConfigWatcher handles automatic configuration reloading
@@ -435,14 +1376,59 @@ type ConfigWatcher struct {
 	watcher       *fsnotify.Watcher
 	logger        *slog.Logger
 	callbacks     []func(*Config)
+	errorCallbacks []func(error) // Called when a reload fails
 	lastModTime   time.Time
 	debounceTimer *time.Timer
 	registry      *ConfigRegistry
 	registryPath  string
+	pollInterval  time.Duration
+	degraded      bool // File watch could not be re-established; relying on polling
+	rewatching    bool // A backoff re-add goroutine is running
+	done          chan struct{}
+	closeOnce     sync.Once
+
+	// Endpoints from endpoints_source: the last document that applied, kept while fetches fail
+	remote        *remoteEndpoints
+	sourceWake    chan struct{}  // Refetches right away after the endpoints_source settings changed
+	reloadMutex   sync.Mutex     // Serializes loading and applying configs from the file and the source
+
+	// Activity and errors, exposed through Status for diagnostics
+	lastActivity  time.Time
+	lastReload    time.Time
+	lastError     string
+	lastErrorTime time.Time
+	errorCount    int64
+}
+
+// WatcherStatus describes the config watcher's recent activity
+type WatcherStatus struct {
+	ConfigPath    string
+	Degraded      bool          // Watching fell back to polling
+	PollInterval  time.Duration // How often the polling fallback runs
+	LastActivity  time.Time     // Last file event or polling check
+	LastReload    time.Time     // Last successful reload
+	LastError     string
+	LastErrorTime time.Time
+	ErrorCount    int64 // Watch and reload errors since startup
 }
 
+const (
+	// defaultConfigPollInterval is how often the stat-based fallback checks the config file
+	defaultConfigPollInterval = 30 * time.Second
+	// rewatchInitialDelay and rewatchMaxDelay bound the exponential backoff used when
+	// re-adding the config file to the watcher after it was removed or renamed
+	rewatchInitialDelay = 50 * time.Millisecond
+	rewatchMaxDelay     = 5 * time.Second
+	rewatchMaxAttempts  = 10
+)
+
 // NewConfigWatcher creates a new configuration watcher
 func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, error) {
+	return newConfigWatcher(configPath, logger, defaultConfigPollInterval)
+}
+
+// newConfigWatcher creates a configuration watcher with a custom polling fallback interval
+func newConfigWatcher(configPath string, logger *slog.Logger, pollInterval time.Duration) (*ConfigWatcher, error) {
     // Normalize to absolute path for watcher reliability
     if abs, err := filepath.Abs(configPath); err == nil {
         configPath = abs
@@ -450,16 +1436,34 @@ func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, e
 
     // Load initial configuration
     config, err := LoadConfig(configPath)
+	setupMode := false
 	if err != nil {
-		return nil, fmt.Errorf("failed to load initial config: %w", err)
+		// A missing config or one without endpoints starts in setup mode instead of failing
+		if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrNoEndpoints) {
+			return nil, fmt.Errorf("failed to load initial config: %w", err)
+		}
+		if config, err = LoadSetupConfig(configPath); err != nil {
+			return nil, fmt.Errorf("failed to load initial config: %w", err)
+		}
+		setupMode = true
 	}
 
-	// Get initial modification time
-	fileInfo, err := os.Stat(configPath)
-	if err != nil {
+	// Get initial modification time (the file may not exist yet in setup mode)
+	var lastModTime time.Time
+	if fileInfo, err := os.Stat(configPath); err == nil {
+		lastModTime = fileInfo.ModTime()
+	} else if !setupMode {
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
 
+	configDir := filepath.Dir(configPath)
+	if setupMode {
+		// Imports and the registry are written next to the config file
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+
 	// Create file watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -467,13 +1471,27 @@ func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, e
 	}
 
 	// Initialize config registry
-	configDir := filepath.Dir(configPath)
 	registryPath := filepath.Join(configDir, "registry.yaml")
 	registry, err := ScanAndInitializeRegistry(configDir, registryPath, configPath)
 	if err != nil {
 		logger.Warn("Failed to initialize config registry", "error", err)
 		registry = NewConfigRegistry()
 	}
+	if setupMode {
+		// The registry scan skips configs without endpoints; register the setup config so it
+		// can be edited through the WebUI
+		configName := getConfigNameFromPath(configPath)
+		registry.AddConfig(ConfigMetadata{
+			Name:        configName,
+			FilePath:    configPath,
+			Description: fmt.Sprintf("Setup configuration: %s", configName),
+			IsActive:    true,
+		})
+		registry.SetActiveConfig(configName)
+		if err := registry.Save(registryPath); err != nil {
+			logger.Warn("Failed to save config registry", "error", err)
+		}
+	}
 
 	cw := &ConfigWatcher{
 		configPath:   configPath,
@@ -481,19 +1499,36 @@ func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, e
 		watcher:      watcher,
 		logger:       logger,
 		callbacks:    make([]func(*Config), 0),
-		lastModTime:  fileInfo.ModTime(),
+		lastModTime:  lastModTime,
 		registry:     registry,
 		registryPath: registryPath,
+		pollInterval: pollInterval,
+		done:         make(chan struct{}),
+		sourceWake:   make(chan struct{}, 1),
+		lastActivity: time.Now(),
 	}
 
-	// Add config file to watcher
-	if err := watcher.Add(configPath); err != nil {
+	// Add config file to watcher; in setup mode a missing file is picked up by the directory watch
+	if err := watcher.Add(configPath); err != nil && !(setupMode && lastModTime.IsZero()) {
 		watcher.Close()
 		return nil, fmt.Errorf("failed to watch config file: %w", err)
 	}
 
+	// Also watch the parent directory so that editors which replace the file
+	// (write temp file + rename) still produce a Create event for the config path
+	if err := watcher.Add(configDir); err != nil {
+		logger.Warn(fmt.Sprintf("⚠️ [配置监听] 无法监听配置目录，仅监听配置文件: %v", err))
+	}
+
+	// Fetch the endpoints_source document once before startup, so its endpoints are there from the start
+	if config.EndpointsSource.Enabled() {
+		cw.refreshEndpoints()
+	}
+
 	// Start watching in background
 	go cw.watchLoop()
+	go cw.pollLoop()
+	go cw.endpointsSourceLoop()
 
 	return cw, nil
 }
@@ -519,6 +1554,14 @@ func (cw *ConfigWatcher) AddReloadCallback(callback func(*Config)) {
 	cw.callbacks = append(cw.callbacks, callback)
 }
 
+// AddReloadErrorCallback adds a callback function that will be called when reloading the
+// changed config file fails, e.g. because it no longer parses or validates
+func (cw *ConfigWatcher) AddReloadErrorCallback(callback func(error)) {
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+	cw.errorCallbacks = append(cw.errorCallbacks, callback)
+}
+
 // watchLoop monitors the config file for changes
 func (cw *ConfigWatcher) watchLoop() {
 	for {
@@ -527,68 +1570,282 @@ func (cw *ConfigWatcher) watchLoop() {
 			if !ok {
 				return
 			}
+			cw.recordActivity()
 
-			// Handle file write events
-			if event.Has(fsnotify.Write) {
-				// Check if file was actually modified by comparing modification time
-				fileInfo, err := os.Stat(cw.configPath)
-				if err != nil {
-					cw.logger.Warn(fmt.Sprintf("⚠️ 无法获取配置文件信息: %v", err))
-					continue
-				}
-
-				// Skip if modification time hasn't changed
-				if !fileInfo.ModTime().After(cw.lastModTime) {
-					continue
-				}
-
-				cw.lastModTime = fileInfo.ModTime()
+			// The parent directory is watched too, so ignore events for other files
+			configPath := cw.currentConfigPath()
+			if filepath.Clean(event.Name) != configPath {
+				continue
+			}
 
-				// Cancel any existing debounce timer
-				if cw.debounceTimer != nil {
-					cw.debounceTimer.Stop()
-				}
+			// The config file was (re)created, e.g. renamed over by an editor:
+			// re-establish the file watch and reload
+			if event.Has(fsnotify.Create) {
+				cw.rewatch(configPath)
+				cw.scheduleReload(event.Name)
+			}
 
-				// Set up debounce timer to avoid multiple rapid reloads
-				cw.debounceTimer = time.AfterFunc(500*time.Millisecond, func() {
-					cw.logger.Info(fmt.Sprintf("🔄 检测到配置文件变更，正在重新加载... - 文件: %s", event.Name))
-					if err := cw.reloadConfig(); err != nil {
-						cw.logger.Error(fmt.Sprintf("❌ 配置文件重新加载失败: %v", err))
-					} else {
-						cw.logger.Info("✅ 配置文件重新加载成功")
-					}
-				})
+			// Handle file write events
+			if event.Has(fsnotify.Write) {
+				cw.scheduleReload(event.Name)
 			}
 
 			// Handle file rename/remove events (some editors rename files during save)
 			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
-				// Re-add the file to watcher in case it was recreated
-				time.Sleep(100 * time.Millisecond) // Give time for the file to be recreated
-				if _, err := os.Stat(cw.configPath); err == nil {
-					cw.watcher.Add(cw.configPath)
-					cw.logger.Info(fmt.Sprintf("🔄 重新监听配置文件: %s", cw.configPath))
-				}
+				cw.startRewatch(configPath)
 			}
 
 		case err, ok := <-cw.watcher.Errors:
 			if !ok {
 				return
 			}
+			cw.recordError(err)
 			cw.logger.Error(fmt.Sprintf("⚠️ 配置文件监听错误: %v", err))
 		}
 	}
 }
 
+// recordActivity notes that the watch or polling loop is still running
+func (cw *ConfigWatcher) recordActivity() {
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+	cw.lastActivity = time.Now()
+}
+
+// recordError notes a watch or reload error
+func (cw *ConfigWatcher) recordError(err error) {
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+	cw.lastError = err.Error()
+	cw.lastErrorTime = time.Now()
+	cw.errorCount++
+}
+
+// Status returns the watcher's recent activity and errors (thread-safe)
+func (cw *ConfigWatcher) Status() WatcherStatus {
+	cw.mutex.RLock()
+	defer cw.mutex.RUnlock()
+	return WatcherStatus{
+		ConfigPath:    cw.configPath,
+		Degraded:      cw.degraded,
+		PollInterval:  cw.pollInterval,
+		LastActivity:  cw.lastActivity,
+		LastReload:    cw.lastReload,
+		LastError:     cw.lastError,
+		LastErrorTime: cw.lastErrorTime,
+		ErrorCount:    cw.errorCount,
+	}
+}
+
+// RegistryPath returns the path of the config registry file
+func (cw *ConfigWatcher) RegistryPath() string {
+	cw.mutex.RLock()
+	defer cw.mutex.RUnlock()
+	return cw.registryPath
+}
+
+// currentConfigPath returns the path of the active config file (thread-safe)
+func (cw *ConfigWatcher) currentConfigPath() string {
+	cw.mutex.RLock()
+	defer cw.mutex.RUnlock()
+	return cw.configPath
+}
+
+// scheduleReload reloads the config after a short debounce if its modification time changed
+func (cw *ConfigWatcher) scheduleReload(source string) bool {
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+
+	// Check if file was actually modified by comparing modification time
+	fileInfo, err := os.Stat(cw.configPath)
+	if err != nil {
+		// The file may be briefly missing while an editor replaces it
+		if !os.IsNotExist(err) {
+			cw.logger.Warn(fmt.Sprintf("⚠️ 无法获取配置文件信息: %v", err))
+		}
+		return false
+	}
+
+	// Skip if modification time hasn't changed
+	if fileInfo.ModTime().Equal(cw.lastModTime) {
+		return false
+	}
+
+	cw.lastModTime = fileInfo.ModTime()
+
+	// Cancel any existing debounce timer
+	if cw.debounceTimer != nil {
+		cw.debounceTimer.Stop()
+	}
+
+	// Set up debounce timer to avoid multiple rapid reloads
+	cw.debounceTimer = time.AfterFunc(500*time.Millisecond, func() {
+		cw.logger.Info(fmt.Sprintf("🔄 检测到配置文件变更，正在重新加载... - 文件: %s", source))
+		cw.Reload()
+	})
+	return true
+}
+
+// Reload reloads the active config file right away, running the reload callbacks on success
+// and the error callbacks on failure, in which case the previous configuration stays active
+func (cw *ConfigWatcher) Reload() error {
+	if err := cw.reloadConfig(); err != nil {
+		cw.recordError(err)
+		cw.logger.Error(fmt.Sprintf("❌ 配置文件重新加载失败: %v", err))
+		cw.mutex.RLock()
+		errorCallbacks := make([]func(error), len(cw.errorCallbacks))
+		copy(errorCallbacks, cw.errorCallbacks)
+		cw.mutex.RUnlock()
+		for _, callback := range errorCallbacks {
+			callback(err)
+		}
+		return err
+	}
+	cw.logger.Info("✅ 配置文件重新加载成功")
+	return nil
+}
+
+// rewatch re-adds the config file to the watcher, returning false if it is not available yet
+func (cw *ConfigWatcher) rewatch(configPath string) bool {
+	if _, err := os.Stat(configPath); err != nil {
+		return false
+	}
+	if err := cw.watcher.Add(configPath); err != nil {
+		return false
+	}
+
+	cw.mutex.Lock()
+	wasDegraded := cw.degraded
+	cw.degraded = false
+	cw.mutex.Unlock()
+
+	if wasDegraded {
+		cw.logger.Info(fmt.Sprintf("✅ [配置监听] 文件监听已恢复: %s", configPath))
+	} else {
+		cw.logger.Info(fmt.Sprintf("🔄 重新监听配置文件: %s", configPath))
+	}
+	return true
+}
+
+// startRewatch re-adds the config file in the background with exponential backoff
+func (cw *ConfigWatcher) startRewatch(configPath string) {
+	cw.mutex.Lock()
+	if cw.rewatching {
+		cw.mutex.Unlock()
+		return
+	}
+	cw.rewatching = true
+	cw.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			cw.mutex.Lock()
+			cw.rewatching = false
+			cw.mutex.Unlock()
+		}()
+
+		delay := rewatchInitialDelay
+		for attempt := 0; attempt < rewatchMaxAttempts; attempt++ {
+			select {
+			case <-cw.done:
+				return
+			case <-time.After(delay):
+			}
+
+			// Stop if the active config was switched in the meantime
+			if cw.currentConfigPath() != configPath {
+				return
+			}
+			if cw.rewatch(configPath) {
+				// The replacement may have landed without a Write/Create event we saw
+				cw.scheduleReload(configPath)
+				return
+			}
+
+			delay *= 2
+			if delay > rewatchMaxDelay {
+				delay = rewatchMaxDelay
+			}
+		}
+
+		cw.markDegraded(fmt.Sprintf("重新监听配置文件失败 (%d 次尝试)", rewatchMaxAttempts))
+	}()
+}
+
+// markDegraded records that the fsnotify pipeline can no longer be trusted and
+// logs a warning the first time the watcher falls back to polling
+func (cw *ConfigWatcher) markDegraded(reason string) {
+	cw.mutex.Lock()
+	wasDegraded := cw.degraded
+	cw.degraded = true
+	cw.mutex.Unlock()
+
+	if !wasDegraded {
+		cw.logger.Warn(fmt.Sprintf("⚠️ [配置监听] 文件监听已降级为轮询模式 (每 %v 检查一次): %s", cw.pollInterval, reason))
+	}
+}
+
+// pollLoop periodically stats the config file as a fallback for missed fsnotify events
+func (cw *ConfigWatcher) pollLoop() {
+	if cw.pollInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cw.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cw.done:
+			return
+		case <-ticker.C:
+			cw.pollOnce()
+		}
+	}
+}
+
+// pollOnce reloads the config if its modification time changed without an fsnotify event
+func (cw *ConfigWatcher) pollOnce() {
+	configPath := cw.currentConfigPath()
+	cw.recordActivity()
+
+	cw.mutex.RLock()
+	degraded := cw.degraded
+	cw.mutex.RUnlock()
+
+	// Try to restore the file watch while degraded
+	if degraded {
+		cw.rewatch(configPath)
+	}
+
+	if cw.scheduleReload(configPath) {
+		cw.markDegraded("轮询检测到文件监听未捕获的配置变更")
+	}
+}
+
 // reloadConfig reloads the configuration from file
 func (cw *ConfigWatcher) reloadConfig() error {
-	newConfig, err := LoadConfig(cw.configPath)
+	cw.reloadMutex.Lock()
+	defer cw.reloadMutex.Unlock()
+
+	cw.mutex.RLock()
+	remote := cw.remote
+	cw.mutex.RUnlock()
+	newConfig, err := loadConfig(cw.currentConfigPath(), remote)
 	if err != nil {
 		return err
 	}
+	cw.applyConfig(newConfig)
+	return nil
+}
 
+// applyConfig makes a loaded configuration current and runs the reload callbacks
+// (caller holds reloadMutex)
+func (cw *ConfigWatcher) applyConfig(newConfig *Config) {
 	cw.mutex.Lock()
 	oldConfig := cw.config
 	cw.config = newConfig
+	cw.lastReload = time.Now()
 	callbacks := make([]func(*Config), len(cw.callbacks))
 	copy(callbacks, cw.callbacks)
 	cw.mutex.Unlock()
@@ -601,7 +1858,9 @@ func (cw *ConfigWatcher) reloadConfig() error {
 	// Log configuration changes
 	cw.logConfigChanges(oldConfig, newConfig)
 
-	return nil
+	if oldConfig.EndpointsSource != newConfig.EndpointsSource {
+		cw.wakeEndpointsSource()
+	}
 }
 
 // logConfigChanges logs the key differences between old and new configurations
@@ -612,12 +1871,25 @@ func (cw *ConfigWatcher) logConfigChanges(oldConfig, newConfig *Config) {
 			"new_count", len(newConfig.Endpoints))
 	}
 
+	if added, removed, modified := diffEndpoints(oldConfig.Endpoints, newConfig.Endpoints); len(added)+len(removed)+len(modified) > 0 {
+		cw.logger.Info("📡 端点配置变更",
+			"added", strings.Join(added, ","),
+			"removed", strings.Join(removed, ","),
+			"modified", strings.Join(modified, ","))
+	}
+
 	if oldConfig.Server.Port != newConfig.Server.Port {
 		cw.logger.Info("🌐 服务器端口变更",
 			"old_port", oldConfig.Server.Port,
 			"new_port", newConfig.Server.Port)
 	}
 
+	if oldConfig.Server.Listen != newConfig.Server.Listen {
+		cw.logger.Info("🌐 服务器监听套接字变更",
+			"old_listen", oldConfig.Server.Listen,
+			"new_listen", newConfig.Server.Listen)
+	}
+
 	if oldConfig.Strategy.Type != newConfig.Strategy.Type {
 		cw.logger.Info("🎯 策略类型变更",
 			"old_strategy", oldConfig.Strategy.Type,
@@ -631,12 +1903,75 @@ func (cw *ConfigWatcher) logConfigChanges(oldConfig, newConfig *Config) {
 	}
 }
 
+// DescribeChanges summarizes the differences logConfigChanges logs, such as
+// "endpoints 3→4" or "strategy priority→fastest", for display before a config is applied
+func DescribeChanges(oldConfig, newConfig *Config) []string {
+	changes := make([]string, 0)
+	if len(oldConfig.Endpoints) != len(newConfig.Endpoints) {
+		changes = append(changes, fmt.Sprintf("endpoints %d→%d", len(oldConfig.Endpoints), len(newConfig.Endpoints)))
+	}
+	added, removed, modified := diffEndpoints(oldConfig.Endpoints, newConfig.Endpoints)
+	for _, change := range []struct {
+		label string
+		names []string
+	}{{"added", added}, {"removed", removed}, {"modified", modified}} {
+		if len(change.names) > 0 {
+			changes = append(changes, fmt.Sprintf("endpoints %s: %s", change.label, strings.Join(change.names, ", ")))
+		}
+	}
+	if oldConfig.Server.Port != newConfig.Server.Port {
+		changes = append(changes, fmt.Sprintf("server port %d→%d", oldConfig.Server.Port, newConfig.Server.Port))
+	}
+	if oldConfig.Server.Listen != newConfig.Server.Listen {
+		changes = append(changes, fmt.Sprintf("server listen %q→%q", oldConfig.Server.Listen, newConfig.Server.Listen))
+	}
+	if oldConfig.Strategy.Type != newConfig.Strategy.Type {
+		changes = append(changes, fmt.Sprintf("strategy %s→%s", oldConfig.Strategy.Type, newConfig.Strategy.Type))
+	}
+	if oldConfig.Auth.Enabled != newConfig.Auth.Enabled {
+		changes = append(changes, fmt.Sprintf("auth enabled %v→%v", oldConfig.Auth.Enabled, newConfig.Auth.Enabled))
+	}
+	return changes
+}
+
+// diffEndpoints returns the names of endpoints added, removed and modified between two configs
+func diffEndpoints(oldEndpoints, newEndpoints []EndpointConfig) (added, removed, modified []string) {
+	old := make(map[string]EndpointConfig, len(oldEndpoints))
+	for _, ep := range oldEndpoints {
+		old[ep.Name] = ep
+	}
+	seen := make(map[string]bool, len(newEndpoints))
+	for _, ep := range newEndpoints {
+		seen[ep.Name] = true
+		previous, exists := old[ep.Name]
+		switch {
+		case !exists:
+			added = append(added, ep.Name)
+		case !reflect.DeepEqual(previous, ep):
+			modified = append(modified, ep.Name)
+		}
+	}
+	for _, ep := range oldEndpoints {
+		if !seen[ep.Name] {
+			removed = append(removed, ep.Name)
+		}
+	}
+	return added, removed, modified
+}
+
 // Close stops the configuration watcher
 func (cw *ConfigWatcher) Close() error {
+	// Stop the polling fallback and any pending re-watch attempts
+	cw.closeOnce.Do(func() {
+		close(cw.done)
+	})
+
 	// Cancel any pending debounce timer
+	cw.mutex.Lock()
 	if cw.debounceTimer != nil {
 		cw.debounceTimer.Stop()
 	}
+	cw.mutex.Unlock()
 	return cw.watcher.Close()
 }
 
@@ -664,68 +1999,136 @@ func SaveConfig(config *Config, path string) error {
 
 // SaveConfigWithComments saves configuration to file while preserving all comments
 func SavePriorityConfigWithComments(config *Config, path string) error {
+	rootNode, err := readConfigNode(config, path)
+	if err != nil {
+		return err
+	}
+	endpoints := endpointsNode(rootNode)
+	updatePriorityNodes(endpoints, config)
+	updateGroupPriorityNodes(endpoints, config)
+	return writeConfigNode(rootNode, path)
+}
+
+// readConfigNode decodes the config file into a node tree so comments survive a rewrite.
+// A missing file yields the encoded config.
+func readConfigNode(config *Config, path string) (*yaml.Node, error) {
 	// Read existing file to preserve comments
 	yamlFile, err := os.ReadFile(path)
 	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to read existing config file: %w", err)
+		return nil, fmt.Errorf("failed to read existing config file: %w", err)
 	}
 
-	var rootNode yaml.Node
+	rootNode := &yaml.Node{}
 	if len(yamlFile) > 0 {
 		// Decode existing YAML to preserve structure and comments
-		if err := yaml.Unmarshal(yamlFile, &rootNode); err != nil {
-			return fmt.Errorf("failed to decode existing YAML: %w", err)
+		if err := yaml.Unmarshal(yamlFile, rootNode); err != nil {
+			return nil, fmt.Errorf("failed to decode existing YAML: %w", err)
 		}
 	} else {
 		// Create new YAML structure if file doesn't exist
-		rootNode = yaml.Node{}
 		if err := rootNode.Encode(config); err != nil {
-			return fmt.Errorf("failed to create new YAML structure: %w", err)
+			return nil, fmt.Errorf("failed to create new YAML structure: %w", err)
 		}
 	}
+	return rootNode, nil
+}
 
-	// Update endpoint priorities in the YAML node tree
-	if len(rootNode.Content) > 0 {
-		mappingNode := rootNode.Content[0]
-
-		// Find endpoints section
-		for i := 0; i < len(mappingNode.Content); i += 2 {
-			keyNode := mappingNode.Content[i]
-			valueNode := mappingNode.Content[i+1]
+// endpointsNode returns the endpoints sequence of a config node tree, or nil
+func endpointsNode(rootNode *yaml.Node) *yaml.Node {
+	mappingNode := rootNode
+	if rootNode.Kind == yaml.DocumentNode {
+		if len(rootNode.Content) == 0 {
+			return nil
+		}
+		mappingNode = rootNode.Content[0]
+	}
+	for i := 0; i+1 < len(mappingNode.Content); i += 2 {
+		if mappingNode.Content[i].Value == "endpoints" {
+			return mappingNode.Content[i+1]
+		}
+	}
+	return nil
+}
 
-			if keyNode.Value == "endpoints" {
-				// Update each endpoint's priority
-				for _, endpointNode := range valueNode.Content {
-					var endpointName string
-					var priorityNode *yaml.Node
+// updatePriorityNodes sets the priority of each endpoint in the sequence that has one
+func updatePriorityNodes(endpoints *yaml.Node, config *Config) {
+	if endpoints == nil {
+		return
+	}
+	for _, endpointNode := range endpoints.Content {
+		endpointName := ""
+		if nameNode := mappingValue(endpointNode, "name"); nameNode != nil {
+			endpointName = nameNode.Value
+		}
+		priorityNode := mappingValue(endpointNode, "priority")
+
+		// Find the corresponding endpoint in config and update priority
+		if endpointName != "" && priorityNode != nil {
+			for _, endpoint := range config.Endpoints {
+				if endpoint.Name == endpointName {
+					priorityNode.Value = fmt.Sprintf("%d", endpoint.Priority)
+					break
+				}
+			}
+		}
+	}
+}
 
-					// Find name and priority nodes for this endpoint
-					for j := 0; j < len(endpointNode.Content); j += 2 {
-						fieldKey := endpointNode.Content[j]
-						fieldValue := endpointNode.Content[j+1]
+// updateGroupPriorityNodes writes each endpoint's group priority where the file defines it.
+// group-priority only counts on endpoints that set a group and is otherwise inherited from the
+// previous group, so it is added to a group's first endpoint when the inherited value is wrong.
+func updateGroupPriorityNodes(endpoints *yaml.Node, config *Config) {
+	if endpoints == nil {
+		return
+	}
+	inherited := 1
+	for i, endpointNode := range endpoints.Content {
+		groupNode := mappingValue(endpointNode, "group")
+		nameNode := mappingValue(endpointNode, "name")
+		if (groupNode == nil || groupNode.Value == "") && i > 0 {
+			continue
+		}
 
-						if fieldKey.Value == "name" {
-							endpointName = fieldValue.Value
-						} else if fieldKey.Value == "priority" {
-							priorityNode = fieldValue
-						}
-					}
+		// Group priority as the file currently yields it
+		current := inherited
+		priorityNode := mappingValue(endpointNode, "group-priority")
+		if priorityNode != nil {
+			if value, err := strconv.Atoi(priorityNode.Value); err == nil && value != 0 {
+				current = value
+			}
+		}
 
-					// Find the corresponding endpoint in config and update priority
-					if endpointName != "" && priorityNode != nil {
-						for _, endpoint := range config.Endpoints {
-							if endpoint.Name == endpointName {
-								priorityNode.Value = fmt.Sprintf("%d", endpoint.Priority)
-								break
-							}
+		if nameNode != nil {
+			for _, endpoint := range config.Endpoints {
+				if endpoint.Name == nameNode.Value {
+					if endpoint.GroupPriority != current {
+						if groupNode == nil || groupNode.Value == "" {
+							// The implicit default group needs a group for its priority to count
+							setMappingValue(endpointNode, "group", endpoint.Group)
 						}
+						setMappingValue(endpointNode, "group-priority", fmt.Sprintf("%d", endpoint.GroupPriority))
+						current = endpoint.GroupPriority
 					}
+					break
 				}
-				break
 			}
 		}
+		inherited = current
+	}
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for j := 0; j+1 < len(node.Content); j += 2 {
+		if node.Content[j].Value == key {
+			return node.Content[j+1]
+		}
 	}
+	return nil
+}
 
+// writeConfigNode writes a config node tree back to the file
+func writeConfigNode(rootNode *yaml.Node, path string) error {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -742,7 +2145,7 @@ func SavePriorityConfigWithComments(config *Config, path string) error {
 	// Encode with comments
 	encoder := yaml.NewEncoder(file)
 	encoder.SetIndent(2)
-	if err := encoder.Encode(&rootNode); err != nil {
+	if err := encoder.Encode(rootNode); err != nil {
 		return fmt.Errorf("failed to encode YAML: %w", err)
 	}
 
@@ -969,8 +2372,8 @@ func ScanAndInitializeRegistry(configDir, registryPath, currentConfigPath string
 
 	// Process each config file
 	for _, filePath := range files {
-		// Skip registry file
-		if filepath.Base(filePath) == "registry.yaml" {
+		// Skip registry and runtime state files
+		if filepath.Base(filePath) == "registry.yaml" || filepath.Base(filePath) == "state.yaml" {
 			continue
 		}
 
@@ -1044,18 +2447,19 @@ func getConfigNameFromPath(filePath string) string {
 	return name
 }
 
-// ImportConfigFile imports a configuration file with given name
-func ImportConfigFile(configDir, configName string, configData []byte, registry *ConfigRegistry) (string, error) {
+// ImportConfigFile imports a configuration file with given name. The returned warnings list
+// redaction placeholders and empty credentials that must be filled in before the config is used.
+func ImportConfigFile(configDir, configName string, configData []byte, registry *ConfigRegistry) (string, []CredentialWarning, error) {
 	// Validate config data
 	var testConfig Config
 	if err := yaml.Unmarshal(configData, &testConfig); err != nil {
-		return "", fmt.Errorf("invalid configuration format: %w", err)
+		return "", nil, fmt.Errorf("invalid configuration format: %w", err)
 	}
 
 	// Set defaults and validate
 	testConfig.setDefaults()
 	if err := testConfig.validate(); err != nil {
-		return "", fmt.Errorf("invalid configuration: %w", err)
+		return "", nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
 	// Generate file path
@@ -1065,9 +2469,12 @@ func ImportConfigFile(configDir, configName string, configData []byte, registry
         filePath = abs
     }
 
-	// Write config file
+	// Write config file (the directory may not exist yet on a fresh install)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
 	if err := os.WriteFile(filePath, configData, 0644); err != nil {
-		return "", fmt.Errorf("failed to write config file: %w", err)
+		return "", nil, fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	// Add to registry
@@ -1079,10 +2486,10 @@ func ImportConfigFile(configDir, configName string, configData []byte, registry
 	}
 
 	if err := registry.AddConfig(metadata); err != nil {
-		return "", fmt.Errorf("failed to add to registry: %w", err)
+		return "", nil, fmt.Errorf("failed to add to registry: %w", err)
 	}
 
-	return filePath, nil
+	return filePath, CheckCredentials(configData), nil
 }
 
 // SwitchConfig switches to a different configuration file
@@ -1102,7 +2509,7 @@ func (cw *ConfigWatcher) SwitchConfig(configName string) error {
 	}
 
 	// Load new configuration
-	newConfig, err := LoadConfig(configMeta.FilePath)
+	newConfig, err := loadConfig(configMeta.FilePath, cw.remote)
 	if err != nil {
 		return fmt.Errorf("failed to load new config: %w", err)
 	}
@@ -1133,6 +2540,15 @@ func (cw *ConfigWatcher) SwitchConfig(configName string) error {
 		cw.watcher.Add(oldConfigPath)
 		return fmt.Errorf("failed to watch new config file: %w", err)
 	}
+	cw.degraded = false
+
+	// Move the directory watch along if the new file lives elsewhere
+	if oldDir, newDir := filepath.Dir(oldConfigPath), filepath.Dir(configMeta.FilePath); oldDir != newDir {
+		if err := cw.watcher.Add(newDir); err != nil {
+			cw.logger.Warn("Failed to watch new config directory", "error", err)
+		}
+		cw.watcher.Remove(oldDir)
+	}
 
 	// Update registry active config
 	if err := cw.registry.SetActiveConfig(configName); err != nil {
@@ -1154,6 +2570,7 @@ func (cw *ConfigWatcher) SwitchConfig(configName string) error {
 		callback(newConfig)
 	}
 	cw.mutex.Lock()
+	cw.wakeEndpointsSource()
 
 	cw.logger.Info("🔄 配置已切换", "from", oldConfigPath, "to", configMeta.FilePath, "name", configName)
 
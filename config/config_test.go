@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -453,4 +456,1277 @@ func TestFindEndpointIndex(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestServerListeners(t *testing.T) {
+	// Without listeners the single host/port is used
+	single := ServerConfig{Host: "localhost", Port: 8080}
+	listeners := single.GetListeners()
+	if len(listeners) != 1 || listeners[0].Address() != "localhost:8080" {
+		t.Fatalf("Expected fallback listener localhost:8080, got %+v", listeners)
+	}
+
+	config := &Config{
+		Server: ServerConfig{
+			Host: "127.0.0.1",
+			Listeners: []ListenerConfig{
+				{Port: 8080},
+				{Host: "::1", Port: 8443, TLS: ListenerTLSConfig{Enabled: true, CertFile: "cert.pem", KeyFile: "key.pem"}},
+			},
+		},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid listeners, got %v", err)
+	}
+
+	listeners = config.Server.GetListeners()
+	if listeners[0].Address() != "127.0.0.1:8080" {
+		t.Errorf("Expected listener host to default to server host, got %s", listeners[0].Address())
+	}
+	if listeners[1].URL() != "https://[::1]:8443" {
+		t.Errorf("Expected TLS listener URL https://[::1]:8443, got %s", listeners[1].URL())
+	}
+
+	tests := []struct {
+		name     string
+		listener ListenerConfig
+	}{
+		{"Missing port", ListenerConfig{Host: "0.0.0.0"}},
+		{"TLS without cert", ListenerConfig{Host: "0.0.0.0", Port: 9000, TLS: ListenerTLSConfig{Enabled: true}}},
+		{"Duplicate address", ListenerConfig{Host: "127.0.0.1", Port: 8080}},
+		{"Invalid address", ListenerConfig{Addr: "::1:9000"}},
+		{"Address without port", ListenerConfig{Addr: "[::1]:0"}},
+		{"Address and host", ListenerConfig{Addr: "127.0.0.1:9000", Host: "127.0.0.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalid := *config
+			invalid.Server.Listeners = append([]ListenerConfig{}, config.Server.Listeners...)
+			invalid.Server.Listeners = append(invalid.Server.Listeners, tt.listener)
+			if err := invalid.validate(); err == nil {
+				t.Errorf("Expected validation error for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestServerListenerAddresses(t *testing.T) {
+	config := &Config{
+		Server: ServerConfig{
+			Host: "localhost",
+			Listeners: []ListenerConfig{
+				{Addr: "127.0.0.1:8080"},
+				{Addr: "[::1]:8080"},
+				{Addr: ":9090"},
+				{Host: "[fd00::10]", Port: 8443},
+			},
+		},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid listener addresses, got %v", err)
+	}
+
+	expected := []struct {
+		address string
+		local   bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":9090", false},
+		{"[fd00::10]:8443", false},
+	}
+	for i, want := range expected {
+		listener := config.Server.Listeners[i]
+		if listener.Address() != want.address || listener.IsLocal() != want.local {
+			t.Errorf("Listener %d: expected %s (local %v), got %s (local %v)",
+				i, want.address, want.local, listener.Address(), listener.IsLocal())
+		}
+	}
+}
+
+func TestUnixSocketEndpoint(t *testing.T) {
+	ep := EndpointConfig{Name: "local", URL: "unix:///run/gateway.sock", UnixPathPrefix: "/api/"}
+	if !ep.IsUnixSocket() || ep.SocketPath() != "/run/gateway.sock" {
+		t.Fatalf("Expected socket path /run/gateway.sock, got %q", ep.SocketPath())
+	}
+	if ep.BaseURL() != "http://localhost/api" {
+		t.Errorf("Expected base URL http://localhost/api, got %s", ep.BaseURL())
+	}
+	if ep.DisplayURL() != "unix:/run/gateway.sock/api" {
+		t.Errorf("Expected display URL to show the socket path, got %s", ep.DisplayURL())
+	}
+
+	regular := EndpointConfig{Name: "remote", URL: "https://api.example.com"}
+	if regular.IsUnixSocket() || regular.BaseURL() != regular.URL || regular.DisplayURL() != regular.URL {
+		t.Errorf("Expected regular endpoint URL to be used unchanged")
+	}
+
+	tests := []struct {
+		name     string
+		endpoint EndpointConfig
+		valid    bool
+	}{
+		{"Missing socket only warns", EndpointConfig{Name: "a", URL: "unix:///nonexistent/gateway.sock"}, true},
+		{"Relative socket path", EndpointConfig{Name: "b", URL: "unix://gateway.sock"}, false},
+		{"Prefix on http endpoint", EndpointConfig{Name: "c", URL: "http://localhost:8080", UnixPathPrefix: "/api"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Endpoints: []EndpointConfig{tt.endpoint}}
+			config.setDefaults()
+			err := config.validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid config, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestGroupStrategy(t *testing.T) {
+	config := &Config{
+		Groups: map[string]GroupSettings{"backup": {Strategy: "least-busy"}},
+		Endpoints: []EndpointConfig{
+			{Name: "main-1", URL: "https://a.example.com", Group: "main", GroupPriority: 1, GroupStrategy: "round-robin"},
+			{Name: "main-2", URL: "https://b.example.com"},
+			{Name: "backup-1", URL: "https://c.example.com", Group: "backup", GroupPriority: 2, GroupStrategy: "priority"},
+			{Name: "other-1", URL: "https://d.example.com", Group: "other", GroupPriority: 3},
+		},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	if got := config.GetGroupStrategy("main"); got != "round-robin" {
+		t.Errorf("Expected main group strategy from its first endpoint, got %q", got)
+	}
+	if got := config.GetGroupStrategy("backup"); got != "least-busy" {
+		t.Errorf("Expected groups section to take precedence, got %q", got)
+	}
+	if got := config.GetGroupStrategy("other"); got != "" {
+		t.Errorf("Expected unset strategy for other group, got %q", got)
+	}
+
+	invalid := &Config{
+		Groups:    map[string]GroupSettings{"main": {Strategy: "random"}},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Group: "main"}},
+	}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected validation error for unknown group strategy")
+	}
+
+	invalid = &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", GroupStrategy: "fastest"}}}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected validation error for unsupported group-strategy")
+	}
+}
+
+func TestGroupPolicies(t *testing.T) {
+	zero := 0
+	config := &Config{
+		Retry: RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Multiplier: 2},
+		Group: GroupConfig{Cooldown: 10 * time.Minute, MaxRetries: 3},
+		Groups: map[string]GroupSettings{
+			"premium": {Retry: &GroupRetryConfig{MaxAttempts: 5, BaseDelay: 500 * time.Millisecond}},
+			"free":    {Cooldown: 30 * time.Minute, MaxRetries: &zero},
+		},
+		Endpoints: []EndpointConfig{
+			{Name: "premium-1", URL: "https://a.example.com", Group: "premium",
+				GroupRetry: &GroupRetryConfig{MaxAttempts: 2, Multiplier: 1.5}, GroupCooldown: time.Minute},
+			{Name: "free-1", URL: "https://b.example.com", Group: "free", GroupRetry: &GroupRetryConfig{MaxAttempts: 1}},
+			{Name: "other-1", URL: "https://c.example.com", Group: "other"},
+		},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	want := config.Retry
+	want.MaxAttempts, want.BaseDelay, want.Multiplier = 5, 500*time.Millisecond, 1.5
+	if got := config.GetGroupRetry("premium"); got != want {
+		t.Errorf("Expected groups section over first endpoint over retry section, got %+v", got)
+	}
+	if got := config.GetGroupRetry("free").MaxAttempts; got != 1 {
+		t.Errorf("Expected free group max_attempts from its first endpoint, got %d", got)
+	}
+	if got := config.GetGroupRetry("other"); got != config.Retry {
+		t.Errorf("Expected other group to use the retry section, got %+v", got)
+	}
+	if got := config.GetGroupCooldown("premium"); got != time.Minute {
+		t.Errorf("Expected premium cooldown from its first endpoint, got %v", got)
+	}
+	if got := config.GetGroupCooldown("free"); got != 30*time.Minute {
+		t.Errorf("Expected free cooldown from the groups section, got %v", got)
+	}
+	if got := config.GetGroupCooldown("other"); got != 10*time.Minute {
+		t.Errorf("Expected other group to use group.cooldown, got %v", got)
+	}
+	if got := config.GetGroupMaxRetries("free"); got != 0 {
+		t.Errorf("Expected free group max_retries 0 to be kept, got %d", got)
+	}
+	if got := config.GetGroupMaxRetries("other"); got != 3 {
+		t.Errorf("Expected other group to use group.max_retries, got %d", got)
+	}
+
+	negative := -1
+	invalidGroups := map[string]GroupSettings{
+		"negative attempts": {Retry: &GroupRetryConfig{MaxAttempts: -1}},
+		"negative delay":    {Retry: &GroupRetryConfig{BaseDelay: -time.Second}},
+		"small multiplier":  {Retry: &GroupRetryConfig{Multiplier: 0.5}},
+		"negative cooldown": {Cooldown: -time.Minute},
+		"negative retries":  {MaxRetries: &negative},
+	}
+	for name, settings := range invalidGroups {
+		invalid := &Config{
+			Groups:    map[string]GroupSettings{"main": settings},
+			Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Group: "main"}},
+		}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	invalid := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", GroupRetry: &GroupRetryConfig{Multiplier: 0.9}}}}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected validation error for group-retry multiplier below 1")
+	}
+}
+
+func TestRuleValidation(t *testing.T) {
+	endpoints := []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Group: "main"}}
+
+	config := &Config{
+		Rules: []RuleConfig{
+			{Name: "block", Match: RuleMatchConfig{Glob: "claude-3-opus*"}, Action: RuleActionConfig{Type: RuleActionDeny}},
+			{Name: "route", Match: RuleMatchConfig{Client: "batch-*"}, Action: RuleActionConfig{Type: RuleActionRouteToGroup, Group: "main"}},
+			{Name: "embeddings", Match: RuleMatchConfig{PathRegex: "^/v1/embeddings", Method: "POST"}, Action: RuleActionConfig{Type: RuleActionRouteToGroup, Group: "main"}},
+		},
+		Endpoints: endpoints,
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid rules, got %v", err)
+	}
+	if config.Rules[0].Match.Field != "model" {
+		t.Errorf("Expected body field to default to model, got %q", config.Rules[0].Match.Field)
+	}
+	if config.Rules[0].Action.Status != 403 {
+		t.Errorf("Expected deny status to default to 403, got %d", config.Rules[0].Action.Status)
+	}
+
+	invalidRules := map[string]RuleConfig{
+		"bad regex":      {Name: "r", Match: RuleMatchConfig{Regex: "("}, Action: RuleActionConfig{Type: RuleActionDeny}},
+		"bad path regex": {Name: "r", Match: RuleMatchConfig{PathRegex: "["}, Action: RuleActionConfig{Type: RuleActionDeny}},
+		"method list":    {Name: "r", Match: RuleMatchConfig{Method: "GET,POST"}, Action: RuleActionConfig{Type: RuleActionDeny}},
+		"empty match":    {Name: "r", Action: RuleActionConfig{Type: RuleActionDeny}},
+		"unknown action": {Name: "r", Match: RuleMatchConfig{PathPrefix: "/v1"}, Action: RuleActionConfig{Type: "drop"}},
+		"unknown group":  {Name: "r", Match: RuleMatchConfig{PathPrefix: "/v1"}, Action: RuleActionConfig{Type: RuleActionRouteToGroup, Group: "missing"}},
+		"missing model":  {Name: "r", Match: RuleMatchConfig{PathPrefix: "/v1"}, Action: RuleActionConfig{Type: RuleActionRewriteModel}},
+		"missing name":   {Match: RuleMatchConfig{PathPrefix: "/v1"}, Action: RuleActionConfig{Type: RuleActionDeny}},
+	}
+	for name, rule := range invalidRules {
+		invalid := &Config{Rules: []RuleConfig{rule}, Endpoints: endpoints}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestEndpointAuthValidation(t *testing.T) {
+	config := &Config{Endpoints: []EndpointConfig{{
+		Name: "ep",
+		URL:  "https://api.example.com",
+		Auth: &EndpointAuthConfig{Type: AuthTypeOAuth2, TokenURL: "https://auth.example.com/token", ClientID: "id"},
+	}}}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid oauth2 auth, got %v", err)
+	}
+	if !config.Endpoints[0].IsOAuth2() || config.Endpoints[0].Auth.RefreshMargin != time.Minute {
+		t.Errorf("Expected oauth2 with default refresh margin, got %+v", config.Endpoints[0].Auth)
+	}
+
+	invalidAuth := map[string]EndpointAuthConfig{
+		"unknown type":      {Type: "basic"},
+		"missing token url": {Type: AuthTypeOAuth2, ClientID: "id"},
+		"bad token url":     {Type: AuthTypeOAuth2, TokenURL: "auth.example.com/token", ClientID: "id"},
+		"missing client id": {Type: AuthTypeOAuth2, TokenURL: "https://auth.example.com/token"},
+		"negative margin":   {Type: AuthTypeOAuth2, TokenURL: "https://auth.example.com/token", ClientID: "id", RefreshMargin: -time.Second},
+	}
+	for name, auth := range invalidAuth {
+		auth := auth
+		invalid := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Auth: &auth}}}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestClientAuthValidation(t *testing.T) {
+	endpoints := []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}
+	valid := []AuthConfig{
+		{Enabled: false},
+		{Enabled: true, Token: "shared"},
+		{Enabled: true, Tokens: []AuthToken{{Name: "alice", Token: "a"}, {Name: "bob", Token: "b"}}},
+		{Enabled: true, Token: "shared", Tokens: []AuthToken{{Name: "alice", Token: "a"}}},
+	}
+	for _, auth := range valid {
+		config := &Config{Auth: auth, Endpoints: endpoints}
+		config.setDefaults()
+		if err := config.validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", auth, err)
+		}
+	}
+
+	invalid := map[string]AuthConfig{
+		"no token":        {Enabled: true},
+		"missing name":    {Enabled: true, Tokens: []AuthToken{{Token: "a"}}},
+		"missing token":   {Enabled: true, Tokens: []AuthToken{{Name: "alice"}}},
+		"duplicate name":  {Enabled: true, Tokens: []AuthToken{{Name: "alice", Token: "a"}, {Name: "alice", Token: "b"}}},
+		"duplicate token": {Enabled: true, Tokens: []AuthToken{{Name: "alice", Token: "a"}, {Name: "bob", Token: "a"}}},
+		"shared token":    {Enabled: true, Token: "a", Tokens: []AuthToken{{Name: "alice", Token: "a"}}},
+	}
+	for name, auth := range invalid {
+		config := &Config{Auth: auth, Endpoints: endpoints}
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestNotificationValidation(t *testing.T) {
+	endpoints := []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}
+	webhook := NotifySinkConfig{Name: "hook", Type: NotifySinkWebhook, URL: "https://hooks.example.com/x", Template: `{"text": {{json .Message}}}`}
+
+	config := &Config{
+		Notifications: NotificationsConfig{
+			Enabled:  true,
+			Sinks:    []NotifySinkConfig{webhook, {Name: "mail", Type: NotifySinkSMTP, SMTP: NotifySMTPConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}}},
+			Triggers: []NotifyTriggerConfig{{Event: NotifyEventEndpointUnhealthy, Sinks: []string{"mail"}}, {Event: NotifyEventSuccessRateLow}},
+		},
+		Endpoints: endpoints,
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid notifications, got %v", err)
+	}
+	n := config.Notifications
+	if n.QueueSize != 100 || n.Retry.MaxAttempts != 3 || n.Sinks[1].SMTP.Port != 587 || n.Triggers[0].Cooldown != 5*time.Minute {
+		t.Errorf("Unexpected notification defaults: %+v", n)
+	}
+	if n.Triggers[1].Threshold != 90 || n.Triggers[1].Window != 5*time.Minute || n.Triggers[1].MinRequests != 10 {
+		t.Errorf("Unexpected success_rate_low defaults: %+v", n.Triggers[1])
+	}
+
+	// webhook_url alone is enough to receive every event
+	shorthand := &Config{Notifications: NotificationsConfig{WebhookURL: "http://localhost:9000/hook", WebhookTemplate: `{"text": {{json .Message}}}`}, Endpoints: endpoints}
+	shorthand.setDefaults()
+	if err := shorthand.validate(); err != nil || !shorthand.Notifications.Active() {
+		t.Errorf("Expected webhook_url to be valid and active, got %v", err)
+	}
+
+	invalid := map[string]NotificationsConfig{
+		"no sinks":       {Enabled: true},
+		"unknown type":   {Sinks: []NotifySinkConfig{{Name: "x", Type: "slack"}}},
+		"bad url":        {Sinks: []NotifySinkConfig{{Name: "x", Type: NotifySinkWebhook, URL: "hooks.example.com"}}},
+		"bad template":   {Sinks: []NotifySinkConfig{{Name: "x", Type: NotifySinkWebhook, URL: "https://h.example.com", Template: "{{.Message"}}},
+		"smtp no to":     {Sinks: []NotifySinkConfig{{Name: "x", Type: NotifySinkSMTP, SMTP: NotifySMTPConfig{Host: "h", From: "a@example.com"}}}},
+		"unknown event":  {Sinks: []NotifySinkConfig{webhook}, Triggers: []NotifyTriggerConfig{{Event: "endpoint_slow"}}},
+		"unknown sink":   {Sinks: []NotifySinkConfig{webhook}, Triggers: []NotifyTriggerConfig{{Event: NotifyEventEndpointHealthy, Sinks: []string{"mail"}}}},
+		"bad threshold":  {Sinks: []NotifySinkConfig{webhook}, Triggers: []NotifyTriggerConfig{{Event: NotifyEventSuccessRateLow, Threshold: 120}}},
+		"duplicate sink": {Sinks: []NotifySinkConfig{webhook, webhook}},
+		"bad webhook":    {WebhookURL: "hooks.example.com"},
+		"webhook sink":   {WebhookURL: "https://h.example.com", Triggers: []NotifyTriggerConfig{{Event: NotifyEventAllEndpointsDown, Sinks: []string{"webhook_url"}}}},
+	}
+	for name, notifications := range invalid {
+		cfg := &Config{Notifications: notifications, Endpoints: endpoints}
+		cfg.setDefaults()
+		if err := cfg.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestCompatValidation(t *testing.T) {
+	endpoints := []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}
+
+	config := &Config{
+		Compat:    CompatConfig{OpenAIEnabled: true, ModelMap: map[string]string{"gpt-4o": "claude-sonnet-4-5"}},
+		Endpoints: endpoints,
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid compat settings, got %v", err)
+	}
+	if config.Compat.DefaultMaxTokens != 4096 || config.Compat.AnthropicVersion != "2023-06-01" {
+		t.Errorf("Unexpected compat defaults: %+v", config.Compat)
+	}
+	if config.Compat.MapModel("gpt-4o") != "claude-sonnet-4-5" || config.Compat.MapModel("claude-3-5-haiku") != "claude-3-5-haiku" {
+		t.Errorf("Expected mapped models to be replaced and others passed through")
+	}
+
+	invalid := &Config{Compat: CompatConfig{ModelMap: map[string]string{"gpt-4o": ""}}, Endpoints: endpoints}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for an empty model_map target")
+	}
+}
+
+func TestForwardingValidation(t *testing.T) {
+	endpoints := []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}
+
+	config := &Config{Endpoints: endpoints}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid forwarding defaults, got %v", err)
+	}
+	if config.Forwarding.RequestIDHeader != DefaultRequestIDHeader {
+		t.Errorf("Expected request_id_header to default to %s, got %q", DefaultRequestIDHeader, config.Forwarding.RequestIDHeader)
+	}
+	if config.Forwarding.CoalesceWindow != DefaultCoalesceWindow {
+		t.Errorf("Expected coalesce_window to default to %v, got %v", DefaultCoalesceWindow, config.Forwarding.CoalesceWindow)
+	}
+
+	negativeWindow := &Config{Forwarding: ForwardingConfig{CoalesceIdenticalRequests: true, CoalesceWindow: -time.Second}, Endpoints: endpoints}
+	negativeWindow.setDefaults()
+	if err := negativeWindow.validate(); err == nil {
+		t.Error("Expected an error for a negative coalesce_window")
+	}
+
+	invalid := &Config{Forwarding: ForwardingConfig{RequestIDHeader: "X-Request Id"}, Endpoints: endpoints}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for a request_id_header with a space")
+	}
+
+	invalidHeaders := map[string]HeaderRulesConfig{
+		"bare wildcard":      {StripRequestHeaders: []string{"*"}},
+		"inner wildcard":     {StripResponseHeaders: []string{"X-*-Id"}},
+		"empty name":         {StripRequestHeaders: []string{""}},
+		"name with colon":    {AddRequestHeaders: map[string]string{"X-Tenant:": "a"}},
+		"value with newline": {AddRequestHeaders: map[string]string{"X-Tenant": "a\r\nX-Injected: 1"}},
+	}
+	for name, headers := range invalidHeaders {
+		config := &Config{Forwarding: ForwardingConfig{Headers: headers}, Endpoints: endpoints}
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	rules := HeaderRulesConfig{StripRequestHeaders: []string{"X-Internal-*", "x-debug"}}
+	for header, want := range map[string]bool{"x-internal-user": true, "X-INTERNAL-": true, "X-Debug": true, "X-Debugger": false, "X-Internal": false} {
+		if got := rules.StripsRequestHeader(header); got != want {
+			t.Errorf("StripsRequestHeader(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestTransportValidation(t *testing.T) {
+	endpoints := []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}
+
+	config := &Config{Endpoints: endpoints}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid transport defaults, got %v", err)
+	}
+	want := TransportConfig{MaxIdleConns: 100, MaxIdleConnsPerHost: 10, IdleConnTimeout: 90 * time.Second, TLSHandshakeTimeout: 10 * time.Second}
+	if config.Transport != want {
+		t.Errorf("Expected transport defaults %+v, got %+v", want, config.Transport)
+	}
+
+	invalid := []TransportConfig{
+		{MaxConnsPerHost: -1},
+		{IdleConnTimeout: -time.Second},
+		{MaxIdleConnsPerHost: 20, MaxConnsPerHost: 5},
+	}
+	for _, transport := range invalid {
+		config := &Config{Transport: transport, Endpoints: endpoints}
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("Expected an error for transport settings %+v", transport)
+		}
+	}
+}
+
+func TestTUIViewIntervals(t *testing.T) {
+	config := &Config{
+		TUI:       TUIConfig{UpdateInterval: 3 * time.Second, ConnectionsInterval: 500 * time.Millisecond},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	if config.TUI.OverviewInterval != 3*time.Second {
+		t.Errorf("Expected overview interval to default to update_interval, got %v", config.TUI.OverviewInterval)
+	}
+	if config.TUI.ConnectionsInterval != 500*time.Millisecond {
+		t.Errorf("Expected connections interval override to be kept, got %v", config.TUI.ConnectionsInterval)
+	}
+}
+
+func TestWriteTemplateStartsSetupMode(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "conf", "config.yaml")
+	if err := WriteTemplate(configPath); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if err := WriteTemplate(configPath); err == nil {
+		t.Error("Expected WriteTemplate to refuse overwriting an existing file")
+	}
+
+	if _, err := LoadConfig(configPath); !errors.Is(err, ErrNoEndpoints) {
+		t.Fatalf("Expected template to have no endpoints, got %v", err)
+	}
+
+	config, err := LoadSetupConfig(configPath)
+	if err != nil {
+		t.Fatalf("Expected template to load in setup mode, got %v", err)
+	}
+	if !config.IsSetupMode() || !config.WebUI.Enabled {
+		t.Errorf("Expected setup mode with WebUI enabled, got setup=%v webui=%v", config.IsSetupMode(), config.WebUI.Enabled)
+	}
+
+	// A missing file falls back to defaults
+	config, err = LoadSetupConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || config.Server.Port != 8080 {
+		t.Fatalf("Expected default setup config, got %+v, %v", config, err)
+	}
+
+	// Other settings are still validated
+	invalidPath := filepath.Join(t.TempDir(), "invalid.yaml")
+	os.WriteFile(invalidPath, []byte("strategy:\n  type: \"random\"\n"), 0644)
+	if _, err := LoadSetupConfig(invalidPath); err == nil {
+		t.Error("Expected invalid settings to be rejected in setup mode")
+	}
+}
+
+func TestSaveEndpointEditsWithComments(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := `# Forwarder config
+endpoints:
+  - name: "primary"  # main upstream
+    url: "https://primary.example.com"
+    priority: 1
+    group: "main"
+    group-priority: 1
+    timeout: "30s"
+  - name: "secondary"
+    url: "https://secondary.example.com"
+    priority: 2
+  - name: "backup"
+    url: "https://backup.example.com"
+    priority: 1
+    group: "backup"
+    group-priority: 2
+`
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Move primary to the backup group with a longer timeout and change backup's URL
+	config.Endpoints[0].Priority = 3
+	config.Endpoints[0].Group, config.Endpoints[0].GroupPriority = "backup", 2
+	config.Endpoints[0].Timeout = time.Minute
+	config.Endpoints[2].URL = "https://backup2.example.com"
+	edits := map[int]EndpointEdit{
+		0: {Timeout: time.Minute, Group: "backup", GroupPriority: 2},
+		2: {URL: "https://backup2.example.com"},
+	}
+	if err := SaveEndpointEditsWithComments(config, configPath, edits); err != nil {
+		t.Fatalf("Failed to save edits: %v", err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	for _, comment := range []string{"# Forwarder config", "# main upstream"} {
+		if !strings.Contains(string(data), comment) {
+			t.Errorf("Expected comment %q to be preserved:\n%s", comment, data)
+		}
+	}
+
+	saved, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload saved config: %v\n%s", err, data)
+	}
+	want := []struct {
+		group    string
+		priority int
+		timeout  time.Duration
+		url      string
+	}{
+		{"backup", 3, time.Minute, "https://primary.example.com"},
+		// Inherited group and timeout are pinned to their previous values
+		{"main", 2, 30 * time.Second, "https://secondary.example.com"},
+		{"backup", 1, 30 * time.Second, "https://backup2.example.com"},
+	}
+	for i, w := range want {
+		ep := saved.Endpoints[i]
+		if ep.Group != w.group || ep.Priority != w.priority || ep.Timeout != w.timeout || ep.URL != w.url {
+			t.Errorf("Endpoint %s: expected %+v, got group=%s priority=%d timeout=%v url=%s",
+				ep.Name, w, ep.Group, ep.Priority, ep.Timeout, ep.URL)
+		}
+	}
+	if saved.Endpoints[1].GroupPriority != 1 {
+		t.Errorf("Expected secondary to keep group priority 1, got %d", saved.Endpoints[1].GroupPriority)
+	}
+
+	// A file that no longer matches the running config is not overwritten
+	saved.Endpoints = saved.Endpoints[:2]
+	if err := SaveEndpointEditsWithComments(saved, configPath, nil); err == nil {
+		t.Error("Expected an error when the file's endpoints differ from the config")
+	}
+}
+
+func TestSaveGroupPriorities(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := `endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+    priority: 1
+    group: "main"
+    group-priority: 1  # served first
+  - name: "secondary"
+    url: "https://secondary.example.com"
+    priority: 2
+  - name: "backup"
+    url: "https://backup.example.com"
+    priority: 1
+    group: "backup"
+`
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// backup has no group-priority of its own and inherits main's, so swapping must pin it
+	for i := range config.Endpoints {
+		if config.Endpoints[i].Group == "main" {
+			config.Endpoints[i].GroupPriority = 2
+		} else {
+			config.Endpoints[i].GroupPriority = 1
+		}
+	}
+	if err := SavePriorityConfigWithComments(config, configPath); err != nil {
+		t.Fatalf("Failed to save priorities: %v", err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(data), "# served first") {
+		t.Errorf("Expected comments to be preserved:\n%s", data)
+	}
+	saved, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload saved config: %v\n%s", err, data)
+	}
+	for _, ep := range saved.Endpoints {
+		want := 1
+		if ep.Group == "main" {
+			want = 2
+		}
+		if ep.GroupPriority != want {
+			t.Errorf("Endpoint %s: expected group priority %d, got %d\n%s", ep.Name, want, ep.GroupPriority, data)
+		}
+	}
+}
+
+func TestSaveEditsAlias(t *testing.T) {
+	if (TUIConfig{}).SaveEditsEnabled() {
+		t.Error("Expected saving edits to be disabled by default")
+	}
+	if !(TUIConfig{SavePriorityEdits: true}).SaveEditsEnabled() || !(TUIConfig{SaveEdits: true}).SaveEditsEnabled() {
+		t.Error("Expected save_edits and save_priority_edits to both enable saving")
+	}
+}
+
+func TestHealthProbeValidation(t *testing.T) {
+	config := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", HealthMethod: "head"}}}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected health_method head to be valid, got %v", err)
+	}
+	if config.Health.PassiveIdleWindow != 2*time.Minute {
+		t.Errorf("Expected passive_idle_window to default to 2m, got %v", config.Health.PassiveIdleWindow)
+	}
+	if config.Health.HealthyThreshold != 2 || config.Health.UnhealthyThreshold != 3 {
+		t.Errorf("Expected health thresholds to default to 2 and 3, got %d and %d", config.Health.HealthyThreshold, config.Health.UnhealthyThreshold)
+	}
+
+	invalid := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", HealthMethod: "POST"}}}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for health_method POST")
+	}
+
+	badCodes := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}}
+	badCodes.setDefaults()
+	badCodes.Health.AcceptableStatusCodes = []int{401, 1000}
+	if err := badCodes.validate(); err == nil {
+		t.Error("Expected an error for acceptable_status_codes outside 100-599")
+	}
+
+	badThreshold := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}}
+	badThreshold.Health.UnhealthyThreshold = -1
+	badThreshold.setDefaults()
+	if err := badThreshold.validate(); err == nil {
+		t.Error("Expected an error for a negative unhealthy_threshold")
+	}
+}
+
+func TestRateLimitValidation(t *testing.T) {
+	config := &Config{Endpoints: []EndpointConfig{{
+		Name:      "ep",
+		URL:       "https://api.example.com",
+		RateLimit: &EndpointRateLimitConfig{RequestsPerMinute: 50},
+	}}}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid rate limit, got %v", err)
+	}
+	limit := config.Endpoints[0].RateLimit
+	if limit.Burst != 50 || limit.OnExceeded != RateLimitFailover || limit.MaxWait != 30*time.Second {
+		t.Errorf("Expected burst 50, failover and a 30s max wait by default, got %+v", limit)
+	}
+
+	invalidLimits := map[string]EndpointRateLimitConfig{
+		"missing rate":     {},
+		"negative burst":   {RequestsPerMinute: 50, Burst: -1},
+		"unknown behavior": {RequestsPerMinute: 50, OnExceeded: "drop"},
+		"negative wait":    {RequestsPerMinute: 50, OnExceeded: RateLimitQueue, MaxWait: -time.Second},
+	}
+	for name, limit := range invalidLimits {
+		limit := limit
+		invalid := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", RateLimit: &limit}}}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	config := &Config{
+		Server:    ServerConfig{MaxRequestBodySize: "10MB"},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid body size limit, got %v", err)
+	}
+	if config.Server.MaxRequestBodyBytes() != 10<<20 || config.Server.OnLargeBody != OnLargeBodyReject {
+		t.Errorf("Expected 10MB with on_large_body reject, got %d and %q", config.Server.MaxRequestBodyBytes(), config.Server.OnLargeBody)
+	}
+
+	invalidServers := map[string]ServerConfig{
+		"bad size":     {MaxRequestBodySize: "ten megabytes"},
+		"zero size":    {MaxRequestBodySize: "0MB"},
+		"bad behavior": {MaxRequestBodySize: "10MB", OnLargeBody: "truncate"},
+	}
+	for name, server := range invalidServers {
+		invalid := &Config{Server: server, Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestCircuitBreakerDefaults(t *testing.T) {
+	config := &Config{
+		CircuitBreaker: CircuitBreakerConfig{Enabled: true},
+		Endpoints:      []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid circuit breaker, got %v", err)
+	}
+	cb := config.CircuitBreaker
+	if cb.FailureThreshold != 5 || cb.OpenDuration != 30*time.Second || cb.HalfOpenMaxRequests != 1 {
+		t.Errorf("Expected defaults 5/30s/1, got %+v", cb)
+	}
+
+	invalid := &Config{
+		CircuitBreaker: CircuitBreakerConfig{Enabled: true, OpenDuration: -time.Second},
+		Endpoints:      []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for a negative open_duration")
+	}
+}
+
+func TestTimeoutCeilingDefaults(t *testing.T) {
+	config := &Config{
+		GlobalTimeout: 10 * time.Minute,
+		Endpoints:     []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid timeout config, got %v", err)
+	}
+	if config.GlobalTimeoutCeiling != 10*time.Minute {
+		t.Errorf("Expected the ceiling to default to global_timeout, got %v", config.GlobalTimeoutCeiling)
+	}
+
+	invalid := &Config{
+		GlobalTimeoutCeiling: -time.Second,
+		Endpoints:            []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for a negative global_timeout_ceiling")
+	}
+}
+
+func TestCompressionDefaults(t *testing.T) {
+	config := &Config{
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid compression config, got %v", err)
+	}
+	if config.Server.Compression != CompressionOff || config.Server.CompressionMinBytes() != 1024 {
+		t.Errorf("Expected compression off with a 1KB threshold, got %q and %d", config.Server.Compression, config.Server.CompressionMinBytes())
+	}
+
+	for _, server := range []ServerConfig{{Compression: "br"}, {Compression: CompressionGzip, CompressionMinSize: "large"}} {
+		invalid := &Config{
+			Server:    server,
+			Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+		}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("Expected an error for compression %q with min size %q", server.Compression, server.CompressionMinSize)
+		}
+	}
+}
+
+func TestStreamReplayDefaults(t *testing.T) {
+	config := &Config{
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid streaming config, got %v", err)
+	}
+	if config.Streaming.ReplayEnabled() || config.Streaming.ReplayWindow != 30*time.Second {
+		t.Errorf("Expected stream replay off with a 30s window, got %d KB and %v", config.Streaming.ReplayBufferKB, config.Streaming.ReplayWindow)
+	}
+
+	invalid := &Config{
+		Streaming: StreamingConfig{ReplayBufferKB: -1},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for a negative replay_buffer_kb")
+	}
+}
+
+func TestRetryOverrideDefaults(t *testing.T) {
+	config := &Config{
+		Retry:     RetryConfig{MaxAttempts: 4},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a valid retry config, got %v", err)
+	}
+	if config.Retry.MaxAttemptsCeiling != 4 || config.Retry.BudgetPerMinute != 0 {
+		t.Errorf("Expected ceiling 4 and an unlimited budget, got %d and %d", config.Retry.MaxAttemptsCeiling, config.Retry.BudgetPerMinute)
+	}
+
+	invalid := &Config{
+		Retry:     RetryConfig{BudgetPerMinute: -1},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for a negative budget_per_minute")
+	}
+}
+
+func TestAccessLogFieldsValidation(t *testing.T) {
+	config := &Config{
+		Logging:   LoggingConfig{Format: "json", AccessLogFields: []string{"timestamp", "endpoint", "duration_ms"}},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid access_log_fields, got %v", err)
+	}
+
+	invalid := &Config{
+		Logging:   LoggingConfig{Format: "json", AccessLogFields: []string{"endpoint", "latency"}},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for an unknown access_log_fields entry")
+	}
+}
+
+func TestEndpointModels(t *testing.T) {
+	ep := EndpointConfig{Name: "ep", URL: "https://api.example.com", Models: []string{"claude-3-haiku-20240307", "claude-3-5-*"}}
+	for model, want := range map[string]bool{
+		"claude-3-haiku-20240307":    true,
+		"claude-3-5-sonnet-20241022": true,
+		"claude-3-opus-20240229":     false,
+		"":                           true,
+	} {
+		if got := ep.SupportsModel(model); got != want {
+			t.Errorf("SupportsModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if !(EndpointConfig{}).SupportsModel("claude-3-opus-20240229") {
+		t.Error("Expected an endpoint without models to serve every model")
+	}
+
+	valid := &Config{Endpoints: []EndpointConfig{ep}}
+	valid.setDefaults()
+	if err := valid.validate(); err != nil {
+		t.Fatalf("Expected models to be valid, got %v", err)
+	}
+	invalid := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Models: []string{"claude-[3"}}}}
+	invalid.setDefaults()
+	if err := invalid.validate(); err == nil {
+		t.Error("Expected an error for a malformed model pattern")
+	}
+}
+
+func TestStrategyLatencySettings(t *testing.T) {
+	config := &Config{Strategy: StrategyConfig{Type: "fastest"}, Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected defaults to be valid, got %v", err)
+	}
+	if config.Strategy.EWMAAlpha != DefaultEWMAAlpha || config.Strategy.MinSamples != 3 || config.Strategy.Stickiness() != DefaultStickyFactor {
+		t.Errorf("Expected alpha %v, 3 samples and sticky factor %v by default, got %+v", DefaultEWMAAlpha, DefaultStickyFactor, config.Strategy)
+	}
+
+	off := 0.0
+	config.Strategy.StickyFactor = &off
+	if config.Strategy.Stickiness() != 0 {
+		t.Errorf("Expected an explicit sticky_factor of 0 to disable stickiness, got %v", config.Strategy.Stickiness())
+	}
+
+	tooSticky := 1.0
+	invalid := map[string]StrategyConfig{
+		"alpha above 1":      {Type: "fastest", EWMAAlpha: 1.5},
+		"negative alpha":     {Type: "fastest", EWMAAlpha: -0.1},
+		"negative samples":   {Type: "fastest", MinSamples: -1},
+		"sticky factor of 1": {Type: "fastest", StickyFactor: &tooSticky},
+	}
+	for name, strategy := range invalid {
+		config := &Config{Strategy: strategy, Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}}
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestEndpointTokensValidation(t *testing.T) {
+	config := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Tokens: []string{"sk-a", "sk-b"}}}}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected a tokens list to be valid, got %v", err)
+	}
+	if config.Endpoints[0].TokenCooldown != 5*time.Minute {
+		t.Errorf("Expected token_cooldown to default to 5m, got %v", config.Endpoints[0].TokenCooldown)
+	}
+
+	invalid := map[string]EndpointConfig{
+		"token and tokens":  {Name: "ep", URL: "https://api.example.com", Token: "sk-a", Tokens: []string{"sk-b"}},
+		"empty token entry": {Name: "ep", URL: "https://api.example.com", Tokens: []string{"sk-a", " "}},
+		"negative cooldown": {Name: "ep", URL: "https://api.example.com", Tokens: []string{"sk-a"}, TokenCooldown: -time.Second},
+		"oauth2 and tokens": {Name: "ep", URL: "https://api.example.com", Tokens: []string{"sk-a"},
+			Auth: &EndpointAuthConfig{Type: AuthTypeOAuth2, TokenURL: "https://auth.example.com/token", ClientID: "id"}},
+	}
+	for name, endpoint := range invalid {
+		config := &Config{Endpoints: []EndpointConfig{endpoint}}
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestTransformValidation(t *testing.T) {
+	valid := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Transform: &TransformConfig{
+		RemoveFields:  []string{"metadata"},
+		RenameFields:  map[string]string{"stop_sequences": "stop"},
+		DefaultFields: map[string]any{"max_tokens": 4096, "thinking": map[string]any{"type": "disabled"}},
+	}}}}
+	valid.setDefaults()
+	if err := valid.validate(); err != nil {
+		t.Fatalf("Expected the transform to be valid, got %v", err)
+	}
+
+	invalid := map[string]*TransformConfig{
+		"empty remove field": {RemoveFields: []string{""}},
+		"rename to itself":   {RenameFields: map[string]string{"stop": "stop"}},
+		"rename without new": {RenameFields: map[string]string{"stop_sequences": ""}},
+		"unnamed default":    {DefaultFields: map[string]any{"": 1}},
+		"unencodable value":  {DefaultFields: map[string]any{"bad": make(chan int)}},
+	}
+	for name, transform := range invalid {
+		config := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com", Transform: transform}}}
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestListenValidation(t *testing.T) {
+	valid := &Config{
+		Server:    ServerConfig{Listen: "unix:///run/forwarder.sock", SocketMode: "0600"},
+		WebUI:     WebUIConfig{Listen: "systemd:webui"},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	valid.setDefaults()
+	if err := valid.validate(); err != nil {
+		t.Fatalf("Expected the listen settings to be valid, got %v", err)
+	}
+	listeners := valid.Server.GetListeners()
+	if len(listeners) != 1 || listeners[0].Address() != "/run/forwarder.sock" || listeners[0].SocketFileMode() != 0600 || !listeners[0].IsLocal() {
+		t.Errorf("Expected a single local socket listener with mode 0600, got %+v", listeners)
+	}
+
+	invalid := map[string]func(c *Config){
+		"relative path":       func(c *Config) { c.Server.Listen = "unix://run/forwarder.sock" },
+		"unknown scheme":      func(c *Config) { c.Server.Listen = "tcp://127.0.0.1:8080" },
+		"empty systemd name":  func(c *Config) { c.WebUI.Listen = "systemd:" },
+		"non-octal mode":      func(c *Config) { c.Server.Listen = "unix:///run/forwarder.sock"; c.Server.SocketMode = "rw-rw----" },
+		"mode too large":      func(c *Config) { c.WebUI.SocketMode = "01777" },
+		"shared socket":       func(c *Config) { c.Server.Listen = "unix:///run/ef.sock"; c.WebUI.Listen = "unix:///run/ef.sock" },
+		"admin socket":        func(c *Config) { c.Admin.SocketPath = "/run/ef.sock"; c.Server.Listen = "unix:///run/ef.sock" },
+		"listen and listener": func(c *Config) { c.Server.Listen = "systemd"; c.Server.Listeners = []ListenerConfig{{Port: 8080}} },
+		"path too long":       func(c *Config) { c.Server.Listen = "unix:///" + strings.Repeat("a", maxSocketPathLength) },
+	}
+	for name, modify := range invalid {
+		config := &Config{Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}}}
+		modify(config)
+		config.setDefaults()
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	// A socket listener needs no port
+	config := &Config{
+		Server:    ServerConfig{Listeners: []ListenerConfig{{Port: 8080}, {Listen: "unix:///run/forwarder.sock"}}},
+		Endpoints: []EndpointConfig{{Name: "ep", URL: "https://api.example.com"}},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Errorf("Expected a socket listener without port to be valid, got %v", err)
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	messages := func(report *CheckReport, severity string) string {
+		var matched []string
+		for _, issue := range report.Issues {
+			if issue.Severity == severity {
+				matched = append(matched, issue.Message)
+			}
+		}
+		return strings.Join(matched, "\n")
+	}
+
+	valid := CheckConfigFile(write(`
+endpoints:
+  - name: primary
+    url: https://api.example.com
+    priority: 1
+  - name: backup
+    url: https://backup.example.com
+    priority: 2
+`))
+	if len(valid.Issues) != 0 {
+		t.Errorf("Expected no issues for a valid config, got %+v", valid.Issues)
+	}
+
+	// Passes validate() but would break name lookups and the WebUI
+	report := CheckConfigFile(write(`
+strategy:
+  type: priority
+  fast_test_enabled: true
+webui:
+  enabled: true
+  host: 0.0.0.0
+  port: 8080
+  tls:
+    enabled: true
+    cert_file: missing.crt
+    key_file: missing.key
+endpoints:
+  - name: primary
+    url: https://api.example.com
+    priority: 1
+  - name: primary
+    url: https://backup.example.com
+    priority: 2
+  - name: other
+    url: https://other.example.com
+    priority: 2
+`))
+	if !report.HasErrors() {
+		t.Fatal("Expected errors for a duplicate endpoint name, a port collision and missing TLS files")
+	}
+	errs := messages(report, CheckError)
+	for _, want := range []string{"duplicate endpoint name", "collides with server listener", "webui tls: cannot load certificate missing.crt"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("Expected an error containing %q, got:\n%s", want, errs)
+		}
+	}
+	if issues := report.EndpointIssues("primary"); len(issues) != 1 {
+		t.Errorf("Expected the duplicate name to be reported on the endpoint, got %+v", issues)
+	}
+	warnings := messages(report, CheckWarning)
+	for _, want := range []string{"priority 2 is also used by primary", "fast_test_enabled has no effect"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("Expected a warning containing %q, got:\n%s", want, warnings)
+		}
+	}
+
+	// Malformed durations are explained and stop the check
+	malformed := CheckConfigFile(write("health:\n  timeout: 30\n"))
+	if malformed.Config != nil || !strings.Contains(messages(malformed, CheckError), `"30s"`) {
+		t.Errorf("Expected a parse error explaining duration syntax, got %+v", malformed.Issues)
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	valid := "endpoints:\n  - name: primary\n    url: https://api.example.com\n"
+	if cfg, fieldErr := ValidateContent([]byte(valid)); fieldErr != nil || cfg.Strategy.Type != "priority" {
+		t.Fatalf("Expected a valid config with defaults, got %v", fieldErr)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		path    string
+		line    int
+	}{
+		{"syntax", "endpoints:\n  - name: primary\n    url: a: b\n", "", 3},
+		{"type", "endpoints:\n  - name: primary\n    url: https://api.example.com\n    priority: high\n", "", 4},
+		{"no endpoints", "server:\n  port: 8080\n", "endpoints", 0},
+		{"endpoint field", "endpoints:\n  - name: primary\n    url: https://api.example.com\n  - name: backup\n    url: https://b.example.com\n    priority: -1\n", "endpoints[1].priority", 6},
+		{"missing endpoint field", "endpoints:\n  - name: primary\n", "endpoints[0].url", 2},
+		{"nested endpoint field", "endpoints:\n  - name: primary\n    url: https://api.example.com\n    rate_limit:\n      requests_per_minute: 60\n      burst: -1\n", "endpoints[0].rate_limit.burst", 6},
+		{"section field", "server:\n  max_concurrent_requests: -1\nendpoints:\n  - name: primary\n    url: https://api.example.com\n", "server.max_concurrent_requests", 2},
+		{"strategy", "strategy:\n  type: slowest\nendpoints:\n  - name: primary\n    url: https://api.example.com\n", "strategy.type", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fieldErr := ValidateContent([]byte(tt.content))
+			if fieldErr == nil {
+				t.Fatal("Expected a validation error")
+			}
+			if fieldErr.Path != tt.path || fieldErr.Line != tt.line {
+				t.Errorf("Expected %s at line %d, got %s at line %d: %s", tt.path, tt.line, fieldErr.Path, fieldErr.Line, fieldErr.Message)
+			}
+		})
+	}
+}
+
+func TestDescribeChanges(t *testing.T) {
+	oldConfig := &Config{Strategy: StrategyConfig{Type: "priority"}, Endpoints: []EndpointConfig{{Name: "a"}, {Name: "b"}}}
+	newConfig := &Config{Strategy: StrategyConfig{Type: "fastest"}, Endpoints: []EndpointConfig{{Name: "a", Priority: 2}, {Name: "b"}, {Name: "c"}}}
+	got := strings.Join(DescribeChanges(oldConfig, newConfig), "; ")
+	want := "endpoints 2→3; endpoints added: c; endpoints modified: a; strategy priority→fastest"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if changes := DescribeChanges(oldConfig, oldConfig); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestBudgetValidation(t *testing.T) {
+	endpoints := []EndpointConfig{
+		{Name: "other", URL: "https://other.example.com"},
+		{Name: "api", URL: "https://api.example.com", Group: "main"},
+	}
+	config := &Config{Endpoints: endpoints, Budgets: []BudgetConfig{
+		{Endpoint: "api", Limit: 5_000_000},
+		{Group: "Default", Window: time.Hour, Limit: 1000, Action: BudgetActionBlock},
+	}}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid budgets, got %v", err)
+	}
+	if budget := config.Budgets[0]; budget.Window != 24*time.Hour || budget.Action != BudgetActionWarn {
+		t.Errorf("Expected a 24h window and warn by default, got %+v", budget)
+	}
+
+	invalidBudgets := map[string][]BudgetConfig{
+		"no subject":       {{Limit: 10}},
+		"both subjects":    {{Endpoint: "api", Group: "main", Limit: 10}},
+		"unknown endpoint": {{Endpoint: "missing", Limit: 10}},
+		"empty group":      {{Group: "missing", Limit: 10}},
+		"missing limit":    {{Endpoint: "api"}},
+		"negative window":  {{Endpoint: "api", Limit: 10, Window: -time.Hour}},
+		"unknown action":   {{Endpoint: "api", Limit: 10, Action: "throttle"}},
+		"duplicate":        {{Group: "main", Limit: 10}, {Group: "main", Limit: 20}},
+	}
+	for name, budgets := range invalidBudgets {
+		invalid := &Config{Endpoints: endpoints, Budgets: budgets}
+		invalid.setDefaults()
+		if err := invalid.validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestLoadSheddingDefaults(t *testing.T) {
+	config := &Config{
+		Endpoints:    []EndpointConfig{{Name: "api", URL: "https://api.example.com"}},
+		LoadShedding: LoadSheddingConfig{Enabled: true},
+	}
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		t.Fatalf("Expected valid load shedding, got %v", err)
+	}
+	if config.LoadShedding.RecoveryProbeInterval != 10*time.Second {
+		t.Errorf("Expected a 10s recovery probe interval by default, got %v", config.LoadShedding.RecoveryProbeInterval)
+	}
+
+	config.LoadShedding.RecoveryProbeInterval = -time.Second
+	if err := config.validate(); err == nil {
+		t.Error("Expected a negative recovery probe interval to be rejected")
+	}
+}
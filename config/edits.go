@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EndpointEdit holds the non-priority fields changed for one endpoint. Zero values mean
+// unchanged; GroupPriority is only used together with Group.
+type EndpointEdit struct {
+	Timeout       time.Duration
+	Group         string
+	GroupPriority int
+	URL           string
+}
+
+// SaveEndpointEditsWithComments writes priorities and group priorities like
+// SavePriorityConfigWithComments and the given field edits, keyed by endpoint index, while preserving comments. Because group
+// and timeout are inherited from earlier endpoints, endpoints that relied on an inherited
+// value are pinned to their current value so an edit only affects the edited endpoint.
+// Endpoints from endpoints_source are not in the file and cannot be edited.
+func SaveEndpointEditsWithComments(config *Config, path string, edits map[int]EndpointEdit) error {
+	local := config.localEndpointCount()
+	for i := range edits {
+		if i >= local && i < len(config.Endpoints) {
+			return fmt.Errorf("endpoint %q comes from endpoints_source; edit it in the endpoints document", config.Endpoints[i].Name)
+		}
+	}
+
+	rootNode, err := readConfigNode(config, path)
+	if err != nil {
+		return err
+	}
+	endpoints := endpointsNode(rootNode)
+	if endpoints == nil || len(endpoints.Content) != local {
+		return fmt.Errorf("endpoints in %s do not match the running configuration, reload it first", path)
+	}
+	for i, endpointNode := range endpoints.Content {
+		nameNode := mappingValue(endpointNode, "name")
+		if nameNode == nil || nameNode.Value != config.Endpoints[i].Name {
+			return fmt.Errorf("endpoints in %s do not match the running configuration, reload it first", path)
+		}
+	}
+
+	updatePriorityNodes(endpoints, config)
+
+	for i := 0; i < local; i++ {
+		edit, ok := edits[i]
+		if !ok {
+			continue
+		}
+		endpointNode := endpoints.Content[i]
+		if edit.URL != "" {
+			setMappingValue(endpointNode, "url", edit.URL)
+		}
+		if edit.Timeout > 0 {
+			if i == 0 {
+				// Later endpoints without a timeout inherit the first endpoint's
+				for j := 1; j < local; j++ {
+					if mappingValue(endpoints.Content[j], "timeout") == nil {
+						setMappingValue(endpoints.Content[j], "timeout", config.Endpoints[j].Timeout.String())
+					}
+				}
+			}
+			setMappingValue(endpointNode, "timeout", edit.Timeout.String())
+		}
+		if edit.Group != "" {
+			// The next endpoint without a group inherits this one's
+			if next := i + 1; next < local && mappingValue(endpoints.Content[next], "group") == nil {
+				setMappingValue(endpoints.Content[next], "group", config.Endpoints[next].Group)
+				setMappingValue(endpoints.Content[next], "group-priority", fmt.Sprintf("%d", config.Endpoints[next].GroupPriority))
+			}
+			setMappingValue(endpointNode, "group", edit.Group)
+			if edit.GroupPriority > 0 {
+				setMappingValue(endpointNode, "group-priority", fmt.Sprintf("%d", edit.GroupPriority))
+			}
+		}
+	}
+	updateGroupPriorityNodes(endpoints, config)
+
+	return writeConfigNode(rootNode, path)
+}
+
+// setMappingValue sets key in a mapping node, appending it if missing
+func setMappingValue(node *yaml.Node, key, value string) {
+	if valueNode := mappingValue(node, key); valueNode != nil {
+		valueNode.Kind = yaml.ScalarNode
+		valueNode.Tag = ""
+		valueNode.Value = value
+		return
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: value})
+}
@@ -0,0 +1,279 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EndpointsSourceConfig adds endpoints fetched from a remote document to the ones defined in
+// the config file, so many forwarders can share one endpoint list
+type EndpointsSourceConfig struct {
+	URL             string        `yaml:"url,omitempty"`              // Document listing endpoints like the endpoints section, default: none
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"` // How often the document is fetched again, default: 5m
+	Token           string        `yaml:"token,omitempty"`            // Bearer token sent with the request, default: none
+}
+
+const (
+	// minEndpointsRefreshInterval keeps forwarders from hammering the registry
+	minEndpointsRefreshInterval = 10 * time.Second
+	// endpointsFetchTimeout bounds one fetch of the endpoints document
+	endpointsFetchTimeout = 30 * time.Second
+	// maxEndpointsDocumentSize bounds the endpoints document
+	maxEndpointsDocumentSize = 4 << 20
+)
+
+// Enabled reports whether endpoints are fetched from a remote document
+func (s EndpointsSourceConfig) Enabled() bool {
+	return s.URL != ""
+}
+
+// setEndpointsSourceDefaults fills in endpoints source defaults
+func (c *Config) setEndpointsSourceDefaults() {
+	if c.EndpointsSource.RefreshInterval == 0 {
+		c.EndpointsSource.RefreshInterval = 5 * time.Minute
+	}
+}
+
+// validateEndpointsSource validates the endpoints source settings
+func (c *Config) validateEndpointsSource() error {
+	source := c.EndpointsSource
+	if !source.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(source.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoints_source: url must be an http or https URL, got %q", source.URL)
+	}
+	if source.RefreshInterval < minEndpointsRefreshInterval {
+		return fmt.Errorf("endpoints_source: refresh_interval must be at least %v", minEndpointsRefreshInterval)
+	}
+	return nil
+}
+
+// FetchEndpointsDocument downloads the endpoints document of an endpoints source and checks
+// that it parses. The document is YAML or JSON with either an endpoints list at the top level
+// or the list alone. A document without endpoints is an error, so a broken registry cannot
+// empty the forwarder.
+func FetchEndpointsDocument(ctx context.Context, source EndpointsSourceConfig) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, endpointsFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/yaml, application/json")
+	if source.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+source.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEndpointsDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > maxEndpointsDocumentSize {
+		return nil, fmt.Errorf("document exceeds %d bytes", maxEndpointsDocumentSize)
+	}
+	if _, err := parseEndpointsDocument(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseEndpointsDocument parses an endpoints document, see FetchEndpointsDocument
+func parseEndpointsDocument(data []byte) ([]EndpointConfig, error) {
+	var document struct {
+		Endpoints []EndpointConfig `yaml:"endpoints"`
+	}
+	var endpoints []EndpointConfig
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+		if err := node.Decode(&endpoints); err != nil {
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+	} else {
+		if err := node.Decode(&document); err != nil {
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+		endpoints = document.Endpoints
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("document lists no endpoints")
+	}
+	return endpoints, nil
+}
+
+// addRemoteEndpoints appends the endpoints of an endpoints_source document after the config
+// file's own. They get their defaults as a list of their own, so they do not inherit the
+// group, timeout, api-key or headers of the local endpoints. Local endpoints win on name
+// collisions.
+func (c *Config) addRemoteEndpoints(data []byte) error {
+	remote, err := parseEndpointsDocument(data)
+	if err != nil {
+		return err
+	}
+	local := make(map[string]bool, len(c.Endpoints))
+	for _, endpoint := range c.Endpoints {
+		local[endpoint.Name] = true
+	}
+
+	document := &Config{GlobalTimeout: c.GlobalTimeout, Endpoints: remote}
+	document.setDefaults()
+
+	for _, endpoint := range document.Endpoints {
+		if local[endpoint.Name] {
+			continue
+		}
+		endpoint.Remote = true
+		c.Endpoints = append(c.Endpoints, endpoint)
+	}
+	return nil
+}
+
+// RemoteEndpointCount returns how many endpoints come from endpoints_source
+func (c *Config) RemoteEndpointCount() int {
+	return len(c.Endpoints) - c.localEndpointCount()
+}
+
+// localEndpointCount returns how many endpoints come from the config file; endpoints from
+// endpoints_source follow them
+func (c *Config) localEndpointCount() int {
+	for i, endpoint := range c.Endpoints {
+		if endpoint.Remote {
+			return i
+		}
+	}
+	return len(c.Endpoints)
+}
+
+// remoteEndpoints is an endpoints_source document that applied, with the URL it came from
+type remoteEndpoints struct {
+	url      string
+	document []byte
+}
+
+// endpointsSourceLoop fetches the endpoints_source document every refresh_interval, and right
+// away when the source settings change
+func (cw *ConfigWatcher) endpointsSourceLoop() {
+	for {
+		var timer *time.Timer
+		var tick <-chan time.Time
+		if source := cw.GetConfig().EndpointsSource; source.Enabled() {
+			timer = time.NewTimer(source.RefreshInterval)
+			tick = timer.C
+		}
+
+		select {
+		case <-cw.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-cw.sourceWake:
+		case <-tick:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		cw.refreshEndpoints()
+	}
+}
+
+// wakeEndpointsSource makes the endpoints source loop fetch right away
+func (cw *ConfigWatcher) wakeEndpointsSource() {
+	select {
+	case cw.sourceWake <- struct{}{}:
+	default:
+	}
+}
+
+// refreshEndpoints fetches the endpoints_source document and, when it changed, applies it
+// through the same path as a config file reload. A failed fetch or a document that does not
+// validate keeps the endpoints fetched last.
+func (cw *ConfigWatcher) refreshEndpoints() {
+	source := cw.GetConfig().EndpointsSource
+	if !source.Enabled() {
+		cw.mutex.Lock()
+		cw.remote = nil
+		cw.mutex.Unlock()
+		return
+	}
+
+	document, err := FetchEndpointsDocument(context.Background(), source)
+	if err != nil {
+		cw.recordError(err)
+		cw.logger.Warn(fmt.Sprintf("⚠️ [端点发现] 获取远程端点列表失败，继续使用当前端点: %s - %v", source.URL, err))
+		return
+	}
+
+	cw.reloadMutex.Lock()
+	defer cw.reloadMutex.Unlock()
+
+	cw.mutex.RLock()
+	unchanged := cw.remote != nil && cw.remote.url == source.URL && bytes.Equal(cw.remote.document, document)
+	configPath := cw.configPath
+	cw.mutex.RUnlock()
+	if unchanged {
+		cw.logger.Debug(fmt.Sprintf("🌐 [端点发现] 远程端点列表未变更: %s", source.URL))
+		return
+	}
+
+	remote := &remoteEndpoints{url: source.URL, document: document}
+	newConfig, err := loadConfig(configPath, remote)
+	if err != nil {
+		cw.recordError(err)
+		cw.logger.Warn(fmt.Sprintf("⚠️ [端点发现] 远程端点列表无效，继续使用当前端点: %s - %v", source.URL, err))
+		return
+	}
+	if newConfig.EndpointsSource.URL != source.URL {
+		// The config file changed the source while fetching; the wake-up fetches the new one
+		return
+	}
+
+	cw.mutex.Lock()
+	cw.remote = remote
+	cw.mutex.Unlock()
+
+	cw.logger.Info(fmt.Sprintf("🌐 [端点发现] 远程端点列表已更新，正在应用: %d 个远程端点 (来源: %s)",
+		newConfig.RemoteEndpointCount(), source.URL))
+	if shadowed := shadowedRemoteEndpoints(newConfig, document); len(shadowed) > 0 {
+		cw.logger.Info(fmt.Sprintf("🌐 [端点发现] 本地配置中的同名端点优先，忽略远程端点: %s", strings.Join(shadowed, ", ")))
+	}
+	cw.applyConfig(newConfig)
+}
+
+// shadowedRemoteEndpoints returns the names in an endpoints document that a local endpoint overrides
+func shadowedRemoteEndpoints(config *Config, document []byte) []string {
+	remote, _ := parseEndpointsDocument(document)
+	local := make(map[string]bool)
+	for _, endpoint := range config.Endpoints[:config.localEndpointCount()] {
+		local[endpoint.Name] = true
+	}
+	var shadowed []string
+	for _, endpoint := range remote {
+		if local[endpoint.Name] {
+			shadowed = append(shadowed, endpoint.Name)
+		}
+	}
+	return shadowed
+}
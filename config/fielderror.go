@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a configuration error located in the YAML it was found in
+type FieldError struct {
+	Path    string `json:"path"`    // Location in the config, e.g. endpoints[1].priority, empty when unknown
+	Line    int    `json:"line"`    // Line number in the YAML of the field or its nearest parent, 0 when unknown
+	Message string `json:"message"` // The error as LoadConfig reports it
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidateContent parses YAML, applies defaults and validates it like LoadConfig does with
+// a file, so content can be checked before it is written. A failure is returned with the
+// field it concerns.
+func ValidateContent(data []byte) (*Config, *FieldError) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, &FieldError{Line: yamlErrorLine(err.Error()), Message: fmt.Sprintf("failed to parse config file: %v", err)}
+	}
+
+	var config Config
+	if err := unmarshalConfig(data, &config); err != nil {
+		return nil, &FieldError{Line: yamlErrorLine(err.Error()), Message: fmt.Sprintf("failed to parse config file: %v", err)}
+	}
+	config.setDefaults()
+
+	if err := config.validate(); err != nil {
+		path := "endpoints"
+		if !errors.Is(err, ErrNoEndpoints) {
+			path = config.errorPath(err.Error())
+		}
+		return nil, &FieldError{Path: path, Line: pathLine(&doc, path), Message: fmt.Sprintf("invalid configuration: %v", err)}
+	}
+	return &config, nil
+}
+
+// yamlLinePattern finds the line yaml.v3 names in its syntax and type errors
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+func yamlErrorLine(message string) int {
+	if match := yamlLinePattern.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+	return 0
+}
+
+// errorSubjects map the subject validate() names at the start of an error to its place in
+// the config. Named subjects are looked up by name, numbered ones by index.
+var errorSubjects = []struct {
+	pattern *regexp.Regexp
+	path    func(c *Config, subject string) string
+}{
+	{regexp.MustCompile(`^auth\.tokens\[(\d+)\](?: \([^)]*\))?: `), func(c *Config, subject string) string {
+		return "auth.tokens[" + subject + "]"
+	}},
+	{regexp.MustCompile(`^endpoint (\S+): `), func(c *Config, subject string) string {
+		return indexedPath("endpoints", subject, len(c.Endpoints), func(i int) string { return c.Endpoints[i].Name })
+	}},
+	{regexp.MustCompile(`^rule (\S+): `), func(c *Config, subject string) string {
+		return indexedPath("rules", subject, len(c.Rules), func(i int) string { return c.Rules[i].Name })
+	}},
+	{regexp.MustCompile(`^notification sink (\S+): `), func(c *Config, subject string) string {
+		return indexedPath("notifications.sinks", subject, len(c.Notifications.Sinks), func(i int) string { return c.Notifications.Sinks[i].Name })
+	}},
+	{regexp.MustCompile(`^notification trigger (\S+): `), func(c *Config, subject string) string {
+		return indexedPath("notifications.triggers", subject, len(c.Notifications.Triggers), func(i int) string { return c.Notifications.Triggers[i].Event })
+	}},
+	{regexp.MustCompile(`^server listener (\d+): `), func(c *Config, subject string) string {
+		return "server.listeners[" + subject + "]"
+	}},
+	{regexp.MustCompile(`^budget (\d+): `), func(c *Config, subject string) string {
+		return "budgets[" + subject + "]"
+	}},
+	{regexp.MustCompile(`^group (\S+): `), func(c *Config, subject string) string {
+		return "groups." + subject
+	}},
+	{regexp.MustCompile(`^([a-z_]+):? `), func(c *Config, subject string) string {
+		return subject
+	}},
+}
+
+// errorWords end the field names that follow the subject of an error
+var errorWords = map[string]bool{
+	"must": true, "is": true, "are": true, "cannot": true, "requires": true, "require": true,
+	"and": true, "or": true, "entries": true, "need": true, "needs": true, "renames": true,
+	"has": true, "do": true, "invalid": true, "duplicate": true, "unknown": true, "at": true,
+	"only": true, "should": true, "not": true,
+}
+
+// fieldNamePattern matches a config key as validate() writes it in an error
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// errorPath derives the config path of a validation error from its message, which names
+// the section or entry first and then the keys, e.g. "endpoint api: rate_limit burst must
+// be non-negative" is endpoints[0].rate_limit.burst
+func (c *Config) errorPath(message string) string {
+	for _, subject := range errorSubjects {
+		match := subject.pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		path := subject.path(c, match[1])
+		for _, word := range strings.Fields(message[len(match[0]):]) {
+			last := strings.HasSuffix(word, ":")
+			word = strings.TrimSuffix(word, ":")
+			if errorWords[word] || !fieldNamePattern.MatchString(word) {
+				break
+			}
+			path += "." + strings.ToLower(word)
+			if last {
+				break
+			}
+		}
+		return path
+	}
+	return ""
+}
+
+// indexedPath returns the path of a list entry given by its index or its name
+func indexedPath(list, subject string, count int, name func(int) string) string {
+	for i := 0; i < count; i++ {
+		if name(i) == subject {
+			return fmt.Sprintf("%s[%d]", list, i)
+		}
+	}
+	if i, err := strconv.Atoi(subject); err == nil && i >= 0 && i < count {
+		return fmt.Sprintf("%s[%d]", list, i)
+	}
+	return list
+}
+
+// pathSegmentPattern splits a path such as endpoints[1].auth.type into keys and indexes
+var pathSegmentPattern = regexp.MustCompile(`([^.\[\]]+)|\[(\d+)\]`)
+
+// pathLine returns the line of the deepest node of path present in the document
+func pathLine(doc *yaml.Node, path string) int {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := 0
+	for _, segment := range pathSegmentPattern.FindAllStringSubmatch(path, -1) {
+		var next *yaml.Node
+		switch {
+		case segment[2] != "" && node.Kind == yaml.SequenceNode:
+			if i, _ := strconv.Atoi(segment[2]); i < len(node.Content) {
+				next = node.Content[i]
+			}
+		case segment[1] != "" && node.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment[1] {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		if segment[2] != "" {
+			line = next.Line
+		}
+		node = next
+	}
+	return line
+}
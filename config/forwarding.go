@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header a request ID is read from and forwarded upstream in
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// DefaultCoalesceWindow is how long after an identical request started a duplicate may still join it
+const DefaultCoalesceWindow = 10 * time.Second
+
+// ForwardingConfig configures what the forwarder adds to upstream requests
+type ForwardingConfig struct {
+	RequestIDHeader string            `yaml:"request_id_header"` // Header carrying the request ID upstream, also read from clients, default: X-Request-Id
+	Headers         HeaderRulesConfig `yaml:"headers"`           // Headers removed from or added to forwarded requests and responses
+
+	// Identical requests arriving while one is still waiting for its first response byte share
+	// its upstream request and receive the same response
+	CoalesceIdenticalRequests bool          `yaml:"coalesce_identical_requests"` // Default: false
+	CoalesceWindow            time.Duration `yaml:"coalesce_window"`             // How long after the first request a duplicate may join, default: 10s
+}
+
+// HeaderRulesConfig lists headers that are never forwarded and headers added to every
+// upstream request. Names match case-insensitively; a trailing * matches any suffix
+// ("X-Internal-*"). Hop-by-hop headers are always removed.
+type HeaderRulesConfig struct {
+	StripRequestHeaders  []string          `yaml:"strip_request_headers"`  // Client headers not forwarded upstream
+	StripResponseHeaders []string          `yaml:"strip_response_headers"` // Upstream response headers not returned to clients
+	AddRequestHeaders    map[string]string `yaml:"add_request_headers"`    // Headers set on every upstream request, after endpoint headers
+}
+
+// StripsRequestHeader reports whether a client header must not be forwarded upstream
+func (h HeaderRulesConfig) StripsRequestHeader(name string) bool {
+	return headerMatches(h.StripRequestHeaders, name)
+}
+
+// StripsResponseHeader reports whether an upstream response header must not reach the client
+func (h HeaderRulesConfig) StripsResponseHeader(name string) bool {
+	return headerMatches(h.StripResponseHeaders, name)
+}
+
+// headerMatches reports whether name matches one of the patterns, ignoring case
+func headerMatches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// validHeaderName reports whether name can be sent as an HTTP header name
+func validHeaderName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n:*")
+}
+
+// setForwardingDefaults fills in defaults for forwarding settings
+func (c *Config) setForwardingDefaults() {
+	if c.Forwarding.RequestIDHeader == "" {
+		c.Forwarding.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if c.Forwarding.CoalesceWindow == 0 {
+		c.Forwarding.CoalesceWindow = DefaultCoalesceWindow
+	}
+}
+
+// validateForwarding validates forwarding settings
+func (c *Config) validateForwarding() error {
+	if strings.ContainsAny(c.Forwarding.RequestIDHeader, " \t\r\n:") {
+		return fmt.Errorf("forwarding: request_id_header %q is not a valid header name", c.Forwarding.RequestIDHeader)
+	}
+
+	if c.Forwarding.CoalesceWindow < 0 {
+		return fmt.Errorf("forwarding: coalesce_window must not be negative")
+	}
+
+	headers := c.Forwarding.Headers
+	for _, list := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"strip_request_headers", headers.StripRequestHeaders},
+		{"strip_response_headers", headers.StripResponseHeaders},
+	} {
+		for _, pattern := range list.patterns {
+			if name := strings.TrimSuffix(pattern, "*"); !validHeaderName(name) {
+				return fmt.Errorf("forwarding: headers %s: %q is not a header name or a prefix ending in *", list.key, pattern)
+			}
+		}
+	}
+	for name, value := range headers.AddRequestHeaders {
+		if !validHeaderName(name) {
+			return fmt.Errorf("forwarding: headers add_request_headers: %q is not a valid header name", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("forwarding: headers add_request_headers: value of %s must not contain line breaks", name)
+		}
+	}
+	return nil
+}
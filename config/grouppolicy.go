@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// GroupRetryConfig overrides the retry section for requests served by the endpoints of one
+// group. Unset (zero) fields keep the global value.
+type GroupRetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts,omitempty"` // Attempts per endpoint of the group, default: retry.max_attempts
+	BaseDelay   time.Duration `yaml:"base_delay,omitempty"`   // Default: retry.base_delay
+	MaxDelay    time.Duration `yaml:"max_delay,omitempty"`    // Default: retry.max_delay
+	Multiplier  float64       `yaml:"multiplier,omitempty"`   // At least 1, default: retry.multiplier
+}
+
+// validate checks a group's retry override; where names the setting in errors
+func (r *GroupRetryConfig) validate(where string) error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxAttempts < 0 || r.BaseDelay < 0 || r.MaxDelay < 0 {
+		return fmt.Errorf("%s: max_attempts, base_delay and max_delay must be non-negative", where)
+	}
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		return fmt.Errorf("%s: multiplier must be at least 1, got %v", where, r.Multiplier)
+	}
+	return nil
+}
+
+// apply returns retry with the fields set in r replacing its own
+func (r *GroupRetryConfig) apply(retry RetryConfig) RetryConfig {
+	if r == nil {
+		return retry
+	}
+	if r.MaxAttempts > 0 {
+		retry.MaxAttempts = r.MaxAttempts
+	}
+	if r.BaseDelay > 0 {
+		retry.BaseDelay = r.BaseDelay
+	}
+	if r.MaxDelay > 0 {
+		retry.MaxDelay = r.MaxDelay
+	}
+	if r.Multiplier > 0 {
+		retry.Multiplier = r.Multiplier
+	}
+	return retry
+}
+
+// validateGroupPolicies validates the retry and cooldown overrides of groups, set in the groups
+// section or on the first endpoint of a group
+func (c *Config) validateGroupPolicies() error {
+	for name, settings := range c.Groups {
+		if err := settings.Retry.validate(fmt.Sprintf("group %s: retry", name)); err != nil {
+			return err
+		}
+		if settings.Cooldown < 0 {
+			return fmt.Errorf("group %s: cooldown must be non-negative", name)
+		}
+		if settings.MaxRetries != nil && *settings.MaxRetries < 0 {
+			return fmt.Errorf("group %s: max_retries must be non-negative", name)
+		}
+	}
+	for _, endpoint := range c.Endpoints {
+		if err := endpoint.GroupRetry.validate(fmt.Sprintf("endpoint %s: group-retry", endpoint.Name)); err != nil {
+			return err
+		}
+		if endpoint.GroupCooldown < 0 {
+			return fmt.Errorf("endpoint %s: group-cooldown must be non-negative", endpoint.Name)
+		}
+		if endpoint.GroupMaxRetries != nil && *endpoint.GroupMaxRetries < 0 {
+			return fmt.Errorf("endpoint %s: group-max-retries must be non-negative", endpoint.Name)
+		}
+	}
+	return nil
+}
+
+// groupEndpoint returns the first endpoint of a group, which carries the group's group-*
+// settings, or nil when the group has no endpoints
+func (c *Config) groupEndpoint(groupName string) *EndpointConfig {
+	for i := range c.Endpoints {
+		name := c.Endpoints[i].Group
+		if name == "" {
+			name = "Default"
+		}
+		if name == groupName {
+			return &c.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// GetGroupRetry returns the retry settings for requests served by a group's endpoints: the
+// retry section with the group's overrides applied. Fields set in the groups section take
+// precedence over group-retry on the group's first endpoint.
+func (c *Config) GetGroupRetry(groupName string) RetryConfig {
+	retry := c.Retry
+	if endpoint := c.groupEndpoint(groupName); endpoint != nil {
+		retry = endpoint.GroupRetry.apply(retry)
+	}
+	return c.Groups[groupName].Retry.apply(retry)
+}
+
+// GetGroupCooldown returns how long a group stays in cooldown after exhausting its retries:
+// cooldown in the groups section, else group-cooldown on its first endpoint, else group.cooldown
+func (c *Config) GetGroupCooldown(groupName string) time.Duration {
+	if cooldown := c.Groups[groupName].Cooldown; cooldown > 0 {
+		return cooldown
+	}
+	if endpoint := c.groupEndpoint(groupName); endpoint != nil && endpoint.GroupCooldown > 0 {
+		return endpoint.GroupCooldown
+	}
+	return c.Group.Cooldown
+}
+
+// GetGroupMaxRetries returns how many times a group may fail before it enters cooldown:
+// max_retries in the groups section, else group-max-retries on its first endpoint, else
+// group.max_retries. Unlike group.max_retries, a group's own 0 means the first failure starts
+// the cooldown.
+func (c *Config) GetGroupMaxRetries(groupName string) int {
+	if maxRetries := c.Groups[groupName].MaxRetries; maxRetries != nil {
+		return *maxRetries
+	}
+	if endpoint := c.groupEndpoint(groupName); endpoint != nil && endpoint.GroupMaxRetries != nil {
+		return *endpoint.GroupMaxRetries
+	}
+	return c.Group.MaxRetries
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Prefixes of the listen setting (server.listen, webui.listen)
+const (
+	ListenUnixPrefix    = "unix://" // unix:///run/forwarder.sock serves on a Unix domain socket
+	ListenSystemdPrefix = "systemd" // systemd or systemd:<name> serves on sockets passed by systemd socket activation
+)
+
+// defaultSocketMode is the permission of listen sockets without socket_mode: owner and group,
+// so a reverse proxy in the socket's group can connect
+const defaultSocketMode os.FileMode = 0660
+
+// ListenAddress is a parsed listen setting
+type ListenAddress struct {
+	SocketPath  string // Unix socket path, empty for systemd
+	Systemd     bool   // Serve on the sockets passed by systemd (LISTEN_FDS)
+	SystemdName string // Only use systemd sockets with this FileDescriptorName, empty = all
+}
+
+// ParseListen parses a listen setting: unix:///path/to.sock, systemd or systemd:<name>
+func ParseListen(value string) (ListenAddress, error) {
+	if path, ok := strings.CutPrefix(value, ListenUnixPrefix); ok {
+		if !strings.HasPrefix(path, "/") {
+			return ListenAddress{}, fmt.Errorf("unix socket path must be absolute (unix:///path/to.sock): %s", value)
+		}
+		if len(path) > maxSocketPathLength {
+			return ListenAddress{}, fmt.Errorf("unix socket path is longer than %d bytes: %s", maxSocketPathLength, path)
+		}
+		return ListenAddress{SocketPath: path}, nil
+	}
+	if value == ListenSystemdPrefix {
+		return ListenAddress{Systemd: true}, nil
+	}
+	if name, ok := strings.CutPrefix(value, ListenSystemdPrefix+":"); ok && name != "" {
+		return ListenAddress{Systemd: true, SystemdName: name}, nil
+	}
+	return ListenAddress{}, fmt.Errorf("must be unix:///path/to.sock, systemd or systemd:<name>, got %q", value)
+}
+
+// String returns the listen setting in its configured form
+func (a ListenAddress) String() string {
+	switch {
+	case a.SocketPath != "":
+		return ListenUnixPrefix + a.SocketPath
+	case a.SystemdName != "":
+		return ListenSystemdPrefix + ":" + a.SystemdName
+	default:
+		return ListenSystemdPrefix
+	}
+}
+
+// parseSocketMode parses an octal socket_mode such as "0660", defaultSocketMode when empty
+func parseSocketMode(value string) (os.FileMode, error) {
+	if value == "" {
+		return defaultSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("socket_mode must be octal permissions such as \"0660\", got %q", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// SocketFileMode returns the permissions of the listener's Unix socket file
+func (l ListenerConfig) SocketFileMode() os.FileMode {
+	mode, err := parseSocketMode(l.SocketMode)
+	if err != nil {
+		return defaultSocketMode
+	}
+	return mode
+}
+
+// ListenAddress returns the parsed listen setting, or false for a host/port listener
+func (l ListenerConfig) ListenAddress() (ListenAddress, bool) {
+	if l.Listen == "" {
+		return ListenAddress{}, false
+	}
+	addr, err := ParseListen(l.Listen)
+	return addr, err == nil
+}
+
+// SocketFileMode returns the permissions of the WebUI's Unix socket file
+func (w WebUIConfig) SocketFileMode() os.FileMode {
+	mode, err := parseSocketMode(w.SocketMode)
+	if err != nil {
+		return defaultSocketMode
+	}
+	return mode
+}
+
+// validateListen validates the listen and socket_mode settings of the server and the WebUI
+func (c *Config) validateListen() error {
+	paths := make(map[string]string)
+	if c.Admin.SocketPath != "" {
+		paths[c.Admin.SocketPath] = "admin"
+	}
+	check := func(what, listen, socketMode string) error {
+		if _, err := parseSocketMode(socketMode); err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		if listen == "" {
+			return nil
+		}
+		addr, err := ParseListen(listen)
+		if err != nil {
+			return fmt.Errorf("%s: listen %w", what, err)
+		}
+		if addr.SocketPath != "" {
+			if other, exists := paths[addr.SocketPath]; exists {
+				return fmt.Errorf("%s: listen socket %s is also used by %s", what, addr.SocketPath, other)
+			}
+			paths[addr.SocketPath] = what
+		}
+		return nil
+	}
+
+	if c.Server.Listen != "" && len(c.Server.Listeners) > 0 {
+		return fmt.Errorf("server: listen cannot be combined with listeners, set listen on a listener instead")
+	}
+	if err := check("server", c.Server.Listen, c.Server.SocketMode); err != nil {
+		return err
+	}
+	for i, listener := range c.Server.Listeners {
+		if err := check(fmt.Sprintf("server listener %d", i), listener.Listen, listener.SocketMode); err != nil {
+			return err
+		}
+	}
+	if err := check("webui", c.WebUI.Listen, c.WebUI.SocketMode); err != nil {
+		return err
+	}
+	return nil
+}
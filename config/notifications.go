@@ -0,0 +1,228 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// NotificationsConfig configures alerts sent to external sinks when endpoint health,
+// group cooldown, success rate or config reloads change
+type NotificationsConfig struct {
+	Enabled         bool                  `yaml:"enabled"`          // Enable notifications, default: false
+	QueueSize       int                   `yaml:"queue_size"`       // Pending events buffered before new ones are dropped, default: 100
+	Retry           NotifyRetryConfig     `yaml:"retry"`            // Delivery retries per sink
+	Sinks           []NotifySinkConfig    `yaml:"sinks"`            // Where notifications are sent
+	Triggers        []NotifyTriggerConfig `yaml:"triggers"`         // Which events are sent
+	WebhookURL      string                `yaml:"webhook_url"`      // Shorthand: POST every event here, without sinks or triggers; enables notifications
+	WebhookTemplate string                `yaml:"webhook_template"` // Body template for webhook_url, default: the event as JSON
+}
+
+// WebhookTimeout is the per-attempt timeout of notifications.webhook_url
+const WebhookTimeout = 5 * time.Second
+
+// WebhookSink returns the sink notifications.webhook_url stands for
+func (n NotificationsConfig) WebhookSink() NotifySinkConfig {
+	return NotifySinkConfig{
+		Name:     "webhook_url",
+		Type:     NotifySinkWebhook,
+		URL:      n.WebhookURL,
+		Template: n.WebhookTemplate,
+		Timeout:  WebhookTimeout,
+	}
+}
+
+// Active reports whether events are delivered, either through triggers or webhook_url
+func (n NotificationsConfig) Active() bool {
+	return n.Enabled || n.WebhookURL != ""
+}
+
+type NotifyRetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // Delivery attempts per sink, default: 3
+	Backoff     time.Duration `yaml:"backoff"`      // Delay before the first retry, doubled on each retry, default: 1s
+}
+
+type NotifySinkConfig struct {
+	Name     string            `yaml:"name"`     // Sink name, referenced by triggers and shown in logs
+	Type     string            `yaml:"type"`     // "webhook" or "smtp"
+	URL      string            `yaml:"url"`      // Webhook URL
+	Template string            `yaml:"template"` // Webhook body template (text/template), default: the event as JSON
+	Headers  map[string]string `yaml:"headers"`  // Extra webhook request headers
+	Timeout  time.Duration     `yaml:"timeout"`  // Per-attempt timeout, default: 10s
+	SMTP     NotifySMTPConfig  `yaml:"smtp"`     // SMTP settings for type smtp
+}
+
+type NotifySMTPConfig struct {
+	Host     string   `yaml:"host"`     // SMTP server host
+	Port     int      `yaml:"port"`     // SMTP server port, default: 587
+	Username string   `yaml:"username"` // PLAIN auth username, empty = no auth
+	Password string   `yaml:"password"` // PLAIN auth password
+	From     string   `yaml:"from"`     // Sender address
+	To       []string `yaml:"to"`       // Recipient addresses
+}
+
+type NotifyTriggerConfig struct {
+	Event       string        `yaml:"event"`        // Event type, see the NotifyEvent* constants
+	Cooldown    time.Duration `yaml:"cooldown"`     // Minimum time between notifications for the same event and subject, default: 5m
+	Sinks       []string      `yaml:"sinks"`        // Sink names, default: all sinks
+	Threshold   float64       `yaml:"threshold"`    // success_rate_low: alert below this success percentage, default: 90
+	Window      time.Duration `yaml:"window"`       // success_rate_low: window the rate is computed over, default: 5m
+	MinRequests int           `yaml:"min_requests"` // success_rate_low: requests in the window needed before alerting, default: 10
+}
+
+// Notification sink types
+const (
+	NotifySinkWebhook = "webhook"
+	NotifySinkSMTP    = "smtp"
+)
+
+// Notification event types
+const (
+	NotifyEventEndpointUnhealthy  = "endpoint_unhealthy"
+	NotifyEventEndpointHealthy    = "endpoint_healthy"
+	NotifyEventGroupCooldownEnter = "group_cooldown_entered"
+	NotifyEventGroupCooldownExit  = "group_cooldown_exited"
+	NotifyEventSuccessRateLow     = "success_rate_low"
+	NotifyEventConfigReloadFailed = "config_reload_failed"
+	NotifyEventAllEndpointsDown   = "all_endpoints_down"
+	NotifyEventConfigSwitched     = "config_switched"
+	NotifyEventBudgetWarning      = "budget_warning"
+	NotifyEventBudgetExceeded     = "budget_exceeded"
+	NotifyEventTest               = "test"
+)
+
+// notifyTriggerEvents are the event types a trigger can subscribe to
+var notifyTriggerEvents = map[string]bool{
+	NotifyEventEndpointUnhealthy:  true,
+	NotifyEventEndpointHealthy:    true,
+	NotifyEventGroupCooldownEnter: true,
+	NotifyEventGroupCooldownExit:  true,
+	NotifyEventSuccessRateLow:     true,
+	NotifyEventConfigReloadFailed: true,
+	NotifyEventAllEndpointsDown:   true,
+	NotifyEventConfigSwitched:     true,
+	NotifyEventBudgetWarning:      true,
+	NotifyEventBudgetExceeded:     true,
+}
+
+// NotifyTemplateFuncs are available in webhook templates. json encodes a value as a JSON
+// literal, so {"text": {{json .Message}}} stays valid whatever the message contains.
+var NotifyTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// setNotificationDefaults fills in defaults for notification settings
+func (c *Config) setNotificationDefaults() {
+	n := &c.Notifications
+	if n.QueueSize == 0 {
+		n.QueueSize = 100
+	}
+	if n.Retry.MaxAttempts == 0 {
+		n.Retry.MaxAttempts = 3
+	}
+	if n.Retry.Backoff == 0 {
+		n.Retry.Backoff = time.Second
+	}
+	for i := range n.Sinks {
+		sink := &n.Sinks[i]
+		if sink.Timeout == 0 {
+			sink.Timeout = 10 * time.Second
+		}
+		if sink.Type == NotifySinkSMTP && sink.SMTP.Port == 0 {
+			sink.SMTP.Port = 587
+		}
+	}
+	for i := range n.Triggers {
+		trigger := &n.Triggers[i]
+		if trigger.Cooldown == 0 {
+			trigger.Cooldown = 5 * time.Minute
+		}
+		if trigger.Event == NotifyEventSuccessRateLow {
+			if trigger.Threshold == 0 {
+				trigger.Threshold = 90
+			}
+			if trigger.Window == 0 {
+				trigger.Window = 5 * time.Minute
+			}
+			if trigger.MinRequests == 0 {
+				trigger.MinRequests = 10
+			}
+		}
+	}
+}
+
+// validateNotifications validates notification sinks and triggers
+func (c *Config) validateNotifications() error {
+	n := c.Notifications
+	if n.QueueSize < 0 {
+		return fmt.Errorf("notifications: queue_size cannot be negative")
+	}
+	if n.Retry.MaxAttempts < 0 || n.Retry.Backoff < 0 {
+		return fmt.Errorf("notifications: retry max_attempts and backoff cannot be negative")
+	}
+
+	sinkConfigs := n.Sinks
+	if n.WebhookURL != "" {
+		sinkConfigs = append(append([]NotifySinkConfig(nil), n.Sinks...), n.WebhookSink())
+	}
+	sinks := make(map[string]bool)
+	for i, sink := range sinkConfigs {
+		if sink.Name == "" {
+			return fmt.Errorf("notification sink %d: name is required", i)
+		}
+		if sinks[sink.Name] {
+			return fmt.Errorf("notification sink %s: duplicate name", sink.Name)
+		}
+		sinks[sink.Name] = true
+
+		switch sink.Type {
+		case NotifySinkWebhook:
+			u, err := url.Parse(sink.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("notification sink %s: url must be an http(s) URL", sink.Name)
+			}
+			if sink.Template != "" {
+				if _, err := template.New(sink.Name).Funcs(NotifyTemplateFuncs).Parse(sink.Template); err != nil {
+					return fmt.Errorf("notification sink %s: invalid template: %v", sink.Name, err)
+				}
+			}
+		case NotifySinkSMTP:
+			if sink.SMTP.Host == "" || sink.SMTP.From == "" || len(sink.SMTP.To) == 0 {
+				return fmt.Errorf("notification sink %s: smtp requires host, from and to", sink.Name)
+			}
+		default:
+			return fmt.Errorf("notification sink %s: type must be %q or %q", sink.Name, NotifySinkWebhook, NotifySinkSMTP)
+		}
+	}
+	// webhook_url receives every event on its own; triggers cannot route to it
+	if n.WebhookURL != "" {
+		delete(sinks, n.WebhookSink().Name)
+	}
+
+	for i, trigger := range n.Triggers {
+		if !notifyTriggerEvents[trigger.Event] {
+			return fmt.Errorf("notification trigger %d: unknown event %q", i, trigger.Event)
+		}
+		if trigger.Cooldown < 0 {
+			return fmt.Errorf("notification trigger %s: cooldown cannot be negative", trigger.Event)
+		}
+		for _, name := range trigger.Sinks {
+			if !sinks[name] {
+				return fmt.Errorf("notification trigger %s: unknown sink %q", trigger.Event, name)
+			}
+		}
+		if trigger.Event == NotifyEventSuccessRateLow && (trigger.Threshold <= 0 || trigger.Threshold > 100) {
+			return fmt.Errorf("notification trigger %s: threshold must be between 0 and 100", trigger.Event)
+		}
+	}
+
+	if n.Enabled && len(n.Sinks) == 0 {
+		return fmt.Errorf("notifications: at least one sink is required when enabled")
+	}
+	return nil
+}
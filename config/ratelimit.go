@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// EndpointRateLimitConfig limits how fast requests are sent to an endpoint with a token
+// bucket, e.g. to stay under a provider's per-key requests-per-minute limit
+type EndpointRateLimitConfig struct {
+	RequestsPerMinute int           `yaml:"requests_per_minute"`   // Sustained rate the bucket refills at, required
+	Burst             int           `yaml:"burst,omitempty"`       // Bucket size: requests that may be sent back to back, default: requests_per_minute
+	OnExceeded        string        `yaml:"on_exceeded,omitempty"` // "failover" (default): try the next endpoint right away; "queue": wait for a token
+	MaxWait           time.Duration `yaml:"max_wait,omitempty"`    // Longest a queued request waits before failing over, default: 30s
+}
+
+// Rate limit behaviors when an endpoint's bucket is empty
+const (
+	RateLimitFailover = "failover"
+	RateLimitQueue    = "queue"
+)
+
+// defaultRateLimitMaxWait is how long a request queues for an endpoint's rate limit by default
+const defaultRateLimitMaxWait = 30 * time.Second
+
+// setRateLimitDefaults fills in defaults for endpoint rate limits
+func (c *Config) setRateLimitDefaults() {
+	for i := range c.Endpoints {
+		limit := c.Endpoints[i].RateLimit
+		if limit == nil {
+			continue
+		}
+		if limit.Burst == 0 {
+			limit.Burst = limit.RequestsPerMinute
+		}
+		if limit.OnExceeded == "" {
+			limit.OnExceeded = RateLimitFailover
+		}
+		if limit.MaxWait == 0 {
+			limit.MaxWait = defaultRateLimitMaxWait
+		}
+	}
+}
+
+// validateRateLimits validates endpoint rate limit blocks
+func (c *Config) validateRateLimits() error {
+	for _, endpoint := range c.Endpoints {
+		limit := endpoint.RateLimit
+		if limit == nil {
+			continue
+		}
+		if limit.RequestsPerMinute <= 0 {
+			return fmt.Errorf("endpoint %s: rate_limit requests_per_minute must be positive", endpoint.Name)
+		}
+		if limit.Burst < 0 {
+			return fmt.Errorf("endpoint %s: rate_limit burst must be non-negative", endpoint.Name)
+		}
+		if limit.OnExceeded != RateLimitFailover && limit.OnExceeded != RateLimitQueue {
+			return fmt.Errorf("endpoint %s: rate_limit on_exceeded must be 'failover' or 'queue'", endpoint.Name)
+		}
+		if limit.MaxWait < 0 {
+			return fmt.Errorf("endpoint %s: rate_limit max_wait must be non-negative", endpoint.Name)
+		}
+	}
+	return nil
+}
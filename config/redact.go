@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// RedactedPlaceholder replaces secret values in exported configs
+const RedactedPlaceholder = "<REDACTED>"
+
+// secretKeys are the mapping keys whose values are credentials
+var secretKeys = map[string]bool{
+	"token":         true, // auth.token, endpoint and group tokens
+	"tokens":        true, // endpoint token rotation lists, auth.tokens
+	"api-key":       true, // endpoint API keys
+	"password":      true, // webui.password, proxy.password
+	"client_secret": true, // endpoint OAuth2 client secret
+}
+
+// MaskSecret keeps just enough of a secret to recognise it, for logs and API responses
+func MaskSecret(value string) string {
+	if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return scheme + " " + MaskSecret(token)
+	}
+	if len(value) <= 12 {
+		return "****"
+	}
+	return value[:4] + "****" + value[len(value)-4:]
+}
+
+// CredentialWarning describes a credential that needs to be filled in before a config is used
+type CredentialWarning struct {
+	Path    string `json:"path"`    // Location in the config, e.g. endpoints[1].token
+	Line    int    `json:"line"`    // Line number in the YAML
+	Reason  string `json:"reason"`  // "redacted" or "empty"
+	Message string `json:"message"` // Human-readable description
+}
+
+// RedactSecrets replaces credential values in a YAML config with RedactedPlaceholder. It
+// rewrites the parsed node tree, so comments and all other values are kept.
+func RedactSecrets(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid configuration format: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return data, nil
+	}
+
+	walkCredentials(&doc, "", func(path, key string, value *yaml.Node) {
+		if value.Kind != yaml.ScalarNode || value.Tag == "!!null" || value.Value == "" {
+			return
+		}
+		if key == "url" {
+			// Proxy URLs may embed user:password@
+			if redacted, ok := redactURLPassword(value.Value); ok {
+				value.Value = redacted
+				value.Style = yaml.DoubleQuotedStyle
+			}
+			return
+		}
+		value.Value = RedactedPlaceholder
+		value.Tag = "!!str"
+		value.Style = yaml.DoubleQuotedStyle
+	})
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	encoder.Close()
+	return buf.Bytes(), nil
+}
+
+// CheckCredentials reports redaction placeholders and literal empty credential strings, so
+// the user can fill them in before switching to the config. Invalid YAML yields no warnings.
+func CheckCredentials(data []byte) []CredentialWarning {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+
+	warnings := make([]CredentialWarning, 0)
+	walkCredentials(&doc, "", func(path, key string, value *yaml.Node) {
+		if value.Kind != yaml.ScalarNode {
+			return
+		}
+		switch {
+		case strings.Contains(value.Value, RedactedPlaceholder):
+			warnings = append(warnings, CredentialWarning{
+				Path:    path,
+				Line:    value.Line,
+				Reason:  "redacted",
+				Message: fmt.Sprintf("%s is a redaction placeholder; enter the real value", path),
+			})
+		case key != "url" && value.Tag == "!!str" && value.Value == "":
+			warnings = append(warnings, CredentialWarning{
+				Path:    path,
+				Line:    value.Line,
+				Reason:  "empty",
+				Message: fmt.Sprintf("%s is an empty string; fill it in or remove the key", path),
+			})
+		}
+	})
+	return warnings
+}
+
+// walkCredentials calls fn for every credential value in the document: the values of
+// secretKeys anywhere, and proxy.url. path is a dotted location such as endpoints[0].token.
+func walkCredentials(node *yaml.Node, path string, fn func(path, key string, value *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkCredentials(child, path, fn)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			walkCredentials(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := key.Value
+			if path != "" {
+				childPath = path + "." + key.Value
+			}
+			if secretKeys[key.Value] && value.Kind == yaml.SequenceNode {
+				for j, item := range value.Content {
+					itemPath := fmt.Sprintf("%s[%d]", childPath, j)
+					if item.Kind == yaml.MappingNode {
+						// Named entries such as auth.tokens: [{name, token}]
+						walkCredentials(item, itemPath, fn)
+						continue
+					}
+					fn(itemPath, key.Value, item)
+				}
+				continue
+			}
+			if secretKeys[key.Value] || childPath == "proxy.url" {
+				fn(childPath, key.Value, value)
+				continue
+			}
+			walkCredentials(value, childPath, fn)
+		}
+	}
+}
+
+// redactURLPassword replaces the password in a URL's user info
+func redactURLPassword(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw, false
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return raw, false
+	}
+	// Build the string by hand: url.UserPassword would percent-encode the placeholder
+	userInfo := u.User.Username() + ":" + RedactedPlaceholder
+	return strings.Replace(raw, u.User.String(), userInfo, 1), true
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RuleConfig is a request filter evaluated before endpoint selection. Rules are checked
+// in order and the first matching rule's action is applied.
+type RuleConfig struct {
+	Name   string           `yaml:"name"`   // Rule name, used in logs and metrics
+	Match  RuleMatchConfig  `yaml:"match"`  // All configured conditions must match
+	Action RuleActionConfig `yaml:"action"` // What to do with a matching request
+}
+
+type RuleMatchConfig struct {
+	PathPrefix string `yaml:"path_prefix"` // Request path prefix, e.g. "/v1/messages"
+	PathRegex  string `yaml:"path_regex"`  // Regular expression for the request path, e.g. "^/v1/embeddings"
+	Method     string `yaml:"method"`      // HTTP method, e.g. "POST" (case-insensitive)
+	Field      string `yaml:"field"`       // Top-level JSON body field matched by glob/regex, default: "model"
+	Glob       string `yaml:"glob"`        // Glob pattern for the body field, e.g. "claude-3-opus*"
+	Regex      string `yaml:"regex"`       // Regular expression for the body field
+	Client     string `yaml:"client"`      // Glob pattern for the client identity (auth client name or IP)
+}
+
+type RuleActionConfig struct {
+	Type    string `yaml:"type"`    // "deny", "route-to-group" or "rewrite-model"
+	Status  int    `yaml:"status"`  // Response status for deny, default: 403
+	Message string `yaml:"message"` // Error message for deny
+	Group   string `yaml:"group"`   // Target group for route-to-group
+	Model   string `yaml:"model"`   // Replacement model for rewrite-model
+}
+
+// Rule actions
+const (
+	RuleActionDeny         = "deny"
+	RuleActionRouteToGroup = "route-to-group"
+	RuleActionRewriteModel = "rewrite-model"
+)
+
+// setRuleDefaults fills in defaults for request rules
+func (c *Config) setRuleDefaults() {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Match.Field == "" && (rule.Match.Glob != "" || rule.Match.Regex != "") {
+			rule.Match.Field = "model"
+		}
+		if rule.Action.Type == RuleActionDeny {
+			if rule.Action.Status == 0 {
+				rule.Action.Status = http.StatusForbidden
+			}
+			if rule.Action.Message == "" {
+				rule.Action.Message = fmt.Sprintf("Request blocked by rule %s", rule.Name)
+			}
+		}
+	}
+}
+
+// validateRules validates the request rules
+func (c *Config) validateRules() error {
+	groups := make(map[string]bool)
+	for _, endpoint := range c.Endpoints {
+		groups[endpoint.Group] = true
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range c.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		match := rule.Match
+		if match.PathPrefix == "" && match.PathRegex == "" && match.Method == "" && match.Glob == "" && match.Regex == "" && match.Client == "" {
+			return fmt.Errorf("rule %s: match requires at least one of path_prefix, path_regex, method, glob, regex or client", rule.Name)
+		}
+		if match.PathRegex != "" {
+			if _, err := regexp.Compile(match.PathRegex); err != nil {
+				return fmt.Errorf("rule %s: invalid path_regex %q: %w", rule.Name, match.PathRegex, err)
+			}
+		}
+		if strings.ContainsAny(match.Method, " \t,") {
+			return fmt.Errorf("rule %s: method must be a single HTTP method, got %q", rule.Name, match.Method)
+		}
+		if match.Glob != "" && match.Regex != "" {
+			return fmt.Errorf("rule %s: match glob and regex are mutually exclusive", rule.Name)
+		}
+		if match.Glob != "" {
+			if _, err := path.Match(match.Glob, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid glob %q: %w", rule.Name, match.Glob, err)
+			}
+		}
+		if match.Regex != "" {
+			if _, err := regexp.Compile(match.Regex); err != nil {
+				return fmt.Errorf("rule %s: invalid regex %q: %w", rule.Name, match.Regex, err)
+			}
+		}
+		if match.Client != "" {
+			if _, err := path.Match(match.Client, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid client pattern %q: %w", rule.Name, match.Client, err)
+			}
+		}
+
+		switch rule.Action.Type {
+		case RuleActionDeny:
+			if rule.Action.Status < 400 || rule.Action.Status > 599 {
+				return fmt.Errorf("rule %s: deny status must be between 400 and 599", rule.Name)
+			}
+		case RuleActionRouteToGroup:
+			if rule.Action.Group == "" {
+				return fmt.Errorf("rule %s: route-to-group requires a group", rule.Name)
+			}
+			// Setup mode has no endpoints yet, so groups cannot be checked
+			if len(c.Endpoints) > 0 && !groups[rule.Action.Group] {
+				return fmt.Errorf("rule %s: group %s has no endpoints", rule.Name, rule.Action.Group)
+			}
+		case RuleActionRewriteModel:
+			if rule.Action.Model == "" {
+				return fmt.Errorf("rule %s: rewrite-model requires a model", rule.Name)
+			}
+		default:
+			return fmt.Errorf("rule %s: action type must be 'deny', 'route-to-group', or 'rewrite-model'", rule.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoEndpoints is returned by validation when a configuration defines no endpoints
+var ErrNoEndpoints = errors.New("at least one endpoint must be configured")
+
+// configTemplate is written by --init. It has no endpoints, so starting with it enters
+// setup mode with the WebUI enabled on localhost.
+const configTemplate = `# Claude Request Forwarder Configuration
+# 由 --init 生成的配置模板。未配置任何端点时程序以"设置模式"启动：
+# 转发请求返回 503，可通过 WebUI 导入/粘贴配置，或直接编辑本文件。
+# 保存包含至少一个端点的有效配置后即开始转发，无需重启。
+
+# 服务器配置
+server:
+  host: "127.0.0.1"      # 监听地址，默认: localhost
+  port: 8080             # 监听端口，默认: 8080
+
+# 路由策略配置(适用于组内)
+strategy:
+  type: "priority"       # priority | fastest | round-robin
+
+# 重试配置
+retry:
+  max_attempts: 3
+  base_delay: "1s"
+  max_delay: "30s"
+  multiplier: 2.0
+
+# 健康检查配置
+health:
+  check_interval: "30s"
+  timeout: "5s"
+  health_path: "/v1/models"
+
+# 日志配置
+logging:
+  level: "info"          # debug | info | warn | error
+  format: "text"         # text | json
+
+# 组配置
+group:
+  cooldown: "600s"
+  max_retries: 3
+
+# 鉴权配置 - 对外开放前请启用
+auth:
+  enabled: false
+  # token: "your-forwarder-token"
+
+# TUI 界面配置
+tui:
+  enabled: true
+  update_interval: "1s"
+
+# WebUI 配置 - 设置模式下用于导入配置
+webui:
+  enabled: true
+  host: "127.0.0.1"
+  port: 8003
+  password: ""           # 建议设置访问密码
+
+# 端点配置 - 取消注释并填写后保存即可开始转发
+endpoints:
+  # - name: "primary"
+  #   url: "https://api.anthropic.com"
+  #   group: "main"
+  #   group-priority: 1
+  #   priority: 1
+  #   timeout: "300s"
+  #   token: "sk-your-api-key"
+  #
+  # - name: "backup"
+  #   url: "https://backup.example.com"
+  #   priority: 2
+`
+
+// Template returns the commented configuration template written by --init
+func Template() string {
+	return configTemplate
+}
+
+// IsSetupMode reports whether the configuration has no endpoints yet. This only happens
+// when the process was started without a usable config; requests cannot be forwarded.
+func (c *Config) IsSetupMode() bool {
+	return len(c.Endpoints) == 0
+}
+
+// LoadSetupConfig loads the settings for setup mode. A missing file yields the defaults;
+// an existing file is parsed and validated except for the endpoint requirement.
+func LoadSetupConfig(path string) (*Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err == nil {
+		if err := unmarshalConfig(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	config.setDefaults()
+	if err := config.validate(); err != nil && !errors.Is(err, ErrNoEndpoints) {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &config, nil
+}
+
+// WriteTemplate writes a commented configuration template to path. It refuses to
+// overwrite an existing file.
+func WriteTemplate(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("config file already exists: %s", path)
+		}
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(configTemplate); err != nil {
+		return fmt.Errorf("failed to write config template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadSheddingConfig answers requests with 503 right away while no endpoint can take them,
+// instead of letting each one walk the retry ladder during an outage
+type LoadSheddingConfig struct {
+	Enabled               bool          `yaml:"enabled"`                 // Fail fast while every endpoint is unhealthy or in a group in cooldown, default: false
+	RecoveryProbeInterval time.Duration `yaml:"recovery_probe_interval"` // How often one request is still forwarded to detect recovery, default: 10s
+}
+
+// setLoadSheddingDefaults fills in load shedding defaults
+func (c *Config) setLoadSheddingDefaults() {
+	if c.LoadShedding.RecoveryProbeInterval == 0 {
+		c.LoadShedding.RecoveryProbeInterval = 10 * time.Second
+	}
+}
+
+// validateLoadShedding validates the load shedding settings
+func (c *Config) validateLoadShedding() error {
+	if c.LoadShedding.RecoveryProbeInterval < 0 {
+		return fmt.Errorf("load_shedding: recovery_probe_interval must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TransformConfig rewrites the top-level fields of the JSON request body sent to one
+// endpoint. Fields are removed first, then renamed, then defaulted.
+type TransformConfig struct {
+	RemoveFields  []string          `yaml:"remove_fields,omitempty"`  // Fields dropped from the body, e.g. [metadata]
+	RenameFields  map[string]string `yaml:"rename_fields,omitempty"`  // Old name -> new name, e.g. {stop_sequences: stop}
+	DefaultFields map[string]any    `yaml:"default_fields,omitempty"` // Fields added when the body has none, e.g. {max_tokens: 4096}
+}
+
+// IsEmpty reports whether the transform changes nothing
+func (t *TransformConfig) IsEmpty() bool {
+	return t == nil || (len(t.RemoveFields) == 0 && len(t.RenameFields) == 0 && len(t.DefaultFields) == 0)
+}
+
+// validateTransforms validates the request body transforms of the endpoints
+func (c *Config) validateTransforms() error {
+	for _, endpoint := range c.Endpoints {
+		t := endpoint.Transform
+		if t == nil {
+			continue
+		}
+		for _, field := range t.RemoveFields {
+			if field == "" {
+				return fmt.Errorf("endpoint %s: transform remove_fields must not contain empty entries", endpoint.Name)
+			}
+		}
+		for from, to := range t.RenameFields {
+			if from == "" || to == "" {
+				return fmt.Errorf("endpoint %s: transform rename_fields entries need both an old and a new name (got %q: %q)", endpoint.Name, from, to)
+			}
+			if from == to {
+				return fmt.Errorf("endpoint %s: transform rename_fields renames %q to itself", endpoint.Name, from)
+			}
+		}
+		for field, value := range t.DefaultFields {
+			if field == "" {
+				return fmt.Errorf("endpoint %s: transform default_fields must not contain empty names", endpoint.Name)
+			}
+			if _, err := json.Marshal(value); err != nil {
+				return fmt.Errorf("endpoint %s: transform default_fields %s cannot be encoded as JSON: %w", endpoint.Name, field, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// TransportConfig tunes the connection pools used to reach endpoints
+type TransportConfig struct {
+	MaxIdleConns        int           `yaml:"max_idle_conns"`          // Idle connections kept across all hosts of an endpoint's pool, default: 100
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"` // Idle connections kept per upstream host, default: 10
+	MaxConnsPerHost     int           `yaml:"max_conns_per_host"`      // Connections per upstream host including active ones, 0 = unlimited
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`       // How long an idle connection is kept, default: 90s
+	EnableHTTP2         bool          `yaml:"enable_http2"`            // Negotiate HTTP/2 with upstreams that support it, default: false
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout"`   // default: 10s
+}
+
+// setTransportDefaults fills in defaults for connection pool settings
+func (c *Config) setTransportDefaults() {
+	if c.Transport.MaxIdleConns == 0 {
+		c.Transport.MaxIdleConns = 100
+	}
+	if c.Transport.MaxIdleConnsPerHost == 0 {
+		c.Transport.MaxIdleConnsPerHost = 10
+	}
+	if c.Transport.IdleConnTimeout == 0 {
+		c.Transport.IdleConnTimeout = 90 * time.Second
+	}
+	if c.Transport.TLSHandshakeTimeout == 0 {
+		c.Transport.TLSHandshakeTimeout = 10 * time.Second
+	}
+}
+
+// validateTransport validates connection pool settings
+func (c *Config) validateTransport() error {
+	t := c.Transport
+	if t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.MaxConnsPerHost < 0 {
+		return fmt.Errorf("transport: connection limits cannot be negative")
+	}
+	if t.IdleConnTimeout < 0 || t.TLSHandshakeTimeout < 0 {
+		return fmt.Errorf("transport: timeouts cannot be negative")
+	}
+	if t.MaxConnsPerHost > 0 && t.MaxIdleConnsPerHost > t.MaxConnsPerHost {
+		return fmt.Errorf("transport: max_idle_conns_per_host (%d) cannot exceed max_conns_per_host (%d)", t.MaxIdleConnsPerHost, t.MaxConnsPerHost)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func watcherTestConfig(port int) string {
+	return fmt.Sprintf(`
+server:
+  host: "localhost"
+  port: %d
+
+endpoints:
+  - name: "primary"
+    url: "https://api.example.com"
+    priority: 1
+`, port)
+}
+
+// replaceFile mimics editors that save by writing a temp file and renaming it over the target
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".swp")
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("failed to rename temp file: %v", err)
+	}
+}
+
+func waitForReload(t *testing.T, reloaded <-chan *Config, wantPort int) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case cfg := <-reloaded:
+			if cfg.Server.Port == wantPort {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("reload callback did not fire for port %d", wantPort)
+		}
+	}
+}
+
+func newTestWatcher(t *testing.T, pollInterval time.Duration) (*ConfigWatcher, string, <-chan *Config) {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(watcherTestConfig(8080)), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cw, err := newConfigWatcher(configPath, logger, pollInterval)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	t.Cleanup(func() { cw.Close() })
+
+	reloaded := make(chan *Config, 10)
+	cw.AddReloadCallback(func(cfg *Config) {
+		reloaded <- cfg
+	})
+	return cw, configPath, reloaded
+}
+
+func TestConfigWatcherRenameReplace(t *testing.T) {
+	// Disable polling so the reload must come from fsnotify
+	cw, configPath, reloaded := newTestWatcher(t, 0)
+
+	replaceFile(t, configPath, watcherTestConfig(8081))
+	waitForReload(t, reloaded, 8081)
+
+	// A second replace must still be noticed after the file watch was re-established
+	replaceFile(t, configPath, watcherTestConfig(8082))
+	waitForReload(t, reloaded, 8082)
+
+	if port := cw.GetConfig().Server.Port; port != 8082 {
+		t.Errorf("expected port 8082 after reload, got %d", port)
+	}
+}
+
+func TestConfigWatcherPollingFallback(t *testing.T) {
+	cw, configPath, reloaded := newTestWatcher(t, 50*time.Millisecond)
+
+	// Simulate a broken fsnotify pipeline by dropping all watches
+	cw.watcher.Remove(configPath)
+	cw.watcher.Remove(filepath.Dir(configPath))
+
+	// Ensure the modification time differs even on coarse-grained filesystems
+	if err := os.WriteFile(configPath, []byte(watcherTestConfig(9090)), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	future := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(configPath, future, future); err != nil {
+		t.Fatalf("failed to update mod time: %v", err)
+	}
+
+	waitForReload(t, reloaded, 9090)
+
+	// After noticing the missed change the poller should restore the file watch
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		watched := false
+		for _, path := range cw.watcher.WatchList() {
+			if path == configPath {
+				watched = true
+			}
+		}
+		if watched {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected polling fallback to re-establish the config file watch")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestConfigWatcherSetupMode(t *testing.T) {
+	// A fresh install: neither the config file nor its directory exist yet
+	configPath := filepath.Join(t.TempDir(), "config", "config.yaml")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cw, err := newConfigWatcher(configPath, logger, 0)
+	if err != nil {
+		t.Fatalf("Expected setup mode instead of an error, got %v", err)
+	}
+	t.Cleanup(func() { cw.Close() })
+
+	if !cw.GetConfig().IsSetupMode() {
+		t.Fatal("Expected watcher to start in setup mode")
+	}
+	if _, err := cw.GetRegistry().GetConfig("config"); err != nil {
+		t.Errorf("Expected setup config to be registered for WebUI editing: %v", err)
+	}
+
+	reloaded := make(chan *Config, 10)
+	cw.AddReloadCallback(func(cfg *Config) {
+		reloaded <- cfg
+	})
+
+	// Writing a valid config leaves setup mode without a restart
+	if err := os.WriteFile(configPath, []byte(watcherTestConfig(8082)), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	waitForReload(t, reloaded, 8082)
+	if cw.GetConfig().IsSetupMode() {
+		t.Error("Expected setup mode to end once endpoints are configured")
+	}
+}
+
+func TestConfigWatcherStatus(t *testing.T) {
+	cw, configPath, reloaded := newTestWatcher(t, 0)
+
+	status := cw.Status()
+	if status.LastActivity.IsZero() || !status.LastReload.IsZero() || status.ErrorCount != 0 {
+		t.Fatalf("Unexpected initial status: %+v", status)
+	}
+
+	replaceFile(t, configPath, watcherTestConfig(8083))
+	waitForReload(t, reloaded, 8083)
+	if status := cw.Status(); status.LastReload.IsZero() {
+		t.Error("Expected LastReload to be set after a reload")
+	}
+
+	// An invalid config is counted as an error and keeps the previous config
+	replaceFile(t, configPath, "server: [")
+	deadline := time.Now().Add(5 * time.Second)
+	for cw.Status().ErrorCount == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected failed reload to be recorded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status := cw.Status(); status.LastError == "" || status.LastErrorTime.Before(status.LastReload) {
+		t.Errorf("Expected last error after the last reload, got %+v", status)
+	}
+	if filepath.Dir(cw.RegistryPath()) != filepath.Dir(configPath) {
+		t.Errorf("Expected registry next to the config, got %s", cw.RegistryPath())
+	}
+}
+
+func TestConfigWatcherEndpointsSource(t *testing.T) {
+	var document atomic.Value
+	document.Store(`endpoints:
+  - name: "remote-a"
+    url: "https://a.example.com"
+    group: "remote"
+  - name: "primary"
+    url: "https://shadowed.example.com"
+`)
+	var failing atomic.Bool
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer registry-token" || failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(document.Load().(string)))
+	}))
+	defer registry.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	local := watcherTestConfig(8080) + fmt.Sprintf(`    group: "local"
+    api-key: "local-key"
+
+endpoints_source:
+  url: %q
+  token: "registry-token"
+`, registry.URL)
+	if err := os.WriteFile(configPath, []byte(local), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cw, err := newConfigWatcher(configPath, logger, 0)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	t.Cleanup(func() { cw.Close() })
+	reloaded := make(chan *Config, 10)
+	cw.AddReloadCallback(func(cfg *Config) {
+		reloaded <- cfg
+	})
+
+	// The first fetch happens before startup; the local primary wins over the remote one
+	cfg := cw.GetConfig()
+	if len(cfg.Endpoints) != 2 || cfg.RemoteEndpointCount() != 1 {
+		t.Fatalf("Expected the local and one remote endpoint, got %+v", cfg.Endpoints)
+	}
+	if primary := cfg.Endpoints[0]; primary.URL != "https://api.example.com" || primary.Remote {
+		t.Errorf("Expected the local primary to win, got %+v", primary)
+	}
+	if remote := cfg.Endpoints[1]; remote.Name != "remote-a" || !remote.Remote || remote.ApiKey != "" || remote.Timeout != cfg.GlobalTimeout {
+		t.Errorf("Expected remote-a without the local endpoint's settings, got %+v", remote)
+	}
+
+	// A new endpoint in the document is applied through the reload callbacks
+	document.Store(`- name: "remote-a"
+  url: "https://a.example.com"
+- name: "remote-b"
+  url: "https://b.example.com"
+`)
+	cw.refreshEndpoints()
+	select {
+	case cfg = <-reloaded:
+	default:
+		t.Fatal("Expected the changed document to be applied")
+	}
+	if len(cfg.Endpoints) != 3 || cfg.Endpoints[2].Name != "remote-b" {
+		t.Fatalf("Expected remote-b to be added, got %+v", cfg.Endpoints)
+	}
+
+	// Failed fetches and invalid documents keep the last good endpoints
+	failing.Store(true)
+	cw.refreshEndpoints()
+	failing.Store(false)
+	document.Store(`endpoints: []`)
+	cw.refreshEndpoints()
+	document.Store(`- name: "remote-c"`)
+	cw.refreshEndpoints()
+	if len(reloaded) != 0 || len(cw.GetConfig().Endpoints) != 3 || cw.Status().ErrorCount != 3 {
+		t.Fatalf("Expected the endpoints to be kept with 3 errors, got %d endpoints and %+v", len(cw.GetConfig().Endpoints), cw.Status())
+	}
+
+	// Reloading the config file keeps the remote endpoints
+	replaceFile(t, configPath, strings.Replace(local, "port: 8080", "port: 8081", 1))
+	waitForReload(t, reloaded, 8081)
+	if cfg := cw.GetConfig(); len(cfg.Endpoints) != 3 {
+		t.Errorf("Expected the remote endpoints to survive a file reload, got %+v", cfg.Endpoints)
+	}
+
+	// Remote endpoints cannot be edited in the file
+	if err := SaveEndpointEditsWithComments(cw.GetConfig(), configPath, map[int]EndpointEdit{2: {URL: "https://c.example.com"}}); err == nil {
+		t.Error("Expected editing a remote endpoint to fail")
+	}
+	if err := SaveEndpointEditsWithComments(cw.GetConfig(), configPath, map[int]EndpointEdit{0: {Timeout: time.Minute}}); err != nil {
+		t.Errorf("Expected editing the local endpoint to work, got %v", err)
+	}
+}
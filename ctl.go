@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/admin"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// ctlUsage lists the ctl subcommands
+const ctlUsage = `Usage: %s ctl [-socket path] [-config file] [-json] <command>
+
+Commands:
+  endpoint list
+  endpoint set-priority NAME PRIORITY
+  endpoint maintenance NAME on|off
+  group list
+  group clear-cooldown NAME
+  config switch NAME
+  config reload
+  metrics
+`
+
+// runCtlCommand controls a running forwarder through its admin socket
+func runCtlCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, ctlUsage, os.Args[0])
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	socketPath := fs.String("socket", "", "Admin socket path (default: admin.socket_path from the config)")
+	path := fs.String("config", "config/example.yaml", "Path to configuration file (for the admin socket path)")
+	asJSON := fs.Bool("json", false, "Print list and metrics output as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *socketPath == "" {
+		cfg, err := config.LoadSetupConfig(*path)
+		if err != nil {
+			fmt.Fprintf(stderr, "❌ %s: %v\n", *path, err)
+			return 1
+		}
+		if cfg.Admin.SocketPath == "" {
+			fmt.Fprintf(stderr, "❌ The admin socket is not enabled in %s (admin.socket_path); use -socket to pass its path\n", *path)
+			return 1
+		}
+		*socketPath = cfg.Admin.SocketPath
+	}
+
+	ctl := &ctlRunner{client: admin.NewClient(*socketPath), stdout: stdout, json: *asJSON}
+	err := ctl.run(fs.Args())
+	if err == errCtlUsage {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "❌ %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// errCtlUsage reports an unknown ctl command or wrong arguments
+var errCtlUsage = errors.New("invalid ctl command")
+
+// ctlRunner runs one ctl command against the admin API
+type ctlRunner struct {
+	client *admin.Client
+	stdout io.Writer
+	json   bool
+}
+
+func (c *ctlRunner) run(args []string) error {
+	command := args[0]
+	if len(args) > 1 {
+		command += " " + args[1]
+	}
+
+	switch {
+	case command == "endpoint list" && len(args) == 2:
+		endpoints, err := c.client.Endpoints()
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(endpoints)
+		}
+		tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tGROUP\tPRIORITY\tSTATUS\tRESPONSE\tIN-FLIGHT")
+		for _, ep := range endpoints {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%dms\t%d\n", ep.Name, ep.Group, ep.Priority, endpointState(ep), ep.ResponseTimeMs, ep.InFlight)
+		}
+		return tw.Flush()
+
+	case command == "endpoint set-priority" && len(args) == 4:
+		priority, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid priority %q", args[3])
+		}
+		if err := c.client.SetPriority(args[2], priority); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "✅ Endpoint %s priority set to %d\n", args[2], priority)
+
+	case command == "endpoint maintenance" && len(args) == 4:
+		var enabled bool
+		switch args[3] {
+		case "on":
+			enabled = true
+		case "off":
+		default:
+			return errCtlUsage
+		}
+		if err := c.client.SetMaintenance(args[2], enabled); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "✅ Endpoint %s maintenance %s\n", args[2], args[3])
+
+	case command == "group list" && len(args) == 2:
+		groups, err := c.client.Groups()
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(groups)
+		}
+		tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tPRIORITY\tACTIVE\tCOOLDOWN\tRETRIES\tENDPOINTS")
+		for _, group := range groups {
+			cooldown := group.CooldownRemaining
+			if cooldown == "" {
+				cooldown = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%v\t%s\t%d/%d\t%d\n", group.Name, group.Priority, group.Active, cooldown, group.RetryCount, group.MaxRetries, group.Endpoints)
+		}
+		return tw.Flush()
+
+	case command == "group clear-cooldown" && len(args) == 3:
+		if err := c.client.ClearGroupCooldown(args[2]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "✅ Group %s cooldown cleared\n", args[2])
+
+	case command == "config switch" && len(args) == 3:
+		if err := c.client.SwitchConfig(args[2]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "✅ Switched to config %s\n", args[2])
+
+	case command == "config reload" && len(args) == 2:
+		if err := c.client.Reload(); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, "✅ Config reloaded")
+
+	case command == "metrics" && len(args) == 1:
+		metrics, err := c.client.Metrics()
+		if err != nil {
+			return err
+		}
+		return c.printJSON(metrics)
+
+	default:
+		return errCtlUsage
+	}
+	return nil
+}
+
+func (c *ctlRunner) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// endpointState summarizes an endpoint's status in one word
+func endpointState(ep admin.EndpointStatus) string {
+	switch {
+	case ep.Maintenance:
+		return "maintenance"
+	case ep.RateLimited && ep.RateLimitedFor != "":
+		return "rate-limited for " + ep.RateLimitedFor
+	case ep.RateLimited:
+		return "rate-limited"
+	case ep.Circuit == endpoint.BreakerOpen || ep.Circuit == endpoint.BreakerHalfOpen:
+		return "circuit-" + ep.Circuit
+	case ep.Healthy:
+		return "healthy"
+	default:
+		return "unhealthy"
+	}
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Client calls the admin API of a running forwarder over its Unix socket
+type Client struct {
+	socketPath string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the admin socket at socketPath
+func NewClient(socketPath string) *Client {
+	return &Client{
+		socketPath: socketPath,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Endpoints lists all endpoints with their status
+func (c *Client) Endpoints() ([]EndpointStatus, error) {
+	var response struct {
+		Endpoints []EndpointStatus `json:"endpoints"`
+	}
+	err := c.do(http.MethodGet, "/v1/endpoints", nil, &response)
+	return response.Endpoints, err
+}
+
+// SetPriority changes an endpoint's priority
+func (c *Client) SetPriority(name string, priority int) error {
+	return c.do(http.MethodPost, "/v1/endpoints/priority", map[string]interface{}{"name": name, "priority": priority}, nil)
+}
+
+// SetMaintenance puts an endpoint into (enabled) or out of maintenance mode
+func (c *Client) SetMaintenance(name string, enabled bool) error {
+	return c.do(http.MethodPost, "/v1/endpoints/maintenance", map[string]interface{}{"name": name, "enabled": enabled}, nil)
+}
+
+// Groups lists the endpoint groups with their cooldown state
+func (c *Client) Groups() ([]GroupStatus, error) {
+	var response struct {
+		Groups []GroupStatus `json:"groups"`
+	}
+	err := c.do(http.MethodGet, "/v1/groups", nil, &response)
+	return response.Groups, err
+}
+
+// ClearGroupCooldown ends a group's cooldown early
+func (c *Client) ClearGroupCooldown(name string) error {
+	return c.do(http.MethodPost, "/v1/groups/clear-cooldown", map[string]interface{}{"name": name}, nil)
+}
+
+// SwitchConfig switches the active configuration to one from the registry by name
+func (c *Client) SwitchConfig(name string) error {
+	return c.do(http.MethodPost, "/v1/config/switch", map[string]interface{}{"name": name}, nil)
+}
+
+// Reload reloads the active config file
+func (c *Client) Reload() error {
+	return c.do(http.MethodPost, "/v1/config/reload", nil, nil)
+}
+
+// Metrics returns the current request, response time and token metrics
+func (c *Client) Metrics() (*Metrics, error) {
+	var metrics Metrics
+	if err := c.do(http.MethodGet, "/v1/metrics", nil, &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored: every request goes to the socket
+	req, err := http.NewRequest(method, "http://admin"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("no forwarder is listening on %s (is admin.socket_path set and the server running?)", c.socketPath)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&failure) == nil && failure.Error != "" {
+			return errors.New(failure.Error)
+		}
+		return fmt.Errorf("admin API returned %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,460 @@
+// Package admin serves a small REST API on a Unix domain socket so scripts can control a
+// running forwarder without the WebUI. There is no password: access to the socket file
+// (created with mode 0600) is the authentication.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/listen"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// EndpointStatus is an endpoint as listed by the admin API
+type EndpointStatus struct {
+	Name             string `json:"name"`
+	URL              string `json:"url"`
+	Group            string `json:"group"`
+	Priority         int    `json:"priority"`
+	Healthy          bool   `json:"healthy"`
+	Maintenance      bool   `json:"maintenance"`
+	RateLimited      bool   `json:"rate_limited"`
+	RateLimitedFor   string `json:"rate_limited_for,omitempty"` // Time left until the upstream rate limit ends, empty when not rate limited
+	Circuit          string `json:"circuit"`                    // Circuit breaker state: closed, open or half-open
+	ResponseTimeMs   int64  `json:"response_time_ms"`
+	ConsecutiveFails int    `json:"consecutive_fails"`
+	ConsecutiveOKs   int    `json:"consecutive_oks"` // Good checks or requests in a row, toward health.healthy_threshold
+	InFlight         int64  `json:"in_flight"`
+	LastCheck        string `json:"last_check,omitempty"` // RFC 3339, empty before the first check
+}
+
+// GroupStatus is an endpoint group as listed by the admin API
+type GroupStatus struct {
+	Name              string `json:"name"`
+	Priority          int    `json:"priority"`
+	Active            bool   `json:"active"`
+	CooldownRemaining string `json:"cooldown_remaining,omitempty"` // Empty when not in cooldown
+	RetryCount        int    `json:"retry_count"`
+	MaxRetries        int    `json:"max_retries"`
+	Endpoints         int    `json:"endpoints"`
+}
+
+// Metrics is the request and token summary returned by the admin API
+type Metrics struct {
+	UptimeSeconds      int64                      `json:"uptime_seconds"`
+	TotalRequests      int64                      `json:"total_requests"`
+	SuccessfulRequests int64                      `json:"successful_requests"`
+	FailedRequests     int64                      `json:"failed_requests"`
+	CancelledRequests  int64                      `json:"cancelled_requests"`
+	CoalescedRequests  int64                      `json:"coalesced_requests"`
+	SuccessRate        float64                    `json:"success_rate"`
+	AvgResponseTimeMs  int64                      `json:"avg_response_time_ms"`
+	P95ResponseTimeMs  int64                      `json:"p95_response_time_ms"`
+	ActiveConnections  int                        `json:"active_connections"`
+	Tokens             TokenUsage                 `json:"tokens"`
+	Endpoints          map[string]EndpointMetrics `json:"endpoints"`
+}
+
+// EndpointMetrics is the per-endpoint part of Metrics
+type EndpointMetrics struct {
+	TotalRequests      int64      `json:"total_requests"`
+	SuccessfulRequests int64      `json:"successful_requests"`
+	FailedRequests     int64      `json:"failed_requests"`
+	RetryCount         int64      `json:"retry_count"`
+	RateLimitCount     int64      `json:"rate_limit_count"`
+	AvgResponseTimeMs  int64      `json:"avg_response_time_ms"`
+	P50ResponseTimeMs  int64      `json:"p50_response_time_ms"`
+	P95ResponseTimeMs  int64      `json:"p95_response_time_ms"`
+	P99ResponseTimeMs  int64      `json:"p99_response_time_ms"`
+	Tokens             TokenUsage `json:"tokens"`
+}
+
+// TokenUsage counts the tokens of proxied requests
+type TokenUsage struct {
+	InputTokens         int64 `json:"input_tokens"`
+	OutputTokens        int64 `json:"output_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_tokens"`
+}
+
+// Server owns the admin socket across config reloads: it listens when admin.socket_path
+// is set, stops when it is cleared and moves the socket when the path changes
+type Server struct {
+	endpointManager      *endpoint.Manager
+	monitoringMiddleware *middleware.MonitoringMiddleware
+	configWatcher        *config.ConfigWatcher // nil disables config switching and reloading
+
+	mutex      sync.Mutex
+	socketPath string
+	server     *http.Server
+}
+
+// NewServer creates an admin server calling the same manager and config watcher methods as
+// the WebUI. It does not listen until Apply is called with a socket path.
+func NewServer(endpointManager *endpoint.Manager, monitoringMiddleware *middleware.MonitoringMiddleware, configWatcher *config.ConfigWatcher) *Server {
+	return &Server{
+		endpointManager:      endpointManager,
+		monitoringMiddleware: monitoringMiddleware,
+		configWatcher:        configWatcher,
+	}
+}
+
+// Apply brings the admin socket in line with cfg
+func (s *Server) Apply(cfg *config.Config) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	socketPath := cfg.Admin.SocketPath
+	if s.server != nil && s.socketPath == socketPath {
+		return nil
+	}
+
+	if s.server != nil {
+		if socketPath != "" {
+			slog.Info(fmt.Sprintf("🔧 [管理接口] 套接字路径已变更，正在重新监听 - 新路径: %s", socketPath))
+		} else {
+			slog.Info("🔧 [管理接口] 已在配置中禁用，正在关闭管理套接字")
+		}
+		// The reload may have been requested over this very socket, so don't wait for it
+		server := s.server
+		go server.Close()
+		s.server = nil
+		s.socketPath = ""
+	}
+	if socketPath == "" {
+		return nil
+	}
+
+	listener, err := listenUnix(socketPath)
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	s.socketPath = socketPath
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error(fmt.Sprintf("❌ [管理接口] 管理套接字服务异常: %v", err))
+		}
+	}(s.server)
+
+	slog.Info(fmt.Sprintf("🔧 [管理接口] 管理套接字已启动: %s", socketPath))
+	return nil
+}
+
+// Stop closes the admin socket, waiting for in-flight requests, and removes the socket file
+func (s *Server) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	s.server = nil
+	s.socketPath = ""
+	return err
+}
+
+// SocketPath returns the path the admin socket listens on, or "" when it is disabled
+func (s *Server) SocketPath() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.socketPath
+}
+
+// listenUnix listens on socketPath with owner-only permissions. A socket file left behind by
+// a previous run is replaced, but not one another process is still serving.
+func listenUnix(socketPath string) (net.Listener, error) {
+	listener, err := listen.Unix(socketPath, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("admin: %w", err)
+	}
+	return listener, nil
+}
+
+// Handler returns the admin API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/endpoints", s.handleEndpoints)
+	mux.HandleFunc("/v1/endpoints/priority", s.handleEndpointPriority)
+	mux.HandleFunc("/v1/endpoints/maintenance", s.handleEndpointMaintenance)
+	mux.HandleFunc("/v1/groups", s.handleGroups)
+	mux.HandleFunc("/v1/groups/clear-cooldown", s.handleClearGroupCooldown)
+	mux.HandleFunc("/v1/config/switch", s.handleConfigSwitch)
+	mux.HandleFunc("/v1/config/reload", s.handleConfigReload)
+	mux.HandleFunc("/v1/metrics", s.handleMetrics)
+	return mux
+}
+
+// handleEndpoints lists all endpoints with their status, in priority order
+func (s *Server) handleEndpoints(rw http.ResponseWriter, r *http.Request) {
+	if !requireMethod(rw, r, http.MethodGet) {
+		return
+	}
+
+	endpoints := s.endpointManager.GetAllEndpoints()
+	now := time.Now()
+	list := make([]EndpointStatus, 0, len(endpoints))
+	for _, ep := range endpoints {
+		status := ep.GetStatus()
+		item := EndpointStatus{
+			Name:             ep.Config.Name,
+			URL:              ep.Config.DisplayURL(),
+			Group:            ep.Config.Group,
+			Priority:         ep.Config.Priority,
+			Healthy:          status.Healthy,
+			Maintenance:      status.Disabled,
+			RateLimited:      status.IsRateLimited(now),
+			Circuit:          status.BreakerState(now),
+			ResponseTimeMs:   status.ResponseTime.Milliseconds(),
+			ConsecutiveFails: status.ConsecutiveFails,
+			ConsecutiveOKs:   status.ConsecutiveOKs,
+			InFlight:         ep.InFlight(),
+		}
+		if item.RateLimited {
+			item.RateLimitedFor = status.RateLimitedUntil.Sub(now).Round(time.Second).String()
+		}
+		if !status.LastCheck.IsZero() {
+			item.LastCheck = status.LastCheck.Format(time.RFC3339)
+		}
+		list = append(list, item)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Priority < list[j].Priority })
+
+	writeJSON(rw, map[string]interface{}{"endpoints": list})
+}
+
+// handleEndpointPriority changes an endpoint's priority at runtime
+func (s *Server) handleEndpointPriority(rw http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name     string `json:"name"`
+		Priority int    `json:"priority"`
+	}
+	if !decodeRequest(rw, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		writeError(rw, http.StatusBadRequest, "endpoint name is required")
+		return
+	}
+	if request.Priority < 1 {
+		writeError(rw, http.StatusBadRequest, "priority must be at least 1")
+		return
+	}
+
+	if err := s.endpointManager.SetEndpointPriorities(map[string]int{request.Name: request.Priority}, "admin"); err != nil {
+		writeError(rw, http.StatusNotFound, err.Error())
+		return
+	}
+	slog.Info(fmt.Sprintf("🔧 [管理接口] 端点优先级已更新: %s → %d", request.Name, request.Priority))
+	writeJSON(rw, map[string]interface{}{"success": true, "name": request.Name, "priority": request.Priority})
+}
+
+// handleEndpointMaintenance puts an endpoint into or out of maintenance mode
+func (s *Server) handleEndpointMaintenance(rw http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"` // true puts the endpoint into maintenance mode
+	}
+	if !decodeRequest(rw, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		writeError(rw, http.StatusBadRequest, "endpoint name is required")
+		return
+	}
+
+	if err := s.endpointManager.SetEndpointMaintenance(request.Name, request.Enabled, "admin"); err != nil {
+		writeError(rw, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(rw, map[string]interface{}{"success": true, "name": request.Name, "maintenance": request.Enabled})
+}
+
+// handleGroups lists the endpoint groups with their cooldown state, in priority order
+func (s *Server) handleGroups(rw http.ResponseWriter, r *http.Request) {
+	if !requireMethod(rw, r, http.MethodGet) {
+		return
+	}
+
+	groupManager := s.endpointManager.GetGroupManager()
+	groups := groupManager.GetAllGroups()
+	list := make([]GroupStatus, 0, len(groups))
+	for _, group := range groups {
+		item := GroupStatus{
+			Name:       group.Name,
+			Priority:   group.Priority,
+			Active:     group.IsActive,
+			RetryCount: groupManager.GetGroupRetryCount(group.Name),
+			MaxRetries: groupManager.GetGroupMaxRetries(group.Name),
+			Endpoints:  len(group.Endpoints),
+		}
+		if remaining := groupManager.GetGroupCooldownRemaining(group.Name); remaining > 0 {
+			item.CooldownRemaining = remaining.Round(time.Second).String()
+		}
+		list = append(list, item)
+	}
+
+	writeJSON(rw, map[string]interface{}{"groups": list})
+}
+
+// handleClearGroupCooldown ends a group's cooldown early
+func (s *Server) handleClearGroupCooldown(rw http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if !decodeRequest(rw, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		writeError(rw, http.StatusBadRequest, "group name is required")
+		return
+	}
+
+	if !s.endpointManager.GetGroupManager().ClearGroupCooldown(request.Name) {
+		writeError(rw, http.StatusNotFound, fmt.Sprintf("group '%s' not found", request.Name))
+		return
+	}
+	writeJSON(rw, map[string]interface{}{"success": true, "name": request.Name})
+}
+
+// handleConfigSwitch switches the active configuration to one from the registry by name
+func (s *Server) handleConfigSwitch(rw http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if !decodeRequest(rw, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		writeError(rw, http.StatusBadRequest, "config name is required")
+		return
+	}
+	if s.configWatcher == nil {
+		writeError(rw, http.StatusServiceUnavailable, "configuration switching not available")
+		return
+	}
+
+	if err := s.configWatcher.SwitchConfig(request.Name); err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.Info(fmt.Sprintf("🔧 [管理接口] 已切换配置: %s", request.Name))
+	writeJSON(rw, map[string]interface{}{"success": true, "name": request.Name})
+}
+
+// handleConfigReload reloads the active config file right away
+func (s *Server) handleConfigReload(rw http.ResponseWriter, r *http.Request) {
+	if !requireMethod(rw, r, http.MethodPost) {
+		return
+	}
+	if s.configWatcher == nil {
+		writeError(rw, http.StatusServiceUnavailable, "configuration reloading not available")
+		return
+	}
+
+	if err := s.configWatcher.Reload(); err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(rw, map[string]interface{}{"success": true, "path": s.configWatcher.Status().ConfigPath})
+}
+
+// handleMetrics returns the current request, response time and token metrics
+func (s *Server) handleMetrics(rw http.ResponseWriter, r *http.Request) {
+	if !requireMethod(rw, r, http.MethodGet) {
+		return
+	}
+
+	source := s.monitoringMiddleware.GetMetrics()
+	snapshot := source.GetMetrics()
+	metrics := Metrics{
+		UptimeSeconds:      int64(time.Since(snapshot.StartTime).Seconds()),
+		TotalRequests:      snapshot.TotalRequests,
+		SuccessfulRequests: snapshot.SuccessfulRequests,
+		FailedRequests:     snapshot.FailedRequests,
+		CancelledRequests:  snapshot.CancelledRequests,
+		CoalescedRequests:  snapshot.CoalescedRequests,
+		SuccessRate:        source.GetSuccessRate(),
+		AvgResponseTimeMs:  source.GetAverageResponseTime().Milliseconds(),
+		P95ResponseTimeMs:  source.GetP95ResponseTime().Milliseconds(),
+		ActiveConnections:  len(snapshot.ActiveConnections),
+		Tokens:             newTokenUsage(snapshot.TotalTokenUsage),
+		Endpoints:          make(map[string]EndpointMetrics, len(snapshot.EndpointStats)),
+	}
+	for name, stats := range snapshot.EndpointStats {
+		item := EndpointMetrics{
+			TotalRequests:      stats.TotalRequests,
+			SuccessfulRequests: stats.SuccessfulRequests,
+			FailedRequests:     stats.FailedRequests,
+			RetryCount:         stats.RetryCount,
+			RateLimitCount:     stats.RateLimitCount,
+			Tokens:             newTokenUsage(stats.TokenUsage),
+		}
+		if stats.TotalRequests > 0 {
+			item.AvgResponseTimeMs = (stats.TotalResponseTime / time.Duration(stats.TotalRequests)).Milliseconds()
+		}
+		p50, p95, p99 := stats.LatencyPercentiles()
+		item.P50ResponseTimeMs, item.P95ResponseTimeMs, item.P99ResponseTimeMs = p50.Milliseconds(), p95.Milliseconds(), p99.Milliseconds()
+		metrics.Endpoints[name] = item
+	}
+
+	writeJSON(rw, metrics)
+}
+
+func newTokenUsage(usage monitor.TokenUsage) TokenUsage {
+	return TokenUsage{
+		InputTokens:         usage.InputTokens,
+		OutputTokens:        usage.OutputTokens,
+		CacheCreationTokens: usage.CacheCreationTokens,
+		CacheReadTokens:     usage.CacheReadTokens,
+	}
+}
+
+// requireMethod answers 405 unless the request uses method
+func requireMethod(rw http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// decodeRequest reads the JSON body of a POST request into v, answering the error itself
+func decodeRequest(rw http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if !requireMethod(rw, r, http.MethodPost) {
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		slog.Error(fmt.Sprintf("❌ [管理接口] 响应编码失败: %v", err))
+	}
+}
+
+// writeError answers with status and a JSON body {"error": message}
+func writeError(rw http.ResponseWriter, status int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,204 @@
+package admin
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+const adminTestConfig = `
+server:
+  port: %PORT%
+admin:
+  socket_path: "%SOCKET%"
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+    priority: 1
+    group: "main"
+    group-priority: 1
+  - name: "backup"
+    url: "https://backup.example.com"
+    priority: 2
+    group: "backup"
+    group-priority: 2
+`
+
+// newAdminTestServer starts an admin server on a temp socket with a config watcher over a
+// temp config file, and returns the server, a client and the config path
+func newAdminTestServer(t *testing.T) (*Server, *Client, string) {
+	t.Helper()
+
+	// Keep the path short: socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "ef-admin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "admin.sock")
+
+	configPath := filepath.Join(dir, "config.yaml")
+	writeAdminTestConfig(t, configPath, socketPath, 8080)
+	watcher, err := config.NewConfigWatcher(configPath, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Failed to create config watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+
+	manager := endpoint.NewManager(watcher.GetConfig())
+	watcher.AddReloadCallback(manager.UpdateConfig)
+	server := NewServer(manager, middleware.NewMonitoringMiddleware(manager), watcher)
+	if err := server.Apply(watcher.GetConfig()); err != nil {
+		t.Fatalf("Failed to start admin server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	return server, NewClient(socketPath), configPath
+}
+
+func writeAdminTestConfig(t *testing.T, configPath, socketPath string, port int) {
+	t.Helper()
+	content := strings.NewReplacer("%PORT%", strconv.Itoa(port), "%SOCKET%", socketPath).Replace(adminTestConfig)
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func TestAdminSocketEndToEnd(t *testing.T) {
+	server, client, configPath := newAdminTestServer(t)
+	socketPath := server.SocketPath()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Expected the socket file to exist: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected socket permissions 0600, got %o", perm)
+	}
+
+	endpoints, err := client.Endpoints()
+	if err != nil {
+		t.Fatalf("Listing endpoints failed: %v", err)
+	}
+	if len(endpoints) != 2 || endpoints[0].Name != "primary" || endpoints[1].Group != "backup" {
+		t.Fatalf("Unexpected endpoints: %+v", endpoints)
+	}
+
+	// Priority and maintenance changes go through to the manager
+	if err := client.SetPriority("backup", 0); err == nil {
+		t.Error("Expected priority 0 to be rejected")
+	}
+	if err := client.SetPriority("backup", 5); err != nil {
+		t.Fatalf("Setting priority failed: %v", err)
+	}
+	if err := client.SetMaintenance("primary", true); err != nil {
+		t.Fatalf("Enabling maintenance failed: %v", err)
+	}
+	if err := client.SetMaintenance("missing", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error for an unknown endpoint, got %v", err)
+	}
+	endpoints, _ = client.Endpoints()
+	for _, ep := range endpoints {
+		if ep.Name == "backup" && ep.Priority != 5 {
+			t.Errorf("Expected backup priority 5, got %d", ep.Priority)
+		}
+		if ep.Name == "primary" && !ep.Maintenance {
+			t.Error("Expected primary to be in maintenance mode")
+		}
+	}
+
+	// Group cooldowns can be cleared early
+	server.endpointManager.GetGroupManager().SetGroupCooldown("main")
+	groups, err := client.Groups()
+	if err != nil {
+		t.Fatalf("Listing groups failed: %v", err)
+	}
+	if len(groups) != 2 || groups[0].Name != "main" || groups[0].CooldownRemaining == "" {
+		t.Fatalf("Expected group main in cooldown, got %+v", groups)
+	}
+	if err := client.ClearGroupCooldown("main"); err != nil {
+		t.Fatalf("Clearing cooldown failed: %v", err)
+	}
+	if server.endpointManager.GetGroupManager().IsGroupInCooldown("main") {
+		t.Error("Expected the cooldown of group main to be cleared")
+	}
+	if err := client.ClearGroupCooldown("missing"); err == nil {
+		t.Error("Expected an error for an unknown group")
+	}
+
+	// Reload picks up the edited config file right away
+	writeAdminTestConfig(t, configPath, socketPath, 9090)
+	if err := client.Reload(); err != nil {
+		t.Fatalf("Reloading failed: %v", err)
+	}
+	if port := server.configWatcher.GetConfig().Server.Port; port != 9090 {
+		t.Errorf("Expected the reloaded port 9090, got %d", port)
+	}
+	if err := client.SwitchConfig("missing"); err == nil {
+		t.Error("Expected switching to an unknown config to fail")
+	}
+
+	server.monitoringMiddleware.RecordRequest("primary", "", "127.0.0.1", "test", "POST", "/v1/messages")
+	metrics, err := client.Metrics()
+	if err != nil {
+		t.Fatalf("Fetching metrics failed: %v", err)
+	}
+	if metrics.TotalRequests != 1 || metrics.ActiveConnections != 1 {
+		t.Errorf("Unexpected metrics: %+v", metrics)
+	}
+
+	// Stopping removes the socket, and the client reports that nothing is listening
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stopping failed: %v", err)
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket file to be removed, got %v", err)
+	}
+	if _, err := client.Endpoints(); err == nil || !strings.Contains(err.Error(), "no forwarder is listening") {
+		t.Errorf("Expected a not-listening error, got %v", err)
+	}
+}
+
+func TestAdminSocketReplacesStaleSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "ef-admin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "admin.sock")
+
+	// A socket file left behind by a crashed process
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listenUnix(socketPath)
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced, got %v", err)
+	}
+
+	// A socket that is still served is left alone
+	if _, err := listenUnix(socketPath); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Expected an in-use error, got %v", err)
+	}
+	listener.Close()
+
+	if err := os.WriteFile(socketPath, []byte("not a socket"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := listenUnix(socketPath); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Expected a not-a-socket error, got %v", err)
+	}
+}
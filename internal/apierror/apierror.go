@@ -0,0 +1,98 @@
+// Package apierror writes the errors the forwarder generates itself. They use Anthropic's
+// error envelope so clients can parse them like upstream errors, and carry the
+// X-Forwarder-Error header and a forwarder_* error type so they can be told apart.
+package apierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Response headers that mark where an error came from
+const (
+	// HeaderError is set to "true" on errors generated by the forwarder
+	HeaderError = "X-Forwarder-Error"
+	// HeaderUpstream names the endpoint a proxied response came from
+	HeaderUpstream = "X-Forwarder-Upstream"
+)
+
+// Error types of forwarder-generated errors
+const (
+	TypeNotConfigured        = "forwarder_not_configured"
+	TypeNoHealthyEndpoints   = "forwarder_no_healthy_endpoints"
+	TypeAllEndpointsFailed   = "forwarder_all_endpoints_failed"
+	TypeEndpointsSaturated   = "forwarder_endpoints_saturated"
+	TypeRateLimited          = "forwarder_rate_limited"
+	TypeOverloaded           = "forwarder_overloaded"
+	TypeFailoverDisabled     = "forwarder_failover_disabled"
+	TypeRequestDenied        = "forwarder_request_denied"
+	TypeOverrideNotAllowed   = "forwarder_override_not_allowed"
+	TypeOverrideNotFound     = "forwarder_override_not_found"
+	TypeOverrideInvalid      = "forwarder_override_invalid"
+	TypeAuthenticationFailed = "forwarder_authentication_failed"
+	TypeOriginNotAllowed     = "forwarder_origin_not_allowed"
+	TypeInvalidRequest       = "forwarder_invalid_request"
+	TypeRequestTooLarge      = "forwarder_request_too_large"
+	TypeUpstreamUnreadable   = "forwarder_upstream_unreadable"
+	TypeStreamingUnsupported = "forwarder_streaming_unsupported"
+	TypeRetryBudgetExhausted = "forwarder_retry_budget_exhausted"
+	TypeModelUnsupported     = "forwarder_model_unsupported"
+	TypeShuttingDown         = "forwarder_shutting_down"
+	TypeShed                 = "forwarder_shed"
+	TypeStreamLost           = "forwarder_stream_lost"
+)
+
+// Envelope is Anthropic's error response shape
+type Envelope struct {
+	Type  string `json:"type"`
+	Error Detail `json:"error"`
+}
+
+// Detail is the error object inside an Envelope
+type Detail struct {
+	Type              string `json:"type"`
+	Message           string `json:"message"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"` // Set by WriteRetryAfter
+}
+
+// Body returns the JSON error envelope for an error type and message
+func Body(errorType, message string) []byte {
+	body, _ := json.Marshal(Envelope{Type: "error", Error: Detail{Type: errorType, Message: message}})
+	return body
+}
+
+// Write responds with status and a forwarder error envelope
+func Write(w http.ResponseWriter, status int, errorType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(HeaderError, "true")
+	w.WriteHeader(status)
+	w.Write(append(Body(errorType, message), '\n'))
+}
+
+// WriteRetryAfter responds like Write, telling the client when to try again in both the
+// Retry-After header and the error's retry_after_seconds
+func WriteRetryAfter(w http.ResponseWriter, status int, errorType, message string, seconds int) {
+	body, _ := json.Marshal(Envelope{Type: "error", Error: Detail{Type: errorType, Message: message, RetryAfterSeconds: seconds}})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set(HeaderError, "true")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// WriteSSE writes a forwarder error envelope as a terminal SSE error event. If the
+// response has not started yet, the header still marks it as a forwarder error.
+func WriteSSE(w http.ResponseWriter, errorType, message string) {
+	w.Header().Set(HeaderError, "true")
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", Body(errorType, message))
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// IsLocal reports whether a response was marked as a forwarder-generated error
+func IsLocal(header http.Header) bool {
+	return header.Get(HeaderError) == "true"
+}
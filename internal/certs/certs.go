@@ -0,0 +1,189 @@
+// Package certs loads the TLS certificates of the forwarder's servers and reloads them when
+// their files change, so a renewed certificate is served without a restart
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrLoad is wrapped by the errors of certificate files that cannot be read or parsed
+var ErrLoad = errors.New("failed to load TLS files")
+
+// reloadDelay is how long file events settle before reloading, so a certificate and key
+// written one after the other are loaded together
+const reloadDelay = 200 * time.Millisecond
+
+// Reloader serves the current certificate and client CA pool of a TLS config. Handshakes
+// pick up a reloaded certificate right away; open connections keep the one they started with.
+type Reloader struct {
+	cfg  config.ListenerTLSConfig
+	name string // Server the certificate belongs to, for logs
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	caPEM     []byte
+
+	watcher   *fsnotify.Watcher
+	timer     *time.Timer
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New loads the files of cfg and returns their reloader along with the tls.Config to serve
+// them with. The files are watched until Close; if watching fails they are only loaded once.
+func New(cfg config.ListenerTLSConfig, name string) (*Reloader, *tls.Config, error) {
+	r := &Reloader{cfg: cfg, name: name, done: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     cfg.TLSMinVersion(),
+		GetCertificate: r.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if cfg.ClientCAFile != "" {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		// The CA pool is taken per handshake so a reloaded bundle applies to new connections
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			clientConfig := tlsConfig.Clone()
+			clientConfig.GetConfigForClient = nil
+			r.mu.RLock()
+			clientConfig.ClientCAs = r.clientCAs
+			r.mu.RUnlock()
+			return clientConfig, nil
+		}
+	}
+
+	if err := r.watch(); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [TLS] %s 无法监视证书文件，证书更新后需要重启: %v", name, err))
+	}
+	return r, tlsConfig, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload reads the certificate, key and client CA files again. On error the current
+// certificate stays in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("%w: certificate %s / key %s: %v", ErrLoad, r.cfg.CertFile, r.cfg.KeyFile, err)
+	}
+
+	var clientCAs *x509.CertPool
+	var caPEM []byte
+	if r.cfg.ClientCAFile != "" {
+		caPEM, err = os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("%w: client CA %s: %v", ErrLoad, r.cfg.ClientCAFile, err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("%w: client CA %s: no PEM certificates found", ErrLoad, r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	changed := r.cert != nil && (!bytes.Equal(r.cert.Certificate[0], cert.Certificate[0]) || !bytes.Equal(r.caPEM, caPEM))
+	r.cert, r.clientCAs, r.caPEM = &cert, clientCAs, caPEM
+	r.mu.Unlock()
+
+	if changed {
+		slog.Info(fmt.Sprintf("🔐 [TLS] %s 证书已重新加载: %s", r.name, r.cfg.CertFile))
+	}
+	return nil
+}
+
+// watch starts watching the directories of the files. Directories rather than files are
+// watched so certificates replaced by a rename (certbot, Kubernetes secrets) are noticed.
+func (r *Reloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]bool)
+	for _, file := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		dirs[dir] = true
+	}
+	r.watcher = watcher
+	go r.watchLoop()
+	return nil
+}
+
+// watchLoop reloads the files once events in their directories have settled. Reload only
+// logs when the certificate actually changed, so events of unrelated files are harmless.
+func (r *Reloader) watchLoop() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			r.mu.Lock()
+			if r.timer != nil {
+				r.timer.Stop()
+			}
+			r.timer = time.AfterFunc(reloadDelay, func() {
+				if err := r.Reload(); err != nil {
+					slog.Warn(fmt.Sprintf("⚠️ [TLS] %s 证书重新加载失败，继续使用当前证书: %v", r.name, err))
+				}
+			})
+			r.mu.Unlock()
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn(fmt.Sprintf("⚠️ [TLS] %s 证书文件监视出错: %v", r.name, err))
+		}
+	}
+}
+
+// Close stops watching the files
+func (r *Reloader) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		if r.watcher != nil {
+			r.watcher.Close()
+		}
+		r.mu.Lock()
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		r.mu.Unlock()
+	})
+}
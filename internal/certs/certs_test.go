@@ -0,0 +1,196 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key, replacing the files
+// by a rename like certbot does, and returns the certificate
+func writeCert(t *testing.T, certFile, keyFile, name string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace := func(path string, block *pem.Block) {
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replace(keyFile, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	replace(certFile, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// serveTLS serves an empty 200 response over HTTPS with tlsConfig and returns its URL
+func serveTLS(t *testing.T, tlsConfig *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: tlsConfig,
+	}
+	go server.ServeTLS(ln, "", "")
+	t.Cleanup(func() { server.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+// client returns an HTTPS client trusting roots and presenting certificates
+func client(roots []*x509.Certificate, certificates ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		pool.AddCert(root)
+	}
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certificates},
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.ListenerTLSConfig{Enabled: true, CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")}
+	first := writeCert(t, cfg.CertFile, cfg.KeyFile, "first")
+
+	reloader, tlsConfig, err := New(cfg, "test")
+	if err != nil {
+		t.Fatalf("Expected the certificate to load, got %v", err)
+	}
+	defer reloader.Close()
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 as the default minimum version, got %x", tlsConfig.MinVersion)
+	}
+	url := serveTLS(t, tlsConfig)
+
+	resp, err := client([]*x509.Certificate{first}).Get(url)
+	if err != nil {
+		t.Fatalf("Expected an HTTPS request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+
+	// Replacing the files is picked up without restarting the server
+	second := writeCert(t, cfg.CertFile, cfg.KeyFile, "second")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client([]*x509.Certificate{second}).Get(url)
+		if err == nil {
+			if name := resp.TLS.PeerCertificates[0].Subject.CommonName; name != "second" {
+				t.Errorf("Expected the new certificate, got %q", name)
+			}
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the replaced certificate to be served, got %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A broken replacement keeps the current certificate
+	if err := os.WriteFile(cfg.KeyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Expected reloading a broken key to fail")
+	}
+	if cert, _ := reloader.GetCertificate(nil); cert.Leaf != nil && cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("Expected the current certificate to stay in use, got %q", cert.Leaf.Subject.CommonName)
+	}
+}
+
+func TestCertificateMissing(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.ListenerTLSConfig{Enabled: true, CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}
+	if _, _, err := New(cfg, "test"); !errors.Is(err, ErrLoad) {
+		t.Errorf("Expected ErrLoad for missing files, got %v", err)
+	}
+
+	writeCert(t, cfg.CertFile, cfg.KeyFile, "server")
+	cfg.ClientCAFile = filepath.Join(dir, "missing-ca.crt")
+	if _, _, err := New(cfg, "test"); !errors.Is(err, ErrLoad) {
+		t.Errorf("Expected ErrLoad for a missing client CA, got %v", err)
+	}
+}
+
+func TestClientCertificateRequired(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.ListenerTLSConfig{
+		Enabled:      true,
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "client.crt"),
+		MinVersion:   "1.3",
+	}
+	server := writeCert(t, cfg.CertFile, cfg.KeyFile, "server")
+	clientKeyFile := filepath.Join(dir, "client.key")
+	writeCert(t, cfg.ClientCAFile, clientKeyFile, "client")
+
+	reloader, tlsConfig, err := New(cfg, "test")
+	if err != nil {
+		t.Fatalf("Expected the certificates to load, got %v", err)
+	}
+	defer reloader.Close()
+	url := serveTLS(t, tlsConfig)
+
+	if resp, err := client([]*x509.Certificate{server}).Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected a client without a certificate to be refused")
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(cfg.ClientCAFile, clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client([]*x509.Certificate{server}, clientCert).Get(url)
+	if err != nil {
+		t.Fatalf("Expected a client with a trusted certificate to be accepted, got %v", err)
+	}
+	if resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3, got %x", resp.TLS.Version)
+	}
+	resp.Body.Close()
+}
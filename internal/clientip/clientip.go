@@ -0,0 +1,115 @@
+// Package clientip finds the address of the client behind a request. Forwarding headers are
+// only believed when the direct peer is a trusted proxy (server.trusted_proxies), so clients
+// cannot spoof their address by sending the headers themselves.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver extracts client addresses for one set of trusted proxies. A nil Resolver trusts
+// no proxy and always returns the direct peer.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New parses trusted proxy ranges: CIDRs such as "10.0.0.0/8" or single addresses such as
+// "127.0.0.1" and "::1"
+func New(cidrs []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: expected a CIDR or an IP address", cidr)
+			}
+			addr = addr.Unmap()
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", cidr, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// ClientIP returns the client address of a request. When the peer is a trusted proxy, the
+// nearest untrusted hop of X-Forwarded-For is used (the right-most address not added by a
+// trusted proxy), then X-Real-IP; otherwise, or when the headers are missing or malformed,
+// the peer address.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	peer := PeerIP(req)
+	addr, ok := parseAddr(peer)
+	if !ok || !r.isTrusted(addr) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) > 0 {
+		client := addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := parseAddr(hops[i])
+			if !ok {
+				// Anything left of a malformed hop cannot be attributed to a trusted proxy
+				break
+			}
+			client = hop
+			if !r.isTrusted(hop) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if realIP, ok := parseAddr(req.Header.Get("X-Real-IP")); ok {
+		return realIP.String()
+	}
+	return peer
+}
+
+// isTrusted reports whether addr is within a trusted proxy range
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	if r == nil {
+		return false
+	}
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerIP returns the address of the direct peer, without the port
+func PeerIP(req *http.Request) string {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// parseAddr parses an address from a forwarding header, with or without a port
+func parseAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,73 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := New([]string{"10.0.0.0/8", "192.168.1.10", "::1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{name: "no headers", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "spoofed header from untrusted peer", remoteAddr: "203.0.113.7:5000", xff: []string{"1.2.3.4"}, realIP: "5.6.7.8", want: "203.0.113.7"},
+		{name: "single trusted proxy", remoteAddr: "10.1.2.3:5000", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "trusted proxy by address", remoteAddr: "192.168.1.10:5000", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:5000", xff: []string{"198.51.100.9, 10.0.0.2, 10.0.0.3"}, want: "198.51.100.9"},
+		{name: "client spoofs a hop before the proxy", remoteAddr: "10.0.0.1:5000", xff: []string{"1.2.3.4, 198.51.100.9"}, want: "198.51.100.9"},
+		{name: "hops across repeated headers", remoteAddr: "10.0.0.1:5000", xff: []string{"1.2.3.4", "198.51.100.9, 10.0.0.2"}, want: "198.51.100.9"},
+		{name: "all hops trusted", remoteAddr: "10.0.0.1:5000", xff: []string{"10.0.0.5, 10.0.0.2"}, want: "10.0.0.5"},
+		{name: "hop with port", remoteAddr: "10.0.0.1:5000", xff: []string{"198.51.100.9:4711"}, want: "198.51.100.9"},
+		{name: "malformed hop", remoteAddr: "10.0.0.1:5000", xff: []string{"198.51.100.9, garbage, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "malformed only hop", remoteAddr: "10.0.0.1:5000", xff: []string{"unknown"}, want: "10.0.0.1"},
+		{name: "x-real-ip from trusted proxy", remoteAddr: "10.0.0.1:5000", realIP: "198.51.100.9", want: "198.51.100.9"},
+		{name: "x-forwarded-for wins over x-real-ip", remoteAddr: "10.0.0.1:5000", xff: []string{"198.51.100.9"}, realIP: "198.51.100.10", want: "198.51.100.9"},
+		{name: "malformed x-real-ip", remoteAddr: "10.0.0.1:5000", realIP: "not-an-ip", want: "10.0.0.1"},
+		{name: "ipv6 proxy", remoteAddr: "[::1]:5000", xff: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "ipv6 hop with port", remoteAddr: "[fd00::2]:5000", xff: []string{"[2001:db8::1]:4711"}, want: "2001:db8::1"},
+		{name: "ipv4-mapped peer", remoteAddr: "[::ffff:10.0.0.1]:5000", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "unix socket peer", remoteAddr: "@", xff: []string{"198.51.100.9"}, want: "@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilResolverIgnoresHeaders(t *testing.T) {
+	var resolver *Resolver
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	if got := resolver.ClientIP(req); got != "10.0.0.1" {
+		t.Errorf("Expected the peer without trusted proxies, got %q", got)
+	}
+}
+
+func TestNewRejectsInvalidRanges(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/33", "nginx", "10.0.0/8", ""} {
+		if _, err := New([]string{cidr}); err == nil {
+			t.Errorf("Expected %q to be rejected", cidr)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/logging"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// Check statuses, from best to worst
+const (
+	StatusOK       = "ok"
+	StatusDisabled = "disabled"
+	StatusWarn     = "warn"
+	StatusFail     = "fail"
+)
+
+// Check is the result of one self-diagnostic check
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// MemoryStats is the subset of runtime.MemStats worth showing to operators
+type MemoryStats struct {
+	HeapAllocBytes uint64  `json:"heapAllocBytes"`
+	HeapInuseBytes uint64  `json:"heapInuseBytes"`
+	SysBytes       uint64  `json:"sysBytes"`
+	NumGC          uint32  `json:"numGC"`
+	GCPauseTotalMs float64 `json:"gcPauseTotalMs"`
+}
+
+// Report is a full self-diagnostics report
+type Report struct {
+	Status        string           `json:"status"` // Worst status of all checks
+	GeneratedAt   time.Time        `json:"generatedAt"`
+	Build         VersionInfo      `json:"build"`
+	Checks        []Check          `json:"checks"`
+	Goroutines    int              `json:"goroutines"`
+	Memory        MemoryStats      `json:"memory"`
+	ErrorCounters map[string]int64 `json:"errorCounters"` // Errors per subsystem since startup
+}
+
+// Collector runs self-diagnostics against the running components
+type Collector struct {
+	startTime            time.Time
+	configWatcher        *config.ConfigWatcher
+	endpointManager      *endpoint.Manager
+	monitoringMiddleware *middleware.MonitoringMiddleware
+	logRotator           func() *logging.FileRotator // The logger is rebuilt on reload, so look it up each time
+}
+
+// NewCollector creates a diagnostics collector. Any component may be nil; its checks are skipped.
+func NewCollector(startTime time.Time, configWatcher *config.ConfigWatcher, endpointManager *endpoint.Manager, monitoringMiddleware *middleware.MonitoringMiddleware, logRotator func() *logging.FileRotator) *Collector {
+	return &Collector{
+		startTime:            startTime,
+		configWatcher:        configWatcher,
+		endpointManager:      endpointManager,
+		monitoringMiddleware: monitoringMiddleware,
+		logRotator:           logRotator,
+	}
+}
+
+// Collect runs all checks and gathers runtime statistics
+func (c *Collector) Collect() Report {
+	report := Report{
+		Status:        StatusOK,
+		GeneratedAt:   time.Now(),
+		Build:         Version(c.startTime),
+		Goroutines:    runtime.NumGoroutine(),
+		ErrorCounters: make(map[string]int64),
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	report.Memory = MemoryStats{
+		HeapAllocBytes: memStats.HeapAlloc,
+		HeapInuseBytes: memStats.HeapInuse,
+		SysBytes:       memStats.Sys,
+		NumGC:          memStats.NumGC,
+		GCPauseTotalMs: float64(memStats.PauseTotalNs) / float64(time.Millisecond),
+	}
+
+	if c.configWatcher != nil {
+		report.Checks = append(report.Checks, c.checkConfigWatcher(&report), c.checkRegistry())
+		report.Checks = append(report.Checks, c.checkFileLogging(&report))
+	}
+	if c.endpointManager != nil {
+		report.Checks = append(report.Checks, c.checkEndpoints())
+	}
+	if c.monitoringMiddleware != nil {
+		report.ErrorCounters["proxy"] = c.monitoringMiddleware.GetMetrics().GetMetrics().FailedRequests
+	}
+
+	for _, check := range report.Checks {
+		if severity(check.Status) > severity(report.Status) {
+			report.Status = check.Status
+		}
+	}
+	return report
+}
+
+// severity orders statuses for picking the overall report status
+func severity(status string) int {
+	switch status {
+	case StatusWarn:
+		return 1
+	case StatusFail:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// checkConfigWatcher reports whether the watch/poll loops are still running
+func (c *Collector) checkConfigWatcher(report *Report) Check {
+	status := c.configWatcher.Status()
+	report.ErrorCounters["configWatcher"] = status.ErrorCount
+
+	check := Check{Name: "config_watcher", Status: StatusOK}
+	idle := time.Since(status.LastActivity)
+	lastReload := "never"
+	if !status.LastReload.IsZero() {
+		lastReload = formatAgo(status.LastReload)
+	}
+	check.Message = fmt.Sprintf("last activity %s, last reload %s", formatAgo(status.LastActivity), lastReload)
+
+	// The polling fallback touches the watcher at least once per poll interval
+	if status.PollInterval > 0 && idle > 2*status.PollInterval+5*time.Second {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("no activity for %s (poll interval %s)", idle.Round(time.Second), status.PollInterval)
+		return check
+	}
+	if status.Degraded {
+		check.Status = StatusWarn
+		check.Message += ", file watch degraded to polling"
+	}
+	if status.LastError != "" && status.LastErrorTime.After(status.LastReload) {
+		check.Status = StatusWarn
+		check.Message += fmt.Sprintf(", last error %s: %s", formatAgo(status.LastErrorTime), status.LastError)
+	}
+	return check
+}
+
+// checkRegistry reports whether the config registry can be written
+func (c *Collector) checkRegistry() Check {
+	registryPath := c.configWatcher.RegistryPath()
+	check := Check{Name: "registry", Status: StatusOK, Message: fmt.Sprintf("%s is writable", registryPath)}
+	if err := probeWritable(registryPath); err != nil {
+		check.Status = StatusFail
+		check.Message = err.Error()
+	}
+	return check
+}
+
+// checkFileLogging reports whether the log file can be written
+func (c *Collector) checkFileLogging(report *Report) Check {
+	check := Check{Name: "file_logging", Status: StatusOK}
+	cfg := c.configWatcher.GetConfig()
+	if !cfg.Logging.FileEnabled {
+		check.Status = StatusDisabled
+		check.Message = "file logging is disabled"
+		return check
+	}
+
+	var rotator *logging.FileRotator
+	if c.logRotator != nil {
+		rotator = c.logRotator()
+	}
+	if rotator == nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("log file %s could not be opened", cfg.Logging.FilePath)
+		return check
+	}
+
+	status := rotator.Status()
+	report.ErrorCounters["fileLogging"] = status.ErrorCount
+	if err := probeWritable(status.Filename); err != nil {
+		check.Status = StatusFail
+		check.Message = err.Error()
+		return check
+	}
+	if status.LastError != "" && status.LastErrorTime.After(status.LastWrite) {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("last write failed %s: %s", formatAgo(status.LastErrorTime), status.LastError)
+		return check
+	}
+
+	lastWrite := "never"
+	if !status.LastWrite.IsZero() {
+		lastWrite = formatAgo(status.LastWrite)
+	}
+	check.Message = fmt.Sprintf("%s is writable, last write %s", status.Filename, lastWrite)
+	return check
+}
+
+// checkEndpoints summarizes endpoint health
+func (c *Collector) checkEndpoints() Check {
+	endpoints := c.endpointManager.GetAllEndpoints()
+	healthy := 0
+	for _, ep := range endpoints {
+		if ep.IsHealthy() {
+			healthy++
+		}
+	}
+
+	check := Check{Name: "endpoints", Status: StatusOK, Message: fmt.Sprintf("%d/%d healthy", healthy, len(endpoints))}
+	switch {
+	case len(endpoints) == 0:
+		check.Status = StatusWarn
+		check.Message = "no endpoints configured (setup mode)"
+	case healthy == 0:
+		check.Status = StatusFail
+	case healthy < len(endpoints):
+		check.Status = StatusWarn
+	}
+	return check
+}
+
+// probeWritable checks that a file can be written without modifying it: an existing file
+// is opened for appending, a missing one is checked by creating a temp file beside it
+func probeWritable(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %w", path, err)
+		}
+		return file.Close()
+	}
+
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, ".diagnostics-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// formatAgo formats a past time relative to now
+func formatAgo(t time.Time) string {
+	return fmt.Sprintf("%s ago", time.Since(t).Round(time.Second))
+}
@@ -0,0 +1,129 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/clientip"
+	"endpoint_forwarder/internal/logging"
+)
+
+func TestVersionHandler(t *testing.T) {
+	SetBuildInfo("1.2.3", "abc123", "2024-01-01T00:00:00Z")
+	defer SetBuildInfo("dev", "unknown", "unknown")
+
+	handler := VersionHandler(time.Now().Add(-time.Hour), clientip.PeerIP)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/api/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var info VersionInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if info.Version != "1.2.3" || info.Commit != "abc123" || info.Date != "2024-01-01T00:00:00Z" {
+		t.Errorf("Unexpected build info: %+v", info)
+	}
+	if info.GoVersion == "" || info.UptimeSeconds < 3600 {
+		t.Errorf("Expected Go version and uptime, got %+v", info)
+	}
+}
+
+func TestVersionHandlerRateLimit(t *testing.T) {
+	handler := VersionHandler(time.Now(), clientip.PeerIP)
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/version", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	for i := 0; i < versionRateLimit; i++ {
+		if rec := request(fmt.Sprintf("10.0.0.1:%d", 1000+i)); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+	rec := request("10.0.0.1:9999")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After over the limit, got %d", rec.Code)
+	}
+	if rec := request("10.0.0.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("Expected other clients to be unaffected, got %d", rec.Code)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "logs", "forwarder.log")
+	configPath := filepath.Join(dir, "config.yaml")
+	content := fmt.Sprintf(`
+logging:
+  file_enabled: true
+  file_path: %q
+endpoints:
+  - name: "primary"
+    url: "https://api.example.com"
+    priority: 1
+`, logPath)
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	watcher, err := config.NewConfigWatcher(configPath, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	rotator, err := logging.NewFileRotator(logPath, logging.RotatorOptions{MaxSize: 1024 * 1024, MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("failed to create rotator: %v", err)
+	}
+	defer rotator.Close()
+	rotator.Write([]byte("hello\n"))
+
+	collector := NewCollector(time.Now(), watcher, nil, nil, func() *logging.FileRotator { return rotator })
+	report := collector.Collect()
+
+	checks := make(map[string]Check)
+	for _, check := range report.Checks {
+		checks[check.Name] = check
+	}
+	for _, name := range []string{"config_watcher", "registry", "file_logging"} {
+		if checks[name].Status != StatusOK {
+			t.Errorf("Expected %s to be ok, got %+v", name, checks[name])
+		}
+	}
+	if report.Status != StatusOK {
+		t.Errorf("Expected overall ok, got %s", report.Status)
+	}
+	if report.Goroutines == 0 || report.Memory.SysBytes == 0 {
+		t.Errorf("Expected runtime stats, got %d goroutines, %+v", report.Goroutines, report.Memory)
+	}
+	if _, ok := report.ErrorCounters["configWatcher"]; !ok {
+		t.Errorf("Expected config watcher error counter, got %v", report.ErrorCounters)
+	}
+
+	// A rotator that failed to open is reported as a failure
+	collector = NewCollector(time.Now(), watcher, nil, nil, func() *logging.FileRotator { return nil })
+	report = collector.Collect()
+	for _, check := range report.Checks {
+		if check.Name == "file_logging" && check.Status != StatusFail {
+			t.Errorf("Expected file_logging to fail without a rotator, got %+v", check)
+		}
+	}
+	if report.Status != StatusFail {
+		t.Errorf("Expected overall fail, got %s", report.Status)
+	}
+}
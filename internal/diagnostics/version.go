@@ -0,0 +1,109 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// versionRateLimit is how many /api/version requests one client IP may make per minute
+const versionRateLimit = 30
+
+// Build-time information, set once from main's ldflags variables
+var (
+	buildVersion = "dev"
+	buildCommit  = "unknown"
+	buildDate    = "unknown"
+)
+
+// SetBuildInfo records the version, commit and build date injected via ldflags
+func SetBuildInfo(version, commit, date string) {
+	buildVersion = version
+	buildCommit = commit
+	buildDate = date
+}
+
+// VersionInfo identifies the running build
+type VersionInfo struct {
+	Version       string    `json:"version"`
+	Commit        string    `json:"commit"`
+	Date          string    `json:"date"`
+	GoVersion     string    `json:"go_version"`
+	StartTime     time.Time `json:"start_time"`
+	Uptime        string    `json:"uptime"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// Version returns the build information and uptime
+func Version(startTime time.Time) VersionInfo {
+	uptime := time.Since(startTime)
+	return VersionInfo{
+		Version:       buildVersion,
+		Commit:        buildCommit,
+		Date:          buildDate,
+		GoVersion:     runtime.Version(),
+		StartTime:     startTime,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+	}
+}
+
+// VersionHandler serves GET /api/version. It requires no authentication, so each client IP,
+// as returned by clientIP, is limited to versionRateLimit requests per minute.
+func VersionHandler(startTime time.Time, clientIP func(*http.Request) string) http.HandlerFunc {
+	limiter := newRateLimiter(versionRateLimit, time.Minute)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if ok, retryAfter := limiter.Allow(clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Version(startTime))
+	}
+}
+
+// rateLimiter is a fixed-window request counter per key. All keys share one window, so
+// the map is reset at most once per window and never grows without bound.
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]int),
+	}
+}
+
+// Allow counts a request for key and reports whether it is within the limit; otherwise it
+// returns how long until the window resets
+func (rl *rateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.windowStart) >= rl.window {
+		rl.windowStart = now
+		rl.counts = make(map[string]int)
+	}
+	if rl.counts[key] >= rl.limit {
+		return false, rl.windowStart.Add(rl.window).Sub(now)
+	}
+	rl.counts[key]++
+	return true, 0
+}
@@ -0,0 +1,153 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerState returns the endpoint's circuit breaker state at now. An open breaker turns
+// half-open once its open_duration has passed, and stays so until a probe request succeeds.
+func (s EndpointStatus) BreakerState(now time.Time) string {
+	switch {
+	case s.BreakerOpenUntil.IsZero():
+		return BreakerClosed
+	case now.Before(s.BreakerOpenUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// breakerBlocks reports whether the circuit breaker keeps the endpoint out of selection:
+// while open, and while half-open with all probe slots taken
+func (s EndpointStatus) breakerBlocks(now time.Time) bool {
+	switch s.BreakerState(now) {
+	case BreakerOpen:
+		return true
+	case BreakerHalfOpen:
+		return s.breakerProbes >= s.breakerProbeLimit
+	}
+	return false
+}
+
+// BreakerInfo returns the endpoint's circuit breaker state, the time left until an open
+// breaker turns half-open, and the consecutive failures counted so far
+func (e *Endpoint) BreakerInfo() (state string, remaining time.Duration, failures int) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	now := time.Now()
+	state = e.Status.BreakerState(now)
+	if state == BreakerOpen {
+		remaining = e.Status.BreakerOpenUntil.Sub(now)
+	}
+	return state, remaining, e.Status.BreakerFailures
+}
+
+// acquireBreakerProbe admits a request through a half-open breaker, up to
+// half_open_max_requests at a time. The returned release frees the probe slot; ok is false
+// when all slots are taken.
+func (e *Endpoint) acquireBreakerProbe() (release func(), ok bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.Status.BreakerState(time.Now()) != BreakerHalfOpen {
+		return func() {}, true
+	}
+	if e.Status.breakerProbes >= e.Status.breakerProbeLimit {
+		return nil, false
+	}
+	e.Status.breakerProbes++
+	return func() {
+		e.mutex.Lock()
+		e.Status.breakerProbes--
+		e.mutex.Unlock()
+	}, true
+}
+
+// recordBreakerOutcome feeds a request outcome into the endpoint's circuit breaker. Enough
+// consecutive failures open it; while half-open, a success closes it and a failure opens it
+// again. When the last breaker of a group opens, the group enters cooldown.
+func (m *Manager) recordBreakerOutcome(endpoint *Endpoint, failed bool) {
+	cfg := m.GetConfig().CircuitBreaker
+	if !cfg.Enabled {
+		return
+	}
+
+	endpoint.mutex.Lock()
+	now := time.Now()
+	state := endpoint.Status.BreakerState(now)
+	opened, closed := false, false
+	switch {
+	case state == BreakerOpen:
+		// Requests sent before the breaker opened; they do not change it
+	case !failed:
+		endpoint.Status.BreakerFailures = 0
+		if state == BreakerHalfOpen {
+			endpoint.Status.BreakerOpenUntil = time.Time{}
+			closed = true
+		}
+	default:
+		endpoint.Status.BreakerFailures++
+		if state == BreakerHalfOpen || endpoint.Status.BreakerFailures >= cfg.FailureThreshold {
+			endpoint.Status.BreakerOpenUntil = now.Add(cfg.OpenDuration)
+			endpoint.Status.breakerProbeLimit = cfg.HalfOpenMaxRequests
+			opened = true
+		}
+	}
+	failures := endpoint.Status.BreakerFailures
+	endpoint.mutex.Unlock()
+
+	if !opened && !closed {
+		return
+	}
+	m.statusGeneration.Add(1)
+
+	name := endpoint.Config.Name
+	if closed {
+		slog.Info(fmt.Sprintf("🔌 [熔断器] 端点 %s 探测请求成功，熔断器关闭", name))
+		return
+	}
+	if state == BreakerHalfOpen {
+		slog.Warn(fmt.Sprintf("🔌 [熔断器] 端点 %s 探测请求失败，熔断器重新打开 %s", name, cfg.OpenDuration))
+	} else {
+		slog.Warn(fmt.Sprintf("🔌 [熔断器] 端点 %s 连续失败 %d 次，熔断器打开 %s，期间不参与选择", name, failures, cfg.OpenDuration))
+	}
+	m.cooldownGroupIfAllOpen(endpoint, now)
+}
+
+// cooldownGroupIfAllOpen puts the endpoint's group into cooldown when the breakers of all its
+// endpoints (not in maintenance) are open, as if its requests had exhausted the group's retries
+func (m *Manager) cooldownGroupIfAllOpen(endpoint *Endpoint, now time.Time) {
+	groupName := endpoint.Config.Group
+	if groupName == "" {
+		groupName = "Default"
+	}
+	for _, ep := range m.GetAllEndpoints() {
+		name := ep.Config.Group
+		if name == "" {
+			name = "Default"
+		}
+		if name != groupName {
+			continue
+		}
+		status := ep.GetStatus()
+		if !status.Disabled && status.BreakerState(now) != BreakerOpen {
+			return
+		}
+	}
+	if m.groupManager.IsGroupInCooldown(groupName) {
+		return
+	}
+
+	slog.Error(fmt.Sprintf("🔌 [熔断器] 组 %s 中所有端点的熔断器均已打开，组进入冷却状态", groupName))
+	m.groupManager.SetGroupCooldown(groupName)
+}
@@ -0,0 +1,126 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func newBreakerTestManager(enabled bool) *Manager {
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "primary", URL: "https://primary.example.com", Priority: 1, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "secondary", URL: "https://secondary.example.com", Priority: 2, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "backup", URL: "https://backup.example.com", Priority: 3, Group: "backup", GroupPriority: 2},
+	)
+	cfg.CircuitBreaker = config.CircuitBreakerConfig{Enabled: enabled, FailureThreshold: 3, OpenDuration: 100 * time.Millisecond, HalfOpenMaxRequests: 1}
+	return NewManager(cfg)
+}
+
+func healthyNames(m *Manager) []string {
+	var names []string
+	for _, ep := range m.GetHealthyEndpoints() {
+		names = append(names, ep.Config.Name)
+	}
+	return names
+}
+
+func TestCircuitBreakerLifecycle(t *testing.T) {
+	manager := newBreakerTestManager(true)
+	primary := manager.GetEndpointByNameAny("primary")
+
+	// Failures below the threshold, interrupted by a success, keep the breaker closed
+	manager.RecordRequestOutcome("primary", http.StatusInternalServerError, nil)
+	manager.RecordRequestOutcome("primary", 0, errors.New("connection refused"))
+	manager.RecordRequestOutcome("primary", http.StatusOK, nil)
+	manager.RecordRequestOutcome("primary", http.StatusBadRequest, nil) // Client errors are not failures
+	manager.RecordRequestOutcome("primary", http.StatusBadGateway, nil)
+	if state, _, failures := primary.BreakerInfo(); state != BreakerClosed || failures != 1 {
+		t.Fatalf("Expected a closed breaker with 1 failure, got %s with %d", state, failures)
+	}
+
+	for i := 0; i < 2; i++ {
+		manager.RecordRequestOutcome("primary", http.StatusServiceUnavailable, nil)
+	}
+	if state, remaining, _ := primary.BreakerInfo(); state != BreakerOpen || remaining <= 0 {
+		t.Fatalf("Expected the breaker to open after 3 consecutive failures, got %s (%v)", state, remaining)
+	}
+	if names := healthyNames(manager); len(names) != 1 || names[0] != "secondary" {
+		t.Fatalf("Expected the open endpoint to be excluded, got %v", names)
+	}
+	if !primary.IsHealthy() {
+		t.Error("Expected the breaker to leave the health status alone")
+	}
+
+	// Once open_duration has passed, one probe at a time is let through
+	time.Sleep(120 * time.Millisecond)
+	if state, _, _ := primary.BreakerInfo(); state != BreakerHalfOpen {
+		t.Fatalf("Expected the breaker to be half-open, got %s", state)
+	}
+	release, ok := primary.TryAcquire()
+	if !ok {
+		t.Fatal("Expected a probe request to be admitted")
+	}
+	if _, ok := primary.TryAcquire(); ok {
+		t.Error("Expected a second concurrent probe to be refused")
+	}
+	if names := healthyNames(manager); len(names) != 1 {
+		t.Errorf("Expected the endpoint to be skipped while its probe is in flight, got %v", names)
+	}
+
+	// A failed probe opens the breaker again
+	manager.RecordRequestOutcome("primary", http.StatusInternalServerError, nil)
+	release()
+	if state, _, _ := primary.BreakerInfo(); state != BreakerOpen {
+		t.Fatalf("Expected a failed probe to reopen the breaker, got %s", state)
+	}
+
+	// A successful probe closes it
+	time.Sleep(120 * time.Millisecond)
+	release, ok = primary.TryAcquire()
+	if !ok {
+		t.Fatal("Expected a probe request to be admitted")
+	}
+	manager.RecordRequestOutcome("primary", http.StatusOK, nil)
+	release()
+	if state, _, failures := primary.BreakerInfo(); state != BreakerClosed || failures != 0 {
+		t.Fatalf("Expected a successful probe to close the breaker, got %s with %d failures", state, failures)
+	}
+	if names := healthyNames(manager); len(names) != 2 || names[0] != "primary" {
+		t.Errorf("Expected both endpoints back in selection, got %v", names)
+	}
+}
+
+func TestCircuitBreakerOpensGroupCooldown(t *testing.T) {
+	manager := newBreakerTestManager(true)
+
+	for i := 0; i < 3; i++ {
+		manager.RecordRequestOutcome("primary", http.StatusInternalServerError, nil)
+	}
+	if manager.GetGroupManager().IsGroupInCooldown("main") {
+		t.Fatal("Expected the group to stay active while one endpoint is closed")
+	}
+
+	for i := 0; i < 3; i++ {
+		manager.RecordRequestOutcome("secondary", 0, errors.New("connection reset"))
+	}
+	if !manager.GetGroupManager().IsGroupInCooldown("main") {
+		t.Fatal("Expected the group to enter cooldown once all its breakers are open")
+	}
+	if names := healthyNames(manager); len(names) != 1 || names[0] != "backup" {
+		t.Errorf("Expected selection to move to the backup group, got %v", names)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	manager := newBreakerTestManager(false)
+
+	for i := 0; i < 5; i++ {
+		manager.RecordRequestOutcome("primary", http.StatusInternalServerError, nil)
+	}
+	if state, _, _ := manager.GetEndpointByNameAny("primary").BreakerInfo(); state != BreakerClosed {
+		t.Errorf("Expected no breaker when disabled, got %s", state)
+	}
+}
@@ -0,0 +1,270 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/notify"
+)
+
+// budgetWarnRatio is the share of a token budget whose use raises the first alert
+const budgetWarnRatio = 0.8
+
+// Alert levels of a token budget within its window
+const (
+	budgetLevelNone = iota
+	budgetLevelWarning
+	budgetLevelExceeded
+)
+
+// tokenBudget counts the tokens used against one budget entry in its current window. The
+// window starts with the first tokens recorded and a new one with the first tokens after it.
+type tokenBudget struct {
+	mutex       sync.Mutex
+	windowStart time.Time
+	used        int64
+	level       int // Highest alert raised in this window
+}
+
+// rollWindow starts a new window once the current one has passed (caller holds the mutex)
+func (b *tokenBudget) rollWindow(window time.Duration, now time.Time) {
+	if !b.windowStart.IsZero() && now.Sub(b.windowStart) >= window {
+		b.windowStart = time.Time{}
+		b.used = 0
+		b.level = budgetLevelNone
+	}
+}
+
+// add records used tokens and returns the alert level they crossed into, budgetLevelNone when
+// the level did not change
+func (b *tokenBudget) add(tokens int64, cfg config.BudgetConfig, now time.Time) (used int64, crossed int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.rollWindow(cfg.Window, now)
+	if b.windowStart.IsZero() {
+		b.windowStart = now
+	}
+	b.used += tokens
+
+	level := budgetLevelNone
+	switch {
+	case b.used >= cfg.Limit:
+		level = budgetLevelExceeded
+	case float64(b.used) >= float64(cfg.Limit)*budgetWarnRatio:
+		level = budgetLevelWarning
+	}
+	if level <= b.level {
+		return b.used, budgetLevelNone
+	}
+	b.level = level
+	return b.used, level
+}
+
+// usage returns the tokens used in the current window and when it resets (0 before any use)
+func (b *tokenBudget) usage(window time.Duration, now time.Time) (used int64, resetIn time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.rollWindow(window, now)
+	if b.windowStart.IsZero() {
+		return 0, 0
+	}
+	return b.used, b.windowStart.Add(window).Sub(now)
+}
+
+// BudgetStatus is a token budget's consumption in its current window
+type BudgetStatus struct {
+	Subject  string        `json:"subject"` // "endpoint <name>" or "group <name>"
+	Endpoint string        `json:"endpoint,omitempty"`
+	Group    string        `json:"group,omitempty"`
+	Action   string        `json:"action"`
+	Window   time.Duration `json:"-"`
+	Limit    int64         `json:"limit"`
+	Used     int64         `json:"used"`
+	ResetIn  time.Duration `json:"-"` // 0 until tokens are used in a new window
+	Exceeded bool          `json:"exceeded"`
+	Blocking bool          `json:"blocking"` // Exceeded with action block: out of selection until the window resets
+}
+
+// Percent returns the share of the budget used, above 100 once it is exceeded
+func (s BudgetStatus) Percent() float64 {
+	return float64(s.Used) / float64(s.Limit) * 100
+}
+
+// budgetKey identifies a budget entry's counter; a subject may have budgets over several windows
+func budgetKey(cfg config.BudgetConfig) string {
+	return fmt.Sprintf("%s/%s", cfg.Subject(), cfg.Window)
+}
+
+// tokenBudgetFor returns the counter of a budget entry. Like rate limit buckets, counters
+// outlive config reloads so a reload does not reset a budget.
+func (m *Manager) tokenBudgetFor(cfg config.BudgetConfig) *tokenBudget {
+	m.budgetMutex.Lock()
+	defer m.budgetMutex.Unlock()
+
+	if m.budgets == nil {
+		m.budgets = make(map[string]*tokenBudget)
+	}
+	key := budgetKey(cfg)
+	budget, exists := m.budgets[key]
+	if !exists {
+		budget = &tokenBudget{}
+		m.budgets[key] = budget
+	}
+	return budget
+}
+
+// pruneTokenBudgets drops the counters of budget entries that are no longer configured
+func (m *Manager) pruneTokenBudgets(cfg *config.Config) {
+	m.budgetMutex.Lock()
+	defer m.budgetMutex.Unlock()
+
+	current := make(map[string]bool, len(cfg.Budgets))
+	for _, budget := range cfg.Budgets {
+		current[budgetKey(budget)] = true
+	}
+	for key := range m.budgets {
+		if !current[key] {
+			delete(m.budgets, key)
+		}
+	}
+}
+
+// RecordTokenUsage counts the input and output tokens of a response from an endpoint toward
+// the budgets covering it. Crossing 80% and 100% of a budget is logged and published once
+// per window; a group whose block budget is used up steps aside until the window resets.
+func (m *Manager) RecordTokenUsage(endpointName string, tokens int64) {
+	budgets := m.GetConfig().Budgets
+	if len(budgets) == 0 || tokens <= 0 {
+		return
+	}
+	ep := m.GetEndpointByNameAny(endpointName)
+	if ep == nil {
+		return
+	}
+	group := groupOf(ep)
+
+	now := time.Now()
+	for _, cfg := range budgets {
+		if !cfg.Covers(endpointName, group) {
+			continue
+		}
+		used, crossed := m.tokenBudgetFor(cfg).add(tokens, cfg, now)
+		if crossed == budgetLevelNone {
+			continue
+		}
+
+		status := m.budgetStatus(cfg, now)
+		details := map[string]string{
+			"used":   strconv.FormatInt(used, 10),
+			"limit":  strconv.FormatInt(cfg.Limit, 10),
+			"window": cfg.Window.String(),
+			"action": cfg.Action,
+		}
+		if crossed == budgetLevelWarning {
+			slog.Warn(fmt.Sprintf("💰 [令牌预算] %s 已使用 %.0f%% 的令牌预算 (%d / %d, 窗口 %v)",
+				cfg.Subject(), status.Percent(), used, cfg.Limit, cfg.Window))
+			m.publish.Publish(notify.Event{
+				Type:    config.NotifyEventBudgetWarning,
+				Subject: cfg.Subject(),
+				Message: fmt.Sprintf("%s has used %.0f%% of its token budget (%d of %d tokens per %s)",
+					cfg.Subject(), status.Percent(), used, cfg.Limit, cfg.Window),
+				Details: details,
+			})
+			continue
+		}
+
+		slog.Warn(fmt.Sprintf("💸 [令牌预算] %s 的令牌预算已用完 (%d / %d, 窗口 %v, 动作: %s, %v 后重置)",
+			cfg.Subject(), used, cfg.Limit, cfg.Window, cfg.Action, status.ResetIn.Round(time.Second)))
+		message := fmt.Sprintf("%s has used up its token budget (%d of %d tokens per %s)", cfg.Subject(), used, cfg.Limit, cfg.Window)
+		if cfg.Action == config.BudgetActionBlock {
+			message += fmt.Sprintf(" and is not selected until the window resets in %s", status.ResetIn.Round(time.Second))
+		}
+		m.publish.Publish(notify.Event{
+			Type:    config.NotifyEventBudgetExceeded,
+			Subject: cfg.Subject(),
+			Message: message,
+			Details: details,
+		})
+		if cfg.Action == config.BudgetActionBlock && cfg.Group != "" {
+			m.groupManager.SetGroupBudgetExhausted(cfg.Group, now.Add(status.ResetIn))
+		}
+	}
+}
+
+// budgetStatus returns the consumption of a budget entry
+func (m *Manager) budgetStatus(cfg config.BudgetConfig, now time.Time) BudgetStatus {
+	used, resetIn := m.tokenBudgetFor(cfg).usage(cfg.Window, now)
+	status := BudgetStatus{
+		Subject:  cfg.Subject(),
+		Endpoint: cfg.Endpoint,
+		Group:    cfg.Group,
+		Action:   cfg.Action,
+		Window:   cfg.Window,
+		Limit:    cfg.Limit,
+		Used:     used,
+		ResetIn:  resetIn,
+		Exceeded: used >= cfg.Limit,
+	}
+	status.Blocking = status.Exceeded && cfg.Action == config.BudgetActionBlock
+	return status
+}
+
+// BudgetStatuses returns the consumption of every configured token budget, in config order
+func (m *Manager) BudgetStatuses() []BudgetStatus {
+	now := time.Now()
+	budgets := m.GetConfig().Budgets
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, cfg := range budgets {
+		statuses = append(statuses, m.budgetStatus(cfg, now))
+	}
+	return statuses
+}
+
+// budgetBlockReason returns why a used-up block budget keeps the endpoint out of selection,
+// or "" when none does
+func (m *Manager) budgetBlockReason(ep *Endpoint) string {
+	budgets := m.GetConfig().Budgets
+	if len(budgets) == 0 {
+		return ""
+	}
+	now := time.Now()
+	group := groupOf(ep)
+	for _, cfg := range budgets {
+		if cfg.Action != config.BudgetActionBlock || !cfg.Covers(ep.Config.Name, group) {
+			continue
+		}
+		if status := m.budgetStatus(cfg, now); status.Exceeded {
+			return fmt.Sprintf("token budget of %s used up (%d of %d tokens, resets in %s)",
+				cfg.Subject(), status.Used, cfg.Limit, status.ResetIn.Round(time.Second))
+		}
+	}
+	return ""
+}
+
+// applyBudgetCooldowns puts groups whose block budget is used up back into cooldown after a
+// reload reset the group states
+func (m *Manager) applyBudgetCooldowns() {
+	now := time.Now()
+	for _, cfg := range m.GetConfig().Budgets {
+		if cfg.Action != config.BudgetActionBlock || cfg.Group == "" {
+			continue
+		}
+		if status := m.budgetStatus(cfg, now); status.Exceeded {
+			m.groupManager.SetGroupBudgetExhausted(cfg.Group, now.Add(status.ResetIn))
+		}
+	}
+}
+
+// groupOf returns the endpoint's group name, "Default" when none is configured
+func groupOf(ep *Endpoint) string {
+	if ep.Config.Group == "" {
+		return "Default"
+	}
+	return ep.Config.Group
+}
@@ -0,0 +1,79 @@
+package endpoint
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/notify"
+)
+
+func newBudgetTestConfig(budgets ...config.BudgetConfig) *config.Config {
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "primary", URL: "https://primary.example.com", Priority: 1, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "backup", URL: "https://backup.example.com", Priority: 1, Group: "backup", GroupPriority: 2},
+	)
+	cfg.Budgets = budgets
+	return cfg
+}
+
+func TestBudgetAlerts(t *testing.T) {
+	manager := NewManager(newBudgetTestConfig(config.BudgetConfig{Endpoint: "primary", Window: time.Hour, Limit: 100, Action: config.BudgetActionWarn}))
+	var events []notify.Event
+	manager.SetEventPublisher(func(e notify.Event) { events = append(events, e) })
+
+	manager.RecordTokenUsage("primary", 50)
+	manager.RecordTokenUsage("backup", 500)
+	if len(events) != 0 {
+		t.Fatalf("Expected no alert below 80%%, got %+v", events)
+	}
+	manager.RecordTokenUsage("primary", 35)
+	manager.RecordTokenUsage("primary", 5)
+	manager.RecordTokenUsage("primary", 20)
+	manager.RecordTokenUsage("primary", 20)
+	if len(events) != 2 || events[0].Type != config.NotifyEventBudgetWarning || events[1].Type != config.NotifyEventBudgetExceeded {
+		t.Fatalf("Expected one warning and one exceeded event, got %+v", events)
+	}
+
+	status := manager.BudgetStatuses()[0]
+	if status.Used != 130 || !status.Exceeded || status.Blocking || status.Percent() != 130 {
+		t.Errorf("Expected 130%% of a warn budget used, got %+v", status)
+	}
+	if healthy := manager.GetHealthyEndpointsInGroup("main"); len(healthy) != 1 {
+		t.Errorf("Expected a warn budget to leave the endpoint selectable, got %d endpoints", len(healthy))
+	}
+}
+
+func TestBudgetBlockExcludesGroup(t *testing.T) {
+	cfg := newBudgetTestConfig(config.BudgetConfig{Group: "main", Window: 150 * time.Millisecond, Limit: 10, Action: config.BudgetActionBlock})
+	manager := NewManager(cfg)
+
+	manager.RecordTokenUsage("primary", 12)
+	if !manager.GetGroupManager().IsGroupInCooldown("main") {
+		t.Fatal("Expected the group over its block budget to step aside")
+	}
+	healthy := manager.GetHealthyEndpoints()
+	if len(healthy) != 1 || healthy[0].Config.Name != "backup" {
+		t.Fatalf("Expected traffic to move to backup, got %d endpoints", len(healthy))
+	}
+	trace := &SelectionTrace{}
+	if inGroup := manager.SelectHealthyInGroup("main", trace); len(inGroup) != 0 ||
+		!strings.Contains(trace.Excluded[len(trace.Excluded)-1].Reason, "token budget of group main used up") {
+		t.Errorf("Expected primary to be excluded for its group's budget, got %+v", trace.Excluded)
+	}
+
+	// A reload keeps the used budget and the group aside
+	manager.UpdateConfig(newBudgetTestConfig(config.BudgetConfig{Group: "main", Window: 150 * time.Millisecond, Limit: 10, Action: config.BudgetActionBlock}))
+	if !manager.GetGroupManager().IsGroupInCooldown("main") || len(manager.GetHealthyEndpointsInGroup("main")) != 0 {
+		t.Error("Expected the used budget to survive the reload")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if inGroup := manager.GetHealthyEndpointsInGroup("main"); len(inGroup) != 1 {
+		t.Errorf("Expected primary back once the window rolled over, got %d endpoints", len(inGroup))
+	}
+	if status := manager.BudgetStatuses()[0]; status.Used != 0 || status.Exceeded {
+		t.Errorf("Expected a fresh window, got %+v", status)
+	}
+}
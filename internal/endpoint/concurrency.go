@@ -0,0 +1,171 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrEndpointSaturated is returned when an endpoint is at max_concurrent_requests (or its
+// half-open circuit breaker has no probe slot left) and no slot freed up within max_queue_wait
+var ErrEndpointSaturated = errors.New("endpoint at its concurrency limit")
+
+// slotCounter counts the requests in flight to an endpoint. Requests queued for a slot wait
+// on freed, which is closed and replaced whenever a slot is released.
+type slotCounter struct {
+	mutex sync.Mutex
+	count int64
+	freed chan struct{}
+}
+
+func newSlotCounter() *slotCounter {
+	return &slotCounter{freed: make(chan struct{})}
+}
+
+// load returns the number of slots in use
+func (c *slotCounter) load() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count
+}
+
+// tryTake reserves a slot if fewer than limit are in use (limit <= 0 = unlimited). When it
+// fails it returns a channel that is closed once a slot is released.
+func (c *slotCounter) tryTake(limit int64) (bool, <-chan struct{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if limit > 0 && c.count >= limit {
+		return false, c.freed
+	}
+	c.count++
+	return true, nil
+}
+
+// release frees a slot and wakes the queued requests
+func (c *slotCounter) release() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count--
+	close(c.freed)
+	c.freed = make(chan struct{})
+}
+
+// InFlight returns the number of requests currently being proxied to the endpoint
+func (e *Endpoint) InFlight() int64 {
+	if e.inFlight == nil {
+		return 0
+	}
+	return e.inFlight.load()
+}
+
+// MaxConcurrent returns the endpoint's concurrency limit (0 = unlimited)
+func (e *Endpoint) MaxConcurrent() int {
+	return e.Config.MaxConcurrentRequests
+}
+
+// HasCapacity reports whether the endpoint can take another concurrent request
+func (e *Endpoint) HasCapacity() bool {
+	limit := e.MaxConcurrent()
+	return limit <= 0 || e.InFlight() < int64(limit)
+}
+
+// Acquire reserves a concurrency slot on the endpoint, and a probe slot while its circuit
+// breaker is half-open. When the endpoint is saturated it waits up to max_queue_wait for a
+// slot to be released, taking it the moment it frees up, and otherwise returns
+// ErrEndpointSaturated. A cancelled ctx returns its error. The returned release function
+// frees the slots and is safe to call more than once, so callers can both defer it and
+// release early.
+func (e *Endpoint) Acquire(ctx context.Context) (release func(), err error) {
+	return e.acquire(ctx, e.Config.MaxQueueWait)
+}
+
+// TryAcquire reserves a slot like Acquire but never waits; ok is false when the endpoint is
+// saturated
+func (e *Endpoint) TryAcquire() (release func(), ok bool) {
+	release, err := e.acquire(context.Background(), 0)
+	return release, err == nil
+}
+
+func (e *Endpoint) acquire(ctx context.Context, maxWait time.Duration) (func(), error) {
+	if e.inFlight == nil {
+		releaseProbe, ok := e.acquireBreakerProbe()
+		if !ok {
+			return nil, ErrEndpointSaturated
+		}
+		var once sync.Once
+		return func() { once.Do(releaseProbe) }, nil
+	}
+
+	var deadline <-chan time.Time
+	for {
+		taken, freed := e.inFlight.tryTake(int64(e.MaxConcurrent()))
+		if taken {
+			break
+		}
+		if maxWait <= 0 {
+			return nil, ErrEndpointSaturated
+		}
+		if deadline == nil {
+			timer := time.NewTimer(maxWait)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		select {
+		case <-freed:
+		case <-deadline:
+			return nil, ErrEndpointSaturated
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	counter := e.inFlight
+	releaseProbe, ok := e.acquireBreakerProbe()
+	if !ok {
+		counter.release()
+		return nil, ErrEndpointSaturated
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			counter.release()
+			releaseProbe()
+		})
+	}, nil
+}
+
+// inFlightCounter returns the shared in-flight counter for an endpoint name. Counters
+// outlive config reloads so requests started before a reload still release their slot
+// against the same count the new endpoint reports.
+func (m *Manager) inFlightCounter(name string) *slotCounter {
+	m.inFlightMutex.Lock()
+	defer m.inFlightMutex.Unlock()
+
+	if m.inFlightCounters == nil {
+		m.inFlightCounters = make(map[string]*slotCounter)
+	}
+	counter, exists := m.inFlightCounters[name]
+	if !exists {
+		counter = newSlotCounter()
+		m.inFlightCounters[name] = counter
+	}
+	return counter
+}
+
+// pruneInFlightCounters drops counters for endpoints that no longer exist. Requests still
+// running against a removed endpoint keep their own reference and release it normally.
+func (m *Manager) pruneInFlightCounters(endpoints []*Endpoint) {
+	m.inFlightMutex.Lock()
+	defer m.inFlightMutex.Unlock()
+
+	current := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		current[ep.Config.Name] = true
+	}
+	for name := range m.inFlightCounters {
+		if !current[name] {
+			delete(m.inFlightCounters, name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func TestAcquireHandsFreedSlotToOneWaiter(t *testing.T) {
+	ep := &Endpoint{
+		Config:   config.EndpointConfig{Name: "slow", MaxConcurrentRequests: 1, MaxQueueWait: 2 * time.Second},
+		inFlight: newSlotCounter(),
+	}
+	held, ok := ep.TryAcquire()
+	if !ok {
+		t.Fatal("Expected the first slot to be free")
+	}
+	if _, ok := ep.TryAcquire(); ok {
+		t.Fatal("Expected TryAcquire not to wait for a saturated endpoint")
+	}
+
+	acquired := make(chan func(), 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			release, err := ep.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("Expected every waiter to get a slot in turn, got %v", err)
+				return
+			}
+			acquired <- release
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Each release wakes all waiters, but only one takes the slot; the rest keep queueing
+	for i := 0; i < 3; i++ {
+		held()
+		select {
+		case held = <-acquired:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected waiter %d to take the freed slot", i+1)
+		}
+		select {
+		case <-acquired:
+			t.Fatal("Expected a single freed slot to go to a single waiter")
+		case <-time.After(20 * time.Millisecond):
+		}
+		if n := ep.InFlight(); n != 1 {
+			t.Errorf("Expected 1 in-flight request, got %d", n)
+		}
+	}
+	held()
+	held()
+	if n := ep.InFlight(); n != 0 {
+		t.Errorf("Expected releasing twice to free the slot once, got %d in flight", n)
+	}
+}
+
+func TestAcquireGivesUpAfterMaxQueueWait(t *testing.T) {
+	ep := &Endpoint{
+		Config:   config.EndpointConfig{Name: "slow", MaxConcurrentRequests: 1, MaxQueueWait: 30 * time.Millisecond},
+		inFlight: newSlotCounter(),
+	}
+	held, _ := ep.TryAcquire()
+	defer held()
+
+	start := time.Now()
+	if _, err := ep.Acquire(context.Background()); !errors.Is(err, ErrEndpointSaturated) {
+		t.Fatalf("Expected ErrEndpointSaturated after max_queue_wait, got %v", err)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("Expected to queue for max_queue_wait, gave up after %v", waited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ep.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the wait, got %v", err)
+	}
+}
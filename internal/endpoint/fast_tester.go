@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"endpoint_forwarder/config"
@@ -21,13 +23,20 @@ type FastTestResult struct {
 	TestTime     time.Time
 }
 
+// anyAge accepts cached results however old they are
+const anyAge = time.Duration(math.MaxInt64)
+
 // FastTester performs quick parallel tests on endpoints
 type FastTester struct {
-	config      *config.Config
-	client      *http.Client
-	resultCache map[string]*FastTestResult
-	cacheMutex  sync.RWMutex
-	manager     *Manager // Reference to manager for dynamic token resolution
+	config  *config.Config
+	client  *http.Client
+	prober  *Prober  // Sends the tests and caches results, shared with the health checker once a manager is set
+	manager *Manager // Reference to manager for dynamic token resolution
+
+	roundMutex   sync.Mutex
+	round        chan struct{} // Closed when the test round in progress completes, nil when none is running
+	rounds       atomic.Int64  // Test rounds performed
+	deduplicated atomic.Int64  // Callers that reused another caller's round instead of starting one
 }
 
 // NewFastTester creates a new fast tester
@@ -46,37 +55,30 @@ func NewFastTester(cfg *config.Config) *FastTester {
 			Timeout:   cfg.Strategy.FastTestTimeout,
 			Transport: httpTransport,
 		},
-		resultCache: make(map[string]*FastTestResult),
+		prober: NewProber(nil),
 	}
 }
 
-// SetManager sets the manager reference for dynamic token resolution
+// SetManager sets the manager reference for dynamic token resolution and switches to
+// the manager's prober, so health check results count as fast test results and vice versa
 func (ft *FastTester) SetManager(manager *Manager) {
 	ft.manager = manager
+	ft.prober = manager.prober
 }
 
 // TestEndpointsParallel performs parallel testing on all healthy endpoints
-// Returns (results, usedCache) where usedCache indicates if cached results were used
+// Returns (results, usedCache) where usedCache indicates if cached results were used.
+// Only one test round runs at a time: while the cache is expired, the first caller starts
+// a round and concurrent callers reuse the previous results (stale-while-revalidate), or
+// wait for the round when there are none yet.
 func (ft *FastTester) TestEndpointsParallel(ctx context.Context, endpoints []*Endpoint) ([]*FastTestResult, bool) {
 	if !ft.config.Strategy.FastTestEnabled {
 		// Fast testing disabled, return endpoints with artificial results based on current status
-		results := make([]*FastTestResult, 0, len(endpoints))
-		for _, ep := range endpoints {
-			ep.mutex.RLock()
-			result := &FastTestResult{
-				Endpoint:     ep,
-				ResponseTime: ep.Status.ResponseTime,
-				Success:      ep.Status.Healthy,
-				TestTime:     time.Now(),
-			}
-			ep.mutex.RUnlock()
-			results = append(results, result)
-		}
-		return results, false
+		return statusResults(endpoints), false
 	}
 
 	// Check cache first
-	cachedResults := ft.getCachedResults(endpoints)
+	cachedResults := ft.getCachedResults(endpoints, ft.config.Strategy.FastTestCacheTTL)
 	if len(cachedResults) == len(endpoints) {
 		slog.Info("📋 Using cached fast test results",
 			"cached_endpoints", len(cachedResults),
@@ -84,6 +86,52 @@ func (ft *FastTester) TestEndpointsParallel(ctx context.Context, endpoints []*En
 		return cachedResults, true
 	}
 
+	for {
+		stale := ft.getCachedResults(endpoints, anyAge)
+
+		ft.roundMutex.Lock()
+		round := ft.round
+		if round == nil {
+			round = make(chan struct{})
+			ft.round = round
+			ft.roundMutex.Unlock()
+			if stale != nil {
+				// Refresh in the background and answer with the previous measurements
+				go ft.runRound(context.WithoutCancel(ctx), endpoints, round)
+				slog.Debug("♻️ Using stale fast test results while refreshing",
+					"endpoints", len(stale))
+				return stale, true
+			}
+			return ft.runRound(ctx, endpoints, round), false
+		}
+		ft.roundMutex.Unlock()
+
+		ft.deduplicated.Add(1)
+		if stale != nil {
+			return stale, true
+		}
+		select {
+		case <-round:
+		case <-ctx.Done():
+			return statusResults(endpoints), false
+		}
+		// Endpoints the finished round did not cover start another round on the next pass
+		if results := ft.getCachedResults(endpoints, anyAge); results != nil {
+			return results, true
+		}
+	}
+}
+
+// runRound tests the endpoints in parallel and closes round when done
+func (ft *FastTester) runRound(ctx context.Context, endpoints []*Endpoint, round chan struct{}) []*FastTestResult {
+	defer func() {
+		ft.roundMutex.Lock()
+		ft.round = nil
+		ft.roundMutex.Unlock()
+		close(round)
+	}()
+	ft.rounds.Add(1)
+
 	slog.Debug("🚀 Starting parallel fast test",
 		"endpoints", len(endpoints),
 		"timeout", ft.config.Strategy.FastTestTimeout,
@@ -101,140 +149,116 @@ func (ft *FastTester) TestEndpointsParallel(ctx context.Context, endpoints []*En
 		}(i, endpoint)
 	}
 
-	// Wait for all tests to complete
+	// Wait for all tests to complete (the prober caches the results)
 	wg.Wait()
 
-	// Update cache
-	ft.updateCache(results)
-
+	performed, deduplicated := ft.RoundCounts()
 	slog.Debug("✅ Parallel fast test completed",
 		"total_endpoints", len(results),
-		"successful", ft.countSuccessful(results))
+		"successful", ft.countSuccessful(results),
+		"rounds_performed", performed,
+		"rounds_deduplicated", deduplicated)
 
-	return results, false
+	return results
 }
 
-// testSingleEndpoint tests a single endpoint
-func (ft *FastTester) testSingleEndpoint(ctx context.Context, endpoint *Endpoint) *FastTestResult {
-	start := time.Now()
-
-	// Create test URL
-	testURL := endpoint.Config.URL + ft.config.Strategy.FastTestPath
+// RoundCounts returns how many test rounds were performed and how many callers reused
+// a round in progress instead of starting their own
+func (ft *FastTester) RoundCounts() (performed, deduplicated int64) {
+	return ft.rounds.Load(), ft.deduplicated.Load()
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", testURL, nil)
-	if err != nil {
-		return &FastTestResult{
-			Endpoint:     endpoint,
-			ResponseTime: time.Since(start),
-			Success:      false,
-			Error:        err,
+// statusResults builds results from the endpoints' health check status without testing
+func statusResults(endpoints []*Endpoint) []*FastTestResult {
+	results := make([]*FastTestResult, 0, len(endpoints))
+	for _, ep := range endpoints {
+		ep.mutex.RLock()
+		result := &FastTestResult{
+			Endpoint:     ep,
+			ResponseTime: ep.Status.ResponseTime,
+			Success:      ep.Status.Healthy,
 			TestTime:     time.Now(),
 		}
+		ep.mutex.RUnlock()
+		results = append(results, result)
 	}
+	return results
+}
 
-	// Add authorization with dynamically resolved token
-	var token string
-	if ft.manager != nil {
-		token = ft.manager.GetTokenForEndpoint(endpoint)
-	} else {
-		// Fallback to endpoint's own token if manager is not available
-		token = endpoint.Config.Token
-	}
-	
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
+// testSingleEndpoint tests a single endpoint
+func (ft *FastTester) testSingleEndpoint(ctx context.Context, endpoint *Endpoint) *FastTestResult {
+	testURL := endpoint.Config.BaseURL() + ft.config.Strategy.FastTestPath
 
-	// Add custom headers
-	for key, value := range endpoint.Config.Headers {
-		req.Header.Set(key, value)
-	}
+	client, cleanup := endpointClient(ft.config, endpoint, ft.client)
+	defer cleanup()
 
-	resp, err := ft.client.Do(req)
-	responseTime := time.Since(start)
+	probe := ft.prober.Probe(ctx, endpoint, client, ft.config.Strategy.FastTestPath)
+	result := newFastTestResult(endpoint, probe)
 
-	if err != nil {
+	if probe.Error != nil {
 		slog.Warn("❌ Fast test failed with network error",
 			"endpoint", endpoint.Config.Name,
 			"url", testURL,
-			"response_time_ms", responseTime.Milliseconds(),
-			"error", err.Error(),
+			"response_time_ms", probe.ResponseTime.Milliseconds(),
+			"error", probe.Error.Error(),
 			"reason", "Network or connection error")
-
-		return &FastTestResult{
-			Endpoint:     endpoint,
-			ResponseTime: responseTime,
-			Success:      false,
-			Error:        err,
-			TestTime:     time.Now(),
-		}
+		return result
 	}
 
-	resp.Body.Close()
-
-	// Consider 2xx and 40x as success (same logic as health check)
-	success := (resp.StatusCode >= 200 && resp.StatusCode < 300) ||
-		(resp.StatusCode >= 400 && resp.StatusCode < 500)
-
 	// Log detailed test results
-	if success {
+	if probe.Healthy {
 		slog.Debug("⚡ Fast test completed successfully",
 			"endpoint", endpoint.Config.Name,
 			"url", testURL,
-			"status_code", resp.StatusCode,
-			"response_time_ms", responseTime.Milliseconds(),
-			"success", success)
+			"status_code", probe.StatusCode,
+			"response_time_ms", probe.ResponseTime.Milliseconds(),
+			"success", probe.Healthy)
 	} else {
 		slog.Warn("❌ Fast test failed with bad status",
 			"endpoint", endpoint.Config.Name,
 			"url", testURL,
-			"status_code", resp.StatusCode,
-			"response_time_ms", responseTime.Milliseconds(),
-			"success", success,
+			"status_code", probe.StatusCode,
+			"response_time_ms", probe.ResponseTime.Milliseconds(),
+			"success", probe.Healthy,
 			"reason", "Invalid HTTP status code")
 	}
 
+	return result
+}
+
+// newFastTestResult converts a probe result of an endpoint
+func newFastTestResult(endpoint *Endpoint, probe ProbeResult) *FastTestResult {
 	return &FastTestResult{
 		Endpoint:     endpoint,
-		ResponseTime: responseTime,
-		Success:      success,
-		TestTime:     time.Now(),
+		ResponseTime: probe.ResponseTime,
+		Success:      probe.Healthy,
+		Error:        probe.Error,
+		TestTime:     probe.Time,
 	}
 }
 
-// getCachedResults returns cached results for endpoints if they're still valid
-func (ft *FastTester) getCachedResults(endpoints []*Endpoint) []*FastTestResult {
-	ft.cacheMutex.RLock()
-	defer ft.cacheMutex.RUnlock()
-
-	now := time.Now()
+// getCachedResults returns cached results for endpoints if they're all at most maxAge old.
+// Passive results (from real requests) don't measure latency and are not used.
+func (ft *FastTester) getCachedResults(endpoints []*Endpoint, maxAge time.Duration) []*FastTestResult {
 	results := make([]*FastTestResult, 0, len(endpoints))
-
 	for _, ep := range endpoints {
-		if cached, exists := ft.resultCache[ep.Config.Name]; exists {
-			if now.Sub(cached.TestTime) <= ft.config.Strategy.FastTestCacheTTL {
-				results = append(results, cached)
-			} else {
-				// Cache expired for this endpoint
-				return nil
-			}
-		} else {
-			// No cache for this endpoint
+		probe, ok := ft.prober.Result(ep.Config.Name, maxAge)
+		if !ok || probe.Passive {
 			return nil
 		}
+		results = append(results, newFastTestResult(ep, probe))
 	}
-
 	return results
 }
 
-// updateCache updates the cache with new test results
-func (ft *FastTester) updateCache(results []*FastTestResult) {
-	ft.cacheMutex.Lock()
-	defer ft.cacheMutex.Unlock()
-
-	for _, result := range results {
-		ft.resultCache[result.Endpoint.Config.Name] = result
+// measuredResults returns the results a request would rank the endpoints by right now,
+// without testing: fresh ones, or older ones a request uses while a new round runs. fresh
+// reports which; the results are nil when some endpoint has not been tested yet.
+func (ft *FastTester) measuredResults(endpoints []*Endpoint) (results []*FastTestResult, fresh bool) {
+	if results = ft.getCachedResults(endpoints, ft.config.Strategy.FastTestCacheTTL); len(results) == len(endpoints) {
+		return results, true
 	}
+	return ft.getCachedResults(endpoints, anyAge), false
 }
 
 // countSuccessful counts successful test results
@@ -283,15 +307,11 @@ func (ft *FastTester) UpdateConfig(cfg *config.Config) {
 	}
 	
 	// Clear cache when configuration changes
-	ft.cacheMutex.Lock()
-	ft.resultCache = make(map[string]*FastTestResult)
-	ft.cacheMutex.Unlock()
+	ft.prober.Reset()
 }
 
 // ResetCache clears the fast tester's result cache without recreating the client.
 func (ft *FastTester) ResetCache() {
-    ft.cacheMutex.Lock()
-    ft.resultCache = make(map[string]*FastTestResult)
-    ft.cacheMutex.Unlock()
+    ft.prober.Reset()
     slog.Info("🧹 [FastTester] 已清空快速测试缓存")
 }
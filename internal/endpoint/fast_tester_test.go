@@ -3,6 +3,10 @@ package endpoint
 import (
 	"context"
 	"endpoint_forwarder/config"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -107,4 +111,80 @@ func TestFastTesterDisabled(t *testing.T) {
 	if usedCache {
 		t.Error("Expected cache not to be used when fast testing is disabled")
 	}
-}
\ No newline at end of file
+}
+func TestFastTesterStampedeProtection(t *testing.T) {
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{
+			Type:             "fastest",
+			FastTestEnabled:  true,
+			FastTestCacheTTL: 300 * time.Millisecond,
+			FastTestTimeout:  time.Second,
+			FastTestPath:     "/test",
+		},
+	}
+	tester := NewFastTester(cfg)
+	var endpoints []*Endpoint
+	for _, name := range []string{"a", "b", "c"} {
+		endpoints = append(endpoints, &Endpoint{
+			Config: config.EndpointConfig{Name: name, URL: server.URL},
+			Status: EndpointStatus{Healthy: true},
+		})
+	}
+
+	burst := func() int64 {
+		var wg sync.WaitGroup
+		var fromCache atomic.Int64
+		start := make(chan struct{})
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				results, usedCache := tester.TestEndpointsParallel(context.Background(), endpoints)
+				if len(results) != len(endpoints) {
+					t.Errorf("Expected %d results, got %d", len(endpoints), len(results))
+				}
+				if usedCache {
+					fromCache.Add(1)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		return fromCache.Load()
+	}
+
+	// Without previous results, one caller tests and the others wait for its round
+	burst()
+	if got := hits.Load(); got != 3 {
+		t.Errorf("Expected one round of 3 tests, got %d requests", got)
+	}
+	if performed, _ := tester.RoundCounts(); performed != 1 {
+		t.Errorf("Expected 1 round, got %d", performed)
+	}
+
+	// After expiry, every caller gets the stale results while one round refreshes them
+	time.Sleep(cfg.Strategy.FastTestCacheTTL + 50*time.Millisecond)
+	if fromCache := burst(); fromCache != 100 {
+		t.Errorf("Expected all 100 callers to get stale results, got %d", fromCache)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for hits.Load() < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := hits.Load(); got != 6 {
+		t.Errorf("Expected exactly one refresh round after expiry, got %d requests in total", got)
+	}
+	if performed, deduplicated := tester.RoundCounts(); performed != 2 || deduplicated == 0 {
+		t.Errorf("Expected 2 rounds and deduplicated callers, got %d and %d", performed, deduplicated)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"log/slog"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/notify"
 )
 
 // GroupInfo represents information about an endpoint group
@@ -19,6 +21,9 @@ type GroupInfo struct {
 	Endpoints    []*Endpoint
 	RetryCount   int           // Current retry count for this group
 	MaxRetries   int           // Maximum retries before cooldown
+	Cooldown     time.Duration // How long the group cools down after exhausting its retries
+	Strategy     string        // Selection strategy within the group, empty = global strategy
+	rrCounter    *atomic.Uint64 // Round-robin position, recreated when groups are rebuilt
 }
 
 // GroupManager manages endpoint groups and their cooldown states
@@ -27,6 +32,18 @@ type GroupManager struct {
 	config        *config.Config
 	mutex         sync.RWMutex
 	cooldownDuration time.Duration
+	onStateChange func() // Called when cooldown state changes (used for runtime state persistence)
+	generation    atomic.Uint64 // Bumped on every group state change
+	publish       notify.Publisher // Receives cooldown enter/exit events
+	preferred     string        // Group force-activated by an operator, used ahead of priority order until it cools down
+	cooldownTime  map[string]time.Duration // Time spent in finished cooldowns per group name, kept across reloads
+	cooldownSpans map[string]cooldownSpan  // Latest cooldown of each group, possibly expired already
+}
+
+// cooldownSpan is one cooldown of a group; it lasts until the deadline unless cleared earlier
+type cooldownSpan struct {
+	start time.Time
+	until time.Time
 }
 
 // NewGroupManager creates a new group manager
@@ -35,6 +52,8 @@ func NewGroupManager(cfg *config.Config) *GroupManager {
 		groups:        make(map[string]*GroupInfo),
 		config:        cfg,
 		cooldownDuration: cfg.Group.Cooldown,
+		cooldownTime:  make(map[string]time.Duration),
+		cooldownSpans: make(map[string]cooldownSpan),
 	}
 }
 
@@ -46,9 +65,32 @@ func (gm *GroupManager) UpdateConfig(cfg *config.Config) {
 	gm.config = cfg
 	gm.cooldownDuration = cfg.Group.Cooldown
 	
-	// Update max retries for all groups
+	// Update max retries and cooldown for all groups
 	for _, group := range gm.groups {
-		group.MaxRetries = cfg.Group.MaxRetries
+		group.MaxRetries = cfg.GetGroupMaxRetries(group.Name)
+		group.Cooldown = cfg.GetGroupCooldown(group.Name)
+	}
+}
+
+// SetStateChangeHandler sets a callback invoked whenever group cooldown state changes
+func (gm *GroupManager) SetStateChangeHandler(handler func()) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+	gm.onStateChange = handler
+}
+
+// SetEventPublisher sets where cooldown enter and exit events are published
+func (gm *GroupManager) SetEventPublisher(publish notify.Publisher) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+	gm.publish = publish
+}
+
+// notifyStateChange invokes the state change callback (caller holds the lock)
+func (gm *GroupManager) notifyStateChange() {
+	gm.generation.Add(1)
+	if gm.onStateChange != nil {
+		gm.onStateChange()
 	}
 }
 
@@ -99,7 +141,10 @@ func (gm *GroupManager) UpdateGroups(endpoints []*Endpoint) {
 				CooldownUntil: cooldownUntil,
 				Endpoints:    make([]*Endpoint, 0),
 				RetryCount:   retryCount,
-				MaxRetries:   gm.config.Group.MaxRetries,
+				MaxRetries:   gm.config.GetGroupMaxRetries(groupName),
+				Cooldown:     gm.config.GetGroupCooldown(groupName),
+				Strategy:     gm.config.GetGroupStrategy(groupName),
+				rrCounter:    new(atomic.Uint64),
 			}
 		}
 		
@@ -107,9 +152,13 @@ func (gm *GroupManager) UpdateGroups(endpoints []*Endpoint) {
 	}
 	
 	gm.groups = newGroups
+	if _, exists := newGroups[gm.preferred]; !exists {
+		gm.preferred = ""
+	}
 	
     // Update active status based on cooldown timers
     gm.updateActiveGroups()
+    gm.generation.Add(1)
 }
 
 // ResetAllStates clears retry counters and cooldown timers for all groups and marks them active.
@@ -118,16 +167,44 @@ func (gm *GroupManager) ResetAllStates() {
     gm.mutex.Lock()
     defer gm.mutex.Unlock()
 
+    now := time.Now()
     for _, group := range gm.groups {
         group.RetryCount = 0
         group.CooldownUntil = time.Time{}
         group.IsActive = true
+        gm.closeCooldownSpan(group.Name, now)
     }
+    gm.preferred = ""
+    // Only the highest priority group is active again
+    gm.updateActiveGroups()
+    gm.notifyStateChange()
 
     slog.Info("🔄 [组管理] 已重置所有组的重试计数与冷却状态")
 }
 
-// updateActiveGroups updates which groups are currently active
+// expireCooldowns reactivates groups whose cooldown ran out. Readers call it before looking at
+// the active groups; the expiry is applied under the write lock, so only one caller announces it.
+func (gm *GroupManager) expireCooldowns() {
+	now := time.Now()
+	gm.mutex.RLock()
+	expired := false
+	for _, group := range gm.groups {
+		if !group.CooldownUntil.IsZero() && now.After(group.CooldownUntil) {
+			expired = true
+			break
+		}
+	}
+	gm.mutex.RUnlock()
+	if !expired {
+		return
+	}
+
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+	gm.updateActiveGroups()
+}
+
+// updateActiveGroups updates which groups are currently active (caller holds the write lock)
 func (gm *GroupManager) updateActiveGroups() {
 	now := time.Now()
 	
@@ -139,6 +216,11 @@ func (gm *GroupManager) updateActiveGroups() {
 			group.CooldownUntil = time.Time{}
 			slog.Info(fmt.Sprintf("🔄 [组管理] 组冷却结束，重新激活: %s (优先级: %d)", 
 				group.Name, group.Priority))
+			gm.publish.Publish(notify.Event{
+				Type:    config.NotifyEventGroupCooldownExit,
+				Subject: group.Name,
+				Message: fmt.Sprintf("Group %s left cooldown and can be used again", group.Name),
+			})
 		} else if !group.CooldownUntil.IsZero() && now.Before(group.CooldownUntil) {
 			// Still in cooldown
 			group.IsActive = false
@@ -149,9 +231,16 @@ func (gm *GroupManager) updateActiveGroups() {
 	// Get all groups sorted by priority
 	sortedGroups := gm.getSortedGroups()
 	
-	// Find the highest priority group that's not in cooldown
+	// A force-activated group goes first; otherwise the highest priority group that's not in cooldown
 	activeGroupFound := false
+	if preferred, exists := gm.groups[gm.preferred]; exists && (preferred.CooldownUntil.IsZero() || now.After(preferred.CooldownUntil)) {
+		preferred.IsActive = true
+		activeGroupFound = true
+	}
 	for _, group := range sortedGroups {
+		if activeGroupFound && group.Name == gm.preferred {
+			continue
+		}
 		if group.CooldownUntil.IsZero() || now.After(group.CooldownUntil) {
 			if !activeGroupFound {
 				group.IsActive = true
@@ -177,17 +266,18 @@ func (gm *GroupManager) getSortedGroups() []*GroupInfo {
 	return groups
 }
 
-// GetActiveGroups returns currently active groups
+// GetActiveGroups returns copies of the currently active groups
 func (gm *GroupManager) GetActiveGroups() []*GroupInfo {
+	gm.expireCooldowns()
+
 	gm.mutex.RLock()
 	defer gm.mutex.RUnlock()
 	
-	gm.updateActiveGroups()
-	
 	var active []*GroupInfo
 	for _, group := range gm.groups {
 		if group.IsActive {
-			active = append(active, group)
+			copied := *group
+			active = append(active, &copied)
 		}
 	}
 	
@@ -199,16 +289,17 @@ func (gm *GroupManager) GetActiveGroups() []*GroupInfo {
 	return active
 }
 
-// GetAllGroups returns all groups
+// GetAllGroups returns copies of all groups
 func (gm *GroupManager) GetAllGroups() []*GroupInfo {
+	gm.expireCooldowns()
+
 	gm.mutex.RLock()
 	defer gm.mutex.RUnlock()
 	
-	gm.updateActiveGroups()
-	
 	groups := make([]*GroupInfo, 0, len(gm.groups))
 	for _, group := range gm.groups {
-		groups = append(groups, group)
+		copied := *group
+		groups = append(groups, &copied)
 	}
 	
 	// Sort by priority
@@ -225,27 +316,228 @@ func (gm *GroupManager) SetGroupCooldown(groupName string) {
 	defer gm.mutex.Unlock()
 	
 	if group, exists := gm.groups[groupName]; exists {
-		now := time.Now()
-		group.CooldownUntil = now.Add(gm.cooldownDuration)
-		group.IsActive = false
-		
-		slog.Warn(fmt.Sprintf("❄️ [组管理] 组进入冷却状态: %s (冷却时长: %v, 恢复时间: %s)", 
-			groupName, gm.cooldownDuration, group.CooldownUntil.Format("15:04:05")))
-		
-		// Update active groups after cooldown change
-		gm.updateActiveGroups()
-		
-		// Log next active group if any
-		for _, g := range gm.getSortedGroups() {
-			if g.IsActive {
-				slog.Info(fmt.Sprintf("🔄 [组管理] 切换到下一优先级组: %s (优先级: %d)", 
-					g.Name, g.Priority))
-				break
-			}
+		gm.enterCooldown(group, "exhausted its retries and entered cooldown")
+	}
+}
+
+// StartGroupCooldown puts a group into cooldown on request of an operator, moving traffic
+// to the next group. Returns false if the group does not exist.
+func (gm *GroupManager) StartGroupCooldown(groupName string) bool {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	group, exists := gm.groups[groupName]
+	if !exists {
+		return false
+	}
+	slog.Info(fmt.Sprintf("❄️ [组管理] 组被手动置为冷却: %s", groupName))
+	gm.enterCooldown(group, "was put into cooldown manually")
+	return true
+}
+
+// SetGroupRateLimited puts a group whose endpoints are all rate limited by their upstreams
+// into cooldown until the first of them may be used again, moving traffic to the next group
+// without counting toward the group's retries. A cooldown that already lasts longer is kept.
+func (gm *GroupManager) SetGroupRateLimited(groupName string, until time.Time) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	group, exists := gm.groups[groupName]
+	if !exists || !until.After(time.Now()) || !group.CooldownUntil.Before(until) {
+		return
+	}
+	gm.enterCooldownUntil(group, until, "has all endpoints rate limited by their upstreams")
+}
+
+// SetGroupBudgetExhausted puts a group whose block token budget is used up into cooldown until
+// the budget's window resets, moving traffic to the next group. A cooldown that already lasts
+// longer is kept.
+func (gm *GroupManager) SetGroupBudgetExhausted(groupName string, until time.Time) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	group, exists := gm.groups[groupName]
+	if !exists || !until.After(time.Now()) || !group.CooldownUntil.Before(until) {
+		return
+	}
+	gm.enterCooldownUntil(group, until, "has used up its token budget")
+}
+
+// enterCooldown starts a group's cooldown and activates the next group (caller holds the lock)
+func (gm *GroupManager) enterCooldown(group *GroupInfo, reason string) {
+	cooldown := group.Cooldown
+	if cooldown <= 0 {
+		cooldown = gm.cooldownDuration
+	}
+	gm.enterCooldownUntil(group, time.Now().Add(cooldown), reason)
+}
+
+// enterCooldownUntil starts a group's cooldown lasting until the given time and activates
+// the next group (caller holds the lock)
+func (gm *GroupManager) enterCooldownUntil(group *GroupInfo, until time.Time, reason string) {
+	now := time.Now()
+	group.CooldownUntil = until
+	group.IsActive = false
+	gm.closeCooldownSpan(group.Name, now)
+	gm.cooldownSpans[group.Name] = cooldownSpan{start: now, until: group.CooldownUntil}
+	if gm.preferred == group.Name {
+		// A force-activated group that fails falls back to the normal priority order
+		gm.preferred = ""
+	}
+	
+	cooldown := until.Sub(now).Round(time.Second)
+	slog.Warn(fmt.Sprintf("❄️ [组管理] 组进入冷却状态: %s (冷却时长: %v, 恢复时间: %s)", 
+		group.Name, cooldown, group.CooldownUntil.Format("15:04:05")))
+	gm.publish.Publish(notify.Event{
+		Type:    config.NotifyEventGroupCooldownEnter,
+		Subject: group.Name,
+		Message: fmt.Sprintf("Group %s %s until %s", group.Name, reason, group.CooldownUntil.Format(time.RFC3339)),
+		Details: map[string]string{"cooldown": cooldown.String()},
+	})
+	
+	// Update active groups after cooldown change
+	gm.updateActiveGroups()
+	gm.notifyStateChange()
+	
+	// Log next active group if any
+	for _, g := range gm.getSortedGroups() {
+		if g.IsActive {
+			slog.Info(fmt.Sprintf("🔄 [组管理] 切换到下一优先级组: %s (优先级: %d)", 
+				g.Name, g.Priority))
+			break
 		}
 	}
 }
 
+// RestoreGroupCooldown puts a group back into cooldown until the given deadline (used when
+// restoring runtime state). Returns false if the group does not exist.
+func (gm *GroupManager) RestoreGroupCooldown(groupName string, until time.Time) bool {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	group, exists := gm.groups[groupName]
+	if !exists {
+		return false
+	}
+
+	now := time.Now()
+	group.CooldownUntil = until
+	gm.closeCooldownSpan(groupName, now)
+	gm.cooldownSpans[groupName] = cooldownSpan{start: now, until: until}
+	gm.updateActiveGroups()
+	return true
+}
+
+// closeCooldownSpan adds a group's latest cooldown, up to end, to its finished cooldown time
+// (caller holds the lock)
+func (gm *GroupManager) closeCooldownSpan(groupName string, end time.Time) {
+	span, exists := gm.cooldownSpans[groupName]
+	if !exists {
+		return
+	}
+	delete(gm.cooldownSpans, groupName)
+	if span.until.Before(end) {
+		end = span.until
+	}
+	if d := end.Sub(span.start); d > 0 {
+		gm.cooldownTime[groupName] += d
+	}
+}
+
+// GetGroupCooldownTimes returns the total time each group has spent in cooldown, including a
+// cooldown still running. Groups keep their time when they are removed on config reload.
+func (gm *GroupManager) GetGroupCooldownTimes() map[string]time.Duration {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	now := time.Now()
+	times := make(map[string]time.Duration, len(gm.cooldownTime)+len(gm.cooldownSpans))
+	for name, d := range gm.cooldownTime {
+		times[name] = d
+	}
+	for name, span := range gm.cooldownSpans {
+		end := now
+		if span.until.Before(end) {
+			end = span.until
+		}
+		if d := end.Sub(span.start); d > 0 {
+			times[name] += d
+		}
+	}
+	return times
+}
+
+// ClearGroupCooldown ends a group's cooldown early and resets its retry count, e.g. on
+// request of an operator. Returns false if the group does not exist.
+func (gm *GroupManager) ClearGroupCooldown(groupName string) bool {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	group, exists := gm.groups[groupName]
+	if !exists {
+		return false
+	}
+
+	now := time.Now()
+	inCooldown := !group.CooldownUntil.IsZero() && now.Before(group.CooldownUntil)
+	group.CooldownUntil = time.Time{}
+	gm.closeCooldownSpan(groupName, now)
+	group.RetryCount = 0
+	gm.updateActiveGroups()
+	gm.notifyStateChange()
+
+	if inCooldown {
+		slog.Info(fmt.Sprintf("🔄 [组管理] 组冷却已被手动清除: %s (优先级: %d)", group.Name, group.Priority))
+		gm.publish.Publish(notify.Event{
+			Type:    config.NotifyEventGroupCooldownExit,
+			Subject: group.Name,
+			Message: fmt.Sprintf("Group %s left cooldown and can be used again", group.Name),
+		})
+	}
+	return true
+}
+
+// ActivateGroup makes a group the active one regardless of its priority, ending its cooldown
+// and resetting its retry count. It stays preferred until it enters cooldown again, another
+// group is activated or the configuration changes. Returns false if the group does not exist.
+func (gm *GroupManager) ActivateGroup(groupName string) bool {
+	gm.mutex.Lock()
+	group, exists := gm.groups[groupName]
+	if exists {
+		gm.preferred = groupName
+	}
+	gm.mutex.Unlock()
+	if !exists {
+		return false
+	}
+
+	gm.ClearGroupCooldown(groupName)
+	slog.Info(fmt.Sprintf("⭐ [组管理] 组被手动激活: %s (优先级: %d)", groupName, group.Priority))
+	return true
+}
+
+// PreferredGroup returns the group force-activated with ActivateGroup, or "" if none
+func (gm *GroupManager) PreferredGroup() string {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	return gm.preferred
+}
+
+// GetGroupCooldowns returns the cooldown deadlines of all groups currently in cooldown
+func (gm *GroupManager) GetGroupCooldowns() map[string]time.Time {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	now := time.Now()
+	cooldowns := make(map[string]time.Time)
+	for name, group := range gm.groups {
+		if !group.CooldownUntil.IsZero() && now.Before(group.CooldownUntil) {
+			cooldowns[name] = group.CooldownUntil
+		}
+	}
+	return cooldowns
+}
+
 // IsGroupInCooldown checks if a group is currently in cooldown
 func (gm *GroupManager) IsGroupInCooldown(groupName string) bool {
 	gm.mutex.RLock()
@@ -274,8 +566,14 @@ func (gm *GroupManager) GetGroupCooldownRemaining(groupName string) time.Duratio
 
 // FilterEndpointsByActiveGroups filters endpoints to only include those in active groups
 func (gm *GroupManager) FilterEndpointsByActiveGroups(endpoints []*Endpoint) []*Endpoint {
+	return gm.filterActive(endpoints, nil)
+}
+
+// filterActive is FilterEndpointsByActiveGroups recording the endpoints of inactive groups
+// in trace when trace is not nil
+func (gm *GroupManager) filterActive(endpoints []*Endpoint, trace *SelectionTrace) []*Endpoint {
 	activeGroups := gm.GetActiveGroups()
-	if len(activeGroups) == 0 {
+	if len(activeGroups) == 0 && trace == nil {
 		return nil
 	}
 	
@@ -295,12 +593,25 @@ func (gm *GroupManager) FilterEndpointsByActiveGroups(endpoints []*Endpoint) []*
 		
 		if activeGroupNames[groupName] {
 			filtered = append(filtered, ep)
+		} else if trace != nil {
+			trace.Exclude(ep, gm.inactiveReason(groupName, activeGroups))
 		}
 	}
 	
 	return filtered
 }
 
+// inactiveReason explains why a group is not one of the active groups
+func (gm *GroupManager) inactiveReason(groupName string, activeGroups []*GroupInfo) string {
+	if remaining := gm.GetGroupCooldownRemaining(groupName); remaining > 0 {
+		return fmt.Sprintf("group %s is in cooldown for another %s", groupName, remaining.Round(time.Second))
+	}
+	if len(activeGroups) == 0 {
+		return fmt.Sprintf("group %s is not active, and neither is any other group", groupName)
+	}
+	return fmt.Sprintf("group %s is not active (active group: %s)", groupName, activeGroups[0].Name)
+}
+
 // IncrementGroupRetry increments the retry count for a group
 // Returns true if the group should enter cooldown, false otherwise
 func (gm *GroupManager) IncrementGroupRetry(groupName string) bool {
@@ -359,5 +670,25 @@ func (gm *GroupManager) GetGroupMaxRetries(groupName string) int {
 		return group.MaxRetries
 	}
 	
-	return gm.config.Group.MaxRetries
+	return gm.config.GetGroupMaxRetries(groupName)
+}
+
+// GetGroupCooldownDuration returns how long a group cools down after exhausting its retries
+func (gm *GroupManager) GetGroupCooldownDuration(groupName string) time.Duration {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	if group, exists := gm.groups[groupName]; exists && group.Cooldown > 0 {
+		return group.Cooldown
+	}
+
+	return gm.config.GetGroupCooldown(groupName)
+}
+
+// GetGroupRetry returns the retry settings used for requests served by a group's endpoints
+func (gm *GroupManager) GetGroupRetry(groupName string) config.RetryConfig {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	return gm.config.GetGroupRetry(groupName)
 }
@@ -0,0 +1,90 @@
+package endpoint
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/notify"
+)
+
+func TestActivateGroup(t *testing.T) {
+	m := NewManager(newGroupStrategyTestConfig("priority"))
+	gm := m.GetGroupManager()
+
+	if got := firstHealthyName(t, m); got != "main-1" {
+		t.Fatalf("Expected traffic on the main group, got %s", got)
+	}
+
+	// Force-activating the lower priority group moves traffic there
+	if !gm.ActivateGroup("backup") {
+		t.Fatal("Expected backup group to exist")
+	}
+	if got := firstHealthyName(t, m); got != "backup-1" {
+		t.Errorf("Expected traffic on the activated backup group, got %s", got)
+	}
+	if gm.PreferredGroup() != "backup" {
+		t.Errorf("Expected backup to be the preferred group, got %q", gm.PreferredGroup())
+	}
+
+	// A preferred group that cools down falls back to the priority order
+	if !gm.StartGroupCooldown("backup") {
+		t.Fatal("Expected backup group to exist")
+	}
+	if gm.PreferredGroup() != "" {
+		t.Errorf("Expected the preference to end with the cooldown, got %q", gm.PreferredGroup())
+	}
+	if got := firstHealthyName(t, m); got != "main-1" {
+		t.Errorf("Expected traffic back on the main group, got %s", got)
+	}
+
+	// Activating a cooled group ends its cooldown
+	gm.StartGroupCooldown("main")
+	if !gm.IsGroupInCooldown("main") {
+		t.Fatal("Expected main group in cooldown")
+	}
+	gm.ActivateGroup("main")
+	if gm.IsGroupInCooldown("main") {
+		t.Error("Expected activation to clear the cooldown")
+	}
+	if got := firstHealthyName(t, m); got != "main-1" {
+		t.Errorf("Expected traffic on the activated main group, got %s", got)
+	}
+
+	if gm.ActivateGroup("missing") || gm.StartGroupCooldown("missing") {
+		t.Error("Expected unknown groups to be rejected")
+	}
+}
+
+func TestCooldownExpiryAnnouncedOnce(t *testing.T) {
+	m := NewManager(newGroupStrategyTestConfig("priority"))
+	var exits atomic.Int32
+	m.SetEventPublisher(func(event notify.Event) {
+		if event.Type == config.NotifyEventGroupCooldownExit {
+			exits.Add(1)
+		}
+	})
+	gm := m.GetGroupManager()
+	gm.RestoreGroupCooldown("main", time.Now().Add(20*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+
+	// Concurrent selections all notice the expiry; only one of them applies it
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.GetHealthyEndpoints()
+		}()
+	}
+	wg.Wait()
+
+	if got := exits.Load(); got != 1 {
+		t.Errorf("Expected one cooldown exit event, got %d", got)
+	}
+	if gm.IsGroupInCooldown("main") {
+		t.Error("Expected the main group to have left its cooldown")
+	}
+}
@@ -0,0 +1,132 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+
+	"endpoint_forwarder/config"
+)
+
+// GetGroupStrategy returns the strategy used to order endpoints within a group:
+// the group's own setting, or the global strategy when none is configured
+func (gm *GroupManager) GetGroupStrategy(groupName string) string {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	if group, exists := gm.groups[groupName]; exists && group.Strategy != "" {
+		return group.Strategy
+	}
+	return gm.config.Strategy.Type
+}
+
+// groupSelection returns a group's configured strategy and its round-robin counter
+func (gm *GroupManager) groupSelection(groupName string) (string, *atomic.Uint64) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	group, exists := gm.groups[groupName]
+	if !exists {
+		return "", nil
+	}
+	return group.Strategy, group.rrCounter
+}
+
+// applyGroupStrategies reorders healthy endpoints of each group according to the group's
+// strategy. Groups keep their relative order; groups without a strategy keep the global order.
+func (m *Manager) applyGroupStrategies(healthy []*Endpoint, showLogs bool, trace *SelectionTrace) []*Endpoint {
+	if len(healthy) < 2 {
+		return healthy
+	}
+
+	var groupOrder []string
+	byGroup := make(map[string][]*Endpoint)
+	for _, ep := range healthy {
+		groupName := ep.Config.Group
+		if groupName == "" {
+			groupName = "Default"
+		}
+		if _, exists := byGroup[groupName]; !exists {
+			groupOrder = append(groupOrder, groupName)
+		}
+		byGroup[groupName] = append(byGroup[groupName], ep)
+	}
+
+	ordered := make([]*Endpoint, 0, len(healthy))
+	for _, groupName := range groupOrder {
+		strategy, counter := m.groupManager.groupSelection(groupName)
+		ordered = append(ordered, orderGroupEndpoints(groupName, byGroup[groupName], strategy, counter, showLogs, trace)...)
+	}
+	return ordered
+}
+
+// orderGroupEndpoints orders one group's healthy endpoints by the given strategy. With a trace
+// the round-robin counter is only looked at, not advanced.
+func orderGroupEndpoints(groupName string, endpoints []*Endpoint, strategy string, counter *atomic.Uint64, showLogs bool, trace *SelectionTrace) []*Endpoint {
+	if len(endpoints) < 2 {
+		return endpoints
+	}
+
+	switch strategy {
+	case config.GroupStrategyPriority:
+		sortByPriority(endpoints)
+		if trace != nil {
+			trace.Note("group %s priority: ordered by endpoint priority", groupName)
+		}
+
+	case config.GroupStrategyRoundRobin:
+		if counter == nil {
+			return endpoints
+		}
+		sortByPriority(endpoints)
+		position := counter.Load()
+		if trace == nil {
+			position = counter.Add(1) - 1
+		}
+		idx := int(position % uint64(len(endpoints)))
+
+		rotated := make([]*Endpoint, len(endpoints))
+		copy(rotated, endpoints[idx:])
+		copy(rotated[len(endpoints)-idx:], endpoints[:idx])
+		endpoints = rotated
+
+		if showLogs {
+			slog.Info(fmt.Sprintf("🔄 [组策略] 组 %s 轮询选择端点: %s (轮询索引: %d)",
+				groupName, endpoints[0].Config.Name, idx))
+		}
+		if trace != nil {
+			trace.Note("group %s round-robin: rotation starts at %s (index %d)", groupName, endpoints[0].Config.Name, idx)
+		}
+
+	case config.GroupStrategyLeastBusy:
+		// Snapshot in-flight counts so the ordering is consistent while sorting
+		inFlight := make(map[*Endpoint]int64, len(endpoints))
+		for _, ep := range endpoints {
+			inFlight[ep] = ep.InFlight()
+		}
+		sort.SliceStable(endpoints, func(i, j int) bool {
+			if inFlight[endpoints[i]] != inFlight[endpoints[j]] {
+				return inFlight[endpoints[i]] < inFlight[endpoints[j]]
+			}
+			return endpoints[i].Config.Priority < endpoints[j].Config.Priority
+		})
+
+		if showLogs {
+			slog.Info(fmt.Sprintf("⚖️ [组策略] 组 %s 最空闲端点: %s (进行中请求: %d)",
+				groupName, endpoints[0].Config.Name, inFlight[endpoints[0]]))
+		}
+		if trace != nil {
+			trace.Note("group %s least-busy: %s has the fewest requests in flight (%d)", groupName, endpoints[0].Config.Name, inFlight[endpoints[0]])
+		}
+	}
+
+	return endpoints
+}
+
+// sortByPriority sorts endpoints by endpoint priority (lower number = higher priority)
+func sortByPriority(endpoints []*Endpoint) {
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Config.Priority < endpoints[j].Config.Priority
+	})
+}
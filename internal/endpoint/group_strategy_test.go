@@ -0,0 +1,100 @@
+package endpoint
+
+import (
+	"testing"
+
+	"endpoint_forwarder/config"
+)
+
+func newGroupStrategyTestConfig(strategy string) *config.Config {
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "main-2", URL: "http://127.0.0.1:1", Priority: 2, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "main-3", URL: "http://127.0.0.1:1", Priority: 3, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "backup-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "backup", GroupPriority: 2},
+	)
+	cfg.Groups = map[string]config.GroupSettings{"main": {Strategy: strategy}}
+	return cfg
+}
+
+func firstHealthyName(t *testing.T, m *Manager) string {
+	t.Helper()
+	healthy := m.GetHealthyEndpoints()
+	if len(healthy) == 0 {
+		t.Fatal("Expected healthy endpoints")
+	}
+	return healthy[0].Config.Name
+}
+
+func TestGroupRoundRobinStrategy(t *testing.T) {
+	m := NewManager(newGroupStrategyTestConfig("round-robin"))
+
+	if got := m.GetGroupManager().GetGroupStrategy("main"); got != "round-robin" {
+		t.Fatalf("Expected main group strategy round-robin, got %s", got)
+	}
+	if got := m.GetGroupManager().GetGroupStrategy("backup"); got != "priority" {
+		t.Errorf("Expected backup group to follow the global strategy, got %s", got)
+	}
+
+	expected := []string{"main-1", "main-2", "main-3", "main-1"}
+	for i, name := range expected {
+		if got := firstHealthyName(t, m); got != name {
+			t.Errorf("Request %d: expected %s, got %s", i+1, name, got)
+		}
+	}
+
+	// Reloading the config starts the rotation over
+	m.UpdateConfig(newGroupStrategyTestConfig("round-robin"))
+	for _, name := range []string{"main-1", "main-2", "main-3"} {
+		setEndpointHealthy(m, name, true) // The post-reload health check cannot reach the fake upstreams
+	}
+	if got := firstHealthyName(t, m); got != "main-1" {
+		t.Errorf("Expected rotation to restart at main-1 after reload, got %s", got)
+	}
+}
+
+func TestGroupLeastBusyStrategy(t *testing.T) {
+	m := NewManager(newGroupStrategyTestConfig("least-busy"))
+
+	// All idle: ties are broken by priority
+	if got := firstHealthyName(t, m); got != "main-1" {
+		t.Errorf("Expected main-1 when all endpoints are idle, got %s", got)
+	}
+
+	release1, _ := m.GetEndpointByNameAny("main-1").TryAcquire()
+	defer release1()
+	releaseA, _ := m.GetEndpointByNameAny("main-2").TryAcquire()
+	defer releaseA()
+	releaseB, _ := m.GetEndpointByNameAny("main-2").TryAcquire()
+	defer releaseB()
+
+	healthy := m.GetHealthyEndpoints()
+	order := make([]string, 0, len(healthy))
+	for _, ep := range healthy {
+		order = append(order, ep.Config.Name)
+	}
+	want := []string{"main-3", "main-1", "main-2"}
+	if len(order) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, order)
+		}
+	}
+}
+
+func TestGroupStrategyFromFirstEndpoint(t *testing.T) {
+	cfg := newGroupStrategyTestConfig("")
+	cfg.Groups = nil
+	cfg.Strategy.Type = "fastest"
+	cfg.Endpoints[0].GroupStrategy = "priority"
+
+	m := NewManager(cfg)
+	if got := m.GetGroupManager().GetGroupStrategy("main"); got != "priority" {
+		t.Errorf("Expected group-strategy on the first endpoint to apply, got %s", got)
+	}
+	if got := m.GetGroupManager().GetGroupStrategy("backup"); got != "fastest" {
+		t.Errorf("Expected backup group to follow the global strategy, got %s", got)
+	}
+}
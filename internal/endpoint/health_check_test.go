@@ -1,7 +1,15 @@
 package endpoint
 
 import (
+	"context"
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/notify"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -71,4 +79,164 @@ func TestConsecutiveFailureCounter(t *testing.T) {
 			t.Errorf("Expected ConsecutiveFails to be %d, got %d", i, endpoint.Status.ConsecutiveFails)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestHealthAndFastTestOverUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "ef-sock")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "health.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	var paths []string
+	var pathsMu sync.Mutex
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathsMu.Lock()
+		paths = append(paths, r.URL.Path)
+		pathsMu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "fastest", FastTestEnabled: true, FastTestTimeout: time.Second, FastTestPath: "/v1/models"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/health"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "socket", URL: "unix://" + socketPath, UnixPathPrefix: "/api", Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		},
+	}
+	manager := NewManager(cfg)
+	ep := manager.GetEndpointByName("socket")
+
+	// Fail the endpoint first so a successful check is observable
+	manager.updateEndpointStatus(ep, false, 0)
+	manager.checkEndpointHealth(ep)
+	if !ep.IsHealthy() {
+		t.Fatal("Expected health check over the unix socket to succeed")
+	}
+
+	result := manager.fastTester.testSingleEndpoint(context.Background(), ep)
+	if !result.Success {
+		t.Fatalf("Expected fast test over the unix socket to succeed, got %v", result.Error)
+	}
+
+	pathsMu.Lock()
+	defer pathsMu.Unlock()
+	if len(paths) != 2 || paths[0] != "/api/health" || paths[1] != "/api/v1/models" {
+		t.Errorf("Expected prefixed health and fast test paths, got %v", paths)
+	}
+}
+
+func TestHealthAndCooldownEventsPublished(t *testing.T) {
+	var events []notify.Event
+	publish := notify.Publisher(func(e notify.Event) { events = append(events, e) })
+
+	endpoint := &Endpoint{
+		Config: config.EndpointConfig{Name: "primary", Group: "main", URL: "https://api.example.com"},
+		Status: EndpointStatus{Healthy: true},
+	}
+	cfg := &config.Config{Group: config.GroupConfig{Cooldown: 20 * time.Millisecond, MaxRetries: 1}}
+	manager := &Manager{config: cfg, groupManager: NewGroupManager(cfg)}
+	manager.groupManager.UpdateGroups([]*Endpoint{endpoint})
+	manager.SetEventPublisher(publish)
+
+	// Only transitions are published, not repeated failures
+	manager.updateEndpointStatus(endpoint, false, 10*time.Millisecond)
+	manager.updateEndpointStatus(endpoint, false, 10*time.Millisecond)
+	manager.updateEndpointStatus(endpoint, true, 10*time.Millisecond)
+
+	manager.groupManager.SetGroupCooldown("main")
+	time.Sleep(30 * time.Millisecond)
+	manager.groupManager.GetActiveGroups()
+
+	want := []string{
+		config.NotifyEventEndpointUnhealthy,
+		config.NotifyEventEndpointHealthy,
+		config.NotifyEventGroupCooldownEnter,
+		config.NotifyEventGroupCooldownExit,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected events %v, got %+v", want, events)
+	}
+	for i, event := range events {
+		if event.Type != want[i] || event.Time.IsZero() {
+			t.Errorf("Event %d: expected %s, got %+v", i, want[i], event)
+		}
+	}
+	if events[0].Subject != "primary" || events[0].Details["group"] != "main" || events[2].Subject != "main" {
+		t.Errorf("Unexpected event subjects: %+v", events)
+	}
+}
+
+func TestAllEndpointsDownEventPublished(t *testing.T) {
+	var events []notify.Event
+	publish := notify.Publisher(func(e notify.Event) { events = append(events, e) })
+
+	primary := &Endpoint{Config: config.EndpointConfig{Name: "primary", Group: "main"}, Status: EndpointStatus{Healthy: true}}
+	backup := &Endpoint{Config: config.EndpointConfig{Name: "backup", Group: "main"}, Status: EndpointStatus{Healthy: true}}
+	other := &Endpoint{Config: config.EndpointConfig{Name: "other", Group: "spare"}, Status: EndpointStatus{Healthy: true}}
+	cfg := &config.Config{}
+	manager := &Manager{config: cfg, groupManager: NewGroupManager(cfg), endpoints: []*Endpoint{primary, backup, other}}
+	manager.SetEventPublisher(publish)
+
+	manager.updateEndpointStatus(primary, false, 10*time.Millisecond)
+	manager.updateEndpointStatus(backup, false, 10*time.Millisecond)
+	manager.updateEndpointStatus(backup, false, 10*time.Millisecond)
+
+	var down []notify.Event
+	for _, event := range events {
+		if event.Type == config.NotifyEventAllEndpointsDown {
+			down = append(down, event)
+		}
+	}
+	if len(down) != 1 || down[0].Subject != "main" || down[0].Details["endpoints"] != "2" {
+		t.Fatalf("Expected one all_endpoints_down event for group main, got %+v", down)
+	}
+}
+
+func TestHealthThresholdsPreventFlapping(t *testing.T) {
+	cfg := newTestConfig(config.EndpointConfig{Name: "primary", URL: "https://primary.example.com", Group: "main"})
+	cfg.Health.HealthyThreshold = 2
+	cfg.Health.UnhealthyThreshold = 3
+	manager := NewManager(cfg)
+	primary := manager.GetEndpointByNameAny("primary")
+
+	// A backend answering alternately 200 and 500 stays healthy
+	for i := 0; i < 10; i++ {
+		status := http.StatusOK
+		if i%2 == 0 {
+			status = http.StatusInternalServerError
+		}
+		manager.RecordRequestOutcome("primary", status, nil)
+		if !primary.IsHealthy() {
+			t.Fatalf("Expected the endpoint to stay healthy, flipped after result %d", i+1)
+		}
+	}
+
+	// A run of failed requests marks it unhealthy between health checks
+	for i := 0; i < 3; i++ {
+		manager.RecordRequestOutcome("primary", http.StatusBadGateway, nil)
+	}
+	if primary.IsHealthy() || primary.GetStatus().ConsecutiveFails != 3 {
+		t.Fatalf("Expected 3 failed requests to mark the endpoint unhealthy, got %+v", primary.GetStatus())
+	}
+
+	// One lucky check is not enough to bring it back, nor is an alternating backend
+	manager.updateEndpointStatus(primary, true, 10*time.Millisecond)
+	manager.RecordRequestOutcome("primary", http.StatusInternalServerError, nil)
+	manager.updateEndpointStatus(primary, true, 10*time.Millisecond)
+	if primary.IsHealthy() || primary.GetStatus().ConsecutiveOKs != 1 {
+		t.Fatalf("Expected the endpoint to stay unhealthy with 1 good result in a row, got %+v", primary.GetStatus())
+	}
+	manager.RecordRequestOutcome("primary", http.StatusOK, nil)
+	if !primary.IsHealthy() {
+		t.Error("Expected 2 good results in a row to mark the endpoint healthy")
+	}
+}
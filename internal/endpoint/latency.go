@@ -0,0 +1,94 @@
+package endpoint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recordLatency folds a response time sample into the endpoint's moving average
+func (e *Endpoint) recordLatency(sample time.Duration, alpha float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.Status.LatencySamples == 0 {
+		e.Status.LatencyEWMA = sample
+	} else {
+		e.Status.LatencyEWMA = time.Duration(alpha*float64(sample) + (1-alpha)*float64(e.Status.LatencyEWMA))
+	}
+	e.Status.LastLatency = sample
+	e.Status.LatencySamples++
+}
+
+// RankingLatency returns the latency the fastest strategy ranks the endpoint by: the moving
+// average once minSamples samples were taken, the latest sample before that, and the last
+// health check's response time when no sample was taken yet
+func (s EndpointStatus) RankingLatency(minSamples int) time.Duration {
+	switch {
+	case s.LatencySamples == 0:
+		return s.ResponseTime
+	case s.LatencySamples < minSamples:
+		return s.LastLatency
+	default:
+		return s.LatencyEWMA
+	}
+}
+
+// RecordLatency adds the response time of a request proxied to an endpoint (time until
+// its response headers arrived) to the endpoint's moving average
+func (m *Manager) RecordLatency(name string, latency time.Duration) {
+	if endpoint := m.GetEndpointByNameAny(name); endpoint != nil && latency > 0 {
+		endpoint.recordLatency(latency, m.GetConfig().Strategy.LatencyAlpha())
+	}
+}
+
+// rankingLatency returns the latency an endpoint is ranked by under the fastest strategy
+func (m *Manager) rankingLatency(endpoint *Endpoint) time.Duration {
+	return endpoint.GetStatus().RankingLatency(m.GetConfig().Strategy.MinSamples)
+}
+
+// orderByLatency sorts endpoints fastest first. The endpoint that was fastest last time stays
+// in front unless another one is at least strategy.sticky_factor faster, so jitter doesn't
+// make requests hop between endpoints of similar speed. With a trace the endpoint kept in
+// front is not updated.
+func (m *Manager) orderByLatency(endpoints []*Endpoint, trace *SelectionTrace) []*Endpoint {
+	latencies := make(map[*Endpoint]time.Duration, len(endpoints))
+	for _, ep := range endpoints {
+		latencies[ep] = m.rankingLatency(ep)
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return latencies[endpoints[i]] < latencies[endpoints[j]]
+	})
+
+	m.fastestMutex.Lock()
+	defer m.fastestMutex.Unlock()
+	sticky := false
+	if len(endpoints) > 1 && endpoints[0].Config.Name != m.lastFastest {
+		for i, ep := range endpoints {
+			if ep.Config.Name != m.lastFastest {
+				continue
+			}
+			if float64(latencies[endpoints[0]]) > float64(latencies[ep])*(1-m.GetConfig().Strategy.Stickiness()) {
+				copy(endpoints[1:i+1], endpoints[:i])
+				endpoints[0] = ep
+				sticky = true
+			}
+			break
+		}
+	}
+	if trace != nil {
+		ranking := make([]string, len(endpoints))
+		for i, ep := range endpoints {
+			ranking[i] = fmt.Sprintf("%s %dms", ep.Config.Name, latencies[ep].Milliseconds())
+		}
+		trace.Note("fastest strategy: ranked by latency average (%s)", strings.Join(ranking, ", "))
+		if sticky {
+			trace.Note("fastest strategy: %s stays in front, no endpoint is sticky_factor faster", m.lastFastest)
+		}
+		return endpoints
+	}
+	if len(endpoints) > 0 {
+		m.lastFastest = endpoints[0].Config.Name
+	}
+	return endpoints
+}
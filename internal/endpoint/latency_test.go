@@ -0,0 +1,112 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func newLatencyTestManager(t *testing.T) *Manager {
+	t.Helper()
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "a", URL: "https://a.example.com", Timeout: time.Second},
+		config.EndpointConfig{Name: "b", URL: "https://b.example.com", Timeout: time.Second},
+	)
+	cfg.Strategy = config.StrategyConfig{Type: "fastest", EWMAAlpha: 0.3, MinSamples: 3}
+	return NewManager(cfg)
+}
+
+func fastestName(m *Manager) string {
+	return m.sortHealthyEndpoints(m.GetAllEndpoints(), false, nil)[0].Config.Name
+}
+
+func TestLatencyOrderingIgnoresJitter(t *testing.T) {
+	m := newLatencyTestManager(t)
+
+	// a averages 100ms, b 130ms, both with ±40ms of jitter that often puts b ahead on a single sample
+	jitter := []time.Duration{40, -40, 30, -30, 40, -20, 10, -40, 35, -35, 25, -25}
+	var order []string
+	for _, j := range jitter {
+		m.RecordLatency("a", (100+j)*time.Millisecond)
+		m.RecordLatency("b", (130-j)*time.Millisecond)
+		order = append(order, fastestName(m))
+	}
+
+	// Once warmed up (min_samples), a stays the fastest endpoint
+	for i := 2; i < len(order); i++ {
+		if order[i] != "a" {
+			t.Fatalf("Expected a stable fastest endpoint under jitter, got %v", order)
+		}
+	}
+}
+
+func TestLatencyOrderingTracksSustainedSlowdown(t *testing.T) {
+	m := newLatencyTestManager(t)
+	for i := 0; i < 5; i++ {
+		m.RecordLatency("a", 100*time.Millisecond)
+		m.RecordLatency("b", 150*time.Millisecond)
+	}
+	if got := fastestName(m); got != "a" {
+		t.Fatalf("Expected a first, got %s", got)
+	}
+
+	// One slow sample is not enough to switch
+	m.RecordLatency("a", 300*time.Millisecond)
+	if got := fastestName(m); got != "a" {
+		t.Errorf("Expected a single slow sample to keep a first, got %s", got)
+	}
+
+	// A sustained slowdown is
+	for i := 0; i < 5; i++ {
+		m.RecordLatency("a", 300*time.Millisecond)
+	}
+	if got := fastestName(m); got != "b" {
+		t.Errorf("Expected b first after a's sustained slowdown, got %s", got)
+	}
+}
+
+func TestLatencyStickiness(t *testing.T) {
+	m := newLatencyTestManager(t)
+	for i := 0; i < 3; i++ {
+		m.RecordLatency("a", 100*time.Millisecond)
+		m.RecordLatency("b", 120*time.Millisecond)
+	}
+	if got := fastestName(m); got != "a" {
+		t.Fatalf("Expected a first, got %s", got)
+	}
+
+	// b becomes 5% faster: below the default 10% sticky factor, a stays first
+	for i := 0; i < 20; i++ {
+		m.RecordLatency("b", 95*time.Millisecond)
+	}
+	if got := fastestName(m); got != "a" {
+		t.Errorf("Expected a to stay first while b is less than 10%% faster, got %s", got)
+	}
+
+	// b becomes 30% faster: switch
+	for i := 0; i < 20; i++ {
+		m.RecordLatency("b", 70*time.Millisecond)
+	}
+	if got := fastestName(m); got != "b" {
+		t.Errorf("Expected b first once it is 30%% faster, got %s", got)
+	}
+}
+
+func TestRankingLatencyMinSamples(t *testing.T) {
+	status := EndpointStatus{ResponseTime: 50 * time.Millisecond}
+	if got := status.RankingLatency(3); got != 50*time.Millisecond {
+		t.Errorf("Expected the health check response time without samples, got %v", got)
+	}
+
+	ep := &Endpoint{}
+	ep.recordLatency(100*time.Millisecond, 0.5)
+	ep.recordLatency(200*time.Millisecond, 0.5)
+	if got := ep.GetStatus().RankingLatency(3); got != 200*time.Millisecond {
+		t.Errorf("Expected the latest sample below min_samples, got %v", got)
+	}
+	ep.recordLatency(200*time.Millisecond, 0.5)
+	if got := ep.GetStatus().RankingLatency(3); got != 175*time.Millisecond {
+		t.Errorf("Expected the moving average at min_samples, got %v", got)
+	}
+}
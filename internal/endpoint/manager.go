@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"reflect"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/notify"
 	"endpoint_forwarder/internal/transport"
 )
 
@@ -18,14 +21,36 @@ type EndpointStatus struct {
 	Healthy          bool
 	LastCheck        time.Time
 	ResponseTime     time.Duration
-	ConsecutiveFails int
+	ConsecutiveFails int       // Consecutive failed checks or requests, counted toward health.unhealthy_threshold
+	ConsecutiveOKs   int       // Consecutive good checks or requests, counted toward health.healthy_threshold
+	Disabled         bool      // Maintenance mode: skipped by selection, fast tests and health checks
+	RateLimitedUntil time.Time // Upstream asked us to back off (429/Retry-After): skipped by selection until then
+	AuthExpiry       time.Time // OAuth2 access token expiry (zero for static auth)
+	AuthError        string    // Last OAuth2 refresh error; unhealthy while no valid token is left
+	BreakerOpenUntil time.Time // Circuit breaker open until then, half-open afterwards (zero = closed)
+	BreakerFailures  int       // Consecutive failed requests counted by the circuit breaker
+
+	LatencyEWMA    time.Duration // Moving average of probe and request latencies, ranks the fastest strategy
+	LastLatency    time.Duration // Latest latency sample
+	LatencySamples int           // Latency samples taken
+
+	breakerProbes     int // Half-open probe requests in flight
+	breakerProbeLimit int // Half-open probe requests allowed at a time
+}
+
+// IsRateLimited reports whether the endpoint is still inside its rate-limit window
+func (s EndpointStatus) IsRateLimited(now time.Time) bool {
+	return now.Before(s.RateLimitedUntil)
 }
 
 // Endpoint represents an endpoint with its configuration and status
 type Endpoint struct {
-	Config config.EndpointConfig
-	Status EndpointStatus
-	mutex  sync.RWMutex
+	Config   config.EndpointConfig
+	Status   EndpointStatus
+	mutex    sync.RWMutex
+	inFlight *slotCounter // Requests currently proxied to this endpoint, shared across config reloads
+	rate     *rateBucket  // Rate limit bucket shared across config reloads, nil when unlimited
+	tokens   *tokenPool   // Rotated tokens shared across config reloads, nil without a tokens list
 }
 
 // Manager manages endpoints and their health status
@@ -33,15 +58,53 @@ type Manager struct {
 	endpoints     []*Endpoint
 	config        *config.Config
 	client        *http.Client
+	configMutex   sync.RWMutex // Mutex for endpoints, config and client, swapped together by config updates
+	updateMutex   sync.Mutex   // Serializes config updates, reloads and runtime edits alike
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
 	fastTester    *FastTester
+	prober        *Prober // Probes shared by health checks and fast tests, plus passive results
 	groupManager  *GroupManager
 	roundRobinIdx int          // Round-robin index for load balancing
 	rrMutex       sync.Mutex   // Mutex for round-robin index
 	configVersion int64        // Configuration version for detecting updates
 	versionMutex  sync.RWMutex // Mutex for config version
+	lastFastest   string       // Endpoint the fastest strategy put first last time
+	fastestMutex  sync.Mutex   // Mutex for lastFastest
+
+	stateStore             *StateStore                  // Optional runtime state persistence
+	priorityOverrides      map[string]*priorityOverride // Runtime priority edits not saved to the config file
+	groupPriorityOverrides map[string]*priorityOverride // Runtime group priority edits not saved to the config file, by group
+	stateMutex             sync.Mutex                   // Mutex for priority overrides and the primary override
+	primary                *primaryOverride             // -p command line override, nil when none is active
+	primaryEnded           PrimaryStatus                // How the last primary override ended
+
+	inFlightCounters map[string]*slotCounter // Per-endpoint in-flight request counters, keyed by name
+	inFlightMutex    sync.Mutex              // Mutex for in-flight counters
+
+	rateBuckets map[string]*rateBucket // Per-endpoint rate limit buckets, keyed by name
+	rateMutex   sync.Mutex             // Mutex for rate limit buckets
+
+	tokenPools     map[string]*tokenPool // Per-endpoint rotated tokens, keyed by name
+	tokenPoolMutex sync.Mutex            // Mutex for token pools
+
+	budgets     map[string]*tokenBudget // Token budget counters, keyed by subject and window
+	budgetMutex sync.Mutex              // Mutex for token budgets
+
+	statusGeneration atomic.Uint64 // Bumped whenever endpoint status or configuration changes
+
+	tokenSources map[string]*OAuth2TokenSource // OAuth2 token sources keyed by endpoint name
+	tokenMutex   sync.Mutex                    // Mutex for token sources
+	started      atomic.Bool                   // Set by Start; new token sources refresh in the background
+
+	publish notify.Publisher // Receives health transition events, set before Start
+}
+
+// priorityOverride remembers a runtime priority edit together with the config value it replaced
+type priorityOverride struct {
+	Original int
+	Priority int
 }
 
 // NewManager creates a new endpoint manager
@@ -62,11 +125,14 @@ func NewManager(cfg *config.Config) *Manager {
 			Timeout:   cfg.Health.Timeout,
 			Transport: httpTransport,
 		},
-		ctx:           ctx,
-		cancel:        cancel,
-		fastTester:    NewFastTester(cfg),
-		groupManager:  NewGroupManager(cfg),
-		configVersion: time.Now().UnixNano(), // Initialize with current timestamp
+		ctx:                    ctx,
+		cancel:                 cancel,
+		fastTester:             NewFastTester(cfg),
+		groupManager:           NewGroupManager(cfg),
+		configVersion:          time.Now().UnixNano(), // Initialize with current timestamp
+		priorityOverrides:      make(map[string]*priorityOverride),
+		groupPriorityOverrides: make(map[string]*priorityOverride),
+		tokenSources:           make(map[string]*OAuth2TokenSource),
 	}
 
 	// Initialize endpoints
@@ -76,12 +142,19 @@ func NewManager(cfg *config.Config) *Manager {
 			Status: EndpointStatus{
 				Healthy:   true, // Start optimistic
 				LastCheck: time.Now(),
+				Disabled:  endpointCfg.Disabled,
 			},
+			inFlight: manager.inFlightCounter(endpointCfg.Name),
+			rate:     manager.rateBucketFor(endpointCfg),
+			tokens:   manager.tokenPoolFor(endpointCfg),
 		}
 		manager.endpoints = append(manager.endpoints, endpoint)
 	}
 
-	// Set manager reference in fast tester for dynamic token resolution
+	manager.syncTokenSources(cfg)
+
+	// Set manager reference in fast tester for dynamic token resolution and the shared prober
+	manager.prober = NewProber(manager)
 	manager.fastTester.SetManager(manager)
 
 	// Initialize groups from endpoints
@@ -90,34 +163,136 @@ func NewManager(cfg *config.Config) *Manager {
 	return manager
 }
 
+// SetEventPublisher sets where endpoint health and group cooldown transitions are published.
+// Call it before Start.
+func (m *Manager) SetEventPublisher(publish notify.Publisher) {
+	m.publish = publish
+	m.groupManager.SetEventPublisher(publish)
+}
+
 // Start starts the health checking routine
 func (m *Manager) Start() {
+	m.started.Store(true)
+	m.startTokenSources()
+
 	m.wg.Add(1)
 	go m.healthCheckLoop()
 }
 
 // Stop stops the health checking routine
 func (m *Manager) Stop() {
+    // Under updateMutex, so no config update starts rechecks after the wait below
+    m.updateMutex.Lock()
     m.cancel()
+    m.updateMutex.Unlock()
     m.wg.Wait()
+
+    // A timed primary override must not fire after shutdown
+    m.stateMutex.Lock()
+    if m.primary != nil {
+        m.primary.stop()
+    }
+    m.stateMutex.Unlock()
+
+    // Write out any pending runtime state
+    if m.stateStore != nil {
+        if err := m.stateStore.Flush(); err != nil {
+            slog.Error(fmt.Sprintf("❌ [运行时状态] 保存状态文件失败: %v", err))
+        }
+    }
 }
 
 // UpdateConfig updates the manager configuration and recreates endpoints
 func (m *Manager) UpdateConfig(cfg *config.Config) {
-	m.config = cfg
+	m.updateMutex.Lock()
+	defer m.updateMutex.Unlock()
+	m.updateConfig(cfg)
+}
+
+// updateConfig swaps in cfg and recreates endpoints (caller holds updateMutex)
+func (m *Manager) updateConfig(cfg *config.Config) {
+	oldConfig, oldClient := m.configAndClient()
+	oldCredentials := m.resolveCredentials()
+
+	// Re-apply runtime priority overrides on top of the new config
+	m.stateMutex.Lock()
+	m.applyPriorityOverrides(cfg)
+	m.applyPrimaryOverride(cfg)
+	m.stateMutex.Unlock()
+
+	// Remember old endpoints so runtime maintenance state survives unrelated reloads
+	current := m.GetAllEndpoints()
+	oldEndpoints := make(map[string]*Endpoint, len(current))
+	for _, ep := range current {
+		oldEndpoints[ep.Config.Name] = ep
+	}
+
+	// Keep OAuth2 token sources whose auth settings did not change
+	m.syncTokenSources(cfg)
 
 	// Recreate endpoints with new configuration
 	endpoints := make([]*Endpoint, len(cfg.Endpoints))
 	for i, epCfg := range cfg.Endpoints {
+		status := EndpointStatus{
+			Healthy:   true,
+			LastCheck: time.Now(),
+			Disabled:  epCfg.Disabled,
+		}
+		old, exists := oldEndpoints[epCfg.Name]
+		// Maintenance mode set at runtime survives reloads, unless the reload changes the endpoint's disabled setting
+		if exists && old.Config.Disabled == epCfg.Disabled {
+			status.Disabled = old.IsDisabled()
+		}
+		if exists && reflect.DeepEqual(old.Config, epCfg) {
+			// Endpoint config untouched, keep its health and runtime rate-limit and circuit breaker state
+			oldStatus := old.GetStatus()
+			status.Healthy = oldStatus.Healthy
+			status.LastCheck = oldStatus.LastCheck
+			status.ResponseTime = oldStatus.ResponseTime
+			status.LatencyEWMA = oldStatus.LatencyEWMA
+			status.LastLatency = oldStatus.LastLatency
+			status.LatencySamples = oldStatus.LatencySamples
+			status.ConsecutiveFails = oldStatus.ConsecutiveFails
+			status.ConsecutiveOKs = oldStatus.ConsecutiveOKs
+			status.RateLimitedUntil = oldStatus.RateLimitedUntil
+			if cfg.CircuitBreaker.Enabled {
+				status.BreakerOpenUntil = oldStatus.BreakerOpenUntil
+				status.BreakerFailures = oldStatus.BreakerFailures
+				status.breakerProbeLimit = cfg.CircuitBreaker.HalfOpenMaxRequests
+			}
+		}
+
 		endpoints[i] = &Endpoint{
-			Config: epCfg,
-			Status: EndpointStatus{
-				Healthy:   true,
-				LastCheck: time.Now(),
-			},
+			Config:   epCfg,
+			Status:   status,
+			inFlight: m.inFlightCounter(epCfg.Name),
+			rate:     m.rateBucketFor(epCfg),
+			tokens:   m.tokenPoolFor(epCfg),
+		}
+		if source := m.tokenSource(epCfg.Name); source != nil {
+			applyAuthStatus(&endpoints[i].Status, source.Status(), time.Now())
+		}
+	}
+
+	// Recreate transport with new proxy configuration
+	client := oldClient
+	if transport, err := transport.CreateTransport(cfg); err == nil {
+		client = &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Health.Timeout,
 		}
 	}
+
+	m.configMutex.Lock()
+	m.config = cfg
 	m.endpoints = endpoints
+	m.client = client
+	m.configMutex.Unlock()
+
+	m.pruneInFlightCounters(endpoints)
+	m.pruneRateBuckets(endpoints)
+	m.pruneTokenPools(endpoints)
+	m.pruneTokenBudgets(cfg)
 
 	// Reset Round-Robin index when configuration changes to ensure fresh start
 	// This only affects round-robin strategy and doesn't impact priority or fastest strategies
@@ -132,27 +307,66 @@ func (m *Manager) UpdateConfig(cfg *config.Config) {
 
     // Update group manager with new config and endpoints
     m.groupManager.UpdateConfig(cfg)
-    m.groupManager.UpdateGroups(m.endpoints)
+    m.groupManager.UpdateGroups(endpoints)
 
     // Reset group states (cooldowns/retries) on configuration change to avoid stale failures persisting
     m.groupManager.ResetAllStates()
+    // Groups over a block budget stay aside until their window resets
+    m.applyBudgetCooldowns()
 
     // Update fast tester with new config
     if m.fastTester != nil {
         m.fastTester.UpdateConfig(cfg)
     }
 
-	// Recreate transport with new proxy configuration
-	if transport, err := transport.CreateTransport(cfg); err == nil {
-		m.client = &http.Client{
-			Transport: transport,
-			Timeout:   cfg.Health.Timeout,
-		}
+	// Check new and changed endpoints right away; all of them when the probe settings changed
+	changed := m.changedEndpoints(oldEndpoints, oldCredentials)
+	if !reflect.DeepEqual(oldConfig.Health, cfg.Health) || !reflect.DeepEqual(oldConfig.Proxy, cfg.Proxy) {
+		changed = endpoints
 	}
 
-	// Immediately perform health checks on new endpoints to get real status
-	slog.Info("🔄 配置更新后立即执行健康检查")
-	m.performHealthChecks()
+	m.statusGeneration.Add(1)
+	m.saveState()
+
+	m.recheckEndpoints(cfg, client, changed)
+}
+
+// editConfig applies a runtime edit to a copy of the current config and swaps the copy in,
+// so readers of the config never see it change. edit runs under stateMutex and returns false
+// when there is nothing to update.
+func (m *Manager) editConfig(edit func(cfg *config.Config) bool) bool {
+	m.updateMutex.Lock()
+	defer m.updateMutex.Unlock()
+	return m.editConfigLocked(edit)
+}
+
+// editConfigLocked is editConfig for callers holding updateMutex
+func (m *Manager) editConfigLocked(edit func(cfg *config.Config) bool) bool {
+	current := m.GetConfig()
+	cfg := copyConfig(current)
+
+	m.stateMutex.Lock()
+	// The copy already carries the primary override applied to the current config
+	if m.primary != nil && m.primary.applied == current {
+		m.primary.applied = cfg
+	}
+	changed := edit(cfg)
+	if !changed && m.primary != nil && m.primary.applied == cfg {
+		m.primary.applied = current
+	}
+	m.stateMutex.Unlock()
+
+	if changed {
+		m.updateConfig(cfg)
+	}
+	return changed
+}
+
+// copyConfig copies cfg deep enough for runtime edits, which only change endpoint settings
+func copyConfig(cfg *config.Config) *config.Config {
+	copied := *cfg
+	copied.Endpoints = append([]config.EndpointConfig(nil), cfg.Endpoints...)
+	return &copied
 }
 
 // ResetStates resets group cooldown/retry states, clears fast-test cache,
@@ -160,17 +374,26 @@ func (m *Manager) UpdateConfig(cfg *config.Config) {
 func (m *Manager) ResetStates() {
     // Reset groups
     m.groupManager.ResetAllStates()
+    m.applyBudgetCooldowns()
 
     // Reset endpoints to optimistic healthy
     now := time.Now()
-    for _, ep := range m.endpoints {
+    for _, ep := range m.GetAllEndpoints() {
         ep.mutex.Lock()
         ep.Status.Healthy = true
         ep.Status.ConsecutiveFails = 0
+        ep.Status.ConsecutiveOKs = 0
         ep.Status.LastCheck = now
         ep.Status.ResponseTime = 0
+        ep.Status.LatencyEWMA = 0
+        ep.Status.LastLatency = 0
+        ep.Status.LatencySamples = 0
+        ep.Status.RateLimitedUntil = time.Time{}
+        ep.Status.BreakerOpenUntil = time.Time{}
+        ep.Status.BreakerFailures = 0
         ep.mutex.Unlock()
     }
+    m.statusGeneration.Add(1)
 
     // Clear fast test cache
     if m.fastTester != nil {
@@ -191,57 +414,80 @@ func (m *Manager) GetConfigVersion() int64 {
 
 // GetHealthyEndpoints returns a list of healthy endpoints from active groups based on strategy
 func (m *Manager) GetHealthyEndpoints() []*Endpoint {
+	return m.SelectHealthy(nil)
+}
+
+// SelectHealthy is GetHealthyEndpoints recording its decisions in trace when trace is not nil
+func (m *Manager) SelectHealthy(trace *SelectionTrace) []*Endpoint {
 	// First filter by active groups
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	activeEndpoints := m.groupManager.filterActive(m.GetAllEndpoints(), trace)
 
-	// Then filter by health status
-	var healthy []*Endpoint
-	for _, endpoint := range activeEndpoints {
-		endpoint.mutex.RLock()
-		if endpoint.Status.Healthy {
-			healthy = append(healthy, endpoint)
+	// Then filter by health status (skipping endpoints in maintenance mode or rate limited)
+	healthy := m.selectable(activeEndpoints, trace)
+
+	healthy = m.sortHealthyEndpoints(healthy, trace == nil, trace) // Show logs for real requests
+	return m.applyGroupStrategies(healthy, trace == nil, trace)
+}
+
+// GetHealthyEndpointsInGroup returns the healthy endpoints of a single group, whether or not
+// the group is currently active. Used when a request rule routes to a specific group.
+func (m *Manager) GetHealthyEndpointsInGroup(groupName string) []*Endpoint {
+	return m.SelectHealthyInGroup(groupName, nil)
+}
+
+// SelectHealthyInGroup is GetHealthyEndpointsInGroup recording its decisions in trace when
+// trace is not nil
+func (m *Manager) SelectHealthyInGroup(groupName string, trace *SelectionTrace) []*Endpoint {
+	var inGroup []*Endpoint
+	for _, endpoint := range m.GetAllEndpoints() {
+		name := endpoint.Config.Group
+		if name == "" {
+			name = "Default"
+		}
+		if name == groupName {
+			inGroup = append(inGroup, endpoint)
+		} else if trace != nil {
+			trace.Exclude(endpoint, fmt.Sprintf("not in group %s the request is routed to", groupName))
 		}
-		endpoint.mutex.RUnlock()
 	}
+	healthy := m.selectable(inGroup, trace)
 
-	return m.sortHealthyEndpoints(healthy, true) // Show logs by default
+	healthy = m.sortHealthyEndpoints(healthy, trace == nil, trace)
+	return m.applyGroupStrategies(healthy, trace == nil, trace)
 }
 
-// sortHealthyEndpoints sorts healthy endpoints based on strategy with optional logging
-func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool) []*Endpoint {
+// sortHealthyEndpoints sorts healthy endpoints based on strategy with optional logging. With
+// a trace the round-robin position is only looked at, not advanced.
+func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool, trace *SelectionTrace) []*Endpoint {
 	// Sort based on strategy
-	switch m.config.Strategy.Type {
+	switch m.GetConfig().Strategy.Type {
 	case "priority":
 		sort.Slice(healthy, func(i, j int) bool {
 			return healthy[i].Config.Priority < healthy[j].Config.Priority
 		})
+		if trace != nil {
+			trace.Note("priority strategy: ordered by endpoint priority")
+		}
 	case "fastest":
 		// Log endpoint latencies for fastest strategy (only if showLogs is true)
 		if len(healthy) > 1 && showLogs {
-			slog.Info("📊 [Fastest Strategy] 基于健康检查的端点延迟排序:")
+			slog.Info("📊 [Fastest Strategy] 基于延迟均值的端点排序:")
 			for _, ep := range healthy {
-				ep.mutex.RLock()
-				responseTime := ep.Status.ResponseTime
-				ep.mutex.RUnlock()
-				slog.Info(fmt.Sprintf("  ⏱️ %s - 延迟: %dms (来源: 定期健康检查)",
-					ep.Config.Name, responseTime.Milliseconds()))
+				slog.Info(fmt.Sprintf("  ⏱️ %s - 延迟: %dms (来源: 健康检查与请求延迟均值)",
+					ep.Config.Name, m.rankingLatency(ep).Milliseconds()))
 			}
 		}
 
-		sort.Slice(healthy, func(i, j int) bool {
-			healthy[i].mutex.RLock()
-			healthy[j].mutex.RLock()
-			defer healthy[i].mutex.RUnlock()
-			defer healthy[j].mutex.RUnlock()
-			return healthy[i].Status.ResponseTime < healthy[j].Status.ResponseTime
-		})
+		healthy = m.orderByLatency(healthy, trace)
 	case "round-robin":
 		// Round-robin strategy: rotate the starting endpoint
 		if len(healthy) > 1 {
 			m.rrMutex.Lock()
 			// Get current index and increment for next time
 			currentIdx := m.roundRobinIdx % len(healthy)
-			m.roundRobinIdx = (m.roundRobinIdx + 1) % len(healthy)
+			if trace == nil {
+				m.roundRobinIdx = (m.roundRobinIdx + 1) % len(healthy)
+			}
 			m.rrMutex.Unlock()
 
 			// Rotate the slice to start from the selected endpoint
@@ -254,6 +500,9 @@ func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool) []*En
 				slog.Info(fmt.Sprintf("🔄 [Round-Robin Strategy] 选择端点: %s (轮询索引: %d)",
 					healthy[0].Config.Name, currentIdx))
 			}
+			if trace != nil {
+				trace.Note("round-robin strategy: rotation starts at %s (index %d)", healthy[0].Config.Name, currentIdx)
+			}
 		}
 	}
 
@@ -262,32 +511,48 @@ func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool) []*En
 
 // GetFastestEndpointsWithRealTimeTest returns endpoints from active groups sorted by real-time testing
 func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*Endpoint {
-	// First get endpoints from active groups and filter by health
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	return m.SelectFastest(ctx, nil)
+}
 
-	var healthy []*Endpoint
-	for _, endpoint := range activeEndpoints {
-		endpoint.mutex.RLock()
-		if endpoint.Status.Healthy {
-			healthy = append(healthy, endpoint)
-		}
-		endpoint.mutex.RUnlock()
-	}
+// SelectFastest is GetFastestEndpointsWithRealTimeTest recording its decisions in trace when
+// trace is not nil. A traced selection ranks by the fast test results a request would use
+// right now instead of testing.
+func (m *Manager) SelectFastest(ctx context.Context, trace *SelectionTrace) []*Endpoint {
+	showLogs := trace == nil
+
+	// First get endpoints from active groups and filter by health
+	activeEndpoints := m.groupManager.filterActive(m.GetAllEndpoints(), trace)
+	healthy := m.selectable(activeEndpoints, trace)
 
 	if len(healthy) == 0 {
 		return healthy
 	}
 
 	// If not using fastest strategy or fast test disabled, apply sorting with logging
-	if m.config.Strategy.Type != "fastest" || !m.config.Strategy.FastTestEnabled {
-		return m.sortHealthyEndpoints(healthy, true) // Show logs
+	strategy := m.GetConfig().Strategy
+	if strategy.Type != "fastest" || !strategy.FastTestEnabled {
+		return m.applyGroupStrategies(m.sortHealthyEndpoints(healthy, showLogs, trace), showLogs, trace)
 	}
 
 	// Check if we have cached fast test results first
-	testResults, usedCache := m.fastTester.TestEndpointsParallel(ctx, healthy)
+	var testResults []*FastTestResult
+	var usedCache bool
+	if trace == nil {
+		testResults, usedCache = m.fastTester.TestEndpointsParallel(ctx, healthy)
+	} else {
+		var fresh bool
+		if testResults, fresh = m.fastTester.measuredResults(healthy); testResults == nil {
+			trace.Note("fast test: no results yet, a request would test all %d endpoints first; ranked by latency averages instead", len(healthy))
+			return m.applyGroupStrategies(m.sortHealthyEndpoints(healthy, false, trace), false, trace)
+		}
+		if !fresh {
+			trace.Note("fast test: results are older than fast_test_cache_ttl, a request would use them while a new round runs")
+		}
+		usedCache = true
+	}
 
 	// Only show health check sorting if we're NOT using cache
-	if !usedCache && m.config.Strategy.Type == "fastest" && len(healthy) > 1 {
+	if !usedCache && strategy.Type == "fastest" && len(healthy) > 1 {
 		slog.InfoContext(ctx, "📊 [Fastest Strategy] 基于健康检查的活跃组端点延迟排序:")
 		for _, ep := range healthy {
 			ep.mutex.RLock()
@@ -326,12 +591,23 @@ func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*En
 			len(testResults), successCount, len(testResults)-successCount))
 	}
 
-	// Sort by response time (only successful results)
+	// Keep the endpoints that passed, ranked by their latency averages (fed by these tests too)
 	sortedResults := SortByResponseTime(testResults)
 
 	if len(sortedResults) == 0 {
-		slog.WarnContext(ctx, "⚠️ [Fastest Response Mode] 活跃组所有端点测试失败，回退到健康检查模式")
-		return healthy // Fall back to health check results if no fast tests succeeded
+		if showLogs {
+			slog.WarnContext(ctx, "⚠️ [Fastest Response Mode] 活跃组所有端点测试失败，回退到健康检查模式")
+		} else {
+			trace.Note("fast test: every endpoint failed its last test, falling back to health check order")
+		}
+		return m.applyGroupStrategies(healthy, showLogs, trace) // Fall back to health check results if no fast tests succeeded
+	}
+	if trace != nil {
+		for _, result := range testResults {
+			if !result.Success {
+				trace.Exclude(result.Endpoint, fmt.Sprintf("failed its last fast test: %v", result.Error))
+			}
+		}
 	}
 
 	// Convert back to endpoint slice
@@ -339,55 +615,41 @@ func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*En
 	for _, result := range sortedResults {
 		endpoints = append(endpoints, result.Endpoint)
 	}
+	endpoints = m.orderByLatency(endpoints, trace)
 
 	// Log the successful endpoint ranking
-	if len(endpoints) > 0 {
+	if len(endpoints) > 0 && showLogs {
 		// Show the fastest endpoint selection
 		fastestEndpoint := endpoints[0]
-		var fastestTime int64
-		var fastestGroup string
-		for _, result := range sortedResults {
-			if result.Endpoint == fastestEndpoint {
-				fastestTime = result.ResponseTime.Milliseconds()
-				fastestGroup = result.Endpoint.Config.Group
-				break
-			}
-		}
+		fastestTime := m.rankingLatency(fastestEndpoint).Milliseconds()
+		fastestGroup := fastestEndpoint.Config.Group
 
 		cacheIndicator := ""
 		if usedCache {
 			cacheIndicator = " (缓存)"
 		}
 
-		slog.InfoContext(ctx, fmt.Sprintf("🚀 [Fastest Response Mode] 选择最快端点: %s (组: %s, %dms)%s",
+		slog.InfoContext(ctx, fmt.Sprintf("🚀 [Fastest Response Mode] 选择最快端点: %s (组: %s, 延迟均值 %dms)%s",
 			fastestEndpoint.Config.Name, fastestGroup, fastestTime, cacheIndicator))
 		// Show other available endpoints if there are more than one
 		if len(endpoints) > 1 && !usedCache {
 			slog.InfoContext(ctx, "📋 [备用端点] 其他可用端点:")
 			for i := 1; i < len(endpoints); i++ {
 				ep := endpoints[i]
-				var responseTime int64
-				var epGroup string
-				for _, result := range sortedResults {
-					if result.Endpoint == ep {
-						responseTime = result.ResponseTime.Milliseconds()
-						epGroup = result.Endpoint.Config.Group
-						break
-					}
-				}
-				slog.InfoContext(ctx, fmt.Sprintf("  🔄 备用 %s (组: %s) - 响应时间: %dms",
-					ep.Config.Name, epGroup, responseTime))
+				slog.InfoContext(ctx, fmt.Sprintf("  🔄 备用 %s (组: %s) - 延迟均值: %dms",
+					ep.Config.Name, ep.Config.Group, m.rankingLatency(ep).Milliseconds()))
 			}
 		}
 	}
 
-	return endpoints
+	// Groups with their own strategy override the measured ranking
+	return m.applyGroupStrategies(endpoints, showLogs, trace)
 }
 
 // GetEndpointByName returns an endpoint by name, only from active groups
 func (m *Manager) GetEndpointByName(name string) *Endpoint {
 	// First filter by active groups
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.GetAllEndpoints())
 
 	// Then find by name
 	for _, endpoint := range activeEndpoints {
@@ -400,7 +662,7 @@ func (m *Manager) GetEndpointByName(name string) *Endpoint {
 
 // GetEndpointByNameAny returns an endpoint by name from all endpoints (ignoring group status)
 func (m *Manager) GetEndpointByNameAny(name string) *Endpoint {
-	for _, endpoint := range m.endpoints {
+	for _, endpoint := range m.GetAllEndpoints() {
 		if endpoint.Config.Name == name {
 			return endpoint
 		}
@@ -410,26 +672,47 @@ func (m *Manager) GetEndpointByNameAny(name string) *Endpoint {
 
 // GetAllEndpoints returns all endpoints
 func (m *Manager) GetAllEndpoints() []*Endpoint {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
 	return m.endpoints
 }
 
 // GetTokenForEndpoint dynamically resolves the token for an endpoint
+// If the endpoint uses OAuth2, return its current access token
 // If the endpoint has its own token, return it
-// If not, find the first endpoint in the same group that has a token
+// If not, use the group's token from the groups section
+// If there is none, find the first endpoint in the same group that has a token
 func (m *Manager) GetTokenForEndpoint(ep *Endpoint) string {
-	// 1. If endpoint has its own token, use it directly
+	// 0. OAuth2 endpoints always use their own access token, never an inherited static one
+	if ep.Config.IsOAuth2() {
+		source := m.tokenSource(ep.Config.Name)
+		if source == nil {
+			return ""
+		}
+		token, _ := source.Token(m.ctx)
+		return token
+	}
+
+	// 1. If endpoint has its own token, use it directly; with a tokens list, the one next in rotation
 	if ep.Config.Token != "" {
 		return ep.Config.Token
 	}
+	if ep.tokens != nil {
+		return ep.tokens.take(time.Now(), false)
+	}
 
-	// 2. Find the first endpoint in the same group that has a token
 	groupName := ep.Config.Group
 	if groupName == "" {
 		groupName = "Default"
 	}
 
-	// Search through all endpoints for the same group
-	for _, endpoint := range m.endpoints {
+	// 2. Use the token configured for the group, if any
+	if token := m.GetConfig().GetGroupToken(groupName); token != "" {
+		return token
+	}
+
+	// 3. Find the first endpoint in the same group that has a token
+	for _, endpoint := range m.GetAllEndpoints() {
 		endpointGroup := endpoint.Config.Group
 		if endpointGroup == "" {
 			endpointGroup = "Default"
@@ -441,7 +724,7 @@ func (m *Manager) GetTokenForEndpoint(ep *Endpoint) string {
 		}
 	}
 
-	// 3. No token found in the group
+	// 4. No token found in the group
 	return ""
 }
 
@@ -461,7 +744,7 @@ func (m *Manager) GetApiKeyForEndpoint(ep *Endpoint) string {
 	}
 
 	// Search through all endpoints for the same group
-	for _, endpoint := range m.endpoints {
+	for _, endpoint := range m.GetAllEndpoints() {
 		endpointGroup := endpoint.Config.Group
 		if endpointGroup == "" {
 			endpointGroup = "Default"
@@ -477,11 +760,79 @@ func (m *Manager) GetApiKeyForEndpoint(ep *Endpoint) string {
 	return ""
 }
 
-// GetConfig returns the manager's configuration
+// SetEndpointMaintenance enables or disables maintenance mode for an endpoint at runtime.
+// source identifies the operator interface (e.g. "tui", "webui") for logging.
+func (m *Manager) SetEndpointMaintenance(name string, disabled bool, source string) error {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return fmt.Errorf("endpoint '%s' not found", name)
+	}
+
+	ep.mutex.Lock()
+	changed := ep.Status.Disabled != disabled
+	ep.Status.Disabled = disabled
+	ep.mutex.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	m.statusGeneration.Add(1)
+	m.saveState()
+
+	if disabled {
+		slog.Info(fmt.Sprintf("⏸️ [维护模式] 端点已进入维护模式: %s (来源: %s)", name, source))
+	} else {
+		slog.Info(fmt.Sprintf("▶️ [维护模式] 端点已退出维护模式: %s (来源: %s)", name, source))
+		// Refresh health status of the re-enabled endpoint right away
+		go m.checkEndpointHealth(ep)
+	}
+
+	return nil
+}
+
+// SetEndpointRateLimited marks an endpoint as rate limited until the given time.
+// Selection skips the endpoint until the deadline passes; a later deadline never gets shortened.
+func (m *Manager) SetEndpointRateLimited(name string, until time.Time) error {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return fmt.Errorf("endpoint '%s' not found", name)
+	}
+
+	ep.mutex.Lock()
+	if until.After(ep.Status.RateLimitedUntil) {
+		ep.Status.RateLimitedUntil = until
+	}
+	until = ep.Status.RateLimitedUntil
+	ep.mutex.Unlock()
+	m.statusGeneration.Add(1)
+
+	slog.Warn(fmt.Sprintf("🚦 [上游限流] 端点 %s 暂时降级，直到 %s 前不参与选择",
+		name, until.Format("15:04:05")))
+	return nil
+}
+
+// StatusGeneration returns a counter that changes whenever endpoint health, maintenance,
+// rate-limit or group state changes. Views compare it to skip re-rendering unchanged data.
+func (m *Manager) StatusGeneration() uint64 {
+	return m.statusGeneration.Load() + m.groupManager.generation.Load()
+}
+
+// GetConfig returns the manager's configuration. Runtime edits swap in an edited copy, so
+// the returned config is never modified afterwards.
 func (m *Manager) GetConfig() *config.Config {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
 	return m.config
 }
 
+// configAndClient returns the configuration together with the HTTP client created from it
+func (m *Manager) configAndClient() (*config.Config, *http.Client) {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
+	return m.config, m.client
+}
+
 // GetGroupManager returns the group manager
 func (m *Manager) GetGroupManager() *GroupManager {
 	return m.groupManager
@@ -491,7 +842,7 @@ func (m *Manager) GetGroupManager() *GroupManager {
 func (m *Manager) healthCheckLoop() {
 	defer m.wg.Done()
 
-	ticker := time.NewTicker(m.config.Health.CheckInterval)
+	ticker := time.NewTicker(m.GetConfig().Health.CheckInterval)
 	defer ticker.Stop()
 
 	// Initial health check
@@ -502,15 +853,22 @@ func (m *Manager) healthCheckLoop() {
 		case <-m.ctx.Done():
 			return
 		case <-ticker.C:
-			m.performHealthChecks()
+			m.runHealthChecks(true)
 		}
 	}
 }
 
 // performHealthChecks performs health checks on all endpoints
 func (m *Manager) performHealthChecks() {
+	m.runHealthChecks(false)
+}
+
+// runHealthChecks checks the endpoints of the active groups. A scheduled run skips endpoints
+// with a fresh result (a fast test sent since the last run, or in passive mode, recent traffic).
+func (m *Manager) runHealthChecks(scheduled bool) {
 	// Get endpoints from active groups only
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	endpoints := m.GetAllEndpoints()
+	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(endpoints)
 
 	if len(activeEndpoints) == 0 {
 		slog.Debug("🩺 [健康检查] 没有活跃组中的端点，跳过健康检查")
@@ -518,12 +876,18 @@ func (m *Manager) performHealthChecks() {
 	}
 
 	slog.Debug(fmt.Sprintf("🩺 [健康检查] 开始检查 %d 个活跃组端点 (总共 %d 个端点)",
-		len(activeEndpoints), len(m.endpoints)))
+		len(activeEndpoints), len(endpoints)))
 
 	var wg sync.WaitGroup
 
-	// Only check endpoints in active groups
+	// Only check endpoints in active groups (endpoints in maintenance mode are skipped)
 	for _, endpoint := range activeEndpoints {
+		if endpoint.IsDisabled() {
+			continue
+		}
+		if scheduled && m.reuseProbeResult(endpoint) {
+			continue
+		}
 		wg.Add(1)
 		go func(ep *Endpoint) {
 			defer wg.Done()
@@ -544,94 +908,298 @@ func (m *Manager) performHealthChecks() {
 	slog.Debug(fmt.Sprintf("🩺 [健康检查] 完成检查 - 活跃组健康: %d/%d", healthyCount, len(activeEndpoints)))
 }
 
-// checkEndpointHealth checks the health of a single endpoint
-func (m *Manager) checkEndpointHealth(endpoint *Endpoint) {
-	start := time.Now()
+// reuseProbeResult reports whether a scheduled health check of the endpoint can be skipped.
+// In passive mode that is while a healthy endpoint had traffic within the idle window (its
+// requests already keep the status current; unhealthy ones are still probed to detect
+// recovery). Otherwise it is when another probe ran within half a check interval, whose
+// result is applied instead.
+func (m *Manager) reuseProbeResult(endpoint *Endpoint) bool {
+	health := m.GetConfig().Health
+	if health.PassiveMode && endpoint.IsHealthy() && time.Since(m.prober.LastTraffic(endpoint.Config.Name)) < health.PassiveIdleWindow {
+		return true
+	}
+	result, ok := m.prober.Result(endpoint.Config.Name, health.CheckInterval/2)
+	if !ok || result.Passive {
+		return false
+	}
+	m.updateEndpointStatus(endpoint, result.Healthy, result.ResponseTime)
+	return true
+}
 
-	healthURL := endpoint.Config.URL + m.config.Health.HealthPath
-	req, err := http.NewRequestWithContext(m.ctx, "GET", healthURL, nil)
-	if err != nil {
-		m.updateEndpointStatus(endpoint, false, 0)
-		return
+// CheckConnectivity probes the health path of every endpoint once, in parallel, and returns
+// the results in endpoint order without changing endpoint health. It is meant for checking a
+// config before the manager is started.
+func (m *Manager) CheckConnectivity(ctx context.Context, timeout time.Duration) []ProbeResult {
+	cfg, client := m.configAndClient()
+	endpoints := m.GetAllEndpoints()
+	shared := &http.Client{Timeout: timeout, Transport: client.Transport}
+	results := make([]ProbeResult, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func(i int, ep *Endpoint) {
+			defer wg.Done()
+			var result ProbeResult
+			if source := m.tokenSource(ep.Config.Name); source != nil {
+				if _, err := source.Token(ctx); err != nil {
+					result = ProbeResult{Error: err, Time: time.Now()}
+				}
+			}
+			if result.Error == nil {
+				client, cleanup := endpointClient(cfg, ep, shared)
+				result = m.prober.Probe(ctx, ep, client, cfg.Health.HealthPath)
+				cleanup()
+			}
+			results[i] = result
+		}(i, ep)
 	}
+	wg.Wait()
+	return results
+}
 
-	// Add authorization header with dynamically resolved token
-	token := m.GetTokenForEndpoint(endpoint)
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+// CheckEndpointNow runs the health check of the named endpoint right away, the same probe
+// the periodic health checker sends, and returns its result once the endpoint's status has
+// been updated. Endpoints in maintenance mode are not checked.
+func (m *Manager) CheckEndpointNow(name string) (ProbeResult, error) {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return ProbeResult{}, fmt.Errorf("endpoint '%s' not found", name)
+	}
+	if ep.IsDisabled() {
+		return ProbeResult{}, fmt.Errorf("endpoint '%s' is in maintenance mode", name)
 	}
 
-	resp, err := m.client.Do(req)
-	responseTime := time.Since(start)
+	result := m.checkEndpointHealth(ep)
+	if result.Error != nil {
+		slog.Info(fmt.Sprintf("🩺 [手动检查] 端点: %s - 错误: %v, 响应时间: %dms", name, result.Error, result.ResponseTime.Milliseconds()))
+	} else {
+		slog.Info(fmt.Sprintf("🩺 [手动检查] 端点: %s - 状态码: %d, 响应时间: %dms", name, result.StatusCode, result.ResponseTime.Milliseconds()))
+	}
+	return result, nil
+}
 
-	if err != nil {
+// checkEndpointHealth checks the health of a single endpoint and returns the probe result
+func (m *Manager) checkEndpointHealth(endpoint *Endpoint) ProbeResult {
+	cfg, client := m.configAndClient()
+	return m.probeEndpointHealth(cfg, client, endpoint)
+}
+
+// probeEndpointHealth is checkEndpointHealth with the config and client of a given update
+func (m *Manager) probeEndpointHealth(cfg *config.Config, client *http.Client, endpoint *Endpoint) ProbeResult {
+	// Without an OAuth2 token the probe would only see 401s; the endpoint is already
+	// marked unhealthy by the failed refresh
+	if source := m.tokenSource(endpoint.Config.Name); source != nil {
+		if _, err := source.Token(m.ctx); err != nil {
+			return ProbeResult{Error: err, Time: time.Now()}
+		}
+	}
+
+	client, cleanup := endpointClient(cfg, endpoint, client)
+	defer cleanup()
+
+	result := m.prober.Probe(m.ctx, endpoint, client, cfg.Health.HealthPath)
+	responseTime := result.ResponseTime
+
+	if result.Error != nil {
 		// Network or connection error
 		slog.Warn(fmt.Sprintf("❌ [健康检查] 端点网络错误: %s - 错误: %s, 响应时间: %dms",
-			endpoint.Config.Name, err.Error(), responseTime.Milliseconds()))
+			endpoint.Config.Name, result.Error.Error(), responseTime.Milliseconds()))
 		m.updateEndpointStatus(endpoint, false, responseTime)
-		return
+		return result
 	}
 
-	resp.Body.Close()
-
-	// Consider 2xx and 40x as healthy for API endpoints
-	// 2xx: Success responses
-	// 40x: Client errors (like 401 Unauthorized, 403 Forbidden) indicate the endpoint is reachable
-	healthy := (resp.StatusCode >= 200 && resp.StatusCode < 300) ||
-		(resp.StatusCode >= 400 && resp.StatusCode < 500)
-
 	// Log health check results
-	if healthy {
+	if result.Healthy {
 		slog.Debug(fmt.Sprintf("✅ [健康检查] 端点正常: %s - 状态码: %d, 响应时间: %dms",
 			endpoint.Config.Name,
-			resp.StatusCode,
+			result.StatusCode,
 			responseTime.Milliseconds()))
 	} else {
 		slog.Warn(fmt.Sprintf("⚠️ [健康检查] 端点异常: %s - 状态码: %d, 响应时间: %dms",
 			endpoint.Config.Name,
-			resp.StatusCode,
+			result.StatusCode,
 			responseTime.Milliseconds()))
 	}
 
+	m.updateEndpointStatus(endpoint, result.Healthy, responseTime)
+	return result
+}
+
+// RecordRequestOutcome records a request proxied to an endpoint (statusCode 0 when it failed
+// with err). Network errors and 5xx responses count as failures for the circuit breaker and
+// toward the health thresholds, so a run of failed requests marks the endpoint unhealthy
+// between health checks. In passive health mode the outcome also counts as a health check.
+// The response time of a real request includes generation, so the probed response time is kept.
+func (m *Manager) RecordRequestOutcome(name string, statusCode int, err error) {
+	now := time.Now()
+	m.prober.RecordTraffic(name, now)
+	endpoint := m.GetEndpointByNameAny(name)
+	if endpoint == nil {
+		return
+	}
+
+	healthy := err == nil && isHealthyStatus(statusCode)
+	m.recordBreakerOutcome(endpoint, err != nil || statusCode >= 500)
+	health := m.GetConfig().Health
+	if !health.PassiveMode {
+		// Configs that skipped the defaults leave health to the checks, as before the thresholds
+		if health.UnhealthyThreshold > 0 {
+			m.countHealthResult(endpoint, healthy)
+		}
+		return
+	}
+	if !healthy && endpoint.IsHealthy() {
+		slog.Warn(fmt.Sprintf("❌ [被动健康检查] 端点请求失败: %s - 状态码: %d, 错误: %v", name, statusCode, err))
+	}
+	responseTime := endpoint.GetResponseTime()
+	m.prober.Record(name, ProbeResult{
+		Healthy:      healthy,
+		StatusCode:   statusCode,
+		ResponseTime: responseTime,
+		Error:        err,
+		Time:         now,
+		Passive:      true,
+	})
 	m.updateEndpointStatus(endpoint, healthy, responseTime)
 }
 
-// updateEndpointStatus updates the health status of an endpoint
+// HealthThresholds returns how many consecutive good and failed results flip an endpoint's
+// health. Configs that skipped the defaults flip on every result.
+func (m *Manager) HealthThresholds() (healthy, unhealthy int) {
+	health := m.GetConfig().Health
+	return max(health.HealthyThreshold, 1), max(health.UnhealthyThreshold, 1)
+}
+
+// updateEndpointStatus records a health check result of an endpoint
 func (m *Manager) updateEndpointStatus(endpoint *Endpoint, healthy bool, responseTime time.Duration) {
 	endpoint.mutex.Lock()
-	defer endpoint.mutex.Unlock()
-
 	endpoint.Status.LastCheck = time.Now()
 	endpoint.Status.ResponseTime = responseTime
+	endpoint.mutex.Unlock()
+
+	m.countHealthResult(endpoint, healthy)
+}
+
+// countHealthResult counts a good or failed check or request of an endpoint. Its health only
+// flips once health.healthy_threshold or health.unhealthy_threshold results in a row agree, so
+// an endpoint answering alternately well and badly keeps its state instead of flapping.
+func (m *Manager) countHealthResult(endpoint *Endpoint, healthy bool) {
+	// The group is checked once the endpoint's lock is released, since it reads the other endpoints
+	becameUnhealthy := false
+	defer func() {
+		if becameUnhealthy {
+			m.checkGroupDown(endpoint.Config.Group)
+		}
+	}()
+
+	healthyThreshold, unhealthyThreshold := m.HealthThresholds()
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	defer m.statusGeneration.Add(1)
 
 	if healthy {
-		// Endpoint is healthy
-		wasUnhealthy := !endpoint.Status.Healthy
-		endpoint.Status.Healthy = true
+		endpoint.Status.ConsecutiveOKs++
 		endpoint.Status.ConsecutiveFails = 0
-
-		// Log recovery if endpoint was previously unhealthy
-		if wasUnhealthy {
-			slog.Info(fmt.Sprintf("✅ [健康检查] 端点恢复正常: %s - 响应时间: %dms",
-				endpoint.Config.Name, responseTime.Milliseconds()))
+		if endpoint.Status.Healthy {
+			return
 		}
-	} else {
-		// Endpoint failed health check
-		endpoint.Status.ConsecutiveFails++
-		wasHealthy := endpoint.Status.Healthy
+		if endpoint.Status.ConsecutiveOKs < healthyThreshold {
+			slog.Debug(fmt.Sprintf("🩹 [健康检查] 端点 %s 恢复中: 连续成功 %d/%d次",
+				endpoint.Config.Name, endpoint.Status.ConsecutiveOKs, healthyThreshold))
+			return
+		}
+		m.markHealthy(endpoint)
+		return
+	}
+
+	endpoint.Status.ConsecutiveFails++
+	endpoint.Status.ConsecutiveOKs = 0
+	if !endpoint.Status.Healthy {
+		slog.Debug(fmt.Sprintf("❌ [健康检查] 端点仍然不可用: %s - 连续失败: %d次",
+			endpoint.Config.Name, endpoint.Status.ConsecutiveFails))
+		return
+	}
+	if endpoint.Status.ConsecutiveFails < unhealthyThreshold {
+		slog.Debug(fmt.Sprintf("⚠️ [健康检查] 端点 %s 失败: 连续失败 %d/%d次，暂不标记为不可用",
+			endpoint.Config.Name, endpoint.Status.ConsecutiveFails, unhealthyThreshold))
+		return
+	}
 
-		// Mark as unhealthy immediately on any failure
-		endpoint.Status.Healthy = false
+	becameUnhealthy = true
+	endpoint.Status.Healthy = false
+	slog.Warn(fmt.Sprintf("❌ [健康检查] 端点标记为不可用: %s - 连续失败: %d次, 响应时间: %dms",
+		endpoint.Config.Name, endpoint.Status.ConsecutiveFails, endpoint.Status.ResponseTime.Milliseconds()))
+	m.publish.Publish(notify.Event{
+		Type:    config.NotifyEventEndpointUnhealthy,
+		Subject: endpoint.Config.Name,
+		Message: fmt.Sprintf("Endpoint %s failed %d checks or requests in a row and was marked unhealthy", endpoint.Config.Name, endpoint.Status.ConsecutiveFails),
+		Details: map[string]string{"group": endpoint.Config.Group, "url": endpoint.Config.DisplayURL()},
+	})
+}
 
-		// Log the failure
-		if wasHealthy {
-			slog.Warn(fmt.Sprintf("❌ [健康检查] 端点标记为不可用: %s - 连续失败: %d次, 响应时间: %dms",
-				endpoint.Config.Name, endpoint.Status.ConsecutiveFails, responseTime.Milliseconds()))
-		} else {
-			slog.Debug(fmt.Sprintf("❌ [健康检查] 端点仍然不可用: %s - 连续失败: %d次, 响应时间: %dms",
-				endpoint.Config.Name, endpoint.Status.ConsecutiveFails, responseTime.Milliseconds()))
+// markHealthy marks an unhealthy endpoint healthy again (caller holds the lock)
+func (m *Manager) markHealthy(endpoint *Endpoint) {
+	endpoint.Status.Healthy = true
+	endpoint.Status.ConsecutiveFails = 0
+	slog.Info(fmt.Sprintf("✅ [健康检查] 端点恢复正常: %s - 响应时间: %dms",
+		endpoint.Config.Name, endpoint.Status.ResponseTime.Milliseconds()))
+	m.publish.Publish(notify.Event{
+		Type:    config.NotifyEventEndpointHealthy,
+		Subject: endpoint.Config.Name,
+		Message: fmt.Sprintf("Endpoint %s is healthy again (response time %dms)", endpoint.Config.Name, endpoint.Status.ResponseTime.Milliseconds()),
+		Details: map[string]string{"group": endpoint.Config.Group, "url": endpoint.Config.DisplayURL()},
+	})
+}
+
+// checkGroupDown publishes all_endpoints_down when no endpoint of a group is healthy anymore
+func (m *Manager) checkGroupDown(group string) {
+	total := 0
+	for _, endpoint := range m.GetAllEndpoints() {
+		if endpoint.Config.Group != group {
+			continue
+		}
+		endpoint.mutex.RLock()
+		healthy := endpoint.Status.Healthy
+		endpoint.mutex.RUnlock()
+		if healthy {
+			return
 		}
+		total++
+	}
+	if total == 0 {
+		return
+	}
+
+	name := group
+	if name == "" {
+		name = "Default"
+	}
+	slog.Error(fmt.Sprintf("🚨 [健康检查] 组内所有端点均不可用: %s (%d个端点)", name, total))
+	m.publish.Publish(notify.Event{
+		Type:    config.NotifyEventAllEndpointsDown,
+		Subject: name,
+		Message: fmt.Sprintf("All %d endpoints of group %s are unhealthy", total, name),
+		Details: map[string]string{"endpoints": fmt.Sprintf("%d", total)},
+	})
+}
+
+// endpointClient returns the client used to probe an endpoint. Regular endpoints share
+// the given client; unix:// endpoints get a client dialing their socket, and the returned
+// cleanup closes its idle connections.
+func endpointClient(cfg *config.Config, endpoint *Endpoint, shared *http.Client) (*http.Client, func()) {
+	if !endpoint.Config.IsUnixSocket() {
+		return shared, func() {}
 	}
+
+	httpTransport, err := transport.CreateEndpointTransport(cfg, endpoint.Config)
+	if err != nil {
+		return shared, func() {}
+	}
+	client := &http.Client{
+		Timeout:   shared.Timeout,
+		Transport: httpTransport,
+	}
+	return client, httpTransport.CloseIdleConnections
 }
 
 // IsHealthy returns the health status of an endpoint
@@ -641,6 +1209,20 @@ func (e *Endpoint) IsHealthy() bool {
 	return e.Status.Healthy
 }
 
+// IsDisabled returns whether the endpoint is in maintenance mode
+func (e *Endpoint) IsDisabled() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Status.Disabled
+}
+
+// IsRateLimited returns whether the endpoint is currently rate limited by its upstream
+func (e *Endpoint) IsRateLimited() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Status.IsRateLimited(time.Now())
+}
+
 // GetResponseTime returns the last response time of an endpoint
 func (e *Endpoint) GetResponseTime() time.Duration {
 	e.mutex.RLock()
@@ -3,12 +3,24 @@ package endpoint
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"endpoint_forwarder/config"
 )
 
+// newTestConfig returns a minimal config with the given endpoints: priority strategy, health
+// checks every minute and the default group settings. Tests set what they exercise on top.
+func newTestConfig(endpoints ...config.EndpointConfig) *config.Config {
+	return &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Health:    config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:     config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: endpoints,
+	}
+}
+
 func TestHealthCheckWithAPIEndpoint(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -277,4 +289,191 @@ func TestGetEndpointByNameWithNoActiveGroups(t *testing.T) {
 	if endpointAny.Config.Name != "test-endpoint" {
 		t.Errorf("Expected test-endpoint, got: %s", endpointAny.Config.Name)
 	}
-}
\ No newline at end of file
+}
+
+func TestEndpointMaintenanceMode(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Group: config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+			{Name: "backup", URL: "http://127.0.0.1:1", Priority: 2, Group: "main", GroupPriority: 1},
+			{Name: "boot-disabled", URL: "http://127.0.0.1:1", Priority: 3, Group: "main", GroupPriority: 1, Disabled: true},
+		},
+	}
+
+	manager := NewManager(cfg)
+
+	if !manager.GetEndpointByNameAny("boot-disabled").IsDisabled() {
+		t.Fatal("Expected endpoint with disabled: true to start in maintenance mode")
+	}
+
+	if err := manager.SetEndpointMaintenance("primary", true, "test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	healthy := manager.GetHealthyEndpoints()
+	if len(healthy) != 1 || healthy[0].Config.Name != "backup" {
+		t.Fatalf("Expected only 'backup' to be selectable, got %d endpoints", len(healthy))
+	}
+
+	if err := manager.SetEndpointMaintenance("missing", true, "test"); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+
+	// Reload touching only the backup endpoint: primary keeps its runtime state
+	newCfg := *cfg
+	newCfg.Endpoints = append([]config.EndpointConfig(nil), cfg.Endpoints...)
+	newCfg.Endpoints[1].Priority = 5
+	manager.UpdateConfig(&newCfg)
+
+	if !manager.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected maintenance state of untouched endpoint to survive config reload")
+	}
+
+	// Reload touching other settings of the primary endpoint: maintenance mode is kept
+	changedCfg := newCfg
+	changedCfg.Endpoints = append([]config.EndpointConfig(nil), newCfg.Endpoints...)
+	changedCfg.Endpoints[0].Priority = 4
+	manager.UpdateConfig(&changedCfg)
+
+	if !manager.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected maintenance state to survive a reload changing other endpoint settings")
+	}
+
+	// Reload changing the disabled setting: state comes from config again
+	enabledCfg := changedCfg
+	enabledCfg.Endpoints = append([]config.EndpointConfig(nil), changedCfg.Endpoints...)
+	enabledCfg.Endpoints[2].Disabled = false
+	manager.UpdateConfig(&enabledCfg)
+
+	if manager.GetEndpointByNameAny("boot-disabled").IsDisabled() {
+		t.Error("Expected maintenance state to follow the config when its disabled setting changed")
+	}
+
+	// An endpoint removed and added back starts from its config
+	removedCfg := enabledCfg
+	removedCfg.Endpoints = append([]config.EndpointConfig(nil), enabledCfg.Endpoints[1:]...)
+	manager.UpdateConfig(&removedCfg)
+	manager.UpdateConfig(&enabledCfg)
+
+	if manager.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected maintenance state to reset once the endpoint was removed from the config")
+	}
+}
+
+func TestEndpointRateLimitExpiry(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Group: config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+			{Name: "backup", URL: "http://127.0.0.1:1", Priority: 2, Group: "main", GroupPriority: 1},
+		},
+	}
+
+	manager := NewManager(cfg)
+
+	if err := manager.SetEndpointRateLimited("primary", time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	primary := manager.GetEndpointByNameAny("primary")
+	if !primary.IsRateLimited() {
+		t.Fatal("Expected 'primary' to be rate limited")
+	}
+	if until := primary.GetStatus().RateLimitedUntil; until.IsZero() {
+		t.Error("Expected rate-limited-until to be exposed in status")
+	}
+
+	healthy := manager.GetHealthyEndpoints()
+	if len(healthy) != 1 || healthy[0].Config.Name != "backup" {
+		t.Fatalf("Expected only 'backup' to be selectable while 'primary' is rate limited, got %d endpoints", len(healthy))
+	}
+
+	// An earlier deadline must not shorten an active rate limit
+	manager.SetEndpointRateLimited("primary", time.Now())
+	if !primary.IsRateLimited() {
+		t.Error("Expected earlier deadline to keep the existing rate limit")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if primary.IsRateLimited() {
+		t.Error("Expected rate limit to expire")
+	}
+	healthy = manager.GetHealthyEndpoints()
+	if len(healthy) != 2 || healthy[0].Config.Name != "primary" {
+		t.Fatalf("Expected 'primary' to be selected first again after expiry, got %d endpoints", len(healthy))
+	}
+
+	if err := manager.SetEndpointRateLimited("missing", time.Now()); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+}
+
+func TestCheckEndpointNow(t *testing.T) {
+	var statusCode atomic.Int32
+	statusCode.Store(http.StatusBadGateway)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(statusCode.Load()))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: server.URL, Timeout: 30 * time.Second},
+			{Name: "parked", URL: server.URL, Timeout: 30 * time.Second, Disabled: true},
+		},
+	}
+	manager := NewManager(cfg)
+
+	// Two failed checks mark the endpoint unhealthy
+	for i := 0; i < 2; i++ {
+		result, err := manager.CheckEndpointNow("primary")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Healthy || result.StatusCode != http.StatusBadGateway {
+			t.Fatalf("Expected unhealthy 502 result, got healthy=%v status=%d", result.Healthy, result.StatusCode)
+		}
+	}
+	if manager.GetEndpointByNameAny("primary").IsHealthy() {
+		t.Fatal("Expected endpoint to be unhealthy after failed checks")
+	}
+
+	// Once the upstream is fixed a single manual check brings it back
+	statusCode.Store(http.StatusOK)
+	result, err := manager.CheckEndpointNow("primary")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Healthy || result.StatusCode != http.StatusOK || result.ResponseTime <= 0 {
+		t.Errorf("Expected healthy 200 result with a response time, got %+v", result)
+	}
+	if !manager.GetEndpointByNameAny("primary").IsHealthy() {
+		t.Error("Expected endpoint to be healthy right after the manual check")
+	}
+
+	if _, err := manager.CheckEndpointNow("parked"); err == nil {
+		t.Error("Expected error for endpoint in maintenance mode")
+	}
+	if _, err := manager.CheckEndpointNow("missing"); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+}
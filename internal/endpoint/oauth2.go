@@ -0,0 +1,319 @@
+package endpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+const (
+	// oauth2DefaultLifetime is assumed when the token response has no expires_in
+	oauth2DefaultLifetime = time.Hour
+	// oauth2RetryInterval is how long the background refresher waits after a failed refresh
+	oauth2RetryInterval = 15 * time.Second
+	// oauth2RequestTimeout bounds a single token request
+	oauth2RequestTimeout = 30 * time.Second
+)
+
+// AuthStatus describes the state of an endpoint's OAuth2 token, without the token itself
+type AuthStatus struct {
+	Expiry      time.Time // When the cached token expires (zero if none)
+	LastRefresh time.Time // Last successful refresh
+	LastError   string    // Last refresh error, cleared on success
+}
+
+// OAuth2TokenSource fetches and caches an access token using the client-credentials grant
+type OAuth2TokenSource struct {
+	cfg    config.EndpointAuthConfig
+	client *http.Client
+
+	mutex       sync.Mutex
+	token       string
+	expiry      time.Time
+	lastRefresh time.Time
+	lastError   string
+
+	// onChange is called after every refresh attempt (err is nil on success)
+	onChange func(status AuthStatus, err error)
+
+	cancel context.CancelFunc
+}
+
+// NewOAuth2TokenSource creates a token source for the given auth config
+func NewOAuth2TokenSource(cfg config.EndpointAuthConfig, client *http.Client) *OAuth2TokenSource {
+	return &OAuth2TokenSource{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+// valid reports whether the cached token can still be used (caller holds the lock)
+func (s *OAuth2TokenSource) valid(now time.Time) bool {
+	return s.token != "" && now.Before(s.expiry)
+}
+
+// Token returns the cached token, fetching a new one if there is no valid token
+func (s *OAuth2TokenSource) Token(ctx context.Context) (string, error) {
+	s.mutex.Lock()
+	if s.valid(time.Now()) {
+		token := s.token
+		s.mutex.Unlock()
+		return token, nil
+	}
+	s.mutex.Unlock()
+
+	if err := s.Refresh(ctx); err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.token, nil
+}
+
+// Status returns the token expiry and refresh state
+func (s *OAuth2TokenSource) Status() AuthStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.statusLocked()
+}
+
+func (s *OAuth2TokenSource) statusLocked() AuthStatus {
+	return AuthStatus{
+		Expiry:      s.expiry,
+		LastRefresh: s.lastRefresh,
+		LastError:   s.lastError,
+	}
+}
+
+// Refresh fetches a new token from the token endpoint and caches it
+func (s *OAuth2TokenSource) Refresh(ctx context.Context) error {
+	token, expiry, err := s.fetch(ctx)
+
+	s.mutex.Lock()
+	if err != nil {
+		s.lastError = err.Error()
+	} else {
+		s.token = token
+		s.expiry = expiry
+		s.lastRefresh = time.Now()
+		s.lastError = ""
+	}
+	status := s.statusLocked()
+	onChange := s.onChange
+	s.mutex.Unlock()
+
+	if onChange != nil {
+		onChange(status, err)
+	}
+	return err
+}
+
+// fetch performs the client-credentials token request
+func (s *OAuth2TokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, oauth2RequestTimeout)
+	defer cancel()
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", s.cfg.ClientID)
+	if s.cfg.ClientSecret != "" {
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2 token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	requested := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2 token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("oauth2 token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(truncate(string(body), 200)))
+	}
+
+	var payload struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2 token response is not valid JSON: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("oauth2 token response has no access_token")
+	}
+
+	lifetime := oauth2DefaultLifetime
+	if payload.ExpiresIn > 0 {
+		lifetime = time.Duration(payload.ExpiresIn) * time.Second
+	}
+	return payload.AccessToken, requested.Add(lifetime), nil
+}
+
+// nextRefresh returns how long to wait before the next background refresh
+func (s *OAuth2TokenSource) nextRefresh(now time.Time) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.lastError != "" || !s.valid(now) {
+		return oauth2RetryInterval
+	}
+	wait := s.expiry.Sub(now) - s.cfg.RefreshMargin
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+// start refreshes the token in the background ahead of expiry until ctx is done
+func (s *OAuth2TokenSource) start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	go func() {
+		s.Refresh(ctx)
+		for {
+			timer := time.NewTimer(s.nextRefresh(time.Now()))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// stop ends the background refresher
+func (s *OAuth2TokenSource) stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// syncTokenSources creates token sources for OAuth2 endpoints, keeping sources whose auth
+// settings are unchanged so a reload does not throw away a valid token
+func (m *Manager) syncTokenSources(cfg *config.Config) {
+	m.tokenMutex.Lock()
+	defer m.tokenMutex.Unlock()
+
+	_, client := m.configAndClient()
+	sources := make(map[string]*OAuth2TokenSource)
+	for _, epCfg := range cfg.Endpoints {
+		if !epCfg.IsOAuth2() {
+			continue
+		}
+		if old, exists := m.tokenSources[epCfg.Name]; exists && reflect.DeepEqual(old.cfg, *epCfg.Auth) {
+			sources[epCfg.Name] = old
+			continue
+		}
+
+		source := NewOAuth2TokenSource(*epCfg.Auth, &http.Client{Transport: client.Transport})
+		name := epCfg.Name
+		source.onChange = func(status AuthStatus, err error) {
+			m.handleAuthChange(name, status, err)
+		}
+		if m.started.Load() {
+			source.start(m.ctx)
+		}
+		sources[epCfg.Name] = source
+	}
+
+	for name, old := range m.tokenSources {
+		if sources[name] != old {
+			old.stop()
+		}
+	}
+	m.tokenSources = sources
+}
+
+// startTokenSources starts background refreshing for all token sources
+func (m *Manager) startTokenSources() {
+	m.tokenMutex.Lock()
+	defer m.tokenMutex.Unlock()
+
+	for _, source := range m.tokenSources {
+		source.start(m.ctx)
+	}
+}
+
+// tokenSource returns the OAuth2 token source of an endpoint, or nil for static auth
+func (m *Manager) tokenSource(name string) *OAuth2TokenSource {
+	m.tokenMutex.Lock()
+	defer m.tokenMutex.Unlock()
+	return m.tokenSources[name]
+}
+
+// handleAuthChange records a refresh result on the endpoint's status
+func (m *Manager) handleAuthChange(name string, status AuthStatus, err error) {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return
+	}
+
+	ep.mutex.Lock()
+	wasHealthy := ep.Status.Healthy
+	hadError := ep.Status.AuthError != ""
+	applyAuthStatus(&ep.Status, status, time.Now())
+	healthy := ep.Status.Healthy
+	ep.mutex.Unlock()
+	m.statusGeneration.Add(1)
+
+	switch {
+	case err != nil && wasHealthy && !healthy:
+		slog.Warn(fmt.Sprintf("🔑 [OAuth2] 端点 %s 无法获取访问令牌，标记为不可用: %v", name, err))
+	case err != nil:
+		slog.Warn(fmt.Sprintf("🔑 [OAuth2] 端点 %s 刷新访问令牌失败 (当前令牌仍有效): %v", name, err))
+	case hadError:
+		slog.Info(fmt.Sprintf("🔑 [OAuth2] 端点 %s 访问令牌已恢复，有效期至 %s", name, status.Expiry.Format(time.RFC3339)))
+	}
+}
+
+// applyAuthStatus copies the token state onto an endpoint status. A failed refresh only makes
+// the endpoint unhealthy once no valid token is left; a successful one clears that again.
+func applyAuthStatus(status *EndpointStatus, auth AuthStatus, now time.Time) {
+	hadError := status.AuthError != "" && !status.Healthy
+	status.AuthExpiry = auth.Expiry
+	status.AuthError = auth.LastError
+
+	if auth.LastError != "" && !now.Before(auth.Expiry) {
+		status.Healthy = false
+		status.LastCheck = now
+	} else if auth.LastError == "" && hadError {
+		// Requests can be authenticated again; the next health check confirms reachability
+		status.Healthy = true
+		status.ConsecutiveFails = 0
+		status.ConsecutiveOKs = 0
+	}
+}
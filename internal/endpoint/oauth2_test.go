@@ -0,0 +1,166 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// fakeTokenServer issues numbered client-credentials tokens, or fails while failing is set
+type fakeTokenServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	issued    int
+	expiresIn int
+	failing   bool
+	lastForm  map[string]string
+}
+
+func newFakeTokenServer(t *testing.T, expiresIn int) *fakeTokenServer {
+	fake := &fakeTokenServer{expiresIn: expiresIn}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.lastForm = map[string]string{}
+		for key := range r.PostForm {
+			fake.lastForm[key] = r.PostForm.Get(key)
+		}
+		if fake.failing {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		fake.issued++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"Bearer","expires_in":%d}`, fake.issued, fake.expiresIn)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeTokenServer) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *fakeTokenServer) issuedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.issued
+}
+
+func newOAuth2TestManager(tokenURL string, margin time.Duration) *Manager {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Hour, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "static", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1, Token: "sk-static"},
+			{Name: "oauth", URL: "http://127.0.0.1:1", Priority: 2, Group: "main", GroupPriority: 1, Auth: &config.EndpointAuthConfig{
+				Type: config.AuthTypeOAuth2, TokenURL: tokenURL, ClientID: "client", ClientSecret: "secret",
+				Scopes: []string{"api.read", "api.write"}, RefreshMargin: margin,
+			}},
+		},
+	}
+	return NewManager(cfg)
+}
+
+func TestOAuth2TokenRefresh(t *testing.T) {
+	tokens := newFakeTokenServer(t, 2)
+	manager := newOAuth2TestManager(tokens.URL, 1500*time.Millisecond)
+	ep := manager.GetEndpointByNameAny("oauth")
+
+	// The first request fetches a token on demand instead of inheriting the group's static token
+	if token := manager.GetTokenForEndpoint(ep); token != "tok-1" {
+		t.Fatalf("Expected OAuth2 token tok-1, got %q", token)
+	}
+	tokens.mu.Lock()
+	form := tokens.lastForm
+	tokens.mu.Unlock()
+	if form["grant_type"] != "client_credentials" || form["client_id"] != "client" ||
+		form["client_secret"] != "secret" || form["scope"] != "api.read api.write" {
+		t.Errorf("Unexpected token request form: %v", form)
+	}
+	if token := manager.GetTokenForEndpoint(ep); token != "tok-1" || tokens.issuedCount() != 1 {
+		t.Errorf("Expected the cached token to be reused, got %q after %d requests", token, tokens.issuedCount())
+	}
+	status := ep.GetStatus()
+	if until := time.Until(status.AuthExpiry); until <= 0 || until > 2*time.Second || status.AuthError != "" {
+		t.Errorf("Expected token expiry within 2s and no error, got %v (%q)", status.AuthExpiry, status.AuthError)
+	}
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("static")); token != "sk-static" {
+		t.Errorf("Expected static endpoint to keep its token, got %q", token)
+	}
+
+	// The background refresher replaces the token ahead of expiry
+	manager.Start()
+	defer manager.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for tokens.issuedCount() < 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if tokens.issuedCount() < 3 {
+		t.Fatalf("Expected background refreshes, got %d tokens issued", tokens.issuedCount())
+	}
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth")); token == "tok-1" {
+		t.Error("Expected a refreshed token")
+	}
+}
+
+func TestOAuth2RefreshFailure(t *testing.T) {
+	tokens := newFakeTokenServer(t, 3600)
+	tokens.setFailing(true)
+	manager := newOAuth2TestManager(tokens.URL, time.Minute)
+	ep := manager.GetEndpointByNameAny("oauth")
+
+	// No token can be obtained: the endpoint is unhealthy with a descriptive error
+	if token := manager.GetTokenForEndpoint(ep); token != "" {
+		t.Fatalf("Expected no token while the token server fails, got %q", token)
+	}
+	status := ep.GetStatus()
+	if status.Healthy || !strings.Contains(status.AuthError, "401") {
+		t.Errorf("Expected unhealthy endpoint with the refresh error, got healthy=%v error=%q", status.Healthy, status.AuthError)
+	}
+	for _, healthy := range manager.GetHealthyEndpoints() {
+		if healthy.Config.Name == "oauth" {
+			t.Error("Expected the endpoint to be excluded from selection")
+		}
+	}
+
+	// Health checks do not probe without a token, so the endpoint stays down
+	manager.checkEndpointHealth(ep)
+	if ep.IsHealthy() {
+		t.Error("Expected health check to keep the endpoint unhealthy")
+	}
+
+	// Once the token server recovers the endpoint becomes usable again
+	tokens.setFailing(false)
+	source := manager.tokenSource("oauth")
+	if err := source.Refresh(manager.ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	status = ep.GetStatus()
+	if !status.Healthy || status.AuthError != "" || status.AuthExpiry.IsZero() {
+		t.Errorf("Expected recovered endpoint, got %+v", status)
+	}
+
+	// A failed refresh while the token is still valid is reported but keeps the endpoint healthy
+	tokens.setFailing(true)
+	if err := source.Refresh(manager.ctx); err == nil {
+		t.Fatal("Expected refresh to fail")
+	}
+	status = ep.GetStatus()
+	if !status.Healthy || status.AuthError == "" {
+		t.Errorf("Expected healthy endpoint with a reported refresh error, got %+v", status)
+	}
+	if token := manager.GetTokenForEndpoint(ep); token != "tok-1" {
+		t.Errorf("Expected the still-valid token to be used, got %q", token)
+	}
+}
@@ -0,0 +1,109 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// recoveryAt returns when an endpoint that cannot take requests right now is expected to be
+// usable again, zero when unknown (unhealthy or in maintenance) (caller holds the lock)
+func (s EndpointStatus) recoveryAt(now time.Time) time.Time {
+	if s.Disabled || !s.Healthy {
+		return time.Time{}
+	}
+	var at time.Time
+	if s.IsRateLimited(now) {
+		at = s.RateLimitedUntil
+	}
+	if s.BreakerState(now) == BreakerOpen && s.BreakerOpenUntil.After(at) {
+		at = s.BreakerOpenUntil
+	}
+	return at
+}
+
+// Outage reports whether no endpoint can take requests: every endpoint is unhealthy, in
+// maintenance, rate limited, held by its circuit breaker or token budget, or in a group in
+// cooldown. retryAfter is the time until the soonest known recovery, 0 when none is known.
+func (m *Manager) Outage() (down bool, retryAfter time.Duration) {
+	now := time.Now()
+	cooldowns := m.groupManager.GetGroupCooldowns()
+
+	var soonest time.Time
+	for _, ep := range m.GetAllEndpoints() {
+		ep.mutex.RLock()
+		reason := ep.Status.unselectableReason(now)
+		recovery := ep.Status.recoveryAt(now)
+		ep.mutex.RUnlock()
+		if reason == "" {
+			reason = m.budgetBlockReason(ep)
+		}
+		cooldownUntil, cooling := cooldowns[groupOf(ep)]
+		if reason == "" && !cooling {
+			return false, 0
+		}
+
+		// Usable once both the endpoint and its group are
+		if reason == "" {
+			recovery = cooldownUntil
+		} else if recovery.IsZero() {
+			continue
+		} else if cooling && cooldownUntil.After(recovery) {
+			recovery = cooldownUntil
+		}
+		if soonest.IsZero() || recovery.Before(soonest) {
+			soonest = recovery
+		}
+	}
+	if soonest.IsZero() {
+		return true, 0
+	}
+	return true, soonest.Sub(now)
+}
+
+// RecoveryCandidates returns the endpoints a recovery probe may try while no endpoint can take
+// requests, by group priority and then priority. Endpoints in maintenance or held by a token
+// budget are left out, since nothing about them is expected to recover by itself.
+func (m *Manager) RecoveryCandidates() []*Endpoint {
+	var candidates []*Endpoint
+	for _, ep := range m.GetAllEndpoints() {
+		if ep.IsDisabled() || m.budgetBlockReason(ep) != "" {
+			continue
+		}
+		candidates = append(candidates, ep)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Config.GroupPriority != candidates[j].Config.GroupPriority {
+			return candidates[i].Config.GroupPriority < candidates[j].Config.GroupPriority
+		}
+		return candidates[i].Config.Priority < candidates[j].Config.Priority
+	})
+	return candidates
+}
+
+// MarkRecovered puts an endpoint that answered a recovery probe back into selection: it is
+// marked healthy without waiting for health.healthy_threshold, its upstream rate limit is
+// lifted and its group leaves cooldown
+func (m *Manager) MarkRecovered(name string) {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return
+	}
+
+	ep.mutex.Lock()
+	ep.Status.RateLimitedUntil = time.Time{}
+	ep.Status.LastCheck = time.Now()
+	ep.Status.ConsecutiveOKs++
+	if !ep.Status.Healthy {
+		m.markHealthy(ep)
+	}
+	ep.mutex.Unlock()
+	m.statusGeneration.Add(1)
+
+	group := groupOf(ep)
+	if m.groupManager.IsGroupInCooldown(group) {
+		m.groupManager.ClearGroupCooldown(group)
+	}
+	slog.Info(fmt.Sprintf("🩺 [负载保护] 恢复探测请求成功，端点 %s (组: %s) 重新参与选择", name, group))
+}
@@ -0,0 +1,62 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutageRetryAfter(t *testing.T) {
+	manager := NewManager(newBudgetTestConfig())
+	if down, _ := manager.Outage(); down {
+		t.Fatal("Expected no outage with healthy endpoints")
+	}
+
+	// Rate limited primary recovers in 30s, unhealthy backup at an unknown time
+	manager.SetEndpointRateLimited("primary", time.Now().Add(30*time.Second))
+	backup := manager.GetEndpointByNameAny("backup")
+	backup.mutex.Lock()
+	backup.Status.Healthy = false
+	backup.mutex.Unlock()
+	down, retryAfter := manager.Outage()
+	if !down || retryAfter < 29*time.Second || retryAfter > 30*time.Second {
+		t.Fatalf("Expected an outage ending with primary's rate limit, got down=%v retryAfter=%v", down, retryAfter)
+	}
+
+	// A group cooldown outlasting the rate limit pushes the recovery back
+	manager.GetGroupManager().SetGroupCooldown("main")
+	if _, retryAfter := manager.Outage(); retryAfter < 59*time.Second || retryAfter > time.Minute {
+		t.Fatalf("Expected the recovery at the end of main's cooldown, got %v", retryAfter)
+	}
+
+	candidates := manager.RecoveryCandidates()
+	if len(candidates) != 2 || candidates[0].Config.Name != "primary" || candidates[1].Config.Name != "backup" {
+		t.Fatalf("Expected primary then backup as recovery candidates, got %d", len(candidates))
+	}
+
+	manager.MarkRecovered("backup")
+	if down, _ := manager.Outage(); down {
+		t.Fatal("Expected the recovered backup to end the outage")
+	}
+	if healthy := manager.GetHealthyEndpoints(); len(healthy) != 1 || healthy[0].Config.Name != "backup" {
+		t.Errorf("Expected backup back in selection, got %d endpoints", len(healthy))
+	}
+}
+
+func TestOutageGroupCooldownsOnly(t *testing.T) {
+	manager := NewManager(newBudgetTestConfig())
+	manager.GetGroupManager().SetGroupCooldown("main")
+	manager.GetGroupManager().SetGroupCooldown("backup")
+
+	down, retryAfter := manager.Outage()
+	if !down || retryAfter < 59*time.Second || retryAfter > time.Minute {
+		t.Fatalf("Expected an outage until the cooldowns end, got down=%v retryAfter=%v", down, retryAfter)
+	}
+
+	manager.MarkRecovered("primary")
+	if manager.GetGroupManager().IsGroupInCooldown("main") {
+		t.Error("Expected the recovered endpoint's group to leave cooldown")
+	}
+	if down, _ := manager.Outage(); down {
+		t.Error("Expected the outage to end")
+	}
+}
@@ -0,0 +1,167 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// primaryOverride is the -p command line override: one endpoint is promoted to priority 1,
+// for the process lifetime or until a deadline, after which the changed priorities revert
+type primaryOverride struct {
+	name      string
+	until     time.Time      // Zero when the override lasts for the process lifetime
+	applied   *config.Config // Config the override was last applied to
+	originals map[string]int // Priorities the override changed in that config, by endpoint name
+	timer     *time.Timer    // Ends a timed override
+	ending    bool           // Set while the override's priorities are being reverted
+}
+
+// stop cancels the expiry of a timed override
+func (o *primaryOverride) stop() {
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+}
+
+// PrimaryStatus describes the primary endpoint override
+type PrimaryStatus struct {
+	Name    string    // Promoted endpoint, empty when no override is active
+	Until   time.Time // When the override expires; zero for the process lifetime
+	Ended   string    // Endpoint of the last override that expired or was cancelled
+	EndedAt time.Time
+	Reason  string // Why the last override ended: "expired" or "cancelled"
+}
+
+// SetPrimaryEndpoint promotes an endpoint to priority 1. With a positive duration the
+// override expires after it and the priorities revert to their previous values. The
+// override is re-applied on top of reloaded configs until then.
+func (m *Manager) SetPrimaryEndpoint(name string, duration time.Duration) error {
+	var override *primaryOverride
+	var err error
+	m.editConfig(func(cfg *config.Config) bool {
+		if findConfigEndpoint(cfg, name) < 0 {
+			var names []string
+			for _, ep := range cfg.Endpoints {
+				names = append(names, ep.Name)
+			}
+			err = fmt.Errorf("指定的主端点 '%s' 未找到，可用端点: %v", name, names)
+			return false
+		}
+
+		if m.primary != nil {
+			m.primary.stop()
+			m.restorePrimaryPriorities(cfg)
+		}
+		override = &primaryOverride{name: name}
+		if duration > 0 {
+			override.until = time.Now().Add(duration)
+			override.timer = time.AfterFunc(duration, func() { m.endPrimaryOverride(override, "expired") })
+		}
+		m.primary = override
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if duration > 0 {
+		slog.Info(fmt.Sprintf("📌 [主端点] 端点 %s 已提升为最高优先级，将于 %s 后 (%s) 自动恢复",
+			name, duration, override.until.Format("15:04:05")))
+	} else {
+		slog.Info(fmt.Sprintf("📌 [主端点] 端点 %s 已提升为最高优先级，持续到进程退出", name))
+	}
+	return nil
+}
+
+// CancelPrimaryEndpoint ends the primary endpoint override right away
+func (m *Manager) CancelPrimaryEndpoint() error {
+	m.stateMutex.Lock()
+	override := m.primary
+	m.stateMutex.Unlock()
+
+	if override == nil || !m.endPrimaryOverride(override, "cancelled") {
+		return fmt.Errorf("no primary endpoint override is active")
+	}
+	return nil
+}
+
+// PrimaryStatus returns the active primary endpoint override and how the last one ended
+func (m *Manager) PrimaryStatus() PrimaryStatus {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	status := m.primaryEnded
+	if m.primary != nil {
+		status.Name = m.primary.name
+		status.Until = m.primary.until
+	}
+	return status
+}
+
+// endPrimaryOverride reverts the priorities changed by an override; false when the override
+// is no longer active. The override is reported as ended only once the endpoints carry the
+// reverted priorities.
+func (m *Manager) endPrimaryOverride(override *primaryOverride, reason string) bool {
+	m.updateMutex.Lock()
+	defer m.updateMutex.Unlock()
+
+	ended := m.editConfigLocked(func(cfg *config.Config) bool {
+		if m.primary != override {
+			return false
+		}
+		override.stop()
+		override.ending = true
+		m.restorePrimaryPriorities(cfg)
+		return true
+	})
+	if !ended {
+		return false
+	}
+
+	m.stateMutex.Lock()
+	m.primary = nil
+	m.primaryEnded = PrimaryStatus{Ended: override.name, EndedAt: time.Now(), Reason: reason}
+	m.stateMutex.Unlock()
+
+	if reason == "expired" {
+		slog.Info(fmt.Sprintf("⏰ [主端点] 端点 %s 的最高优先级已到期，优先级已恢复", override.name))
+	} else {
+		slog.Info(fmt.Sprintf("↩️ [主端点] 端点 %s 的最高优先级已取消，优先级已恢复", override.name))
+	}
+	return true
+}
+
+// applyPrimaryOverride promotes the primary endpoint in cfg (caller holds stateMutex). A config
+// it was already applied to is left alone, so repeated updates do not push endpoints further back.
+func (m *Manager) applyPrimaryOverride(cfg *config.Config) {
+	override := m.primary
+	if override == nil || override.ending || override.applied == cfg {
+		return
+	}
+
+	changed, err := cfg.PromoteEndpoint(override.name)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [主端点] 主端点 %s 已不在配置中，重新加入配置前不再生效", override.name))
+		return
+	}
+	override.applied = cfg
+	override.originals = changed
+}
+
+// restorePrimaryPriorities reverts the priorities the primary override changed in cfg, a copy
+// of the config it was applied to (caller holds stateMutex)
+func (m *Manager) restorePrimaryPriorities(cfg *config.Config) {
+	override := m.primary
+	if override.applied != cfg {
+		return // A reload replaced that config, its priorities come fresh from the file
+	}
+	for name, priority := range override.originals {
+		if idx := findConfigEndpoint(cfg, name); idx >= 0 {
+			cfg.Endpoints[idx].Priority = priority
+		}
+	}
+	override.applied = nil
+}
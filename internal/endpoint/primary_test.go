@@ -0,0 +1,93 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrimaryEndpointExpires(t *testing.T) {
+	manager := NewManager(newStateTestConfig("primary", "backup"))
+	defer manager.Stop()
+
+	if err := manager.SetPrimaryEndpoint("missing", time.Minute); err == nil {
+		t.Error("Expected an error for an unknown endpoint")
+	}
+	if err := manager.SetPrimaryEndpoint("backup", 200*time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := manager.GetEndpointByNameAny("backup").Config.Priority; got != 1 {
+		t.Errorf("Expected backup to be promoted to priority 1, got %d", got)
+	}
+	if got := manager.GetEndpointByNameAny("primary").Config.Priority; got != 3 {
+		t.Errorf("Expected primary to move behind backup, got priority %d", got)
+	}
+
+	// A reload re-applies the override until it expires
+	manager.UpdateConfig(newStateTestConfig("primary", "backup"))
+	if got := manager.GetEndpointByNameAny("backup").Config.Priority; got != 1 {
+		t.Errorf("Expected the override to survive a reload, got priority %d", got)
+	}
+	if status := manager.PrimaryStatus(); status.Name != "backup" || status.Until.IsZero() {
+		t.Errorf("Expected an active timed override, got %+v", status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.PrimaryStatus().Name != "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := manager.PrimaryStatus()
+	if status.Name != "" || status.Ended != "backup" || status.Reason != "expired" {
+		t.Fatalf("Expected the override to expire, got %+v", status)
+	}
+	if got := manager.GetEndpointByNameAny("backup").Config.Priority; got != 2 {
+		t.Errorf("Expected backup to revert to priority 2, got %d", got)
+	}
+	if got := manager.GetEndpointByNameAny("primary").Config.Priority; got != 1 {
+		t.Errorf("Expected primary to revert to priority 1, got %d", got)
+	}
+}
+
+func TestCancelPrimaryEndpoint(t *testing.T) {
+	manager := NewManager(newStateTestConfig("primary", "backup"))
+	defer manager.Stop()
+
+	if err := manager.CancelPrimaryEndpoint(); err == nil {
+		t.Error("Expected an error when no override is active")
+	}
+	if err := manager.SetPrimaryEndpoint("backup", 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status := manager.PrimaryStatus(); status.Name != "backup" || !status.Until.IsZero() {
+		t.Errorf("Expected an override for the process lifetime, got %+v", status)
+	}
+	if err := manager.CancelPrimaryEndpoint(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := manager.GetConfig().Endpoints[1].Priority; got != 2 {
+		t.Errorf("Expected backup to revert to priority 2, got %d", got)
+	}
+	if status := manager.PrimaryStatus(); status.Reason != "cancelled" {
+		t.Errorf("Expected the override to be reported as cancelled, got %+v", status)
+	}
+}
+
+func TestPrimaryEndpointEditsConfigCopy(t *testing.T) {
+	manager := NewManager(newStateTestConfig("primary", "backup"))
+	defer manager.Stop()
+
+	if err := manager.SetPrimaryEndpoint("backup", 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	promoted := manager.GetConfig()
+	if err := manager.CancelPrimaryEndpoint(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Readers holding the promoted config keep seeing it unchanged
+	if got := promoted.Endpoints[1].Priority; got != 1 {
+		t.Errorf("Expected the promoted config to be left alone, got backup priority %d", got)
+	}
+	if got := manager.GetConfig().Endpoints[1].Priority; got != 2 {
+		t.Errorf("Expected backup to revert to priority 2, got %d", got)
+	}
+}
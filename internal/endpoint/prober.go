@@ -0,0 +1,212 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProbeResult is the outcome of a probe sent to an endpoint or, in passive health mode,
+// of a real request proxied to it
+type ProbeResult struct {
+	Healthy      bool
+	StatusCode   int // 0 when no response was received
+	ResponseTime time.Duration
+	Error        error
+	Time         time.Time
+	Passive      bool // Recorded from proxied traffic rather than a probe
+}
+
+// Prober sends the probes of both the health checker and the fast tester, and keeps the
+// latest result per endpoint so each can reuse the other's fresh results
+type Prober struct {
+	manager *Manager // Resolves dynamic tokens and api-keys, nil for a standalone fast tester
+
+	mutex           sync.RWMutex
+	results         map[string]ProbeResult // Latest result keyed by endpoint name
+	lastTraffic     map[string]time.Time   // Last proxied request keyed by endpoint name
+	headUnsupported map[string]bool        // HEAD endpoints that answered 405/501 and are probed with GET
+
+	probes atomic.Int64 // Probe requests sent, for tests and diagnostics
+}
+
+// NewProber creates a prober; manager may be nil, in which case endpoints are probed
+// with their own token
+func NewProber(manager *Manager) *Prober {
+	return &Prober{
+		manager:         manager,
+		results:         make(map[string]ProbeResult),
+		lastTraffic:     make(map[string]time.Time),
+		headUnsupported: make(map[string]bool),
+	}
+}
+
+// Probe sends a probe to path on the endpoint with its auth headers and records the result.
+// Endpoints with health_method HEAD are probed with HEAD until they answer 405 or 501,
+// after which they are probed with GET. A rotated token answered with 401 or 429 is taken
+// out of the rotation and the probe is repeated with the next token.
+func (p *Prober) Probe(ctx context.Context, ep *Endpoint, client *http.Client, path string) ProbeResult {
+	method := p.probeMethod(ep)
+	result, token := p.send(ctx, ep, client, method, path)
+	if method == http.MethodHead && (result.StatusCode == http.StatusMethodNotAllowed || result.StatusCode == http.StatusNotImplemented) {
+		p.mutex.Lock()
+		p.headUnsupported[ep.Config.Name] = true
+		p.mutex.Unlock()
+		method = http.MethodGet
+		result, token = p.send(ctx, ep, client, method, path)
+	}
+	for i := 0; i < len(ep.Config.Tokens) && p.manager != nil && isTokenRejection(result.StatusCode); i++ {
+		if !p.manager.RejectEndpointToken(ep, token, result.StatusCode, 0) {
+			break
+		}
+		result, token = p.send(ctx, ep, client, method, path)
+	}
+
+	p.Record(ep.Config.Name, result)
+	if p.manager != nil && result.Healthy {
+		ep.recordLatency(result.ResponseTime, p.manager.config.Strategy.LatencyAlpha())
+	}
+	return result
+}
+
+// probeMethod returns the method the endpoint is currently probed with
+func (p *Prober) probeMethod(ep *Endpoint) string {
+	if !strings.EqualFold(ep.Config.HealthMethod, http.MethodHead) {
+		return http.MethodGet
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.headUnsupported[ep.Config.Name] {
+		return http.MethodGet
+	}
+	return http.MethodHead
+}
+
+// send performs a single probe request and returns its result with the token it carried
+func (p *Prober) send(ctx context.Context, ep *Endpoint, client *http.Client, method, path string) (ProbeResult, string) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, method, ep.Config.BaseURL()+path, nil)
+	if err != nil {
+		return ProbeResult{Error: err, Time: time.Now()}, ""
+	}
+	token := p.setAuthHeaders(req, ep)
+
+	p.probes.Add(1)
+	resp, err := client.Do(req)
+	responseTime := time.Since(start)
+	if err != nil {
+		return ProbeResult{ResponseTime: responseTime, Error: err, Time: time.Now()}, token
+	}
+	resp.Body.Close()
+
+	return ProbeResult{
+		Healthy:      p.isHealthyProbeStatus(resp.StatusCode),
+		StatusCode:   resp.StatusCode,
+		ResponseTime: responseTime,
+		Time:         time.Now(),
+	}, token
+}
+
+// setAuthHeaders adds the credentials and custom headers a proxied request would carry,
+// so an authentication failure is not mistaken for (or hides) the endpoint's health.
+// It returns the bearer token sent.
+func (p *Prober) setAuthHeaders(req *http.Request, ep *Endpoint) string {
+	token, apiKey := ep.Config.Token, ep.Config.ApiKey
+	if token == "" && len(ep.Config.Tokens) > 0 {
+		token = ep.Config.Tokens[0]
+	}
+	if p.manager != nil {
+		token = p.manager.NextTokenForEndpoint(ep)
+		apiKey = p.manager.GetApiKeyForEndpoint(ep)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	for key, value := range ep.Config.Headers {
+		req.Header.Set(key, value)
+	}
+	return token
+}
+
+// isHealthyStatus reports whether a status shows the endpoint is up: 2xx responses, and
+// 4xx client errors, which still prove the endpoint is reachable
+func isHealthyStatus(statusCode int) bool {
+	return (statusCode >= 200 && statusCode < 300) || (statusCode >= 400 && statusCode < 500)
+}
+
+// isHealthyProbeStatus reports whether a probe status shows the endpoint is up. With
+// health.acceptable_status_codes set, only 2xx and the listed statuses count as healthy.
+func (p *Prober) isHealthyProbeStatus(statusCode int) bool {
+	if p.manager == nil || len(p.manager.config.Health.AcceptableStatusCodes) == 0 {
+		return isHealthyStatus(statusCode)
+	}
+	if statusCode >= 200 && statusCode < 300 {
+		return true
+	}
+	for _, code := range p.manager.config.Health.AcceptableStatusCodes {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Record stores the latest result for an endpoint
+func (p *Prober) Record(name string, result ProbeResult) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.results[name] = result
+}
+
+// Result returns the latest result for an endpoint if it is at most maxAge old
+func (p *Prober) Result(name string, maxAge time.Duration) (ProbeResult, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	result, ok := p.results[name]
+	if !ok || time.Since(result.Time) > maxAge {
+		return ProbeResult{}, false
+	}
+	return result, true
+}
+
+// Forget drops the cached result and HEAD fallback of an endpoint, e.g. after its URL or
+// credentials changed
+func (p *Prober) Forget(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.results, name)
+	delete(p.headUnsupported, name)
+}
+
+// RecordTraffic notes that a request was proxied to an endpoint
+func (p *Prober) RecordTraffic(name string, at time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.lastTraffic[name] = at
+}
+
+// LastTraffic returns when a request was last proxied to an endpoint
+func (p *Prober) LastTraffic(name string) time.Time {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.lastTraffic[name]
+}
+
+// ProbeCount returns the number of probe requests sent
+func (p *Prober) ProbeCount() int64 {
+	return p.probes.Load()
+}
+
+// Reset forgets cached results and HEAD fallbacks, e.g. after a configuration change
+func (p *Prober) Reset() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.results = make(map[string]ProbeResult)
+	p.headUnsupported = make(map[string]bool)
+}
@@ -0,0 +1,233 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// probeRecorder is an upstream that counts the probes it receives by method
+type probeRecorder struct {
+	mu      sync.Mutex
+	methods []string
+	headers []http.Header
+}
+
+func (p *probeRecorder) handler(headStatus int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.methods = append(p.methods, r.Method)
+		p.headers = append(p.headers, r.Header.Clone())
+		p.mu.Unlock()
+		if r.Method == http.MethodHead && headStatus != 0 {
+			w.WriteHeader(headStatus)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (p *probeRecorder) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.methods)
+}
+
+// newProberTestConfig returns a config that probes its endpoints with both fast tests and
+// health checks
+func newProberTestConfig(endpoints ...config.EndpointConfig) *config.Config {
+	cfg := newTestConfig(endpoints...)
+	cfg.Strategy = config.StrategyConfig{Type: "fastest", FastTestEnabled: true, FastTestCacheTTL: time.Minute, FastTestTimeout: time.Second, FastTestPath: "/v1/models"}
+	cfg.Health.PassiveIdleWindow = time.Minute
+	return cfg
+}
+
+func TestProbeHeadWithGetFallback(t *testing.T) {
+	var headOK, headRejected probeRecorder
+	okServer := httptest.NewServer(headOK.handler(0))
+	defer okServer.Close()
+	rejectServer := httptest.NewServer(headRejected.handler(http.StatusMethodNotAllowed))
+	defer rejectServer.Close()
+
+	manager := NewManager(newProberTestConfig(
+		config.EndpointConfig{Name: "head", URL: okServer.URL, HealthMethod: "HEAD", Token: "secret", Headers: map[string]string{"X-Relay": "r1"}, Timeout: time.Second},
+		config.EndpointConfig{Name: "fallback", URL: rejectServer.URL, HealthMethod: "head", ApiKey: "key", Timeout: time.Second},
+	))
+
+	for i := 0; i < 2; i++ {
+		for _, ep := range manager.GetAllEndpoints() {
+			manager.checkEndpointHealth(ep)
+		}
+	}
+
+	if got := headOK.methods; len(got) != 2 || got[0] != http.MethodHead || got[1] != http.MethodHead {
+		t.Errorf("Expected two HEAD probes, got %v", got)
+	}
+	if h := headOK.headers[0]; h.Get("Authorization") != "Bearer secret" || h.Get("X-Relay") != "r1" {
+		t.Errorf("Expected the endpoint's auth and custom headers on the probe, got %v", h)
+	}
+
+	// HEAD is rejected once, then the endpoint is probed with GET only
+	want := []string{http.MethodHead, http.MethodGet, http.MethodGet}
+	if got := headRejected.methods; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Expected probes %v, got %v", want, got)
+	}
+	if headRejected.headers[0].Get("X-Api-Key") != "key" {
+		t.Errorf("Expected the api-key on the probe, got %v", headRejected.headers[0])
+	}
+	if !manager.GetEndpointByNameAny("fallback").IsHealthy() {
+		t.Error("Expected the endpoint to be healthy through the GET fallback")
+	}
+}
+
+func TestFastTestReusesHealthCheckResults(t *testing.T) {
+	var probes probeRecorder
+	server := httptest.NewServer(probes.handler(0))
+	defer server.Close()
+
+	manager := NewManager(newProberTestConfig(
+		config.EndpointConfig{Name: "a", URL: server.URL, Priority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "b", URL: server.URL, Priority: 2, Timeout: time.Second},
+	))
+	manager.performHealthChecks()
+	if probes.count() != 2 {
+		t.Fatalf("Expected one health probe per endpoint, got %d", probes.count())
+	}
+
+	// Fresh health check results serve as fast test results
+	for i := 0; i < 3; i++ {
+		if endpoints := manager.GetFastestEndpointsWithRealTimeTest(context.Background()); len(endpoints) != 2 {
+			t.Fatalf("Expected 2 endpoints, got %d", len(endpoints))
+		}
+	}
+	if probes.count() != 2 {
+		t.Errorf("Expected fast tests to reuse the health probes, got %d probes", probes.count())
+	}
+
+	// A scheduled health check right after a fast test reuses its result too
+	manager.prober.Reset()
+	manager.GetFastestEndpointsWithRealTimeTest(context.Background())
+	before := probes.count()
+	manager.runHealthChecks(true)
+	if probes.count() != before {
+		t.Errorf("Expected the scheduled health check to reuse fresh fast test results, got %d new probes", probes.count()-before)
+	}
+}
+
+func TestPassiveModeReducesProbes(t *testing.T) {
+	var busyProbes, idleProbes probeRecorder
+	busy := httptest.NewServer(busyProbes.handler(0))
+	defer busy.Close()
+	idle := httptest.NewServer(idleProbes.handler(0))
+	defer idle.Close()
+
+	cfg := newProberTestConfig(
+		config.EndpointConfig{Name: "busy", URL: busy.URL, Timeout: time.Second},
+		config.EndpointConfig{Name: "idle", URL: idle.URL, Timeout: time.Second},
+	)
+	cfg.Strategy.FastTestEnabled = false
+	cfg.Health.PassiveMode = true
+	cfg.Health.CheckInterval = time.Millisecond // So probe results are never reused between rounds
+	manager := NewManager(cfg)
+
+	// Steady traffic to "busy" between scheduled checks
+	const rounds = 5
+	for i := 0; i < rounds; i++ {
+		manager.RecordRequestOutcome("busy", http.StatusOK, nil)
+		manager.runHealthChecks(true)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if busyProbes.count() != 0 {
+		t.Errorf("Expected no probes for an endpoint with steady traffic, got %d", busyProbes.count())
+	}
+	if idleProbes.count() != rounds {
+		t.Errorf("Expected the idle endpoint to be probed every round, got %d", idleProbes.count())
+	}
+
+	// Real failures mark the endpoint unhealthy, and it is then probed for recovery
+	manager.RecordRequestOutcome("busy", 0, errors.New("connection refused"))
+	ep := manager.GetEndpointByNameAny("busy")
+	if ep.IsHealthy() {
+		t.Fatal("Expected a failed request to mark the endpoint unhealthy in passive mode")
+	}
+	manager.runHealthChecks(true)
+	if busyProbes.count() != 1 || !ep.IsHealthy() {
+		t.Errorf("Expected an unhealthy endpoint to be probed and recover, got %d probes (healthy=%v)", busyProbes.count(), ep.IsHealthy())
+	}
+
+	// Client errors still prove the endpoint is up
+	manager.RecordRequestOutcome("busy", http.StatusBadRequest, nil)
+	if !ep.IsHealthy() {
+		t.Error("Expected a 4xx response to keep the endpoint healthy")
+	}
+
+	// Without passive mode, traffic does not replace probes
+	cfg.Health.PassiveMode = false
+	before := busyProbes.count()
+	manager.RecordRequestOutcome("busy", http.StatusOK, nil)
+	time.Sleep(2 * time.Millisecond)
+	manager.runHealthChecks(true)
+	if busyProbes.count() != before+1 {
+		t.Errorf("Expected a probe without passive mode, got %d", busyProbes.count()-before)
+	}
+}
+
+func TestProbeUsesResolvedToken(t *testing.T) {
+	var probes probeRecorder
+	server := httptest.NewServer(probes.handler(0))
+	defer server.Close()
+
+	// The second endpoint has no token of its own and inherits the first one's
+	manager := NewManager(newProberTestConfig(
+		config.EndpointConfig{Name: "primary", URL: server.URL, Group: "main", Token: "shared", Timeout: time.Second},
+		config.EndpointConfig{Name: "inherits", URL: server.URL, Group: "main", Headers: map[string]string{"X-Tenant": "t1"}, Timeout: time.Second},
+	))
+	manager.checkEndpointHealth(manager.GetEndpointByNameAny("inherits"))
+
+	if probes.count() != 1 {
+		t.Fatalf("Expected one probe, got %d", probes.count())
+	}
+	if h := probes.headers[0]; h.Get("Authorization") != "Bearer shared" || h.Get("X-Tenant") != "t1" {
+		t.Errorf("Expected the inherited token and custom headers on the probe, got %v", h)
+	}
+}
+
+func TestAcceptableStatusCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		acceptable []int
+		status     int
+		healthy    bool
+	}{
+		{"default 2xx", nil, http.StatusOK, true},
+		{"default 4xx", nil, http.StatusUnauthorized, true},
+		{"default 5xx", nil, http.StatusBadGateway, false},
+		{"listed 4xx", []int{401, 403}, http.StatusForbidden, true},
+		{"unlisted 4xx", []int{401, 403}, http.StatusNotFound, false},
+		{"2xx always healthy", []int{401}, http.StatusNoContent, true},
+		{"listed 5xx", []int{503}, http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			cfg := newProberTestConfig(config.EndpointConfig{Name: "ep", URL: server.URL, Timeout: time.Second})
+			cfg.Health.AcceptableStatusCodes = tt.acceptable
+			manager := NewManager(cfg)
+			ep := manager.GetEndpointByNameAny("ep")
+			result := manager.prober.Probe(context.Background(), ep, server.Client(), "/v1/models")
+			if result.Healthy != tt.healthy {
+				t.Errorf("Expected healthy=%v for status %d, got %v", tt.healthy, tt.status, result.Healthy)
+			}
+		})
+	}
+}
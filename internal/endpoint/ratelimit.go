@@ -0,0 +1,200 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// ErrRateLimitExceeded is returned when an endpoint's rate limit bucket is empty and the
+// request may not (on_exceeded: failover) or no longer (max_wait reached) wait for a token
+var ErrRateLimitExceeded = errors.New("endpoint rate limit exceeded")
+
+// rateBucket is a token bucket refilled at requests_per_minute and holding up to burst tokens.
+// Queued requests reserve a token ahead of time, which can take the fill below zero.
+type rateBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateBucket creates a full bucket for a rate limit
+func newRateBucket(limit *config.EndpointRateLimitConfig) *rateBucket {
+	b := &rateBucket{last: time.Now()}
+	b.configure(limit)
+	b.tokens = b.burst
+	return b
+}
+
+// configure applies a (possibly changed) rate limit, keeping the current fill
+func (b *rateBucket) configure(limit *config.EndpointRateLimitConfig) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill(time.Now())
+	b.rate = float64(limit.RequestsPerMinute) / 60
+	b.burst = float64(limit.Burst)
+	if limit.Burst <= 0 {
+		b.burst = float64(limit.RequestsPerMinute)
+	}
+	b.tokens = math.Min(b.tokens, b.burst)
+}
+
+// refill adds the tokens earned since the last refill; the caller holds the mutex
+func (b *rateBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes a token if one becomes available within maxWait and returns how long the
+// caller must wait before using it
+func (b *rateBucket) reserve(maxWait time.Duration) (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// cancel gives back a reserved token that was not used
+func (b *rateBucket) cancel() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill(time.Now())
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// fill returns the tokens currently in the bucket (negative while requests are queued)
+// and the bucket size
+func (b *rateBucket) fill() (float64, int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill(time.Now())
+	return b.tokens, int(b.burst)
+}
+
+// wait returns how long until a token is available
+func (b *rateBucket) wait() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// RateLimit returns the endpoint's rate limit, or nil when it is unlimited
+func (e *Endpoint) RateLimit() *config.EndpointRateLimitConfig {
+	if e.rate == nil {
+		return nil
+	}
+	return e.Config.RateLimit
+}
+
+// AcquireRateToken takes a token from the endpoint's rate limit bucket before a request is
+// sent. When the bucket is empty it returns ErrRateLimitExceeded right away with on_exceeded
+// failover, and with queue waits for a token unless that would take longer than max_wait.
+// Unlimited endpoints always succeed. A cancelled ctx returns its error.
+func (e *Endpoint) AcquireRateToken(ctx context.Context) error {
+	limit := e.RateLimit()
+	if limit == nil {
+		return nil
+	}
+
+	maxWait := time.Duration(0)
+	if limit.OnExceeded == config.RateLimitQueue {
+		maxWait = limit.MaxWait
+	}
+	wait, ok := e.rate.reserve(maxWait)
+	if !ok {
+		return ErrRateLimitExceeded
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		e.rate.cancel()
+		return ctx.Err()
+	}
+}
+
+// RateTokens returns the tokens left in the endpoint's rate limit bucket (negative while
+// requests are queued) and the bucket size; both are 0 for unlimited endpoints
+func (e *Endpoint) RateTokens() (available float64, burst int) {
+	if e.rate == nil {
+		return 0, 0
+	}
+	return e.rate.fill()
+}
+
+// RateLimitWait returns how long until the endpoint's rate limit lets another request
+// through, 0 when it is unlimited or has tokens left
+func (e *Endpoint) RateLimitWait() time.Duration {
+	if e.rate == nil {
+		return 0
+	}
+	return e.rate.wait()
+}
+
+// rateBucketFor returns the shared rate limit bucket for an endpoint, or nil when it is
+// unlimited. Like in-flight counters, buckets outlive config reloads so a reload does not
+// refill them; a changed limit is applied to the existing bucket.
+func (m *Manager) rateBucketFor(epCfg config.EndpointConfig) *rateBucket {
+	m.rateMutex.Lock()
+	defer m.rateMutex.Unlock()
+
+	if epCfg.RateLimit == nil {
+		delete(m.rateBuckets, epCfg.Name)
+		return nil
+	}
+	if m.rateBuckets == nil {
+		m.rateBuckets = make(map[string]*rateBucket)
+	}
+	bucket, exists := m.rateBuckets[epCfg.Name]
+	if !exists {
+		bucket = newRateBucket(epCfg.RateLimit)
+		m.rateBuckets[epCfg.Name] = bucket
+	} else {
+		bucket.configure(epCfg.RateLimit)
+	}
+	return bucket
+}
+
+// pruneRateBuckets drops buckets for endpoints that no longer exist
+func (m *Manager) pruneRateBuckets(endpoints []*Endpoint) {
+	m.rateMutex.Lock()
+	defer m.rateMutex.Unlock()
+
+	current := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		current[ep.Config.Name] = true
+	}
+	for name := range m.rateBuckets {
+		if !current[name] {
+			delete(m.rateBuckets, name)
+		}
+	}
+}
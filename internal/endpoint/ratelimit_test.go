@@ -0,0 +1,89 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func newRateLimitTestConfig(limit *config.EndpointRateLimitConfig) *config.Config {
+	return newTestConfig(config.EndpointConfig{
+		Name: "limited", URL: "https://limited.example.com", Priority: 1, Group: "main", GroupPriority: 1, RateLimit: limit,
+	})
+}
+
+func TestRateLimitBucket(t *testing.T) {
+	// 600/min refills a token every 100ms
+	cfg := newRateLimitTestConfig(&config.EndpointRateLimitConfig{RequestsPerMinute: 600, Burst: 2, OnExceeded: config.RateLimitFailover})
+	manager := NewManager(cfg)
+	ep := manager.GetEndpointByNameAny("limited")
+
+	for i := 0; i < 2; i++ {
+		if err := ep.AcquireRateToken(context.Background()); err != nil {
+			t.Fatalf("Expected burst token %d, got %v", i, err)
+		}
+	}
+	if err := ep.AcquireRateToken(context.Background()); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Fatalf("Expected ErrRateLimitExceeded once the burst is used, got %v", err)
+	}
+	if wait := ep.RateLimitWait(); wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("Expected a wait of up to 100ms, got %v", wait)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	if err := ep.AcquireRateToken(context.Background()); err != nil {
+		t.Errorf("Expected a refilled token, got %v", err)
+	}
+
+	// A reload keeps the fill instead of handing out a fresh burst
+	manager.UpdateConfig(newRateLimitTestConfig(&config.EndpointRateLimitConfig{RequestsPerMinute: 600, Burst: 5, OnExceeded: config.RateLimitFailover}))
+	ep = manager.GetEndpointByNameAny("limited")
+	if tokens, burst := ep.RateTokens(); tokens >= 1 || burst != 5 {
+		t.Errorf("Expected the drained bucket to survive the reload with burst 5, got %.2f/%d", tokens, burst)
+	}
+
+	// Removing the limit makes the endpoint unlimited
+	manager.UpdateConfig(newRateLimitTestConfig(nil))
+	ep = manager.GetEndpointByNameAny("limited")
+	if ep.RateLimit() != nil || ep.AcquireRateToken(context.Background()) != nil {
+		t.Error("Expected an endpoint without rate_limit to be unlimited")
+	}
+}
+
+func TestRateLimitQueue(t *testing.T) {
+	cfg := newRateLimitTestConfig(&config.EndpointRateLimitConfig{RequestsPerMinute: 600, Burst: 1, OnExceeded: config.RateLimitQueue, MaxWait: 150 * time.Millisecond})
+	ep := NewManager(cfg).GetEndpointByNameAny("limited")
+
+	if err := ep.AcquireRateToken(context.Background()); err != nil {
+		t.Fatalf("Expected the first token, got %v", err)
+	}
+
+	// The next token is ~100ms away, within max_wait
+	start := time.Now()
+	if err := ep.AcquireRateToken(context.Background()); err != nil {
+		t.Fatalf("Expected the queued request to get a token, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected the request to wait for a token, took %v", elapsed)
+	}
+
+	// A cancelled wait gives its reserved token back
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ep.AcquireRateToken(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the cancelled wait to fail with the context error, got %v", err)
+	}
+	if tokens, _ := ep.RateTokens(); tokens < 0 {
+		t.Errorf("Expected the cancelled reservation to be returned, got %.2f tokens", tokens)
+	}
+
+	// Two queued requests would need ~200ms, beyond max_wait
+	go ep.AcquireRateToken(context.Background())
+	time.Sleep(5 * time.Millisecond)
+	if err := ep.AcquireRateToken(context.Background()); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("Expected ErrRateLimitExceeded beyond max_wait, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package endpoint
+
+import (
+	"time"
+)
+
+// ReadinessReport describes whether the forwarder can currently serve requests
+type ReadinessReport struct {
+	Ready              bool                `json:"ready"`
+	ReadyEndpoints     []string            `json:"ready_endpoints"`
+	Groups             []GroupReadiness    `json:"groups"`
+	UnhealthyEndpoints []UnhealthyEndpoint `json:"unhealthy_endpoints"`
+}
+
+// GroupReadiness describes the state of a group in a readiness report
+type GroupReadiness struct {
+	Name              string `json:"name"`
+	Priority          int    `json:"priority"`
+	Active            bool   `json:"active"`
+	InCooldown        bool   `json:"in_cooldown"`
+	CooldownRemaining string `json:"cooldown_remaining,omitempty"`
+}
+
+// UnhealthyEndpoint describes an endpoint that cannot currently take requests
+type UnhealthyEndpoint struct {
+	Name   string `json:"name"`
+	Group  string `json:"group"`
+	Reason string `json:"reason"` // "unhealthy", "maintenance" or "rate_limited"
+}
+
+// GetReadiness reports whether at least one endpoint that counts toward readiness is
+// healthy and belongs to an active (non-cooldown) group. Endpoints and groups listed in
+// health.readiness_exclude_endpoints / health.readiness_exclude_groups are ignored.
+func (m *Manager) GetReadiness() *ReadinessReport {
+	cfg := m.GetConfig()
+	excludedEndpoints := make(map[string]bool, len(cfg.Health.ReadinessExcludeEndpoints))
+	for _, name := range cfg.Health.ReadinessExcludeEndpoints {
+		excludedEndpoints[name] = true
+	}
+	excludedGroups := make(map[string]bool, len(cfg.Health.ReadinessExcludeGroups))
+	for _, name := range cfg.Health.ReadinessExcludeGroups {
+		excludedGroups[name] = true
+	}
+
+	report := &ReadinessReport{
+		ReadyEndpoints:     []string{},
+		Groups:             []GroupReadiness{},
+		UnhealthyEndpoints: []UnhealthyEndpoint{},
+	}
+
+	activeGroups := make(map[string]bool)
+	for _, group := range m.groupManager.GetAllGroups() {
+		if excludedGroups[group.Name] {
+			continue
+		}
+		remaining := m.groupManager.GetGroupCooldownRemaining(group.Name)
+		groupReadiness := GroupReadiness{
+			Name:       group.Name,
+			Priority:   group.Priority,
+			Active:     group.IsActive,
+			InCooldown: remaining > 0,
+		}
+		if remaining > 0 {
+			groupReadiness.CooldownRemaining = remaining.Round(time.Second).String()
+		}
+		report.Groups = append(report.Groups, groupReadiness)
+		if group.IsActive && remaining == 0 {
+			activeGroups[group.Name] = true
+		}
+	}
+
+	now := time.Now()
+	for _, ep := range m.GetAllEndpoints() {
+		groupName := ep.Config.Group
+		if groupName == "" {
+			groupName = "Default"
+		}
+		if excludedEndpoints[ep.Config.Name] || excludedGroups[groupName] {
+			continue
+		}
+
+		status := ep.GetStatus()
+		switch {
+		case status.Disabled:
+			report.UnhealthyEndpoints = append(report.UnhealthyEndpoints, UnhealthyEndpoint{Name: ep.Config.Name, Group: groupName, Reason: "maintenance"})
+		case !status.Healthy:
+			report.UnhealthyEndpoints = append(report.UnhealthyEndpoints, UnhealthyEndpoint{Name: ep.Config.Name, Group: groupName, Reason: "unhealthy"})
+		case status.IsRateLimited(now):
+			report.UnhealthyEndpoints = append(report.UnhealthyEndpoints, UnhealthyEndpoint{Name: ep.Config.Name, Group: groupName, Reason: "rate_limited"})
+		case activeGroups[groupName]:
+			report.ReadyEndpoints = append(report.ReadyEndpoints, ep.Config.Name)
+		}
+	}
+
+	report.Ready = len(report.ReadyEndpoints) > 0
+	return report
+}
@@ -0,0 +1,106 @@
+package endpoint
+
+import (
+	"testing"
+
+	"endpoint_forwarder/config"
+)
+
+func newReadinessTestManager() *Manager {
+	return NewManager(newTestConfig(
+		config.EndpointConfig{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "main-2", URL: "http://127.0.0.1:1", Priority: 2, Group: "main", GroupPriority: 1},
+		config.EndpointConfig{Name: "backup-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "backup", GroupPriority: 2},
+	))
+}
+
+func setEndpointHealthy(m *Manager, name string, healthy bool) {
+	ep := m.GetEndpointByNameAny(name)
+	ep.mutex.Lock()
+	ep.Status.Healthy = healthy
+	ep.mutex.Unlock()
+}
+
+func TestReadinessFollowsEndpointHealth(t *testing.T) {
+	m := newReadinessTestManager()
+
+	report := m.GetReadiness()
+	if !report.Ready || len(report.ReadyEndpoints) != 2 {
+		t.Fatalf("Expected ready with 2 ready endpoints, got ready=%v endpoints=%v", report.Ready, report.ReadyEndpoints)
+	}
+
+	// Healthy backup does not count while its group is not the active one
+	setEndpointHealthy(m, "main-1", false)
+	setEndpointHealthy(m, "main-2", false)
+	report = m.GetReadiness()
+	if report.Ready {
+		t.Error("Expected not ready when every endpoint of the active group is unhealthy")
+	}
+	if len(report.UnhealthyEndpoints) != 2 || report.UnhealthyEndpoints[0].Reason != "unhealthy" {
+		t.Errorf("Expected 2 unhealthy endpoints, got %+v", report.UnhealthyEndpoints)
+	}
+
+	setEndpointHealthy(m, "main-2", true)
+	m.SetEndpointMaintenance("main-2", true, "test")
+	report = m.GetReadiness()
+	if report.Ready {
+		t.Error("Expected endpoint in maintenance not to count toward readiness")
+	}
+
+	// Leave maintenance directly to avoid the async health re-check against the fake URL
+	ep := m.GetEndpointByNameAny("main-2")
+	ep.mutex.Lock()
+	ep.Status.Disabled = false
+	ep.mutex.Unlock()
+	if !m.GetReadiness().Ready {
+		t.Error("Expected ready again once an endpoint recovers")
+	}
+}
+
+func TestReadinessFollowsGroupCooldown(t *testing.T) {
+	m := newReadinessTestManager()
+
+	m.GetGroupManager().SetGroupCooldown("main")
+	report := m.GetReadiness()
+	if !report.Ready || len(report.ReadyEndpoints) != 1 || report.ReadyEndpoints[0] != "backup-1" {
+		t.Fatalf("Expected backup group to take over readiness, got %+v", report)
+	}
+
+	m.GetGroupManager().SetGroupCooldown("backup")
+	report = m.GetReadiness()
+	if report.Ready {
+		t.Error("Expected not ready when every group is in cooldown")
+	}
+	for _, group := range report.Groups {
+		if !group.InCooldown || group.CooldownRemaining == "" {
+			t.Errorf("Expected group %s to report its cooldown, got %+v", group.Name, group)
+		}
+	}
+}
+
+func TestReadinessExclusions(t *testing.T) {
+	m := newReadinessTestManager()
+	m.GetConfig().Health.ReadinessExcludeEndpoints = []string{"main-1"}
+
+	setEndpointHealthy(m, "main-2", false)
+	report := m.GetReadiness()
+	if report.Ready {
+		t.Error("Expected excluded endpoint not to make the instance ready")
+	}
+	for _, ep := range report.UnhealthyEndpoints {
+		if ep.Name == "main-1" {
+			t.Error("Expected excluded endpoint to be left out of the report")
+		}
+	}
+
+	m.GetConfig().Health.ReadinessExcludeEndpoints = nil
+	m.GetConfig().Health.ReadinessExcludeGroups = []string{"main"}
+	m.GetGroupManager().SetGroupCooldown("main")
+	report = m.GetReadiness()
+	if !report.Ready {
+		t.Error("Expected backup group to make the instance ready")
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Name != "backup" {
+		t.Errorf("Expected excluded group to be left out of the report, got %+v", report.Groups)
+	}
+}
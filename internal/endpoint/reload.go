@@ -0,0 +1,104 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// endpointCredentials are the static credentials an endpoint sends upstream, as resolved at
+// request time (the endpoint's own, its group's, or a sibling's)
+type endpointCredentials struct {
+	token  string
+	apiKey string
+}
+
+// resolveCredentials resolves the static credentials of all current endpoints. OAuth2
+// endpoints are left out; their token sources are kept in sync by syncTokenSources.
+func (m *Manager) resolveCredentials() map[string]endpointCredentials {
+	endpoints := m.GetAllEndpoints()
+	credentials := make(map[string]endpointCredentials, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.Config.IsOAuth2() {
+			continue
+		}
+		creds := endpointCredentials{apiKey: m.GetApiKeyForEndpoint(ep)}
+		// A tokens list is part of the endpoint's own config; its rotation position is not a change
+		if len(ep.Config.Tokens) == 0 {
+			creds.token = m.GetTokenForEndpoint(ep)
+		}
+		credentials[ep.Config.Name] = creds
+	}
+	return credentials
+}
+
+// changedEndpoints returns the endpoints that are new or changed by a reload: their own config
+// differs, or the credentials resolved for them do (e.g. a group token was edited). Endpoints
+// whose own config is unchanged but whose credentials changed get their runtime state reset,
+// like endpoints whose config changed.
+func (m *Manager) changedEndpoints(oldEndpoints map[string]*Endpoint, oldCredentials map[string]endpointCredentials) []*Endpoint {
+	newCredentials := m.resolveCredentials()
+
+	var changed []*Endpoint
+	for _, ep := range m.GetAllEndpoints() {
+		old, exists := oldEndpoints[ep.Config.Name]
+		if !exists || !reflect.DeepEqual(old.Config, ep.Config) {
+			changed = append(changed, ep)
+			continue
+		}
+		if oldCredentials[ep.Config.Name] != newCredentials[ep.Config.Name] {
+			ep.resetRuntimeState()
+			changed = append(changed, ep)
+		}
+	}
+	return changed
+}
+
+// resetRuntimeState clears failure counters, rate limiting and the circuit breaker, as for an
+// endpoint created from a changed config
+func (e *Endpoint) resetRuntimeState() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.Status.Healthy = true
+	e.Status.ConsecutiveFails = 0
+	e.Status.ConsecutiveOKs = 0
+	e.Status.RateLimitedUntil = time.Time{}
+	e.Status.BreakerOpenUntil = time.Time{}
+	e.Status.BreakerFailures = 0
+	e.Status.breakerProbes = 0
+}
+
+// recheckEndpoints health checks the given endpoints right away, in the background, so a
+// reload that fixed an endpoint's URL or credentials takes effect without waiting for the
+// next check interval. The checks use the config and client of the update that asked for
+// them. Cached probe results from before the reload are dropped first.
+func (m *Manager) recheckEndpoints(cfg *config.Config, client *http.Client, endpoints []*Endpoint) {
+	var names []string
+	for _, ep := range endpoints {
+		m.prober.Forget(ep.Config.Name)
+		if !ep.IsDisabled() {
+			names = append(names, ep.Config.Name)
+		}
+	}
+	if len(names) == 0 || m.ctx.Err() != nil {
+		return
+	}
+
+	slog.Info(fmt.Sprintf("🔄 配置更新后立即检查 %d 个新增或变更的端点: %s", len(names), strings.Join(names, ", ")))
+	for _, ep := range endpoints {
+		if ep.IsDisabled() {
+			continue
+		}
+		m.wg.Add(1)
+		go func(ep *Endpoint) {
+			defer m.wg.Done()
+			m.probeEndpointHealth(cfg, client, ep)
+		}(ep)
+	}
+}
@@ -0,0 +1,173 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// newTokenCheckingUpstream answers probes with 200 for the given token and 500 otherwise,
+// counting the probes it receives
+func newTokenCheckingUpstream(t *testing.T, token string, probes *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// waitForProbes polls until the upstream received more than count probes
+func waitForProbes(t *testing.T, probes *atomic.Int64, count int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if probes.Load() > count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Expected the endpoint to be health checked right after the reload")
+}
+
+// waitForHealthy polls until the endpoint reports the expected health
+func waitForHealthy(t *testing.T, m *Manager, name string, healthy bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if m.GetEndpointByNameAny(name).IsHealthy() == healthy {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %s to be healthy=%v", name, healthy)
+}
+
+func TestReloadRechecksEndpointWithNewToken(t *testing.T) {
+	var editedProbes, untouchedProbes atomic.Int64
+	edited := newTokenCheckingUpstream(t, "sk-good", &editedProbes)
+	untouched := newTokenCheckingUpstream(t, "sk-good", &untouchedProbes)
+
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "edited", URL: edited.URL, Priority: 1, Group: "main", GroupPriority: 1, Token: "sk-bad", Timeout: time.Second},
+		config.EndpointConfig{Name: "untouched", URL: untouched.URL, Priority: 2, Group: "main", GroupPriority: 1, Token: "sk-wrong", Timeout: time.Second},
+	)
+	m := NewManager(cfg)
+	defer m.Stop()
+	m.performHealthChecks()
+	if m.GetEndpointByNameAny("edited").IsHealthy() || m.GetEndpointByNameAny("untouched").IsHealthy() {
+		t.Fatal("Expected both endpoints to fail their health check with a wrong token")
+	}
+	editedBefore, untouchedBefore := editedProbes.Load(), untouchedProbes.Load()
+
+	newCfg := *cfg
+	newCfg.Endpoints = append([]config.EndpointConfig(nil), cfg.Endpoints...)
+	newCfg.Endpoints[0].Token = "sk-good"
+	m.UpdateConfig(&newCfg)
+
+	waitForProbes(t, &editedProbes, editedBefore)
+	waitForHealthy(t, m, "edited", true)
+	if m.GetEndpointByNameAny("untouched").IsHealthy() {
+		t.Error("Expected the untouched endpoint to keep its unhealthy status")
+	}
+	if untouchedProbes.Load() != untouchedBefore {
+		t.Error("Expected the untouched endpoint not to be probed again")
+	}
+}
+
+func TestReloadRechecksEndpointsWhenGroupTokenChanges(t *testing.T) {
+	var probes atomic.Int64
+	upstream := newTokenCheckingUpstream(t, "sk-new-group", &probes)
+
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "main-1", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+	)
+	cfg.Groups = map[string]config.GroupSettings{"main": {Token: "sk-old-group"}}
+	m := NewManager(cfg)
+	defer m.Stop()
+	m.performHealthChecks()
+	if m.GetEndpointByNameAny("main-1").IsHealthy() {
+		t.Fatal("Expected the endpoint to fail its health check with the old group token")
+	}
+	before := probes.Load()
+
+	// Only the group token changes; the endpoint's own config stays the same
+	newCfg := *cfg
+	newCfg.Groups = map[string]config.GroupSettings{"main": {Token: "sk-new-group"}}
+	m.UpdateConfig(&newCfg)
+
+	waitForProbes(t, &probes, before)
+	waitForHealthy(t, m, "main-1", true)
+}
+
+func TestStopWaitsForReloadRechecks(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	t.Cleanup(upstream.Close)
+	t.Cleanup(func() { close(release) })
+
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "moved", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+	)
+	m := NewManager(cfg)
+
+	newCfg := *cfg
+	newCfg.Endpoints = append([]config.EndpointConfig(nil), cfg.Endpoints...)
+	newCfg.Endpoints[0].URL = upstream.URL
+	reloaded := time.Now()
+	m.UpdateConfig(&newCfg)
+
+	select {
+	case <-entered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the moved endpoint to be health checked right after the reload")
+	}
+
+	// The recheck is cancelled by Stop, which returns only once it has recorded the result
+	m.Stop()
+	if status := m.GetEndpointByNameAny("moved").GetStatus(); !status.LastCheck.After(reloaded) || status.Healthy {
+		t.Errorf("Expected the cancelled recheck to be recorded before Stop returned, got %+v", status)
+	}
+}
+
+func TestUpdateConfigWhileSelecting(t *testing.T) {
+	cfg := newTestConfig(
+		config.EndpointConfig{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+	)
+	m := NewManager(cfg)
+	defer m.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			newCfg := *cfg
+			m.UpdateConfig(&newCfg)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			m.GetHealthyEndpoints()
+			_ = m.GetConfig().Strategy.Type
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package endpoint
+
+import (
+	"fmt"
+	"time"
+)
+
+// SelectionTrace records the decisions of one pass through endpoint selection, so a routing
+// decision can be explained without acting on it. Selection that records into a trace has no
+// side effects: round-robin positions and the sticky fastest endpoint stay where they are, no
+// fast tests run and nothing is logged.
+type SelectionTrace struct {
+	Excluded []Exclusion // Endpoints selection left out, in the order it considered them
+	Notes    []string    // How the remaining endpoints were ordered
+}
+
+// Exclusion is an endpoint selection left out and the reason why
+type Exclusion struct {
+	Endpoint string `json:"endpoint"`
+	Group    string `json:"group"`
+	Reason   string `json:"reason"`
+}
+
+// Exclude records that selection left out an endpoint
+func (t *SelectionTrace) Exclude(ep *Endpoint, reason string) {
+	group := ep.Config.Group
+	if group == "" {
+		group = "Default"
+	}
+	t.Excluded = append(t.Excluded, Exclusion{Endpoint: ep.Config.Name, Group: group, Reason: reason})
+}
+
+// Note records an ordering decision
+func (t *SelectionTrace) Note(format string, args ...any) {
+	t.Notes = append(t.Notes, fmt.Sprintf(format, args...))
+}
+
+// unselectableReason returns why the endpoint cannot take requests right now, or "" when it
+// can (caller holds the lock)
+func (s EndpointStatus) unselectableReason(now time.Time) string {
+	switch {
+	case s.Disabled:
+		return "in maintenance mode"
+	case !s.Healthy && s.AuthError != "":
+		return "unhealthy: " + s.AuthError
+	case !s.Healthy:
+		return fmt.Sprintf("unhealthy (%d consecutive failed checks)", s.ConsecutiveFails)
+	case s.IsRateLimited(now):
+		return fmt.Sprintf("rate limited by its upstream for another %s", s.RateLimitedUntil.Sub(now).Round(time.Second))
+	case s.BreakerState(now) == BreakerOpen:
+		return fmt.Sprintf("circuit breaker open for another %s", s.BreakerOpenUntil.Sub(now).Round(time.Second))
+	case s.breakerBlocks(now):
+		return "circuit breaker half-open with all probe slots in use"
+	}
+	return ""
+}
+
+// selectable returns the endpoints that can take requests right now, recording the others
+// in trace
+func (m *Manager) selectable(endpoints []*Endpoint, trace *SelectionTrace) []*Endpoint {
+	now := time.Now()
+	var healthy []*Endpoint
+	for _, endpoint := range endpoints {
+		endpoint.mutex.RLock()
+		reason := endpoint.Status.unselectableReason(now)
+		endpoint.mutex.RUnlock()
+		if reason == "" {
+			reason = m.budgetBlockReason(endpoint)
+		}
+		if reason == "" {
+			healthy = append(healthy, endpoint)
+		} else if trace != nil {
+			trace.Exclude(endpoint, reason)
+		}
+	}
+	return healthy
+}
@@ -0,0 +1,601 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+	"gopkg.in/yaml.v3"
+)
+
+// RuntimeState holds runtime adjustments that are not part of the YAML config
+// (priority overrides, maintenance flags, group priority overrides and group cooldowns)
+// so they survive restarts
+type RuntimeState struct {
+	UpdatedAt time.Time                       `yaml:"updated_at" json:"updatedAt"`
+	Endpoints map[string]EndpointRuntimeState `yaml:"endpoints,omitempty" json:"endpoints"`
+	Groups    map[string]GroupRuntimeState    `yaml:"groups,omitempty" json:"groups"`
+}
+
+// EndpointRuntimeState holds the runtime overrides of a single endpoint
+type EndpointRuntimeState struct {
+	Priority *int  `yaml:"priority,omitempty" json:"priority,omitempty"` // Priority override set via TUI/WebUI
+	Disabled *bool `yaml:"disabled,omitempty" json:"disabled,omitempty"` // Maintenance flag differing from the config
+}
+
+// GroupRuntimeState holds the runtime state of a single group
+type GroupRuntimeState struct {
+	CooldownUntil time.Time `yaml:"cooldown_until,omitempty" json:"cooldownUntil"`
+	Priority      *int      `yaml:"priority,omitempty" json:"priority,omitempty"` // Group priority override set via WebUI
+}
+
+// StateStore persists RuntimeState to a YAML file. Saves are debounced so that
+// rapid edits result in a single write, and written atomically via rename.
+type StateStore struct {
+	path      string
+	saveDelay time.Duration
+	snapshot  func() *RuntimeState
+	timer     *time.Timer
+	mutex     sync.Mutex
+	writeMu   sync.Mutex
+}
+
+// NewStateStore creates a state store writing to path, debouncing saves by saveDelay
+func NewStateStore(path string, saveDelay time.Duration) *StateStore {
+	return &StateStore{
+		path:      path,
+		saveDelay: saveDelay,
+	}
+}
+
+// Path returns the state file path
+func (s *StateStore) Path() string {
+	return s.path
+}
+
+// Load reads the state file. A missing file yields an empty state.
+func (s *StateStore) Load() (*RuntimeState, error) {
+	state := &RuntimeState{}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	return state, nil
+}
+
+// ScheduleSave schedules a debounced save. snapshot is called when the timer fires,
+// so the latest state is written even if several changes happened in between.
+func (s *StateStore) ScheduleSave(snapshot func() *RuntimeState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.snapshot = snapshot
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(s.saveDelay, func() {
+		if err := s.Flush(); err != nil {
+			slog.Error(fmt.Sprintf("❌ [运行时状态] 保存状态文件失败: %v", err))
+		}
+	})
+}
+
+// Flush writes any pending state immediately
+func (s *StateStore) Flush() error {
+	s.mutex.Lock()
+	snapshot := s.snapshot
+	s.snapshot = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mutex.Unlock()
+
+	if snapshot == nil {
+		return nil
+	}
+	return s.write(snapshot())
+}
+
+// Remove cancels any pending save and deletes the state file
+func (s *StateStore) Remove() error {
+	s.mutex.Lock()
+	s.snapshot = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mutex.Unlock()
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
+
+// write atomically writes the state by writing a temp file and renaming it over the target
+func (s *StateStore) write(state *RuntimeState) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp state file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
+
+// SetStateStore enables runtime state persistence: the state file is restored right away
+// and rewritten whenever priorities, maintenance flags or group cooldowns change
+func (m *Manager) SetStateStore(store *StateStore) {
+	m.stateStore = store
+	m.restoreState()
+	m.groupManager.SetStateChangeHandler(m.saveState)
+}
+
+// restoreState applies the persisted runtime state, dropping entries for endpoints
+// or groups that no longer exist in the config. It runs before the manager is started.
+func (m *Manager) restoreState() {
+	state, err := m.stateStore.Load()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [运行时状态] 无法加载状态文件 %s: %v", m.stateStore.Path(), err))
+		return
+	}
+
+	dropped := false
+	restoredEndpoints := 0
+	restoredGroups := make(map[string]bool)
+
+	m.updateMutex.Lock()
+	defer m.updateMutex.Unlock()
+	cfg := copyConfig(m.GetConfig())
+
+	m.stateMutex.Lock()
+	for name, epState := range state.Endpoints {
+		idx := findConfigEndpoint(cfg, name)
+		if idx < 0 {
+			slog.Warn(fmt.Sprintf("🧹 [运行时状态] 丢弃过期条目: 端点 %s 已不在配置中", name))
+			dropped = true
+			continue
+		}
+
+		if epState.Priority != nil {
+			m.priorityOverrides[name] = &priorityOverride{
+				Original: cfg.Endpoints[idx].Priority,
+				Priority: *epState.Priority,
+			}
+		}
+		if epState.Disabled != nil {
+			if ep := m.GetEndpointByNameAny(name); ep != nil {
+				ep.mutex.Lock()
+				ep.Status.Disabled = *epState.Disabled
+				ep.mutex.Unlock()
+			}
+		}
+		restoredEndpoints++
+	}
+	for name, groupState := range state.Groups {
+		if groupState.Priority != nil {
+			original, _ := groupPriority(cfg, name)
+			m.groupPriorityOverrides[name] = &priorityOverride{Original: original, Priority: *groupState.Priority}
+		}
+	}
+	if m.applyPriorityOverrides(cfg) {
+		dropped = true
+	}
+	for name := range m.groupPriorityOverrides {
+		restoredGroups[name] = true
+	}
+	m.stateMutex.Unlock()
+
+	// Endpoints hold a copy of their config, sync restored priorities into them
+	m.configMutex.Lock()
+	m.config = cfg
+	for _, ep := range m.endpoints {
+		if idx := findConfigEndpoint(cfg, ep.Config.Name); idx >= 0 {
+			ep.Config.Priority = cfg.Endpoints[idx].Priority
+			ep.Config.GroupPriority = cfg.Endpoints[idx].GroupPriority
+		}
+	}
+	m.configMutex.Unlock()
+	if len(restoredGroups) > 0 {
+		m.groupManager.UpdateGroups(m.GetAllEndpoints())
+	}
+
+	now := time.Now()
+	for name, groupState := range state.Groups {
+		if !groupState.CooldownUntil.After(now) {
+			continue // Cooldown already over
+		}
+		if !m.groupManager.RestoreGroupCooldown(name, groupState.CooldownUntil) {
+			slog.Warn(fmt.Sprintf("🧹 [运行时状态] 丢弃过期条目: 组 %s 已不在配置中", name))
+			dropped = true
+			continue
+		}
+		restoredGroups[name] = true
+	}
+
+	if restoredEndpoints > 0 || len(restoredGroups) > 0 {
+		slog.Info(fmt.Sprintf("📂 [运行时状态] 已从 %s 恢复 %d 个端点与 %d 个组的运行时状态",
+			m.stateStore.Path(), restoredEndpoints, len(restoredGroups)))
+	}
+
+	// Rewrite the file without the stale entries
+	if dropped {
+		m.saveState()
+	}
+}
+
+// applyPriorityOverrides writes runtime priority and group priority overrides into cfg (caller
+// holds stateMutex). Overrides for endpoints or groups missing from cfg are dropped; returns true
+// if any were dropped.
+func (m *Manager) applyPriorityOverrides(cfg *config.Config) bool {
+	dropped := false
+	for name, override := range m.priorityOverrides {
+		idx := -1
+		for i := range cfg.Endpoints {
+			if cfg.Endpoints[i].Name == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			slog.Warn(fmt.Sprintf("🧹 [运行时状态] 丢弃过期优先级覆盖: 端点 %s 已不在配置中", name))
+			delete(m.priorityOverrides, name)
+			dropped = true
+			continue
+		}
+
+		if cfg.Endpoints[idx].Priority != override.Priority {
+			// Fresh value from the config file, remember it as the value to restore on reset
+			override.Original = cfg.Endpoints[idx].Priority
+			cfg.Endpoints[idx].Priority = override.Priority
+		}
+	}
+
+	for group, override := range m.groupPriorityOverrides {
+		current, exists := groupPriority(cfg, group)
+		if !exists {
+			slog.Warn(fmt.Sprintf("🧹 [运行时状态] 丢弃过期组优先级覆盖: 组 %s 已不在配置中", group))
+			delete(m.groupPriorityOverrides, group)
+			dropped = true
+			continue
+		}
+
+		if current != override.Priority {
+			override.Original = current
+			setGroupPriority(cfg, group, override.Priority)
+		}
+	}
+	return dropped
+}
+
+// configGroupName returns the group an endpoint config belongs to
+func configGroupName(ep config.EndpointConfig) string {
+	if ep.Group == "" {
+		return "Default"
+	}
+	return ep.Group
+}
+
+// groupPriority returns the priority of a group in cfg, taken from its first endpoint like the
+// group manager does, and whether the group exists
+func groupPriority(cfg *config.Config, group string) (int, bool) {
+	for _, ep := range cfg.Endpoints {
+		if configGroupName(ep) == group {
+			return ep.GroupPriority, true
+		}
+	}
+	return 0, false
+}
+
+// setGroupPriority sets the group priority of every endpoint of a group in cfg
+func setGroupPriority(cfg *config.Config, group string, priority int) {
+	for i := range cfg.Endpoints {
+		if configGroupName(cfg.Endpoints[i]) == group {
+			cfg.Endpoints[i].GroupPriority = priority
+		}
+	}
+}
+
+// findConfigEndpoint returns the index of the named endpoint in cfg, or -1
+func findConfigEndpoint(cfg *config.Config, name string) int {
+	for i := range cfg.Endpoints {
+		if cfg.Endpoints[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetEndpointPriorities applies runtime priority edits (endpoint name -> priority) and records
+// them as overrides in the runtime state. source identifies the operator interface for logging.
+func (m *Manager) SetEndpointPriorities(priorities map[string]int, source string) error {
+	var err error
+	m.editConfig(func(cfg *config.Config) bool {
+		for name := range priorities {
+			if findConfigEndpoint(cfg, name) < 0 {
+				err = fmt.Errorf("endpoint '%s' not found", name)
+				return false
+			}
+		}
+
+		for name, priority := range priorities {
+			idx := findConfigEndpoint(cfg, name)
+			original := cfg.Endpoints[idx].Priority
+			if override, exists := m.priorityOverrides[name]; exists {
+				original = override.Original
+			}
+
+			if priority == original {
+				delete(m.priorityOverrides, name)
+			} else {
+				m.priorityOverrides[name] = &priorityOverride{Original: original, Priority: priority}
+			}
+			cfg.Endpoints[idx].Priority = priority
+
+			slog.Info(fmt.Sprintf("🔢 [运行时状态] 端点 %s 优先级已更新为 %d (来源: %s)", name, priority, source))
+		}
+		return true
+	})
+	return err
+}
+
+// SetGroupPriorities applies runtime group priority edits (group name -> priority) and records
+// them as overrides in the runtime state. The edits are rejected when a group would end up
+// with the same priority as another one, since the failover order would then be ambiguous.
+func (m *Manager) SetGroupPriorities(priorities map[string]int, source string) error {
+	var err error
+	m.editConfig(func(cfg *config.Config) bool {
+		if err = checkGroupPriorities(cfg, priorities); err != nil {
+			return false
+		}
+
+		for group, priority := range priorities {
+			original, _ := groupPriority(cfg, group)
+			if override, exists := m.groupPriorityOverrides[group]; exists {
+				original = override.Original
+			}
+
+			if priority == original {
+				delete(m.groupPriorityOverrides, group)
+			} else {
+				m.groupPriorityOverrides[group] = &priorityOverride{Original: original, Priority: priority}
+			}
+			setGroupPriority(cfg, group, priority)
+
+			slog.Info(fmt.Sprintf("🔢 [运行时状态] 组 %s 优先级已更新为 %d (来源: %s)", group, priority, source))
+		}
+		return true
+	})
+	return err
+}
+
+// checkGroupPriorities validates group priority edits against cfg
+func checkGroupPriorities(cfg *config.Config, priorities map[string]int) error {
+	resulting := make(map[string]int)
+	for _, ep := range cfg.Endpoints {
+		if _, seen := resulting[configGroupName(ep)]; !seen {
+			resulting[configGroupName(ep)] = ep.GroupPriority
+		}
+	}
+	for group, priority := range priorities {
+		if _, exists := resulting[group]; !exists {
+			return fmt.Errorf("group '%s' not found", group)
+		}
+		if priority < 1 {
+			return fmt.Errorf("group '%s': priority must be at least 1", group)
+		}
+		resulting[group] = priority
+	}
+
+	groups := make([]string, 0, len(resulting))
+	for group := range resulting {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		if _, edited := priorities[group]; !edited {
+			continue
+		}
+		for _, other := range groups {
+			if other != group && resulting[other] == resulting[group] {
+				return fmt.Errorf("groups '%s' and '%s' would both have priority %d", group, other, resulting[group])
+			}
+		}
+	}
+	return nil
+}
+
+// ClearPriorityOverrides forgets all runtime priority overrides, e.g. after the
+// priorities have been saved to the config file
+func (m *Manager) ClearPriorityOverrides() {
+	m.stateMutex.Lock()
+	m.priorityOverrides = make(map[string]*priorityOverride)
+	m.groupPriorityOverrides = make(map[string]*priorityOverride)
+	m.stateMutex.Unlock()
+
+	m.saveState()
+}
+
+// PriorityOverride is a runtime priority edit and the config file value it replaces
+type PriorityOverride struct {
+	Priority int `json:"priority"`       // Priority in effect
+	Config   int `json:"configPriority"` // Priority from the config file
+}
+
+// PriorityOverrides returns the runtime endpoint priority overrides (by endpoint name) and
+// group priority overrides (by group name) that are applied on top of the config file
+func (m *Manager) PriorityOverrides() (endpoints, groups map[string]PriorityOverride) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	endpoints = make(map[string]PriorityOverride, len(m.priorityOverrides))
+	for name, override := range m.priorityOverrides {
+		endpoints[name] = PriorityOverride{Priority: override.Priority, Config: override.Original}
+	}
+	groups = make(map[string]PriorityOverride, len(m.groupPriorityOverrides))
+	for name, override := range m.groupPriorityOverrides {
+		groups[name] = PriorityOverride{Priority: override.Priority, Config: override.Original}
+	}
+	return endpoints, groups
+}
+
+// RevertPriorityOverrides puts endpoint and group priorities back to the config file values
+// and removes the overrides from the state file. Maintenance flags and group cooldowns are
+// kept. Returns the number of overrides removed.
+func (m *Manager) RevertPriorityOverrides(source string) int {
+	reverted := 0
+	m.editConfig(func(cfg *config.Config) bool {
+		reverted = m.revertPriorityOverrides(cfg)
+		return reverted > 0
+	})
+
+	if reverted == 0 {
+		return 0
+	}
+	slog.Info(fmt.Sprintf("♻️ [运行时状态] 已清除 %d 个优先级覆盖，恢复配置文件中的优先级 (来源: %s)", reverted, source))
+	return reverted
+}
+
+// revertPriorityOverrides puts the overridden priorities in cfg back to their config file
+// values and forgets the overrides; returns how many there were (caller holds stateMutex)
+func (m *Manager) revertPriorityOverrides(cfg *config.Config) int {
+	reverted := len(m.priorityOverrides) + len(m.groupPriorityOverrides)
+	for name, override := range m.priorityOverrides {
+		if idx := findConfigEndpoint(cfg, name); idx >= 0 {
+			cfg.Endpoints[idx].Priority = override.Original
+		}
+	}
+	for group, override := range m.groupPriorityOverrides {
+		setGroupPriority(cfg, group, override.Original)
+	}
+	m.priorityOverrides = make(map[string]*priorityOverride)
+	m.groupPriorityOverrides = make(map[string]*priorityOverride)
+	return reverted
+}
+
+// GetRuntimeState returns the current runtime state as it would be persisted
+func (m *Manager) GetRuntimeState() *RuntimeState {
+	return m.snapshotState()
+}
+
+// GetStateFilePath returns the runtime state file path, or "" if persistence is disabled
+func (m *Manager) GetStateFilePath() string {
+	if m.stateStore == nil {
+		return ""
+	}
+	return m.stateStore.Path()
+}
+
+// ResetRuntimeState reverts priority overrides and maintenance flags to the config values,
+// clears group cooldowns and deletes the state file
+func (m *Manager) ResetRuntimeState() error {
+	m.editConfig(func(cfg *config.Config) bool {
+		return m.revertPriorityOverrides(cfg) > 0
+	})
+
+	for _, ep := range m.GetAllEndpoints() {
+		ep.mutex.Lock()
+		ep.Status.Disabled = ep.Config.Disabled
+		ep.mutex.Unlock()
+	}
+	m.groupManager.ResetAllStates()
+	m.applyBudgetCooldowns()
+
+	slog.Info("♻️ [运行时状态] 已清除运行时状态 (优先级覆盖、维护模式、组冷却)")
+
+	if m.stateStore != nil {
+		return m.stateStore.Remove()
+	}
+	return nil
+}
+
+// saveState schedules a debounced write of the runtime state
+func (m *Manager) saveState() {
+	if m.stateStore != nil {
+		m.stateStore.ScheduleSave(m.snapshotState)
+	}
+}
+
+// snapshotState builds the runtime state from the manager and group manager
+func (m *Manager) snapshotState() *RuntimeState {
+	state := &RuntimeState{
+		UpdatedAt: time.Now(),
+		Endpoints: make(map[string]EndpointRuntimeState),
+		Groups:    make(map[string]GroupRuntimeState),
+	}
+
+	m.stateMutex.Lock()
+	for name, override := range m.priorityOverrides {
+		priority := override.Priority
+		state.Endpoints[name] = EndpointRuntimeState{Priority: &priority}
+	}
+	for group, override := range m.groupPriorityOverrides {
+		priority := override.Priority
+		state.Groups[group] = GroupRuntimeState{Priority: &priority}
+	}
+	m.stateMutex.Unlock()
+
+	for _, ep := range m.GetAllEndpoints() {
+		status := ep.GetStatus()
+		if status.Disabled == ep.Config.Disabled {
+			continue
+		}
+		disabled := status.Disabled
+		epState := state.Endpoints[ep.Config.Name]
+		epState.Disabled = &disabled
+		state.Endpoints[ep.Config.Name] = epState
+	}
+
+	for name, until := range m.groupManager.GetGroupCooldowns() {
+		groupState := state.Groups[name]
+		groupState.CooldownUntil = until
+		state.Groups[name] = groupState
+	}
+
+	return state
+}
@@ -0,0 +1,251 @@
+package endpoint
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// newStateTestConfig returns a config with one endpoint per name, each in its own group, in
+// priority order
+func newStateTestConfig(names ...string) *config.Config {
+	cfg := newTestConfig()
+	for i, name := range names {
+		cfg.Endpoints = append(cfg.Endpoints, config.EndpointConfig{
+			Name:          name,
+			URL:           "http://127.0.0.1:1",
+			Priority:      i + 1,
+			Group:         name + "-group",
+			GroupPriority: i + 1,
+		})
+	}
+	return cfg
+}
+
+func TestRuntimeStatePersistence(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.yaml")
+
+	manager := NewManager(newStateTestConfig("primary", "backup", "removed"))
+	manager.SetStateStore(NewStateStore(statePath, time.Hour))
+
+	if err := manager.SetEndpointPriorities(map[string]int{"backup": 9}, "test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	manager.SetEndpointMaintenance("primary", true, "test")
+	manager.SetEndpointMaintenance("removed", true, "test")
+	manager.GetGroupManager().SetGroupCooldown("backup-group")
+
+	// Saves are debounced: nothing is written until the delay elapses or the store is flushed
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Fatal("Expected state file not to be written before the debounce delay")
+	}
+	manager.Stop()
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("Expected state file to be written on stop: %v", err)
+	}
+
+	// Restart with a config that no longer contains 'removed'
+	restarted := NewManager(newStateTestConfig("primary", "backup"))
+	restarted.SetStateStore(NewStateStore(statePath, time.Hour))
+
+	if !restarted.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected maintenance flag to be restored")
+	}
+	if got := restarted.GetEndpointByNameAny("backup").Config.Priority; got != 9 {
+		t.Errorf("Expected restored priority 9, got %d", got)
+	}
+	if got := restarted.GetConfig().Endpoints[1].Priority; got != 9 {
+		t.Errorf("Expected restored priority to be applied to config, got %d", got)
+	}
+	if !restarted.GetGroupManager().IsGroupInCooldown("backup-group") {
+		t.Error("Expected group cooldown to be restored")
+	}
+
+	state := restarted.GetRuntimeState()
+	if _, exists := state.Endpoints["removed"]; exists {
+		t.Error("Expected stale endpoint entry to be dropped")
+	}
+	if p := state.Endpoints["backup"].Priority; p == nil || *p != 9 {
+		t.Error("Expected priority override in runtime state")
+	}
+
+	// Reset reverts to config values and deletes the file
+	if err := restarted.ResetRuntimeState(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if restarted.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected maintenance flag to be cleared by reset")
+	}
+	if got := restarted.GetEndpointByNameAny("backup").Config.Priority; got != 2 {
+		t.Errorf("Expected priority to revert to config value 2, got %d", got)
+	}
+	if restarted.GetGroupManager().IsGroupInCooldown("backup-group") {
+		t.Error("Expected group cooldown to be cleared by reset")
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Error("Expected state file to be removed by reset")
+	}
+	restarted.Stop()
+}
+
+func TestStateStoreDebouncedAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.yaml")
+	store := NewStateStore(statePath, 20*time.Millisecond)
+
+	var calls atomic.Int32
+	snapshot := func() *RuntimeState {
+		calls.Add(1)
+		return &RuntimeState{Groups: map[string]GroupRuntimeState{"main": {CooldownUntil: time.Now().Add(time.Minute)}}}
+	}
+
+	for i := 0; i < 5; i++ {
+		store.ScheduleSave(snapshot)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected rapid saves to be coalesced into 1 write, got %d", got)
+	}
+
+	state, err := store.Load()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, exists := state.Groups["main"]; !exists {
+		t.Error("Expected written state to be loadable")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the state file in directory (no temp files left), got %d entries", len(entries))
+	}
+}
+
+func TestSetGroupPriorities(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.yaml")
+
+	manager := NewManager(newStateTestConfig("primary", "backup", "spare"))
+	manager.SetStateStore(NewStateStore(statePath, time.Hour))
+
+	if err := manager.SetGroupPriorities(map[string]int{"backup-group": 3}, "test"); err == nil {
+		t.Error("Expected an error when two groups would share a priority")
+	}
+	if err := manager.SetGroupPriorities(map[string]int{"missing-group": 7}, "test"); err == nil {
+		t.Error("Expected an error for an unknown group")
+	}
+
+	// Swapping two groups in one edit is allowed
+	if err := manager.SetGroupPriorities(map[string]int{"primary-group": 2, "backup-group": 1}, "test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if healthy := manager.GetHealthyEndpoints(); len(healthy) == 0 || healthy[0].Config.Name != "backup" {
+		t.Errorf("Expected the backup group to serve traffic first, got %v", healthy)
+	}
+	manager.Stop()
+
+	restarted := NewManager(newStateTestConfig("primary", "backup", "spare"))
+	restarted.SetStateStore(NewStateStore(statePath, time.Hour))
+
+	if got := restarted.GetEndpointByNameAny("backup").Config.GroupPriority; got != 1 {
+		t.Errorf("Expected restored group priority 1, got %d", got)
+	}
+	if healthy := restarted.GetHealthyEndpoints(); len(healthy) == 0 || healthy[0].Config.Name != "backup" {
+		t.Error("Expected the restored group priorities to decide which group serves traffic")
+	}
+	if p := restarted.GetRuntimeState().Groups["primary-group"].Priority; p == nil || *p != 2 {
+		t.Error("Expected group priority override in runtime state")
+	}
+
+	if err := restarted.ResetRuntimeState(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := restarted.GetEndpointByNameAny("backup").Config.GroupPriority; got != 2 {
+		t.Errorf("Expected group priority to revert to config value 2, got %d", got)
+	}
+	restarted.Stop()
+}
+
+func TestRevertPriorityOverrides(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.yaml")
+	manager := NewManager(newStateTestConfig("primary", "backup"))
+	manager.SetStateStore(NewStateStore(statePath, time.Hour))
+	defer manager.Stop()
+
+	if err := manager.SetEndpointPriorities(map[string]int{"backup": 7}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetGroupPriorities(map[string]int{"primary-group": 5}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	manager.SetEndpointMaintenance("primary", true, "test")
+
+	endpoints, groups := manager.PriorityOverrides()
+	if endpoints["backup"] != (PriorityOverride{Priority: 7, Config: 2}) || groups["primary-group"] != (PriorityOverride{Priority: 5, Config: 1}) {
+		t.Fatalf("Expected the overrides with their config values, got %+v / %+v", endpoints, groups)
+	}
+
+	if reverted := manager.RevertPriorityOverrides("test"); reverted != 2 {
+		t.Errorf("Expected 2 overrides to be reverted, got %d", reverted)
+	}
+	if got := manager.GetConfig().Endpoints[1].Priority; got != 2 {
+		t.Errorf("Expected the config priority 2 back, got %d", got)
+	}
+	if got := manager.GetConfig().Endpoints[0].GroupPriority; got != 1 {
+		t.Errorf("Expected the config group priority 1 back, got %d", got)
+	}
+	if !manager.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected the maintenance flag to be kept")
+	}
+
+	// The state file keeps the maintenance flag but no priorities
+	if err := manager.stateStore.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	state, err := NewStateStore(statePath, time.Hour).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Endpoints["backup"].Priority != nil || state.Groups["primary-group"].Priority != nil {
+		t.Errorf("Expected no priority overrides in the state file, got %+v", state)
+	}
+	if disabled := state.Endpoints["primary"].Disabled; disabled == nil || !*disabled {
+		t.Errorf("Expected the maintenance flag in the state file, got %+v", state.Endpoints)
+	}
+	if reverted := manager.RevertPriorityOverrides("test"); reverted != 0 {
+		t.Errorf("Expected nothing left to revert, got %d", reverted)
+	}
+}
+
+func TestConcurrentPriorityEditsUseConfigCopies(t *testing.T) {
+	cfg := newStateTestConfig("primary", "backup")
+	manager := NewManager(cfg)
+	defer manager.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := manager.SetEndpointPriorities(map[string]int{"backup": 10 + i}, "test"); err != nil {
+				t.Error(err)
+			}
+			manager.RevertPriorityOverrides("test")
+			manager.GetHealthyEndpoints()
+		}(i)
+	}
+	wg.Wait()
+
+	// The config handed to the manager is never edited in place
+	if got := cfg.Endpoints[1].Priority; got != 2 {
+		t.Errorf("Expected the original config to keep priority 2, got %d", got)
+	}
+	if got := manager.GetConfig().Endpoints[1].Priority; got != manager.GetEndpointByNameAny("backup").Config.Priority {
+		t.Errorf("Expected the endpoint to carry the config priority %d, got %d", got, manager.GetEndpointByNameAny("backup").Config.Priority)
+	}
+}
@@ -0,0 +1,211 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// tokenPool rotates the tokens of an endpoint configured with a tokens list. Tokens the
+// upstream rejected (401/429) leave the rotation until their cooldown ends.
+type tokenPool struct {
+	mu       sync.Mutex
+	tokens   []string
+	next     int                 // Index of the token to try first for the next request
+	rejected map[string]tokenBan // Rejected tokens keyed by token
+}
+
+// tokenBan records why and until when a token is out of the rotation
+type tokenBan struct {
+	until      time.Time
+	statusCode int
+}
+
+// TokenState describes one token of a multi-token endpoint, with the token masked
+type TokenState struct {
+	Token         string    // Masked token
+	Available     bool      // In the rotation
+	DisabledUntil time.Time // Cooldown end of a rejected token
+	StatusCode    int       // Status the token was rejected with
+}
+
+func newTokenPool(tokens []string) *tokenPool {
+	pool := &tokenPool{rejected: make(map[string]tokenBan)}
+	pool.configure(tokens)
+	return pool
+}
+
+// configure sets the tokens to rotate, forgetting rejections of tokens no longer listed
+func (p *tokenPool) configure(tokens []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens = append([]string(nil), tokens...)
+	listed := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		listed[token] = true
+	}
+	for token := range p.rejected {
+		if !listed[token] {
+			delete(p.rejected, token)
+		}
+	}
+	if p.next >= len(p.tokens) {
+		p.next = 0
+	}
+}
+
+// take returns the next available token in round-robin order and advances the rotation when
+// advance is set. When every token is out of the rotation it returns the one whose cooldown
+// ends first, so requests are still sent.
+func (p *tokenPool) take(now time.Time, advance bool) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.tokens) == 0 {
+		return ""
+	}
+
+	soonest := -1
+	for i := 0; i < len(p.tokens); i++ {
+		idx := (p.next + i) % len(p.tokens)
+		ban, rejected := p.rejected[p.tokens[idx]]
+		if !rejected || !now.Before(ban.until) {
+			if advance {
+				delete(p.rejected, p.tokens[idx])
+				p.next = (idx + 1) % len(p.tokens)
+			}
+			return p.tokens[idx]
+		}
+		if soonest < 0 || ban.until.Before(p.rejected[p.tokens[soonest]].until) {
+			soonest = idx
+		}
+	}
+	return p.tokens[soonest]
+}
+
+// reject takes a token out of the rotation until the given time and reports whether
+// another token is still available
+func (p *tokenPool) reject(token string, until time.Time, statusCode int, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	available := false
+	for _, t := range p.tokens {
+		if t == token {
+			if ban, rejected := p.rejected[t]; !rejected || until.After(ban.until) {
+				p.rejected[t] = tokenBan{until: until, statusCode: statusCode}
+			}
+			continue
+		}
+		if ban, rejected := p.rejected[t]; !rejected || !now.Before(ban.until) {
+			available = true
+		}
+	}
+	return available
+}
+
+// states returns the masked state of every token
+func (p *tokenPool) states(now time.Time) []TokenState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := make([]TokenState, 0, len(p.tokens))
+	for _, token := range p.tokens {
+		state := TokenState{Token: config.MaskSecret(token), Available: true}
+		if ban, rejected := p.rejected[token]; rejected && now.Before(ban.until) {
+			state.Available = false
+			state.DisabledUntil = ban.until
+			state.StatusCode = ban.statusCode
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// isTokenRejection reports whether a status means the upstream refused the token sent: 401
+// for an invalid or revoked token, 429 for a token that ran out of quota
+func isTokenRejection(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusTooManyRequests
+}
+
+// TokenStates returns the masked state of the endpoint's rotated tokens, or nil when the
+// endpoint has no tokens list
+func (e *Endpoint) TokenStates() []TokenState {
+	if e.tokens == nil {
+		return nil
+	}
+	return e.tokens.states(time.Now())
+}
+
+// NextTokenForEndpoint returns the token to send with the next request to an endpoint: the
+// next token in rotation for endpoints with a tokens list, GetTokenForEndpoint otherwise
+func (m *Manager) NextTokenForEndpoint(ep *Endpoint) string {
+	if ep.tokens != nil {
+		return ep.tokens.take(time.Now(), true)
+	}
+	return m.GetTokenForEndpoint(ep)
+}
+
+// RejectEndpointToken takes a token the upstream answered with 401 or 429 out of the
+// endpoint's rotation for at least its token_cooldown, and reports whether another token
+// is available to retry the request with. Endpoints without a tokens list return false.
+func (m *Manager) RejectEndpointToken(ep *Endpoint, token string, statusCode int, cooldown time.Duration) bool {
+	if ep.tokens == nil || token == "" {
+		return false
+	}
+	if cooldown < ep.Config.TokenCooldown {
+		cooldown = ep.Config.TokenCooldown
+	}
+	now := time.Now()
+	available := ep.tokens.reject(token, now.Add(cooldown), statusCode, now)
+	m.statusGeneration.Add(1)
+
+	if available {
+		slog.Warn(fmt.Sprintf("🔑 [令牌轮换] 端点 %s 的令牌 %s 被拒绝 (状态码: %d)，%s内停用，改用下一个令牌",
+			ep.Config.Name, config.MaskSecret(token), statusCode, cooldown))
+	} else {
+		slog.Error(fmt.Sprintf("🔑 [令牌轮换] 端点 %s 的令牌 %s 被拒绝 (状态码: %d)，已没有可用令牌",
+			ep.Config.Name, config.MaskSecret(token), statusCode))
+	}
+	return available
+}
+
+// tokenPoolFor returns the token pool of an endpoint, reusing the existing pool across
+// reloads so rejected tokens stay out of the rotation; nil without a tokens list
+func (m *Manager) tokenPoolFor(epCfg config.EndpointConfig) *tokenPool {
+	m.tokenPoolMutex.Lock()
+	defer m.tokenPoolMutex.Unlock()
+
+	if len(epCfg.Tokens) == 0 {
+		delete(m.tokenPools, epCfg.Name)
+		return nil
+	}
+	if m.tokenPools == nil {
+		m.tokenPools = make(map[string]*tokenPool)
+	}
+	pool, exists := m.tokenPools[epCfg.Name]
+	if !exists {
+		pool = newTokenPool(epCfg.Tokens)
+		m.tokenPools[epCfg.Name] = pool
+	} else {
+		pool.configure(epCfg.Tokens)
+	}
+	return pool
+}
+
+// pruneTokenPools drops token pools of endpoints that no longer exist
+func (m *Manager) pruneTokenPools(endpoints []*Endpoint) {
+	m.tokenPoolMutex.Lock()
+	defer m.tokenPoolMutex.Unlock()
+
+	current := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		current[ep.Config.Name] = true
+	}
+	for name := range m.tokenPools {
+		if !current[name] {
+			delete(m.tokenPools, name)
+		}
+	}
+}
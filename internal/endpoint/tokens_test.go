@@ -0,0 +1,96 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func TestTokenPoolRotation(t *testing.T) {
+	now := time.Now()
+	pool := newTokenPool([]string{"a", "b", "c"})
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, pool.take(now, true))
+	}
+	if strings.Join(got, "") != "abca" {
+		t.Errorf("Expected round-robin order abca, got %v", got)
+	}
+
+	// A rejected token is skipped until its cooldown ends
+	if !pool.reject("b", now.Add(time.Minute), http.StatusUnauthorized, now) {
+		t.Fatal("Expected other tokens to remain available")
+	}
+	got = nil
+	for i := 0; i < 4; i++ {
+		got = append(got, pool.take(now, true))
+	}
+	if strings.Join(got, "") != "caca" {
+		t.Errorf("Expected b to be skipped, got %v", got)
+	}
+	if token := pool.take(now.Add(2*time.Minute), true); token != "b" {
+		t.Errorf("Expected b back in rotation after its cooldown, got %s", token)
+	}
+
+	// With every token rejected, the one available soonest is still sent
+	pool.reject("a", now.Add(3*time.Minute), http.StatusTooManyRequests, now)
+	pool.reject("b", now.Add(time.Minute), http.StatusUnauthorized, now)
+	if pool.reject("c", now.Add(2*time.Minute), http.StatusUnauthorized, now) {
+		t.Error("Expected no token to remain available")
+	}
+	if token := pool.take(now, true); token != "b" {
+		t.Errorf("Expected the token whose cooldown ends first, got %s", token)
+	}
+}
+
+func TestTokenPoolSurvivesReload(t *testing.T) {
+	cfg := newTestConfig(config.EndpointConfig{Name: "multi", URL: "https://multi.example.com", Timeout: time.Second,
+		Tokens: []string{"sk-first-00001", "sk-second-0002"}, TokenCooldown: time.Minute})
+	m := NewManager(cfg)
+	ep := m.GetEndpointByNameAny("multi")
+	m.RejectEndpointToken(ep, "sk-first-00001", http.StatusUnauthorized, 0)
+
+	newCfg := *cfg
+	newCfg.Endpoints = []config.EndpointConfig{cfg.Endpoints[0]}
+	newCfg.Endpoints[0].Tokens = []string{"sk-first-00001", "sk-second-0002", "sk-third-00003"}
+	m.UpdateConfig(&newCfg)
+
+	states := m.GetEndpointByNameAny("multi").TokenStates()
+	if len(states) != 3 || states[0].Available || !states[1].Available || !states[2].Available {
+		t.Errorf("Expected the rejected token to stay disabled across the reload, got %+v", states)
+	}
+	if token := m.GetTokenForEndpoint(m.GetEndpointByNameAny("multi")); token != "sk-second-0002" {
+		t.Errorf("Expected the next token in rotation, got %s", token)
+	}
+}
+
+func TestProbeRotatesRejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer sk-revoked-0001" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(config.EndpointConfig{Name: "multi", URL: server.URL, Timeout: time.Second,
+		Tokens: []string{"sk-revoked-0001", "sk-valid-00002"}, TokenCooldown: time.Minute})
+	cfg.Health.AcceptableStatusCodes = []int{404} // 401 would otherwise count as healthy
+	m := NewManager(cfg)
+	ep := m.GetEndpointByNameAny("multi")
+
+	result := m.prober.Probe(context.Background(), ep, server.Client(), "/v1/models")
+	if !result.Healthy || result.StatusCode != http.StatusOK {
+		t.Errorf("Expected the probe to succeed with the next token, got %+v", result)
+	}
+	if states := ep.TokenStates(); states[0].Available {
+		t.Errorf("Expected the revoked token out of rotation, got %+v", states)
+	}
+}
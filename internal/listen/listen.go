@@ -0,0 +1,144 @@
+// Package listen opens the sockets the proxy and the WebUI serve on besides TCP host/port:
+// Unix domain sockets and sockets passed by systemd socket activation.
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// Listen opens the socket of a listen setting (unix:///path/to.sock, systemd or systemd:<name>).
+// A systemd setting may yield several listeners, one per passed socket.
+func Listen(value string, mode os.FileMode) ([]net.Listener, error) {
+	addr, err := config.ParseListen(value)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Systemd {
+		return Systemd(addr.SystemdName)
+	}
+	listener, err := Unix(addr.SocketPath, mode)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{listener}, nil
+}
+
+// Unix listens on socketPath and sets its permissions to mode. A socket file left behind by
+// a process that did not shut down cleanly is removed first; a socket another process still
+// serves, or a path that is not a socket, is left alone. The socket file is removed again
+// when the listener is closed.
+func Unix(socketPath string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Stat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("socket path %s exists and is not a socket", socketPath)
+		}
+		if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use by another process", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", socketPath, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions on %s: %w", socketPath, err)
+	}
+	return listener, nil
+}
+
+// listenFdsStart is the first file descriptor systemd passes (SD_LISTEN_FDS_START)
+const listenFdsStart = 3
+
+var (
+	systemdOnce  sync.Once
+	systemdFiles []*os.File
+	systemdErr   error
+)
+
+// Systemd returns listeners for the sockets passed by systemd socket activation
+// (LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES), only those whose FileDescriptorName
+// is name unless name is empty. The inherited sockets are kept open, so Systemd
+// may be called again when a server restarts.
+func Systemd(name string) ([]net.Listener, error) {
+	systemdOnce.Do(func() {
+		systemdFiles, systemdErr = inheritSystemdFiles()
+	})
+	if systemdErr != nil {
+		return nil, systemdErr
+	}
+	return systemdListeners(systemdFiles, name)
+}
+
+// inheritSystemdFiles takes over the sockets passed by systemd, clearing the
+// environment variables so processes started later don't claim them as well
+func inheritSystemdFiles() ([]*os.File, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid := os.Getenv("LISTEN_PID")
+	if pid == "" {
+		return nil, fmt.Errorf("no sockets passed by systemd (LISTEN_PID is not set)")
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("sockets passed by systemd belong to process %s", pid)
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("no sockets passed by systemd (LISTEN_FDS=%q)", os.Getenv("LISTEN_FDS"))
+	}
+
+	var names []string
+	if value := os.Getenv("LISTEN_FDNAMES"); value != "" {
+		names = strings.Split(value, ":")
+	}
+	files := make([]*os.File, count)
+	for i := range files {
+		// systemd names unnamed sockets "unknown"
+		fdName := "unknown"
+		if i < len(names) {
+			fdName = names[i]
+		}
+		files[i] = os.NewFile(uintptr(listenFdsStart+i), fdName)
+	}
+	return files, nil
+}
+
+// systemdListeners returns listeners for the files named name, all files when name is empty
+func systemdListeners(files []*os.File, name string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, file := range files {
+		if name != "" && file.Name() != name {
+			continue
+		}
+		// FileListener duplicates the descriptor, the inherited one stays open for later calls
+		listener, err := net.FileListener(file)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("systemd socket %s is not a listening socket: %w", file.Name(), err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("systemd passed no socket named %q", name)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,110 @@
+package listen
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnixServesAndRemovesSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "forwarder.sock")
+
+	// A socket file left behind by a crashed process
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listeners, err := Listen("unix://"+socketPath, 0660)
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced, got %v", err)
+	}
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("Expected socket mode 0660, got %v", info.Mode().Perm())
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	})}
+	go server.Serve(listeners[0])
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	resp, err := client.Get("http://localhost/v1/models")
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "/v1/models" {
+		t.Errorf("Expected the request path to be served, got %q", body)
+	}
+
+	// A socket that is still served is left alone
+	if _, err := Unix(socketPath, 0660); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Expected an in-use error, got %v", err)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket file to be removed on shutdown, got %v", err)
+	}
+
+	if err := os.WriteFile(socketPath, []byte("not a socket"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Unix(socketPath, 0660); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Expected a not-a-socket error, got %v", err)
+	}
+}
+
+func TestSystemdListenersByName(t *testing.T) {
+	var files []*os.File
+	for _, name := range []string{"proxy", "webui"} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer ln.Close()
+		file, err := ln.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatalf("Failed to get listener file: %v", err)
+		}
+		defer file.Close()
+		files = append(files, os.NewFile(file.Fd(), name))
+	}
+
+	all, err := systemdListeners(files, "")
+	if err != nil || len(all) != 2 {
+		t.Fatalf("Expected both sockets, got %d (%v)", len(all), err)
+	}
+	for _, ln := range all {
+		ln.Close()
+	}
+
+	named, err := systemdListeners(files, "webui")
+	if err != nil || len(named) != 1 {
+		t.Fatalf("Expected the webui socket, got %d (%v)", len(named), err)
+	}
+	named[0].Close()
+
+	if _, err := systemdListeners(files, "admin"); err == nil {
+		t.Error("Expected an error for a socket name systemd did not pass")
+	}
+}
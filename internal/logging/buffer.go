@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is how many recent log entries the in-memory buffer keeps
+const DefaultBufferSize = 500
+
+// Entry is a single log entry kept in memory for the TUI and WebUI
+type Entry struct {
+	Timestamp string    `json:"timestamp"`
+	Level     string    `json:"level"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+	At        time.Time `json:"-"` // Full timestamp used for "since" filtering
+}
+
+// Buffer keeps the most recent log entries and fans new entries out to subscribers.
+// It collects regardless of which UIs are running, so a UI started later still sees history.
+type Buffer struct {
+	logs        []Entry
+	maxLogs     int
+	total       int64
+	mutex       sync.RWMutex
+	subscribers []chan Entry
+}
+
+// NewBuffer creates a log buffer keeping the latest maxLogs entries
+func NewBuffer(maxLogs int) *Buffer {
+	return &Buffer{
+		logs:        make([]Entry, 0, maxLogs),
+		maxLogs:     maxLogs,
+		subscribers: make([]chan Entry, 0),
+	}
+}
+
+// AddLog adds a new log entry and notifies subscribers
+func (b *Buffer) AddLog(level, message, source string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	entry := Entry{
+		Timestamp: now.Format("15:04:05"),
+		Level:     level,
+		Source:    source,
+		Message:   message,
+		At:        now,
+	}
+
+	// Keep only the latest maxLogs entries
+	b.logs = append(b.logs, entry)
+	if len(b.logs) > b.maxLogs {
+		b.logs = b.logs[len(b.logs)-b.maxLogs:]
+	}
+	b.total++
+
+	for _, subscriber := range b.subscribers {
+		select {
+		case subscriber <- entry:
+		default:
+			// Skip if subscriber's channel is full
+		}
+	}
+}
+
+// GetLogs returns a copy of the buffered entries, oldest first
+func (b *Buffer) GetLogs() []Entry {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.snapshotLocked()
+}
+
+// Total returns how many entries were ever added, so readers can cheaply detect new entries
+func (b *Buffer) Total() int64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.total
+}
+
+// Subscribe adds a subscriber for new entries
+func (b *Buffer) Subscribe() chan Entry {
+	_, subscriber := b.SubscribeWithHistory()
+	return subscriber
+}
+
+// SubscribeWithHistory returns the buffered entries and a subscriber for entries added
+// after them, without gaps or duplicates between the two
+func (b *Buffer) SubscribeWithHistory() ([]Entry, chan Entry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscriber := make(chan Entry, 100) // Buffer for 100 log entries
+	b.subscribers = append(b.subscribers, subscriber)
+	return b.snapshotLocked(), subscriber
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (b *Buffer) Unsubscribe(subscriber chan Entry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i, sub := range b.subscribers {
+		if sub == subscriber {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			close(subscriber)
+			break
+		}
+	}
+}
+
+func (b *Buffer) snapshotLocked() []Entry {
+	result := make([]Entry, len(b.logs))
+	copy(result, b.logs)
+	return result
+}
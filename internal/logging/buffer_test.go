@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBufferKeepsLatestEntries(t *testing.T) {
+	buffer := NewBuffer(3)
+	for i := 1; i <= 5; i++ {
+		buffer.AddLog("INFO", fmt.Sprintf("entry %d", i), "system")
+	}
+
+	logs := buffer.GetLogs()
+	if len(logs) != 3 || logs[0].Message != "entry 3" || logs[2].Message != "entry 5" {
+		t.Fatalf("Expected entries 3-5, got %+v", logs)
+	}
+	if buffer.Total() != 5 {
+		t.Errorf("Expected total 5, got %d", buffer.Total())
+	}
+	if logs[2].At.IsZero() || logs[2].Timestamp != logs[2].At.Format("15:04:05") {
+		t.Errorf("Expected timestamps to be set, got %+v", logs[2])
+	}
+}
+
+func TestBufferSubscribeWithHistory(t *testing.T) {
+	buffer := NewBuffer(10)
+	buffer.AddLog("INFO", "before", "system")
+
+	// A late subscriber gets the history plus everything after it, without duplicates
+	history, subscriber := buffer.SubscribeWithHistory()
+	buffer.AddLog("WARN", "after", "system")
+
+	if len(history) != 1 || history[0].Message != "before" {
+		t.Fatalf("Expected history with the earlier entry, got %+v", history)
+	}
+	if entry := <-subscriber; entry.Message != "after" || entry.Level != "WARN" {
+		t.Errorf("Expected the new entry, got %+v", entry)
+	}
+
+	buffer.Unsubscribe(subscriber)
+	if _, open := <-subscriber; open {
+		t.Error("Expected the channel to be closed after unsubscribing")
+	}
+	buffer.AddLog("INFO", "unsubscribed", "system")
+}
@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxMessageLength is how much of a message the buffer, the console and (unless
+// DisableFileResponseLimit is set) the log file get
+const maxMessageLength = 500
+
+// rotatorCloseDelay is how long a replaced log file stays open, so lines being written
+// while the config is reloaded still reach it
+var rotatorCloseDelay = 2 * time.Second
+
+// HandlerOptions are the settings of a Handler that a config reload can change
+type HandlerOptions struct {
+	Level                    slog.Level
+	ConsoleOutput            bool   // Print to stdout (disabled while the TUI owns the terminal)
+	FilePath                 string // Log file, "" disables file logging
+	Rotation                 RotatorOptions
+	DisableFileResponseLimit bool // Write full messages to the log file instead of truncating them
+}
+
+// handlerState is what a Handler writes with; it is replaced as a whole on UpdateConfig
+type handlerState struct {
+	opts    HandlerOptions
+	rotator *FileRotator // nil without file logging
+}
+
+// Handler is the forwarder's slog handler. It writes the message of each record to the
+// shared buffer read by the TUI and WebUI, to stdout and to the log file, without
+// attributes. One Handler lives for the whole run: UpdateConfig swaps its level, console output
+// and log file atomically, so every logger created from it follows config reloads.
+type Handler struct {
+	buffer     *Buffer
+	requestID  func(context.Context) string // ID of the client request a context belongs to, "" for none
+	state      atomic.Pointer[handlerState]
+	mutex      sync.Mutex     // Serializes UpdateConfig and Close
+	rolledOver bool           // RotateOnStartup only applies to the first log file of a run
+	retiring   sync.WaitGroup // Replaced log files waiting to be closed
+}
+
+// NewHandler creates a handler that logs to buffer (which may be nil) with the given
+// options, tagging lines with the request ID requestID finds in their context. Like
+// UpdateConfig, it returns an error when the log file cannot be opened; the handler then logs
+// without a file.
+func NewHandler(buffer *Buffer, requestID func(context.Context) string, opts HandlerOptions) (*Handler, error) {
+	h := &Handler{buffer: buffer, requestID: requestID}
+	err := h.UpdateConfig(opts)
+	return h, err
+}
+
+// UpdateConfig applies new options. A log file whose path and rotation settings did not change
+// stays open; otherwise the new file is opened first and the old one is closed once
+// rotatorCloseDelay has passed. When the new file cannot be opened, the handler logs
+// without a file and the error is returned.
+func (h *Handler) UpdateConfig(opts HandlerOptions) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	old := h.state.Load()
+	next := &handlerState{opts: opts}
+	var err error
+	if opts.FilePath != "" {
+		if old != nil && old.rotator != nil && sameLogFile(old.opts, opts) {
+			next.rotator = old.rotator
+		} else {
+			rotation := opts.Rotation
+			rotation.RotateOnStartup = rotation.RotateOnStartup && !h.rolledOver
+			h.rolledOver = true
+			if next.rotator, err = NewFileRotator(opts.FilePath, rotation); err != nil {
+				next.rotator = nil
+				err = fmt.Errorf("failed to open log file %s: %w", opts.FilePath, err)
+			}
+		}
+	}
+	h.state.Store(next)
+
+	if old != nil && old.rotator != nil && old.rotator != next.rotator {
+		h.retire(old.rotator)
+	}
+	return err
+}
+
+// sameLogFile reports whether two options write the same file the same way
+func sameLogFile(a, b HandlerOptions) bool {
+	a.Rotation.RotateOnStartup, b.Rotation.RotateOnStartup = false, false
+	return a.FilePath == b.FilePath && a.Rotation == b.Rotation
+}
+
+// retire closes a replaced log file after rotatorCloseDelay (caller holds the mutex)
+func (h *Handler) retire(rotator *FileRotator) {
+	h.retiring.Add(1)
+	time.AfterFunc(rotatorCloseDelay, func() {
+		defer h.retiring.Done()
+		rotator.Sync()
+		rotator.Close()
+	})
+}
+
+// Rotator returns the current log file's rotator, nil without file logging
+func (h *Handler) Rotator() *FileRotator {
+	return h.state.Load().rotator
+}
+
+// FileWriter returns a writer that appends to whichever log file is current, so other
+// writers such as the access log follow reloads too. Without file logging writes are dropped.
+func (h *Handler) FileWriter() io.Writer {
+	return handlerFile{h}
+}
+
+// handlerFile writes to the current log file of a Handler
+type handlerFile struct {
+	h *Handler
+}
+
+func (f handlerFile) Write(p []byte) (int, error) {
+	rotator := f.h.Rotator()
+	if rotator == nil {
+		return len(p), nil
+	}
+	return rotator.Write(p)
+}
+
+// Close stops file logging and closes the current and replaced log files
+func (h *Handler) Close() error {
+	h.mutex.Lock()
+	state := h.state.Load()
+	h.state.Store(&handlerState{opts: state.opts})
+	h.mutex.Unlock()
+
+	var err error
+	if state.rotator != nil {
+		state.rotator.Sync()
+		err = state.rotator.Close()
+	}
+	h.retiring.Wait()
+	return err
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.state.Load().opts.Level
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	state := h.state.Load()
+	message := r.Message
+	// Tag lines logged for a client request so its whole lifecycle can be found by its ID
+	if h.requestID != nil {
+		if requestID := h.requestID(ctx); requestID != "" {
+			message = "[req:" + requestID + "] " + message
+		}
+	}
+
+	// Format log message with timestamp for file output
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	level := "INFO"
+	switch r.Level {
+	case slog.LevelDebug:
+		level = "DEBUG"
+	case slog.LevelWarn:
+		level = "WARN"
+	case slog.LevelError:
+		level = "ERROR"
+	}
+
+	if state.rotator != nil {
+		fileMessage := message
+		if !state.opts.DisableFileResponseLimit && len(message) > maxMessageLength {
+			fileMessage = message[:maxMessageLength] + "... (文件日志截断)"
+		}
+		state.rotator.Write([]byte(fmt.Sprintf("[%s] [%s] %s\n", timestamp, level, fileMessage)))
+	}
+
+	// For UI/console output - always limit message length
+	displayMessage := message
+	if len(displayMessage) > maxMessageLength {
+		displayMessage = displayMessage[:maxMessageLength] + "... (显示截断)"
+	}
+
+	// Always collect for the TUI and WebUI, including ones started later
+	if h.buffer != nil {
+		h.buffer.AddLog(level, displayMessage, "system")
+	}
+
+	if state.opts.ConsoleOutput {
+		fmt.Printf("[%s] [%s] %s\n", timestamp, level, displayMessage)
+	}
+
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	// Return the same handler since we don't use attributes
+	return h
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	// Return the same handler since we don't use groups
+	return h
+}
@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openLogFiles returns how many descriptors of this process point into dir
+func openLogFiles(t *testing.T, dir string) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("Cannot list open descriptors: %v", err)
+	}
+	count := 0
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestHandlerReload(t *testing.T) {
+	defer func(delay time.Duration) { rotatorCloseDelay = delay }(rotatorCloseDelay)
+	rotatorCloseDelay = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	buffer := NewBuffer(100)
+	handler, err := NewHandler(buffer, nil, HandlerOptions{Level: slog.LevelDebug})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	defer handler.Close()
+	logger := slog.New(handler)
+
+	logger.Debug("before file logging")
+	if handler.Rotator() != nil || openLogFiles(t, dir) != 0 {
+		t.Fatal("Expected no log file without a path")
+	}
+
+	// Enabling file logging applies to the logger created before the reload
+	firstPath := filepath.Join(dir, "first.log")
+	if err := handler.UpdateConfig(HandlerOptions{Level: slog.LevelDebug, FilePath: firstPath}); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	logger.Debug("into the first file")
+	handler.FileWriter().Write([]byte("access line\n"))
+
+	// Raising the level takes effect with the next record
+	if err := handler.UpdateConfig(HandlerOptions{Level: slog.LevelError, FilePath: firstPath}); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	logger.Debug("dropped debug line")
+	logger.Error("kept error line")
+	if handler.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Expected warnings to be disabled at level error")
+	}
+	if n := openLogFiles(t, dir); n != 1 {
+		t.Fatalf("Expected the unchanged log file to stay the only one open, got %d", n)
+	}
+
+	lines := readAllLogLines(t, firstPath)
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "[DEBUG] into the first file") ||
+		lines[1] != "access line" || !strings.HasSuffix(lines[2], "[ERROR] kept error line") {
+		t.Fatalf("Unexpected first file contents: %q", lines)
+	}
+	if logs := buffer.GetLogs(); len(logs) != 3 {
+		t.Errorf("Expected 3 entries in the buffer, got %d", len(logs))
+	}
+
+	// Moving the log file writes to the new one and closes the old one after the grace period
+	secondPath := filepath.Join(dir, "second.log")
+	if err := handler.UpdateConfig(HandlerOptions{Level: slog.LevelError, FilePath: secondPath}); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	logger.Error("into the second file")
+	if lines := readAllLogLines(t, secondPath); len(lines) != 1 || !strings.HasSuffix(lines[0], "into the second file") {
+		t.Fatalf("Expected the line in the new file, got %q", lines)
+	}
+	if lines := readAllLogLines(t, firstPath); len(lines) != 3 {
+		t.Errorf("Expected the old file untouched, got %q", lines)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := openLogFiles(t, dir); n != 1 {
+		t.Errorf("Expected only the new log file open, got %d", n)
+	}
+
+	// Disabling file logging closes the last file
+	if err := handler.UpdateConfig(HandlerOptions{Level: slog.LevelError}); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	handler.FileWriter().Write([]byte("dropped access line\n"))
+	handler.Close()
+	if n := openLogFiles(t, dir); n != 0 {
+		t.Errorf("Expected no log file left open, got %d", n)
+	}
+}
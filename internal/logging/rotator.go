@@ -13,19 +13,51 @@ import (
 	"time"
 )
 
-// FileRotator manages log file rotation and archival
+// rotatedTimeLayout is the timestamp in rotated file names, e.g. app-2024-06-01T00-00.log.gz
+const rotatedTimeLayout = "2006-01-02T15-04"
+
+// RotatorOptions controls when a FileRotator starts a new file and what it keeps
+type RotatorOptions struct {
+	MaxSize         int64         // Size in bytes a file may reach before rotation, 0 for no limit
+	MaxFiles        int           // Rotated files to keep, 0 to keep all
+	Compress        bool          // Gzip rotated files
+	RotateInterval  time.Duration // Start a new file every interval, aligned to local midnight; 0 disables
+	RotateOnStartup bool          // Start a new file when the rotator is created, if the current one is not empty
+}
+
+// FileRotator manages log file rotation and archival. A file is rotated when the next write
+// would take it over MaxSize, when the write falls into a new RotateInterval period or, with
+// RotateOnStartup, when the rotator is created. Rotated files are named after the time the
+// file was started: app.log becomes app-2024-06-01T00-00.log.
 type FileRotator struct {
-	filename        string // Base filename for the log
-	maxSize         int64  // Maximum size in bytes before rotation
-	maxFiles        int    // Maximum number of rotated files to keep
-	compress        bool   // Whether to compress rotated files
-	currentFile     *os.File
-	currentSize     int64
-	mutex           sync.Mutex
+	filename    string // Base filename for the log
+	opts        RotatorOptions
+	currentFile *os.File
+	currentSize int64
+	startedAt   time.Time // When the current file was started, names it once rotated
+	nextRotate  time.Time // Start of the next rotation interval (zero without one)
+	mutex       sync.Mutex
+	archiving   sync.WaitGroup // Compression and cleanup of rotated files in progress
+
+	// Activity and errors, exposed through Status for diagnostics
+	lastWrite     time.Time
+	lastError     string
+	lastErrorTime time.Time
+	errorCount    int64
+}
+
+// RotatorStatus describes the rotator's recent writes and errors
+type RotatorStatus struct {
+	Filename      string
+	CurrentSize   int64
+	LastWrite     time.Time
+	LastError     string
+	LastErrorTime time.Time
+	ErrorCount    int64 // Failed writes and rotations since the rotator was created
 }
 
 // NewFileRotator creates a new file rotator
-func NewFileRotator(filename string, maxSize int64, maxFiles int, compress bool) (*FileRotator, error) {
+func NewFileRotator(filename string, opts RotatorOptions) (*FileRotator, error) {
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -34,50 +66,123 @@ func NewFileRotator(filename string, maxSize int64, maxFiles int, compress bool)
 
 	fr := &FileRotator{
 		filename: filename,
-		maxSize:  maxSize,
-		maxFiles: maxFiles,
-		compress: compress,
+		opts:     opts,
 	}
 
 	// Open initial file
-	if err := fr.openFile(); err != nil {
+	if err := fr.openFile(time.Now()); err != nil {
 		return nil, err
 	}
 
+	// A file left from an earlier run or rotation interval is rotated before anything is
+	// written to it
+	now := time.Now()
+	stale := opts.RotateInterval > 0 && fr.startedAt.Before(fr.periodStart(now))
+	if fr.currentSize > 0 && (opts.RotateOnStartup || stale) {
+		if err := fr.rotate(now); err != nil {
+			fr.Close()
+			return nil, fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+
 	return fr, nil
 }
 
-// Write implements io.Writer interface
+// Write implements io.Writer interface. Rotation happens under the same lock as writes, so
+// concurrent writes all land in either the old or the new file.
 func (fr *FileRotator) Write(p []byte) (int, error) {
 	fr.mutex.Lock()
 	defer fr.mutex.Unlock()
 
+	if fr.currentFile == nil {
+		return 0, os.ErrClosed
+	}
+
 	// Check if we need to rotate
-	if fr.currentSize+int64(len(p)) > fr.maxSize {
-		if err := fr.rotate(); err != nil {
-			return 0, fmt.Errorf("failed to rotate log file: %w", err)
+	now := time.Now()
+	if fr.currentSize > 0 && fr.dueForRotation(int64(len(p)), now) {
+		newPeriod := !fr.nextRotate.IsZero() && !now.Before(fr.nextRotate)
+		if err := fr.rotate(now); err != nil {
+			err = fmt.Errorf("failed to rotate log file: %w", err)
+			fr.recordError(err)
+			return 0, err
+		}
+		if newPeriod {
+			// Named after the interval it covers, however late its first write comes
+			fr.startedAt = fr.periodStart(now)
 		}
 	}
 
 	// Write to current file
 	n, err := fr.currentFile.Write(p)
 	if err != nil {
+		fr.recordError(err)
 		return n, err
 	}
 
 	fr.currentSize += int64(n)
+	fr.lastWrite = now
 	return n, nil
 }
 
-// Close closes the current log file
-func (fr *FileRotator) Close() error {
+// dueForRotation reports whether a write of n bytes at now goes into a new file (caller holds
+// the mutex)
+func (fr *FileRotator) dueForRotation(n int64, now time.Time) bool {
+	if fr.opts.MaxSize > 0 && fr.currentSize+n > fr.opts.MaxSize {
+		return true
+	}
+	return !fr.nextRotate.IsZero() && !now.Before(fr.nextRotate)
+}
+
+// periodStart returns the start of the rotation interval containing t, t itself without an
+// interval. Intervals that divide a day are aligned to local midnight, longer ones to the
+// Unix epoch.
+func (fr *FileRotator) periodStart(t time.Time) time.Time {
+	interval := fr.opts.RotateInterval
+	if interval <= 0 {
+		return t
+	}
+	if (24*time.Hour)%interval != 0 {
+		return t.Truncate(interval)
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight) / interval * interval)
+}
+
+// recordError notes a failed write or rotation; the caller holds the mutex
+func (fr *FileRotator) recordError(err error) {
+	fr.lastError = err.Error()
+	fr.lastErrorTime = time.Now()
+	fr.errorCount++
+}
+
+// Status returns the rotator's recent writes and errors
+func (fr *FileRotator) Status() RotatorStatus {
 	fr.mutex.Lock()
 	defer fr.mutex.Unlock()
 
+	return RotatorStatus{
+		Filename:      fr.filename,
+		CurrentSize:   fr.currentSize,
+		LastWrite:     fr.lastWrite,
+		LastError:     fr.lastError,
+		LastErrorTime: fr.lastErrorTime,
+		ErrorCount:    fr.errorCount,
+	}
+}
+
+// Close closes the current log file and waits for rotated files to be archived
+func (fr *FileRotator) Close() error {
+	fr.mutex.Lock()
+	var err error
 	if fr.currentFile != nil {
-		return fr.currentFile.Close()
+		err = fr.currentFile.Close()
+		fr.currentFile = nil
 	}
-	return nil
+	fr.mutex.Unlock()
+
+	fr.archiving.Wait()
+	return err
 }
 
 // Sync syncs the current log file to disk
@@ -91,8 +196,9 @@ func (fr *FileRotator) Sync() error {
 	return nil
 }
 
-// openFile opens or creates the log file
-func (fr *FileRotator) openFile() error {
+// openFile opens or creates the log file. A new file is started now; an existing one is taken
+// to have started in the rotation interval it was last written in.
+func (fr *FileRotator) openFile(now time.Time) error {
 	file, err := os.OpenFile(fr.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %w", err)
@@ -107,39 +213,106 @@ func (fr *FileRotator) openFile() error {
 
 	fr.currentFile = file
 	fr.currentSize = info.Size()
+	fr.startedAt = now
+	if fr.currentSize > 0 {
+		fr.startedAt = fr.periodStart(info.ModTime())
+	}
+	if fr.opts.RotateInterval > 0 {
+		fr.nextRotate = fr.periodStart(now).Add(fr.opts.RotateInterval)
+	}
 	return nil
 }
 
-// rotate rotates the current log file
-func (fr *FileRotator) rotate() error {
+// rotate moves the current log file to its timestamped name and starts a new one (caller
+// holds the mutex)
+func (fr *FileRotator) rotate(now time.Time) error {
 	// Close current file
 	if fr.currentFile != nil {
 		fr.currentFile.Close()
 		fr.currentFile = nil
 	}
 
-	// Generate timestamp for rotated file
-	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	
 	// Move current file to rotated name
-	rotatedName := fr.filename + "." + timestamp
+	rotatedName := fr.rotatedName(fr.startedAt)
 	if err := os.Rename(fr.filename, rotatedName); err != nil {
+		// Keep writing to the current file rather than losing lines
+		if openErr := fr.openFile(now); openErr != nil {
+			return openErr
+		}
 		return fmt.Errorf("failed to rename log file: %w", err)
 	}
 
-	// Compress rotated file if enabled
-	if fr.compress {
-		go fr.compressFile(rotatedName)
+	// Compress, then clean up old files, so both rotation triggers keep the same number
+	fr.archiving.Add(1)
+	go func() {
+		defer fr.archiving.Done()
+		if fr.opts.Compress {
+			fr.compressFile(rotatedName)
+		}
+		fr.cleanupOldFiles()
+	}()
+
+	// Open new file
+	return fr.openFile(now)
+}
+
+// rotatedName returns an unused name for a file started at the given time, adding a
+// sequence number when several files start within the same minute
+func (fr *FileRotator) rotatedName(startedAt time.Time) string {
+	dir := filepath.Dir(fr.filename)
+	stem, ext := splitLogName(filepath.Base(fr.filename))
+	timestamp := startedAt.Format(rotatedTimeLayout)
+	for seq := 0; ; seq++ {
+		name := stem + "-" + timestamp + ext
+		if seq > 0 {
+			name = fmt.Sprintf("%s-%s-%d%s", stem, timestamp, seq, ext)
+		}
+		path := filepath.Join(dir, name)
+		if !fileExists(path) && !fileExists(path+".gz") {
+			return path
+		}
 	}
+}
 
-	// Clean up old files
-	go fr.cleanupOldFiles()
+// fileExists reports whether a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
 
-	// Open new file
-	return fr.openFile()
+// splitLogName splits a log file name into its stem and extension: "app.log" into "app" and ".log"
+func splitLogName(base string) (stem, ext string) {
+	ext = filepath.Ext(base)
+	return strings.TrimSuffix(base, ext), ext
 }
 
-// compressFile compresses a rotated log file
+// isRotatedName reports whether name is a rotated file of the log file base, either named
+// app-2024-06-01T00-00[-N].log[.gz] or, as earlier versions did, app.log.<timestamp>[.gz]
+func isRotatedName(base, name string) bool {
+	if strings.HasPrefix(name, base+".") {
+		return true
+	}
+	stem, ext := splitLogName(base)
+	rest, ok := strings.CutPrefix(name, stem+"-")
+	if !ok {
+		return false
+	}
+	rest = strings.TrimSuffix(rest, ".gz")
+	if rest, ok = strings.CutSuffix(rest, ext); !ok || len(rest) < len(rotatedTimeLayout) {
+		return false
+	}
+	if _, err := time.Parse(rotatedTimeLayout, rest[:len(rotatedTimeLayout)]); err != nil {
+		return false
+	}
+	seq := rest[len(rotatedTimeLayout):]
+	if seq == "" {
+		return true
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(seq, "-"))
+	return strings.HasPrefix(seq, "-") && err == nil
+}
+
+// compressFile compresses a rotated log file, removing it once the compressed copy is complete
 func (fr *FileRotator) compressFile(filename string) {
 	src, err := os.Open(filename)
 	if err != nil {
@@ -151,13 +324,18 @@ func (fr *FileRotator) compressFile(filename string) {
 	if err != nil {
 		return
 	}
-	defer dst.Close()
 
 	gw := gzip.NewWriter(dst)
-	defer gw.Close()
-
-	// Copy file content to gzip writer
-	if _, err := io.Copy(gw, src); err != nil {
+	_, err = io.Copy(gw, src)
+	if closeErr := gw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Keep the uncompressed file rather than a truncated archive
+		os.Remove(filename + ".gz")
 		return
 	}
 
@@ -167,7 +345,7 @@ func (fr *FileRotator) compressFile(filename string) {
 
 // cleanupOldFiles removes old rotated files beyond maxFiles limit
 func (fr *FileRotator) cleanupOldFiles() {
-	if fr.maxFiles <= 0 {
+	if fr.opts.MaxFiles <= 0 {
 		return
 	}
 
@@ -182,7 +360,7 @@ func (fr *FileRotator) cleanupOldFiles() {
 	// Collect rotated files
 	var rotatedFiles []os.DirEntry
 	for _, file := range files {
-		if strings.HasPrefix(file.Name(), base+".") {
+		if !file.IsDir() && isRotatedName(base, file.Name()) {
 			rotatedFiles = append(rotatedFiles, file)
 		}
 	}
@@ -198,7 +376,7 @@ func (fr *FileRotator) cleanupOldFiles() {
 	})
 
 	// Remove files beyond maxFiles limit
-	for i := fr.maxFiles; i < len(rotatedFiles); i++ {
+	for i := fr.opts.MaxFiles; i < len(rotatedFiles); i++ {
 		os.Remove(filepath.Join(dir, rotatedFiles[i].Name()))
 	}
 }
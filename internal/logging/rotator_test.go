@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readAllLogLines returns the lines of the current and rotated log files
+func readAllLogLines(t *testing.T, logPath string) []string {
+	t.Helper()
+	files, err := LogFiles(logPath)
+	if err != nil {
+		t.Fatalf("LogFiles failed: %v", err)
+	}
+	var lines []string
+	for _, path := range files {
+		reader, err := OpenLogFile(path)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", path, err)
+		}
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		reader.Close()
+	}
+	return lines
+}
+
+func TestRotatorIntervalUnderLoad(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	rotator, err := NewFileRotator(logPath, RotatorOptions{Compress: true, RotateInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create rotator: %v", err)
+	}
+
+	const writers, perWriter = 8, 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				fmt.Fprintf(rotator, "[2024-06-01 00:00:00] [INFO] writer %d line %d\n", w, i)
+				time.Sleep(2 * time.Millisecond)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := rotator.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	files, _ := LogFiles(logPath)
+	if len(files) < 3 {
+		t.Fatalf("Expected several rotations, got %v", files)
+	}
+	for _, path := range files[1:] {
+		name := filepath.Base(path)
+		if !strings.HasPrefix(name, "app-") || !strings.HasSuffix(name, ".log.gz") {
+			t.Errorf("Expected a compressed timestamped rotation, got %s", name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range readAllLogLines(t, logPath) {
+		if seen[line] {
+			t.Errorf("Duplicate line %q", line)
+		}
+		seen[line] = true
+	}
+	if len(seen) != writers*perWriter {
+		t.Errorf("Expected %d lines across the files, got %d", writers*perWriter, len(seen))
+	}
+}
+
+func TestRotatorStartupRollover(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	yesterday := time.Now().AddDate(0, 0, -1)
+	writeLogFile(t, logPath, []string{"[2024-06-01 10:00:00] [INFO] previous run"}, yesterday)
+
+	// Without a trigger the file is appended to
+	rotator, err := NewFileRotator(logPath, RotatorOptions{MaxFiles: 5})
+	if err != nil {
+		t.Fatalf("Failed to create rotator: %v", err)
+	}
+	rotator.Close()
+	if files, _ := LogFiles(logPath); len(files) != 1 {
+		t.Fatalf("Expected no rotation, got %v", files)
+	}
+
+	// A daily interval rotates a file from an earlier day, named after that day
+	rotator, err = NewFileRotator(logPath, RotatorOptions{MaxFiles: 5, RotateInterval: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create rotator: %v", err)
+	}
+	fmt.Fprintf(rotator, "[2024-06-02 10:00:00] [INFO] this run\n")
+	rotator.Close()
+	want := filepath.Join(dir, "app-"+yesterday.Format("2006-01-02")+"T00-00.log")
+	if files, _ := LogFiles(logPath); len(files) != 2 || files[1] != want {
+		t.Fatalf("Expected the old file rotated to %s, got %v", want, files)
+	}
+
+	// rotate_on_startup starts a new file regardless of its age
+	rotator, err = NewFileRotator(logPath, RotatorOptions{MaxFiles: 5, RotateOnStartup: true})
+	if err != nil {
+		t.Fatalf("Failed to create rotator: %v", err)
+	}
+	rotator.Close()
+	files, _ := LogFiles(logPath)
+	if len(files) != 3 {
+		t.Fatalf("Expected a second rotation, got %v", files)
+	}
+	if info, err := os.Stat(logPath); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty current file, got %v", err)
+	}
+	if lines := readAllLogLines(t, logPath); len(lines) != 2 {
+		t.Errorf("Expected both runs' lines to be kept, got %v", lines)
+	}
+}
+
+func TestIsRotatedName(t *testing.T) {
+	cases := map[string]bool{
+		"app-2024-06-01T00-00.log":      true,
+		"app-2024-06-01T00-00.log.gz":   true,
+		"app-2024-06-01T00-00-2.log.gz": true,
+		"app.log.2024-05-01-08-00-00":   true,
+		"app.log":                       false,
+		"app-access.log":                false,
+		"app-2024-06-01T00-00.txt":      false,
+		"app-2024-06-01T00-00-x.log":    false,
+		"other-2024-06-01T00-00.log":    false,
+	}
+	for name, want := range cases {
+		if got := isRotatedName("app.log", name); got != want {
+			t.Errorf("isRotatedName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lineTimeLayout is the timestamp layout written by the file log handler
+const lineTimeLayout = "2006-01-02 15:04:05"
+
+// LogLine is a single matching line from a log file
+type LogLine struct {
+	File      string    `json:"file"`
+	Timestamp time.Time `json:"-"`
+	Time      string    `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+}
+
+// SearchQuery describes which log lines to return
+type SearchQuery struct {
+	Text   string    // Case-insensitive substring, empty matches everything
+	Levels []string  // Accepted levels (upper case), empty accepts all
+	Since  time.Time // Only lines at or after this time, zero accepts all
+	Limit  int       // Maximum number of (most recent) results
+}
+
+// Matches reports whether a log entry satisfies the query
+func (q SearchQuery) Matches(timestamp time.Time, level, message string) bool {
+	if len(q.Levels) > 0 {
+		found := false
+		for _, l := range q.Levels {
+			if strings.EqualFold(l, level) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && !timestamp.IsZero() && timestamp.Before(q.Since) {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(message), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+// LogFiles returns the current log file followed by its rotated files, newest first.
+// Files that do not exist are skipped.
+func LogFiles(filename string) ([]string, error) {
+	dir := filepath.Dir(filename)
+	base := filepath.Base(filename)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var current []string
+	var rotated []logFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if name == base {
+			current = append(current, filepath.Join(dir, name))
+			continue
+		}
+		if !isRotatedName(base, name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		rotated = append(rotated, logFile{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+
+	// Sort rotated files by modification time (newest first), as the rotator does
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].modTime.After(rotated[j].modTime)
+	})
+
+	files := current
+	for _, f := range rotated {
+		files = append(files, f.path)
+	}
+	return files, nil
+}
+
+// OpenLogFile opens a log file for reading, transparently decompressing rotated .gz files
+func OpenLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open compressed log %s: %w", path, err)
+	}
+	return &gzipFile{Reader: gz, file: file}, nil
+}
+
+// gzipFile closes both the gzip reader and the underlying file
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// ParseLogLine parses a line in the "[2006-01-02 15:04:05] [LEVEL] message" format.
+// Lines in another format are returned with an empty level and zero timestamp.
+func ParseLogLine(line string) (timestamp time.Time, level, message string) {
+	rest := line
+	if strings.HasPrefix(rest, "[") && len(rest) > len(lineTimeLayout)+2 && rest[len(lineTimeLayout)+1] == ']' {
+		if ts, err := time.ParseInLocation(lineTimeLayout, rest[1:len(lineTimeLayout)+1], time.Local); err == nil {
+			timestamp = ts
+			rest = strings.TrimPrefix(rest[len(lineTimeLayout)+2:], " ")
+		}
+	}
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "] "); end > 0 {
+			level = rest[1:end]
+			rest = rest[end+2:]
+		}
+	}
+	return timestamp, level, rest
+}
+
+// newLogLine builds a LogLine from a parsed line of file
+func newLogLine(file string, timestamp time.Time, level, message string) LogLine {
+	line := LogLine{
+		File:      file,
+		Timestamp: timestamp,
+		Level:     level,
+		Message:   message,
+	}
+	if !timestamp.IsZero() {
+		line.Time = timestamp.Format(lineTimeLayout)
+	}
+	return line
+}
+
+// SearchFiles streams through the current and rotated log files and returns the most
+// recent matching lines (at most q.Limit) in chronological order. Files are read line
+// by line so memory use is bounded by the result limit, not the file sizes.
+func SearchFiles(filename string, q SearchQuery) ([]LogLine, bool, error) {
+	files, err := LogFiles(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	if q.Limit <= 0 {
+		return nil, false, nil
+	}
+
+	// Ring buffer keeping the last q.Limit matches
+	ring := make([]LogLine, 0, q.Limit)
+	next := 0
+	truncated := false
+
+	// Oldest file first so the ring ends up holding the newest matches
+	for i := len(files) - 1; i >= 0; i-- {
+		path := files[i]
+
+		// A file last written before "since" cannot contain newer lines
+		if !q.Since.IsZero() {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(q.Since) {
+				continue
+			}
+		}
+
+		reader, err := OpenLogFile(path)
+		if err != nil {
+			continue
+		}
+
+		buffered := bufio.NewReader(reader)
+		for {
+			line, readErr := buffered.ReadString('\n')
+			line = strings.TrimRight(line, "\r\n")
+			if line != "" {
+				timestamp, level, message := ParseLogLine(line)
+				if q.Matches(timestamp, level, message) {
+					match := newLogLine(filepath.Base(path), timestamp, level, message)
+					if len(ring) < q.Limit {
+						ring = append(ring, match)
+					} else {
+						ring[next] = match
+						next = (next + 1) % q.Limit
+						truncated = true
+					}
+				}
+			}
+			if readErr != nil {
+				break
+			}
+		}
+		reader.Close()
+	}
+
+	// Unroll the ring into chronological order
+	results := make([]LogLine, 0, len(ring))
+	results = append(results, ring[next:]...)
+	results = append(results, ring[:next]...)
+	return results, truncated, nil
+}
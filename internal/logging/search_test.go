@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeLogFile(t *testing.T, path string, lines []string, modTime time.Time) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if filepath.Ext(path) == ".gz" {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	if gz != nil {
+		gz.Close()
+	}
+	file.Close()
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set mod time: %v", err)
+	}
+}
+
+func TestParseLogLine(t *testing.T) {
+	ts, level, msg := ParseLogLine("[2024-05-01 10:00:00] [WARN] something happened")
+	if ts.IsZero() || ts.Hour() != 10 {
+		t.Errorf("Expected parsed timestamp, got %v", ts)
+	}
+	if level != "WARN" || msg != "something happened" {
+		t.Errorf("Unexpected parse result: level=%q message=%q", level, msg)
+	}
+
+	ts, level, msg = ParseLogLine("plain line")
+	if !ts.IsZero() || level != "" || msg != "plain line" {
+		t.Errorf("Expected unparsed line to pass through, got %v %q %q", ts, level, msg)
+	}
+}
+
+func TestSearchFiles(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	now := time.Now()
+
+	writeLogFile(t, logPath+".2024-05-01-09-00-00.gz", []string{
+		"[2024-05-01 08:00:00] [ERROR] old upstream failure",
+		"[2024-05-01 08:30:00] [INFO] old request ok",
+	}, now.Add(-2*time.Hour))
+	writeLogFile(t, logPath+".2024-05-01-10-00-00", []string{
+		"[2024-05-01 09:00:00] [ERROR] upstream failure one",
+	}, now.Add(-time.Hour))
+	writeLogFile(t, logPath, []string{
+		"[2024-05-01 10:00:00] [INFO] request ok",
+		"[2024-05-01 10:01:00] [ERROR] Upstream failure two",
+	}, now)
+	writeLogFile(t, filepath.Join(dir, "other.log"), []string{
+		"[2024-05-01 10:00:00] [ERROR] upstream failure elsewhere",
+	}, now)
+
+	files, err := LogFiles(logPath)
+	if err != nil {
+		t.Fatalf("LogFiles failed: %v", err)
+	}
+	if len(files) != 3 || files[0] != logPath {
+		t.Fatalf("Expected current file first followed by 2 rotations, got %v", files)
+	}
+
+	// Matches span rotated (including compressed) and current files, oldest first
+	results, truncated, err := SearchFiles(logPath, SearchQuery{Text: "upstream", Levels: []string{"ERROR"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchFiles failed: %v", err)
+	}
+	if truncated || len(results) != 3 {
+		t.Fatalf("Expected 3 matches without truncation, got %d (truncated=%v)", len(results), truncated)
+	}
+	if results[0].Message != "old upstream failure" || results[2].Message != "Upstream failure two" {
+		t.Errorf("Unexpected result order: %+v", results)
+	}
+
+	// The limit keeps only the most recent matches
+	results, truncated, _ = SearchFiles(logPath, SearchQuery{Text: "upstream", Limit: 2})
+	if !truncated || len(results) != 2 || results[0].Message != "upstream failure one" {
+		t.Errorf("Expected the 2 newest matches with truncation, got %+v (truncated=%v)", results, truncated)
+	}
+
+	// Files last modified before "since" are skipped entirely
+	results, _, _ = SearchFiles(logPath, SearchQuery{Text: "upstream", Since: now.Add(-90 * time.Minute), Limit: 10})
+	for _, r := range results {
+		if r.File == filepath.Base(logPath)+".2024-05-01-09-00-00.gz" {
+			t.Errorf("Expected old rotation to be skipped, got match %+v", r)
+		}
+	}
+}
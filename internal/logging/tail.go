@@ -0,0 +1,228 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultTailPollInterval is how often a followed log file is checked for new lines and rotation
+const defaultTailPollInterval = 250 * time.Millisecond
+
+// logLevels are the levels written by the file log handler, lowest first
+var logLevels = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// TailOptions configures Tail
+type TailOptions struct {
+	Query        SearchQuery   // Line filters; Limit is ignored
+	Lines        int           // Without Query.Since: how many recent matching lines of the current file to print first
+	Follow       bool          // Keep printing new lines until the context is cancelled
+	PollInterval time.Duration // How often to check for new lines and rotation, default 250ms
+}
+
+// LevelsAtLeast returns the levels at or above min (debug, info, warn or error)
+func LevelsAtLeast(min string) ([]string, error) {
+	for i, level := range logLevels {
+		if strings.EqualFold(level, min) || (strings.EqualFold(min, "warning") && level == "WARN") {
+			return append([]string(nil), logLevels[i:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", min)
+}
+
+// Tail passes matching lines of a log file to emit in order. With Query.Since set it starts
+// with the rotated files written in that window (compressed or not), otherwise with the last
+// Lines matches of the current file. With Follow it then waits for new lines, reopening the
+// file when the rotator replaces it and starting over when it is truncated.
+func Tail(ctx context.Context, filename string, opts TailOptions, emit func(LogLine)) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultTailPollInterval
+	}
+	t := &tailer{filename: filename, opts: opts, emit: emit}
+
+	if !opts.Query.Since.IsZero() {
+		t.emitRotated()
+	}
+
+	file, err := t.open(ctx)
+	if err != nil || file == nil {
+		return err
+	}
+	defer func() { file.Close() }()
+
+	// Backlog of the current file
+	var recent []LogLine
+	t.consume(func(line LogLine) {
+		if !opts.Query.Since.IsZero() {
+			emit(line)
+			return
+		}
+		if opts.Lines <= 0 {
+			return
+		}
+		if len(recent) == opts.Lines {
+			recent = recent[1:]
+		}
+		recent = append(recent, line)
+	})
+	for _, line := range recent {
+		emit(line)
+	}
+	if !opts.Follow {
+		t.flush()
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.PollInterval):
+		}
+
+		t.consume(emit)
+
+		current, err := os.Stat(filename)
+		if err != nil {
+			// Between the rotator's rename and reopen; keep the old file until the new one exists
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat log file: %w", err)
+		}
+
+		if !os.SameFile(info, current) {
+			// Rotated: finish the old file, then follow the new one from the start
+			t.consume(emit)
+			t.flush()
+			file.Close()
+			if file, err = t.open(ctx); err != nil || file == nil {
+				return err
+			}
+			continue
+		}
+		if current.Size() < t.offset {
+			// Truncated in place
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind log file: %w", err)
+			}
+			t.reader.Reset(file)
+			t.offset, t.pending = 0, ""
+		}
+	}
+}
+
+// tailer holds the read position of the followed file
+type tailer struct {
+	filename string
+	opts     TailOptions
+	emit     func(LogLine)
+
+	reader  *bufio.Reader
+	offset  int64  // Bytes consumed from the current file
+	pending string // Last line of the file while it is still being written
+}
+
+// open opens the current log file. When following, a missing file is waited for;
+// a nil file means the context was cancelled first.
+func (t *tailer) open(ctx context.Context) (*os.File, error) {
+	for {
+		file, err := os.Open(t.filename)
+		if err == nil {
+			if t.reader == nil {
+				t.reader = bufio.NewReader(file)
+			} else {
+				t.reader.Reset(file)
+			}
+			t.offset, t.pending = 0, ""
+			return file, nil
+		}
+		if !os.IsNotExist(err) || !t.opts.Follow {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(t.opts.PollInterval):
+		}
+	}
+}
+
+// consume reads the complete lines available in the file and passes the matching ones
+// to handle. An incomplete last line is kept until the rest of it is written.
+func (t *tailer) consume(handle func(LogLine)) {
+	for {
+		chunk, err := t.reader.ReadString('\n')
+		t.offset += int64(len(chunk))
+		if err != nil {
+			t.pending += chunk
+			return
+		}
+		line := t.pending + chunk
+		t.pending = ""
+		t.match(filepath.Base(t.filename), line, handle)
+	}
+}
+
+// flush handles an incomplete last line when the file will not be read further
+func (t *tailer) flush() {
+	if t.pending != "" {
+		t.match(filepath.Base(t.filename), t.pending, t.emit)
+		t.pending = ""
+	}
+}
+
+// match passes a line to handle if it satisfies the query
+func (t *tailer) match(file, line string, handle func(LogLine)) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return
+	}
+	timestamp, level, message := ParseLogLine(line)
+	if t.opts.Query.Matches(timestamp, level, message) {
+		handle(newLogLine(file, timestamp, level, message))
+	}
+}
+
+// emitRotated emits the matching lines of rotated files last written at or after
+// Query.Since, oldest first. A file being compressed is read from its uncompressed copy.
+func (t *tailer) emitRotated() {
+	files, err := LogFiles(t.filename)
+	if err != nil {
+		return
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		path := files[i]
+		if path == filepath.Clean(t.filename) {
+			continue
+		}
+		if strings.HasSuffix(path, ".gz") {
+			if _, err := os.Stat(strings.TrimSuffix(path, ".gz")); err == nil {
+				continue
+			}
+		}
+		if info, err := os.Stat(path); err != nil || info.ModTime().Before(t.opts.Query.Since) {
+			continue
+		}
+
+		reader, err := OpenLogFile(path)
+		if err != nil {
+			continue
+		}
+		buffered := bufio.NewReader(reader)
+		for {
+			line, readErr := buffered.ReadString('\n')
+			t.match(filepath.Base(path), line, t.emit)
+			if readErr != nil {
+				break
+			}
+		}
+		reader.Close()
+	}
+}
@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector gathers the lines emitted by Tail
+type collector struct {
+	mu    sync.Mutex
+	lines []LogLine
+}
+
+func (c *collector) emit(line LogLine) {
+	c.mu.Lock()
+	c.lines = append(c.lines, line)
+	c.mu.Unlock()
+}
+
+func (c *collector) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := make([]string, len(c.lines))
+	for i, line := range c.lines {
+		messages[i] = line.Message
+	}
+	return messages
+}
+
+// waitForMessages waits until n lines were collected
+func (c *collector) waitForMessages(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if messages := c.messages(); len(messages) >= n {
+			return messages
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	messages := c.messages()
+	t.Fatalf("Expected %d lines, got %d: %v", n, len(messages), messages)
+	return nil
+}
+
+func TestLevelsAtLeast(t *testing.T) {
+	levels, err := LevelsAtLeast("warn")
+	if err != nil || len(levels) != 2 || levels[0] != "WARN" || levels[1] != "ERROR" {
+		t.Errorf("Expected WARN and ERROR, got %v (%v)", levels, err)
+	}
+	if _, err := LevelsAtLeast("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestTailBacklog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	now := time.Now()
+
+	line := func(age time.Duration, level, message string) string {
+		return fmt.Sprintf("[%s] [%s] %s", now.Add(-age).Format(lineTimeLayout), level, message)
+	}
+
+	writeLogFile(t, logPath+".2024-05-01-08-00-00.gz", []string{
+		line(4*time.Hour, "ERROR", "too old"),
+	}, now.Add(-3*time.Hour))
+	writeLogFile(t, logPath+".2024-05-01-09-00-00.gz", []string{
+		line(2*time.Hour, "ERROR", "before the window"),
+		line(40*time.Minute, "ERROR", "compressed failure"),
+		line(35*time.Minute, "INFO", "compressed ok"),
+	}, now.Add(-30*time.Minute))
+	writeLogFile(t, logPath, []string{
+		line(20*time.Minute, "WARN", "current warning"),
+		line(10*time.Minute, "INFO", "current ok"),
+		line(time.Minute, "ERROR", "current failure"),
+	}, now)
+
+	levels, _ := LevelsAtLeast("warn")
+
+	// Without since, only the last matches of the current file
+	var c collector
+	if err := Tail(context.Background(), logPath, TailOptions{Query: SearchQuery{Levels: levels}, Lines: 1}, c.emit); err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	if messages := c.messages(); len(messages) != 1 || messages[0] != "current failure" {
+		t.Errorf("Expected the last matching line, got %v", messages)
+	}
+
+	// With since, rotations in the window (compressed included) come first
+	c = collector{}
+	query := SearchQuery{Levels: levels, Since: now.Add(-time.Hour)}
+	if err := Tail(context.Background(), logPath, TailOptions{Query: query}, c.emit); err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	want := []string{"compressed failure", "current warning", "current failure"}
+	if messages := c.messages(); fmt.Sprint(messages) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, messages)
+	}
+
+	if err := Tail(context.Background(), filepath.Join(dir, "missing.log"), TailOptions{}, c.emit); err == nil {
+		t.Error("Expected an error for a missing log file without follow")
+	}
+}
+
+func TestTailFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+
+	// Small files so the rotator renames and recreates the log several times
+	rotator, err := NewFileRotator(logPath, RotatorOptions{MaxSize: 512, MaxFiles: 100, Compress: true})
+	if err != nil {
+		t.Fatalf("Failed to create rotator: %v", err)
+	}
+	defer rotator.Close()
+	fmt.Fprintf(rotator, "[2024-05-01 10:00:00] [INFO] before tail\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var c collector
+	done := make(chan error, 1)
+	go func() {
+		done <- Tail(ctx, logPath, TailOptions{Query: SearchQuery{Text: "line"}, Follow: true, PollInterval: 2 * time.Millisecond}, c.emit)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	const total = 60
+	for i := 0; i < total; i++ {
+		fmt.Fprintf(rotator, "[2024-05-01 10:00:00] [INFO] line %03d\n", i)
+		if i%10 == 0 {
+			// A line written in two parts must still be emitted once, whole
+			rotator.Write([]byte("[2024-05-01 10:00:00] [WARN] partial "))
+			time.Sleep(10 * time.Millisecond)
+			rotator.Write([]byte("line\n"))
+		}
+		time.Sleep(3 * time.Millisecond)
+	}
+
+	messages := c.waitForMessages(t, total+total/10)
+	seen := 0
+	for _, message := range messages {
+		if message == "partial line" {
+			continue
+		}
+		if message != fmt.Sprintf("line %03d", seen) {
+			t.Fatalf("Expected line %03d next, got %q (all: %v)", seen, message, messages)
+		}
+		seen++
+	}
+	if seen != total {
+		t.Errorf("Expected %d lines, got %d", total, seen)
+	}
+
+	rotated, _ := LogFiles(logPath)
+	if len(rotated) < 2 {
+		t.Errorf("Expected the log to be rotated during the test, got files %v", rotated)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Tail returned %v", err)
+	}
+}
+
+func TestTailFollowsTruncation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	writeLogFile(t, logPath, []string{"[2024-05-01 10:00:00] [INFO] old line one", "[2024-05-01 10:00:00] [INFO] old line two"}, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var c collector
+	go Tail(ctx, logPath, TailOptions{Follow: true, PollInterval: 2 * time.Millisecond}, c.emit)
+	time.Sleep(20 * time.Millisecond)
+
+	if err := os.WriteFile(logPath, []byte("[2024-05-01 10:00:00] [INFO] new\n"), 0644); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	if messages := c.waitForMessages(t, 1); messages[0] != "new" {
+		t.Errorf("Expected the truncated file to be read from the start, got %v", messages)
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// accessLog writes one JSON object per proxied request (logging.format: json)
+type accessLog struct {
+	w      io.Writer
+	fields []string
+}
+
+// accessLogTokens is the token usage of a request in the access log
+type accessLogTokens struct {
+	InputTokens         int64 `json:"input_tokens"`
+	OutputTokens        int64 `json:"output_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_tokens"`
+}
+
+// accessLogEntry is what the logging middleware knows about a finished request
+type accessLogEntry struct {
+	start     time.Time
+	connID    string
+	requestID string
+	clientIP  string
+	method    string
+	path      string
+	endpoint  string
+	status    int
+	duration  time.Duration
+	bytes     int64
+}
+
+// SetAccessLog enables the JSON access log, written to w with the given fields
+// (config.AccessLogFieldNames when empty). A nil w disables it.
+func (lm *LoggingMiddleware) SetAccessLog(w io.Writer, fields []string) {
+	if w == nil {
+		lm.accessLog.Store(nil)
+		return
+	}
+	if len(fields) == 0 {
+		fields = config.AccessLogFieldNames
+	}
+	lm.accessLog.Store(&accessLog{w: w, fields: fields})
+}
+
+// writeAccessLog writes the access log line of a finished request, if the access log is enabled.
+// Retries and token usage come from the request's connection in the monitoring metrics.
+func (lm *LoggingMiddleware) writeAccessLog(entry accessLogEntry) {
+	log := lm.accessLog.Load()
+	if log == nil {
+		return
+	}
+
+	var retries int
+	var tokens accessLogTokens
+	group := ""
+	if lm.monitoringMiddleware != nil {
+		if conn, ok := lm.monitoringMiddleware.GetMetrics().GetConnection(entry.connID); ok {
+			retries = conn.RetryCount
+			tokens = accessLogTokens(conn.TokenUsage)
+		}
+		if ep := lm.monitoringMiddleware.endpointManager.GetEndpointByNameAny(entry.endpoint); ep != nil {
+			group = ep.Config.Group
+			if group == "" {
+				group = "Default"
+			}
+		}
+	}
+
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, field := range log.fields {
+		var value interface{}
+		switch field {
+		case "timestamp":
+			value = entry.start.UTC().Format(time.RFC3339Nano)
+		case "conn_id":
+			value = entry.connID
+		case "request_id":
+			value = entry.requestID
+		case "client_ip":
+			value = entry.clientIP
+		case "method":
+			value = entry.method
+		case "path":
+			value = entry.path
+		case "endpoint":
+			value = entry.endpoint
+		case "group":
+			value = group
+		case "status":
+			value = entry.status
+		case "retries":
+			value = retries
+		case "duration_ms":
+			value = float64(entry.duration.Microseconds()) / 1000
+		case "bytes_sent":
+			value = entry.bytes
+		case "tokens":
+			value = tokens
+		}
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, _ := json.Marshal(field)
+		encoded, _ := json.Marshal(value)
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(encoded)
+	}
+	line.WriteString("}\n")
+	log.w.Write(line.Bytes())
+}
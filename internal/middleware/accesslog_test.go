@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// newAccessLogTestMiddleware wraps a handler that answers from endpoint "main-1" after one
+// retry, reporting token usage, with the logging middleware writing its access log to out
+func newAccessLogTestMiddleware(out *bytes.Buffer, fields []string) http.Handler {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+		},
+	}
+	mm := NewMonitoringMiddleware(endpoint.NewManager(cfg))
+	lm := NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	lm.SetMonitoringMiddleware(mm)
+	lm.SetAccessLog(out, fields)
+
+	return lm.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connID, _ := r.Context().Value("conn_id").(string)
+		mm.RecordRetry(connID, "main-1")
+		mm.RecordTokenUsage(connID, "main-1", &monitor.TokenUsage{InputTokens: 10, OutputTokens: 5})
+		*r = *r.WithContext(context.WithValue(r.Context(), "selected_endpoint", "main-1"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var out bytes.Buffer
+	handler := newAccessLogTestMiddleware(&out, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader("{}"))
+	req.RemoteAddr = "10.0.0.7:5000"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON object, got %q: %v", out.String(), err)
+	}
+	expected := map[string]interface{}{
+		"client_ip":  "10.0.0.7",
+		"method":     "POST",
+		"path":       "/v1/messages",
+		"endpoint":   "main-1",
+		"group":      "main",
+		"status":     float64(201),
+		"retries":    float64(1),
+		"bytes_sent": float64(5),
+	}
+	for key, want := range expected {
+		if entry[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, entry[key])
+		}
+	}
+	tokens, _ := entry["tokens"].(map[string]interface{})
+	if tokens["input_tokens"] != float64(10) || tokens["output_tokens"] != float64(5) {
+		t.Errorf("Expected token usage 10/5, got %v", entry["tokens"])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string)); err != nil {
+		t.Errorf("Expected an RFC 3339 timestamp, got %v", entry["timestamp"])
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("Expected a numeric duration_ms, got %v", entry["duration_ms"])
+	}
+}
+
+func TestAccessLogFieldSelection(t *testing.T) {
+	var out bytes.Buffer
+	handler := newAccessLogTestMiddleware(&out, []string{"endpoint", "status"})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	if got := strings.TrimSpace(out.String()); got != `{"endpoint":"main-1","status":201}` {
+		t.Errorf("Expected only the selected fields in order, got %s", got)
+	}
+}
+
+func TestTrustedProxyClientIP(t *testing.T) {
+	cfg := &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Health:    config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Endpoints: []config.EndpointConfig{{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1}},
+	}
+	var out bytes.Buffer
+	mm := NewMonitoringMiddleware(endpoint.NewManager(cfg))
+	lm := NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	lm.SetMonitoringMiddleware(mm)
+	lm.SetAccessLog(&out, []string{"client_ip"})
+	lm.SetTrustedProxies([]string{"10.0.0.0/8"})
+	handler := lm.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(remoteAddr, xff string) string {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", xff)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return strings.TrimSpace(out.String())
+	}
+
+	// Behind the trusted nginx the original client is logged and recorded
+	if got := send("10.0.0.7:5000", "198.51.100.9"); got != `{"client_ip":"198.51.100.9"}` {
+		t.Errorf("Expected the forwarded client IP, got %s", got)
+	}
+	// The same header from anyone else is ignored
+	if got := send("203.0.113.5:5000", "198.51.100.9"); got != `{"client_ip":"203.0.113.5"}` {
+		t.Errorf("Expected a spoofed header to be ignored, got %s", got)
+	}
+
+	var recorded []string
+	for _, conn := range mm.GetMetrics().RecentConnections(0) {
+		recorded = append(recorded, conn.ClientIP)
+	}
+	if strings.Join(recorded, ",") != "198.51.100.9,203.0.113.5" {
+		t.Errorf("Expected the connection records to use the resolved IPs, got %v", recorded)
+	}
+}
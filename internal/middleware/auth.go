@@ -1,50 +1,128 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
 type AuthMiddleware struct {
-	config config.AuthConfig
+	config atomic.Pointer[config.AuthConfig]
 }
 
 func NewAuthMiddleware(cfg config.AuthConfig) *AuthMiddleware {
-	return &AuthMiddleware{
-		config: cfg,
-	}
+	am := &AuthMiddleware{}
+	am.config.Store(&cfg)
+	return am
 }
 
 func (am *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !am.config.Enabled {
+		cfg := am.config.Load()
+		if !cfg.Enabled {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		auth := r.Header.Get("Authorization")
 		if auth == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			apierror.Write(w, http.StatusUnauthorized, apierror.TypeAuthenticationFailed, "Authorization header required")
 			return
 		}
 
 		if !strings.HasPrefix(auth, "Bearer ") {
-			http.Error(w, "Invalid authorization format. Expected 'Bearer <token>'", http.StatusUnauthorized)
+			apierror.Write(w, http.StatusUnauthorized, apierror.TypeAuthenticationFailed, "Invalid authorization format. Expected 'Bearer <token>'")
 			return
 		}
 
-		token := strings.TrimPrefix(auth, "Bearer ")
-		if token != am.config.Token {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		name, ok := resolveClient(cfg, strings.TrimPrefix(auth, "Bearer "))
+		if !ok {
+			apierror.Write(w, http.StatusUnauthorized, apierror.TypeAuthenticationFailed, "Invalid token")
 			return
 		}
 
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientNameContextKey, name)))
+	})
+}
+
+// WrapHealth guards a health or readiness endpoint. It stays open to probes and uptime
+// monitors unless auth.protect_health is set, in which case it requires a token like Wrap.
+func (am *AuthMiddleware) WrapHealth(next http.Handler) http.Handler {
+	protected := am.Wrap(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if am.config.Load().ProtectHealth {
+			protected.ServeHTTP(w, r)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// ClientIdentity returns the identity used for per-client statistics.
+// When auth is enabled and the request carries a configured token, the client name from
+// auth.tokens, the configured label (or a short hash of the token) is returned. Unknown
+// tokens are identified by their hash. The raw token is never exposed.
+// An empty string means the caller should fall back to the client IP.
+func (am *AuthMiddleware) ClientIdentity(r *http.Request) string {
+	cfg := am.config.Load()
+	if !cfg.Enabled {
+		return ""
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == "" {
+		return ""
+	}
+	if name, ok := resolveClient(cfg, token); ok {
+		return name
+	}
+	return HashToken(token)
+}
+
+// resolveClient returns the client name for a presented token and whether the token is
+// accepted. Tokens are compared by their SHA-256 digests with subtle.ConstantTimeCompare
+// and every configured token is checked, so the response time reveals neither the length
+// of a token nor which one matched.
+func resolveClient(cfg *config.AuthConfig, token string) (string, bool) {
+	presented := sha256.Sum256([]byte(token))
+	matches := func(configured string) bool {
+		sum := sha256.Sum256([]byte(configured))
+		return configured != "" && subtle.ConstantTimeCompare(presented[:], sum[:]) == 1
+	}
+
+	name, ok := "", false
+	if matches(cfg.Token) {
+		name, ok = cfg.Label, true
+		if name == "" {
+			name = HashToken(token)
+		}
+	}
+	for _, client := range cfg.Tokens {
+		if matches(client.Token) && !ok {
+			name, ok = client.Name, true
+		}
+	}
+	return name, ok
+}
+
+// HashToken returns a short, non-reversible identifier for a bearer token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token-" + hex.EncodeToString(sum[:])[:12]
+}
+
 // UpdateConfig updates the auth middleware configuration
 func (am *AuthMiddleware) UpdateConfig(cfg config.AuthConfig) {
-	am.config = cfg
-}
\ No newline at end of file
+	am.config.Store(&cfg)
+}
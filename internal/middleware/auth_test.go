@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/monitor"
+)
+
+func TestNamedClientTokens(t *testing.T) {
+	cfg := &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Health:    config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Endpoints: []config.EndpointConfig{{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1}},
+	}
+	am := NewAuthMiddleware(config.AuthConfig{
+		Enabled: true,
+		Token:   "shared-token",
+		Label:   "team",
+		Tokens:  []config.AuthToken{{Name: "alice", Token: "alice-token"}, {Name: "bob", Token: "bob-token-longer"}},
+	})
+	mm := NewMonitoringMiddleware(endpoint.NewManager(cfg))
+	lm := NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	lm.SetMonitoringMiddleware(mm)
+	lm.SetAuthMiddleware(am)
+
+	var served []string
+	handler := lm.Wrap(am.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = append(served, ClientName(r.Context()))
+		connID, _ := r.Context().Value("conn_id").(string)
+		mm.RecordTokenUsage(connID, "main-1", &monitor.TokenUsage{InputTokens: int64(10 * len(served)), OutputTokens: 1})
+	})))
+
+	send := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, token := range []string{"alice-token", "bob-token-longer", "shared-token"} {
+		if code := send(token); code != http.StatusOK {
+			t.Errorf("Expected %s to be accepted, got %d", token, code)
+		}
+	}
+	for _, token := range []string{"alice-token-x", "alice-toke", "", "team", "alice"} {
+		if code := send(token); code != http.StatusUnauthorized {
+			t.Errorf("Expected %q to be rejected, got %d", token, code)
+		}
+	}
+	if len(served) != 3 || served[0] != "alice" || served[1] != "bob" || served[2] != "team" {
+		t.Errorf("Expected the client names in the request context, got %v", served)
+	}
+
+	usage := make(map[string]int64)
+	for _, client := range mm.GetMetrics().GetTopClients(0) {
+		usage[client.ID] = client.TokenUsage.InputTokens
+	}
+	if usage["alice"] != 10 || usage["bob"] != 20 || usage["team"] != 30 {
+		t.Errorf("Expected separate token usage per client, got %v", usage)
+	}
+	if _, ok := usage[HashToken("alice-token-x")]; !ok {
+		t.Errorf("Expected unknown tokens to be tracked by their hash, got %v", usage)
+	}
+
+	// Reloading without the named tokens revokes them
+	am.UpdateConfig(config.AuthConfig{Enabled: true, Token: "shared-token"})
+	if code := send("alice-token"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a removed token to be rejected, got %d", code)
+	}
+}
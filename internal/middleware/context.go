@@ -0,0 +1,32 @@
+package middleware
+
+import "context"
+
+// contextKey is the type of the request context keys set by the middlewares
+type contextKey string
+
+const (
+	// clientNameContextKey carries the client name the auth middleware resolved from the token
+	clientNameContextKey = contextKey("client_name")
+	// clientIDContextKey carries the identity used for per-client statistics and request rules
+	clientIDContextKey = contextKey("client_id")
+	// requestIDContextKey carries the ID of the client request
+	requestIDContextKey = contextKey("request_id")
+)
+
+// ClientName returns the client name of a request accepted by the auth middleware, if any
+func ClientName(ctx context.Context) string {
+	name, _ := ctx.Value(clientNameContextKey).(string)
+	return name
+}
+
+// WithClientID returns a context carrying the identity of the client a request came from
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDContextKey, clientID)
+}
+
+// ClientIDFromContext returns the identity of the client a request came from, if any
+func ClientIDFromContext(ctx context.Context) string {
+	clientID, _ := ctx.Value(clientIDContextKey).(string)
+	return clientID
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CORSMiddleware answers CORS preflight requests locally and adds
+// Access-Control-Allow-* headers for allowed origins
+type CORSMiddleware struct {
+	config config.CORSConfig
+	mutex  sync.RWMutex
+}
+
+func NewCORSMiddleware(cfg config.CORSConfig) *CORSMiddleware {
+	return &CORSMiddleware{
+		config: cfg,
+	}
+}
+
+func (cm *CORSMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.mutex.RLock()
+		cfg := cm.config
+		cm.mutex.RUnlock()
+
+		origin := r.Header.Get("Origin")
+		if !cfg.Enabled || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowOrigin, allowed := matchOrigin(cfg.AllowedOrigins, origin)
+		if !allowed {
+			if cfg.Strict {
+				apierror.Write(w, http.StatusForbidden, apierror.TypeOriginNotAllowed, "Origin not allowed")
+				return
+			}
+			// Without CORS headers the browser blocks the response itself
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		if allowOrigin != "*" {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		// Answer preflight requests locally instead of forwarding them upstream
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchOrigin returns the Access-Control-Allow-Origin value for origin and whether it is allowed
+func matchOrigin(allowedOrigins []string, origin string) (string, bool) {
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// UpdateConfig updates the CORS middleware configuration
+func (cm *CORSMiddleware) UpdateConfig(cfg config.CORSConfig) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.config = cfg
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func newTestCORSConfig(strict bool, origins ...string) config.CORSConfig {
+	return config.CORSConfig{
+		Enabled:        true,
+		AllowedOrigins: origins,
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		MaxAge:         10 * time.Minute,
+		Strict:         strict,
+	}
+}
+
+func TestCORSPreflightAnsweredLocally(t *testing.T) {
+	forwarded := false
+	handler := NewCORSMiddleware(newTestCORSConfig(false, "https://tool.internal")).Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			forwarded = true
+		}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/v1/messages", nil)
+	req.Header.Set("Origin", "https://tool.internal")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type,authorization")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if forwarded {
+		t.Error("Expected preflight not to be forwarded")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://tool.internal" {
+		t.Errorf("Unexpected Access-Control-Allow-Origin: %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
+		t.Errorf("Unexpected Access-Control-Allow-Methods: %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization" {
+		t.Errorf("Unexpected Access-Control-Allow-Headers: %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Unexpected Access-Control-Max-Age: %q", got)
+	}
+}
+
+func TestCORSStreamingResponse(t *testing.T) {
+	release := make(chan struct{})
+	handler := NewCORSMiddleware(newTestCORSConfig(false, "*")).Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: first\n\n")
+			w.(http.Flusher).Flush()
+			<-release
+			fmt.Fprint(w, "data: second\n\n")
+		}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/messages", nil)
+	req.Header.Set("Origin", "https://tool.internal")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected wildcard Access-Control-Allow-Origin on stream, got %q", got)
+	}
+
+	// The first event must arrive before the handler finishes, i.e. flushing still works
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read first event: %v", err)
+	}
+	if strings.TrimSpace(line) != "data: first" {
+		t.Errorf("Unexpected first event: %q", line)
+	}
+}
+
+func TestCORSOriginMismatch(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		req.Header.Set("Origin", "https://evil.example")
+		return req
+	}
+
+	// Strict mode rejects disallowed origins
+	rec := httptest.NewRecorder()
+	NewCORSMiddleware(newTestCORSConfig(true, "https://tool.internal")).Wrap(next).ServeHTTP(rec, newRequest())
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 in strict mode, got %d", rec.Code)
+	}
+
+	// Non-strict mode passes the request through without CORS headers
+	rec = httptest.NewRecorder()
+	NewCORSMiddleware(newTestCORSConfig(false, "https://tool.internal")).Wrap(next).ServeHTTP(rec, newRequest())
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected request to pass through in non-strict mode, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin for disallowed origin, got %q", got)
+	}
+
+	// Requests without an Origin header are never rejected
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	NewCORSMiddleware(newTestCORSConfig(true, "https://tool.internal")).Wrap(next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected same-origin/non-browser request to pass, got %d", rec.Code)
+	}
+}
@@ -1,18 +1,28 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
-	"strings"
+	"sync/atomic"
 	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/clientip"
 )
 
 // LoggingMiddleware provides request/response logging
 type LoggingMiddleware struct {
 	logger            *slog.Logger
 	monitoringMiddleware *MonitoringMiddleware
+	authMiddleware    *AuthMiddleware
+	accessLog         atomic.Pointer[accessLog]         // JSON access log, nil unless logging.format is json
+	requestIDHeader   atomic.Pointer[string]            // Header a client's own request ID is read from (forwarding.request_id_header)
+	clientIPs         atomic.Pointer[clientip.Resolver] // Trusted proxies (server.trusted_proxies), nil trusts none
 }
 
 // NewLoggingMiddleware creates a new logging middleware
@@ -27,6 +37,30 @@ func (lm *LoggingMiddleware) SetMonitoringMiddleware(mm *MonitoringMiddleware) {
 	lm.monitoringMiddleware = mm
 }
 
+// SetTrustedProxies sets the proxies whose forwarding headers give the client IP. Invalid
+// ranges are rejected by config validation; if one gets here, no proxy is trusted.
+func (lm *LoggingMiddleware) SetTrustedProxies(cidrs []string) {
+	resolver, err := clientip.New(cidrs)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ [客户端IP] trusted_proxies 配置无效，不信任任何代理: %v", err))
+		resolver = nil
+	}
+	lm.clientIPs.Store(resolver)
+}
+
+// ClientIP returns the client IP of a request, honoring forwarding headers from trusted proxies
+func (lm *LoggingMiddleware) ClientIP(r *http.Request) string {
+	return lm.clientIPs.Load().ClientIP(r)
+}
+
+// SetAuthMiddleware sets the auth middleware reference used to identify clients
+func (lm *LoggingMiddleware) SetAuthMiddleware(am *AuthMiddleware) {
+	lm.authMiddleware = am
+}
+
+// statusClientClosedRequest is the nginx-style status logged for requests the client aborted
+const statusClientClosedRequest = 499
+
 // responseWriter wraps http.ResponseWriter to capture status code and bytes written
 type responseWriter struct {
 	http.ResponseWriter
@@ -50,21 +84,73 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush forwards flushes so streaming responses work through the middleware
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over for a WebSocket tunnel. The request is logged with status
+// 101 and the bytes the tunnel writes to the client.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return &countingConn{Conn: conn, bytes: &rw.bytes}, brw, nil
+}
+
+// countingConn counts the bytes written to a hijacked connection
+type countingConn struct {
+	net.Conn
+	bytes *int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	*c.bytes += int64(n)
+	return n, err
+}
+
 // Wrap wraps an HTTP handler with logging
 func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		clientIP := getClientIP(r)
+		clientIP := lm.ClientIP(r)
 		userAgent := truncateString(r.UserAgent(), 50)
 		
+		// Identify client for per-client statistics (token label/hash if auth is enabled, otherwise IP)
+		clientID := clientIP
+		if lm.authMiddleware != nil {
+			if id := lm.authMiddleware.ClientIdentity(r); id != "" {
+				clientID = id
+			}
+		}
+
+		// Every request gets an ID that is returned to the client and tags its log lines
+		requestID := lm.requestIDFor(r)
+		w.Header().Set(RequestIDResponseHeader, requestID)
+
 		// Record request start in metrics - we'll update the endpoint later
 		var connID string
 		if lm.monitoringMiddleware != nil {
-			connID = lm.monitoringMiddleware.RecordRequest("unknown", clientIP, userAgent, r.Method, r.URL.Path)
+			connID = lm.monitoringMiddleware.RecordRequest("unknown", clientID, clientIP, userAgent, r.Method, r.URL.Path)
+			lm.monitoringMiddleware.RecordRequestID(connID, requestID)
 		}
 		
-		// Store connection ID in request context for use by proxy handler
-		r = r.WithContext(context.WithValue(r.Context(), "conn_id", connID))
+		// Store connection ID, request ID and client identity in request context for use by proxy handler
+		ctx := context.WithValue(r.Context(), "conn_id", connID)
+		ctx = context.WithValue(ctx, requestIDContextKey, requestID)
+		ctx = WithClientID(ctx, clientID)
+		r = r.WithContext(ctx)
 		
 		// Wrap response writer
 		rw := &responseWriter{
@@ -74,7 +160,7 @@ func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 		}
 
 		// Log initial request (without endpoint info yet)
-		lm.logger.Info("🚀 Request started",
+		lm.logger.InfoContext(r.Context(), "🚀 Request started",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"client_ip", clientIP,
@@ -95,14 +181,43 @@ func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 			selectedEndpoint = ep
 		}
 
+		// The client went away before we finished: record it as cancelled, not as a failure
+		// (the context of a hijacked WebSocket connection is cancelled once its tunnel closes)
+		if errors.Is(r.Context().Err(), context.Canceled) && rw.statusCode != http.StatusSwitchingProtocols {
+			if lm.monitoringMiddleware != nil && connID != "" {
+				lm.monitoringMiddleware.RecordCancelled(connID, duration, rw.bytes, selectedEndpoint)
+			}
+			lm.writeAccessLog(accessLogEntry{start: start, connID: connID, requestID: requestID, clientIP: clientIP, method: r.Method, path: r.URL.Path,
+				endpoint: selectedEndpoint, status: statusClientClosedRequest, duration: duration, bytes: rw.bytes})
+			lm.logger.InfoContext(r.Context(), "🚫 Request cancelled by client",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"endpoint", selectedEndpoint,
+				"status_code", statusClientClosedRequest,
+				"bytes_written", formatBytes(rw.bytes),
+				"duration", formatDuration(duration),
+				"client_ip", clientIP,
+				"conn_id", connID,
+			)
+			return
+		}
+
 		// Record response in metrics
 		if lm.monitoringMiddleware != nil && connID != "" {
 			lm.monitoringMiddleware.RecordResponse(connID, rw.statusCode, duration, rw.bytes, selectedEndpoint)
+
+			// Forwarder errors are marked by header; this also covers SSE error events sent after a 200
+			local := apierror.IsLocal(rw.Header())
+			if local || rw.statusCode >= 400 {
+				lm.monitoringMiddleware.RecordErrorOrigin(local)
+			}
 		}
+		lm.writeAccessLog(accessLogEntry{start: start, connID: connID, requestID: requestID, clientIP: clientIP, method: r.Method, path: r.URL.Path,
+			endpoint: selectedEndpoint, status: rw.statusCode, duration: duration, bytes: rw.bytes})
 
 		// Log response
 		statusEmoji := getStatusEmoji(rw.statusCode)
-		lm.logger.Info(fmt.Sprintf("%s Request completed", statusEmoji),
+		lm.logger.InfoContext(r.Context(), fmt.Sprintf("%s Request completed", statusEmoji),
 			"method", r.Method,
 			"path", r.URL.Path,
 			"endpoint", selectedEndpoint,
@@ -115,7 +230,7 @@ func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 
 		// Log slow requests as warnings
 		if duration > 10*time.Second {
-			lm.logger.Warn("🐌 Slow request detected",
+			lm.logger.WarnContext(r.Context(), "🐌 Slow request detected",
 				"method", r.Method,
 				"path", r.URL.Path,
 				"endpoint", selectedEndpoint,
@@ -148,22 +263,6 @@ func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 
 // Helper functions for better log formatting
 
-func getClientIP(r *http.Request) string {
-	// Check for X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return strings.Split(xff, ",")[0]
-	}
-	// Check for X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	// Use RemoteAddr as fallback
-	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
-		return r.RemoteAddr[:idx]
-	}
-	return r.RemoteAddr
-}
-
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -181,6 +280,8 @@ func getStatusEmoji(statusCode int) string {
 		return "⚠️"
 	case statusCode >= 500:
 		return "❌"
+	case statusCode == http.StatusSwitchingProtocols:
+		return "🔌"
 	default:
 		return "❓"
 	}
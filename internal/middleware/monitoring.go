@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"sync/atomic"
 	"time"
 
+	"endpoint_forwarder/config"
 	"endpoint_forwarder/internal/endpoint"
 	"endpoint_forwarder/internal/monitor"
 )
@@ -14,14 +17,67 @@ import (
 type MonitoringMiddleware struct {
 	endpointManager *endpoint.Manager
 	metrics         *monitor.Metrics
+	authMiddleware  *AuthMiddleware // Guards the health endpoints when auth.protect_health is set
+	groupsVersion   atomic.Int64 // Config version the endpoint to group mapping was taken from
 }
 
 // NewMonitoringMiddleware creates a new monitoring middleware
 func NewMonitoringMiddleware(endpointManager *endpoint.Manager) *MonitoringMiddleware {
-	return &MonitoringMiddleware{
+	mm := &MonitoringMiddleware{
 		endpointManager: endpointManager,
 		metrics:         monitor.NewMetrics(),
 	}
+	mm.groupsVersion.Store(endpointManager.GetConfigVersion())
+	mm.metrics.SetEndpointGroups(endpointGroups(endpointManager.GetAllEndpoints()))
+	return mm
+}
+
+// endpointGroups maps endpoint names to their group, "Default" for endpoints without one
+func endpointGroups(endpoints []*endpoint.Endpoint) map[string]string {
+	groups := make(map[string]string, len(endpoints))
+	for _, ep := range endpoints {
+		group := ep.Config.Group
+		if group == "" {
+			group = "Default"
+		}
+		groups[ep.Config.Name] = group
+	}
+	return groups
+}
+
+// syncEndpointGroups refreshes the endpoint to group mapping of the metrics after the
+// endpoint configuration changed, so later requests count towards the endpoints' new groups
+func (mm *MonitoringMiddleware) syncEndpointGroups() {
+	version := mm.endpointManager.GetConfigVersion()
+	if mm.groupsVersion.Swap(version) == version {
+		return
+	}
+	mm.metrics.SetEndpointGroups(endpointGroups(mm.endpointManager.GetAllEndpoints()))
+}
+
+// GetGroupStats returns the metrics of every configured group and of every group that received
+// requests before it was removed, sorted by name, with the time each group spent in cooldown
+func (mm *MonitoringMiddleware) GetGroupStats() []*monitor.GroupMetrics {
+	mm.syncEndpointGroups()
+	stats := mm.metrics.GetGroupStats()
+	for _, name := range endpointGroups(mm.endpointManager.GetAllEndpoints()) {
+		if stats[name] == nil {
+			stats[name] = &monitor.GroupMetrics{Name: name}
+		}
+	}
+	for name, cooldown := range mm.endpointManager.GetGroupManager().GetGroupCooldownTimes() {
+		if stats[name] == nil {
+			stats[name] = &monitor.GroupMetrics{Name: name}
+		}
+		stats[name].CooldownTime = cooldown
+	}
+
+	groups := make([]*monitor.GroupMetrics, 0, len(stats))
+	for _, group := range stats {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
 }
 
 // HealthResponse represents the health check response
@@ -42,33 +98,66 @@ type EndpointHealth struct {
 	Priority         int    `json:"priority"`
 }
 
-// RegisterHealthEndpoint registers health check endpoints
+// SetAuthMiddleware sets the auth middleware that guards the health endpoints
+func (mm *MonitoringMiddleware) SetAuthMiddleware(am *AuthMiddleware) {
+	mm.authMiddleware = am
+}
+
+// RegisterHealthEndpoint registers health check endpoints. /healthz and /readyz are the
+// Kubernetes-style names of the liveness and readiness checks.
 func (mm *MonitoringMiddleware) RegisterHealthEndpoint(mux *http.ServeMux) {
-	mux.HandleFunc("/health", mm.handleHealth)
-	mux.HandleFunc("/health/detailed", mm.handleDetailedHealth)
+	mux.Handle("/health", mm.guardHealth(mm.handleReady)) // Legacy alias for readiness
+	mux.Handle("/health/live", mm.guardHealth(mm.handleLive))
+	mux.Handle("/health/ready", mm.guardHealth(mm.handleReady))
+	mux.Handle("/healthz", mm.guardHealth(mm.handleLive))
+	mux.Handle("/readyz", mm.guardHealth(mm.handleReady))
+	mux.Handle("/health/detailed", mm.guardHealth(mm.handleDetailedHealth))
 	mux.HandleFunc("/metrics", mm.handleMetrics)
 }
 
-// handleHealth handles basic health check
-func (mm *MonitoringMiddleware) handleHealth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// guardHealth requires the forwarder token on a health endpoint when auth.protect_health is set
+func (mm *MonitoringMiddleware) guardHealth(handler http.HandlerFunc) http.Handler {
+	if mm.authMiddleware == nil {
+		return handler
+	}
+	return mm.authMiddleware.WrapHealth(handler)
+}
+
+// handleLive handles liveness checks: always 200 while the process is running
+func (mm *MonitoringMiddleware) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "alive",
+	})
+}
+
+// handleReady handles readiness checks: 200 only when at least one endpoint in an
+// active, non-cooldown group is healthy, otherwise 503 with group and endpoint details
+func (mm *MonitoringMiddleware) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	endpoints := mm.endpointManager.GetAllEndpoints()
 	healthyCount := 0
-	
 	for _, ep := range endpoints {
 		if ep.IsHealthy() {
 			healthyCount++
 		}
 	}
 
+	readiness := mm.endpointManager.GetReadiness()
+
 	status := "healthy"
 	statusCode := http.StatusOK
-	
-	if healthyCount == 0 {
+	if !readiness.Ready {
 		status = "unhealthy"
 		statusCode = http.StatusServiceUnavailable
 	} else if healthyCount < len(endpoints) {
@@ -77,11 +166,15 @@ func (mm *MonitoringMiddleware) handleHealth(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	
+
 	response := map[string]interface{}{
-		"status": status,
-		"healthy_endpoints": healthyCount,
-		"total_endpoints": len(endpoints),
+		"status":              status,
+		"ready":               readiness.Ready,
+		"healthy_endpoints":   healthyCount,
+		"total_endpoints":     len(endpoints),
+		"ready_endpoints":     readiness.ReadyEndpoints,
+		"groups":              readiness.Groups,
+		"unhealthy_endpoints": readiness.UnhealthyEndpoints,
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -180,6 +273,54 @@ func (mm *MonitoringMiddleware) handleMetrics(w http.ResponseWriter, r *http.Req
 	}
 	
 	fmt.Fprintf(w, "endpoint_forwarder_endpoints_healthy %d\n", healthyCount)
+
+	snapshot := mm.metrics.GetMetrics()
+
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_endpoint_latency_ms Response time percentiles of the endpoint's requests of the last few minutes\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_endpoint_latency_ms gauge\n")
+	for _, ep := range endpoints {
+		stats := snapshot.EndpointStats[ep.Config.Name]
+		if stats == nil || stats.Latency.Count() == 0 {
+			continue
+		}
+		p50, p95, p99 := stats.LatencyPercentiles()
+		for _, q := range []struct {
+			quantile string
+			value    time.Duration
+		}{{"0.5", p50}, {"0.95", p95}, {"0.99", p99}} {
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_latency_ms{name=\"%s\",quantile=\"%s\"} %d\n", ep.Config.Name, q.quantile, q.value.Milliseconds())
+		}
+	}
+	ruleHits := snapshot.RuleHits
+	if len(ruleHits) > 0 {
+		fmt.Fprintf(w, "# HELP endpoint_forwarder_rule_hits_total Requests matched by each request rule\n")
+		fmt.Fprintf(w, "# TYPE endpoint_forwarder_rule_hits_total counter\n")
+		for rule, hits := range ruleHits {
+			fmt.Fprintf(w, "endpoint_forwarder_rule_hits_total{rule=\"%s\"} %d\n", rule, hits)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_dry_run_requests_total Dry-run test requests sent from the WebUI (not counted as requests)\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_dry_run_requests_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_dry_run_requests_total %d\n", snapshot.DryRunRequests)
+
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_cancelled_requests_total Requests aborted by the client (not counted as failures)\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_cancelled_requests_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_cancelled_requests_total %d\n", snapshot.CancelledRequests)
+
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_coalesced_requests_total Duplicate requests served from an identical in-flight request instead of being forwarded\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_coalesced_requests_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_coalesced_requests_total %d\n", snapshot.CoalescedRequests)
+
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_rejected_requests_total Requests refused before forwarding, by reason (also counted as failed)\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_rejected_requests_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_rejected_requests_total{reason=\"%s\"} %d\n", monitor.RejectBodyTooLarge, snapshot.RejectedRequests[monitor.RejectBodyTooLarge])
+	fmt.Fprintf(w, "endpoint_forwarder_rejected_requests_total{reason=\"%s\"} %d\n", monitor.RejectShed, snapshot.RejectedRequests[monitor.RejectShed])
+
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_errors_total Error responses by origin: generated by the forwarder (local) or passed through from an endpoint (upstream)\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_errors_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_errors_total{origin=\"local\"} %d\n", snapshot.LocalErrors)
+	fmt.Fprintf(w, "endpoint_forwarder_errors_total{origin=\"upstream\"} %d\n", snapshot.UpstreamErrors)
 }
 
 // GetMetrics returns the metrics instance for TUI access
@@ -188,20 +329,83 @@ func (mm *MonitoringMiddleware) GetMetrics() *monitor.Metrics {
 }
 
 // RecordRequest records a new request in metrics
-func (mm *MonitoringMiddleware) RecordRequest(endpoint, clientIP, userAgent, method, path string) string {
-	return mm.metrics.RecordRequest(endpoint, clientIP, userAgent, method, path)
+func (mm *MonitoringMiddleware) RecordRequest(endpoint, clientID, clientIP, userAgent, method, path string) string {
+	return mm.metrics.RecordRequest(endpoint, clientID, clientIP, userAgent, method, path)
 }
 
 // RecordResponse records a response in metrics
 func (mm *MonitoringMiddleware) RecordResponse(connID string, statusCode int, responseTime time.Duration, bytesSent int64, endpoint string) {
+	mm.syncEndpointGroups()
 	mm.metrics.RecordResponse(connID, statusCode, responseTime, bytesSent, endpoint)
 }
 
+// RecordCancelled records a request the client aborted before a response was sent
+func (mm *MonitoringMiddleware) RecordCancelled(connID string, responseTime time.Duration, bytesSent int64, endpoint string) {
+	mm.metrics.RecordCancelled(connID, responseTime, bytesSent, endpoint)
+}
+
+// RecordErrorOrigin records whether an error response came from the forwarder or an endpoint
+func (mm *MonitoringMiddleware) RecordErrorOrigin(local bool) {
+	mm.metrics.RecordErrorOrigin(local)
+}
+
 // RecordRetry records a retry attempt
 func (mm *MonitoringMiddleware) RecordRetry(connID string, endpoint string) {
+	mm.syncEndpointGroups()
 	mm.metrics.RecordRetry(connID, endpoint)
 }
 
+// RecordRateLimit records an upstream 429 response
+func (mm *MonitoringMiddleware) RecordRateLimit(connID string, endpoint string) {
+	mm.syncEndpointGroups()
+	mm.metrics.RecordRateLimit(connID, endpoint)
+}
+
+// RecordRejected records a request refused before forwarding, with the reason
+func (mm *MonitoringMiddleware) RecordRejected(connID string, reason string) {
+	mm.metrics.RecordRejected(connID, reason)
+}
+
+// RecordRuleHit records a request matched by a request rule
+func (mm *MonitoringMiddleware) RecordRuleHit(connID string, rule string) {
+	mm.metrics.RecordRuleHit(connID, rule)
+}
+
+// RecordDryRun records a dry-run test request
+func (mm *MonitoringMiddleware) RecordDryRun() {
+	mm.metrics.RecordDryRun()
+}
+
+// RecordRequestID records the request ID of a connection
+func (mm *MonitoringMiddleware) RecordRequestID(connID string, requestID string) {
+	mm.metrics.RecordRequestID(connID, requestID)
+}
+
+// RecordCoalesced records a duplicate request served with the response of connection leaderID
+func (mm *MonitoringMiddleware) RecordCoalesced(connID string, leaderID string) {
+	mm.metrics.RecordCoalesced(connID, leaderID)
+}
+
+// RecordPinned marks a connection as pinned to an endpoint by a routing override
+func (mm *MonitoringMiddleware) RecordPinned(connID string) {
+	mm.metrics.RecordPinned(connID)
+}
+
+// RecordIdempotentAttempt records an upstream attempt carrying the request's idempotency key
+func (mm *MonitoringMiddleware) RecordIdempotentAttempt(connID string, key string) {
+	mm.metrics.RecordIdempotentAttempt(connID, key)
+}
+
+// RecordAttempt records an upstream attempt of a connection, keeping at most limit attempts
+func (mm *MonitoringMiddleware) RecordAttempt(connID string, attempt monitor.AttemptInfo, limit int) {
+	mm.metrics.RecordAttempt(connID, attempt, limit)
+}
+
+// RecordAttemptBackoff records the wait before the next attempt on a connection's latest attempt
+func (mm *MonitoringMiddleware) RecordAttemptBackoff(connID string, backoff time.Duration) {
+	mm.metrics.RecordAttemptBackoff(connID, backoff)
+}
+
 // UpdateEndpointHealthStatus updates endpoint health in metrics
 func (mm *MonitoringMiddleware) UpdateEndpointHealthStatus() {
 	endpoints := mm.endpointManager.GetAllEndpoints()
@@ -222,10 +426,23 @@ func (mm *MonitoringMiddleware) UpdateConnectionEndpoint(connID, endpoint string
 
 // RecordTokenUsage records token usage for a specific request
 func (mm *MonitoringMiddleware) RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage) {
+	mm.syncEndpointGroups()
 	mm.metrics.RecordTokenUsage(connID, endpoint, tokens)
 }
 
+// UpdateConfig applies monitoring settings (client tracking limit, token history buckets, history limits)
+func (mm *MonitoringMiddleware) UpdateConfig(cfg config.MonitoringConfig) {
+	mm.metrics.SetMaxClients(cfg.MaxClients)
+	mm.metrics.SetTokenHistoryConfig(cfg.TokenHistoryInterval, cfg.TokenHistoryWindow)
+	mm.metrics.SetHistoryLimits(cfg.ConnectionHistorySize, cfg.TokenHistoryPoints, cfg.HistoryRetention)
+}
+
 // MarkStreamingConnection marks a connection as streaming
 func (mm *MonitoringMiddleware) MarkStreamingConnection(connID string) {
 	mm.metrics.MarkStreamingConnection(connID)
+}
+
+// MarkWebSocketConnection marks a connection as an upgraded WebSocket tunnel
+func (mm *MonitoringMiddleware) MarkWebSocketConnection(connID string) {
+	mm.metrics.MarkWebSocketConnection(connID)
 }
\ No newline at end of file
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestHealthLiveAndReady(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := NewMonitoringMiddleware(manager)
+	mux := http.NewServeMux()
+	mm.RegisterHealthEndpoint(mux)
+
+	get := func(path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body := map[string]interface{}{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+
+	for _, path := range []string{"/health/ready", "/health", "/readyz"} {
+		if rec, body := get(path); rec.Code != http.StatusOK || body["ready"] != true {
+			t.Errorf("Expected %s to be ready, got %d %v", path, rec.Code, body)
+		}
+	}
+
+	manager.GetGroupManager().SetGroupCooldown("main")
+
+	for _, path := range []string{"/health/ready", "/health", "/readyz"} {
+		rec, body := get(path)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected %s to return 503 with every group in cooldown, got %d", path, rec.Code)
+		}
+		if groups, ok := body["groups"].([]interface{}); !ok || len(groups) != 1 {
+			t.Errorf("Expected group states in %s body, got %v", path, body["groups"])
+		}
+	}
+
+	for _, path := range []string{"/health/live", "/healthz"} {
+		if rec, _ := get(path); rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to stay 200, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestHealthEndpointsAuth(t *testing.T) {
+	cfg := &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Health:    config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Endpoints: []config.EndpointConfig{{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1}},
+	}
+	am := NewAuthMiddleware(config.AuthConfig{Enabled: true, Token: "secret"})
+	mm := NewMonitoringMiddleware(endpoint.NewManager(cfg))
+	mm.SetAuthMiddleware(am)
+	mux := http.NewServeMux()
+	mm.RegisterHealthEndpoint(mux)
+
+	get := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Open to probes by default, even with auth enabled
+	if code := get("/readyz", ""); code != http.StatusOK {
+		t.Errorf("Expected /readyz to be open by default, got %d", code)
+	}
+
+	am.UpdateConfig(config.AuthConfig{Enabled: true, Token: "secret", ProtectHealth: true})
+	for _, path := range []string{"/healthz", "/readyz", "/health/detailed"} {
+		if code := get(path, ""); code != http.StatusUnauthorized {
+			t.Errorf("Expected %s to require a token with protect_health, got %d", path, code)
+		}
+	}
+	if code := get("/healthz", "secret"); code != http.StatusOK {
+		t.Errorf("Expected /healthz to accept the token, got %d", code)
+	}
+}
+
+func TestErrorOriginCounting(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "main-1", URL: "http://127.0.0.1:1", Priority: 1},
+		},
+	}
+	mm := NewMonitoringMiddleware(endpoint.NewManager(cfg))
+	lm := NewLoggingMiddleware(slog.Default())
+	lm.SetMonitoringMiddleware(mm)
+
+	handlers := []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			apierror.Write(w, http.StatusBadGateway, apierror.TypeAllEndpointsFailed, "all endpoints failed")
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			apierror.WriteSSE(w, apierror.TypeAllEndpointsFailed, "all endpoints failed")
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(apierror.HeaderUpstream, "main-1")
+			w.WriteHeader(http.StatusNotFound)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	}
+	for _, h := range handlers {
+		lm.Wrap(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+	}
+
+	metrics := mm.GetMetrics().GetMetrics()
+	if metrics.LocalErrors != 2 || metrics.UpstreamErrors != 1 {
+		t.Errorf("Expected 2 local and 1 upstream error, got %d local and %d upstream",
+			metrics.LocalErrors, metrics.UpstreamErrors)
+	}
+}
+
+func TestGroupStatsFollowReload(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "ep1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+			{Name: "ep2", URL: "http://127.0.0.1:2", Priority: 2, Group: "main", GroupPriority: 1},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := NewMonitoringMiddleware(manager)
+
+	connID := mm.RecordRequest("unknown", "", "10.0.0.1", "test", "POST", "/v1/messages")
+	mm.RecordResponse(connID, http.StatusOK, time.Millisecond, 0, "ep2")
+	manager.GetGroupManager().SetGroupCooldown("main")
+
+	// ep2 moves to its own group
+	reloaded := *cfg
+	reloaded.Endpoints = []config.EndpointConfig{
+		{Name: "ep1", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1},
+		{Name: "ep2", URL: "http://127.0.0.1:2", Priority: 1, Group: "backup", GroupPriority: 2},
+	}
+	time.Sleep(time.Millisecond)
+	manager.UpdateConfig(&reloaded)
+	connID = mm.RecordRequest("unknown", "", "10.0.0.1", "test", "POST", "/v1/messages")
+	mm.RecordResponse(connID, http.StatusBadGateway, time.Millisecond, 0, "ep2")
+
+	stats := make(map[string]int64)
+	var cooldown time.Duration
+	for _, group := range mm.GetGroupStats() {
+		stats[group.Name] = group.TotalRequests
+		if group.Name == "main" {
+			cooldown = group.CooldownTime
+		}
+	}
+	if stats["main"] != 1 || stats["backup"] != 1 {
+		t.Errorf("Expected one request in each group, got %v", stats)
+	}
+	if cooldown <= 0 {
+		t.Errorf("Expected the cooldown ended by the reload to be counted for main, got %v", cooldown)
+	}
+}
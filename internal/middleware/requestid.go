@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// RequestIDResponseHeader returns the request ID to the client
+const RequestIDResponseHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// SetRequestIDHeader sets the header a client's own request ID is read from
+func (lm *LoggingMiddleware) SetRequestIDHeader(header string) {
+	if header == "" {
+		header = config.DefaultRequestIDHeader
+	}
+	lm.requestIDHeader.Store(&header)
+}
+
+// requestIDFor reuses the client's request ID when it sent a usable one, otherwise generates a new ID
+func (lm *LoggingMiddleware) requestIDFor(r *http.Request) string {
+	header := config.DefaultRequestIDHeader
+	if h := lm.requestIDHeader.Load(); h != nil {
+		header = *h
+	}
+	if id := r.Header.Get(header); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+// validRequestID reports whether a client-supplied ID is safe to put in log lines and headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random UUID (version 4)
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("req-%d", time.Now().UnixNano())
+	}
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+}
+
+// RequestIDFromContext returns the ID of the client request a context belongs to, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"math"
+	"time"
+)
+
+// Latency histogram layout: bucket i counts durations up to latencyBucketBase * 2^(i/4), so
+// each bucket is about 19% wider than the previous one. 80 buckets reach about 15 minutes;
+// longer durations land in the last bucket.
+const (
+	latencyBucketBase         = time.Millisecond
+	latencyBucketsPerDoubling = 4
+	latencyBuckets            = 80
+)
+
+// LatencyWindow is how long a latency histogram window lasts. Percentiles cover the current
+// and the previous window, so they follow a latency change within one to two windows.
+const LatencyWindow = 5 * time.Minute
+
+// LatencyHistogram tracks the distribution of response times in fixed log-scale buckets.
+// It is a plain value: recording never allocates and copying it copies the whole histogram.
+type LatencyHistogram struct {
+	current     [latencyBuckets]uint32
+	previous    [latencyBuckets]uint32
+	windowStart time.Time
+	max         time.Duration // Longest duration in the current and previous window
+	prevMax     time.Duration
+}
+
+// latencyBucket returns the bucket a duration is counted in
+func latencyBucket(d time.Duration) int {
+	if d <= latencyBucketBase {
+		return 0
+	}
+	i := int(math.Ceil(math.Log2(float64(d)/float64(latencyBucketBase)) * latencyBucketsPerDoubling))
+	if i >= latencyBuckets {
+		return latencyBuckets - 1
+	}
+	return i
+}
+
+// latencyBucketBound returns the upper bound of a bucket
+func latencyBucketBound(i int) time.Duration {
+	return time.Duration(float64(latencyBucketBase) * math.Exp2(float64(i)/latencyBucketsPerDoubling))
+}
+
+// rotate starts a new window when the current one is over
+func (h *LatencyHistogram) rotate(now time.Time) {
+	if h.windowStart.IsZero() {
+		h.windowStart = now
+		return
+	}
+	elapsed := now.Sub(h.windowStart)
+	if elapsed < LatencyWindow {
+		return
+	}
+	if elapsed < 2*LatencyWindow {
+		h.previous, h.prevMax = h.current, h.max
+	} else {
+		// Nothing was recorded for a whole window: the old samples are stale
+		h.previous, h.prevMax = [latencyBuckets]uint32{}, 0
+	}
+	h.current, h.max = [latencyBuckets]uint32{}, 0
+	h.windowStart = now
+}
+
+// Record adds a response time
+func (h *LatencyHistogram) Record(d time.Duration, now time.Time) {
+	h.rotate(now)
+	h.current[latencyBucket(d)]++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Count returns the number of response times the percentiles are computed from
+func (h *LatencyHistogram) Count() uint64 {
+	var count uint64
+	for i := 0; i < latencyBuckets; i++ {
+		count += uint64(h.current[i]) + uint64(h.previous[i])
+	}
+	return count
+}
+
+// Percentile returns the response time below which the fraction p (0-1) of the recorded
+// response times fall, or 0 without samples. The result is the upper bound of the bucket the
+// percentile falls in, capped at the longest recorded duration.
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p * float64(count)))
+	if rank < 1 {
+		rank = 1
+	}
+	longest := h.max
+	if h.prevMax > longest {
+		longest = h.prevMax
+	}
+
+	var seen uint64
+	for i := 0; i < latencyBuckets; i++ {
+		seen += uint64(h.current[i]) + uint64(h.previous[i])
+		if seen >= rank {
+			if bound := latencyBucketBound(i); bound < longest {
+				return bound
+			}
+			return longest
+		}
+	}
+	return longest
+}
+
+// Reset forgets every recorded response time
+func (h *LatencyHistogram) Reset() {
+	*h = LatencyHistogram{}
+}
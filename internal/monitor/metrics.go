@@ -2,6 +2,8 @@ package monitor
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -34,10 +36,24 @@ type Metrics struct {
 	
 	// Endpoint metrics
 	EndpointStats map[string]*EndpointMetrics
+
+	// Group metrics keyed by group name, aggregated from the endpoints' requests. Groups keep
+	// their numbers when endpoints move to another group or the group is removed on reload.
+	GroupStats     map[string]*GroupMetrics
+	EndpointGroups map[string]string // Current group of each endpoint, replaced on config reload
 	
 	// Connection metrics  
 	ActiveConnections map[string]*ConnectionInfo
-	ConnectionHistory []*ConnectionInfo
+	connectionHistory ring[*ConnectionInfo] // Finished connections, oldest first
+	HistoryRetention  time.Duration          // Finished connections older than this are dropped from the history
+
+	// Number of finished connections in the history. Only set on snapshots taken with
+	// GetMetrics, which leave the history itself out: use RecentConnections to read it.
+	HistoricalConnections int
+
+	// Client metrics (bounded, least-recently-seen clients are evicted)
+	ClientStats map[string]*ClientMetrics
+	MaxClients  int
 	
 	// System metrics
 	StartTime time.Time
@@ -45,8 +61,33 @@ type Metrics struct {
 	// Historical data (circular buffer)
 	RequestHistory    []RequestDataPoint
 	ResponseHistory   []ResponseTimePoint
-	TokenHistory      []TokenHistoryPoint
 	MaxHistoryPoints  int
+
+	// Token usage time-series (fixed-interval buckets, oldest first, bounded by the ring's capacity)
+	tokenHistory         ring[TokenHistoryPoint]
+	TokenHistoryInterval time.Duration
+	TokenHistoryWindow   time.Duration
+
+	// Request rule hits keyed by rule name
+	RuleHits map[string]int64
+
+	// Dry-run test requests sent from the WebUI; they are not part of any other counter
+	DryRunRequests int64
+
+	// Requests the client aborted before a response was sent; not counted as failures
+	CancelledRequests int64
+
+	// Duplicate requests that shared the upstream request of an identical one in flight
+	// (forwarding.coalesce_identical_requests)
+	CoalescedRequests int64
+
+	// Requests the forwarder refused before forwarding, keyed by reason (RejectBodyTooLarge, RejectShed);
+	// they are also counted as failed
+	RejectedRequests map[string]int64
+
+	// Error responses by origin: generated by the forwarder itself, or passed through from an endpoint
+	LocalErrors    int64
+	UpstreamErrors int64
 }
 
 // EndpointMetrics tracks metrics for a specific endpoint
@@ -61,14 +102,65 @@ type EndpointMetrics struct {
 	MaxResponseTime  time.Duration
 	LastUsed         time.Time
 	RetryCount       int64
+	RateLimitCount   int64 // Upstream 429 responses
 	Priority         int
 	Healthy          bool
 	TokenUsage       TokenUsage
+	Latency          LatencyHistogram // Response time distribution of the last few minutes
+}
+
+// LatencyPercentiles returns the p50, p95 and p99 response times of the endpoint's recent requests
+func (e *EndpointMetrics) LatencyPercentiles() (p50, p95, p99 time.Duration) {
+	return e.Latency.Percentile(0.50), e.Latency.Percentile(0.95), e.Latency.Percentile(0.99)
+}
+
+// GroupMetrics tracks metrics aggregated over the endpoints of a group
+type GroupMetrics struct {
+	Name               string
+	TotalRequests      int64
+	SuccessfulRequests int64
+	FailedRequests     int64
+	TotalResponseTime  time.Duration
+	RetryCount         int64
+	RateLimitCount     int64
+	TokenUsage         TokenUsage
+	LastUsed           time.Time
+	CooldownTime       time.Duration // Time spent in cooldown, filled in from the group manager
+}
+
+// SuccessRate returns the group's success rate as a percentage
+func (g *GroupMetrics) SuccessRate() float64 {
+	if g.TotalRequests == 0 {
+		return 0
+	}
+	return float64(g.SuccessfulRequests) / float64(g.TotalRequests) * 100
+}
+
+// AverageResponseTime returns the group's average response time
+func (g *GroupMetrics) AverageResponseTime() time.Duration {
+	if g.TotalRequests == 0 {
+		return 0
+	}
+	return g.TotalResponseTime / time.Duration(g.TotalRequests)
+}
+
+// ClientMetrics tracks aggregated metrics for a specific client
+type ClientMetrics struct {
+	ID                 string // Token label/hash when auth is enabled, otherwise client IP
+	ClientIP           string // Last seen client IP
+	TotalRequests      int64
+	SuccessfulRequests int64
+	FailedRequests     int64
+	TokenUsage         TokenUsage
+	FirstSeen          time.Time
+	LastSeen           time.Time
 }
 
 // ConnectionInfo represents an active connection
 type ConnectionInfo struct {
 	ID             string
+	RequestID      string // ID returned to the client as X-Request-Id and forwarded upstream
+	ClientID       string
 	ClientIP       string
 	UserAgent      string
 	StartTime      time.Time
@@ -78,11 +170,84 @@ type ConnectionInfo struct {
 	Endpoint       string
 	Port           string
 	RetryCount     int
-	Status         string // "active", "completed", "failed", "timeout"
+	Status         string // "active", "websocket", "completed", "failed", "cancelled", "timeout"
 	BytesReceived  int64
 	BytesSent      int64
 	IsStreaming    bool
 	TokenUsage     TokenUsage  // Token usage for this connection
+	IdempotencyKey string      // Idempotency key sent upstream on every attempt
+	KeyedAttempts  int         // Number of upstream attempts that carried IdempotencyKey
+	Pinned         bool        // Pinned to an endpoint by the client's X-Forwarder-Endpoint header
+	Attempts       []AttemptInfo // Upstream attempts in order, bounded to the last MaxConnectionAttempts
+	RejectReason   string        // Why the forwarder refused the request without forwarding it, empty otherwise
+	CoalescedWith  string        // Connection whose upstream response this duplicate request received, empty otherwise
+}
+
+// Reasons for requests refused by the forwarder before forwarding
+const (
+	RejectBodyTooLarge = "body_too_large" // Request body over server.max_request_body_size
+	RejectShed         = "shed"           // Answered with 503 right away while no endpoint could take requests (load_shedding)
+)
+
+// MaxConnectionAttempts is how many upstream attempts a connection keeps, enough for the
+// retries on several endpoints of a request that failed over
+const MaxConnectionAttempts = 20
+
+// AttemptInfo records one upstream attempt of a connection
+type AttemptInfo struct {
+	Endpoint   string
+	StartTime  time.Time
+	EndTime    time.Time
+	Outcome    string        // "success", "http-error", "rate-limited", "timeout", "conn-refused", "conn-reset", "dns-error", "stream-interrupted", "cancelled" or "error"
+	StatusCode int           // Upstream status code, 0 when no response was received
+	Streaming  bool          // Attempted by the streaming passthrough handler
+	Backoff    time.Duration // Wait before the next attempt, 0 when the next one started right away
+}
+
+// FailureClass groups the outcome of a failed attempt into the retry reason shown in the
+// connection lists: "connect", "timeouts", "429", "5xx", "4xx", "interrupted" or "errors".
+// It returns "" for successful and cancelled attempts.
+func (a AttemptInfo) FailureClass() string {
+	switch a.Outcome {
+	case "success", "cancelled":
+		return ""
+	case "conn-refused", "conn-reset", "dns-error":
+		return "connect"
+	case "timeout":
+		return "timeouts"
+	case "rate-limited":
+		return "429"
+	case "http-error":
+		if a.StatusCode >= 500 {
+			return "5xx"
+		}
+		return "4xx"
+	case "stream-interrupted":
+		return "interrupted"
+	}
+	return "errors"
+}
+
+// RetryReasons summarizes the failed attempts of a connection by failure class, in the order
+// the classes first occurred, e.g. "timeouts:2 5xx:1". It returns "" when no attempt failed.
+func RetryReasons(attempts []AttemptInfo) string {
+	var classes []string
+	counts := make(map[string]int)
+	for _, attempt := range attempts {
+		class := attempt.FailureClass()
+		if class == "" {
+			continue
+		}
+		if counts[class] == 0 {
+			classes = append(classes, class)
+		}
+		counts[class]++
+	}
+	reasons := make([]string, len(classes))
+	for i, class := range classes {
+		reasons[i] = fmt.Sprintf("%s:%d", class, counts[class])
+	}
+	return strings.Join(reasons, " ")
 }
 
 // RequestDataPoint represents a point in time for request metrics
@@ -101,7 +266,7 @@ type ResponseTimePoint struct {
 	MaxTime      time.Duration
 }
 
-// TokenHistoryPoint represents token usage at a point in time
+// TokenHistoryPoint represents token usage within a history bucket starting at Timestamp
 type TokenHistoryPoint struct {
 	Timestamp           time.Time
 	InputTokens         int64
@@ -111,28 +276,52 @@ type TokenHistoryPoint struct {
 	TotalTokens         int64
 }
 
+// Default history limits, see SetHistoryLimits
+const (
+	defaultConnectionHistorySize = 1000
+	defaultTokenHistoryPoints    = 1440
+	defaultHistoryRetention      = time.Hour
+)
+
 // NewMetrics creates a new metrics instance
 func NewMetrics() *Metrics {
 	return &Metrics{
 		EndpointStats:     make(map[string]*EndpointMetrics),
+		GroupStats:        make(map[string]*GroupMetrics),
+		EndpointGroups:    make(map[string]string),
 		ActiveConnections: make(map[string]*ConnectionInfo),
-		ConnectionHistory: make([]*ConnectionInfo, 0),
+		connectionHistory: newRing[*ConnectionInfo](defaultConnectionHistorySize),
+		HistoryRetention:  defaultHistoryRetention,
+		ClientStats:       make(map[string]*ClientMetrics),
+		RuleHits:          make(map[string]int64),
+		RejectedRequests:  make(map[string]int64),
+		MaxClients:        100,
 		StartTime:         time.Now(),
 		RequestHistory:    make([]RequestDataPoint, 0),
 		ResponseHistory:   make([]ResponseTimePoint, 0),
-		TokenHistory:      make([]TokenHistoryPoint, 0),
+		tokenHistory:      newRing[TokenHistoryPoint](defaultTokenHistoryPoints),
 		MaxHistoryPoints:  300, // 5 minutes of data at 1-second intervals
+		TokenHistoryInterval: time.Minute,
+		TokenHistoryWindow:   24 * time.Hour,
 		MinResponseTime:   time.Duration(0),
 		MaxResponseTime:   time.Duration(0),
 	}
 }
 
 // RecordRequest records a new request
-func (m *Metrics) RecordRequest(endpoint, clientIP, userAgent, method, path string) string {
+func (m *Metrics) RecordRequest(endpoint, clientID, clientIP, userAgent, method, path string) string {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.TotalRequests++
+
+	if clientID == "" {
+		clientID = clientIP
+	}
+	client := m.getOrCreateClient(clientID)
+	client.ClientIP = clientIP
+	client.TotalRequests++
+	client.LastSeen = time.Now()
 	
 	// Update endpoint stats
 	if m.EndpointStats[endpoint] == nil {
@@ -151,6 +340,7 @@ func (m *Metrics) RecordRequest(endpoint, clientIP, userAgent, method, path stri
 	// Create connection info
 	conn := &ConnectionInfo{
 		ID:           connID,
+		ClientID:     clientID,
 		ClientIP:     clientIP,
 		UserAgent:    userAgent,
 		StartTime:    time.Now(),
@@ -186,8 +376,9 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 		m.MaxResponseTime = responseTime
 	}
 
-	// Track success/failure
-	if statusCode >= 200 && statusCode < 400 {
+	// Track success/failure (101 is a WebSocket tunnel that ran until either side closed it)
+	succeeded := statusCode == 101 || statusCode >= 200 && statusCode < 400
+	if succeeded {
 		m.SuccessfulRequests++
 		// Ensure endpoint stats exist
 		if m.EndpointStats[endpoint] == nil && endpoint != "unknown" {
@@ -217,6 +408,18 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 		}
 	}
 
+	// Update the metrics of the endpoint's group
+	if group := m.groupStats(endpoint); group != nil {
+		group.TotalRequests++
+		if succeeded {
+			group.SuccessfulRequests++
+		} else {
+			group.FailedRequests++
+		}
+		group.TotalResponseTime += responseTime
+		group.LastUsed = time.Now()
+	}
+
 	// Update endpoint metrics
 	if endpoint != "unknown" && m.EndpointStats[endpoint] != nil {
 		endpointMetrics := m.EndpointStats[endpoint]
@@ -228,6 +431,7 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 		if responseTime > endpointMetrics.MaxResponseTime {
 			endpointMetrics.MaxResponseTime = responseTime
 		}
+		endpointMetrics.Latency.Record(responseTime, endpointMetrics.LastUsed)
 	}
 
 	// Update connection
@@ -235,20 +439,24 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 		conn.LastActivity = time.Now()
 		conn.BytesSent = bytesSent
 		
-		if statusCode >= 200 && statusCode < 400 {
+		if succeeded {
 			conn.Status = "completed"
 		} else {
 			conn.Status = "failed"
 		}
 
+		if client := m.ClientStats[conn.ClientID]; client != nil {
+			if succeeded {
+				client.SuccessfulRequests++
+			} else {
+				client.FailedRequests++
+			}
+			client.LastSeen = time.Now()
+		}
+
 		// Move to history and remove from active
-		m.ConnectionHistory = append(m.ConnectionHistory, conn)
+		m.addToHistory(conn)
 		delete(m.ActiveConnections, connID)
-		
-		// Limit history size
-		if len(m.ConnectionHistory) > 1000 {
-			m.ConnectionHistory = m.ConnectionHistory[len(m.ConnectionHistory)-1000:]
-		}
 	}
 
 	// Limit response times history
@@ -257,6 +465,103 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 	}
 }
 
+// RecordErrorOrigin counts an error response as generated by the forwarder (local) or
+// passed through from an upstream endpoint
+func (m *Metrics) RecordErrorOrigin(local bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if local {
+		m.LocalErrors++
+	} else {
+		m.UpstreamErrors++
+	}
+}
+
+// RecordCancelled records a request the client aborted. The connection moves to history with
+// status "cancelled" and is kept out of the failure counters of the endpoint and the client.
+func (m *Metrics) RecordCancelled(connID string, responseTime time.Duration, bytesSent int64, endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CancelledRequests++
+
+	if endpoint != "unknown" && m.EndpointStats[endpoint] != nil {
+		m.EndpointStats[endpoint].LastUsed = time.Now()
+	}
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.LastActivity = time.Now()
+		conn.BytesSent = bytesSent
+		conn.Status = "cancelled"
+
+		if client := m.ClientStats[conn.ClientID]; client != nil {
+			client.LastSeen = time.Now()
+		}
+
+		m.addToHistory(conn)
+		delete(m.ActiveConnections, connID)
+	}
+}
+
+// addToHistory adds a finished connection to the history, overwriting the oldest one when the
+// history is full and dropping the ones past the retention. Caller must hold the lock.
+func (m *Metrics) addToHistory(conn *ConnectionInfo) {
+	m.connectionHistory.push(conn)
+	m.connectionHistory.dropOldest(m.expiredConnections(time.Now()))
+}
+
+// expiredConnections returns how many of the oldest finished connections are past the
+// retention at now. Caller must hold the lock.
+func (m *Metrics) expiredConnections(now time.Time) int {
+	if m.HistoryRetention <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-m.HistoryRetention)
+	expired := 0
+	for expired < m.connectionHistory.len() && (*m.connectionHistory.at(expired)).LastActivity.Before(cutoff) {
+		expired++
+	}
+	return expired
+}
+
+// RecentConnections returns copies of the n most recently finished connections, oldest first.
+// A non-positive n returns the whole history.
+func (m *Metrics) RecentConnections(n int) []*ConnectionInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	first := m.expiredConnections(time.Now())
+	count := m.connectionHistory.len() - first
+	if n > 0 && n < count {
+		first += count - n
+		count = n
+	}
+	conns := make([]*ConnectionInfo, count)
+	for i := range conns {
+		conns[i] = copyConnection(*m.connectionHistory.at(first + i))
+	}
+	return conns
+}
+
+// SetHistoryLimits updates how many finished connections and token history buckets are kept
+// and how long finished connections stay in the history. Non-positive values leave a limit unchanged.
+func (m *Metrics) SetHistoryLimits(connections, tokenPoints int, retention time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if connections > 0 {
+		m.connectionHistory.resize(connections)
+	}
+	if tokenPoints > 0 {
+		m.tokenHistory.resize(tokenPoints)
+	}
+	if retention > 0 {
+		m.HistoryRetention = retention
+	}
+	m.connectionHistory.dropOldest(m.expiredConnections(time.Now()))
+}
+
 // RecordRetry records a retry attempt
 func (m *Metrics) RecordRetry(connID string, endpoint string) {
 	m.mu.Lock()
@@ -274,6 +579,183 @@ func (m *Metrics) RecordRetry(connID string, endpoint string) {
 	if endpointMetrics := m.EndpointStats[endpoint]; endpointMetrics != nil {
 		endpointMetrics.RetryCount++
 	}
+	if group := m.groupStats(endpoint); group != nil {
+		group.RetryCount++
+	}
+}
+
+// RecordRateLimit records an upstream 429 response for an endpoint
+func (m *Metrics) RecordRateLimit(connID string, endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.LastActivity = time.Now()
+	}
+
+	if m.EndpointStats[endpoint] == nil {
+		m.EndpointStats[endpoint] = &EndpointMetrics{
+			Name: endpoint,
+		}
+	}
+	m.EndpointStats[endpoint].RateLimitCount++
+	if group := m.groupStats(endpoint); group != nil {
+		group.RateLimitCount++
+	}
+}
+
+// RecordRuleHit records a request matched by a request rule
+func (m *Metrics) RecordRuleHit(connID string, rule string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.LastActivity = time.Now()
+	}
+	m.RuleHits[rule]++
+}
+
+// RecordRejected records a request the forwarder refused before forwarding it. The failure
+// itself is counted when the response is recorded; this keeps the reason.
+func (m *Metrics) RecordRejected(connID string, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.LastActivity = time.Now()
+		conn.RejectReason = reason
+	}
+	m.RejectedRequests[reason]++
+}
+
+// RecordDryRun records a dry-run test request. Test requests are kept out of the request,
+// endpoint and token statistics, so this counter is all they leave behind.
+func (m *Metrics) RecordDryRun() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DryRunRequests++
+}
+
+// RecordCoalesced records a duplicate request that received the response of the identical
+// request on connection leaderID instead of being forwarded itself
+func (m *Metrics) RecordCoalesced(connID string, leaderID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.CoalescedWith = leaderID
+		conn.LastActivity = time.Now()
+	}
+	m.CoalescedRequests++
+}
+
+// RecordIdempotentAttempt records an upstream attempt that carried the request's idempotency key
+func (m *Metrics) RecordIdempotentAttempt(connID string, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.IdempotencyKey = key
+		conn.KeyedAttempts++
+		conn.LastActivity = time.Now()
+	}
+}
+
+// RecordAttempt appends an upstream attempt to a connection, keeping at most limit
+// attempts (the most recent ones) when limit is positive
+func (m *Metrics) RecordAttempt(connID string, attempt AttemptInfo, limit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn, exists := m.ActiveConnections[connID]
+	if !exists {
+		return
+	}
+	conn.Attempts = append(conn.Attempts, attempt)
+	if limit > 0 && len(conn.Attempts) > limit {
+		conn.Attempts = append([]AttemptInfo(nil), conn.Attempts[len(conn.Attempts)-limit:]...)
+	}
+	conn.LastActivity = time.Now()
+}
+
+// RecordAttemptBackoff records the wait before the next attempt on the connection's latest attempt
+func (m *Metrics) RecordAttemptBackoff(connID string, backoff time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn, exists := m.ActiveConnections[connID]
+	if !exists || len(conn.Attempts) == 0 {
+		return
+	}
+	conn.Attempts[len(conn.Attempts)-1].Backoff = backoff
+	conn.LastActivity = time.Now()
+}
+
+// CancelledDisplayWindow is how long connections cancelled by their client stay in the connection lists
+const CancelledDisplayWindow = 30 * time.Second
+
+// RecentlyCancelled returns copies of the finished connections cancelled by their client
+// within CancelledDisplayWindow before now, most recently cancelled first
+func (m *Metrics) RecentlyCancelled(now time.Time) []*ConnectionInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var cancelled []*ConnectionInfo
+	for i := m.connectionHistory.len() - 1; i >= 0; i-- {
+		conn := *m.connectionHistory.at(i)
+		if now.Sub(conn.LastActivity) > CancelledDisplayWindow {
+			break
+		}
+		if conn.Status == "cancelled" {
+			cancelled = append(cancelled, copyConnection(conn))
+		}
+	}
+	return cancelled
+}
+
+// GetConnection returns a copy of an active or recently finished connection
+func (m *Metrics) GetConnection(connID string) (*ConnectionInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		return copyConnection(conn), true
+	}
+	for i := m.connectionHistory.len() - 1; i >= 0; i-- {
+		if conn := *m.connectionHistory.at(i); conn.ID == connID {
+			return copyConnection(conn), true
+		}
+	}
+	return nil, false
+}
+
+// copyConnection copies a connection, including its attempts
+func copyConnection(v *ConnectionInfo) *ConnectionInfo {
+	conn := *v
+	conn.Attempts = append([]AttemptInfo(nil), v.Attempts...)
+	return &conn
+}
+
+// RecordPinned marks a connection as pinned to an endpoint by a routing override
+func (m *Metrics) RecordPinned(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.Pinned = true
+		conn.LastActivity = time.Now()
+	}
+}
+
+// RecordRequestID records the request ID of a connection
+func (m *Metrics) RecordRequestID(connID string, requestID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.RequestID = requestID
+	}
 }
 
 // UpdateEndpointHealth updates endpoint health status
@@ -296,6 +778,53 @@ func (m *Metrics) UpdateEndpointHealth(endpoint, url string, healthy bool, prior
 	m.EndpointStats[endpoint].Priority = priority
 }
 
+// SetEndpointGroups replaces the endpoint to group mapping used to aggregate group metrics.
+// Requests recorded afterwards count towards an endpoint's new group; what was recorded for
+// its old group stays there. Endpoints missing from the mapping were removed from the config:
+// their latency histogram is cleared, so an endpoint added back under the same name starts afresh.
+func (m *Metrics) SetEndpointGroups(groups map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.EndpointGroups = make(map[string]string, len(groups))
+	for endpoint, group := range groups {
+		m.EndpointGroups[endpoint] = group
+	}
+	for name, stats := range m.EndpointStats {
+		if _, exists := groups[name]; !exists {
+			stats.Latency.Reset()
+		}
+	}
+}
+
+// GetGroupStats returns a copy of the metrics of every group that has received requests
+func (m *Metrics) GetGroupStats() map[string]*GroupMetrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]*GroupMetrics, len(m.GroupStats))
+	for name, v := range m.GroupStats {
+		group := *v
+		stats[name] = &group
+	}
+	return stats
+}
+
+// groupStats returns the metrics of an endpoint's current group, creating them on first use,
+// or nil if the endpoint is not in the mapping (caller holds the lock)
+func (m *Metrics) groupStats(endpoint string) *GroupMetrics {
+	name, ok := m.EndpointGroups[endpoint]
+	if !ok {
+		return nil
+	}
+	group := m.GroupStats[name]
+	if group == nil {
+		group = &GroupMetrics{Name: name}
+		m.GroupStats[name] = group
+	}
+	return group
+}
+
 // UpdateConnectionEndpoint updates the endpoint name for an active connection
 func (m *Metrics) UpdateConnectionEndpoint(connID, endpoint string) {
 	m.mu.Lock()
@@ -318,6 +847,18 @@ func (m *Metrics) MarkStreamingConnection(connID string) {
 	}
 }
 
+// MarkWebSocketConnection marks a connection as an upgraded WebSocket tunnel; it stays active
+// with status "websocket" until either side closes it
+func (m *Metrics) MarkWebSocketConnection(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.Status = "websocket"
+		conn.LastActivity = time.Now()
+	}
+}
+
 // GetMetrics returns a snapshot of current metrics
 func (m *Metrics) GetMetrics() *Metrics {
 	m.mu.RLock()
@@ -334,8 +875,35 @@ func (m *Metrics) GetMetrics() *Metrics {
 		MaxResponseTime:    m.MaxResponseTime,
 		StartTime:          m.StartTime,
 		EndpointStats:      make(map[string]*EndpointMetrics),
+		GroupStats:         make(map[string]*GroupMetrics, len(m.GroupStats)),
+		EndpointGroups:     make(map[string]string, len(m.EndpointGroups)),
 		ActiveConnections:  make(map[string]*ConnectionInfo),
-		ConnectionHistory:  make([]*ConnectionInfo, len(m.ConnectionHistory)),
+		HistoryRetention:   m.HistoryRetention,
+		ClientStats:        make(map[string]*ClientMetrics, len(m.ClientStats)),
+		MaxClients:         m.MaxClients,
+		TokenHistoryInterval: m.TokenHistoryInterval,
+		TokenHistoryWindow:   m.TokenHistoryWindow,
+		RuleHits:             make(map[string]int64, len(m.RuleHits)),
+		DryRunRequests:       m.DryRunRequests,
+		CancelledRequests:    m.CancelledRequests,
+		CoalescedRequests:    m.CoalescedRequests,
+		RejectedRequests:     make(map[string]int64, len(m.RejectedRequests)),
+		LocalErrors:          m.LocalErrors,
+		UpstreamErrors:       m.UpstreamErrors,
+	}
+
+	// Copy rule hits and rejections
+	for k, v := range m.RuleHits {
+		snapshot.RuleHits[k] = v
+	}
+	for k, v := range m.RejectedRequests {
+		snapshot.RejectedRequests[k] = v
+	}
+
+	// Copy client stats
+	for k, v := range m.ClientStats {
+		client := *v
+		snapshot.ClientStats[k] = &client
 	}
 
 	// Copy endpoint stats
@@ -351,53 +919,30 @@ func (m *Metrics) GetMetrics() *Metrics {
 			MaxResponseTime:    v.MaxResponseTime,
 			LastUsed:           v.LastUsed,
 			RetryCount:         v.RetryCount,
+			RateLimitCount:     v.RateLimitCount,
 			Priority:           v.Priority,
 			Healthy:            v.Healthy,
 			TokenUsage:         v.TokenUsage,
+			Latency:            v.Latency,
 		}
 	}
 
+	// Copy group stats and the endpoint to group mapping
+	for k, v := range m.GroupStats {
+		group := *v
+		snapshot.GroupStats[k] = &group
+	}
+	for k, v := range m.EndpointGroups {
+		snapshot.EndpointGroups[k] = v
+	}
+
 	// Copy active connections
 	for k, v := range m.ActiveConnections {
-		snapshot.ActiveConnections[k] = &ConnectionInfo{
-			ID:            v.ID,
-			ClientIP:      v.ClientIP,
-			UserAgent:     v.UserAgent,
-			StartTime:     v.StartTime,
-			LastActivity:  v.LastActivity,
-			Method:        v.Method,
-			Path:          v.Path,
-			Endpoint:      v.Endpoint,
-			Port:          v.Port,
-			RetryCount:    v.RetryCount,
-			Status:        v.Status,
-			BytesReceived: v.BytesReceived,
-			BytesSent:     v.BytesSent,
-			IsStreaming:   v.IsStreaming,
-			TokenUsage:    v.TokenUsage,
-		}
+		snapshot.ActiveConnections[k] = copyConnection(v)
 	}
 
-	// Copy connection history
-	for i, v := range m.ConnectionHistory {
-		snapshot.ConnectionHistory[i] = &ConnectionInfo{
-			ID:            v.ID,
-			ClientIP:      v.ClientIP,
-			UserAgent:     v.UserAgent,
-			StartTime:     v.StartTime,
-			LastActivity:  v.LastActivity,
-			Method:        v.Method,
-			Path:          v.Path,
-			Endpoint:      v.Endpoint,
-			Port:          v.Port,
-			RetryCount:    v.RetryCount,
-			Status:        v.Status,
-			BytesReceived: v.BytesReceived,
-			BytesSent:     v.BytesSent,
-			IsStreaming:   v.IsStreaming,
-			TokenUsage:    v.TokenUsage,
-		}
-	}
+	// The histories are left out, callers read them with RecentConnections and GetTokenHistory
+	snapshot.HistoricalConnections = m.connectionHistory.len() - m.expiredConnections(time.Now())
 
 	// Copy response times (last 100)
 	if len(m.ResponseTimes) > 0 {
@@ -434,6 +979,13 @@ func (m *Metrics) GetSuccessRate() float64 {
 	return float64(m.SuccessfulRequests) / float64(m.TotalRequests) * 100
 }
 
+// GetRequestCounts returns the cumulative total and failed request counts
+func (m *Metrics) GetRequestCounts() (total, failed int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.TotalRequests, m.FailedRequests
+}
+
 // GetP95ResponseTime calculates 95th percentile response time
 func (m *Metrics) GetP95ResponseTime() time.Duration {
 	m.mu.RLock()
@@ -465,22 +1017,8 @@ func (m *Metrics) RecordTokenUsage(connID string, endpoint string, tokens *Token
 	m.TotalTokenUsage.CacheCreationTokens += tokens.CacheCreationTokens
 	m.TotalTokenUsage.CacheReadTokens += tokens.CacheReadTokens
 
-	// Record token history point
-	historyPoint := TokenHistoryPoint{
-		Timestamp:           time.Now(),
-		InputTokens:         m.TotalTokenUsage.InputTokens,
-		OutputTokens:        m.TotalTokenUsage.OutputTokens,
-		CacheCreationTokens: m.TotalTokenUsage.CacheCreationTokens,
-		CacheReadTokens:     m.TotalTokenUsage.CacheReadTokens,
-		TotalTokens:         m.TotalTokenUsage.InputTokens + m.TotalTokenUsage.OutputTokens,
-	}
-	
-	m.TokenHistory = append(m.TokenHistory, historyPoint)
-	
-	// Limit token history size
-	if len(m.TokenHistory) > m.MaxHistoryPoints {
-		m.TokenHistory = m.TokenHistory[len(m.TokenHistory)-m.MaxHistoryPoints:]
-	}
+	// Accumulate into the current token history bucket
+	m.recordTokenBucket(time.Now(), tokens)
 
 	// Update endpoint-specific token metrics
 	if endpoint != "unknown" && m.EndpointStats[endpoint] != nil {
@@ -489,6 +1027,12 @@ func (m *Metrics) RecordTokenUsage(connID string, endpoint string, tokens *Token
 		m.EndpointStats[endpoint].TokenUsage.CacheCreationTokens += tokens.CacheCreationTokens
 		m.EndpointStats[endpoint].TokenUsage.CacheReadTokens += tokens.CacheReadTokens
 	}
+	if group := m.groupStats(endpoint); group != nil {
+		group.TokenUsage.InputTokens += tokens.InputTokens
+		group.TokenUsage.OutputTokens += tokens.OutputTokens
+		group.TokenUsage.CacheCreationTokens += tokens.CacheCreationTokens
+		group.TokenUsage.CacheReadTokens += tokens.CacheReadTokens
+	}
 
 	// Update connection info if available
 	if conn, exists := m.ActiveConnections[connID]; exists {
@@ -498,6 +1042,15 @@ func (m *Metrics) RecordTokenUsage(connID string, endpoint string, tokens *Token
 		conn.TokenUsage.CacheCreationTokens += tokens.CacheCreationTokens
 		conn.TokenUsage.CacheReadTokens += tokens.CacheReadTokens
 		conn.LastActivity = time.Now()
+
+		// Attribute token usage to the client that issued the request
+		if client := m.ClientStats[conn.ClientID]; client != nil {
+			client.TokenUsage.InputTokens += tokens.InputTokens
+			client.TokenUsage.OutputTokens += tokens.OutputTokens
+			client.TokenUsage.CacheCreationTokens += tokens.CacheCreationTokens
+			client.TokenUsage.CacheReadTokens += tokens.CacheReadTokens
+			client.LastSeen = time.Now()
+		}
 	}
 }
 
@@ -515,11 +1068,198 @@ func (m *Metrics) GetTokenHistory() []TokenHistoryPoint {
 	defer m.mu.RUnlock()
 
 	// Return a copy of the token history
-	history := make([]TokenHistoryPoint, len(m.TokenHistory))
-	copy(history, m.TokenHistory)
+	history := make([]TokenHistoryPoint, m.tokenHistory.len())
+	for i := range history {
+		history[i] = *m.tokenHistory.at(i)
+	}
 	return history
 }
 
+// maxTokenHistoryBuckets caps the number of buckets returned by GetTokenHistoryBuckets
+const maxTokenHistoryBuckets = 1440
+
+// SetTokenHistoryConfig updates the token history bucket interval and retention window.
+// Changing the interval discards existing buckets since they cannot be re-bucketed.
+func (m *Metrics) SetTokenHistoryConfig(interval, window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if interval <= 0 || window < interval {
+		return
+	}
+	if interval != m.TokenHistoryInterval {
+		m.tokenHistory.clear()
+	}
+	m.TokenHistoryInterval = interval
+	m.TokenHistoryWindow = window
+	m.trimTokenHistory(time.Now())
+}
+
+// GetTokenHistoryBuckets returns evenly spaced token usage buckets covering the given window
+// up to now, oldest first, along with the effective bucket interval. Idle periods are returned
+// as zero buckets. The interval is rounded down to a multiple of the configured bucket interval,
+// and both values fall back to the configured settings when out of range.
+func (m *Metrics) GetTokenHistoryBuckets(window, interval time.Duration) ([]TokenHistoryPoint, time.Duration) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	base := m.TokenHistoryInterval
+	if interval < base {
+		interval = base
+	}
+	interval = (interval / base) * base
+	if window <= 0 || window > m.TokenHistoryWindow {
+		window = m.TokenHistoryWindow
+	}
+
+	count := int(window / interval)
+	if count < 1 {
+		count = 1
+	}
+	if count > maxTokenHistoryBuckets {
+		count = maxTokenHistoryBuckets
+	}
+
+	end := time.Now().Truncate(interval)
+	start := end.Add(-time.Duration(count-1) * interval)
+
+	buckets := make([]TokenHistoryPoint, count)
+	for i := range buckets {
+		buckets[i].Timestamp = start.Add(time.Duration(i) * interval)
+	}
+
+	for i := 0; i < m.tokenHistory.len(); i++ {
+		point := m.tokenHistory.at(i)
+		if point.Timestamp.Before(start) {
+			continue
+		}
+		idx := int(point.Timestamp.Sub(start) / interval)
+		if idx >= count {
+			continue
+		}
+		bucket := &buckets[idx]
+		bucket.InputTokens += point.InputTokens
+		bucket.OutputTokens += point.OutputTokens
+		bucket.CacheCreationTokens += point.CacheCreationTokens
+		bucket.CacheReadTokens += point.CacheReadTokens
+		bucket.TotalTokens += point.TotalTokens
+	}
+
+	return buckets, interval
+}
+
+// recordTokenBucket adds token usage to the bucket containing now, starting a new bucket
+// when the interval has rolled over. Caller must hold the lock.
+func (m *Metrics) recordTokenBucket(now time.Time, tokens *TokenUsage) {
+	bucketStart := now.Truncate(m.TokenHistoryInterval)
+
+	n := m.tokenHistory.len()
+	if n == 0 || m.tokenHistory.at(n-1).Timestamp.Before(bucketStart) {
+		m.tokenHistory.push(TokenHistoryPoint{Timestamp: bucketStart})
+		m.trimTokenHistory(now)
+		n = m.tokenHistory.len()
+	}
+
+	bucket := m.tokenHistory.at(n - 1)
+	bucket.InputTokens += tokens.InputTokens
+	bucket.OutputTokens += tokens.OutputTokens
+	bucket.CacheCreationTokens += tokens.CacheCreationTokens
+	bucket.CacheReadTokens += tokens.CacheReadTokens
+	bucket.TotalTokens = bucket.InputTokens + bucket.OutputTokens
+}
+
+// trimTokenHistory drops buckets that fall outside the retention window. Caller must hold the lock.
+func (m *Metrics) trimTokenHistory(now time.Time) {
+	cutoff := now.Add(-m.TokenHistoryWindow)
+	drop := 0
+	for drop < m.tokenHistory.len() && !m.tokenHistory.at(drop).Timestamp.Add(m.TokenHistoryInterval).After(cutoff) {
+		drop++
+	}
+	m.tokenHistory.dropOldest(drop)
+}
+
+// SetMaxClients updates the maximum number of tracked clients, evicting extras if needed
+func (m *Metrics) SetMaxClients(maxClients int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if maxClients <= 0 {
+		return
+	}
+	m.MaxClients = maxClients
+	for len(m.ClientStats) > m.MaxClients {
+		m.evictOldestClient()
+	}
+}
+
+// GetTopClients returns client stats sorted by total tokens (then requests), limited to n entries.
+// A non-positive n returns all clients.
+func (m *Metrics) GetTopClients(n int) []*ClientMetrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make([]*ClientMetrics, 0, len(m.ClientStats))
+	for _, v := range m.ClientStats {
+		client := *v
+		clients = append(clients, &client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		ti := clients[i].TokenUsage.InputTokens + clients[i].TokenUsage.OutputTokens
+		tj := clients[j].TokenUsage.InputTokens + clients[j].TokenUsage.OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		if clients[i].TotalRequests != clients[j].TotalRequests {
+			return clients[i].TotalRequests > clients[j].TotalRequests
+		}
+		return clients[i].ID < clients[j].ID
+	})
+
+	if n > 0 && len(clients) > n {
+		clients = clients[:n]
+	}
+	return clients
+}
+
+// getOrCreateClient returns the stats entry for a client, creating it (and evicting
+// the least-recently-seen client when the map is full) if necessary. Caller must hold the lock.
+func (m *Metrics) getOrCreateClient(clientID string) *ClientMetrics {
+	if client, exists := m.ClientStats[clientID]; exists {
+		return client
+	}
+
+	if m.MaxClients > 0 {
+		for len(m.ClientStats) >= m.MaxClients {
+			m.evictOldestClient()
+		}
+	}
+
+	now := time.Now()
+	client := &ClientMetrics{
+		ID:        clientID,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	m.ClientStats[clientID] = client
+	return client
+}
+
+// evictOldestClient removes the least-recently-seen client. Caller must hold the lock.
+func (m *Metrics) evictOldestClient() {
+	var oldestID string
+	var oldest time.Time
+	for id, client := range m.ClientStats {
+		if oldestID == "" || client.LastSeen.Before(oldest) {
+			oldestID = id
+			oldest = client.LastSeen
+		}
+	}
+	if oldestID != "" {
+		delete(m.ClientStats, oldestID)
+	}
+}
+
 // generateConnectionID generates a unique connection ID
 func generateConnectionID() string {
 	return time.Now().Format("20060102150405.000000")
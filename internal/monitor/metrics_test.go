@@ -0,0 +1,445 @@
+package monitor
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestClientStatsAggregation(t *testing.T) {
+	m := NewMetrics()
+
+	connID := m.RecordRequest("unknown", "alice", "10.0.0.1", "test", "POST", "/v1/messages")
+	m.RecordTokenUsage(connID, "unknown", &TokenUsage{InputTokens: 100, OutputTokens: 50})
+	m.RecordResponse(connID, 200, 10*time.Millisecond, 128, "ep1")
+
+	time.Sleep(time.Microsecond)
+	connID = m.RecordRequest("unknown", "alice", "10.0.0.2", "test", "POST", "/v1/messages")
+	m.RecordResponse(connID, 502, 10*time.Millisecond, 0, "ep1")
+
+	client := m.GetMetrics().ClientStats["alice"]
+	if client == nil {
+		t.Fatal("Expected client stats for 'alice'")
+	}
+	if client.TotalRequests != 2 || client.SuccessfulRequests != 1 || client.FailedRequests != 1 {
+		t.Errorf("Unexpected request counts: total=%d success=%d failed=%d",
+			client.TotalRequests, client.SuccessfulRequests, client.FailedRequests)
+	}
+	if client.TokenUsage.InputTokens != 100 || client.TokenUsage.OutputTokens != 50 {
+		t.Errorf("Unexpected token usage: %+v", client.TokenUsage)
+	}
+	if client.ClientIP != "10.0.0.2" {
+		t.Errorf("Expected last seen IP '10.0.0.2', got '%s'", client.ClientIP)
+	}
+}
+
+func TestClientStatsFallbackToIP(t *testing.T) {
+	m := NewMetrics()
+	m.RecordRequest("unknown", "", "192.168.1.5", "test", "GET", "/v1/models")
+
+	if m.GetMetrics().ClientStats["192.168.1.5"] == nil {
+		t.Error("Expected client IP to be used when client ID is empty")
+	}
+}
+
+func TestClientStatsEviction(t *testing.T) {
+	m := NewMetrics()
+	m.SetMaxClients(2)
+
+	m.RecordRequest("unknown", "a", "1.1.1.1", "test", "GET", "/")
+	time.Sleep(time.Millisecond)
+	m.RecordRequest("unknown", "b", "1.1.1.2", "test", "GET", "/")
+	time.Sleep(time.Millisecond)
+	// Touch 'a' so that 'b' becomes the least recently seen client
+	m.RecordRequest("unknown", "a", "1.1.1.1", "test", "GET", "/")
+	time.Sleep(time.Millisecond)
+	m.RecordRequest("unknown", "c", "1.1.1.3", "test", "GET", "/")
+
+	stats := m.GetMetrics().ClientStats
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 tracked clients, got %d", len(stats))
+	}
+	if stats["b"] != nil {
+		t.Error("Expected least recently seen client 'b' to be evicted")
+	}
+	if stats["a"] == nil || stats["c"] == nil {
+		t.Error("Expected clients 'a' and 'c' to be retained")
+	}
+}
+
+func TestGetTopClients(t *testing.T) {
+	m := NewMetrics()
+
+	for _, c := range []struct {
+		id     string
+		tokens int64
+	}{{"low", 10}, {"high", 1000}, {"mid", 100}, {"none", 0}} {
+		connID := m.RecordRequest("unknown", c.id, "127.0.0.1", "test", "POST", "/v1/messages")
+		m.RecordTokenUsage(connID, "unknown", &TokenUsage{OutputTokens: c.tokens})
+		m.RecordResponse(connID, 200, time.Millisecond, 0, "ep1")
+	}
+
+	top := m.GetTopClients(3)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 clients, got %d", len(top))
+	}
+	expected := []string{"high", "mid", "low"}
+	for i, id := range expected {
+		if top[i].ID != id {
+			t.Errorf("Expected client %d to be '%s', got '%s'", i, id, top[i].ID)
+		}
+	}
+
+	if all := m.GetTopClients(0); len(all) != 4 {
+		t.Errorf("Expected all 4 clients with non-positive limit, got %d", len(all))
+	}
+}
+
+func TestTokenHistoryBucketing(t *testing.T) {
+	m := NewMetrics()
+	m.SetTokenHistoryConfig(time.Minute, time.Hour)
+
+	base := time.Now().Truncate(time.Minute)
+
+	m.mu.Lock()
+	m.recordTokenBucket(base.Add(-10*time.Minute), &TokenUsage{InputTokens: 10, OutputTokens: 5})
+	m.recordTokenBucket(base.Add(-10*time.Minute+30*time.Second), &TokenUsage{InputTokens: 1, OutputTokens: 1, CacheReadTokens: 7})
+	// Idle gap, then activity in the current minute
+	m.recordTokenBucket(base, &TokenUsage{OutputTokens: 100})
+	m.mu.Unlock()
+
+	if stored := len(m.GetTokenHistory()); stored != 2 {
+		t.Fatalf("Expected 2 stored buckets, got %d", stored)
+	}
+
+	buckets, interval := m.GetTokenHistoryBuckets(15*time.Minute, time.Minute)
+	if interval != time.Minute {
+		t.Errorf("Expected 1m interval, got %v", interval)
+	}
+	if len(buckets) != 15 {
+		t.Fatalf("Expected 15 buckets, got %d", len(buckets))
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].Timestamp.Sub(buckets[i-1].Timestamp) != time.Minute {
+			t.Fatalf("Buckets are not evenly spaced at index %d", i)
+		}
+	}
+
+	last := buckets[len(buckets)-1]
+	if !last.Timestamp.Equal(base) || last.OutputTokens != 100 {
+		t.Errorf("Unexpected current bucket: %+v", last)
+	}
+	old := buckets[len(buckets)-11]
+	if old.InputTokens != 11 || old.OutputTokens != 6 || old.CacheReadTokens != 7 || old.TotalTokens != 17 {
+		t.Errorf("Unexpected aggregated bucket: %+v", old)
+	}
+	if idle := buckets[len(buckets)-5]; idle.TotalTokens != 0 {
+		t.Errorf("Expected idle bucket to be empty, got %+v", idle)
+	}
+
+	// Coarser interval aggregates base buckets
+	coarse, interval := m.GetTokenHistoryBuckets(time.Hour, 30*time.Minute)
+	if interval != 30*time.Minute || len(coarse) != 2 {
+		t.Fatalf("Expected 2 buckets of 30m, got %d of %v", len(coarse), interval)
+	}
+	var total int64
+	for _, b := range coarse {
+		total += b.TotalTokens
+	}
+	if total != 117 {
+		t.Errorf("Expected 117 total tokens across coarse buckets, got %d", total)
+	}
+}
+
+func TestTokenHistoryRetention(t *testing.T) {
+	m := NewMetrics()
+	m.SetTokenHistoryConfig(time.Minute, 5*time.Minute)
+
+	now := time.Now()
+
+	m.mu.Lock()
+	m.recordTokenBucket(now.Add(-20*time.Minute), &TokenUsage{InputTokens: 1})
+	m.recordTokenBucket(now.Add(-2*time.Minute), &TokenUsage{InputTokens: 2})
+	m.recordTokenBucket(now, &TokenUsage{InputTokens: 3})
+	m.mu.Unlock()
+
+	history := m.GetTokenHistory()
+	if len(history) != 2 {
+		t.Fatalf("Expected buckets outside the window to be dropped, got %d buckets", len(history))
+	}
+	if history[0].InputTokens != 2 {
+		t.Errorf("Expected oldest retained bucket to hold 2 input tokens, got %d", history[0].InputTokens)
+	}
+}
+
+func TestAttemptTimelineBounded(t *testing.T) {
+	m := NewMetrics()
+	connID := m.RecordRequest("unknown", "", "10.0.0.1", "test", "POST", "/v1/messages")
+
+	start := time.Now()
+	for i, endpoint := range []string{"a", "a", "b", "c"} {
+		m.RecordAttempt(connID, AttemptInfo{
+			Endpoint:   endpoint,
+			StartTime:  start.Add(time.Duration(i) * time.Second),
+			EndTime:    start.Add(time.Duration(i)*time.Second + 100*time.Millisecond),
+			Outcome:    "http-error",
+			StatusCode: 502,
+		}, 3)
+	}
+	m.RecordAttempt("missing", AttemptInfo{Endpoint: "a"}, 3)
+
+	conn, ok := m.GetConnection(connID)
+	if !ok {
+		t.Fatal("Expected the active connection to be found")
+	}
+	if len(conn.Attempts) != 3 || conn.Attempts[0].Endpoint != "a" || conn.Attempts[2].Endpoint != "c" {
+		t.Errorf("Expected the last 3 attempts (a, b, c), got %+v", conn.Attempts)
+	}
+
+	// Snapshots must not share the attempt slice with the live connection
+	snapshot := m.GetMetrics()
+	snapshot.ActiveConnections[connID].Attempts[0].Endpoint = "changed"
+	if conn, _ := m.GetConnection(connID); conn.Attempts[0].Endpoint != "a" {
+		t.Error("Expected the snapshot to hold a copy of the attempts")
+	}
+
+	// Finished connections keep their timeline in the history
+	m.RecordResponse(connID, 502, time.Second, 0, "c")
+	if conn, ok := m.GetConnection(connID); !ok || len(conn.Attempts) != 3 {
+		t.Errorf("Expected the finished connection with its attempts, got %+v (%v)", conn, ok)
+	}
+	if _, ok := m.GetConnection("missing"); ok {
+		t.Error("Expected an unknown connection not to be found")
+	}
+}
+
+func TestRetryReasons(t *testing.T) {
+	m := NewMetrics()
+	connID := m.RecordRequest("unknown", "", "10.0.0.1", "test", "POST", "/v1/messages")
+
+	attempts := []AttemptInfo{
+		{Endpoint: "a", Outcome: "timeout"},
+		{Endpoint: "a", Outcome: "timeout"},
+		{Endpoint: "b", Outcome: "http-error", StatusCode: 503},
+		{Endpoint: "c", Outcome: "conn-refused"},
+		{Endpoint: "d", Outcome: "stream-interrupted", Streaming: true},
+		{Endpoint: "e", Outcome: "success", StatusCode: 200},
+	}
+	for i, attempt := range attempts {
+		m.RecordAttempt(connID, attempt, 10)
+		if i < len(attempts)-1 {
+			m.RecordAttemptBackoff(connID, time.Duration(i+1)*time.Second)
+		}
+	}
+
+	conn, _ := m.GetConnection(connID)
+	if conn.Attempts[1].Backoff != 2*time.Second || conn.Attempts[5].Backoff != 0 {
+		t.Errorf("Expected the backoff on the attempt it followed, got %+v", conn.Attempts)
+	}
+	if got, want := RetryReasons(conn.Attempts), "timeouts:2 5xx:1 connect:1 interrupted:1"; got != want {
+		t.Errorf("Expected reasons %q, got %q", want, got)
+	}
+	if got := RetryReasons([]AttemptInfo{{Outcome: "success"}, {Outcome: "cancelled"}}); got != "" {
+		t.Errorf("Expected no reasons without failed attempts, got %q", got)
+	}
+}
+
+func TestRecentlyCancelled(t *testing.T) {
+	m := NewMetrics()
+
+	cancelled := m.RecordRequest("unknown", "alice", "10.0.0.1", "test", "POST", "/v1/messages")
+	m.RecordCancelled(cancelled, time.Second, 0, "ep1")
+	completed := m.RecordRequest("unknown", "alice", "10.0.0.1", "test", "POST", "/v1/messages")
+	m.RecordResponse(completed, 200, time.Second, 10, "ep1")
+
+	recent := m.RecentlyCancelled(time.Now())
+	if len(recent) != 1 || recent[0].ID != cancelled || recent[0].Status != "cancelled" {
+		t.Fatalf("Expected only the cancelled connection, got %+v", recent)
+	}
+	if expired := m.RecentlyCancelled(time.Now().Add(CancelledDisplayWindow + time.Second)); len(expired) != 0 {
+		t.Errorf("Expected cancelled connections to drop out after %s, got %d", CancelledDisplayWindow, len(expired))
+	}
+}
+
+func TestGroupStatsAggregation(t *testing.T) {
+	m := NewMetrics()
+	m.SetEndpointGroups(map[string]string{"ep1": "main", "ep2": "main", "ep3": "backup"})
+
+	record := func(endpoint string, status int, tokens int64) {
+		connID := m.RecordRequest("unknown", "alice", "10.0.0.1", "test", "POST", "/v1/messages")
+		if tokens > 0 {
+			m.RecordTokenUsage(connID, endpoint, &TokenUsage{InputTokens: tokens, OutputTokens: tokens})
+		}
+		m.RecordResponse(connID, status, 10*time.Millisecond, 0, endpoint)
+	}
+	record("ep1", 200, 10)
+	record("ep2", 502, 0)
+	record("ep3", 200, 5)
+
+	// ep2 moves to the backup group: its earlier requests stay with main
+	m.SetEndpointGroups(map[string]string{"ep1": "main", "ep2": "backup", "ep3": "backup"})
+	record("ep2", 200, 1)
+
+	stats := m.GetGroupStats()
+	main, backup := stats["main"], stats["backup"]
+	if main == nil || backup == nil {
+		t.Fatalf("Expected stats for both groups, got %v", stats)
+	}
+	if main.TotalRequests != 2 || main.SuccessfulRequests != 1 || main.FailedRequests != 1 {
+		t.Errorf("Unexpected main counts: %+v", main)
+	}
+	if main.TokenUsage.InputTokens != 10 || main.SuccessRate() != 50 {
+		t.Errorf("Unexpected main tokens or success rate: %+v", main)
+	}
+	if backup.TotalRequests != 2 || backup.SuccessfulRequests != 2 || backup.TokenUsage.InputTokens != 6 {
+		t.Errorf("Unexpected backup stats: %+v", backup)
+	}
+
+	// Group totals add up to the overview totals
+	snapshot := m.GetMetrics()
+	var total, successful, failed int64
+	for _, group := range snapshot.GroupStats {
+		total += group.TotalRequests
+		successful += group.SuccessfulRequests
+		failed += group.FailedRequests
+	}
+	if total != snapshot.TotalRequests || successful != snapshot.SuccessfulRequests || failed != snapshot.FailedRequests {
+		t.Errorf("Expected group totals %d/%d/%d to match the overview %d/%d/%d",
+			total, successful, failed, snapshot.TotalRequests, snapshot.SuccessfulRequests, snapshot.FailedRequests)
+	}
+}
+
+func TestEndpointLatencyPercentiles(t *testing.T) {
+	m := NewMetrics()
+	m.SetEndpointGroups(map[string]string{"ep1": "main"})
+	for i := 0; i < 100; i++ {
+		responseTime := 100 * time.Millisecond
+		if i >= 90 {
+			responseTime = 2 * time.Second
+		}
+		connID := m.RecordRequest("ep1", "alice", "10.0.0.1", "test", "POST", "/v1/messages")
+		m.RecordResponse(connID, 200, responseTime, 0, "ep1")
+	}
+
+	stats := m.GetMetrics().EndpointStats["ep1"]
+	p50, p95, p99 := stats.LatencyPercentiles()
+	// Bucket bounds are at most 19% above the recorded value, and never above the maximum
+	if p50 < 100*time.Millisecond || p50 > 120*time.Millisecond {
+		t.Errorf("Expected p50 close to 100ms, got %v", p50)
+	}
+	if p95 != 2*time.Second || p99 != 2*time.Second {
+		t.Errorf("Expected p95 and p99 to be the 2s tail, got %v and %v", p95, p99)
+	}
+	if stats.Latency.Count() != 100 {
+		t.Errorf("Expected 100 samples, got %d", stats.Latency.Count())
+	}
+
+	// Removing the endpoint from the config forgets its latencies
+	m.SetEndpointGroups(map[string]string{})
+	if n := m.GetMetrics().EndpointStats["ep1"].Latency.Count(); n != 0 {
+		t.Errorf("Expected the histogram of a removed endpoint to be reset, got %d samples", n)
+	}
+}
+
+func TestLatencyHistogramWindows(t *testing.T) {
+	var h LatencyHistogram
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		h.Record(50*time.Millisecond, start)
+	}
+
+	// The endpoint slows down: the old window still counts until it is two windows old
+	slow := start.Add(LatencyWindow)
+	for i := 0; i < 50; i++ {
+		h.Record(3*time.Second, slow)
+	}
+	if p99 := h.Percentile(0.99); p99 != 3*time.Second {
+		t.Errorf("Expected p99 to follow the slow requests, got %v", p99)
+	}
+	if p50 := h.Percentile(0.50); p50 > 60*time.Millisecond {
+		t.Errorf("Expected p50 to still include the previous window, got %v", p50)
+	}
+
+	h.Record(3*time.Second, slow.Add(LatencyWindow))
+	if p50 := h.Percentile(0.50); p50 != 3*time.Second {
+		t.Errorf("Expected p50 to be slow once the fast window expired, got %v", p50)
+	}
+
+	// A pause longer than two windows drops everything recorded before it
+	h.Record(10*time.Millisecond, slow.Add(4*LatencyWindow))
+	if n, p99 := h.Count(), h.Percentile(0.99); n != 1 || p99 != 10*time.Millisecond {
+		t.Errorf("Expected only the latest sample after a long pause, got %d samples with p99 %v", n, p99)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { h.Record(time.Second, slow) }); allocs != 0 {
+		t.Errorf("Expected recording to be allocation free, got %.0f allocations", allocs)
+	}
+}
+
+func TestConnectionHistoryBounded(t *testing.T) {
+	m := NewMetrics()
+	m.SetHistoryLimits(3, 2, time.Hour)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		connID := m.RecordRequest("unknown", "alice", "10.0.0.1", "test", "POST", "/v1/messages")
+		m.RecordResponse(connID, 200, time.Millisecond, 10, "ep1")
+		ids = append(ids, connID)
+		time.Sleep(time.Microsecond)
+	}
+
+	if count := m.GetMetrics().HistoricalConnections; count != 3 {
+		t.Fatalf("Expected the history to hold 3 connections, got %d", count)
+	}
+	recent := m.RecentConnections(2)
+	if len(recent) != 2 || recent[0].ID != ids[3] || recent[1].ID != ids[4] {
+		t.Fatalf("Expected the last 2 connections oldest first, got %+v", recent)
+	}
+	if _, ok := m.GetConnection(ids[0]); ok {
+		t.Error("Expected the oldest connection to be overwritten")
+	}
+
+	// Shrinking keeps the newest connections
+	m.SetHistoryLimits(1, 0, 0)
+	if all := m.RecentConnections(0); len(all) != 1 || all[0].ID != ids[4] {
+		t.Fatalf("Expected only the newest connection after shrinking, got %+v", all)
+	}
+
+	// Connections past the retention are dropped
+	m.mu.Lock()
+	(*m.connectionHistory.at(0)).LastActivity = time.Now().Add(-2 * time.Hour)
+	m.mu.Unlock()
+	if all := m.RecentConnections(0); len(all) != 0 {
+		t.Errorf("Expected connections past the retention to be left out, got %d", len(all))
+	}
+
+	// Token history keeps at most token_history_points buckets
+	base := time.Now().Truncate(time.Minute)
+	m.mu.Lock()
+	for i := 5; i >= 0; i-- {
+		m.recordTokenBucket(base.Add(-time.Duration(i)*time.Minute), &TokenUsage{InputTokens: 1})
+	}
+	m.mu.Unlock()
+	if history := m.GetTokenHistory(); len(history) != 2 || !history[1].Timestamp.Equal(base) {
+		t.Errorf("Expected the 2 newest token buckets, got %+v", history)
+	}
+}
+
+// BenchmarkSustainedLoad records finished requests with token usage and reports the live heap
+// afterwards: it stays flat however many requests ran, since both histories are bounded.
+func BenchmarkSustainedLoad(b *testing.B) {
+	m := NewMetrics()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		connID := m.RecordRequest("ep1", "alice", "10.0.0.1", "bench", "POST", "/v1/messages")
+		m.RecordTokenUsage(connID, "ep1", &TokenUsage{InputTokens: 100, OutputTokens: 50})
+		m.RecordResponse(connID, 200, time.Millisecond, 512, "ep1")
+	}
+	b.StopTimer()
+
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	b.ReportMetric(float64(stats.HeapAlloc)/(1<<20), "heap-MB")
+	b.ReportMetric(float64(m.GetMetrics().HistoricalConnections), "history")
+}
@@ -0,0 +1,74 @@
+package monitor
+
+// ring is a fixed-capacity FIFO buffer: pushing onto a full ring overwrites the oldest item.
+// Its storage is allocated once per capacity, so a busy process does not keep growing it.
+type ring[T any] struct {
+	items []T
+	head  int // Index of the oldest item
+	count int
+}
+
+func newRing[T any](capacity int) ring[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return ring[T]{items: make([]T, capacity)}
+}
+
+// len returns the number of items in the ring
+func (r *ring[T]) len() int {
+	return r.count
+}
+
+// at returns a pointer to the i-th item, 0 being the oldest
+func (r *ring[T]) at(i int) *T {
+	return &r.items[(r.head+i)%len(r.items)]
+}
+
+// push appends an item, overwriting the oldest one when the ring is full
+func (r *ring[T]) push(v T) {
+	if r.count == len(r.items) {
+		r.items[r.head] = v
+		r.head = (r.head + 1) % len(r.items)
+		return
+	}
+	r.items[(r.head+r.count)%len(r.items)] = v
+	r.count++
+}
+
+// dropOldest removes the n oldest items, clearing their slots so they can be collected
+func (r *ring[T]) dropOldest(n int) {
+	if n > r.count {
+		n = r.count
+	}
+	var zero T
+	for ; n > 0; n-- {
+		r.items[r.head] = zero
+		r.head = (r.head + 1) % len(r.items)
+		r.count--
+	}
+}
+
+// clear removes every item
+func (r *ring[T]) clear() {
+	r.dropOldest(r.count)
+	r.head = 0
+}
+
+// resize changes the capacity, keeping the newest items that fit
+func (r *ring[T]) resize(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if capacity == len(r.items) {
+		return
+	}
+	if r.count > capacity {
+		r.dropOldest(r.count - capacity)
+	}
+	items := make([]T, capacity)
+	for i := 0; i < r.count; i++ {
+		items[i] = *r.at(i)
+	}
+	r.items, r.head = items, 0
+}
@@ -0,0 +1,417 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// Event is a health or failure notification. It is also the data passed to webhook templates.
+type Event struct {
+	Type    string            `json:"type"`              // One of the config.NotifyEvent* constants
+	Subject string            `json:"subject"`           // Endpoint or group name, empty for global events
+	Message string            `json:"message"`           // Human-readable description
+	Time    time.Time         `json:"time"`              // When the event happened
+	Details map[string]string `json:"details,omitempty"` // Extra context, e.g. group or success rate
+}
+
+// Title returns a one-line summary used as the email subject
+func (e Event) Title() string {
+	if e.Subject == "" {
+		return fmt.Sprintf("[endpoint_forwarder] %s", e.Type)
+	}
+	return fmt.Sprintf("[endpoint_forwarder] %s: %s", e.Type, e.Subject)
+}
+
+// Publisher accepts events without blocking. A nil Publisher drops them.
+type Publisher func(Event)
+
+// Publish sends an event to p, filling in the time if it is missing
+func (p Publisher) Publish(event Event) {
+	if p == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	p(event)
+}
+
+// historySize is how many recent events are kept for /api/events/history and the UIs
+const historySize = 100
+
+// rateCheckInterval is how often the success rate triggers are evaluated
+const rateCheckInterval = 15 * time.Second
+
+// rateSample is a snapshot of the cumulative request counters
+type rateSample struct {
+	at     time.Time
+	total  int64
+	failed int64
+}
+
+// Dispatcher receives events on a channel and delivers those matching a trigger to the
+// trigger's sinks, with a per-trigger cooldown for repeats of the same event and subject.
+// Every event is also sent to notifications.webhook_url, and kept in a short history
+// whether or not notifications are enabled.
+type Dispatcher struct {
+	mutex    sync.RWMutex
+	cfg      config.NotificationsConfig
+	sinks    map[string]Sink
+	webhook  Sink                 // notifications.webhook_url, nil when unset
+	lastSent map[string]time.Time // Keyed by trigger index, event type and subject
+
+	historyMutex sync.Mutex
+	history      []Event // Oldest first, at most historySize
+
+	events chan Event
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	rateSource func() (total, failed int64) // Cumulative request counters for success_rate_low
+	samples    []rateSample
+	rateLow    bool // A success_rate_low alert is active until the rate recovers
+}
+
+// NewDispatcher creates a dispatcher for the notification settings
+func NewDispatcher(cfg config.NotificationsConfig) *Dispatcher {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	d := &Dispatcher{
+		lastSent: make(map[string]time.Time),
+		events:   make(chan Event, queueSize),
+		done:     make(chan struct{}),
+	}
+	d.UpdateConfig(cfg)
+	return d
+}
+
+// UpdateConfig replaces sinks and triggers. Cooldowns of unchanged triggers are kept.
+func (d *Dispatcher) UpdateConfig(cfg config.NotificationsConfig) {
+	sinks := make(map[string]Sink, len(cfg.Sinks))
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := newSink(sinkCfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ [通知] 通知渠道配置无效: %s - %v", sinkCfg.Name, err))
+			continue
+		}
+		sinks[sinkCfg.Name] = sink
+	}
+	var webhook Sink
+	if cfg.WebhookURL != "" {
+		sink, err := newSink(cfg.WebhookSink())
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ [通知] webhook_url 配置无效: %v", err))
+		} else {
+			webhook = sink
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.cfg = cfg
+	d.sinks = sinks
+	d.webhook = webhook
+}
+
+// SetSuccessRateSource sets the function returning cumulative total and failed request
+// counts, used to evaluate success_rate_low triggers
+func (d *Dispatcher) SetSuccessRateSource(source func() (total, failed int64)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.rateSource = source
+}
+
+// Publisher returns a Publisher that queues events on this dispatcher
+func (d *Dispatcher) Publisher() Publisher {
+	return d.Publish
+}
+
+// Publish records an event in the history and queues it for delivery without blocking;
+// events are dropped when the queue is full
+func (d *Dispatcher) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	d.record(event)
+
+	d.mutex.RLock()
+	active := d.cfg.Active()
+	d.mutex.RUnlock()
+	if !active {
+		return
+	}
+
+	select {
+	case d.events <- event:
+	default:
+		slog.Warn(fmt.Sprintf("⚠️ [通知] 事件队列已满，丢弃事件: %s %s", event.Type, event.Subject))
+	}
+}
+
+// record appends an event to the history, dropping the oldest beyond historySize
+func (d *Dispatcher) record(event Event) {
+	d.historyMutex.Lock()
+	defer d.historyMutex.Unlock()
+	if len(d.history) == historySize {
+		copy(d.history, d.history[1:])
+		d.history = d.history[:historySize-1]
+	}
+	d.history = append(d.history, event)
+}
+
+// History returns up to limit recent events, newest first; limit <= 0 returns all kept events
+func (d *Dispatcher) History(limit int) []Event {
+	d.historyMutex.Lock()
+	defer d.historyMutex.Unlock()
+	if limit <= 0 || limit > len(d.history) {
+		limit = len(d.history)
+	}
+	events := make([]Event, 0, limit)
+	for i := len(d.history) - 1; len(events) < limit; i-- {
+		events = append(events, d.history[i])
+	}
+	return events
+}
+
+// Start runs the dispatch loop in the background
+func (d *Dispatcher) Start() {
+	d.wg.Add(1)
+	go d.run()
+}
+
+// Stop ends the dispatch loop and waits for in-flight deliveries
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+// run handles queued events and periodically checks the success rate
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(rateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case event := <-d.events:
+			d.handle(event)
+		case now := <-ticker.C:
+			d.checkSuccessRate(now)
+		}
+	}
+}
+
+// handle delivers an event to the sinks of every trigger it matches, unless the trigger
+// already sent the same event for the same subject within its cooldown
+func (d *Dispatcher) handle(event Event) {
+	d.mutex.Lock()
+	type delivery struct {
+		sinks []Sink
+		names []string
+	}
+	var deliveries []delivery
+	for i, trigger := range d.cfg.Triggers {
+		if trigger.Event != event.Type {
+			continue
+		}
+		key := fmt.Sprintf("%d|%s|%s", i, event.Type, event.Subject)
+		if last, ok := d.lastSent[key]; ok && event.Time.Sub(last) < trigger.Cooldown {
+			slog.Debug(fmt.Sprintf("🔕 [通知] 冷却期内重复事件已忽略: %s %s (上次发送: %s)",
+				event.Type, event.Subject, last.Format("15:04:05")))
+			continue
+		}
+		d.lastSent[key] = event.Time
+
+		sinks, names := d.triggerSinks(trigger)
+		deliveries = append(deliveries, delivery{sinks: sinks, names: names})
+	}
+	if d.webhook != nil {
+		deliveries = append(deliveries, delivery{sinks: []Sink{d.webhook}, names: []string{d.cfg.WebhookSink().Name}})
+	}
+	retry := d.cfg.Retry
+	d.mutex.Unlock()
+
+	for _, del := range deliveries {
+		for i, sink := range del.sinks {
+			d.wg.Add(1)
+			go func(sink Sink, name string) {
+				defer d.wg.Done()
+				d.deliver(sink, name, event, retry)
+			}(sink, del.names[i])
+		}
+	}
+}
+
+// triggerSinks returns the sinks a trigger sends to (caller holds the lock)
+func (d *Dispatcher) triggerSinks(trigger config.NotifyTriggerConfig) ([]Sink, []string) {
+	var sinks []Sink
+	var names []string
+	if len(trigger.Sinks) == 0 {
+		for _, sinkCfg := range d.cfg.Sinks {
+			if sink, ok := d.sinks[sinkCfg.Name]; ok {
+				sinks = append(sinks, sink)
+				names = append(names, sinkCfg.Name)
+			}
+		}
+		return sinks, names
+	}
+	for _, name := range trigger.Sinks {
+		if sink, ok := d.sinks[name]; ok {
+			sinks = append(sinks, sink)
+			names = append(names, name)
+		}
+	}
+	return sinks, names
+}
+
+// deliver sends an event to one sink, retrying with exponential backoff
+func (d *Dispatcher) deliver(sink Sink, name string, event Event, retry config.NotifyRetryConfig) error {
+	attempts := retry.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	backoff := retry.Backoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = sink.Send(context.Background(), event); err == nil {
+			slog.Info(fmt.Sprintf("📣 [通知] 已发送通知: %s %s -> %s", event.Type, event.Subject, name))
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		slog.Warn(fmt.Sprintf("⚠️ [通知] 发送失败，%v 后重试 (%d/%d): %s -> %s - %v",
+			backoff, attempt, attempts, event.Type, name, err))
+		select {
+		case <-time.After(backoff):
+		case <-d.done:
+			return err
+		}
+		backoff *= 2
+	}
+	slog.Error(fmt.Sprintf("❌ [通知] 通知发送失败，已放弃: %s %s -> %s - %v", event.Type, event.Subject, name, err))
+	return err
+}
+
+// SendTest synchronously sends a test event to every configured sink, ignoring triggers and
+// cooldowns. Notifications do not need to be enabled.
+func (d *Dispatcher) SendTest(message string) error {
+	d.mutex.RLock()
+	cfg := d.cfg
+	sinks := d.sinks
+	webhook := d.webhook
+	d.mutex.RUnlock()
+
+	sinkConfigs := cfg.Sinks
+	if webhook != nil {
+		sinkConfigs = append(append([]config.NotifySinkConfig(nil), cfg.Sinks...), cfg.WebhookSink())
+		withWebhook := map[string]Sink{cfg.WebhookSink().Name: webhook}
+		for name, sink := range sinks {
+			withWebhook[name] = sink
+		}
+		sinks = withWebhook
+	}
+	if len(sinkConfigs) == 0 {
+		return errors.New("no notification sinks configured")
+	}
+
+	event := Event{Type: config.NotifyEventTest, Message: message, Time: time.Now()}
+	var errs []error
+	for _, sinkCfg := range sinkConfigs {
+		sink, ok := sinks[sinkCfg.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: invalid sink configuration", sinkCfg.Name))
+			continue
+		}
+		if err := d.deliver(sink, sinkCfg.Name, event, cfg.Retry); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sinkCfg.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkSuccessRate samples the request counters and raises success_rate_low when the
+// success rate over a trigger's window drops below its threshold
+func (d *Dispatcher) checkSuccessRate(now time.Time) {
+	d.mutex.Lock()
+	if d.rateSource == nil || !d.cfg.Enabled {
+		d.mutex.Unlock()
+		return
+	}
+	total, failed := d.rateSource()
+	d.samples = append(d.samples, rateSample{at: now, total: total, failed: failed})
+
+	// Keep samples for the longest configured window
+	var maxWindow time.Duration
+	var triggers []config.NotifyTriggerConfig
+	for _, trigger := range d.cfg.Triggers {
+		if trigger.Event == config.NotifyEventSuccessRateLow {
+			triggers = append(triggers, trigger)
+			if trigger.Window > maxWindow {
+				maxWindow = trigger.Window
+			}
+		}
+	}
+	cutoff := 0
+	for cutoff < len(d.samples)-1 && now.Sub(d.samples[cutoff+1].at) >= maxWindow {
+		cutoff++
+	}
+	d.samples = d.samples[cutoff:]
+
+	var events []Event
+	low := false
+	for _, trigger := range triggers {
+		// Oldest sample inside the window
+		base := d.samples[len(d.samples)-1]
+		for _, sample := range d.samples {
+			if now.Sub(sample.at) <= trigger.Window {
+				base = sample
+				break
+			}
+		}
+		requests := total - base.total
+		if requests < int64(trigger.MinRequests) || requests <= 0 {
+			continue
+		}
+		rate := float64(requests-(failed-base.failed)) / float64(requests) * 100
+		if rate >= trigger.Threshold {
+			continue
+		}
+		low = true
+		if !d.rateLow {
+			events = append(events, Event{
+				Type:    config.NotifyEventSuccessRateLow,
+				Message: fmt.Sprintf("Success rate %.1f%% over the last %v is below %.1f%% (%d requests)", rate, trigger.Window, trigger.Threshold, requests),
+				Time:    now,
+				Details: map[string]string{
+					"success_rate": fmt.Sprintf("%.1f", rate),
+					"threshold":    fmt.Sprintf("%.1f", trigger.Threshold),
+					"window":       trigger.Window.String(),
+					"requests":     fmt.Sprintf("%d", requests),
+				},
+			})
+		}
+	}
+	if d.rateLow && !low {
+		slog.Info("✅ [通知] 成功率已恢复到阈值以上")
+	}
+	d.rateLow = low
+	d.mutex.Unlock()
+
+	for _, event := range events {
+		slog.Warn(fmt.Sprintf("📉 [通知] %s", event.Message))
+		d.handle(event)
+	}
+}
@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// webhookReceiver records the bodies posted to it. The first failures requests get a 500.
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   []string
+	headers  []http.Header
+	failures int
+}
+
+func newWebhookReceiver(t *testing.T, failures int) *webhookReceiver {
+	t.Helper()
+	recv := &webhookReceiver{failures: failures}
+	recv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		recv.mu.Lock()
+		defer recv.mu.Unlock()
+		if recv.failures > 0 {
+			recv.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		recv.bodies = append(recv.bodies, string(body))
+		recv.headers = append(recv.headers, r.Header.Clone())
+	}))
+	t.Cleanup(recv.Close)
+	return recv
+}
+
+func (r *webhookReceiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+// waitFor waits until the receiver has at least n bodies
+func (r *webhookReceiver) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if bodies := r.received(); len(bodies) >= n {
+			return bodies
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d webhook deliveries, got %d", n, len(r.received()))
+	return nil
+}
+
+func webhookConfig(url string, triggers ...config.NotifyTriggerConfig) config.NotificationsConfig {
+	return config.NotificationsConfig{
+		Enabled:   true,
+		QueueSize: 10,
+		Retry:     config.NotifyRetryConfig{MaxAttempts: 3, Backoff: time.Millisecond},
+		Sinks: []config.NotifySinkConfig{
+			{Name: "hook", Type: config.NotifySinkWebhook, URL: url, Timeout: time.Second, Headers: map[string]string{"X-Team": "ops"}},
+		},
+		Triggers: triggers,
+	}
+}
+
+func TestWebhookPayloadAndDedup(t *testing.T) {
+	recv := newWebhookReceiver(t, 0)
+	d := NewDispatcher(webhookConfig(recv.URL,
+		config.NotifyTriggerConfig{Event: config.NotifyEventEndpointUnhealthy, Cooldown: time.Hour}))
+	d.Start()
+	defer d.Stop()
+
+	publish := d.Publisher()
+	publish.Publish(Event{Type: config.NotifyEventEndpointUnhealthy, Subject: "primary", Message: "primary is down",
+		Details: map[string]string{"group": "main"}})
+	bodies := recv.waitFor(t, 1)
+
+	var got Event
+	if err := json.Unmarshal([]byte(bodies[0]), &got); err != nil {
+		t.Fatalf("Expected a JSON event payload, got %q: %v", bodies[0], err)
+	}
+	if got.Type != config.NotifyEventEndpointUnhealthy || got.Subject != "primary" || got.Message != "primary is down" ||
+		got.Details["group"] != "main" || got.Time.IsZero() {
+		t.Errorf("Unexpected payload: %+v", got)
+	}
+	if recv.headers[0].Get("X-Team") != "ops" || recv.headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("Expected configured headers on the webhook request, got %v", recv.headers[0])
+	}
+
+	// Repeats within the cooldown are dropped; other subjects and untriggered events are not affected
+	publish.Publish(Event{Type: config.NotifyEventEndpointUnhealthy, Subject: "primary", Message: "primary is down again"})
+	publish.Publish(Event{Type: config.NotifyEventEndpointHealthy, Subject: "primary", Message: "no trigger for this"})
+	publish.Publish(Event{Type: config.NotifyEventEndpointUnhealthy, Subject: "backup", Message: "backup is down"})
+	bodies = recv.waitFor(t, 2)
+	time.Sleep(100 * time.Millisecond)
+	if bodies = recv.received(); len(bodies) != 2 || !strings.Contains(bodies[1], "backup is down") {
+		t.Errorf("Expected only the backup event after the first one, got %v", bodies)
+	}
+}
+
+func TestWebhookTemplateAndRetry(t *testing.T) {
+	recv := newWebhookReceiver(t, 2)
+	cfg := webhookConfig(recv.URL)
+	cfg.Sinks[0].Template = `{"text": {{json .Message}}, "kind": "{{.Type}}"}`
+	d := NewDispatcher(cfg)
+
+	// Two failures are retried within three attempts
+	if err := d.SendTest(`quoted "message"`); err != nil {
+		t.Fatalf("Expected the test notification to succeed after retries, got %v", err)
+	}
+	bodies := recv.received()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one delivered body, got %v", bodies)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatalf("Expected the template to render valid JSON, got %q: %v", bodies[0], err)
+	}
+	if payload["text"] != `quoted "message"` || payload["kind"] != config.NotifyEventTest {
+		t.Errorf("Unexpected rendered payload: %v", payload)
+	}
+
+	// Giving up after max_attempts reports the error
+	recv.mu.Lock()
+	recv.failures = 3
+	recv.mu.Unlock()
+	if err := d.SendTest("fails"); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("Expected the test notification to fail with the webhook status, got %v", err)
+	}
+}
+
+func TestSuccessRateTrigger(t *testing.T) {
+	recv := newWebhookReceiver(t, 0)
+	d := NewDispatcher(webhookConfig(recv.URL, config.NotifyTriggerConfig{
+		Event: config.NotifyEventSuccessRateLow, Cooldown: time.Millisecond, Threshold: 90, Window: time.Minute, MinRequests: 10,
+	}))
+	var total, failed int64
+	d.SetSuccessRateSource(func() (int64, int64) { return total, failed })
+
+	start := time.Now()
+	d.checkSuccessRate(start)
+
+	// 5 failures out of 8 requests: not enough requests yet
+	total, failed = 8, 5
+	d.checkSuccessRate(start.Add(10 * time.Second))
+	// 6 failures out of 20: 70% is below the threshold
+	total, failed = 20, 6
+	d.checkSuccessRate(start.Add(20 * time.Second))
+	bodies := recv.waitFor(t, 1)
+	if !strings.Contains(bodies[0], config.NotifyEventSuccessRateLow) || !strings.Contains(bodies[0], `"success_rate":"70.0"`) {
+		t.Errorf("Expected a success_rate_low event at 70%%, got %s", bodies[0])
+	}
+
+	// Still low: no repeat until the rate has recovered
+	total, failed = 30, 12
+	d.checkSuccessRate(start.Add(30 * time.Second))
+	// Failures older than the window no longer count
+	total, failed = 130, 12
+	d.checkSuccessRate(start.Add(90 * time.Second))
+	total, failed = 150, 22
+	d.checkSuccessRate(start.Add(100 * time.Second))
+	d.wg.Wait()
+	if bodies := recv.received(); len(bodies) != 2 {
+		t.Errorf("Expected a second alert only after recovery, got %d: %v", len(bodies), bodies)
+	}
+}
+
+func TestDisabledDispatcherDropsEvents(t *testing.T) {
+	recv := newWebhookReceiver(t, 0)
+	cfg := webhookConfig(recv.URL, config.NotifyTriggerConfig{Event: config.NotifyEventConfigReloadFailed, Cooldown: time.Hour})
+	cfg.Enabled = false
+	d := NewDispatcher(cfg)
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(Event{Type: config.NotifyEventConfigReloadFailed, Message: "bad yaml"})
+	time.Sleep(100 * time.Millisecond)
+	if bodies := recv.received(); len(bodies) != 0 {
+		t.Errorf("Expected no notifications while disabled, got %v", bodies)
+	}
+
+	// Test notifications still work, so sinks can be checked before enabling
+	if err := d.SendTest("hello"); err != nil {
+		t.Errorf("Expected the test notification to be sent, got %v", err)
+	}
+}
+
+func TestSMTPSink(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg string
+	sink := &smtpSink{
+		cfg: config.NotifySinkConfig{Name: "mail", Type: config.NotifySinkSMTP, SMTP: config.NotifySMTPConfig{
+			Host: "smtp.example.com", Port: 587, From: "forwarder@example.com", To: []string{"ops@example.com", "oncall@example.com"},
+		}},
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, string(msg)
+			return nil
+		},
+	}
+
+	err := sink.Send(context.Background(), Event{Type: config.NotifyEventGroupCooldownEnter, Subject: "main",
+		Message: "Group main entered cooldown", Time: time.Now(), Details: map[string]string{"cooldown": "1m0s"}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "forwarder@example.com" || len(gotTo) != 2 {
+		t.Errorf("Unexpected envelope: %s %s %v", gotAddr, gotFrom, gotTo)
+	}
+	for _, want := range []string{"Subject: [endpoint_forwarder] group_cooldown_entered: main", "Group main entered cooldown", "cooldown: 1m0s"} {
+		if !strings.Contains(gotMsg, want) {
+			t.Errorf("Expected %q in the message:\n%s", want, gotMsg)
+		}
+	}
+}
+
+func TestWebhookURLReceivesEveryEvent(t *testing.T) {
+	recv := newWebhookReceiver(t, 1)
+	d := NewDispatcher(config.NotificationsConfig{
+		QueueSize:       10,
+		Retry:           config.NotifyRetryConfig{MaxAttempts: 2, Backoff: time.Millisecond},
+		WebhookURL:      recv.URL,
+		WebhookTemplate: `{"text": {{json .Message}}, "kind": {{json .Type}}}`,
+	})
+	d.Start()
+	defer d.Stop()
+
+	// webhook_url alone enables delivery, without triggers; the first attempt fails and is retried
+	d.Publish(Event{Type: config.NotifyEventAllEndpointsDown, Subject: "main", Message: "All 2 endpoints of group main are unhealthy"})
+	d.Publish(Event{Type: config.NotifyEventConfigSwitched, Subject: "prod", Message: "switched"})
+	bodies := recv.waitFor(t, 2)
+	var kinds []string
+	for _, body := range bodies {
+		var payload map[string]string
+		if err := json.Unmarshal([]byte(body), &payload); err != nil {
+			t.Fatalf("Expected the template to render JSON, got %q: %v", body, err)
+		}
+		kinds = append(kinds, payload["kind"])
+	}
+	if !slices.Contains(kinds, config.NotifyEventAllEndpointsDown) || !slices.Contains(kinds, config.NotifyEventConfigSwitched) {
+		t.Errorf("Expected both events to be posted, got %v", kinds)
+	}
+}
+
+func TestEventHistory(t *testing.T) {
+	// Events are kept while notifications are disabled
+	d := NewDispatcher(config.NotificationsConfig{})
+	for i := 0; i < historySize+5; i++ {
+		d.Publish(Event{Type: config.NotifyEventEndpointUnhealthy, Subject: fmt.Sprintf("ep-%d", i)})
+	}
+
+	all := d.History(0)
+	if len(all) != historySize {
+		t.Fatalf("Expected the history to keep %d events, got %d", historySize, len(all))
+	}
+	if all[0].Subject != fmt.Sprintf("ep-%d", historySize+4) || all[len(all)-1].Subject != "ep-5" {
+		t.Errorf("Expected the newest events first, got %s ... %s", all[0].Subject, all[len(all)-1].Subject)
+	}
+	if recent := d.History(3); len(recent) != 3 || recent[0].Time.IsZero() {
+		t.Errorf("Expected 3 timestamped events, got %+v", recent)
+	}
+}
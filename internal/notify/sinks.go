@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// Sink delivers an event to an external system
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// newSink creates the sink for a sink configuration
+func newSink(cfg config.NotifySinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case config.NotifySinkWebhook:
+		return newWebhookSink(cfg)
+	case config.NotifySinkSMTP:
+		return &smtpSink{cfg: cfg, sendMail: smtp.SendMail}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+}
+
+// webhookSink POSTs the event to a URL, as JSON or rendered through a template
+type webhookSink struct {
+	cfg      config.NotifySinkConfig
+	template *template.Template
+	client   *http.Client
+}
+
+func newWebhookSink(cfg config.NotifySinkConfig) (*webhookSink, error) {
+	sink := &webhookSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.New(cfg.Name).Funcs(config.NotifyTemplateFuncs).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		sink.template = tmpl
+	}
+	return sink, nil
+}
+
+// Send posts the event and treats any non-2xx response as a failure
+func (s *webhookSink) Send(ctx context.Context, event Event) error {
+	var body bytes.Buffer
+	if s.template != nil {
+		if err := s.template.Execute(&body, event); err != nil {
+			return fmt.Errorf("failed to render template: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(event); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "endpoint-forwarder-notify")
+	for key, value := range s.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// smtpSink sends the event as a plain text email
+type smtpSink struct {
+	cfg      config.NotifySinkConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Send mails the event to the configured recipients
+func (s *smtpSink) Send(ctx context.Context, event Event) error {
+	smtpCfg := s.cfg.SMTP
+	var auth smtp.Auth
+	if smtpCfg.Username != "" {
+		auth = smtp.PlainAuth("", smtpCfg.Username, smtpCfg.Password, smtpCfg.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", smtpCfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(smtpCfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", event.Title())
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", event.Message)
+	fmt.Fprintf(&msg, "Time: %s\r\n", event.Time.Format("2006-01-02 15:04:05 MST"))
+	keys := make([]string, 0, len(event.Details))
+	for key := range event.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&msg, "%s: %s\r\n", key, event.Details[key])
+	}
+
+	addr := net.JoinHostPort(smtpCfg.Host, strconv.Itoa(smtpCfg.Port))
+	done := make(chan error, 1)
+	go func() {
+		done <- s.sendMail(addr, auth, smtpCfg.From, smtpCfg.To, []byte(msg.String()))
+	}()
+
+	// net/smtp has no timeout of its own
+	timeout := s.cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("smtp send timed out after %v", timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"endpoint_forwarder/internal/monitor"
+)
+
+// Attempt outcomes recorded in a connection's attempt timeline
+const (
+	outcomeSuccess     = "success"
+	outcomeHTTPError   = "http-error"
+	outcomeRateLimited = "rate-limited"
+	outcomeTimeout     = "timeout"
+	outcomeConnRefused = "conn-refused"
+	outcomeConnReset   = "conn-reset"
+	outcomeDNSError    = "dns-error"
+	outcomeInterrupted = "stream-interrupted"
+	outcomeCancelled   = "cancelled"
+	outcomeError       = "error"
+)
+
+// attemptOutcome classifies the result of an upstream attempt from its status code
+// (0 when no response was received) or error
+func attemptOutcome(statusCode int, err error) string {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		statusCode = statusErr.StatusCode
+	}
+	if statusCode != 0 {
+		switch {
+		case statusCode == http.StatusTooManyRequests:
+			return outcomeRateLimited
+		case statusCode >= 400:
+			return outcomeHTTPError
+		default:
+			return outcomeSuccess
+		}
+	}
+	if err == nil {
+		return outcomeSuccess
+	}
+
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var interruptedErr *streamInterruptedError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrClientCancelled):
+		return outcomeCancelled
+	case errors.As(err, &interruptedErr):
+		return outcomeInterrupted
+	case errors.As(err, &dnsErr):
+		return outcomeDNSError
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return outcomeTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return outcomeConnRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return outcomeConnReset
+	}
+	return outcomeError
+}
+
+// recordAttempt adds an upstream attempt to the connection's timeline, bounded to
+// monitor.MaxConnectionAttempts entries, and reports its outcome to the endpoint manager for passive
+// health checks (unless the client cancelled it)
+func (rh *RetryHandler) recordAttempt(connID, endpointName string, start time.Time, statusCode int, err error, streaming bool) {
+	if statusCode == 0 {
+		var statusErr *upstreamStatusError
+		if errors.As(err, &statusErr) {
+			statusCode, err = statusErr.StatusCode, nil
+		}
+	}
+	outcome := attemptOutcome(statusCode, err)
+	if rh.endpointManager != nil && outcome != outcomeCancelled {
+		rh.endpointManager.RecordRequestOutcome(endpointName, statusCode, err)
+	}
+	// Non-streaming attempts end when the response headers arrive: a latency sample for the fastest strategy
+	if rh.endpointManager != nil && outcome == outcomeSuccess && !streaming {
+		rh.endpointManager.RecordLatency(endpointName, time.Since(start))
+	}
+
+	if connID == "" || rh.monitoringMiddleware == nil {
+		return
+	}
+	mm, ok := rh.monitoringMiddleware.(interface {
+		RecordAttempt(connID string, attempt monitor.AttemptInfo, limit int)
+	})
+	if !ok {
+		return
+	}
+	mm.RecordAttempt(connID, monitor.AttemptInfo{
+		Endpoint:   endpointName,
+		StartTime:  start,
+		EndTime:    time.Now(),
+		Outcome:    outcome,
+		StatusCode: statusCode,
+		Streaming:  streaming,
+	}, monitor.MaxConnectionAttempts)
+}
+
+// recordBackoff records the wait before the next attempt on the connection's latest attempt
+func (rh *RetryHandler) recordBackoff(connID string, backoff time.Duration) {
+	if connID == "" || rh.monitoringMiddleware == nil {
+		return
+	}
+	if mm, ok := rh.monitoringMiddleware.(interface {
+		RecordAttemptBackoff(connID string, backoff time.Duration)
+	}); ok {
+		mm.RecordAttemptBackoff(connID, backoff)
+	}
+}
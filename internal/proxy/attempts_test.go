@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+)
+
+func TestAttemptOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		expected string
+	}{
+		{"success", 200, nil, outcomeSuccess},
+		{"client error", 401, nil, outcomeHTTPError},
+		{"server error", 503, nil, outcomeHTTPError},
+		{"rate limited", 429, nil, outcomeRateLimited},
+		{"streaming status", 0, &upstreamStatusError{StatusCode: 429}, outcomeRateLimited},
+		{"deadline", 0, fmt.Errorf("request failed: %w", context.DeadlineExceeded), outcomeTimeout},
+		{"cancelled", 0, context.Canceled, outcomeCancelled},
+		{"refused", 0, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, outcomeConnRefused},
+		{"reset", 0, &net.OpError{Op: "read", Err: syscall.ECONNRESET}, outcomeConnReset},
+		{"dns", 0, &net.DNSError{Err: "no such host", Name: "example.invalid"}, outcomeDNSError},
+		{"stream interrupted", 0, &streamInterruptedError{io.ErrUnexpectedEOF}, outcomeInterrupted},
+		{"stream cancelled", 0, &streamInterruptedError{context.Canceled}, outcomeCancelled},
+		{"other", 0, errors.New("boom"), outcomeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attemptOutcome(tt.status, tt.err); got != tt.expected {
+				t.Errorf("attemptOutcome(%d, %v) = %q, expected %q", tt.status, tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryHandlerRecordsAttempts(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ok.Close()
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "failing", URL: failing.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "ok", URL: ok.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		},
+	}
+
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(manager)
+	rh := NewRetryHandler(cfg)
+	rh.SetEndpointManager(manager)
+	rh.SetMonitoringMiddleware(mm)
+
+	connID := mm.GetMetrics().RecordRequest("unknown", "", "127.0.0.1", "test", "POST", "/v1/messages")
+	operation := func(ep *endpoint.Endpoint, connID string) (*http.Response, error) {
+		return http.Get(ep.Config.URL)
+	}
+	resp, err := rh.Execute(operation, connID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	conn, found := mm.GetMetrics().GetConnection(connID)
+	if !found {
+		t.Fatal("Expected the connection to be tracked")
+	}
+	// Two attempts on the failing endpoint, then the failover
+	if len(conn.Attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %+v", conn.Attempts)
+	}
+	if a := conn.Attempts[0]; a.Endpoint != "failing" || a.Outcome != outcomeHTTPError || a.StatusCode != http.StatusBadGateway || a.Streaming {
+		t.Errorf("Unexpected first attempt: %+v", a)
+	}
+	if a, b := conn.Attempts[0], conn.Attempts[1]; a.Backoff != time.Millisecond || b.Backoff != 0 {
+		t.Errorf("Expected the retry delay after the first attempt only, got %v and %v", a.Backoff, b.Backoff)
+	}
+	if a := conn.Attempts[2]; a.Endpoint != "ok" || a.Outcome != outcomeSuccess || a.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected last attempt: %+v", a)
+	}
+	if a := conn.Attempts[2]; a.EndTime.Before(a.StartTime) {
+		t.Errorf("Expected the attempt to end after it started: %+v", a)
+	}
+	if reasons := monitor.RetryReasons(conn.Attempts); reasons != "5xx:2" {
+		t.Errorf("Expected retry reasons 5xx:2, got %q", reasons)
+	}
+}
+
+func TestRetryReasonsAcrossFailover(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	overloaded := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer overloaded.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ok.Close()
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "refused", URL: refused.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "overloaded", URL: overloaded.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "ok", URL: ok.URL, Priority: 3, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		},
+	}
+
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(manager)
+	rh := NewRetryHandler(cfg)
+	rh.SetEndpointManager(manager)
+	rh.SetMonitoringMiddleware(mm)
+
+	connID := mm.GetMetrics().RecordRequest("unknown", "", "127.0.0.1", "test", "POST", "/v1/messages")
+	resp, err := rh.Execute(func(ep *endpoint.Endpoint, connID string) (*http.Response, error) {
+		return http.Get(ep.Config.URL)
+	}, connID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	// Both failed endpoints stay in the timeline even though max_attempts is 1
+	conn, _ := mm.GetMetrics().GetConnection(connID)
+	if len(conn.Attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %+v", conn.Attempts)
+	}
+	if a := conn.Attempts[0]; a.Endpoint != "refused" || a.FailureClass() != "connect" {
+		t.Errorf("Unexpected first attempt: %+v", a)
+	}
+	if a := conn.Attempts[1]; a.Endpoint != "overloaded" || a.FailureClass() != "5xx" || a.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected second attempt: %+v", a)
+	}
+	if reasons := monitor.RetryReasons(conn.Attempts); reasons != "connect:1 5xx:1" {
+		t.Errorf("Expected retry reasons connect:1 5xx:1, got %q", reasons)
+	}
+}
+
+func TestStreamingAttemptsRecorded(t *testing.T) {
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		writeInPieces(w, passthroughTestStream, 64)
+	}, nil)
+
+	connID := mm.GetMetrics().RecordRequest("unknown", "test", "127.0.0.1", "test", "POST", "/v1/messages")
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{"model":"claude-3-5-haiku","stream":true}`))
+	req.Header.Set("Accept", "text/event-stream")
+	req = req.WithContext(context.WithValue(req.Context(), "conn_id", connID))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	conn, _ := mm.GetMetrics().GetConnection(connID)
+	if conn == nil || len(conn.Attempts) != 1 {
+		t.Fatalf("Expected one streaming attempt, got %+v", conn)
+	}
+	if a := conn.Attempts[0]; a.Endpoint != "primary" || a.Outcome != outcomeSuccess || !a.Streaming {
+		t.Errorf("Unexpected streaming attempt: %+v", a)
+	}
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// bodyStreamedContextKey marks requests whose body is streamed to the upstream instead of
+// buffered (server.on_large_body: stream); such a body can only be sent once
+const bodyStreamedContextKey = contextKey("body_streamed")
+
+// bodyStreamed reports whether the request body is streamed and cannot be replayed
+func bodyStreamed(ctx context.Context) bool {
+	streamed, _ := ctx.Value(bodyStreamedContextKey).(bool)
+	return streamed
+}
+
+// readBody reads the request body, stopping once it exceeds limit bytes (0 = unlimited).
+// For an oversized body it returns the part read so far, with the rest left in r.Body; a
+// Content-Length over the limit is caught before anything is read.
+func readBody(r *http.Request, limit int64) (body []byte, oversized bool, err error) {
+	if limit <= 0 {
+		body, err = io.ReadAll(r.Body)
+		return body, false, err
+	}
+	if r.ContentLength > limit {
+		return nil, true, nil
+	}
+	body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+	return body, int64(len(body)) > limit, err
+}
+
+// readRequestBody reads the client's body so it can be replayed on retries. A body larger
+// than server.max_request_body_size is rejected with 413, or with on_large_body: stream left
+// unread in r.Body and the request marked with bodyStreamedContextKey. A body within that
+// limit but larger than retry.max_buffered_body_size is streamed the same way. ok is false
+// when an error response was written.
+func (h *Handler) readRequestBody(w http.ResponseWriter, r *http.Request) (body []byte, ok bool) {
+	if r.Body == nil {
+		return nil, true
+	}
+
+	serverLimit := h.config.Server.MaxRequestBodyBytes()
+	limit := serverLimit
+	bufferLimit := h.config.Retry.MaxBufferedBodyBytes()
+	retryLimited := bufferLimit > 0 && (serverLimit == 0 || bufferLimit < serverLimit)
+	if retryLimited {
+		limit = bufferLimit
+	}
+
+	body, oversized, err := readBody(r, limit)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.TypeInvalidRequest, "Failed to read request body")
+		return nil, false
+	}
+	if !oversized {
+		r.Body.Close()
+		return body, true
+	}
+
+	overServerLimit := !retryLimited || serverLimit > 0 && r.ContentLength > serverLimit
+	if overServerLimit && h.config.Server.OnLargeBody != config.OnLargeBodyStream {
+		h.rejectLargeBody(w, r)
+		return nil, false
+	}
+
+	// Put back what was read and send the rest as it arrives
+	rest := r.Body
+	if overServerLimit {
+		slog.InfoContext(r.Context(), fmt.Sprintf("📦 [请求体限制] 请求体超过 %s，直接流式转发（不缓存、不重试）: %s %s",
+			h.config.Server.MaxRequestBodySize, r.Method, r.URL.Path))
+	} else {
+		slog.InfoContext(r.Context(), fmt.Sprintf("📦 [请求体缓存] 请求体超过 retry.max_buffered_body_size (%s)，直接流式转发，本次请求不重试、不切换端点: %s %s",
+			h.config.Retry.MaxBufferedBodySize, r.Method, r.URL.Path))
+		if serverLimit > 0 && h.config.Server.OnLargeBody != config.OnLargeBodyStream {
+			// Without a Content-Length the server limit can only be enforced while streaming
+			rest = http.MaxBytesReader(w, r.Body, serverLimit-int64(len(body)))
+		}
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), rest), r.Body}
+	*r = *r.WithContext(context.WithValue(r.Context(), bodyStreamedContextKey, true))
+	return nil, true
+}
+
+// rejectLargeBody responds with 413 to a body over server.max_request_body_size and records
+// the rejection in the monitoring metrics
+func (h *Handler) rejectLargeBody(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	slog.WarnContext(ctx, fmt.Sprintf("📦 [请求体限制] 请求体超过 %s，拒绝请求: %s %s (Content-Length: %d)",
+		h.config.Server.MaxRequestBodySize, r.Method, r.URL.Path, r.ContentLength))
+
+	connID, _ := ctx.Value("conn_id").(string)
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+		RecordRejected(connID string, reason string)
+	}); ok && connID != "" {
+		mm.RecordRejected(connID, monitor.RejectBodyTooLarge)
+	}
+
+	// The rest of the body is not read; close the connection instead of draining it
+	w.Header().Set("Connection", "close")
+	apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TypeRequestTooLarge,
+		fmt.Sprintf("Request body exceeds the limit of %s", h.config.Server.MaxRequestBodySize))
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// newBodyRecordingUpstream counts requests and records the size of the last body received
+func newBodyRecordingUpstream(t *testing.T, status int) (*httptest.Server, *atomic.Int64, *atomic.Int64) {
+	t.Helper()
+
+	var hits, size atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		n, _ := io.Copy(io.Discard, r.Body)
+		size.Store(n)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits, &size
+}
+
+func TestLargeBodyRejected(t *testing.T) {
+	upstream, hits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1})
+	handler.config.Server.MaxRequestBodySize = "10MB"
+	handler.config.Server.OnLargeBody = config.OnLargeBodyReject
+
+	server, mm := newAbortTestServer(manager, handler, handler)
+	defer server.Close()
+
+	// A 20MB body against a 10MB limit
+	resp, err := http.Post(server.URL+"/v1/messages", "application/json", bytes.NewReader(make([]byte, 20<<20)))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || !strings.Contains(string(body), apierror.TypeRequestTooLarge) {
+		t.Fatalf("Expected 413 %s, got %d: %s", apierror.TypeRequestTooLarge, resp.StatusCode, body)
+	}
+
+	// Without a Content-Length the limit is found while reading
+	req, _ := http.NewRequest("POST", server.URL+"/v1/messages", strings.NewReader(strings.Repeat("x", 11<<20)))
+	req.ContentLength = -1
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Chunked request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a chunked body, got %d", resp.StatusCode)
+	}
+
+	// Bodies within the limit are forwarded as before
+	resp, err = http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(`{"model":"claude"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits.Load() != 1 {
+		t.Errorf("Expected the small request to be forwarded, got %d with %d upstream hits", resp.StatusCode, hits.Load())
+	}
+
+	metrics := mm.GetMetrics().GetMetrics()
+	if metrics.RejectedRequests[monitor.RejectBodyTooLarge] != 2 || metrics.FailedRequests != 2 {
+		t.Errorf("Expected 2 rejected and failed requests, got %d rejected and %d failed",
+			metrics.RejectedRequests[monitor.RejectBodyTooLarge], metrics.FailedRequests)
+	}
+	for _, conn := range mm.GetMetrics().RecentConnections(0) {
+		if conn.Status == "failed" && conn.RejectReason != monitor.RejectBodyTooLarge {
+			t.Errorf("Expected the failed connection to carry the reject reason, got %q", conn.RejectReason)
+		}
+	}
+}
+
+func TestLargeBodyStreamed(t *testing.T) {
+	// The first endpoint fails: a streamed body must not be retried or sent to the backup
+	failing, failingHits, failingSize := newBodyRecordingUpstream(t, http.StatusInternalServerError)
+	backup, backupHits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, _ := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "failing", URL: failing.URL, Priority: 1},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	handler.config.Server.MaxRequestBodySize = "1KB"
+	handler.config.Server.OnLargeBody = config.OnLargeBodyStream
+	handler.config.Retry.MaxAttempts = 3
+
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(strings.Repeat("x", 64<<10)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if failingSize.Load() != 64<<10 {
+		t.Errorf("Expected the whole 64KB body upstream, got %d bytes", failingSize.Load())
+	}
+	if failingHits.Load() != 1 || backupHits.Load() != 0 {
+		t.Errorf("Expected exactly one upstream attempt, got failing=%d backup=%d", failingHits.Load(), backupHits.Load())
+	}
+
+	// Small bodies keep retries and failover
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusOK || backupHits.Load() != 1 {
+		t.Errorf("Expected the small request to fail over to the backup, got %d with %d backup hits", rec.Code, backupHits.Load())
+	}
+}
+
+func TestRetryBufferLimitStreamsLargeBodies(t *testing.T) {
+	// The first endpoint fails and records how the body was framed
+	var contentLength atomic.Int64
+	var chunked atomic.Bool
+	failing, failingHits, failingSize := newBodyRecordingUpstream(t, http.StatusInternalServerError)
+	framing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength.Store(r.ContentLength)
+		chunked.Store(len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked")
+		failing.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer framing.Close()
+	backup, backupHits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, _ := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "failing", URL: framing.URL, Priority: 1},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	handler.config.Server.MaxRequestBodySize = "1MB"
+	handler.config.Server.OnLargeBody = config.OnLargeBodyReject
+	handler.config.Retry.MaxBufferedBodySize = "1KB"
+	handler.config.Retry.MaxAttempts = 3
+
+	// Above the buffer limit but within the server limit: streamed once with its Content-Length
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(make([]byte, 64<<10))))
+	if failingSize.Load() != 64<<10 || contentLength.Load() != 64<<10 {
+		t.Errorf("Expected the 64KB body upstream with its Content-Length, got %d bytes and Content-Length %d",
+			failingSize.Load(), contentLength.Load())
+	}
+	if failingHits.Load() != 1 || backupHits.Load() != 0 {
+		t.Errorf("Expected exactly one upstream attempt, got failing=%d backup=%d", failingHits.Load(), backupHits.Load())
+	}
+
+	// Without a Content-Length the body is sent chunked
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(strings.Repeat("x", 64<<10)))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if failingSize.Load() != 64<<10 || !chunked.Load() {
+		t.Errorf("Expected the 64KB body upstream chunked, got %d bytes (chunked=%v)", failingSize.Load(), chunked.Load())
+	}
+
+	// The server limit still rejects larger bodies
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(make([]byte, 2<<20))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 above server.max_request_body_size, got %d", rec.Code)
+	}
+
+	// Small bodies are buffered and keep retries and failover
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusOK || backupHits.Load() != 1 {
+		t.Errorf("Expected the small request to fail over to the backup, got %d with %d backup hits", rec.Code, backupHits.Load())
+	}
+}
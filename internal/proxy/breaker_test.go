@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestCircuitBreakerSkipsFailingEndpoint(t *testing.T) {
+	failing, failingHits, _ := newBodyRecordingUpstream(t, http.StatusInternalServerError)
+	backup, backupHits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "failing", URL: failing.URL, Priority: 1},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	handler.config.CircuitBreaker = config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 2, OpenDuration: time.Minute, HalfOpenMaxRequests: 1}
+
+	for i := 0; i < 5; i++ {
+		if rec := sendRateLimitTestRequest(handler, i%2 == 1); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected the backup to answer, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The breaker opened after two failures, so later requests go straight to the backup
+	if failingHits.Load() != 2 || backupHits.Load() != 5 {
+		t.Errorf("Expected 2 requests to the failing endpoint and 5 to the backup, got %d and %d", failingHits.Load(), backupHits.Load())
+	}
+	if state, _, _ := manager.GetEndpointByNameAny("failing").BreakerInfo(); state != endpoint.BreakerOpen {
+		t.Errorf("Expected the breaker to be open, got %s", state)
+	}
+}
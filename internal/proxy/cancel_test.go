@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+func TestClientCancelStopsUpstreamWithoutBlame(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a closed connection once the body has been read
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(5 * time.Second):
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second})
+	cfg.Retry.MaxAttempts = 3
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+	monitoring := middleware.NewMonitoringMiddleware(manager)
+	handler.SetMonitoringMiddleware(monitoring)
+	logging := middleware.NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	logging.SetMonitoringMiddleware(monitoring)
+	server := logging.Wrap(handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test"}`)).WithContext(ctx)
+
+	start := time.Now()
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected the request to end when the client cancelled, took %s", elapsed)
+	}
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the upstream request to be cancelled")
+	}
+
+	metrics := monitoring.GetMetrics().GetMetrics()
+	recent := monitoring.GetMetrics().RecentlyCancelled(time.Now())
+	if metrics.CancelledRequests != 1 || len(recent) != 1 || recent[0].RetryCount != 0 {
+		t.Errorf("Expected one cancelled connection without retries, got %d cancelled, %+v", metrics.CancelledRequests, recent)
+	}
+	if metrics.FailedRequests != 0 {
+		t.Errorf("Expected the cancellation not to count as a failed request, got %d", metrics.FailedRequests)
+	}
+
+	status := manager.GetEndpointByName("primary").GetStatus()
+	if !status.Healthy || status.ConsecutiveFails != 0 {
+		t.Errorf("Expected the endpoint to stay healthy without failures, got healthy=%v fails=%d", status.Healthy, status.ConsecutiveFails)
+	}
+	if retries := manager.GetGroupManager().GetGroupRetryCount("main"); retries != 0 {
+		t.Errorf("Expected the group retry count to stay 0, got %d", retries)
+	}
+}
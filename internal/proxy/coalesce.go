@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalesceKeyHeaders are the client headers that can change the upstream response, so
+// requests differing in them are never coalesced. X-Forwarder-* overrides are added too.
+var coalesceKeyHeaders = []string{"Authorization", "X-Api-Key", "Anthropic-Version", "Anthropic-Beta", "Accept", "Accept-Encoding", "Last-Event-ID"}
+
+// coalescer lets identical requests share one upstream request (forwarding.coalesce_identical_requests).
+// The first request leads: it is forwarded as usual while its response is recorded. Identical
+// requests arriving before the leader sent its first byte follow: they are not forwarded and
+// replay the leader's response as it is written, streams included.
+type coalescer struct {
+	mu      sync.Mutex
+	pending map[string]*coalescedResponse // Leaders that have not written their first byte yet, by request key
+}
+
+// coalescedResponse is the response of a leading request, shared with its followers
+type coalescedResponse struct {
+	leaderID string    // Connection ID of the leading request
+	started  time.Time // When the leading request started
+
+	mu        sync.Mutex
+	changed   chan struct{} // Closed and replaced whenever the response progresses
+	followers int
+	header    http.Header // Headers as sent with the status line
+	status    int         // 0 until the leader wrote its status
+	body      []byte      // Everything written so far; not kept when nobody follows
+	done      bool        // The leader has finished
+}
+
+// coalesceKey identifies a request by everything that determines its upstream response
+func coalesceKey(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+	for _, name := range coalesceKeyHeaders {
+		fmt.Fprintf(hash, "%s: %q\n", name, r.Header.Values(name))
+	}
+	for name, values := range r.Header {
+		if strings.HasPrefix(name, "X-Forwarder-") {
+			fmt.Fprintf(hash, "%s: %q\n", name, values)
+		}
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// join returns the pending response of an identical request to follow, or registers the
+// caller as the leader for key and returns its response as the second value
+func (c *coalescer) join(key, connID string, window time.Duration) (follow, lead *coalescedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if resp := c.pending[key]; resp != nil && time.Since(resp.started) < window {
+		resp.mu.Lock()
+		resp.followers++
+		resp.mu.Unlock()
+		return resp, nil
+	}
+
+	// No leader, or one that started too long ago: this request leads from now on
+	if c.pending == nil {
+		c.pending = make(map[string]*coalescedResponse)
+	}
+	resp := &coalescedResponse{leaderID: connID, started: time.Now(), changed: make(chan struct{})}
+	c.pending[key] = resp
+	return nil, resp
+}
+
+// close stops duplicates from joining the response of key
+func (c *coalescer) close(key string, resp *coalescedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[key] == resp {
+		delete(c.pending, key)
+	}
+}
+
+// notify wakes the followers; the caller holds resp.mu
+func (resp *coalescedResponse) notify() {
+	close(resp.changed)
+	resp.changed = make(chan struct{})
+}
+
+// coalesceWriter is the leader's response writer: it passes the response through and keeps a
+// copy for the followers
+type coalesceWriter struct {
+	http.ResponseWriter
+	coalescer *coalescer
+	key       string
+	resp      *coalescedResponse
+	solo      bool // Nobody joined before the first byte, so nothing is recorded
+}
+
+func (cw *coalesceWriter) WriteHeader(code int) {
+	// Followers join only until the first byte, so the recorded response is complete
+	cw.coalescer.close(cw.key, cw.resp)
+	cw.resp.mu.Lock()
+	if cw.resp.status == 0 {
+		cw.resp.status = code
+		cw.resp.header = cw.ResponseWriter.Header().Clone()
+		cw.solo = cw.resp.followers == 0
+		cw.resp.notify()
+	}
+	cw.resp.mu.Unlock()
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *coalesceWriter) Write(b []byte) (int, error) {
+	cw.resp.mu.Lock()
+	started := cw.resp.status != 0
+	cw.resp.mu.Unlock()
+	if !started {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.solo {
+		cw.resp.mu.Lock()
+		cw.resp.body = append(cw.resp.body, b...)
+		cw.resp.notify()
+		cw.resp.mu.Unlock()
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush forwards flushes so streamed events reach the leader's client without delay
+func (cw *coalesceWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *coalesceWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// finish marks the leader's response as complete
+func (cw *coalesceWriter) finish() {
+	cw.coalescer.close(cw.key, cw.resp)
+	cw.resp.mu.Lock()
+	cw.resp.done = true
+	cw.resp.notify()
+	cw.resp.mu.Unlock()
+}
+
+// startCoalescing wraps the response writer of a request that may be coalesced with identical
+// ones. It returns a nil writer when the request followed an identical one and has been
+// answered, and a finish function the leader must call once its response is complete.
+func (h *Handler) startCoalescing(w http.ResponseWriter, r *http.Request, bodyBytes []byte) (http.ResponseWriter, func()) {
+	if !h.config.Forwarding.CoalesceIdenticalRequests || bodyStreamed(r.Context()) || isWebSocketUpgrade(r) {
+		return w, func() {}
+	}
+
+	connID, _ := r.Context().Value("conn_id").(string)
+	key := coalesceKey(r, bodyBytes)
+	for {
+		resp, leading := h.coalescer.join(key, connID, h.config.Forwarding.CoalesceWindow)
+		if leading != nil {
+			cw := &coalesceWriter{ResponseWriter: w, coalescer: &h.coalescer, key: key, resp: leading}
+			return cw, cw.finish
+		}
+		if h.followCoalesced(w, r, resp) {
+			return nil, nil
+		}
+		// The leader finished without a response (its client went away): try again, leading if no one else does
+	}
+}
+
+// followCoalesced replays the leader's response to a duplicate request. It returns false
+// when the leader finished without writing anything, so the request has to be forwarded itself.
+func (h *Handler) followCoalesced(w http.ResponseWriter, r *http.Request, resp *coalescedResponse) bool {
+	ctx := r.Context()
+	connID, _ := ctx.Value("conn_id").(string)
+	slog.InfoContext(ctx, fmt.Sprintf("🔗 [请求合并] 相同请求正在处理中，等待连接 %s 的响应: %s %s", resp.leaderID, r.Method, r.URL.Path))
+
+	headerSent := false
+	written := 0
+	for {
+		resp.mu.Lock()
+		status, header, done, changed := resp.status, resp.header, resp.done, resp.changed
+		chunk := resp.body[written:]
+		resp.mu.Unlock()
+
+		if status == 0 && done {
+			slog.InfoContext(ctx, fmt.Sprintf("🔗 [请求合并] 连接 %s 未返回响应，改为直接转发: %s %s", resp.leaderID, r.Method, r.URL.Path))
+			return false
+		}
+		if status != 0 && !headerSent {
+			h.recordCoalesced(connID, resp.leaderID)
+			// Headers the request's own middleware set (request ID, CORS) are kept
+			for key, values := range header {
+				if _, exists := w.Header()[key]; !exists {
+					w.Header()[key] = values
+				}
+			}
+			w.WriteHeader(status)
+			headerSent = true
+		}
+		if len(chunk) > 0 {
+			if _, err := w.Write(chunk); err != nil {
+				return true
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			written += len(chunk)
+		}
+		if done {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return true
+		}
+	}
+}
+
+// recordCoalesced counts a duplicate request answered from an identical one
+func (h *Handler) recordCoalesced(connID, leaderID string) {
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+		RecordCoalesced(connID string, leaderID string)
+	}); ok && connID != "" {
+		mm.RecordCoalesced(connID, leaderID)
+	}
+}
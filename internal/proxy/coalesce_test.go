@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// pendingFollowers returns how many requests wait for an identical one in flight
+func pendingFollowers(h *Handler) int {
+	h.coalescer.mu.Lock()
+	defer h.coalescer.mu.Unlock()
+	followers := 0
+	for _, resp := range h.coalescer.pending {
+		resp.mu.Lock()
+		followers += resp.followers
+		resp.mu.Unlock()
+	}
+	return followers
+}
+
+// serveConcurrently sends the requests at once while the upstream is held back until every
+// duplicate had the chance to join, and returns their responses with the connection IDs
+func serveConcurrently(t *testing.T, handler *Handler, mm *middleware.MonitoringMiddleware, release chan struct{}, wantFollowers int, bodies ...string) ([]*httptest.ResponseRecorder, []string) {
+	t.Helper()
+	recs := make([]*httptest.ResponseRecorder, len(bodies))
+	connIDs := make([]string, len(bodies))
+	var wg sync.WaitGroup
+	for i, body := range bodies {
+		connIDs[i] = mm.GetMetrics().RecordRequest("unknown", "test", "127.0.0.1", "test", "POST", "/v1/messages")
+		req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		if strings.Contains(body, `"stream":true`) {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), "conn_id", connIDs[i]))
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder, req *http.Request) {
+			defer wg.Done()
+			handler.ServeHTTP(rec, req)
+		}(recs[i], req)
+		// Let the first request lead
+		if i == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pendingFollowers(handler) < wantFollowers && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	return recs, connIDs
+}
+
+func TestCoalesceIdenticalRequests(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		response string
+	}{
+		{"regular", `{"model":"claude-3-5-haiku"}`, `{"id":"msg_1","usage":{"input_tokens":3,"output_tokens":5}}`},
+		{"streaming", `{"model":"claude-3-5-haiku","stream":true}`, passthroughTestStream},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var hits atomic.Int32
+			release := make(chan struct{})
+			handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				<-release
+				if strings.HasPrefix(tc.response, "event:") {
+					writeInPieces(w, tc.response, 16)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tc.response))
+			}, func(cfg *config.Config) {
+				cfg.Forwarding.CoalesceIdenticalRequests = true
+				cfg.Forwarding.CoalesceWindow = 10 * time.Second
+			})
+
+			recs, connIDs := serveConcurrently(t, handler, mm, release, 1, tc.body, tc.body)
+
+			if n := hits.Load(); n != 1 {
+				t.Fatalf("Expected a single upstream request, got %d", n)
+			}
+			for i, rec := range recs {
+				if rec.Code != http.StatusOK || rec.Body.String() != tc.response {
+					t.Errorf("Request %d: expected the upstream response, got %d %q", i, rec.Code, rec.Body.String())
+				}
+			}
+			metrics := mm.GetMetrics().GetMetrics()
+			if metrics.CoalescedRequests != 1 {
+				t.Errorf("Expected one coalesced request, got %d", metrics.CoalescedRequests)
+			}
+			if conn := metrics.ActiveConnections[connIDs[1]]; conn == nil || conn.CoalescedWith != connIDs[0] || len(conn.Attempts) != 0 {
+				t.Errorf("Expected the duplicate to follow %s without attempts, got %+v", connIDs[0], conn)
+			}
+			if conn := metrics.ActiveConnections[connIDs[0]]; conn == nil || conn.CoalescedWith != "" {
+				t.Errorf("Expected the first request to be forwarded itself, got %+v", conn)
+			}
+		})
+	}
+}
+
+func TestCoalesceOnlyIdenticalRequests(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte(`{"ok":true}`))
+	}, func(cfg *config.Config) {
+		cfg.Forwarding.CoalesceIdenticalRequests = true
+		cfg.Forwarding.CoalesceWindow = 10 * time.Second
+	})
+
+	serveConcurrently(t, handler, mm, release, 0, `{"model":"a"}`, `{"model":"b"}`)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("Expected different requests to be forwarded separately, got %d upstream requests", n)
+	}
+}
+
+func TestCoalesceDisabledByDefault(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte(`{"ok":true}`))
+	}, nil)
+
+	serveConcurrently(t, handler, mm, release, 0, `{"model":"a"}`, `{"model":"a"}`)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("Expected both requests to be forwarded, got %d upstream requests", n)
+	}
+	if n := mm.GetMetrics().GetMetrics().CoalescedRequests; n != 0 {
+		t.Errorf("Expected no coalesced requests, got %d", n)
+	}
+}
+
+func TestCoalesceFollowerForwardsWhenLeaderIsCancelled(t *testing.T) {
+	var hits atomic.Int32
+	stop := make(chan struct{})
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			// Hold the first request until its client gives up
+			io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-stop:
+			}
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}, func(cfg *config.Config) {
+		cfg.Forwarding.CoalesceIdenticalRequests = true
+		cfg.Forwarding.CoalesceWindow = 10 * time.Second
+	})
+	t.Cleanup(func() { close(stop) })
+
+	body := `{"model":"claude-3-5-haiku"}`
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body)).WithContext(ctx)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	followerDone := make(chan *httptest.ResponseRecorder)
+	go func() {
+		connID := mm.GetMetrics().RecordRequest("unknown", "test", "127.0.0.1", "test", "POST", "/v1/messages")
+		req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), "conn_id", connID))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		followerDone <- rec
+	}()
+	for deadline := time.Now().Add(2 * time.Second); pendingFollowers(handler) < 1 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-leaderDone
+
+	select {
+	case rec := <-followerDone:
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"ok":true}` {
+			t.Errorf("Expected the duplicate to be forwarded itself, got %d %q", rec.Code, rec.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Duplicate request did not complete after the first request was cancelled")
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("Expected the duplicate to reach the upstream, got %d upstream requests", n)
+	}
+}
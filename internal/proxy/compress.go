@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"endpoint_forwarder/config"
+)
+
+// gzipWriters reuses gzip writers across responses; each one holds several hundred KB of
+// compressor state
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipBody compresses a response body with a pooled writer
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(body) / 4)
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressForClient applies server.compression to a non-streaming response whose headers
+// are already in w but not yet sent. Bodies the upstream encoded itself (contentEncoding)
+// and bodies under server.compression_min_size are left alone. It returns the body to send.
+func (h *Handler) compressForClient(ctx context.Context, w http.ResponseWriter, r *http.Request, body []byte, contentEncoding string) []byte {
+	if h.config.Server.Compression != config.CompressionGzip ||
+		(contentEncoding != "" && contentEncoding != "identity") ||
+		int64(len(body)) < h.config.Server.CompressionMinBytes() {
+		return body
+	}
+
+	// The response now depends on Accept-Encoding, also for clients that get it uncompressed
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsEncoding(r, "gzip") {
+		return body
+	}
+
+	compressed, err := gzipBody(body)
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("⚠️ [压缩] gzip 压缩响应失败，发送未压缩的响应: %v", err))
+		return body
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Content-Length", strconv.Itoa(len(compressed)))
+	slog.DebugContext(ctx, fmt.Sprintf("🗜️ [压缩] 响应已 gzip 压缩: %d字节 -> %d字节", len(body), len(compressed)))
+	return compressed
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"endpoint_forwarder/config"
+)
+
+// compressionTestBody is a non-streaming Messages response with token usage
+const compressionTestBody = `{"type":"message","content":[{"type":"text","text":"hello"}],"usage":{"input_tokens":12,"output_tokens":3}}`
+
+// encodeBody compresses compressionTestBody with a content coding
+func encodeBody(t *testing.T, encoding string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	io.WriteString(w, compressionTestBody)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestCompressedResponsePassThrough(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			encoded := encodeBody(t, encoding)
+			// The upstream compresses whether or not it was asked to
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", encoding)
+				w.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+				w.Write(encoded)
+			}))
+			defer upstream.Close()
+
+			handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1})
+			server, mm := newAbortTestServer(manager, handler, handler)
+			defer server.Close()
+
+			// A raw client gets exactly what it asked for, without transparent decoding
+			rawClient := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+			send := func(acceptEncoding string) (*http.Response, []byte) {
+				req, _ := http.NewRequest("POST", server.URL+"/v1/messages", strings.NewReader(`{}`))
+				if acceptEncoding != "" {
+					req.Header.Set("Accept-Encoding", acceptEncoding)
+				}
+				resp, err := rawClient.Do(req)
+				if err != nil {
+					t.Fatalf("Request failed: %v", err)
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				return resp, body
+			}
+
+			// Client accepts the encoding: upstream bytes and headers pass through untouched
+			resp, body := send("br, " + encoding)
+			if !bytes.Equal(body, encoded) {
+				t.Errorf("Expected the compressed upstream bytes, got %q", body)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != encoding {
+				t.Errorf("Expected Content-Encoding %s, got %q", encoding, got)
+			}
+			if resp.ContentLength != int64(len(encoded)) {
+				t.Errorf("Expected Content-Length %d, got %d", len(encoded), resp.ContentLength)
+			}
+
+			// Client did not ask for it: the decoded body with a matching Content-Length
+			resp, body = send("")
+			if string(body) != compressionTestBody {
+				t.Errorf("Expected the decoded body, got %q", body)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != "" {
+				t.Errorf("Expected no Content-Encoding, got %q", got)
+			}
+			if resp.ContentLength != int64(len(compressionTestBody)) {
+				t.Errorf("Expected Content-Length %d, got %d", len(compressionTestBody), resp.ContentLength)
+			}
+
+			// Token usage is parsed from the decoded body in both cases
+			if tokens := mm.GetMetrics().GetTotalTokenStats(); tokens.InputTokens != 24 || tokens.OutputTokens != 6 {
+				t.Errorf("Expected token usage from both responses, got %+v", tokens)
+			}
+		})
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"gzip, deflate, br", true},
+		{"GZIP", true},
+		{"br", false},
+		{"", false},
+		{"gzip;q=0, deflate", false},
+		{"gzip;q=0.5", true},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/v1/messages", nil)
+		if tt.header != "" {
+			req.Header.Set("Accept-Encoding", tt.header)
+		}
+		if got := acceptsEncoding(req, "gzip"); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, gzip) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCompression(t *testing.T) {
+	large := `{"type":"message","content":[{"type":"text","text":"` + strings.Repeat("hello ", 400) + `"}]}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, compressionTestBody)
+		case "/v1/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "event: ping\ndata: "+large+"\n\n")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, large)
+		}
+	}))
+	defer upstream.Close()
+
+	handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1})
+	handler.config.Server.Compression = config.CompressionGzip
+	handler.config.Server.CompressionMinSize = "1KB"
+	server, mm := newAbortTestServer(manager, handler, handler)
+	defer server.Close()
+
+	rawClient := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	send := func(path, acceptEncoding string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("POST", server.URL+path, strings.NewReader(`{}`))
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := rawClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	// Over the threshold and accepted: gzipped, and the connection counts the compressed bytes
+	resp, body := send("/v1/messages", "gzip")
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", got)
+	}
+	if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", got)
+	}
+	if resp.ContentLength != int64(len(body)) || len(body) >= len(large) {
+		t.Errorf("Expected a smaller body with a matching Content-Length, got %d bytes (Content-Length %d)", len(body), resp.ContentLength)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Expected a gzip body: %v", err)
+	}
+	if decoded, _ := io.ReadAll(zr); string(decoded) != large {
+		t.Errorf("Expected the upstream body after decoding, got %d bytes", len(decoded))
+	}
+	if history := mm.GetMetrics().RecentConnections(1); len(history) != 1 || history[0].BytesSent != int64(len(body)) {
+		t.Errorf("Expected %d bytes sent to be recorded, got %+v", len(body), history)
+	}
+
+	// Not accepted: identity, but still varying on Accept-Encoding
+	resp, body = send("/v1/messages", "")
+	if resp.Header.Get("Content-Encoding") != "" || string(body) != large {
+		t.Errorf("Expected the uncompressed body, got Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+	if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", got)
+	}
+
+	// Under the threshold, and streams, are never compressed
+	for _, path := range []string{"/v1/small", "/v1/stream"} {
+		resp, body = send(path, "gzip")
+		if resp.Header.Get("Content-Encoding") != "" || bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+			t.Errorf("Expected %s to be sent uncompressed, got Content-Encoding %q", path, resp.Header.Get("Content-Encoding"))
+		}
+	}
+
+	// Off: sent as is
+	handler.config.Server.Compression = config.CompressionOff
+	if resp, _ = send("/v1/messages", "gzip"); resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Vary") != "" {
+		t.Errorf("Expected no compression when disabled, got Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// ErrEndpointsSaturated is returned when every candidate endpoint is at its concurrency limit
+var ErrEndpointsSaturated = errors.New("all endpoints are at their concurrency limit")
+
+// saturatedRetryAfter is the Retry-After hint (seconds) sent with concurrency 503 responses
+const saturatedRetryAfter = "1"
+
+// slotReleasingBody releases the endpoint's concurrency slot when the response body is closed,
+// so the slot is held for as long as the response is being copied to the client
+type slotReleasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *slotReleasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// runWithSlot runs a single upstream attempt while holding a concurrency slot. On success
+// the slot is handed over to the response body; otherwise (including panics) it is released here.
+func runWithSlot(operation Operation, ep *endpoint.Endpoint, connID string, release func()) (resp *http.Response, err error) {
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			release()
+		}
+	}()
+
+	resp, err = operation(ep, connID)
+	if err == nil && resp != nil && resp.Body != nil {
+		resp.Body = &slotReleasingBody{ReadCloser: resp.Body, release: release}
+		handedOff = true
+	}
+	return resp, err
+}
+
+// acquireSlot reserves a concurrency slot on the endpoint for an upstream request, queueing
+// for up to max_queue_wait when it is saturated. It returns endpoint.ErrEndpointSaturated when
+// no slot freed up in time, or the context error when the client went away while queued.
+func acquireSlot(ctx context.Context, ep *endpoint.Endpoint) (func(), error) {
+	queued := !ep.HasCapacity() && ep.Config.MaxQueueWait > 0
+	if queued {
+		slog.InfoContext(ctx, fmt.Sprintf("⏳ [并发限制] 端点 %s 已达到最大并发数 %d，排队等待空闲槽位 (最长 %v)",
+			ep.Config.Name, ep.MaxConcurrent(), ep.Config.MaxQueueWait))
+	}
+	start := time.Now()
+	release, err := ep.Acquire(ctx)
+	if errors.Is(err, endpoint.ErrEndpointSaturated) {
+		slog.InfoContext(ctx, fmt.Sprintf("🚧 [并发限制] 端点 %s 已达到最大并发数 %d，尝试下一个端点",
+			ep.Config.Name, ep.MaxConcurrent()))
+		return nil, err
+	}
+	if err != nil {
+		return nil, contextError(ctx)
+	}
+	if queued {
+		slog.InfoContext(ctx, fmt.Sprintf("⏳ [并发限制] 端点 %s 排队等待 %s 后获得空闲槽位",
+			ep.Config.Name, time.Since(start).Round(time.Millisecond)))
+	}
+	return release, nil
+}
+
+// acquireGlobalSlot reserves one of the server-wide request slots (server.max_concurrent_requests)
+func (h *Handler) acquireGlobalSlot() bool {
+	limit := int64(h.config.Server.MaxConcurrentRequests)
+	for {
+		current := h.inFlight.Load()
+		if limit > 0 && current >= limit {
+			return false
+		}
+		if h.inFlight.CompareAndSwap(current, current+1) {
+			return true
+		}
+	}
+}
+
+// releaseGlobalSlot frees a server-wide request slot
+func (h *Handler) releaseGlobalSlot() {
+	h.inFlight.Add(-1)
+}
+
+// InFlight returns the number of proxied requests currently being handled
+func (h *Handler) InFlight() int64 {
+	return h.inFlight.Load()
+}
+
+// writeSaturated responds with 503 and a short Retry-After when no capacity is available
+func writeSaturated(w http.ResponseWriter, errorType, message string) {
+	w.Header().Set("Retry-After", saturatedRetryAfter)
+	apierror.Write(w, http.StatusServiceUnavailable, errorType, message)
+}
@@ -0,0 +1,339 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// slowUpstream blocks every request until released, so tests can hold slots open
+type slowUpstream struct {
+	server  *httptest.Server
+	release chan struct{}
+}
+
+func newSlowUpstream(t *testing.T) *slowUpstream {
+	t.Helper()
+
+	su := &slowUpstream{release: make(chan struct{})}
+	su.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-su.release:
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(func() {
+		su.unblock()
+		su.server.Close()
+	})
+	return su
+}
+
+func (su *slowUpstream) unblock() {
+	select {
+	case <-su.release:
+	default:
+		close(su.release)
+	}
+}
+
+func newConcurrencyTestHandler(t *testing.T, serverLimit int, endpoints ...config.EndpointConfig) (*Handler, *endpoint.Manager) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Server:   config.ServerConfig{MaxConcurrentRequests: serverLimit},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+	}
+	for _, ep := range endpoints {
+		ep.Group = "main"
+		ep.GroupPriority = 1
+		ep.Timeout = 5 * time.Second
+		cfg.Endpoints = append(cfg.Endpoints, ep)
+	}
+
+	manager := endpoint.NewManager(cfg)
+	return NewHandler(manager, cfg), manager
+}
+
+// waitForInFlight polls until the endpoint reports the expected in-flight count
+func waitForInFlight(t *testing.T, ep *endpoint.Endpoint, expected int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if ep.InFlight() == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d in-flight requests on %s, got %d", expected, ep.Config.Name, ep.InFlight())
+}
+
+func serveAsync(handler http.Handler, req *http.Request) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		done <- rec
+	}()
+	return done
+}
+
+func TestSaturatedEndpointFallsThroughToNextCandidate(t *testing.T) {
+	slow := newSlowUpstream(t)
+	backup, backupHits := newCountingUpstream(t)
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "slow", URL: slow.server.URL, Priority: 1, MaxConcurrentRequests: 1},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	slowEp := manager.GetEndpointByName("slow")
+
+	// First request occupies the only slot on the slow endpoint
+	first := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	waitForInFlight(t, slowEp, 1)
+
+	// Second request must skip the saturated endpoint
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected second request to be served by backup, got %d", rec.Code)
+	}
+	if *backupHits != 1 {
+		t.Errorf("Expected 1 request on backup endpoint, got %d", *backupHits)
+	}
+	if slowEp.InFlight() != 1 {
+		t.Errorf("Expected slow endpoint to stay at 1 in-flight, got %d", slowEp.InFlight())
+	}
+
+	slow.unblock()
+	if res := <-first; res.Code != http.StatusOK {
+		t.Errorf("Expected first request to complete with 200, got %d", res.Code)
+	}
+	waitForInFlight(t, slowEp, 0)
+}
+
+func TestAllEndpointsSaturatedReturns503(t *testing.T) {
+	slow := newSlowUpstream(t)
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "slow", URL: slow.server.URL, Priority: 1, MaxConcurrentRequests: 2},
+	)
+	slowEp := manager.GetEndpointByName("slow")
+
+	first := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	second := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	waitForInFlight(t, slowEp, 2)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 when all endpoints are saturated, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on saturation 503")
+	}
+
+	slow.unblock()
+	<-first
+	<-second
+	waitForInFlight(t, slowEp, 0)
+}
+
+func TestSaturatedEndpointQueuesWithMaxQueueWait(t *testing.T) {
+	slow := newSlowUpstream(t)
+	backup, backupHits := newCountingUpstream(t)
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "slow", URL: slow.server.URL, Priority: 1, MaxConcurrentRequests: 1, MaxQueueWait: 2 * time.Second},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	slowEp := manager.GetEndpointByName("slow")
+
+	first := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	waitForInFlight(t, slowEp, 1)
+
+	// The second request waits for the slot instead of moving on to the backup
+	second := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	time.Sleep(50 * time.Millisecond)
+	if *backupHits != 0 {
+		t.Fatalf("Expected the queued request to stay off the backup, got %d requests there", *backupHits)
+	}
+
+	slow.unblock()
+	for _, done := range []<-chan *httptest.ResponseRecorder{first, second} {
+		if res := <-done; res.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", res.Code)
+		}
+	}
+	if *backupHits != 0 {
+		t.Errorf("Expected both requests on the slow endpoint, got %d on backup", *backupHits)
+	}
+	waitForInFlight(t, slowEp, 0)
+}
+
+func TestStreamQueuesWithMaxQueueWait(t *testing.T) {
+	proceed := make(chan struct{})
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-proceed:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: ping\ndata: {}\n\n"))
+	}, func(cfg *config.Config) {
+		cfg.Endpoints[0].MaxConcurrentRequests = 1
+		cfg.Endpoints[0].MaxQueueWait = 2 * time.Second
+	})
+	ep := handler.endpointManager.GetEndpointByName("primary")
+
+	streams := make(chan *httptest.ResponseRecorder, 2)
+	go func() {
+		rec, _ := serveStream(handler, mm)
+		streams <- rec
+	}()
+	waitForInFlight(t, ep, 1)
+	go func() {
+		rec, _ := serveStream(handler, mm)
+		streams <- rec
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	close(proceed)
+	for i := 0; i < 2; i++ {
+		rec := <-streams
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "event: ping") {
+			t.Errorf("Expected the queued stream to be served once the slot freed up, got %d: %q", rec.Code, rec.Body.String())
+		}
+	}
+	waitForInFlight(t, ep, 0)
+}
+
+func TestQueuedRequestMovesOnAfterMaxQueueWait(t *testing.T) {
+	slow := newSlowUpstream(t)
+	backup, backupHits := newCountingUpstream(t)
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "slow", URL: slow.server.URL, Priority: 1, MaxConcurrentRequests: 1, MaxQueueWait: 50 * time.Millisecond},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	slowEp := manager.GetEndpointByName("slow")
+
+	first := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	waitForInFlight(t, slowEp, 1)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusOK || *backupHits != 1 {
+		t.Fatalf("Expected the backup to serve the request after the queue wait, got %d with %d backup hits", rec.Code, *backupHits)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("Expected the request to queue for max_queue_wait first, moved on after %v", waited)
+	}
+
+	slow.unblock()
+	<-first
+	waitForInFlight(t, slowEp, 0)
+}
+
+func TestGlobalConcurrencyLimit(t *testing.T) {
+	slow := newSlowUpstream(t)
+
+	handler, manager := newConcurrencyTestHandler(t, 1,
+		config.EndpointConfig{Name: "slow", URL: slow.server.URL, Priority: 1},
+	)
+
+	first := serveAsync(handler, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	waitForInFlight(t, manager.GetEndpointByName("slow"), 1)
+	if handler.InFlight() != 1 {
+		t.Fatalf("Expected 1 global in-flight request, got %d", handler.InFlight())
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 over the global limit, got %d", rec.Code)
+	}
+
+	slow.unblock()
+	<-first
+	if handler.InFlight() != 0 {
+		t.Errorf("Expected global slot to be released, got %d in flight", handler.InFlight())
+	}
+}
+
+func TestConcurrencySlotReleasedOnClientDisconnect(t *testing.T) {
+	slow := newSlowUpstream(t)
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "slow", URL: slow.server.URL, Priority: 1, MaxConcurrentRequests: 1},
+	)
+	slowEp := manager.GetEndpointByName("slow")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)).WithContext(ctx)
+	done := serveAsync(handler, req)
+	waitForInFlight(t, slowEp, 1)
+
+	// Client goes away mid-request
+	cancel()
+	<-done
+	waitForInFlight(t, slowEp, 0)
+	if handler.InFlight() != 0 {
+		t.Errorf("Expected global slot to be released, got %d in flight", handler.InFlight())
+	}
+}
+
+func TestConcurrencySlotReleasedOnPanic(t *testing.T) {
+	_, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "ep", URL: "http://127.0.0.1:1", Priority: 1, MaxConcurrentRequests: 1},
+	)
+	ep := manager.GetEndpointByName("ep")
+
+	release, ok := ep.TryAcquire()
+	if !ok {
+		t.Fatal("Expected to acquire a slot")
+	}
+	if _, ok := ep.TryAcquire(); ok {
+		t.Fatal("Expected endpoint to be saturated")
+	}
+
+	func() {
+		defer func() { recover() }()
+		runWithSlot(func(*endpoint.Endpoint, string) (*http.Response, error) {
+			panic("boom")
+		}, ep, "", release)
+	}()
+
+	if ep.InFlight() != 0 {
+		t.Errorf("Expected slot to be released after panic, got %d in flight", ep.InFlight())
+	}
+}
+
+func newCountingUpstream(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
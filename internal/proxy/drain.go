@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"endpoint_forwarder/internal/apierror"
+)
+
+// shutdownEvent is sent to streaming clients when the forwarder starts shutting down. The
+// stream itself keeps running until it finishes or server.shutdown_drain_timeout expires.
+var shutdownEvent = []byte(fmt.Sprintf("event: shutdown\ndata: %s\n\n",
+	apierror.Body(apierror.TypeShuttingDown, "The forwarder is shutting down; this stream continues until it completes or the drain timeout expires")))
+
+// streamRegistry tracks the passthrough streams in progress so a shutdown can tell their
+// clients before the connections are closed
+type streamRegistry struct {
+	mu       sync.Mutex
+	streams  map[*passthroughWriter]struct{}
+	draining bool
+}
+
+// add registers a stream; a stream that starts while draining is told right away
+func (r *streamRegistry) add(p *passthroughWriter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams == nil {
+		r.streams = make(map[*passthroughWriter]struct{})
+	}
+	r.streams[p] = struct{}{}
+	if r.draining {
+		p.notify(shutdownEvent)
+	}
+}
+
+func (r *streamRegistry) remove(p *passthroughWriter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams, p)
+}
+
+func (r *streamRegistry) isDraining() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.draining
+}
+
+// BeginDrain puts the handler into shutdown mode: new requests are refused with 503 and
+// every active stream receives an "event: shutdown" SSE event at its next event boundary.
+// It returns the number of streams that were told.
+func (h *Handler) BeginDrain() int {
+	h.streams.mu.Lock()
+	defer h.streams.mu.Unlock()
+	h.streams.draining = true
+	for p := range h.streams.streams {
+		p.notify(shutdownEvent)
+	}
+	return len(h.streams.streams)
+}
+
+// ActiveStreams returns the number of passthrough streams currently being forwarded
+func (h *Handler) ActiveStreams() int {
+	h.streams.mu.Lock()
+	defer h.streams.mu.Unlock()
+	return len(h.streams.streams)
+}
+
+// writeShuttingDown refuses a request that arrives after the shutdown started
+func writeShuttingDown(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	w.Header().Set("Retry-After", saturatedRetryAfter)
+	apierror.Write(w, http.StatusServiceUnavailable, apierror.TypeShuttingDown, "The forwarder is shutting down")
+}
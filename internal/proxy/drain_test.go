@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"endpoint_forwarder/internal/apierror"
+)
+
+func TestShutdownEventWaitsForEventBoundary(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out := &passthroughWriter{w: rec, flusher: rec, newlines: 2}
+
+	out.write([]byte("event: a\ndata: 1\n\n"))
+	out.write([]byte("event: b\ndata: "))
+	out.notify(shutdownEvent)
+	if strings.Contains(rec.Body.String(), "event: shutdown") {
+		t.Fatalf("Shutdown event written in the middle of an event: %q", rec.Body.String())
+	}
+	out.write([]byte("2\r\n"))
+	out.write([]byte("\r\n"))
+	out.notify(shutdownEvent)
+	out.write([]byte("event: c\ndata: 3\n\n"))
+
+	want := "event: a\ndata: 1\n\n" + "event: b\ndata: 2\r\n\r\n" + string(shutdownEvent) + "event: c\ndata: 3\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("Unexpected stream:\n got %q\nwant %q", got, want)
+	}
+
+	// At a boundary the event is written right away
+	rec = httptest.NewRecorder()
+	out = &passthroughWriter{w: rec, flusher: rec, newlines: 2}
+	out.write([]byte("event: a\ndata: 1\n\n"))
+	out.notify(shutdownEvent)
+	if want := "event: a\ndata: 1\n\n" + string(shutdownEvent); rec.Body.String() != want {
+		t.Errorf("Expected the shutdown event right after the event, got %q", rec.Body.String())
+	}
+}
+
+func TestBeginDrain(t *testing.T) {
+	handler, _ := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}, nil)
+
+	stream := httptest.NewRecorder()
+	out := &passthroughWriter{w: stream, flusher: stream, newlines: 2}
+	handler.streams.add(out)
+	if got := handler.ActiveStreams(); got != 1 {
+		t.Fatalf("Expected 1 active stream, got %d", got)
+	}
+	if got := handler.BeginDrain(); got != 1 {
+		t.Errorf("Expected 1 notified stream, got %d", got)
+	}
+	if !strings.Contains(stream.Body.String(), "event: shutdown") {
+		t.Errorf("Expected the active stream to receive the shutdown event, got %q", stream.Body.String())
+	}
+
+	// Streams that start while draining are told right away
+	late := httptest.NewRecorder()
+	handler.streams.add(&passthroughWriter{w: late, flusher: late, newlines: 2})
+	if !strings.Contains(late.Body.String(), "event: shutdown") {
+		t.Errorf("Expected a stream started while draining to receive the shutdown event")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{"model":"claude"}`)))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), apierror.TypeShuttingDown) {
+		t.Errorf("Expected 503 %s while draining, got %d %s", apierror.TypeShuttingDown, rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Connection") != "close" {
+		t.Errorf("Expected Connection: close while draining")
+	}
+
+	handler.streams.remove(out)
+	if got := handler.ActiveStreams(); got != 1 {
+		t.Errorf("Expected 1 active stream after removing one, got %d", got)
+	}
+}
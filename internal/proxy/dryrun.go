@@ -0,0 +1,250 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/monitor"
+	"endpoint_forwarder/internal/transport"
+)
+
+const (
+	// dryRunTimeout caps how long a dry-run request may take, whatever the endpoint timeout
+	dryRunTimeout = 30 * time.Second
+	// dryRunPreviewBytes is how much of the response body the report includes
+	dryRunPreviewBytes = 2048
+	// dryRunMaxBody is how much of the response body is read for token parsing
+	dryRunMaxBody = 1 << 20
+)
+
+// DryRunRequest describes a synthetic request sent through the proxy pipeline
+type DryRunRequest struct {
+	Endpoint string // Endpoint name; empty lets the current strategy choose
+	Path     string // Request path, default: "/v1/messages"
+	Method   string // HTTP method, default: "POST"
+	Body     []byte
+	Stream   bool // Request a streaming response
+}
+
+// DryRunReport describes what the proxy did with a dry-run request
+type DryRunReport struct {
+	Endpoint        string              `json:"endpoint"`
+	Group           string              `json:"group"`
+	SelectionReason string              `json:"selectionReason"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	Transport       string              `json:"transport"`
+	RequestHeaders  map[string]string   `json:"requestHeaders"` // Outbound headers, secrets masked
+	StatusCode      int                 `json:"statusCode"`
+	LatencyMs       int64               `json:"latencyMs"`
+	ResponseHeaders map[string]string   `json:"responseHeaders"`
+	ResponseBytes   int                 `json:"responseBytes"`
+	ResponsePreview string              `json:"responsePreview"` // First dryRunPreviewBytes of the body
+	Truncated       bool                `json:"truncated"`
+	TokenUsage      *monitor.TokenUsage `json:"tokenUsage,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+// DryRun sends a synthetic request through the same header copying, token resolution and
+// transport selection as proxied requests. It bypasses retries and monitoring, so it does not
+// count toward request, endpoint or token statistics. Invalid requests return an error;
+// upstream failures are reported in the report.
+func (h *Handler) DryRun(ctx context.Context, dr DryRunRequest) (*DryRunReport, error) {
+	if h.config.IsSetupMode() {
+		return nil, fmt.Errorf("no endpoints are configured")
+	}
+	if dr.Path == "" {
+		dr.Path = "/v1/messages"
+	}
+	if !strings.HasPrefix(dr.Path, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+	if dr.Method == "" {
+		dr.Method = http.MethodPost
+	}
+	dr.Method = strings.ToUpper(dr.Method)
+
+	ep, reason, err := h.selectDryRunEndpoint(ctx, dr.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	body := dr.Body
+	if dr.Stream {
+		body = withStreamField(body)
+	}
+
+	// The synthetic client request that copyHeaders works from
+	inbound, err := http.NewRequestWithContext(ctx, dr.Method, dr.Path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	inbound.Header.Set("Content-Type", "application/json")
+	if dr.Stream {
+		inbound.Header.Set("Accept", "text/event-stream")
+	}
+
+	timeout := dryRunTimeout
+	if ep.Config.Timeout > 0 && ep.Config.Timeout < timeout {
+		timeout = ep.Config.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := &DryRunReport{
+		Endpoint:        ep.Config.Name,
+		Group:           ep.Config.Group,
+		SelectionReason: reason,
+		Method:          dr.Method,
+		URL:             ep.Config.BaseURL() + inbound.URL.RequestURI(),
+		Transport:       transport.GetProxyInfo(h.config),
+	}
+	if ep.Config.IsUnixSocket() {
+		report.Transport = "Unix socket: " + ep.Config.SocketPath()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, dr.Method, report.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	h.copyHeaders(inbound, req, ep)
+	report.RequestHeaders = maskHeaders(req.Header)
+	if req.Host != "" {
+		report.RequestHeaders["Host"] = req.Host
+	}
+
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface{ RecordDryRun() }); ok {
+		mm.RecordDryRun()
+	}
+	slog.InfoContext(ctx, fmt.Sprintf("🧪 [试运行] 发送测试请求: %s %s -> %s (%s)", dr.Method, dr.Path, ep.Config.Name, reason))
+
+	httpTransport, err := h.transports.Get(h.config, ep.Config)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to create transport: %v", err)
+		return report, nil
+	}
+	client := &http.Client{Transport: httpTransport}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		report.LatencyMs = time.Since(start).Milliseconds()
+		report.Error = fmt.Sprintf("request failed: %v", err)
+		return report, nil
+	}
+	defer resp.Body.Close()
+
+	report.StatusCode = resp.StatusCode
+	report.ResponseHeaders = flattenHeaders(resp.Header)
+	resp.Body = io.NopCloser(io.LimitReader(resp.Body, dryRunMaxBody))
+	responseBody, err := h.readAndDecompressResponse(ctx, resp, ep.Config.Name)
+	report.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to read response: %v", err)
+		return report, nil
+	}
+
+	report.ResponseBytes = len(responseBody)
+	report.ResponsePreview = string(responseBody)
+	if len(responseBody) > dryRunPreviewBytes {
+		report.ResponsePreview = string(responseBody[:dryRunPreviewBytes])
+		report.Truncated = true
+	}
+	report.TokenUsage = parseResponseTokens(string(responseBody), ep.Config.ResponseFormat())
+	return report, nil
+}
+
+// selectDryRunEndpoint picks the named endpoint, or the one the current strategy would try first
+func (h *Handler) selectDryRunEndpoint(ctx context.Context, name string) (*endpoint.Endpoint, string, error) {
+	if name != "" {
+		ep := h.endpointManager.GetEndpointByNameAny(name)
+		if ep == nil {
+			return nil, "", fmt.Errorf("endpoint %s not found", name)
+		}
+		reason := "requested by name"
+		if !ep.IsHealthy() {
+			reason += " (endpoint is currently unhealthy)"
+		}
+		return ep, reason, nil
+	}
+
+	candidates := h.retryHandler.selectEndpoints(ctx, nil)
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("no healthy endpoints available")
+	}
+	ep := candidates[0]
+	reason := fmt.Sprintf("selected by %s strategy: first of %d healthy candidates in active group %s (priority %d)",
+		h.config.Strategy.Type, len(candidates), ep.Config.Group, ep.Config.Priority)
+	return ep, reason, nil
+}
+
+// withStreamField sets "stream": true in a JSON object body; other bodies are left unchanged
+func withStreamField(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return body
+	}
+	fields["stream"] = json.RawMessage("true")
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return encoded
+}
+
+// parseResponseTokens extracts token usage from an SSE or JSON response body
+func parseResponseTokens(responseBody, format string) *monitor.TokenUsage {
+	tokenParser := NewTokenParserFor(format)
+	if isSSEBody(responseBody, format) {
+		tokenParser.ParseChunk([]byte(responseBody))
+		tokenParser.Finish()
+		if totals := tokenParser.Totals(); totals != (monitor.TokenUsage{}) {
+			return &totals
+		}
+		return nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(responseBody), "{") && strings.Contains(responseBody, "usage") {
+		return tokenParser.ParseEvent(SSEEvent{Event: "message_delta", Data: responseBody})
+	}
+	return nil
+}
+
+// sensitiveHeader reports whether a header may carry credentials
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range []string{"auth", "key", "token", "secret", "cookie"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskHeaders flattens headers and masks the ones that may carry credentials
+func maskHeaders(header http.Header) map[string]string {
+	headers := flattenHeaders(header)
+	for name, value := range headers {
+		if sensitiveHeader(name) {
+			headers[name] = config.MaskSecret(value)
+		}
+	}
+	return headers
+}
+
+// flattenHeaders joins multi-value headers for display
+func flattenHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
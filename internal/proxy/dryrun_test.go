@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// dryRunRecorder is a minimal monitoring middleware that counts dry runs and token usage
+type dryRunRecorder struct {
+	mu          sync.Mutex
+	dryRuns     int
+	tokenEvents int
+}
+
+func (dr *dryRunRecorder) RecordRetry(connID string, endpoint string) {}
+
+func (dr *dryRunRecorder) RecordDryRun() {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	dr.dryRuns++
+}
+
+func (dr *dryRunRecorder) RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	dr.tokenEvents++
+}
+
+func TestDryRun(t *testing.T) {
+	var gotAuth, gotPath, gotBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"type":"message","usage":{"input_tokens":12,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second, Token: "sk-ant-secret-token-value"},
+		config.EndpointConfig{Name: "backup", URL: upstream.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+	recorder := &dryRunRecorder{}
+	handler.SetMonitoringMiddleware(recorder)
+
+	report, err := handler.DryRun(context.Background(), DryRunRequest{
+		Path:   "/v1/messages",
+		Body:   []byte(`{"model":"claude-3-5-haiku-latest","max_tokens":1}`),
+		Stream: true,
+	})
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+
+	if report.Endpoint != "primary" || !strings.Contains(report.SelectionReason, "priority strategy") {
+		t.Errorf("Expected strategy to choose primary, got %s (%s)", report.Endpoint, report.SelectionReason)
+	}
+	if gotPath != "/v1/messages" || gotAuth != "Bearer sk-ant-secret-token-value" {
+		t.Errorf("Upstream got path %q, auth %q", gotPath, gotAuth)
+	}
+	if !strings.Contains(gotBody, `"stream":true`) {
+		t.Errorf("Expected stream flag in body, got %s", gotBody)
+	}
+	if auth := report.RequestHeaders["Authorization"]; strings.Contains(auth, "secret-token") || !strings.HasPrefix(auth, "Bearer ") {
+		t.Errorf("Expected masked Authorization header, got %q", auth)
+	}
+	if report.RequestHeaders["Accept"] != "text/event-stream" {
+		t.Errorf("Expected streaming Accept header, got %v", report.RequestHeaders)
+	}
+	if report.StatusCode != http.StatusOK || report.Error != "" {
+		t.Errorf("Expected 200, got %d (%s)", report.StatusCode, report.Error)
+	}
+	if report.TokenUsage == nil || report.TokenUsage.InputTokens != 12 {
+		t.Errorf("Expected parsed token usage, got %+v", report.TokenUsage)
+	}
+
+	// Only the dry-run counter moves
+	if recorder.dryRuns != 1 || recorder.tokenEvents != 0 {
+		t.Errorf("Expected 1 dry run and no token usage, got %d and %d", recorder.dryRuns, recorder.tokenEvents)
+	}
+
+	// A named endpoint overrides the strategy
+	report, err = handler.DryRun(context.Background(), DryRunRequest{Endpoint: "backup", Method: "get", Path: "/v1/models"})
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if report.Endpoint != "backup" || report.Method != "GET" || gotPath != "/v1/models" {
+		t.Errorf("Expected GET /v1/models on backup, got %s %s on %s", report.Method, gotPath, report.Endpoint)
+	}
+
+	if _, err := handler.DryRun(context.Background(), DryRunRequest{Endpoint: "missing"}); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+}
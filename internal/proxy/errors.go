@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"endpoint_forwarder/internal/apierror"
+)
+
+// maxUpstreamErrorBody bounds the upstream error body kept for passing through after a
+// streaming attempt
+const maxUpstreamErrorBody = 1 << 20
+
+// upstreamStatusError is a streaming attempt answered with an error status. Non-retryable
+// ones are passed through to the client when nothing has been streamed yet.
+type upstreamStatusError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Retryable  bool
+	Token      string // Bearer token the request carried, for token rotation
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("endpoint returned error: %d", e.StatusCode)
+}
+
+// streamInterruptedError is a stream whose upstream failed after it started sending events
+type streamInterruptedError struct {
+	err error
+}
+
+func (e *streamInterruptedError) Error() string {
+	return "error reading response: " + e.err.Error()
+}
+
+func (e *streamInterruptedError) Unwrap() error {
+	return e.err
+}
+
+// writeUpstreamError passes an upstream error response through byte for byte, keeping its
+// status and headers (including Content-Encoding) and naming the endpoint it came from
+func writeUpstreamError(ctx context.Context, w http.ResponseWriter, status int, header http.Header, body io.Reader, endpointName string) {
+	for key, values := range header {
+		// Skip hop-by-hop headers
+		if key == "Connection" || key == "Transfer-Encoding" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.Header().Set(apierror.HeaderUpstream, endpointName)
+	w.WriteHeader(status)
+
+	written, err := io.Copy(w, body)
+	if err != nil && !clientCancelled(ctx) {
+		slog.WarnContext(ctx, fmt.Sprintf("⚠️ [上游错误] 转发端点 %s 的错误响应失败: %v", endpointName, err))
+		return
+	}
+	slog.InfoContext(ctx, fmt.Sprintf("↩️ [上游错误] 透传端点 %s 的错误响应 - 状态码: %d, 长度: %d字节", endpointName, status, written))
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// gzippedUpstreamError is an Anthropic error body compressed by the upstream
+func gzippedUpstreamError(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func newErrorTestHandler(t *testing.T, upstream http.HandlerFunc, passthrough bool) *Handler {
+	t.Helper()
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: server.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	cfg.Streaming = config.StreamingConfig{PassthroughMode: passthrough, HeartbeatInterval: time.Minute, MaxIdleTime: time.Minute}
+	return NewHandler(endpoint.NewManager(cfg), cfg)
+}
+
+func sendErrorTestRequest(handler *Handler, stream bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-5-haiku"}`))
+	req.Header.Set("Accept-Encoding", "gzip")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUpstreamErrorPassedThrough(t *testing.T) {
+	body := gzippedUpstreamError(t)
+	for _, stream := range []bool{false, true} {
+		handler := newErrorTestHandler(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Request-Id", "req_123")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(body)
+		}, true)
+
+		rec := sendErrorTestRequest(handler, stream)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("stream=%v: expected the upstream 401, got %d", stream, rec.Code)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), body) || rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("stream=%v: expected the gzipped body byte for byte, got %q (encoding %q)",
+				stream, rec.Body.Bytes(), rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Header().Get(apierror.HeaderUpstream) != "primary" || rec.Header().Get("Request-Id") != "req_123" {
+			t.Errorf("stream=%v: expected upstream headers and %s: primary, got %v", stream, apierror.HeaderUpstream, rec.Header())
+		}
+		if apierror.IsLocal(rec.Header()) {
+			t.Errorf("stream=%v: upstream errors must not be marked as forwarder errors", stream)
+		}
+	}
+}
+
+func TestLocalErrorEnvelope(t *testing.T) {
+	handler := newErrorTestHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, false)
+
+	rec := sendErrorTestRequest(handler, false)
+	if rec.Code != http.StatusBadGateway || !apierror.IsLocal(rec.Header()) || rec.Header().Get(apierror.HeaderUpstream) != "" {
+		t.Fatalf("Expected a 502 marked as a forwarder error, got %d %v", rec.Code, rec.Header())
+	}
+	var envelope apierror.Envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || envelope.Type != "error" ||
+		envelope.Error.Type != apierror.TypeAllEndpointsFailed || envelope.Error.Message == "" {
+		t.Errorf("Expected a %s error envelope, got %s", apierror.TypeAllEndpointsFailed, rec.Body.String())
+	}
+}
+
+func TestLocalErrorSSEEvent(t *testing.T) {
+	handler := newErrorTestHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, true)
+
+	rec := sendErrorTestRequest(handler, true)
+	if !apierror.IsLocal(rec.Header()) {
+		t.Errorf("Expected the stream to be marked as a forwarder error, got %v", rec.Header())
+	}
+	event := rec.Body.String()
+	if !strings.HasPrefix(event, "event: error\ndata: ") || !strings.HasSuffix(event, "\n\n") {
+		t.Fatalf("Expected a terminal SSE error event, got %q", event)
+	}
+	var envelope apierror.Envelope
+	data := strings.TrimSuffix(strings.TrimPrefix(event, "event: error\ndata: "), "\n\n")
+	if err := json.Unmarshal([]byte(data), &envelope); err != nil || envelope.Error.Type != apierror.TypeAllEndpointsFailed {
+		t.Errorf("Expected a %s envelope in the event, got %q", apierror.TypeAllEndpointsFailed, data)
+	}
+}
@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// RouteQuery describes a request whose endpoint selection is explained
+type RouteQuery struct {
+	Path   string // Request path, default: "/v1/messages"
+	Method string // HTTP method, default: "POST"
+	Model  string // Model named in the request body
+	Stream bool   // Whether the request asks for a streaming response
+	Client string // Client identity request rules match against
+}
+
+// RouteExplanation is the trace of the endpoint selection a request would get right now
+type RouteExplanation struct {
+	Method      string               `json:"method"`
+	Path        string               `json:"path"`
+	Model       string               `json:"model,omitempty"`
+	Stream      bool                 `json:"stream"`
+	Strategy    string               `json:"strategy"`
+	Rule        string               `json:"rule,omitempty"`        // Request rule the request matches
+	RoutedGroup string               `json:"routedGroup,omitempty"` // Group a request rule routes it to
+	Candidates  []RouteCandidate     `json:"candidates"`            // In the order a request tries them
+	Excluded    []endpoint.Exclusion `json:"excluded"`
+	Notes       []string             `json:"notes"`
+	Selected    string               `json:"selected,omitempty"`
+	Outcome     string               `json:"outcome"`
+}
+
+// RouteCandidate is an endpoint a request would try, in order
+type RouteCandidate struct {
+	Endpoint      string `json:"endpoint"`
+	Group         string `json:"group"`
+	Priority      int    `json:"priority"`
+	InFlight      int64  `json:"inFlight"`
+	MaxConcurrent int    `json:"maxConcurrent,omitempty"`
+	Skipped       string `json:"skipped,omitempty"` // Why a request would pass over it right now
+	Queued        string `json:"queued,omitempty"`  // Why a request would wait for it first
+}
+
+// ExplainRoute runs endpoint selection for a described request the way ServeHTTP would,
+// through request rules, the model filter and the strategy, and returns the trace. Nothing
+// is sent upstream and selection state (round-robin positions, fast test results, rate
+// limit tokens, concurrency slots) is left untouched.
+func (h *Handler) ExplainRoute(ctx context.Context, q RouteQuery) (*RouteExplanation, error) {
+	if h.config.IsSetupMode() {
+		return nil, fmt.Errorf("no endpoints are configured")
+	}
+	if q.Path == "" {
+		q.Path = "/v1/messages"
+	}
+	if !strings.HasPrefix(q.Path, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+	if q.Method == "" {
+		q.Method = http.MethodPost
+	}
+	q.Method = strings.ToUpper(q.Method)
+
+	r, err := http.NewRequestWithContext(ctx, q.Method, q.Path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	ex := &RouteExplanation{
+		Method:   q.Method,
+		Path:     r.URL.Path,
+		Model:    q.Model,
+		Stream:   q.Stream,
+		Strategy: h.config.Strategy.Type,
+	}
+
+	// Request rules see the same body fields a real request would carry
+	fields := map[string]any{"stream": q.Stream}
+	if q.Model != "" {
+		fields["model"] = q.Model
+	}
+	body, _ := json.Marshal(fields)
+	if rule := h.rules.Load().match(r, q.Client, body); rule != nil {
+		ex.Rule = rule.Name
+		switch rule.Action.Type {
+		case config.RuleActionDeny:
+			ex.Outcome = fmt.Sprintf("denied by rule %s with status %d", rule.Name, rule.Action.Status)
+			return ex, nil
+		case config.RuleActionRewriteModel:
+			ex.Model = rule.Action.Model
+			ex.Notes = append(ex.Notes, fmt.Sprintf("rule %s rewrites the model to %s", rule.Name, rule.Action.Model))
+		case config.RuleActionRouteToGroup:
+			ex.RoutedGroup = rule.Action.Group
+			ctx = context.WithValue(ctx, routedGroupContextKey, rule.Action.Group)
+		}
+	}
+
+	if ex.Model != "" {
+		served, available := h.modelServed(ex.Model)
+		if served {
+			ctx = context.WithValue(ctx, requestModelContextKey, ex.Model)
+		} else if len(available) > 0 {
+			ex.Outcome = fmt.Sprintf("rejected with status 400: no endpoint serves model %s (available: %s)",
+				ex.Model, strings.Join(available, ", "))
+			return ex, nil
+		}
+	}
+
+	trace := &endpoint.SelectionTrace{}
+	candidates := h.retryHandler.selectEndpoints(ctx, trace)
+	ex.Excluded = trace.Excluded
+	ex.Notes = append(ex.Notes, trace.Notes...)
+	if q.Stream && h.config.Streaming.PassthroughMode {
+		ex.Notes = append(ex.Notes, "streamed to the client as it arrives (streaming.passthrough_mode)")
+	}
+
+	ex.Candidates = make([]RouteCandidate, 0, len(candidates))
+	for _, ep := range candidates {
+		candidate := RouteCandidate{
+			Endpoint:      ep.Config.Name,
+			Group:         endpointGroup(ep),
+			Priority:      ep.Config.Priority,
+			InFlight:      ep.InFlight(),
+			MaxConcurrent: ep.MaxConcurrent(),
+		}
+		candidate.Skipped, candidate.Queued = availability(ep)
+		if ex.Selected == "" && candidate.Skipped == "" {
+			ex.Selected = ep.Config.Name
+		}
+		ex.Candidates = append(ex.Candidates, candidate)
+	}
+
+	switch {
+	case len(candidates) == 0:
+		ex.Outcome = "no healthy endpoints available in active groups"
+	case ex.Selected == "":
+		ex.Outcome = "every candidate is at its concurrency or rate limit"
+	default:
+		ex.Outcome = fmt.Sprintf("sent to %s", ex.Selected)
+	}
+	return ex, nil
+}
+
+// availability reports whether the retry loop would pass over the endpoint because of its
+// concurrency or rate limit, or wait for it first, checking the same limits without taking
+// a slot or token
+func availability(ep *endpoint.Endpoint) (skipped, queued string) {
+	if !ep.HasCapacity() {
+		if ep.Config.MaxQueueWait <= 0 {
+			return fmt.Sprintf("at its concurrency limit of %d", ep.MaxConcurrent()), ""
+		}
+		queued = fmt.Sprintf("at its concurrency limit of %d, a request waits up to %s for a slot",
+			ep.MaxConcurrent(), ep.Config.MaxQueueWait)
+	}
+
+	limit := ep.RateLimit()
+	if limit == nil {
+		return "", queued
+	}
+	wait := ep.RateLimitWait()
+	if wait <= 0 {
+		return "", queued
+	}
+	if limit.OnExceeded == config.RateLimitQueue && wait <= limit.MaxWait {
+		if queued == "" {
+			queued = fmt.Sprintf("at its rate limit of %d requests per minute, a request waits %s for a token",
+				limit.RequestsPerMinute, wait.Round(time.Millisecond))
+		}
+		return "", queued
+	}
+	return fmt.Sprintf("at its rate limit of %d requests per minute", limit.RequestsPerMinute), ""
+}
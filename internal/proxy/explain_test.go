@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func exclusionReason(ex *RouteExplanation, name string) string {
+	for _, exclusion := range ex.Excluded {
+		if exclusion.Endpoint == name {
+			return exclusion.Reason
+		}
+	}
+	return ""
+}
+
+func TestExplainRouteMatchesServedEndpoint(t *testing.T) {
+	haikuUpstream, haikuRecorder := newBodyRecorder(t)
+	generalUpstream, generalRecorder := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "maintenance", URL: haikuUpstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "haiku-only", URL: haikuUpstream.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Models: []string{"claude-3-haiku-*"}},
+		config.EndpointConfig{Name: "general", URL: generalUpstream.URL, Priority: 3, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "standby", URL: haikuUpstream.URL, Priority: 1, Group: "standby", GroupPriority: 2, Timeout: time.Second})
+	manager := endpoint.NewManager(cfg)
+	if err := manager.SetEndpointMaintenance("maintenance", true, "test"); err != nil {
+		t.Fatalf("SetEndpointMaintenance failed: %v", err)
+	}
+	handler := NewHandler(manager, cfg)
+
+	ex, err := handler.ExplainRoute(context.Background(), RouteQuery{Model: "claude-3-5-sonnet-20241022", Stream: true})
+	if err != nil {
+		t.Fatalf("ExplainRoute failed: %v", err)
+	}
+	if ex.Selected != "general" || len(ex.Candidates) != 1 {
+		t.Fatalf("Expected general as the only candidate, got %s from %+v", ex.Selected, ex.Candidates)
+	}
+	for name, want := range map[string]string{
+		"maintenance": "maintenance mode",
+		"haiku-only":  "does not serve model",
+		"standby":     "not active",
+	} {
+		if reason := exclusionReason(ex, name); !strings.Contains(reason, want) {
+			t.Errorf("Expected %s to be excluded for %q, got %q", name, want, reason)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages",
+		bytes.NewBufferString(`{"model":"claude-3-5-sonnet-20241022","stream":true}`)))
+	if hits, _, _ := generalRecorder.last(); hits != 1 {
+		t.Errorf("Expected the request on the explained endpoint, got %d hits", hits)
+	}
+	if hits, _, _ := haikuRecorder.last(); hits != 0 {
+		t.Errorf("Expected no request on the excluded endpoints, got %d hits", hits)
+	}
+}
+
+func TestExplainRouteLeavesRoundRobinPosition(t *testing.T) {
+	firstUpstream, firstRecorder := newBodyRecorder(t)
+	secondUpstream, _ := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "first", URL: firstUpstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "second", URL: secondUpstream.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	cfg.Strategy.Type = "round-robin"
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	for i := 0; i < 3; i++ {
+		ex, err := handler.ExplainRoute(context.Background(), RouteQuery{})
+		if err != nil {
+			t.Fatalf("ExplainRoute failed: %v", err)
+		}
+		if ex.Selected != "first" {
+			t.Fatalf("Expected every explanation to select first, got %s", ex.Selected)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"messages":[]}`)))
+	if hits, _, _ := firstRecorder.last(); hits != 1 {
+		t.Errorf("Expected the request on the explained endpoint, got %d hits", hits)
+	}
+}
+
+func TestExplainRouteSkipsSaturatedEndpoint(t *testing.T) {
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "small", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			MaxConcurrentRequests: 1},
+		config.EndpointConfig{Name: "large", URL: "http://127.0.0.1:1", Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+
+	release, ok := manager.GetEndpointByNameAny("small").TryAcquire()
+	if !ok {
+		t.Fatal("Expected a free slot on small")
+	}
+	defer release()
+
+	ex, err := handler.ExplainRoute(context.Background(), RouteQuery{})
+	if err != nil {
+		t.Fatalf("ExplainRoute failed: %v", err)
+	}
+	if ex.Selected != "large" || len(ex.Candidates) != 2 || !strings.Contains(ex.Candidates[0].Skipped, "concurrency limit") {
+		t.Errorf("Expected small to be skipped for its concurrency limit, got %s from %+v", ex.Selected, ex.Candidates)
+	}
+	if manager.GetEndpointByNameAny("small").InFlight() != 1 {
+		t.Errorf("Expected explaining not to take a slot")
+	}
+}
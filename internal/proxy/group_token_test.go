@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestGroupTokenFollowsFailover(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	newUpstream := func(status int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			seen = append(seen, r.Header.Get("Authorization"))
+			mu.Unlock()
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	failing := newUpstream(http.StatusInternalServerError)
+	healthy := newUpstream(http.StatusOK)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "main-1", URL: failing.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "backup-1", URL: healthy.URL, Priority: 1, Group: "backup", GroupPriority: 2, Timeout: time.Second})
+	cfg.Group.MaxRetries = 1
+	cfg.Groups = map[string]config.GroupSettings{
+		"main":   {Token: "sk-main-group"},
+		"backup": {Token: "sk-backup-group"},
+	}
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	// The first request exhausts the main group's retry; the second sends it into cooldown and
+	// fails over to the backup group within the same request
+	for i, expected := range []int{http.StatusBadGateway, http.StatusOK} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+		if rec.Code != expected {
+			t.Fatalf("Expected request %d to answer %d, got %d", i+1, expected, rec.Code)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"Bearer sk-main-group", "Bearer sk-main-group", "Bearer sk-backup-group"}
+	if len(seen) != len(expected) {
+		t.Fatalf("Expected %d upstream requests, got %v", len(expected), seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("Expected upstream request %d to carry %q, got %q", i+1, expected[i], seen[i])
+		}
+	}
+}
+
+func TestEndpointTokenOverridesGroupToken(t *testing.T) {
+	cfg := &config.Config{
+		Groups: map[string]config.GroupSettings{"main": {Token: "sk-main-group"}},
+		Endpoints: []config.EndpointConfig{
+			{Name: "main-1", URL: "https://a.example.com", Group: "main", Token: "sk-own"},
+			{Name: "main-2", URL: "https://b.example.com", Group: "main"},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+
+	if got := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("main-1")); got != "sk-own" {
+		t.Errorf("Expected the endpoint's own token, got %q", got)
+	}
+	if got := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("main-2")); got != "sk-main-group" {
+		t.Errorf("Expected the group token to win over a sibling's token, got %q", got)
+	}
+}
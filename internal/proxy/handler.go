@@ -6,15 +6,21 @@ import (
 	"compress/gzip"
 	"compress/lzw"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
 	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
 	"endpoint_forwarder/internal/monitor"
 	"endpoint_forwarder/internal/transport"
 	"github.com/andybalholm/brotli"
@@ -30,6 +36,14 @@ type Handler struct {
 	endpointManager *endpoint.Manager
 	config          *config.Config
 	retryHandler    *RetryHandler
+	inFlight        atomic.Int64 // Proxied requests in progress, limited by server.max_concurrent_requests
+	rules           atomic.Pointer[ruleSet] // Compiled request rules, replaced on config reload
+	captures        captureStore            // Recent requests for the WebUI inspector
+	transports      *transport.Cache        // Upstream transports per endpoint, rebuilt when their settings change
+	coalescer       coalescer               // Identical in-flight requests sharing one upstream request
+	streams         streamRegistry          // Passthrough streams in progress, told about a shutdown
+	replays         replayRegistry          // Passthrough streams clients can reconnect to (streaming.replay_buffer_kb)
+	shedding        shedState               // Fail-fast state while no endpoint can take requests
 }
 
 // NewHandler creates a new proxy handler
@@ -37,11 +51,14 @@ func NewHandler(endpointManager *endpoint.Manager, cfg *config.Config) *Handler
 	retryHandler := NewRetryHandler(cfg)
 	retryHandler.SetEndpointManager(endpointManager)
 	
-	return &Handler{
+	h := &Handler{
 		endpointManager: endpointManager,
 		config:          cfg,
 		retryHandler:    retryHandler,
+		transports:      transport.NewCache(),
 	}
+	h.rules.Store(compileRules(cfg.Rules))
+	return h
 }
 
 // SetMonitoringMiddleware sets the monitoring middleware for retry tracking
@@ -53,19 +70,100 @@ func (h *Handler) SetMonitoringMiddleware(mm interface{
 
 // ServeHTTP implements the http.Handler interface
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Started without endpoints: nothing to forward to until a config is activated
+	if h.config.IsSetupMode() {
+		writeNotConfigured(w)
+		return
+	}
+
+	// Shutting down: requests arriving on connections that are still open are not started
+	if h.streams.isDraining() {
+		writeShuttingDown(w)
+		return
+	}
+
+	// OpenAI chat completions are translated to the Messages API and back (compat.openai_enabled)
+	if h.config.Compat.OpenAIEnabled && r.Method == http.MethodPost && r.URL.Path == openAIChatPath {
+		var finish func()
+		if w, finish = h.serveOpenAI(w, r); w == nil {
+			return
+		}
+		defer finish()
+	}
+
+	// Record the request and response for the WebUI inspector (webui.capture_enabled)
+	var bodyBytes []byte
+	if cw := h.startCapture(w); cw != nil {
+		w = cw
+		start := time.Now()
+		defer func() { h.finishCapture(r, cw, bodyBytes, start) }()
+	}
+
+	// Enforce the server-wide concurrency limit for the whole request (including streaming)
+	if !h.acquireGlobalSlot() {
+		slog.WarnContext(r.Context(), fmt.Sprintf("🚧 [并发限制] 全局并发请求数已达上限 %d，拒绝请求: %s %s",
+			h.config.Server.MaxConcurrentRequests, r.Method, r.URL.Path))
+		writeSaturated(w, apierror.TypeOverloaded, "Too many concurrent requests")
+		return
+	}
+	defer h.releaseGlobalSlot()
+
+	// A client reconnecting to a stream that is still being relayed resumes it
+	if h.resumeStream(w, r) {
+		return
+	}
+
 	// Create a context for this request
 	ctx := r.Context()
 	
-	// Clone request body for potential retries
-	var bodyBytes []byte
-	if r.Body != nil {
-		var err error
-		bodyBytes, err = io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusInternalServerError)
-			return
-		}
-		r.Body.Close()
+	// Clone request body for potential retries (large bodies are rejected or streamed, see server.on_large_body)
+	var ok bool
+	if bodyBytes, ok = h.readRequestBody(w, r); !ok {
+		return
+	}
+
+	// Apply request rules before endpoint selection (may deny, rewrite the body or pick a group)
+	var handled bool
+	if bodyBytes, handled = h.applyRules(w, r, bodyBytes); handled {
+		return
+	}
+
+	// Client routing overrides (X-Forwarder-*) take precedence over a rule's group
+	if h.applyRoutingOverrides(w, r) {
+		return
+	}
+	if h.applyRetryOverride(w, r) {
+		return
+	}
+	if h.applyTimeoutOverride(w, r) {
+		return
+	}
+	// Endpoints with a models list only receive requests for those models
+	if h.applyModelFilter(w, r, bodyBytes) {
+		return
+	}
+	// While no endpoint can take requests, fail fast except for a periodic recovery probe (load_shedding)
+	if h.shedLoad(w, r) {
+		return
+	}
+	ctx = r.Context()
+
+	// Attach the idempotency key and cross-endpoint retry policy for this client request
+	// (mutate in place so the logging middleware still sees values added later)
+	ctx = withRetryPolicy(ctx, r, len(bodyBytes), h.config.Retry)
+	*r = *r.WithContext(ctx)
+
+	// Identical requests in flight share one upstream request (forwarding.coalesce_identical_requests)
+	var finishCoalescing func()
+	if w, finishCoalescing = h.startCoalescing(w, r, bodyBytes); w == nil {
+		return
+	}
+	defer finishCoalescing()
+
+	// WebSocket upgrades are tunneled to the selected endpoint for as long as both sides keep them open
+	if isWebSocketUpgrade(r) {
+		h.handleWebSocket(w, r)
+		return
 	}
 
 	// Check if this is an SSE request - Claude API streaming patterns
@@ -84,8 +182,9 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			 strings.Contains(string(bodyBytes), `"stream":true`) ||
 			 strings.Contains(string(bodyBytes), `"stream": true`)
 
-	// TEMPORARILY DISABLE STREAMING - force all requests to use regular handler for debugging
-	if false && isSSE {
+	// Streaming requests are buffered by the regular handler unless streaming.passthrough_mode
+	// forwards them to the client chunk by chunk
+	if isSSE && h.config.Streaming.PassthroughMode && !bodyStreamed(ctx) {
 		h.handleSSERequest(w, r, bodyBytes)
 		return
 	}
@@ -93,6 +192,13 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.handleRegularRequest(ctx, w, r, bodyBytes)
 }
 
+// writeNotConfigured responds with a 503 JSON error while the forwarder is in setup mode
+func writeNotConfigured(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "30")
+	apierror.Write(w, http.StatusServiceUnavailable, apierror.TypeNotConfigured,
+		"Forwarder is in setup mode: no endpoints are configured. Import or activate a configuration with at least one endpoint.")
+}
+
 // handleRegularRequest handles non-streaming requests
 func (h *Handler) handleRegularRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte) {
 	var selectedEndpointName string
@@ -115,36 +221,59 @@ func (h *Handler) handleRegularRequest(ctx context.Context, w http.ResponseWrite
 		}
 		
 		// Create request to target endpoint
-		targetURL := ep.Config.URL + r.URL.Path
+		targetURL := ep.Config.BaseURL() + r.URL.Path
 		if r.URL.RawQuery != "" {
 			targetURL += "?" + r.URL.RawQuery
 		}
 
-		req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(bodyBytes))
+		var body io.Reader = r.Body
+		if !bodyStreamed(ctx) {
+			// Each endpoint gets the client's body with its own transform applied
+			endpointBody, err := transformBody(ep.Config, bodyBytes)
+			if err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(endpointBody)
+		}
+		// The timeout covers reading the response too, unless it turns out to be a stream
+		attemptCtx, deadline := startAttemptDeadline(ctx, attemptTimeout(ctx, ep))
+		req, err := http.NewRequestWithContext(attemptCtx, r.Method, targetURL, body)
 		if err != nil {
+			deadline.release()
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
+		if bodyStreamed(ctx) {
+			req.ContentLength = r.ContentLength
+		}
 
 		// Copy headers from original request
 		h.copyHeaders(r, req, ep)
 
-		// Create HTTP client with timeout and proxy support
-		httpTransport, err := transport.CreateTransport(h.config)
+		// Create HTTP client, reusing the endpoint's pooled transport
+		httpTransport, err := h.transports.Get(h.config, ep.Config)
 		if err != nil {
+			deadline.release()
 			return nil, fmt.Errorf("failed to create transport: %w", err)
 		}
 		
 		client := &http.Client{
-			Timeout:   ep.Config.Timeout,
 			Transport: httpTransport,
 		}
 
 		// Make the request
 		resp, err := client.Do(req)
 		if err != nil {
+			err = deadline.err(err)
+			deadline.release()
 			return nil, fmt.Errorf("request failed: %w", err)
 		}
 
+		// A stream the client did not ask for is not cut off by the non-streaming timeout
+		if isEventStream(resp) {
+			deadline.disarm()
+		}
+		resp.Body = deadline.body(resp.Body)
+
 		// Return the response - retry logic will check status code
 		return resp, nil
 	}
@@ -158,41 +287,76 @@ func (h *Handler) handleRegularRequest(ctx context.Context, w http.ResponseWrite
 	}
 	
 	if lastErr != nil {
-		// Check if the error is due to no healthy endpoints
-		if strings.Contains(lastErr.Error(), "no healthy endpoints") {
-			http.Error(w, "Service Unavailable: No healthy endpoints available", http.StatusServiceUnavailable)
-		} else {
-			// If all retries failed, return error
-			http.Error(w, "All endpoints failed: "+lastErr.Error(), http.StatusBadGateway)
-		}
+		h.writeRetryError(ctx, w, r, lastErr)
 		return
 	}
 
 	if finalResp == nil {
-		http.Error(w, "No response received from any endpoint", http.StatusBadGateway)
+		apierror.Write(w, http.StatusBadGateway, apierror.TypeAllEndpointsFailed, "No response received from any endpoint")
 		return
 	}
 
 	defer finalResp.Body.Close()
 
-	// Copy response headers (except Content-Encoding for gzip handling)
-	for key, values := range finalResp.Header {
-		// Skip Content-Encoding header as we handle gzip decompression ourselves
-		if strings.ToLower(key) == "content-encoding" {
+	// Upstream errors are the provider's answer: pass them through untouched
+	if finalResp.StatusCode >= 400 {
+		writeUpstreamError(ctx, w, finalResp.StatusCode, h.responseHeaders(finalResp.Header), finalResp.Body, selectedEndpointName)
+		return
+	}
+
+	// A stream answering a request not recognized as streaming takes the streaming path it
+	// would have taken with streaming.passthrough_mode
+	if isEventStream(finalResp) && h.config.Streaming.PassthroughMode {
+		if flusher, ok := w.(http.Flusher); ok {
+			slog.InfoContext(ctx, fmt.Sprintf("📡 [流式检测] 端点 %s 返回了事件流，切换到流式转发", selectedEndpointName))
+			h.streamResponsePassthrough(ctx, w, finalResp, flusher, connID, selectedEndpointName)
+			return
+		}
+	}
+	// Read the body as the upstream sent it
+	rawBody, err := io.ReadAll(finalResp.Body)
+	if err != nil {
+		if clientCancelled(ctx) {
+			slog.InfoContext(ctx, fmt.Sprintf("🚫 [客户端取消] 读取响应时客户端断开连接: 端点 %s", selectedEndpointName))
+			return
+		}
+		apierror.Write(w, http.StatusInternalServerError, apierror.TypeUpstreamUnreadable, "Failed to read response: "+err.Error())
+		return
+	}
+
+	// The body is decoded for logging and token parsing only. The client gets the upstream's
+	// bytes and Content-Encoding/Content-Length untouched, unless it did not ask for that
+	// encoding, in which case it gets the decoded body.
+	contentEncoding := strings.ToLower(strings.TrimSpace(finalResp.Header.Get("Content-Encoding")))
+	bodyBytes, decodeErr := h.decompressBody(ctx, contentEncoding, rawBody, selectedEndpointName)
+	if decodeErr != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("⚠️ [压缩] 无法解码端点 %s 的响应 (编码: %s)，原样转发且不解析token: %v",
+			selectedEndpointName, contentEncoding, decodeErr))
+	}
+	clientBody := rawBody
+	decodeForClient := decodeErr == nil && contentEncoding != "" && contentEncoding != "identity" && !acceptsEncoding(r, contentEncoding)
+
+	for key, values := range h.responseHeaders(finalResp.Header) {
+		if decodeForClient && (key == "Content-Encoding" || key == "Content-Length") {
 			continue
 		}
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
 	}
-
-	// Set status code
+	if decodeForClient {
+		clientBody = bodyBytes
+		w.Header().Set("Content-Length", strconv.Itoa(len(clientBody)))
+		slog.DebugContext(ctx, fmt.Sprintf("🗜️ [压缩] 客户端未接受 %s 编码，转发解码后的响应，端点: %s", contentEncoding, selectedEndpointName))
+	}
+	if !decodeForClient && !isEventStream(finalResp) {
+		clientBody = h.compressForClient(ctx, w, r, clientBody, contentEncoding)
+	}
+	w.Header().Set(apierror.HeaderUpstream, selectedEndpointName)
 	w.WriteHeader(finalResp.StatusCode)
 
-	// Read and decompress response body if needed
-	bodyBytes, err := h.readAndDecompressResponse(ctx, finalResp, selectedEndpointName)
-	if err != nil {
-		http.Error(w, "Failed to read response: "+err.Error(), http.StatusInternalServerError)
+	if decodeErr != nil {
+		w.Write(clientBody)
 		return
 	}
 
@@ -207,11 +371,33 @@ func (h *Handler) handleRegularRequest(ctx context.Context, w http.ResponseWrite
 	h.analyzeResponseForTokens(ctx, bodyContent, selectedEndpointName, r)
 	
 	// Write the body to client
-	_, writeErr := w.Write(bodyBytes)
+	_, writeErr := w.Write(clientBody)
 	if writeErr != nil {
 	}
 }
 
+// writeRetryError responds to a request for which the retry handler found no usable response
+func (h *Handler) writeRetryError(ctx context.Context, w http.ResponseWriter, r *http.Request, lastErr error) {
+	// Check if the error is due to no healthy endpoints
+	if errors.Is(lastErr, ErrClientCancelled) {
+		// Nobody is listening any more; the logging middleware records the cancellation
+		slog.InfoContext(ctx, fmt.Sprintf("🚫 [客户端取消] 客户端在收到响应前断开连接: %s %s", r.Method, r.URL.Path))
+	} else if errors.Is(lastErr, ErrEndpointsRateLimited) {
+		h.writeRateLimited(w)
+	} else if errors.Is(lastErr, ErrEndpointsSaturated) {
+		writeSaturated(w, apierror.TypeEndpointsSaturated, "All endpoints are at their concurrency limit")
+	} else if errors.Is(lastErr, ErrRetryBudgetExhausted) {
+		apierror.Write(w, http.StatusBadGateway, apierror.TypeRetryBudgetExhausted, "Request failed and the retry budget is exhausted: "+lastErr.Error())
+	} else if strings.Contains(lastErr.Error(), "no healthy endpoints") {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.TypeNoHealthyEndpoints, "No healthy endpoints available")
+	} else if strings.Contains(lastErr.Error(), "cross-endpoint retry disabled") {
+		apierror.Write(w, http.StatusBadGateway, apierror.TypeFailoverDisabled, lastErr.Error())
+	} else {
+		// If all retries failed, return error
+		apierror.Write(w, http.StatusBadGateway, apierror.TypeAllEndpointsFailed, "All endpoints failed: "+lastErr.Error())
+	}
+}
+
 // readAndDecompressResponse reads and decompresses the response body based on Content-Encoding
 func (h *Handler) readAndDecompressResponse(ctx context.Context, resp *http.Response, endpointName string) ([]byte, error) {
 	// Read the raw response body
@@ -220,8 +406,12 @@ func (h *Handler) readAndDecompressResponse(ctx context.Context, resp *http.Resp
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Check Content-Encoding header
 	contentEncoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	return h.decompressBody(ctx, contentEncoding, bodyBytes, endpointName)
+}
+
+// decompressBody decodes a response body according to its (lower-cased) Content-Encoding
+func (h *Handler) decompressBody(ctx context.Context, contentEncoding string, bodyBytes []byte, endpointName string) ([]byte, error) {
 	if contentEncoding == "" {
 		// No encoding, return as is
 		return bodyBytes, nil
@@ -247,6 +437,26 @@ func (h *Handler) readAndDecompressResponse(ctx context.Context, resp *http.Resp
 	}
 }
 
+// acceptsEncoding reports whether the client's Accept-Encoding allows a content coding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.TrimSpace(coding)
+			if !strings.EqualFold(coding, encoding) && coding != "*" {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
 // decompressGzip decompresses gzip encoded content
 func (h *Handler) decompressGzip(ctx context.Context, bodyBytes []byte, endpointName string) ([]byte, error) {
 	slog.DebugContext(ctx, fmt.Sprintf("🗜️ [GZIP] 检测到gzip编码响应，端点: %s, 压缩长度: %d字节", endpointName, len(bodyBytes)))
@@ -323,56 +533,79 @@ func (h *Handler) analyzeResponseForTokens(ctx context.Context, responseBody, en
 		connID = connIDValue
 	}
 	
+	// Usage is reported differently by Claude and OpenAI-compatible endpoints (api_format)
+	format := h.responseFormat(endpointName)
+
 	// Method 1: Try to find SSE format in the response (for streaming responses that were buffered)
-	if strings.Contains(responseBody, "event: message_delta") {
-		h.parseSSETokens(ctx, responseBody, endpointName, connID)
+	if isSSEBody(responseBody, format) {
+		h.parseSSETokens(ctx, responseBody, endpointName, connID, format)
 		return
 	}
 	
 	// Method 2: Try to parse as single JSON response
 	if strings.HasPrefix(strings.TrimSpace(responseBody), "{") && strings.Contains(responseBody, "usage") {
-		h.parseJSONTokens(ctx, responseBody, endpointName, connID)
+		h.parseJSONTokens(ctx, responseBody, endpointName, connID, format)
 		return
 	}
 
 }
 
+// responseFormat returns the api_format of an endpoint, anthropic for unknown endpoints
+func (h *Handler) responseFormat(endpointName string) string {
+	if ep := h.endpointManager.GetEndpointByNameAny(endpointName); ep != nil {
+		return ep.Config.ResponseFormat()
+	}
+	return config.APIFormatAnthropic
+}
+
+// isSSEBody reports whether a buffered response body is an SSE stream carrying usage
+func isSSEBody(responseBody, format string) bool {
+	if format == config.APIFormatOpenAI {
+		return strings.HasPrefix(strings.TrimSpace(responseBody), "data:")
+	}
+	return strings.Contains(responseBody, "event: message_delta")
+}
+
 // parseSSETokens parses SSE format response for token usage
-func (h *Handler) parseSSETokens(ctx context.Context, responseBody, endpointName, connID string) {
-	tokenParser := NewTokenParser()
-	lines := strings.Split(responseBody, "\n")
-	
-	for _, line := range lines {
-		if tokenUsage := tokenParser.ParseSSELine(line); tokenUsage != nil {
-			// Record token usage
-			if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
-				RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage)
-			}); ok && connID != "" {
-				mm.RecordTokenUsage(connID, endpointName, tokenUsage)
-				return
-			}
-		}
+func (h *Handler) parseSSETokens(ctx context.Context, responseBody, endpointName, connID, format string) {
+	tokenParser := NewTokenParserFor(format)
+	tokenParser.ParseChunk([]byte(responseBody))
+	tokenParser.Finish()
+
+	totals := tokenParser.Totals()
+	if totals == (monitor.TokenUsage{}) {
+		slog.DebugContext(ctx, "🚫 [SSE解析] 未找到token usage信息")
+		return
 	}
-	
-	slog.DebugContext(ctx, "🚫 [SSE解析] 未找到token usage信息")
+
+	// Record the whole response's usage at once
+	h.recordTokenUsage(connID, endpointName, &totals)
+}
+
+// recordTokenUsage counts token usage toward the endpoint's token budgets and records it with
+// the monitoring middleware, if available
+func (h *Handler) recordTokenUsage(connID, endpointName string, tokens *monitor.TokenUsage) bool {
+	h.endpointManager.RecordTokenUsage(endpointName, tokens.InputTokens+tokens.OutputTokens)
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
+		RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage)
+	}); ok && connID != "" {
+		mm.RecordTokenUsage(connID, endpointName, tokens)
+		return true
+	}
+	return false
 }
 
 // parseJSONTokens parses single JSON response for token usage
-func (h *Handler) parseJSONTokens(ctx context.Context, responseBody, endpointName, connID string) {
+func (h *Handler) parseJSONTokens(ctx context.Context, responseBody, endpointName, connID, format string) {
 	// Simulate SSE parsing for a single JSON response
-	tokenParser := NewTokenParser()
+	tokenParser := NewTokenParserFor(format)
 	
 	slog.InfoContext(ctx, "🔍 [JSON解析] 尝试解析JSON响应")
 	
-	// Wrap JSON as SSE message_delta event
-	tokenParser.ParseSSELine("event: message_delta")
-	tokenParser.ParseSSELine("data: " + responseBody)
-	if tokenUsage := tokenParser.ParseSSELine(""); tokenUsage != nil {
+	// Treat the JSON body as a single message_delta event
+	if tokenUsage := tokenParser.ParseEvent(SSEEvent{Event: "message_delta", Data: responseBody}); tokenUsage != nil {
 		// Record token usage
-		if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
-			RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage)
-		}); ok && connID != "" {
-			mm.RecordTokenUsage(connID, endpointName, tokenUsage)
+		if h.recordTokenUsage(connID, endpointName, tokenUsage) {
 			slog.InfoContext(ctx, "✅ [JSON解析] 成功记录token使用", 
 				"endpoint", endpointName, 
 				"inputTokens", tokenUsage.InputTokens, 
@@ -396,7 +629,7 @@ func (h *Handler) copyHeaders(src *http.Request, dst *http.Request, ep *endpoint
 	
 	// Copy all headers except those we want to skip
 	for key, values := range src.Header {
-		if skipHeaders[strings.ToLower(key)] {
+		if skipHeaders[strings.ToLower(key)] || h.config.Forwarding.Headers.StripsRequestHeader(key) {
 			continue
 		}
 		
@@ -406,14 +639,14 @@ func (h *Handler) copyHeaders(src *http.Request, dst *http.Request, ep *endpoint
 	}
 
 	// Set Host header based on target endpoint URL
-	if u, err := url.Parse(ep.Config.URL); err == nil {
+	if u, err := url.Parse(ep.Config.BaseURL()); err == nil {
 		dst.Header.Set("Host", u.Host)
 		// Also set the Host field directly on the request for proper HTTP/1.1 behavior
 		dst.Host = u.Host
 	}
 
 	// Add or override Authorization header with dynamically resolved token
-	token := h.endpointManager.GetTokenForEndpoint(ep)
+	token := h.endpointManager.NextTokenForEndpoint(ep)
 	if token != "" {
 		dst.Header.Set("Authorization", "Bearer "+token)
 	}
@@ -429,6 +662,21 @@ func (h *Handler) copyHeaders(src *http.Request, dst *http.Request, ep *endpoint
 		dst.Header.Set(key, value)
 	}
 
+	// Static headers from forwarding.headers go on top of the endpoint's own
+	for key, value := range h.config.Forwarding.Headers.AddRequestHeaders {
+		dst.Header.Set(key, value)
+	}
+
+	// Carry the same idempotency key on every attempt so upstreams can deduplicate retries
+	if key := idempotencyKeyFromContext(dst.Context()); key != "" && h.config.Retry.IdempotencyHeader != "" {
+		dst.Header.Set(h.config.Retry.IdempotencyHeader, key)
+	}
+
+	// Forward the request ID so upstream logs can be matched with ours
+	if requestID := middleware.RequestIDFromContext(src.Context()); requestID != "" && h.config.Forwarding.RequestIDHeader != "" {
+		dst.Header.Set(h.config.Forwarding.RequestIDHeader, requestID)
+	}
+
 	// Remove hop-by-hop headers
 	hopByHopHeaders := []string{
 		"Connection",
@@ -446,10 +694,30 @@ func (h *Handler) copyHeaders(src *http.Request, dst *http.Request, ep *endpoint
 	}
 }
 
+// responseHeaders returns the upstream response headers that may be returned to the client,
+// without those matched by forwarding.headers.strip_response_headers
+func (h *Handler) responseHeaders(header http.Header) http.Header {
+	rules := h.config.Forwarding.Headers
+	if len(rules.StripResponseHeaders) == 0 {
+		return header
+	}
+	filtered := make(http.Header, len(header))
+	for key, values := range header {
+		if !rules.StripsResponseHeader(key) {
+			filtered[key] = values
+		}
+	}
+	return filtered
+}
+
 // UpdateConfig updates the handler configuration
 func (h *Handler) UpdateConfig(cfg *config.Config) {
 	h.config = cfg
+	h.rules.Store(compileRules(cfg.Rules))
 	
 	// Update retry handler with new config
 	h.retryHandler.UpdateConfig(cfg)
+
+	// Close the connection pools of removed endpoints; changed ones are rebuilt on next use
+	h.transports.Prune(cfg.Endpoints)
 }
\ No newline at end of file
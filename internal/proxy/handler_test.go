@@ -2,13 +2,19 @@ package proxy
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"endpoint_forwarder/config"
 	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
 )
 
 func TestSensitiveHeaderRemoval(t *testing.T) {
@@ -205,4 +211,102 @@ func TestHostHeaderWithDifferentPorts(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// newAbortTestServer serves next behind the logging and monitoring middleware, like main does
+func newAbortTestServer(manager *endpoint.Manager, handler *Handler, next http.Handler) (*httptest.Server, *middleware.MonitoringMiddleware) {
+	mm := middleware.NewMonitoringMiddleware(manager)
+	handler.SetMonitoringMiddleware(mm)
+	lm := middleware.NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	lm.SetMonitoringMiddleware(mm)
+	return httptest.NewServer(lm.Wrap(next)), mm
+}
+
+// waitForCancelled waits until the monitor has recorded a cancelled request
+func waitForCancelled(t *testing.T, mm *middleware.MonitoringMiddleware) *monitor.Metrics {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if metrics := mm.GetMetrics().GetMetrics(); metrics.CancelledRequests > 0 {
+			return metrics
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the request to be recorded as cancelled")
+	return nil
+}
+
+// assertCancelledNotFailed waits for an aborted request to be recorded and checks that it left
+// no failure or retry behind
+func assertCancelledNotFailed(t *testing.T, mm *middleware.MonitoringMiddleware, manager *endpoint.Manager, backupHits func() int64) {
+	t.Helper()
+	metrics := waitForCancelled(t, mm)
+	if metrics.CancelledRequests != 1 || metrics.FailedRequests != 0 {
+		t.Errorf("Expected 1 cancelled and 0 failed requests, got %d and %d", metrics.CancelledRequests, metrics.FailedRequests)
+	}
+	history := mm.GetMetrics().RecentConnections(0)
+	if len(history) != 1 || history[0].Status != "cancelled" {
+		t.Fatalf("Expected one cancelled connection in history, got %+v", history)
+	}
+	if retries := history[0].RetryCount; retries != 0 {
+		t.Errorf("Expected no retries after the client aborted, got %d", retries)
+	}
+	for name, stats := range metrics.EndpointStats {
+		if stats.FailedRequests != 0 || stats.RetryCount != 0 {
+			t.Errorf("Expected endpoint %s to have no failures or retries, got %d and %d", name, stats.FailedRequests, stats.RetryCount)
+		}
+	}
+	if hits := backupHits(); hits != 0 {
+		t.Errorf("Expected no failover to the backup endpoint, got %d requests", hits)
+	}
+	if count := manager.GetGroupManager().GetGroupRetryCount("main"); count != 0 {
+		t.Errorf("Expected group retry count to stay 0, got %d", count)
+	}
+}
+
+func TestClientAbortNonStreaming(t *testing.T) {
+	started := make(chan struct{}, 1)
+	upstreamCancelled := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // lets the server notice the proxy going away
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer primary.Close()
+	var backupHits atomic.Int64
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupHits.Add(1)
+		w.Write([]byte(`{}`))
+	}))
+	defer backup.Close()
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: primary.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second})
+	cfg.Retry.MaxAttempts = 3
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+	server, mm := newAbortTestServer(manager, handler, handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	req, _ := http.NewRequestWithContext(ctx, "POST", server.URL+"/v1/messages", bytes.NewBufferString(`{"model":"claude"}`))
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected the aborted request to fail on the client, got %d", resp.StatusCode)
+	}
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the upstream request to be cancelled")
+	}
+	assertCancelledNotFailed(t, mm, manager, backupHits.Load)
+}
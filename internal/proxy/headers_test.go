@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestForwardingHeaderRules(t *testing.T) {
+	var upstreamHeaders http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeaders = r.Header.Clone()
+		w.Header().Set("X-Upstream-Region", "us-east")
+		w.Header().Set("X-Request-Cost", "0.01")
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := newRulesTestConfig(nil, config.EndpointConfig{
+		Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+		Headers: map[string]string{"X-Tenant": "endpoint", "X-Endpoint": "primary"},
+	})
+	cfg.Streaming = config.StreamingConfig{PassthroughMode: true, HeartbeatInterval: time.Minute, MaxIdleTime: time.Minute}
+	cfg.Forwarding.Headers = config.HeaderRulesConfig{
+		StripRequestHeaders:  []string{"x-internal-*", "X-Debug"},
+		StripResponseHeaders: []string{"x-upstream-*"},
+		AddRequestHeaders:    map[string]string{"X-Tenant": "forwarder"},
+	}
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	for _, tc := range []struct {
+		name string
+		body string
+	}{
+		{"regular", `{"model":"test"}`},
+		{"streaming", `{"model":"test","stream":true}`},
+	} {
+		upstreamHeaders = nil
+		req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(tc.body))
+		req.Header.Set("X-Internal-User", "alice")
+		req.Header.Set("X-INTERNAL-TRACE", "abc")
+		req.Header.Set("X-Debug", "1")
+		req.Header.Set("X-Client-Version", "2.0")
+		req.Header.Set("Anthropic-Version", "2023-06-01")
+		if tc.name == "streaming" {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || upstreamHeaders == nil {
+			t.Fatalf("%s: expected the request to reach the upstream, got %d", tc.name, rec.Code)
+		}
+		for _, denied := range []string{"X-Internal-User", "X-Internal-Trace", "X-Debug"} {
+			if value := upstreamHeaders.Get(denied); value != "" {
+				t.Errorf("%s: expected %s to be stripped, upstream got %q", tc.name, denied, value)
+			}
+		}
+		for header, want := range map[string]string{
+			"X-Client-Version":  "2.0",
+			"Anthropic-Version": "2023-06-01",
+			"X-Endpoint":        "primary",
+			"X-Tenant":          "forwarder",
+		} {
+			if got := upstreamHeaders.Get(header); got != want {
+				t.Errorf("%s: expected upstream %s %q, got %q", tc.name, header, want, got)
+			}
+		}
+		if value := rec.Header().Get("X-Upstream-Region"); value != "" {
+			t.Errorf("%s: expected X-Upstream-Region to be stripped from the response, got %q", tc.name, value)
+		}
+		if value := rec.Header().Get("X-Request-Cost"); value != "0.01" {
+			t.Errorf("%s: expected X-Request-Cost to reach the client, got %q", tc.name, value)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+const (
+	// IdempotencyKeyContextKey carries the per-request idempotency key to every upstream attempt
+	IdempotencyKeyContextKey = contextKey("idempotency_key")
+	// noFailoverContextKey marks requests that must not be retried on a different endpoint
+	noFailoverContextKey = contextKey("no_failover")
+)
+
+// withRetryPolicy attaches the idempotency key and failover restriction for a client request
+func withRetryPolicy(ctx context.Context, r *http.Request, bodySize int, retryCfg config.RetryConfig) context.Context {
+	if retryCfg.IdempotencyHeader != "" {
+		ctx = context.WithValue(ctx, IdempotencyKeyContextKey, idempotencyKeyFor(r, retryCfg.IdempotencyHeader))
+	}
+	if !allowCrossEndpointRetry(r.Method, bodySize, retryCfg) {
+		ctx = context.WithValue(ctx, noFailoverContextKey, true)
+	}
+	return ctx
+}
+
+// idempotencyKeyFor reuses the client's own key when it sent one, otherwise generates a new key
+func idempotencyKeyFor(r *http.Request, header string) string {
+	if key := r.Header.Get(header); key != "" {
+		return key
+	}
+	return generateIdempotencyKey()
+}
+
+// generateIdempotencyKey returns a random key that is unique per client request
+func generateIdempotencyKey() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("ef-%d", time.Now().UnixNano())
+	}
+	return "ef-" + hex.EncodeToString(buf)
+}
+
+// idempotencyKeyFromContext returns the idempotency key attached to the request, if any
+func idempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(IdempotencyKeyContextKey).(string)
+	return key
+}
+
+// failoverDisabled reports whether the request must stay on the first endpoint it reached
+func failoverDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noFailoverContextKey).(bool)
+	return disabled
+}
+
+// allowCrossEndpointRetry reports whether a request may be resent to a different endpoint.
+// Only POST/PATCH bodies larger than the configured threshold are restricted.
+func allowCrossEndpointRetry(method string, bodySize int, retryCfg config.RetryConfig) bool {
+	if retryCfg.AllowNonIdempotentRetry() {
+		return true
+	}
+	if method != http.MethodPost && method != http.MethodPatch {
+		return true
+	}
+	return int64(bodySize) <= retryCfg.NonIdempotentBodyThreshold
+}
@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// keyRecorder collects the idempotency keys seen by a test upstream
+type keyRecorder struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (kr *keyRecorder) handler(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kr.mu.Lock()
+		kr.keys = append(kr.keys, r.Header.Get("Idempotency-Key"))
+		kr.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	}
+}
+
+func (kr *keyRecorder) seen() []string {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return append([]string(nil), kr.keys...)
+}
+
+// attemptRecorder is a minimal monitoring middleware that tracks keyed attempts
+type attemptRecorder struct {
+	mu       sync.Mutex
+	attempts map[string]int
+	key      string
+}
+
+func (ar *attemptRecorder) RecordRetry(connID string, endpoint string) {}
+
+func (ar *attemptRecorder) RecordIdempotentAttempt(connID string, key string) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.attempts[connID]++
+	ar.key = key
+}
+
+func newIdempotencyTestHandler(t *testing.T, retry config.RetryConfig) (*Handler, *keyRecorder, *keyRecorder) {
+	t.Helper()
+
+	failing, healthy := &keyRecorder{}, &keyRecorder{}
+	failingServer := httptest.NewServer(failing.handler(http.StatusInternalServerError))
+	t.Cleanup(failingServer.Close)
+	healthyServer := httptest.NewServer(healthy.handler(http.StatusOK))
+	t.Cleanup(healthyServer.Close)
+
+	retry.MaxAttempts = 2
+	retry.BaseDelay = 10 * time.Millisecond
+	retry.MaxDelay = 10 * time.Millisecond
+	retry.Multiplier = 1
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    retry,
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "failing", URL: failingServer.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "healthy", URL: healthyServer.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		},
+	}
+
+	return NewHandler(endpoint.NewManager(cfg), cfg), failing, healthy
+}
+
+func TestIdempotencyKeyReusedAcrossFailover(t *testing.T) {
+	handler, failing, healthy := newIdempotencyTestHandler(t, config.RetryConfig{IdempotencyHeader: "Idempotency-Key"})
+	monitor := &attemptRecorder{attempts: make(map[string]int)}
+	handler.SetMonitoringMiddleware(monitor)
+
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test"}`))
+	req = req.WithContext(context.WithValue(req.Context(), "conn_id", "conn-1"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected failover to succeed with 200, got %d", rec.Code)
+	}
+
+	keys := append(failing.seen(), healthy.seen()...)
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 upstream attempts (2 failing + 1 healthy), got %d", len(keys))
+	}
+	if keys[0] == "" || !strings.HasPrefix(keys[0], "ef-") {
+		t.Fatalf("Expected a generated idempotency key, got %q", keys[0])
+	}
+	for i, key := range keys {
+		if key != keys[0] {
+			t.Errorf("Attempt %d carried key %q, expected %q", i+1, key, keys[0])
+		}
+	}
+
+	if monitor.attempts["conn-1"] != 3 || monitor.key != keys[0] {
+		t.Errorf("Expected 3 keyed attempts with key %q, got %d with %q", keys[0], monitor.attempts["conn-1"], monitor.key)
+	}
+
+	// A key supplied by the client is passed through unchanged
+	req = httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test"}`))
+	req.Header.Set("Idempotency-Key", "client-key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	keys = healthy.seen()
+	if last := keys[len(keys)-1]; last != "client-key" {
+		t.Errorf("Expected client key to be reused, got %q", last)
+	}
+}
+
+func TestNonIdempotentFailoverDisabled(t *testing.T) {
+	disabled := false
+	handler, failing, healthy := newIdempotencyTestHandler(t, config.RetryConfig{
+		IdempotencyHeader:          "Idempotency-Key",
+		RetryNonIdempotent:         &disabled,
+		NonIdempotentBodyThreshold: 8,
+	})
+
+	// Large POST body: retries stay on the first endpoint
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 when failover is disabled, got %d", rec.Code)
+	}
+	if n := len(failing.seen()); n != 2 {
+		t.Errorf("Expected both attempts on the first endpoint, got %d", n)
+	}
+	if n := len(healthy.seen()); n != 0 {
+		t.Errorf("Expected no cross-endpoint retry, got %d requests on the second endpoint", n)
+	}
+
+	// Small bodies below the threshold may still fail over
+	req = httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected small request to fail over, got %d", rec.Code)
+	}
+}
+
+func TestAllowCrossEndpointRetry(t *testing.T) {
+	disabled := false
+	restricted := config.RetryConfig{RetryNonIdempotent: &disabled, NonIdempotentBodyThreshold: 100}
+
+	tests := []struct {
+		name     string
+		method   string
+		size     int
+		cfg      config.RetryConfig
+		expected bool
+	}{
+		{"Default allows everything", "POST", 1000, config.RetryConfig{}, true},
+		{"Large POST restricted", "POST", 1000, restricted, false},
+		{"Large PATCH restricted", "PATCH", 1000, restricted, false},
+		{"POST at threshold allowed", "POST", 100, restricted, true},
+		{"GET never restricted", "GET", 1000, restricted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowCrossEndpointRetry(tt.method, tt.size, tt.cfg); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
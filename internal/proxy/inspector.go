@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// Capture is one proxied request recorded for the WebUI inspector (webui.capture_enabled).
+// Header values that may carry credentials are masked before the capture is stored.
+type Capture struct {
+	ID                string            `json:"id"`        // Connection ID
+	RequestID         string            `json:"requestId"` // Request ID returned to the client as X-Request-Id
+	Time              time.Time         `json:"time"`
+	DurationMs        int64             `json:"durationMs"`
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	RequestHeaders    map[string]string `json:"requestHeaders"`
+	RequestBody       string            `json:"requestBody"` // Body as forwarded, after request rules
+	RequestTruncated  bool              `json:"requestTruncated"`
+	Endpoint          string            `json:"endpoint"` // Endpoint of the last attempt
+	Attempts          []CaptureAttempt  `json:"attempts"`
+	CoalescedWith     string            `json:"coalescedWith,omitempty"` // Connection whose response an identical request received instead of being forwarded
+	StatusCode        int               `json:"statusCode"`
+	ResponseHeaders   map[string]string `json:"responseHeaders"`
+	ResponseBody      string            `json:"responseBody"` // First bytes sent to the client, as sent (compressed bodies stay compressed)
+	ResponseTruncated bool              `json:"responseTruncated"`
+}
+
+// CaptureAttempt is one upstream attempt of a captured request
+type CaptureAttempt struct {
+	Endpoint     string `json:"endpoint"`
+	DurationMs   int64  `json:"durationMs"`
+	Outcome      string `json:"outcome"`
+	FailureClass string `json:"failureClass"` // Retry reason, empty for a successful attempt
+	StatusCode   int    `json:"statusCode"`
+	Streaming    bool   `json:"streaming"`
+	BackoffMs    int64  `json:"backoffMs"` // Wait before the next attempt
+}
+
+// captureStore keeps the most recent captures, oldest first
+type captureStore struct {
+	mu       sync.Mutex
+	captures []*Capture
+	seq      atomic.Uint64 // Numbers captures of requests without a connection ID
+}
+
+// add stores a capture, dropping the oldest ones beyond limit
+func (s *captureStore) add(c *Capture, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.captures = append(s.captures, c)
+	if excess := len(s.captures) - limit; excess > 0 {
+		s.captures = append([]*Capture(nil), s.captures[excess:]...)
+	}
+}
+
+// list returns the stored captures, newest first
+func (s *captureStore) list() []*Capture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	captures := make([]*Capture, 0, len(s.captures))
+	for i := len(s.captures) - 1; i >= 0; i-- {
+		captures = append(captures, s.captures[i])
+	}
+	return captures
+}
+
+// captureWriter passes a response through while keeping its status, headers and first bytes
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	header     http.Header // Headers as sent with the status line
+	body       bytes.Buffer
+	limit      int
+	truncated  bool
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.statusCode == 0 {
+		cw.statusCode = code
+		cw.header = cw.ResponseWriter.Header().Clone()
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.statusCode == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if room := cw.limit - cw.body.Len(); room < len(b) {
+		cw.truncated = true
+		if room > 0 {
+			cw.body.Write(b[:room])
+		}
+	} else {
+		cw.body.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush forwards flushes so streaming responses are captured without being held back
+func (cw *captureWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to hijack WebSockets
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// startCapture wraps the response writer when webui.capture_enabled is set, or returns nil
+func (h *Handler) startCapture(w http.ResponseWriter) *captureWriter {
+	if !h.config.WebUI.CaptureEnabled {
+		return nil
+	}
+	return &captureWriter{ResponseWriter: w, limit: h.config.WebUI.CaptureMaxBodyBytes()}
+}
+
+// finishCapture stores the capture of a finished request with its upstream attempts
+func (h *Handler) finishCapture(r *http.Request, cw *captureWriter, body []byte, start time.Time) {
+	limit := cw.limit
+	capture := &Capture{
+		Time:              start,
+		DurationMs:        time.Since(start).Milliseconds(),
+		Method:            r.Method,
+		Path:              r.URL.Path,
+		RequestHeaders:    maskHeaders(r.Header),
+		RequestTruncated:  len(body) > limit,
+		StatusCode:        cw.statusCode,
+		ResponseHeaders:   maskHeaders(cw.header),
+		ResponseBody:      cw.body.String(),
+		ResponseTruncated: cw.truncated,
+		Attempts:          []CaptureAttempt{},
+	}
+	if len(body) > limit {
+		body = body[:limit]
+	}
+	capture.RequestBody = string(body)
+
+	connID, _ := r.Context().Value("conn_id").(string)
+	capture.ID = connID
+	capture.RequestID = middleware.RequestIDFromContext(r.Context())
+	if connID == "" {
+		capture.ID = fmt.Sprintf("capture-%d", h.captures.seq.Add(1))
+	} else if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+		GetMetrics() *monitor.Metrics
+	}); ok {
+		if conn, found := mm.GetMetrics().GetConnection(connID); found {
+			capture.CoalescedWith = conn.CoalescedWith
+			for _, attempt := range conn.Attempts {
+				capture.Attempts = append(capture.Attempts, CaptureAttempt{
+					Endpoint:     attempt.Endpoint,
+					DurationMs:   attempt.EndTime.Sub(attempt.StartTime).Milliseconds(),
+					Outcome:      attempt.Outcome,
+					FailureClass: attempt.FailureClass(),
+					StatusCode:   attempt.StatusCode,
+					Streaming:    attempt.Streaming,
+					BackoffMs:    attempt.Backoff.Milliseconds(),
+				})
+			}
+		}
+	}
+	if n := len(capture.Attempts); n > 0 {
+		capture.Endpoint = capture.Attempts[n-1].Endpoint
+	}
+
+	h.captures.add(capture, h.config.WebUI.CaptureMaxRequests)
+}
+
+// Captures returns the requests recorded for the WebUI inspector, newest first
+func (h *Handler) Captures() []*Capture {
+	return h.captures.list()
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// requestModelContextKey carries the model named in the request body
+const requestModelContextKey = contextKey("request_model")
+
+// applyModelFilter reads the requested model so endpoint selection can skip endpoints
+// whose models list does not cover it. When no configured endpoint serves the model
+// the request is answered with 400 listing the available models, and it reports true.
+func (h *Handler) applyModelFilter(w http.ResponseWriter, r *http.Request, bodyBytes []byte) bool {
+	model := requestModel(bodyBytes)
+	if model == "" {
+		return false
+	}
+
+	served, available := h.modelServed(model)
+	if served {
+		*r = *r.WithContext(context.WithValue(r.Context(), requestModelContextKey, model))
+		return false
+	}
+	if len(available) == 0 {
+		// No endpoints configured; leave the error to the normal selection path
+		return false
+	}
+
+	slog.WarnContext(r.Context(), fmt.Sprintf("🧩 [模型过滤] 没有端点支持模型 %s，可用模型: %s", model, strings.Join(available, ", ")))
+	apierror.Write(w, http.StatusBadRequest, apierror.TypeModelUnsupported,
+		fmt.Sprintf("No endpoint serves model %q; available models: %s", model, strings.Join(available, ", ")))
+	return true
+}
+
+// modelServed reports whether any configured endpoint serves the model, and otherwise the
+// models the endpoints do serve
+func (h *Handler) modelServed(model string) (bool, []string) {
+	var available []string
+	for _, ep := range h.endpointManager.GetAllEndpoints() {
+		if ep.Config.SupportsModel(model) {
+			return true, nil
+		}
+		for _, m := range ep.Config.Models {
+			if !slices.Contains(available, m) {
+				available = append(available, m)
+			}
+		}
+	}
+	return false, available
+}
+
+// requestModel returns the model field of a JSON request body, or "" if there is none
+func requestModel(bodyBytes []byte) string {
+	if len(bodyBytes) == 0 {
+		return ""
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return ""
+	}
+	model, _ := bodyField(body, "model")
+	return model
+}
+
+// requestModelFromContext returns the model recorded by applyModelFilter
+func requestModelFromContext(ctx context.Context) string {
+	model, _ := ctx.Value(requestModelContextKey).(string)
+	return model
+}
+
+// filterByModel drops the endpoints that do not serve the request's model, recording them in
+// trace when trace is not nil
+func filterByModel(ctx context.Context, endpoints []*endpoint.Endpoint, trace *endpoint.SelectionTrace) []*endpoint.Endpoint {
+	model := requestModelFromContext(ctx)
+	if model == "" {
+		return endpoints
+	}
+	filtered := make([]*endpoint.Endpoint, 0, len(endpoints))
+	names := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.Config.SupportsModel(model) {
+			filtered = append(filtered, ep)
+			names = append(names, ep.Config.Name)
+		} else if trace != nil {
+			trace.Exclude(ep, fmt.Sprintf("does not serve model %s", model))
+		}
+	}
+	if len(filtered) != len(endpoints) {
+		slog.DebugContext(ctx, fmt.Sprintf("🧩 [模型过滤] 模型 %s 可用端点: %d/%d [%s]",
+			model, len(filtered), len(endpoints), strings.Join(names, ", ")))
+	}
+	return filtered
+}
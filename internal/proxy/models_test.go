@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestModelFilterSkipsRestrictedEndpoint(t *testing.T) {
+	haikuUpstream, haikuRecorder := newBodyRecorder(t)
+	generalUpstream, generalRecorder := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "haiku-only", URL: haikuUpstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Models: []string{"claude-3-haiku-*"}},
+		config.EndpointConfig{Name: "general", URL: generalUpstream.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	serve := func(model string) int {
+		rec := httptest.NewRecorder()
+		body := `{"model":"` + model + `","messages":[]}`
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(body)))
+		return rec.Code
+	}
+
+	if code := serve("claude-3-5-sonnet-20241022"); code != http.StatusOK {
+		t.Fatalf("Expected the sonnet request to succeed, got %d", code)
+	}
+	if hits, _, _ := haikuRecorder.last(); hits != 0 {
+		t.Errorf("Expected the sonnet request to skip the haiku-only endpoint, got %d hits", hits)
+	}
+	if hits, _, _ := generalRecorder.last(); hits != 1 {
+		t.Errorf("Expected the sonnet request on the general endpoint, got %d hits", hits)
+	}
+
+	// A matching model still goes to the higher priority endpoint
+	if code := serve("claude-3-haiku-20240307"); code != http.StatusOK {
+		t.Fatalf("Expected the haiku request to succeed, got %d", code)
+	}
+	if hits, _, _ := haikuRecorder.last(); hits != 1 {
+		t.Errorf("Expected the haiku request on the haiku-only endpoint, got %d hits", hits)
+	}
+}
+
+func TestModelFilterRejectsUnsupportedModel(t *testing.T) {
+	upstream, recorder := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "haiku", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Models: []string{"claude-3-haiku-20240307"}},
+		config.EndpointConfig{Name: "sonnet", URL: upstream.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Models: []string{"claude-3-5-sonnet-*"}})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-opus-20240229","messages":[]}`)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an unsupported model, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body apierror.Envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if body.Error.Type != apierror.TypeModelUnsupported {
+		t.Errorf("Expected error type %s, got %s", apierror.TypeModelUnsupported, body.Error.Type)
+	}
+	for _, model := range []string{"claude-3-haiku-20240307", "claude-3-5-sonnet-*"} {
+		if !strings.Contains(body.Error.Message, model) {
+			t.Errorf("Expected the error to list %s, got %q", model, body.Error.Message)
+		}
+	}
+	if hits, _, _ := recorder.last(); hits != 0 {
+		t.Errorf("Expected no upstream attempts, got %d", hits)
+	}
+}
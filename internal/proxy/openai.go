@@ -0,0 +1,624 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+)
+
+// OpenAI chat completions requests are translated to this Messages API path
+const (
+	openAIChatPath        = "/v1/chat/completions"
+	anthropicMessagesPath = "/v1/messages"
+)
+
+// openAIChatRequest holds the chat completions fields that are translated; all other
+// fields are dropped
+type openAIChatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	MaxTokens           *int            `json:"max_tokens"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	Stop                json.RawMessage `json:"stop"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	User string `json:"user"`
+}
+
+// openAITranslatedFields are the request fields openAIChatRequest maps
+var openAITranslatedFields = map[string]bool{
+	"model": true, "messages": true, "max_tokens": true, "max_completion_tokens": true, "temperature": true,
+	"top_p": true, "stop": true, "stream": true, "stream_options": true, "user": true,
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // String or array of content parts
+}
+
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Metadata      *anthropicMetadata `json:"metadata,omitempty"`
+}
+
+type anthropicMetadata struct {
+	UserID string `json:"user_id"`
+}
+
+type anthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"` // String, or []anthropicContentBlock when the message has images
+}
+
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// openAIRequestInfo is what the response translation needs to know about the request
+type openAIRequestInfo struct {
+	Model        string // Model name as requested by the client
+	Stream       bool
+	IncludeUsage bool // stream_options.include_usage: send a final chunk with usage
+}
+
+// translateOpenAIRequest converts a chat completions body to a Messages API body.
+// Fields and content that cannot be translated are dropped with a debug log.
+func translateOpenAIRequest(ctx context.Context, body []byte, cfg config.CompatConfig) ([]byte, openAIRequestInfo, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, openAIRequestInfo{}, fmt.Errorf("request body is not a JSON object: %w", err)
+	}
+	var req openAIChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, openAIRequestInfo{}, fmt.Errorf("invalid chat completions request: %w", err)
+	}
+	if req.Model == "" {
+		return nil, openAIRequestInfo{}, fmt.Errorf("model is required")
+	}
+
+	var dropped []string
+	for name := range fields {
+		if !openAITranslatedFields[name] {
+			dropped = append(dropped, name)
+		}
+	}
+
+	out := anthropicRequest{
+		Model:       cfg.MapModel(req.Model),
+		MaxTokens:   cfg.DefaultMaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stream:      req.Stream,
+	}
+	if req.MaxCompletionTokens != nil {
+		out.MaxTokens = *req.MaxCompletionTokens
+	} else if req.MaxTokens != nil {
+		out.MaxTokens = *req.MaxTokens
+	}
+	// OpenAI temperatures go up to 2, Anthropic's up to 1
+	if out.Temperature != nil && *out.Temperature > 1 {
+		clamped := 1.0
+		out.Temperature = &clamped
+		dropped = append(dropped, "temperature>1")
+	}
+	if req.User != "" {
+		out.Metadata = &anthropicMetadata{UserID: req.User}
+	}
+	if len(req.Stop) > 0 && string(req.Stop) != "null" {
+		var stop string
+		if err := json.Unmarshal(req.Stop, &stop); err == nil {
+			out.StopSequences = []string{stop}
+		} else if err := json.Unmarshal(req.Stop, &out.StopSequences); err != nil {
+			return nil, openAIRequestInfo{}, fmt.Errorf("stop must be a string or an array of strings")
+		}
+	}
+
+	var system []string
+	for i, msg := range req.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			text, _, skipped := translateOpenAIContent(msg.Content, false)
+			dropped = append(dropped, prefixed(fmt.Sprintf("messages[%d].", i), skipped)...)
+			if text != "" {
+				system = append(system, text)
+			}
+		case "user", "assistant":
+			text, blocks, skipped := translateOpenAIContent(msg.Content, msg.Role == "user")
+			dropped = append(dropped, prefixed(fmt.Sprintf("messages[%d].", i), skipped)...)
+			switch {
+			case blocks != nil:
+				out.Messages = append(out.Messages, anthropicMessage{Role: msg.Role, Content: blocks})
+			case text != "":
+				out.Messages = append(out.Messages, anthropicMessage{Role: msg.Role, Content: text})
+			default:
+				dropped = append(dropped, fmt.Sprintf("messages[%d] (no text content)", i))
+			}
+		default:
+			dropped = append(dropped, fmt.Sprintf("messages[%d] (role %s)", i, msg.Role))
+		}
+	}
+	if len(out.Messages) == 0 {
+		return nil, openAIRequestInfo{}, fmt.Errorf("messages must contain at least one user or assistant message")
+	}
+	out.System = strings.Join(system, "\n\n")
+
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		slog.DebugContext(ctx, fmt.Sprintf("🐛 [OpenAI兼容] 忽略不支持的字段: %s", strings.Join(dropped, ", ")))
+	}
+
+	translated, err := json.Marshal(out)
+	if err != nil {
+		return nil, openAIRequestInfo{}, err
+	}
+	info := openAIRequestInfo{Model: req.Model, Stream: req.Stream}
+	if req.StreamOptions != nil {
+		info.IncludeUsage = req.StreamOptions.IncludeUsage
+	}
+	return translated, info, nil
+}
+
+// translateOpenAIContent converts message content. Plain text (or text-only parts) is
+// returned as text; when images are allowed and present, all parts are returned as blocks.
+// It also returns the part types that were dropped.
+func translateOpenAIContent(raw json.RawMessage, allowImages bool) (string, []anthropicContentBlock, []string) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil, nil
+	}
+	var parts []openAIContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", nil, []string{"content"}
+	}
+
+	var texts, skipped []string
+	var blocks []anthropicContentBlock
+	hasImage := false
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			texts = append(texts, part.Text)
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: part.Text})
+		case part.Type == "image_url" && allowImages && part.ImageURL != nil:
+			source, ok := anthropicImage(part.ImageURL.URL)
+			if !ok {
+				skipped = append(skipped, "content(image_url)")
+				continue
+			}
+			hasImage = true
+			blocks = append(blocks, anthropicContentBlock{Type: "image", Source: source})
+		default:
+			skipped = append(skipped, fmt.Sprintf("content(%s)", part.Type))
+		}
+	}
+	if hasImage {
+		return "", blocks, skipped
+	}
+	return strings.Join(texts, "\n"), nil, skipped
+}
+
+// anthropicImage converts an image_url (a data: URL or an http(s) URL) to an image source
+func anthropicImage(imageURL string) (*anthropicImageSource, bool) {
+	if strings.HasPrefix(imageURL, "http://") || strings.HasPrefix(imageURL, "https://") {
+		return &anthropicImageSource{Type: "url", URL: imageURL}, true
+	}
+	// data:image/png;base64,....
+	header, data, found := strings.Cut(strings.TrimPrefix(imageURL, "data:"), ",")
+	if !found || !strings.HasPrefix(imageURL, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, false
+	}
+	return &anthropicImageSource{Type: "base64", MediaType: strings.TrimSuffix(header, ";base64"), Data: data}, true
+}
+
+func prefixed(prefix string, names []string) []string {
+	for i := range names {
+		names[i] = prefix + names[i]
+	}
+	return names
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicResponse struct {
+	ID      string `json:"id"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+type openAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int                   `json:"index"`
+	Message      *openAIMessageContent `json:"message,omitempty"`
+	Delta        *openAIMessageContent `json:"delta,omitempty"`
+	FinishReason *string               `json:"finish_reason"`
+}
+
+type openAIMessageContent struct {
+	Role    string  `json:"role,omitempty"`
+	Content *string `json:"content,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// newOpenAIUsage counts cached and cache-creation tokens as prompt tokens, as OpenAI does
+func newOpenAIUsage(u anthropicUsage) *openAIUsage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return &openAIUsage{PromptTokens: prompt, CompletionTokens: u.OutputTokens, TotalTokens: prompt + u.OutputTokens}
+}
+
+// openAIFinishReason maps an Anthropic stop reason to an OpenAI finish reason
+func openAIFinishReason(stopReason string) *string {
+	reason := "stop"
+	switch stopReason {
+	case "":
+		return nil
+	case "max_tokens":
+		reason = "length"
+	case "tool_use":
+		reason = "tool_calls"
+	case "refusal":
+		reason = "content_filter"
+	}
+	return &reason
+}
+
+// openAITranslator converts Messages API responses and stream events to chat completions
+type openAITranslator struct {
+	info    openAIRequestInfo
+	id      string
+	created int64
+	usage   anthropicUsage
+}
+
+func newOpenAITranslator(info openAIRequestInfo, now time.Time) *openAITranslator {
+	return &openAITranslator{info: info, created: now.Unix()}
+}
+
+// translateResponse converts a non-streaming Messages API response body
+func (t *openAITranslator) translateResponse(body []byte) ([]byte, error) {
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	content := text.String()
+	finish := openAIFinishReason(resp.StopReason)
+	if finish == nil {
+		finish = openAIFinishReason("end_turn")
+	}
+	return json.Marshal(openAIChatResponse{
+		ID:      "chatcmpl-" + resp.ID,
+		Object:  "chat.completion",
+		Created: t.created,
+		Model:   t.info.Model,
+		Choices: []openAIChoice{{
+			Message:      &openAIMessageContent{Role: "assistant", Content: &content},
+			FinishReason: finish,
+		}},
+		Usage: newOpenAIUsage(resp.Usage),
+	})
+}
+
+// chunk builds a chat.completion.chunk data line
+func (t *openAITranslator) chunk(choices []openAIChoice, usage *openAIUsage) string {
+	data, _ := json.Marshal(openAIChatResponse{
+		ID:      t.id,
+		Object:  "chat.completion.chunk",
+		Created: t.created,
+		Model:   t.info.Model,
+		Choices: choices,
+		Usage:   usage,
+	})
+	return "data: " + string(data) + "\n\n"
+}
+
+// translateEvent converts one Messages API stream event to chat completions stream output
+func (t *openAITranslator) translateEvent(event SSEEvent) string {
+	switch event.Event {
+	case "message_start":
+		var start struct {
+			Message struct {
+				ID    string         `json:"id"`
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+		}
+		json.Unmarshal([]byte(event.Data), &start)
+		t.id = "chatcmpl-" + start.Message.ID
+		t.usage = start.Message.Usage
+		empty := ""
+		return t.chunk([]openAIChoice{{Delta: &openAIMessageContent{Role: "assistant", Content: &empty}}}, nil)
+
+	case "content_block_delta":
+		var delta struct {
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if json.Unmarshal([]byte(event.Data), &delta) != nil || delta.Delta.Type != "text_delta" {
+			return ""
+		}
+		return t.chunk([]openAIChoice{{Delta: &openAIMessageContent{Content: &delta.Delta.Text}}}, nil)
+
+	case "message_delta":
+		var delta struct {
+			Delta struct {
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage *anthropicUsage `json:"usage"`
+		}
+		json.Unmarshal([]byte(event.Data), &delta)
+		if delta.Usage != nil {
+			t.usage.OutputTokens = delta.Usage.OutputTokens
+			if delta.Usage.InputTokens > 0 {
+				t.usage.InputTokens = delta.Usage.InputTokens
+			}
+		}
+		if finish := openAIFinishReason(delta.Delta.StopReason); finish != nil {
+			return t.chunk([]openAIChoice{{Delta: &openAIMessageContent{}, FinishReason: finish}}, nil)
+		}
+		return ""
+
+	case "message_stop":
+		out := ""
+		if t.info.IncludeUsage {
+			out = t.chunk([]openAIChoice{}, newOpenAIUsage(t.usage))
+		}
+		return out + "data: [DONE]\n\n"
+
+	case "error":
+		var envelope apierror.Envelope
+		if json.Unmarshal([]byte(event.Data), &envelope) != nil || envelope.Error.Message == "" {
+			envelope.Error = apierror.Detail{Type: "api_error", Message: event.Data}
+		}
+		data, _ := json.Marshal(map[string]apierror.Detail{"error": envelope.Error})
+		return "data: " + string(data) + "\n\n"
+
+	case "retry", "shutdown":
+		// Forwarder failover and shutdown notices become comments, which OpenAI clients ignore
+		return ": " + strings.ReplaceAll(event.Data, "\n", " ") + "\n\n"
+	}
+	return ""
+}
+
+// openAIWriteMode is how openAIResponseWriter treats the response body
+type openAIWriteMode int
+
+const (
+	openAIWritePassthrough openAIWriteMode = iota // Errors and anything else are forwarded unchanged
+	openAIWriteJSON                               // Buffered and translated when the handler returns
+	openAIWriteStream                             // Translated event by event
+)
+
+// openAIResponseWriter translates the Messages API response written by the handler
+// into a chat completions response
+type openAIResponseWriter struct {
+	http.ResponseWriter
+	ctx        context.Context
+	translator *openAITranslator
+	mode       openAIWriteMode
+	status     int
+	body       bytes.Buffer // JSON body
+	events     *SSEEventAssembler
+}
+
+func (o *openAIResponseWriter) WriteHeader(status int) {
+	if o.status != 0 {
+		return
+	}
+	o.status = status
+	contentType := o.Header().Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "text/event-stream"):
+		o.mode = openAIWriteStream
+		o.Header().Del("Content-Length")
+	case status < 300 && strings.Contains(contentType, "json"):
+		// Written by finish once the whole body is translated
+		o.mode = openAIWriteJSON
+		return
+	}
+	o.ResponseWriter.WriteHeader(status)
+}
+
+func (o *openAIResponseWriter) Write(p []byte) (int, error) {
+	if o.status == 0 {
+		o.WriteHeader(http.StatusOK)
+	}
+	switch o.mode {
+	case openAIWriteJSON:
+		return o.body.Write(p)
+	case openAIWriteStream:
+		// Heartbeats between events keep the client connection alive, so they are kept
+		if !o.events.Pending() && isSSEComment(p) {
+			return o.ResponseWriter.Write(p)
+		}
+		if err := o.translateEvents(o.events.Write(p)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return o.ResponseWriter.Write(p)
+}
+
+func (o *openAIResponseWriter) Flush() {
+	if flusher, ok := o.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// translateEvents writes the translation of the given events
+func (o *openAIResponseWriter) translateEvents(events []SSEEvent) error {
+	var out strings.Builder
+	for _, event := range events {
+		if event.Event == "" {
+			// Some upstreams only put the type in the data
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(event.Data), &typed)
+			event.Event = typed.Type
+		}
+		out.WriteString(o.translator.translateEvent(event))
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(o.ResponseWriter, out.String())
+	return err
+}
+
+// isSSEComment reports whether p only holds comment lines, such as a heartbeat
+func isSSEComment(p []byte) bool {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\r\n"), "\n") {
+		if !strings.HasPrefix(line, ":") {
+			return false
+		}
+	}
+	return len(p) > 0
+}
+
+// finish writes the translated JSON body, or the rest of a stream
+func (o *openAIResponseWriter) finish() {
+	switch o.mode {
+	case openAIWriteStream:
+		o.translateEvents(o.events.Flush())
+	case openAIWriteJSON:
+		body, err := o.translator.translateResponse(o.body.Bytes())
+		if err != nil {
+			slog.WarnContext(o.ctx, fmt.Sprintf("⚠️ [OpenAI兼容] 无法转换响应，原样返回: %v", err))
+			body = o.body.Bytes()
+		}
+		o.Header().Set("Content-Type", "application/json")
+		o.Header().Del("Content-Length")
+		o.ResponseWriter.WriteHeader(o.status)
+		o.ResponseWriter.Write(body)
+	}
+}
+
+// serveOpenAI rewrites a chat completions request into a Messages API request in place and
+// returns a writer translating the response back. finish must be called after the request
+// was handled; a nil writer means an error response was already written.
+func (h *Handler) serveOpenAI(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	ctx := r.Context()
+	body, oversized, err := readBody(r, h.config.Server.MaxRequestBodyBytes())
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.TypeInvalidRequest, "Failed to read request body")
+		return nil, nil
+	}
+	// The whole body is needed for the translation, so large bodies are never streamed
+	if oversized {
+		h.rejectLargeBody(w, r)
+		return nil, nil
+	}
+	r.Body.Close()
+
+	cfg := h.config.Compat
+	translated, info, err := translateOpenAIRequest(ctx, body, cfg)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.TypeInvalidRequest, err.Error())
+		return nil, nil
+	}
+	slog.DebugContext(ctx, fmt.Sprintf("🔀 [OpenAI兼容] %s 转换为 %s, 模型: %s, 流式: %v",
+		openAIChatPath, anthropicMessagesPath, info.Model, info.Stream))
+
+	// Point the request at the Messages API; the client's path is restored for logging afterwards
+	originalURL := r.URL
+	messagesURL := *r.URL
+	messagesURL.Path, messagesURL.RawPath = anthropicMessagesPath, ""
+	r.URL = &messagesURL
+	r.Body = io.NopCloser(bytes.NewReader(translated))
+	r.ContentLength = int64(len(translated))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Length", fmt.Sprint(len(translated)))
+	// Plain responses so they can be translated as they arrive
+	r.Header.Del("Accept-Encoding")
+	if r.Header.Get("anthropic-version") == "" {
+		r.Header.Set("anthropic-version", cfg.AnthropicVersion)
+	}
+	if info.Stream {
+		r.Header.Set("Accept", "text/event-stream")
+	}
+	for key := range r.Header {
+		if strings.HasPrefix(strings.ToLower(key), "openai-") {
+			r.Header.Del(key)
+		}
+	}
+
+	ow := &openAIResponseWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		translator:     newOpenAITranslator(info, time.Now()),
+		events:         NewSSEEventAssembler(),
+	}
+	return ow, func() {
+		ow.finish()
+		r.URL = originalURL
+	}
+}
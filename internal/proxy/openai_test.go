@@ -0,0 +1,296 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// openAIGoldenTime is the "created" time of the golden responses
+var openAIGoldenTime = time.Unix(1760000000, 0)
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "openai", name))
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	return data
+}
+
+// assertJSONEqual compares JSON documents regardless of formatting and key order
+func assertJSONEqual(t *testing.T, name string, want, got []byte) {
+	t.Helper()
+	var wantValue, gotValue interface{}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("%s: invalid expected JSON: %v", name, err)
+	}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("%s: invalid JSON %q: %v", name, got, err)
+	}
+	if !reflect.DeepEqual(wantValue, gotValue) {
+		t.Errorf("%s mismatch\nwant: %s\ngot:  %s", name, want, got)
+	}
+}
+
+func newOpenAITestCompat() config.CompatConfig {
+	return config.CompatConfig{
+		OpenAIEnabled:    true,
+		ModelMap:         map[string]string{"gpt-4o": "claude-sonnet-4-5"},
+		DefaultMaxTokens: 4096,
+		AnthropicVersion: "2023-06-01",
+	}
+}
+
+// writeStreamEvents feeds a stream to w one event at a time, as the passthrough handler does
+func writeStreamEvents(w http.ResponseWriter, stream []byte) {
+	for _, event := range strings.SplitAfter(string(stream), "\n\n") {
+		if event != "" {
+			w.Write([]byte(event))
+		}
+	}
+}
+
+func TestTranslateOpenAIRequestGolden(t *testing.T) {
+	got, info, err := translateOpenAIRequest(context.Background(), readGolden(t, "chat_request.json"), newOpenAITestCompat())
+	if err != nil {
+		t.Fatalf("Translation failed: %v", err)
+	}
+	assertJSONEqual(t, "messages_request.json", readGolden(t, "messages_request.json"), got)
+	if info.Model != "gpt-4o" || info.Stream || info.IncludeUsage {
+		t.Errorf("Unexpected request info: %+v", info)
+	}
+}
+
+func TestTranslateOpenAIRequestDefaults(t *testing.T) {
+	body := `{"model":"claude-3-5-haiku","messages":[{"role":"user","content":"hi"}],"stop":["a","b"],` +
+		`"max_completion_tokens":100,"max_tokens":50,"stream":true,"stream_options":{"include_usage":true}}`
+	got, info, err := translateOpenAIRequest(context.Background(), []byte(body), newOpenAITestCompat())
+	if err != nil {
+		t.Fatalf("Translation failed: %v", err)
+	}
+	want := `{"model":"claude-3-5-haiku","max_tokens":100,"messages":[{"role":"user","content":"hi"}],"stop_sequences":["a","b"],"stream":true}`
+	assertJSONEqual(t, "translated request", []byte(want), got)
+	if !info.Stream || !info.IncludeUsage {
+		t.Errorf("Expected a streaming request with usage, got %+v", info)
+	}
+
+	got, _, _ = translateOpenAIRequest(context.Background(), []byte(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`), newOpenAITestCompat())
+	if !strings.Contains(string(got), `"max_tokens":4096`) {
+		t.Errorf("Expected default max_tokens, got %s", got)
+	}
+
+	for _, invalid := range []string{
+		`not json`,
+		`{"messages":[{"role":"user","content":"hi"}]}`,
+		`{"model":"m","messages":[{"role":"system","content":"only a system prompt"}]}`,
+		`{"model":"m","messages":[{"role":"user","content":"hi"}],"stop":42}`,
+	} {
+		if _, _, err := translateOpenAIRequest(context.Background(), []byte(invalid), newOpenAITestCompat()); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestTranslateOpenAIResponseGolden(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ow := &openAIResponseWriter{
+		ResponseWriter: rec,
+		ctx:            context.Background(),
+		translator:     newOpenAITranslator(openAIRequestInfo{Model: "gpt-4o"}, openAIGoldenTime),
+		events:         NewSSEEventAssembler(),
+	}
+	ow.Header().Set("Content-Type", "application/json")
+	ow.Header().Set("Content-Length", "999")
+	ow.WriteHeader(http.StatusOK)
+	body := readGolden(t, "messages_response.json")
+	ow.Write(body[:10])
+	ow.Write(body[10:])
+	ow.finish()
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("Expected 200 without the upstream Content-Length, got %d %v", rec.Code, rec.Header())
+	}
+	assertJSONEqual(t, "chat_response.json", readGolden(t, "chat_response.json"), rec.Body.Bytes())
+}
+
+func TestTranslateOpenAIStreamGolden(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ow := &openAIResponseWriter{
+		ResponseWriter: rec,
+		ctx:            context.Background(),
+		translator:     newOpenAITranslator(openAIRequestInfo{Model: "gpt-4o", Stream: true, IncludeUsage: true}, openAIGoldenTime),
+		events:         NewSSEEventAssembler(),
+	}
+	ow.Header().Set("Content-Type", "text/event-stream")
+	ow.WriteHeader(http.StatusOK)
+	writeStreamEvents(ow, readGolden(t, "messages_stream.txt"))
+	ow.finish()
+
+	if got, want := rec.Body.String(), string(readGolden(t, "chat_stream.txt")); got != want {
+		t.Errorf("chat_stream.txt mismatch\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestTranslateOpenAIForwarderNotices(t *testing.T) {
+	translator := newOpenAITranslator(openAIRequestInfo{Model: "gpt-4o", Stream: true}, openAIGoldenTime)
+	for _, event := range []SSEEvent{
+		{Event: "retry", Data: "switching endpoint"},
+		{Event: "shutdown", Data: string(apierror.Body(apierror.TypeShuttingDown, "shutting down"))},
+	} {
+		got := translator.translateEvent(event)
+		if !strings.HasPrefix(got, ": ") || !strings.HasSuffix(got, "\n\n") {
+			t.Errorf("Expected the %s event as an SSE comment, got %q", event.Event, got)
+		}
+	}
+}
+
+// createdPattern matches the "created" time, which is the current time outside the golden tests
+var createdPattern = regexp.MustCompile(`"created":\d+`)
+
+// serveOpenAITest sends a chat completions request and returns the response and its token usage
+func serveOpenAITest(t *testing.T, passthrough bool, upstream http.HandlerFunc, body []byte) (*httptest.ResponseRecorder, monitor.TokenUsage) {
+	t.Helper()
+	handler, mm := newPassthroughEnv(t, upstream, func(cfg *config.Config) {
+		cfg.Compat = newOpenAITestCompat()
+		cfg.Streaming.PassthroughMode = passthrough
+	})
+	connID := mm.GetMetrics().RecordRequest("unknown", "test", "127.0.0.1", "test", "POST", openAIChatPath)
+	req := httptest.NewRequest("POST", openAIChatPath, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer client-key")
+	req.Header.Set("OpenAI-Organization", "org-1")
+	req = req.WithContext(context.WithValue(req.Context(), "conn_id", connID))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if req.URL.Path != openAIChatPath {
+		t.Errorf("Expected the client path to be restored for logging, got %s", req.URL.Path)
+	}
+	conn := mm.GetMetrics().GetMetrics().ActiveConnections[connID]
+	if conn == nil {
+		return rec, monitor.TokenUsage{}
+	}
+	return rec, conn.TokenUsage
+}
+
+// checkMessagesRequest verifies the request the upstream receives from the translation
+func checkMessagesRequest(t *testing.T, r *http.Request, want []byte) {
+	t.Helper()
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path != anthropicMessagesPath || r.Header.Get("anthropic-version") != "2023-06-01" {
+		t.Errorf("Expected a Messages API request, got %s with version %q", r.URL.Path, r.Header.Get("anthropic-version"))
+	}
+	if r.Header.Get("OpenAI-Organization") != "" || r.Header.Get("Authorization") == "Bearer client-key" {
+		t.Errorf("Expected OpenAI and client credential headers to be dropped, got %v", r.Header)
+	}
+	assertJSONEqual(t, "upstream request", want, body)
+}
+
+func TestOpenAICompatNonStreaming(t *testing.T) {
+	rec, usage := serveOpenAITest(t, false, func(w http.ResponseWriter, r *http.Request) {
+		checkMessagesRequest(t, r, readGolden(t, "messages_request.json"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(readGolden(t, "messages_response.json"))
+	}, readGolden(t, "chat_request.json"))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected a 200 JSON response, got %d %v", rec.Code, rec.Header())
+	}
+	got := createdPattern.ReplaceAll(rec.Body.Bytes(), []byte(`"created":1760000000`))
+	assertJSONEqual(t, "chat_response.json", readGolden(t, "chat_response.json"), got)
+
+	want := monitor.TokenUsage{InputTokens: 120, OutputTokens: 512, CacheCreationTokens: 10, CacheReadTokens: 30}
+	if usage != want {
+		t.Errorf("Expected Anthropic-side usage %+v, got %+v", want, usage)
+	}
+}
+
+func TestOpenAICompatStreaming(t *testing.T) {
+	request := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"stream":true,"stream_options":{"include_usage":true}}`)
+	wantRequest := []byte(`{"model":"claude-sonnet-4-5","max_tokens":4096,"messages":[{"role":"user","content":"hi"}],"stream":true}`)
+	stream := readGolden(t, "messages_stream.txt")
+
+	// Heartbeats depend on timing, so only events are compared end to end
+	var wantEvents []string
+	for _, block := range strings.SplitAfter(string(readGolden(t, "chat_stream.txt")), "\n\n") {
+		if block != "" && !strings.HasPrefix(block, ":") {
+			wantEvents = append(wantEvents, block)
+		}
+	}
+
+	for _, passthrough := range []bool{true, false} {
+		rec, usage := serveOpenAITest(t, passthrough, func(w http.ResponseWriter, r *http.Request) {
+			checkMessagesRequest(t, r, wantRequest)
+			writeInPieces(w, string(stream), 7)
+		}, request)
+
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Type"), "text/event-stream") {
+			t.Fatalf("passthrough=%v: expected a 200 event stream, got %d %v", passthrough, rec.Code, rec.Header())
+		}
+		var gotEvents []string
+		for _, block := range strings.SplitAfter(createdPattern.ReplaceAllString(rec.Body.String(), `"created":1760000000`), "\n\n") {
+			if block != "" && !strings.HasPrefix(block, ":") {
+				gotEvents = append(gotEvents, block)
+			}
+		}
+		if strings.Join(gotEvents, "") != strings.Join(wantEvents, "") {
+			t.Errorf("passthrough=%v: stream mismatch\nwant:\n%s\ngot:\n%s", passthrough, strings.Join(wantEvents, ""), strings.Join(gotEvents, ""))
+		}
+
+		want := monitor.TokenUsage{InputTokens: 25, OutputTokens: 42, CacheReadTokens: 7}
+		if usage != want {
+			t.Errorf("passthrough=%v: expected Anthropic-side usage %+v, got %+v", passthrough, want, usage)
+		}
+	}
+}
+
+func TestOpenAICompatErrors(t *testing.T) {
+	upstreamCalled := false
+	rec, _ := serveOpenAITest(t, false, func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalled = true
+	}, []byte(`{"model":"gpt-4o","messages":[]}`))
+	if rec.Code != http.StatusBadRequest || !apierror.IsLocal(rec.Header()) || upstreamCalled {
+		t.Errorf("Expected a local 400 without an upstream request, got %d %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(rec.Body.String(), apierror.TypeInvalidRequest) {
+		t.Errorf("Expected a %s error, got %s", apierror.TypeInvalidRequest, rec.Body.String())
+	}
+
+	// Upstream errors use the Anthropic error envelope, which OpenAI clients read as error.message
+	upstreamError := `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`
+	rec, _ = serveOpenAITest(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(upstreamError))
+	}, []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if rec.Code != http.StatusUnauthorized || rec.Body.String() != upstreamError {
+		t.Errorf("Expected the upstream error unchanged, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOpenAICompatDisabled(t *testing.T) {
+	var path string
+	handler, _ := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{}`))
+	}, nil)
+	req := httptest.NewRequest("POST", openAIChatPath, strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if path != openAIChatPath {
+		t.Errorf("Expected the request to be forwarded unchanged when compat is disabled, got %s", path)
+	}
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// Inbound control headers that let a client override endpoint selection for one request.
+// They are only honored with server.allow_routing_overrides and never forwarded upstream.
+const (
+	HeaderForwarderEndpoint = "X-Forwarder-Endpoint"
+	HeaderForwarderGroup    = "X-Forwarder-Group"
+	HeaderForwarderForce    = "X-Forwarder-Force"
+)
+
+// pinnedEndpointContextKey carries the endpoint a request was pinned to
+const pinnedEndpointContextKey = contextKey("pinned_endpoint")
+
+// applyRoutingOverrides handles the X-Forwarder-* control headers. It strips them from the
+// request, pins the request to an endpoint or restricts it to a group, and reports whether
+// the request was already answered with an error.
+func (h *Handler) applyRoutingOverrides(w http.ResponseWriter, r *http.Request) bool {
+	endpointName := strings.TrimSpace(r.Header.Get(HeaderForwarderEndpoint))
+	groupName := strings.TrimSpace(r.Header.Get(HeaderForwarderGroup))
+	force := strings.EqualFold(strings.TrimSpace(r.Header.Get(HeaderForwarderForce)), "true")
+
+	_, hasEndpoint := r.Header[http.CanonicalHeaderKey(HeaderForwarderEndpoint)]
+	_, hasGroup := r.Header[http.CanonicalHeaderKey(HeaderForwarderGroup)]
+	_, hasForce := r.Header[http.CanonicalHeaderKey(HeaderForwarderForce)]
+	if !hasEndpoint && !hasGroup && !hasForce {
+		return false
+	}
+	r.Header.Del(HeaderForwarderEndpoint)
+	r.Header.Del(HeaderForwarderGroup)
+	r.Header.Del(HeaderForwarderForce)
+
+	ctx := r.Context()
+	if !h.config.Server.AllowRoutingOverrides {
+		slog.WarnContext(ctx, fmt.Sprintf("⛔ [路由覆盖] 未启用 server.allow_routing_overrides，拒绝带有路由控制头的请求: %s %s",
+			r.Method, r.URL.Path))
+		apierror.Write(w, http.StatusForbidden, apierror.TypeOverrideNotAllowed, "Routing override headers are not allowed on this forwarder")
+		return true
+	}
+
+	if endpointName != "" {
+		ep := h.endpointManager.GetEndpointByNameAny(endpointName)
+		if ep == nil {
+			apierror.Write(w, http.StatusNotFound, apierror.TypeOverrideNotFound, fmt.Sprintf("Endpoint %q does not exist", endpointName))
+			return true
+		}
+		if groupName != "" && endpointGroup(ep) != groupName {
+			apierror.Write(w, http.StatusBadRequest, apierror.TypeOverrideInvalid,
+				fmt.Sprintf("Endpoint %q is not in group %q", endpointName, groupName))
+			return true
+		}
+		if !force && (!ep.IsHealthy() || ep.IsDisabled()) {
+			apierror.Write(w, http.StatusNotFound, apierror.TypeOverrideNotFound,
+				fmt.Sprintf("Endpoint %q is not available (unhealthy or in maintenance); send %s: true to use it anyway", endpointName, HeaderForwarderForce))
+			return true
+		}
+
+		slog.InfoContext(ctx, fmt.Sprintf("📌 [路由覆盖] 请求已固定到端点: %s (组: %s, 强制: %v) - %s %s",
+			endpointName, endpointGroup(ep), force, r.Method, r.URL.Path))
+		h.recordPinned(ctx)
+		*r = *r.WithContext(context.WithValue(ctx, pinnedEndpointContextKey, ep))
+		return false
+	}
+
+	if groupName != "" {
+		if !h.groupExists(groupName) {
+			apierror.Write(w, http.StatusNotFound, apierror.TypeOverrideNotFound, fmt.Sprintf("Group %q does not exist", groupName))
+			return true
+		}
+		slog.InfoContext(ctx, fmt.Sprintf("📌 [路由覆盖] 请求限定到组: %s - %s %s", groupName, r.Method, r.URL.Path))
+		*r = *r.WithContext(context.WithValue(ctx, routedGroupContextKey, groupName))
+	}
+	return false
+}
+
+// recordPinned marks the request's connection as pinned to an endpoint
+func (h *Handler) recordPinned(ctx context.Context) {
+	connID, _ := ctx.Value("conn_id").(string)
+	if connID == "" {
+		return
+	}
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+		RecordPinned(connID string)
+	}); ok {
+		mm.RecordPinned(connID)
+	}
+}
+
+// groupExists reports whether any configured endpoint belongs to the group
+func (h *Handler) groupExists(name string) bool {
+	for _, ep := range h.endpointManager.GetAllEndpoints() {
+		if endpointGroup(ep) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointGroup returns the endpoint's group name, "Default" when none is configured
+func endpointGroup(ep *endpoint.Endpoint) string {
+	if ep.Config.Group == "" {
+		return "Default"
+	}
+	return ep.Config.Group
+}
+
+// pinnedEndpointFromContext returns the endpoint the request was pinned to, if any
+func pinnedEndpointFromContext(ctx context.Context) *endpoint.Endpoint {
+	ep, _ := ctx.Value(pinnedEndpointContextKey).(*endpoint.Endpoint)
+	return ep
+}
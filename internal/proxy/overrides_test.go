@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// overrideUpstream counts proxied requests and remembers whether control headers leaked upstream
+type overrideUpstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	hits   int
+	leaked bool
+}
+
+func newOverrideUpstream(t *testing.T, healthy bool) *overrideUpstream {
+	t.Helper()
+	up := &overrideUpstream{}
+	up.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		up.mu.Lock()
+		up.hits++
+		for _, header := range []string{HeaderForwarderEndpoint, HeaderForwarderGroup, HeaderForwarderForce} {
+			if r.Header.Get(header) != "" {
+				up.leaked = true
+			}
+		}
+		up.mu.Unlock()
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(up.Close)
+	return up
+}
+
+func (u *overrideUpstream) stats() (int, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.hits, u.leaked
+}
+
+type overrideEnv struct {
+	handler *Handler
+	manager *endpoint.Manager
+	mm      *middleware.MonitoringMiddleware
+	primary *overrideUpstream
+	backup  *overrideUpstream
+	down    *overrideUpstream
+}
+
+func newOverrideEnv(t *testing.T, allow bool) *overrideEnv {
+	t.Helper()
+	env := &overrideEnv{
+		primary: newOverrideUpstream(t, true),
+		backup:  newOverrideUpstream(t, true),
+		down:    newOverrideUpstream(t, false),
+	}
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: env.primary.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "backup", URL: env.backup.URL, Priority: 1, Group: "standby", GroupPriority: 2, Timeout: time.Second},
+		config.EndpointConfig{Name: "down", URL: env.down.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	cfg.Server.AllowRoutingOverrides = allow
+	env.manager = endpoint.NewManager(cfg)
+	env.mm = middleware.NewMonitoringMiddleware(env.manager)
+	env.handler = NewHandler(env.manager, cfg)
+	env.handler.SetMonitoringMiddleware(env.mm)
+	return env
+}
+
+// serve sends a request with the given headers and returns the response and its connection ID
+func (env *overrideEnv) serve(headers map[string]string) (*httptest.ResponseRecorder, string) {
+	connID := env.mm.GetMetrics().RecordRequest("unknown", "test", "127.0.0.1", "test", "POST", "/v1/messages")
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-5-haiku"}`))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "conn_id", connID))
+	rec := httptest.NewRecorder()
+	env.handler.ServeHTTP(rec, req)
+	return rec, connID
+}
+
+func (env *overrideEnv) pinned(connID string) bool {
+	conn := env.mm.GetMetrics().GetMetrics().ActiveConnections[connID]
+	return conn != nil && conn.Pinned
+}
+
+func errorType(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Type != "error" {
+		t.Fatalf("Expected a JSON error body, got %q", rec.Body.String())
+	}
+	return body.Error.Type
+}
+
+func TestRoutingOverridePinHealthy(t *testing.T) {
+	env := newOverrideEnv(t, true)
+
+	rec, connID := env.serve(map[string]string{HeaderForwarderEndpoint: "backup"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected pinned request to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits, leaked := env.backup.stats(); hits != 1 || leaked {
+		t.Errorf("Expected one request on the pinned endpoint without control headers, got %d (leaked: %v)", hits, leaked)
+	}
+	if hits, _ := env.primary.stats(); hits != 0 {
+		t.Errorf("Expected the preferred endpoint to be bypassed, got %d hits", hits)
+	}
+	if !env.pinned(connID) {
+		t.Error("Expected the connection to be recorded as pinned")
+	}
+
+	// A group override restricts selection to that group without pinning
+	rec, connID = env.serve(map[string]string{HeaderForwarderGroup: "standby"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected group override to succeed, got %d", rec.Code)
+	}
+	if hits, _ := env.backup.stats(); hits != 2 {
+		t.Errorf("Expected the request in group standby, got %d hits on backup", hits)
+	}
+	if env.pinned(connID) {
+		t.Error("Expected a group override not to mark the connection as pinned")
+	}
+
+	// Unknown groups and mismatched endpoint/group pairs are rejected
+	if rec, _ := env.serve(map[string]string{HeaderForwarderGroup: "missing"}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown group, got %d", rec.Code)
+	}
+	if rec, _ := env.serve(map[string]string{HeaderForwarderEndpoint: "backup", HeaderForwarderGroup: "main"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an endpoint outside the requested group, got %d", rec.Code)
+	}
+}
+
+func TestRoutingOverridePinUnhealthy(t *testing.T) {
+	env := newOverrideEnv(t, true)
+	env.manager.Start()
+	defer env.manager.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for env.manager.GetEndpointByNameAny("down").IsHealthy() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if env.manager.GetEndpointByNameAny("down").IsHealthy() {
+		t.Fatal("Expected the health check to mark the endpoint unhealthy")
+	}
+
+	rec, _ := env.serve(map[string]string{HeaderForwarderEndpoint: "down"})
+	if rec.Code != http.StatusNotFound || errorType(t, rec) != apierror.TypeOverrideNotFound {
+		t.Fatalf("Expected 404 forwarder_override_not_found for an unhealthy endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits, _ := env.down.stats(); hits != 0 {
+		t.Errorf("Expected no request on the unhealthy endpoint, got %d", hits)
+	}
+
+	// X-Forwarder-Force sends it anyway
+	rec, connID := env.serve(map[string]string{HeaderForwarderEndpoint: "down", HeaderForwarderForce: "true"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected forced request to reach the endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits, leaked := env.down.stats(); hits != 1 || leaked {
+		t.Errorf("Expected one forced request without control headers, got %d (leaked: %v)", hits, leaked)
+	}
+	if !env.pinned(connID) {
+		t.Error("Expected the forced connection to be recorded as pinned")
+	}
+}
+
+func TestRoutingOverridePinNonexistent(t *testing.T) {
+	env := newOverrideEnv(t, true)
+
+	for _, headers := range []map[string]string{
+		{HeaderForwarderEndpoint: "nope"},
+		{HeaderForwarderEndpoint: "nope", HeaderForwarderForce: "true"},
+	} {
+		rec, connID := env.serve(headers)
+		if rec.Code != http.StatusNotFound || errorType(t, rec) != apierror.TypeOverrideNotFound {
+			t.Errorf("Expected 404 forwarder_override_not_found for %v, got %d: %s", headers, rec.Code, rec.Body.String())
+		}
+		if env.pinned(connID) {
+			t.Errorf("Expected a rejected request not to be marked as pinned")
+		}
+	}
+	if hits, _ := env.primary.stats(); hits != 0 {
+		t.Errorf("Expected no upstream requests, got %d", hits)
+	}
+}
+
+func TestRoutingOverrideDisabled(t *testing.T) {
+	env := newOverrideEnv(t, false)
+
+	rec, _ := env.serve(map[string]string{HeaderForwarderEndpoint: "backup"})
+	if rec.Code != http.StatusForbidden || errorType(t, rec) != apierror.TypeOverrideNotAllowed {
+		t.Fatalf("Expected 403 forwarder_override_not_allowed with overrides disabled, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits, _ := env.backup.stats(); hits != 0 {
+		t.Errorf("Expected the request not to be forwarded, got %d hits", hits)
+	}
+
+	// Requests without control headers are unaffected
+	if rec, _ := env.serve(nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected a normal request to succeed, got %d", rec.Code)
+	}
+}
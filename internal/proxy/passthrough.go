@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// passthroughBufferSize is the read size of the passthrough loop
+const passthroughBufferSize = 32 * 1024
+
+// maxUsageLineSize bounds the line the usage tee keeps while waiting for its end; longer
+// lines cannot be usage events and are skipped
+const maxUsageLineSize = 64 * 1024
+
+// streamResponsePassthrough copies the upstream stream to the client chunk by chunk, flushing
+// after every read. Bytes are forwarded exactly as received; only data lines that mention
+// usage are inspected for token counts, and only when monitoring needs them.
+func (h *Handler) streamResponsePassthrough(ctx context.Context, w http.ResponseWriter, resp *http.Response, flusher http.Flusher, connID, endpointName string) error {
+	slog.InfoContext(ctx, fmt.Sprintf("🚀 [直通流传输] 开始转发 - 状态码: %d, 内容类型: %s",
+		resp.StatusCode, resp.Header.Get("Content-Type")))
+
+	for key, values := range h.responseHeaders(resp.Header) {
+		// Skip hop-by-hop headers and headers we set manually
+		if key == "Connection" || key == "Transfer-Encoding" || key == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	w.Header().Set(apierror.HeaderUpstream, endpointName)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(resp.StatusCode)
+	flusher.Flush()
+
+	var tee *usageTee
+	if h.config.Monitoring.StreamTokenParsing() && h.retryHandler.monitoringMiddleware != nil {
+		tee = newUsageTee(h.responseFormat(endpointName))
+	}
+
+	out := &passthroughWriter{w: w, flusher: flusher, newlines: 2}
+	out.touch()
+	h.streams.add(out)
+	defer h.streams.remove(out)
+	stopHeartbeat := h.startPassthroughHeartbeat(out)
+	defer stopHeartbeat()
+
+	buffer := make([]byte, passthroughBufferSize)
+	var bytesTransferred int64
+	for {
+		n, err := resp.Body.Read(buffer)
+		if n > 0 {
+			if writeErr := out.write(buffer[:n]); writeErr != nil {
+				slog.ErrorContext(ctx, fmt.Sprintf("❌ [直通流传输] 写入客户端失败 - 错误: %s, 已传输: %d字节",
+					writeErr.Error(), bytesTransferred))
+				return fmt.Errorf("error writing to client: %w", writeErr)
+			}
+			bytesTransferred += int64(n)
+			if tee != nil {
+				if tokenUsage := tee.Write(buffer[:n]); tokenUsage != nil {
+					h.recordStreamTokenUsage(ctx, connID, endpointName, tokenUsage)
+				}
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if tee != nil {
+					if tokenUsage := tee.Finish(); tokenUsage != nil {
+						h.recordStreamTokenUsage(ctx, connID, endpointName, tokenUsage)
+					}
+				}
+				slog.InfoContext(ctx, fmt.Sprintf("✅ [直通流传输] 传输完成 - 总计: %d字节", bytesTransferred))
+				return nil
+			}
+			slog.ErrorContext(ctx, fmt.Sprintf("❌ [直通流传输] 读取错误 - 错误: %s, 已传输: %d字节",
+				err.Error(), bytesTransferred))
+			return &streamInterruptedError{err}
+		}
+	}
+}
+
+// startPassthroughHeartbeat writes an SSE comment whenever the stream has been idle for
+// streaming.max_idle_time, checked every streaming.heartbeat_interval. It returns a stop function.
+func (h *Handler) startPassthroughHeartbeat(out *passthroughWriter) func() {
+	interval := h.config.Streaming.HeartbeatInterval
+	maxIdle := h.config.Streaming.MaxIdleTime
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if out.idle() >= maxIdle {
+					out.heartbeat()
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// passthroughWriter serializes writes from the copy loop and the heartbeat and remembers
+// when the client last received data
+type passthroughWriter struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	lastWrite atomic.Int64 // Unix nanoseconds
+	newlines  int          // Line breaks at the end of the forwarded bytes; two or more end an event
+	notice    []byte       // Event waiting for the next event boundary
+	notified  bool         // An event was handed to notify
+}
+
+func (p *passthroughWriter) write(b []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.writeLocked(b); err != nil {
+		return err
+	}
+	if p.notice != nil && p.atBoundary() {
+		notice := p.notice
+		p.notice = nil
+		return p.writeLocked(notice)
+	}
+	return nil
+}
+
+func (p *passthroughWriter) writeLocked(b []byte) error {
+	if _, err := p.w.Write(b); err != nil {
+		return err
+	}
+	p.flusher.Flush()
+	p.touch()
+	p.countNewlines(b)
+	return nil
+}
+
+// notify sends an event of the forwarder's own. It is written between two upstream events,
+// right away when the stream is at an event boundary or else after the event in progress.
+func (p *passthroughWriter) notify(event []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.notified {
+		return
+	}
+	p.notified = true
+	if p.atBoundary() {
+		// A failed write surfaces in the copy loop's next write
+		p.writeLocked(event)
+		return
+	}
+	p.notice = event
+}
+
+// heartbeat sends a keep-alive comment. An upstream that stalls in the middle of an event gets
+// none, since a comment there would be spliced into its data; the next tick tries again.
+func (p *passthroughWriter) heartbeat() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.atBoundary() {
+		return
+	}
+	// A failed write surfaces in the copy loop's next write
+	p.writeLocked([]byte(fmt.Sprintf(": heartbeat %s\n\n", time.Now().Format(time.RFC3339))))
+}
+
+// countNewlines updates the trailing line break count after writing b
+func (p *passthroughWriter) countNewlines(b []byte) {
+	count := 0
+	for i := len(b) - 1; i >= 0; i-- {
+		switch b[i] {
+		case '\n':
+			count++
+		case '\r':
+		default:
+			p.newlines = count
+			return
+		}
+	}
+	p.newlines += count
+}
+
+func (p *passthroughWriter) atBoundary() bool {
+	return p.newlines >= 2
+}
+
+func (p *passthroughWriter) touch() {
+	p.lastWrite.Store(time.Now().UnixNano())
+}
+
+func (p *passthroughWriter) idle() time.Duration {
+	return time.Duration(time.Now().UnixNano() - p.lastWrite.Load())
+}
+
+// usageTee watches forwarded stream bytes for token usage. It splits lines itself and hands
+// only "data:" lines containing "usage" to the token parser, so the bulk of a generation
+// (content deltas) is never converted to strings or decoded.
+type usageTee struct {
+	parser *TokenParser
+	line   []byte
+	skip   bool // Current line exceeded maxUsageLineSize
+}
+
+var (
+	dataPrefix  = []byte("data:")
+	usageMarker = []byte(`"usage"`)
+)
+
+func newUsageTee(format string) *usageTee {
+	return &usageTee{parser: NewTokenParserFor(format)}
+}
+
+// Write inspects a chunk of the stream and returns newly found token usage, if any
+func (t *usageTee) Write(chunk []byte) *monitor.TokenUsage {
+	var increment *monitor.TokenUsage
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			t.appendLine(chunk)
+			break
+		}
+		t.appendLine(chunk[:i])
+		chunk = chunk[i+1:]
+		increment = addUsage(increment, t.endLine())
+	}
+	return increment
+}
+
+// Finish inspects a last line that had no trailing newline
+func (t *usageTee) Finish() *monitor.TokenUsage {
+	return t.endLine()
+}
+
+func (t *usageTee) appendLine(b []byte) {
+	if t.skip {
+		return
+	}
+	if len(t.line)+len(b) > maxUsageLineSize {
+		t.line = t.line[:0]
+		t.skip = true
+		return
+	}
+	t.line = append(t.line, b...)
+}
+
+// endLine parses the pending line if it is a data line mentioning usage
+func (t *usageTee) endLine() *monitor.TokenUsage {
+	line := bytes.TrimRight(t.line, "\r")
+	skipped := t.skip
+	t.line = t.line[:0]
+	t.skip = false
+	if skipped || !bytes.HasPrefix(line, dataPrefix) || !bytes.Contains(line, usageMarker) {
+		return nil
+	}
+	data := bytes.TrimPrefix(line[len(dataPrefix):], []byte(" "))
+	// The event type comes from the payload's own type field
+	return t.parser.ParseEvent(SSEEvent{Data: string(data)})
+}
+
+// addUsage adds usage to a running increment, allocating it on first use
+func addUsage(total, usage *monitor.TokenUsage) *monitor.TokenUsage {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		total = &monitor.TokenUsage{}
+	}
+	total.InputTokens += usage.InputTokens
+	total.OutputTokens += usage.OutputTokens
+	total.CacheCreationTokens += usage.CacheCreationTokens
+	total.CacheReadTokens += usage.CacheReadTokens
+	return total
+}
@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// passthroughTestStream is a Claude stream with multi-byte text and usage in message_start and message_delta
+const passthroughTestStream = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":25,"cache_read_input_tokens":7,"output_tokens":1}}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你好，世界 🌍"}}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+// newPassthroughEnv serves the upstream handler behind a passthrough-mode forwarder
+func newPassthroughEnv(t *testing.T, upstream http.HandlerFunc, tweak func(*config.Config)) (*Handler, *middleware.MonitoringMiddleware) {
+	t.Helper()
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: server.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second})
+	cfg.Streaming = config.StreamingConfig{PassthroughMode: true, HeartbeatInterval: time.Minute, MaxIdleTime: time.Minute}
+	if tweak != nil {
+		tweak(cfg)
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(manager)
+	handler := NewHandler(manager, cfg)
+	handler.SetMonitoringMiddleware(mm)
+	return handler, mm
+}
+
+// serveStream sends a streaming request and returns the response and its connection's token usage
+func serveStream(handler *Handler, mm *middleware.MonitoringMiddleware) (*httptest.ResponseRecorder, monitor.TokenUsage) {
+	connID := mm.GetMetrics().RecordRequest("unknown", "test", "127.0.0.1", "test", "POST", "/v1/messages")
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-5-haiku","stream":true}`))
+	req.Header.Set("Accept", "text/event-stream")
+	req = req.WithContext(context.WithValue(req.Context(), "conn_id", connID))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	conn := mm.GetMetrics().GetMetrics().ActiveConnections[connID]
+	if conn == nil {
+		return rec, monitor.TokenUsage{}
+	}
+	return rec, conn.TokenUsage
+}
+
+// writeInPieces writes the stream in small flushed pieces that split lines and UTF-8 sequences
+func writeInPieces(w http.ResponseWriter, stream string, size int) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for start := 0; start < len(stream); start += size {
+		end := start + size
+		if end > len(stream) {
+			end = len(stream)
+		}
+		w.Write([]byte(stream[start:end]))
+		w.(http.Flusher).Flush()
+	}
+}
+
+func TestPassthroughForwardsBytesExactly(t *testing.T) {
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		writeInPieces(w, passthroughTestStream, 7)
+	}, nil)
+
+	rec, usage := serveStream(handler, mm)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected a 200 event stream, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec.Body.String() != passthroughTestStream {
+		t.Errorf("Expected the stream to be forwarded byte for byte, got:\n%q", rec.Body.String())
+	}
+	want := monitor.TokenUsage{InputTokens: 25, OutputTokens: 42, CacheReadTokens: 7}
+	if usage != want {
+		t.Errorf("Expected token usage %+v, got %+v", want, usage)
+	}
+}
+
+func TestOpenAIFormatTokenUsage(t *testing.T) {
+	for _, passthrough := range []bool{true, false} {
+		handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+			writeInPieces(w, openAITestStream, 9)
+		}, func(cfg *config.Config) {
+			cfg.Endpoints[0].APIFormat = config.APIFormatOpenAI
+			cfg.Streaming.PassthroughMode = passthrough
+		})
+
+		rec, usage := serveStream(handler, mm)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 (passthrough: %v), got %d", passthrough, rec.Code)
+		}
+		want := monitor.TokenUsage{InputTokens: 100, OutputTokens: 35, CacheReadTokens: 20}
+		if usage != want {
+			t.Errorf("Expected token usage %+v (passthrough: %v), got %+v", want, passthrough, usage)
+		}
+	}
+}
+
+func TestPassthroughTokenParsingDisabled(t *testing.T) {
+	disabled := false
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		writeInPieces(w, passthroughTestStream, 64)
+	}, func(cfg *config.Config) {
+		cfg.Monitoring.ParseStreamTokens = &disabled
+	})
+
+	rec, usage := serveStream(handler, mm)
+	if rec.Body.String() != passthroughTestStream {
+		t.Errorf("Expected the stream to be forwarded unchanged, got:\n%q", rec.Body.String())
+	}
+	if usage != (monitor.TokenUsage{}) {
+		t.Errorf("Expected no token usage with parse_stream_tokens: false, got %+v", usage)
+	}
+}
+
+func TestPassthroughHeartbeat(t *testing.T) {
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: ping\ndata: {}\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("event: message_stop\ndata: {}\n\n"))
+	}, func(cfg *config.Config) {
+		cfg.Streaming.HeartbeatInterval = 10 * time.Millisecond
+		cfg.Streaming.MaxIdleTime = 50 * time.Millisecond
+	})
+
+	rec, _ := serveStream(handler, mm)
+	body := rec.Body.String()
+	heartbeat := strings.Index(body, ": heartbeat ")
+	if heartbeat < 0 {
+		t.Fatalf("Expected a heartbeat while the upstream was idle, got:\n%s", body)
+	}
+	if !strings.HasPrefix(body, "event: ping\ndata: {}\n\n") || !strings.HasSuffix(body, "event: message_stop\ndata: {}\n\n") {
+		t.Errorf("Expected heartbeats only between upstream events, got:\n%s", body)
+	}
+}
+
+func TestPassthroughHeartbeatWaitsForEventBoundary(t *testing.T) {
+	handler, mm := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: content_block_delta\ndata: {\"delta\":{\"text\":\"hel"))
+		w.(http.Flusher).Flush()
+		// Stall inside the data line for longer than max_idle_time
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("lo\"}}\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("event: message_stop\ndata: {}\n\n"))
+	}, func(cfg *config.Config) {
+		cfg.Streaming.HeartbeatInterval = 10 * time.Millisecond
+		cfg.Streaming.MaxIdleTime = 50 * time.Millisecond
+	})
+
+	rec, _ := serveStream(handler, mm)
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "event: content_block_delta\ndata: {\"delta\":{\"text\":\"hello\"}}\n\n") {
+		t.Errorf("Expected the stalled data line to reach the client intact, got:\n%s", body)
+	}
+	if !strings.Contains(body, "\n\n: heartbeat ") {
+		t.Errorf("Expected a heartbeat once the stream was back at an event boundary, got:\n%s", body)
+	}
+}
+
+func TestUsageTeeMatchesTokenParser(t *testing.T) {
+	// Byte-by-byte chunks, CRLF line endings and an unterminated last line
+	stream := strings.ReplaceAll(passthroughTestStream, "\n", "\r\n")
+	stream += `data: {"type":"message_delta","delta":{},"usage":{"output_tokens":3}}`
+
+	tee := newUsageTee(config.APIFormatAnthropic)
+	var got *monitor.TokenUsage
+	for i := 0; i < len(stream); i++ {
+		got = addUsage(got, tee.Write([]byte{stream[i]}))
+	}
+	got = addUsage(got, tee.Finish())
+
+	parser := NewTokenParser()
+	want := addUsage(parser.ParseChunk([]byte(stream)), parser.Finish())
+	if got == nil || want == nil || *got != *want {
+		t.Errorf("Expected the tee to find %+v, got %+v", want, got)
+	}
+}
+
+// benchmarkStream is a synthetic ~10MB Claude stream of text deltas
+func benchmarkStream() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("event: message_start\n")
+	buf.WriteString(`data: {"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}` + "\n\n")
+	for i := 0; buf.Len() < 10<<20; i++ {
+		fmt.Fprintf(&buf, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"token %d 生成的文本内容\"}}\n\n", i)
+	}
+	buf.WriteString("event: message_delta\n")
+	buf.WriteString(`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":100000}}` + "\n\n")
+	return buf.Bytes()
+}
+
+// discardStreamWriter is a flushable ResponseWriter that drops the body
+type discardStreamWriter struct {
+	header http.Header
+}
+
+func (d *discardStreamWriter) Header() http.Header         { return d.header }
+func (d *discardStreamWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardStreamWriter) WriteHeader(int)             {}
+func (d *discardStreamWriter) Flush()                      {}
+
+// benchmarkStreaming runs one streaming implementation over the synthetic stream
+func benchmarkStreaming(b *testing.B, stream func(h *Handler, w http.ResponseWriter, resp *http.Response) error) {
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	data := benchmarkStream()
+	cfg := newRulesTestConfig(nil, config.EndpointConfig{Name: "primary", URL: "http://127.0.0.1:1", Timeout: time.Second})
+	cfg.Streaming = config.StreamingConfig{HeartbeatInterval: time.Minute, MaxIdleTime: time.Minute}
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(manager))
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(bytes.NewReader(data)),
+		}
+		if err := stream(handler, &discardStreamWriter{header: http.Header{}}, resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamByBytes(b *testing.B) {
+	benchmarkStreaming(b, func(h *Handler, w http.ResponseWriter, resp *http.Response) error {
+		return h.streamResponseByBytes(context.Background(), w, resp, w.(http.Flusher), "conn", "primary")
+	})
+}
+
+func BenchmarkStreamPassthrough(b *testing.B) {
+	benchmarkStreaming(b, func(h *Handler, w http.ResponseWriter, resp *http.Response) error {
+		return h.streamResponsePassthrough(context.Background(), w, resp, w.(http.Flusher), "conn", "primary")
+	})
+}
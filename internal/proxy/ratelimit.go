@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// ErrEndpointsRateLimited is returned when every candidate endpoint was skipped because of its
+// concurrency or rate limit, and at least one of them because of its rate limit
+var ErrEndpointsRateLimited = errors.New("all endpoints are at their rate limit")
+
+// takeRateToken takes a rate limit token for an upstream attempt on ep, queueing for it when
+// the endpoint's rate_limit.on_exceeded is queue. It returns false when the endpoint is at its
+// rate limit and the request should move on to the next endpoint, and an error when the
+// client went away while queued.
+func takeRateToken(ctx context.Context, ep *endpoint.Endpoint) (bool, error) {
+	limit := ep.RateLimit()
+	if limit == nil {
+		return true, nil
+	}
+
+	start := time.Now()
+	err := ep.AcquireRateToken(ctx)
+	if errors.Is(err, endpoint.ErrRateLimitExceeded) {
+		slog.InfoContext(ctx, fmt.Sprintf("⏱️ [速率限制] 端点 %s 已达到速率限制 (%d 次/分钟)，尝试下一个端点",
+			ep.Config.Name, limit.RequestsPerMinute))
+		return false, nil
+	}
+	if err != nil {
+		return false, contextError(ctx)
+	}
+	if waited := time.Since(start); waited >= 10*time.Millisecond {
+		slog.InfoContext(ctx, fmt.Sprintf("⏱️ [速率限制] 端点 %s 排队等待 %s 后发送请求",
+			ep.Config.Name, waited.Round(time.Millisecond)))
+	}
+	return true, nil
+}
+
+// writeRateLimited responds with 429 and a Retry-After of when the first rate limited
+// endpoint lets a request through again
+func (h *Handler) writeRateLimited(w http.ResponseWriter) {
+	wait := time.Duration(-1)
+	for _, ep := range h.endpointManager.GetAllEndpoints() {
+		if ep.RateLimit() == nil {
+			continue
+		}
+		if epWait := ep.RateLimitWait(); wait < 0 || epWait < wait {
+			wait = epWait
+		}
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	apierror.Write(w, http.StatusTooManyRequests, apierror.TypeRateLimited, "All endpoints are at their rate limit")
+}
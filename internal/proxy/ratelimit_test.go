@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+)
+
+// newSSECountingUpstream answers every request with a short SSE stream and counts them
+func newSSECountingUpstream(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func sendRateLimitTestRequest(handler http.Handler, stream bool) *httptest.ResponseRecorder {
+	body := `{}`
+	if stream {
+		body = `{"stream":true}`
+	}
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(body))
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitShiftsRequestsToNextEndpoint(t *testing.T) {
+	limited, limitedHits := newSSECountingUpstream(t)
+	backup, backupHits := newSSECountingUpstream(t)
+
+	handler, _ := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "limited", URL: limited.URL, Priority: 1,
+			RateLimit: &config.EndpointRateLimitConfig{RequestsPerMinute: 2, Burst: 2, OnExceeded: config.RateLimitFailover}},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2,
+			RateLimit: &config.EndpointRateLimitConfig{RequestsPerMinute: 60, Burst: 10, OnExceeded: config.RateLimitFailover}},
+	)
+
+	// The first endpoint takes its burst, then requests shift to the backup
+	for i := 0; i < 4; i++ {
+		if rec := sendRateLimitTestRequest(handler, false); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+	if limitedHits.Load() != 2 || backupHits.Load() != 2 {
+		t.Errorf("Expected 2 requests on each endpoint, got limited=%d backup=%d", limitedHits.Load(), backupHits.Load())
+	}
+
+	// Streaming requests respect the same limiter
+	if rec := sendRateLimitTestRequest(handler, true); !strings.Contains(rec.Body.String(), "message_stop") {
+		t.Fatalf("Expected the stream to be proxied, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if limitedHits.Load() != 2 || backupHits.Load() != 3 {
+		t.Errorf("Expected the stream on the backup, got limited=%d backup=%d", limitedHits.Load(), backupHits.Load())
+	}
+}
+
+func TestRateLimitQueueWaitsForToken(t *testing.T) {
+	upstream, hits := newSSECountingUpstream(t)
+
+	// 600/min refills a token every 100ms
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "queued", URL: upstream.URL, Priority: 1,
+			RateLimit: &config.EndpointRateLimitConfig{RequestsPerMinute: 600, Burst: 1, OnExceeded: config.RateLimitQueue, MaxWait: time.Second}},
+	)
+
+	start := time.Now()
+	for _, stream := range []bool{false, false, true} {
+		if rec := sendRateLimitTestRequest(handler, stream); rec.Code != http.StatusOK {
+			t.Fatalf("Expected queued requests to succeed, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the second and third requests to wait for tokens, took %v", elapsed)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("Expected 3 requests upstream, got %d", hits.Load())
+	}
+	if tokens, burst := manager.GetEndpointByName("queued").RateTokens(); tokens >= 1 || burst != 1 {
+		t.Errorf("Expected an empty bucket of size 1, got %.2f/%d", tokens, burst)
+	}
+}
+
+func TestAllEndpointsRateLimitedReturns429(t *testing.T) {
+	upstream, hits := newSSECountingUpstream(t)
+
+	handler, _ := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "limited", URL: upstream.URL, Priority: 1,
+			RateLimit: &config.EndpointRateLimitConfig{RequestsPerMinute: 1, Burst: 1, OnExceeded: config.RateLimitQueue, MaxWait: 10 * time.Millisecond}},
+	)
+
+	if rec := sendRateLimitTestRequest(handler, false); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, got %d", rec.Code)
+	}
+
+	// The next token is a minute away, longer than max_wait
+	rec := sendRateLimitTestRequest(handler, false)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), apierror.TypeRateLimited) || rec.Header().Get(apierror.HeaderError) != "true" {
+		t.Errorf("Expected a forwarder rate limit error, got %s", rec.Body.String())
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "1" {
+		t.Errorf("Expected Retry-After close to a minute, got %q", retryAfter)
+	}
+
+	if rec := sendRateLimitTestRequest(handler, true); !strings.Contains(rec.Body.String(), apierror.TypeRateLimited) {
+		t.Errorf("Expected a rate limit SSE error for the stream, got %s", rec.Body.String())
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected only the first request upstream, got %d", hits.Load())
+	}
+}
@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// requestIDLogRecorder keeps every log message with the request ID of its context
+type requestIDLogRecorder struct {
+	mu      sync.Mutex
+	records []requestIDLogRecord
+}
+
+type requestIDLogRecord struct {
+	requestID string
+	message   string
+}
+
+func (lr *requestIDLogRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (lr *requestIDLogRecorder) Handle(ctx context.Context, r slog.Record) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	lr.records = append(lr.records, requestIDLogRecord{requestID: middleware.RequestIDFromContext(ctx), message: r.Message})
+	return nil
+}
+
+func (lr *requestIDLogRecorder) WithAttrs([]slog.Attr) slog.Handler { return lr }
+func (lr *requestIDLogRecorder) WithGroup(string) slog.Handler      { return lr }
+
+// messagesFor returns the messages logged for a request ID
+func (lr *requestIDLogRecorder) messagesFor(requestID string) []string {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	var messages []string
+	for _, record := range lr.records {
+		if record.requestID == requestID {
+			messages = append(messages, record.message)
+		}
+	}
+	return messages
+}
+
+func TestRequestIDFollowsRetriedStream(t *testing.T) {
+	logs := &requestIDLogRecorder{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logs))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var mu sync.Mutex
+	var upstreamIDs []string
+	record := func(r *http.Request) {
+		mu.Lock()
+		upstreamIDs = append(upstreamIDs, r.Header.Get("X-Correlation-Id"))
+		mu.Unlock()
+	}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(failing.Close)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, passthroughTestStream)
+	}))
+	t.Cleanup(healthy.Close)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "failing", URL: failing.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second},
+		config.EndpointConfig{Name: "healthy", URL: healthy.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second})
+	cfg.Streaming = config.StreamingConfig{PassthroughMode: true, HeartbeatInterval: time.Minute, MaxIdleTime: time.Minute}
+	cfg.Forwarding.RequestIDHeader = "X-Correlation-Id"
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+	monitoring := middleware.NewMonitoringMiddleware(manager)
+	handler.SetMonitoringMiddleware(monitoring)
+	logging := middleware.NewLoggingMiddleware(slog.Default())
+	logging.SetMonitoringMiddleware(monitoring)
+	logging.SetRequestIDHeader(cfg.Forwarding.RequestIDHeader)
+	server := logging.Wrap(handler)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test","stream":true}`)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the stream to fail over and succeed, got %d", rec.Code)
+	}
+	requestID := rec.Header().Get(middleware.RequestIDResponseHeader)
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(requestID) {
+		t.Fatalf("Expected a generated UUID in X-Request-Id, got %q", requestID)
+	}
+
+	// Both upstream attempts carry the ID in the configured header
+	if len(upstreamIDs) != 2 || upstreamIDs[0] != requestID || upstreamIDs[1] != requestID {
+		t.Errorf("Expected both attempts to carry %q, got %v", requestID, upstreamIDs)
+	}
+
+	// The log lines of the request show its whole lifecycle under one ID
+	messages := strings.Join(logs.messagesFor(requestID), "\n")
+	for _, want := range []string{"Request started", "failing", "healthy", "令牌统计", "Request completed"} {
+		if !strings.Contains(messages, want) {
+			t.Errorf("Expected a log line containing %q for request %s, got:\n%s", want, requestID, messages)
+		}
+	}
+
+	conns := monitoring.GetMetrics().RecentConnections(0)
+	if len(conns) != 1 || conns[0].RequestID != requestID {
+		t.Errorf("Expected the connection record to carry request ID %q, got %+v", requestID, conns)
+	}
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	var upstreamID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get("X-Request-Id")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	cfg.Forwarding.RequestIDHeader = config.DefaultRequestIDHeader
+	logging := middleware.NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	server := logging.Wrap(NewHandler(endpoint.NewManager(cfg), cfg))
+
+	tests := []struct {
+		name     string
+		clientID string
+		reused   bool
+	}{
+		{"client ID is honored", "trace-1234", true},
+		{"ID with spaces is replaced", "trace 1234", false},
+		{"overlong ID is replaced", strings.Repeat("a", 200), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test"}`))
+			req.Header.Set("X-Request-Id", tt.clientID)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			returned := rec.Header().Get("X-Request-Id")
+			if (returned == tt.clientID) != tt.reused || returned == "" {
+				t.Errorf("Expected client ID reused: %v, got %q", tt.reused, returned)
+			}
+			if upstreamID != returned {
+				t.Errorf("Expected upstream to receive %q, got %q", returned, upstreamID)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+)
+
+// HeaderForwarderStreamID names a resumable passthrough stream (streaming.replay_buffer_kb).
+// The forwarder returns it with the stream, and a client that reconnects within
+// streaming.replay_window sends it back to resume the stream instead of starting the request
+// over. It is never forwarded upstream.
+const HeaderForwarderStreamID = "X-Forwarder-Stream-ID"
+
+// replayRegistry holds the streams that can be resumed, by stream ID
+type replayRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*replayStream
+}
+
+func (r *replayRegistry) add(s *replayStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams == nil {
+		r.streams = make(map[string]*replayStream)
+	}
+	r.streams[s.id] = s
+}
+
+func (r *replayRegistry) get(id string) *replayStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.streams[id]
+}
+
+func (r *replayRegistry) remove(s *replayStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams[s.id] == s {
+		delete(r.streams, s.id)
+	}
+}
+
+// replayStream is a passthrough stream that outlives its client for a while. The request
+// that started it keeps relaying the upstream into a bounded buffer of recent events; a
+// client that reconnects with the stream ID gets the buffer and then follows it.
+type replayStream struct {
+	id     string
+	limit  int           // Bytes of recent events kept
+	window time.Duration // How long the stream waits for a client to reconnect
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	changed  chan struct{} // Closed and replaced whenever the stream progresses or changes hands
+	status   int
+	header   http.Header // Headers as sent with the status line
+	buf      []byte      // Recent events, starting at an event boundary
+	start    int64       // Stream offset of buf[0]
+	owner    int         // Client the stream is written to: 0 is the original request, -1 none
+	clients  int         // Clients that resumed the stream so far
+	done     bool        // The original request has finished
+	expiry   *time.Timer // Aborts the stream once nobody reconnected within the window
+	takeover func()      // Unblocks a write to the original client when another one takes over
+}
+
+// newStreamID returns a random ID for a resumable stream
+func newStreamID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("es-%d", time.Now().UnixNano())
+	}
+	return "es-" + hex.EncodeToString(buf)
+}
+
+// notify wakes the resumed clients; the caller holds s.mu
+func (s *replayStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// append keeps b for clients that reconnect, dropping the oldest whole events beyond the limit
+func (s *replayStream) append(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.buf, b...)
+	if excess := len(s.buf) - s.limit; excess > 0 {
+		cut := len(s.buf)
+		if i := bytes.Index(s.buf[excess:], []byte("\n\n")); i >= 0 {
+			cut = excess + i + 2
+		}
+		// Reslice rather than copy: a resumed client may still be writing the old bytes
+		s.buf = s.buf[cut:]
+		s.start += int64(cut)
+	}
+	s.notify()
+}
+
+// isOwner reports whether the stream is written to the given client
+func (s *replayStream) isOwner(client int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owner == client
+}
+
+// attach hands the stream to a reconnecting client and returns its client number
+func (s *replayStream) attach() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	if s.owner == 0 && s.takeover != nil {
+		s.takeover()
+	}
+	s.clients++
+	s.owner = s.clients
+	s.notify()
+	return s.owner
+}
+
+// detach records that a client went away; unless another client reconnects within the
+// window, the stream is aborted
+func (s *replayStream) detach(client int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner != client {
+		return
+	}
+	s.owner = -1
+	if !s.done {
+		slog.Info(fmt.Sprintf("🔁 [流恢复] 客户端已断开，流继续接收上游数据，等待客户端在 %v 内重新连接: %s", s.window, s.id))
+		s.expiry = time.AfterFunc(s.window, s.expire)
+	}
+}
+
+// expire aborts a stream nobody reconnected to
+func (s *replayStream) expire() {
+	s.mu.Lock()
+	abandoned := s.owner < 0 && !s.done
+	s.mu.Unlock()
+	if abandoned {
+		slog.Info(fmt.Sprintf("⌛ [流恢复] 客户端未在 %v 内重新连接，终止流: %s", s.window, s.id))
+		s.cancel()
+	}
+}
+
+// finish marks the stream as complete; it can still be resumed for the window, to replay its tail
+func (s *replayStream) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	s.notify()
+}
+
+// replayWriter is the response writer of a resumable stream's original request. It keeps
+// recent events for clients that reconnect and, once its client is gone, stops writing to it
+// without failing, so the upstream keeps being relayed.
+type replayWriter struct {
+	http.ResponseWriter
+	stream     *replayStream
+	registry   *replayRegistry
+	resumable  bool // The stream started successfully and can be resumed
+	headerSent bool
+}
+
+func (rw *replayWriter) WriteHeader(code int) {
+	if rw.headerSent {
+		return
+	}
+	rw.headerSent = true
+	s := rw.stream
+	if code != http.StatusOK {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	rw.ResponseWriter.Header().Set(HeaderForwarderStreamID, s.id)
+	s.mu.Lock()
+	s.status = code
+	s.header = rw.ResponseWriter.Header().Clone()
+	s.mu.Unlock()
+	rw.resumable = true
+	rw.registry.add(s)
+	slog.Debug(fmt.Sprintf("🔁 [流恢复] 流可在客户端断开后恢复: %s", s.id))
+
+	rw.ResponseWriter.WriteHeader(code)
+	// Clients that cannot read response headers find the ID in the first comment
+	fmt.Fprintf(rw.ResponseWriter, ": stream-id %s\n\n", s.id)
+	rw.Flush()
+}
+
+func (rw *replayWriter) Write(b []byte) (int, error) {
+	if !rw.headerSent {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if !rw.resumable {
+		return rw.ResponseWriter.Write(b)
+	}
+	rw.stream.append(b)
+	if rw.stream.isOwner(0) {
+		if _, err := rw.ResponseWriter.Write(b); err != nil {
+			rw.stream.detach(0)
+		}
+	}
+	return len(b), nil
+}
+
+// Flush forwards flushes while the original client still receives the stream
+func (rw *replayWriter) Flush() {
+	if !rw.resumable || rw.stream.isOwner(0) {
+		if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *replayWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// startReplay makes a passthrough stream resumable (streaming.replay_buffer_kb). It returns
+// the writer and context to stream with: the context is not cancelled when the client goes
+// away, only when it does not reconnect within streaming.replay_window. The returned
+// function must be called once the stream has ended.
+func (h *Handler) startReplay(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	s := &replayStream{
+		id:      newStreamID(),
+		limit:   h.config.Streaming.ReplayBufferKB * 1024,
+		window:  h.config.Streaming.ReplayWindow,
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+	rw := &replayWriter{ResponseWriter: w, stream: s, registry: &h.replays}
+	// A write blocked on a dead connection ends once another client takes over
+	s.takeover = func() {
+		http.NewResponseController(w).SetWriteDeadline(time.Now())
+	}
+	stop := context.AfterFunc(r.Context(), func() { s.detach(0) })
+
+	return rw, ctx, func() {
+		stop()
+		s.finish()
+		abandoned := ctx.Err() != nil
+		cancel()
+		if abandoned {
+			// Cut short because nobody reconnected: its tail would pass for a complete stream
+			h.replays.remove(s)
+		} else if rw.resumable {
+			time.AfterFunc(s.window, func() { h.replays.remove(s) })
+		}
+	}
+}
+
+// resumeStream answers a request that reconnects to a resumable stream, sent with the
+// stream's ID in X-Forwarder-Stream-ID or Last-Event-ID: the buffered events are sent again
+// and the stream continues from the still-open upstream. It reports whether the request was
+// answered; an unknown or expired ID is forwarded as a new request.
+func (h *Handler) resumeStream(w http.ResponseWriter, r *http.Request) bool {
+	id := r.Header.Get(HeaderForwarderStreamID)
+	r.Header.Del(HeaderForwarderStreamID)
+	explicit := id != ""
+	if !explicit {
+		// Any other Last-Event-ID belongs to the upstream and is forwarded
+		id = r.Header.Get("Last-Event-ID")
+	}
+	if id == "" || !h.config.Streaming.ReplayEnabled() {
+		return false
+	}
+	ctx := r.Context()
+	s := h.replays.get(id)
+	flusher, ok := w.(http.Flusher)
+	if s == nil || !ok {
+		if explicit {
+			slog.InfoContext(ctx, fmt.Sprintf("🔁 [流恢复] 流不存在或已过期，重新转发请求: %s", id))
+		}
+		return false
+	}
+	if explicit {
+		r.Header.Del("Last-Event-ID")
+	}
+
+	client := s.attach()
+	s.mu.Lock()
+	status, header, offset := s.status, s.header, s.start
+	s.mu.Unlock()
+	slog.InfoContext(ctx, fmt.Sprintf("🔁 [流恢复] 客户端重新连接，重放缓冲的事件并继续转发: %s", id))
+
+	// Headers the request's own middleware set (request ID, CORS) are kept
+	for key, values := range header {
+		if _, exists := w.Header()[key]; !exists {
+			w.Header()[key] = values
+		}
+	}
+	w.WriteHeader(status)
+	fmt.Fprintf(w, ": stream-id %s\n\n", id)
+	flusher.Flush()
+
+	out := &passthroughWriter{w: w, flusher: flusher, newlines: 2}
+	out.touch()
+	h.streams.add(out)
+	defer h.streams.remove(out)
+	stopHeartbeat := h.startPassthroughHeartbeat(out)
+	defer stopHeartbeat()
+
+	for {
+		s.mu.Lock()
+		owner, done, changed := s.owner, s.done, s.changed
+		lost := offset < s.start
+		var chunk []byte
+		if !lost {
+			chunk = s.buf[offset-s.start:]
+		}
+		s.mu.Unlock()
+
+		if owner != client {
+			slog.InfoContext(ctx, fmt.Sprintf("🔁 [流恢复] 另一个连接已接管流: %s", id))
+			return true
+		}
+		if lost {
+			// This client fell further behind than the buffer reaches
+			out.write([]byte(fmt.Sprintf("event: error\ndata: %s\n\n",
+				apierror.Body(apierror.TypeStreamLost, "The stream moved past the replay buffer; retry the request"))))
+			s.detach(client)
+			return true
+		}
+		if len(chunk) > 0 {
+			if err := out.write(chunk); err != nil {
+				s.detach(client)
+				return true
+			}
+			offset += int64(len(chunk))
+		}
+		if done {
+			slog.InfoContext(ctx, fmt.Sprintf("✅ [流恢复] 恢复的流已完成: %s", id))
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			s.detach(client)
+			return true
+		}
+	}
+}
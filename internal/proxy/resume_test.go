@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// resumeTestEnv serves a forwarder with resumable streams over real connections. The upstream
+// sends two events, waits for proceed to be closed and sends eight more.
+func resumeTestEnv(t *testing.T, window time.Duration) (handler *Handler, server *httptest.Server, hits *atomic.Int32, proceed chan struct{}, upstreamGone chan struct{}) {
+	t.Helper()
+	hits = new(atomic.Int32)
+	proceed = make(chan struct{})
+	upstreamGone = make(chan struct{}, 1)
+	handler, _ = newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 10; i++ {
+			if i == 2 {
+				select {
+				case <-proceed:
+				case <-r.Context().Done():
+					upstreamGone <- struct{}{}
+					return
+				}
+			}
+			fmt.Fprintf(w, "event: ping\ndata: {\"n\":%d}\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}, func(cfg *config.Config) {
+		cfg.Streaming.ReplayBufferKB = 64
+		cfg.Streaming.ReplayWindow = window
+	})
+	server = httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return handler, server, hits, proceed, upstreamGone
+}
+
+// openStream starts a streaming request, sending the given extra headers
+func openStream(t *testing.T, url string, header http.Header) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url+"/v1/messages", strings.NewReader(`{"model":"claude","stream":true}`))
+	req.Header.Set("Accept", "text/event-stream")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+// readUntil reads the stream until it has seen want
+func readUntil(t *testing.T, body *bufio.Reader, want string) string {
+	t.Helper()
+	var seen strings.Builder
+	for !strings.Contains(seen.String(), want) {
+		line, err := body.ReadString('\n')
+		seen.WriteString(line)
+		if err != nil {
+			t.Fatalf("Stream ended before %q: %v (got %q)", want, err, seen.String())
+		}
+	}
+	return seen.String()
+}
+
+func TestResumeStreamAfterDisconnect(t *testing.T) {
+	handler, server, hits, proceed, _ := resumeTestEnv(t, 2*time.Second)
+
+	resp := openStream(t, server.URL, nil)
+	id := resp.Header.Get(HeaderForwarderStreamID)
+	if id == "" {
+		t.Fatal("Expected the stream ID in the response headers")
+	}
+	first := readUntil(t, bufio.NewReader(resp.Body), `{"n":1}`)
+	if !strings.HasPrefix(first, ": stream-id "+id+"\n\n") {
+		t.Errorf("Expected the stream to start with its ID in a comment, got %q", first)
+	}
+	// Drop the connection mid-generation; the upstream goes on while nobody listens
+	resp.Body.Close()
+	stream := handler.replays.get(id)
+	for deadline := time.Now().Add(2 * time.Second); !stream.isOwner(-1); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the stream to notice its client went away")
+		}
+	}
+	close(proceed)
+
+	resumed := openStream(t, server.URL, http.Header{HeaderForwarderStreamID: {id}})
+	defer resumed.Body.Close()
+	body, err := io.ReadAll(resumed.Body)
+	if err != nil {
+		t.Fatalf("Expected the resumed stream to end cleanly, got %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if !strings.Contains(string(body), fmt.Sprintf(`{"n":%d}`, i)) {
+			t.Errorf("Expected event %d to be replayed or relayed, got %q", i, body)
+		}
+	}
+	if resumed.Header.Get(HeaderForwarderStreamID) != id {
+		t.Errorf("Expected the resumed stream to keep its ID, got %q", resumed.Header.Get(HeaderForwarderStreamID))
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("Expected the generation to continue without a new upstream request, got %d requests", n)
+	}
+
+	// The finished stream still replays its tail within the window
+	again := openStream(t, server.URL, http.Header{"Last-Event-Id": {id}})
+	defer again.Body.Close()
+	tail, _ := io.ReadAll(again.Body)
+	if !strings.Contains(string(tail), `{"n":9}`) || hits.Load() != 1 {
+		t.Errorf("Expected the finished stream's tail from Last-Event-ID, got %q after %d requests", tail, hits.Load())
+	}
+}
+
+func TestResumeStreamExpires(t *testing.T) {
+	handler, server, hits, _, upstreamGone := resumeTestEnv(t, 100*time.Millisecond)
+
+	resp := openStream(t, server.URL, nil)
+	id := resp.Header.Get(HeaderForwarderStreamID)
+	readUntil(t, bufio.NewReader(resp.Body), `{"n":1}`)
+	resp.Body.Close()
+
+	select {
+	case <-upstreamGone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the upstream request to be cancelled once the replay window passed")
+	}
+
+	// An expired ID is forwarded as a new request
+	for deadline := time.Now().Add(2 * time.Second); handler.replays.get(id) != nil; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the abandoned stream to stop being resumable")
+		}
+	}
+	retried := openStream(t, server.URL, http.Header{HeaderForwarderStreamID: {id}})
+	retried.Body.Close()
+	if n := hits.Load(); n != 2 {
+		t.Errorf("Expected an expired stream ID to start a new upstream request, got %d requests", n)
+	}
+}
+
+func TestLastEventIDForwarded(t *testing.T) {
+	var lastEventID, streamID atomic.Value
+	handler, _ := newPassthroughEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		lastEventID.Store(r.Header.Get("Last-Event-ID"))
+		streamID.Store(r.Header.Get(HeaderForwarderStreamID))
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: ping\ndata: {}\n\n")
+	}, func(cfg *config.Config) {
+		cfg.Streaming.ReplayBufferKB = 64
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp := openStream(t, server.URL, http.Header{"Last-Event-Id": {"evt_42"}, HeaderForwarderStreamID: {"es-unknown"}})
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if got := lastEventID.Load(); got != "evt_42" {
+		t.Errorf("Expected Last-Event-ID to be forwarded upstream, got %q", got)
+	}
+	if got := streamID.Load(); got != "" {
+		t.Errorf("Expected %s not to be forwarded upstream, got %q", HeaderForwarderStreamID, got)
+	}
+}
@@ -1,11 +1,15 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,12 +24,14 @@ type RetryHandler struct {
 	monitoringMiddleware interface {
 		RecordRetry(connID string, endpoint string)
 	}
+	budget *retryBudget // Retries made across all requests, limited by retry.budget_per_minute
 }
 
 // NewRetryHandler creates a new retry handler
 func NewRetryHandler(cfg *config.Config) *RetryHandler {
 	return &RetryHandler{
 		config: cfg,
+		budget: &retryBudget{},
 	}
 }
 
@@ -59,6 +65,23 @@ func (re *RetryableError) Error() string {
 	return fmt.Sprintf("HTTP %d", re.StatusCode)
 }
 
+// ErrClientCancelled is returned when the client aborted the request. It wraps context.Canceled
+// and is never retried or counted against the endpoint or its group.
+var ErrClientCancelled = fmt.Errorf("client cancelled request: %w", context.Canceled)
+
+// clientCancelled reports whether the inbound request was aborted by the client
+func clientCancelled(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// contextError maps a done context to ErrClientCancelled when the client went away
+func contextError(ctx context.Context) error {
+	if clientCancelled(ctx) {
+		return ErrClientCancelled
+	}
+	return ctx.Err()
+}
+
 // Execute executes an operation with retry and fallback logic
 func (rh *RetryHandler) Execute(operation Operation, connID string) (*http.Response, error) {
 	return rh.ExecuteWithContext(context.Background(), operation, connID)
@@ -79,15 +102,13 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 	// Track initial configuration version to detect config changes
 	initialConfigVersion := rh.endpointManager.GetConfigVersion()
 
+	// Whether the previously tried endpoint rejected us with a rate limit (request not processed)
+	lastEndpointRateLimited := false
+
 	for {
 	nextEndpointSelection:
 		// Get healthy endpoints with real-time testing if enabled (dynamic refresh)
-		var endpoints []*endpoint.Endpoint
-		if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
-			endpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
-		} else {
-			endpoints = rh.endpointManager.GetHealthyEndpoints()
-		}
+		endpoints := rh.selectEndpoints(ctx, nil)
 
 		if len(endpoints) == 0 {
 			return nil, fmt.Errorf("no healthy endpoints available in active groups")
@@ -107,8 +128,55 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 		groupsFailedThisIteration := make(map[string]bool)
 		endpointsTriedThisIteration := 0
 
+		// Endpoints skipped because they are at their concurrency or rate limit (not failures)
+		saturatedThisIteration := make(map[string]bool)
+		rateLimitedThisIteration := false
+
+		// Endpoints whose upstream rate limited us
+		upstreamLimitedThisIteration := make(map[string]bool)
+
 		// Try each endpoint in current endpoint set
 		for endpointIndex, ep := range endpoints {
+			// Large non-idempotent requests stay on the first endpoint unless it rate limited us;
+			// a streamed body was used up by the first endpoint either way
+			if totalEndpointsAttempted > 0 && (bodyStreamed(ctx) || failoverDisabled(ctx) && !lastEndpointRateLimited) {
+				slog.WarnContext(ctx, fmt.Sprintf("🔒 [幂等保护] 已禁用非幂等请求的跨端点重试，不再切换到端点: %s - 最后错误: %v",
+					ep.Config.Name, lastErr))
+				return nil, fmt.Errorf("cross-endpoint retry disabled for non-idempotent request after trying %d endpoints, last error: %w", totalEndpointsAttempted, lastErr)
+			}
+
+			// Saturated endpoints are skipped (or queued for, with max_queue_wait) without
+			// counting as an attempt; the slot reserved here is used by the first attempt
+			release, err := acquireSlot(ctx, ep)
+			if err != nil {
+				if !errors.Is(err, endpoint.ErrEndpointSaturated) {
+					return nil, err
+				}
+				saturatedThisIteration[ep.Config.Name] = true
+				continue
+			}
+
+			// Endpoints at their rate limit are skipped (or queued for) without counting as an
+			// attempt; the token taken here is used by the first attempt
+			if ok, err := takeRateToken(ctx, ep); err != nil {
+				release()
+				return nil, err
+			} else if !ok {
+				release()
+				saturatedThisIteration[ep.Config.Name] = true
+				rateLimitedThisIteration = true
+				continue
+			}
+
+			// Moving on to another endpoint is a retry as well
+			if totalEndpointsAttempted > 0 && !rh.takeRetryBudget(ctx, ep.Config.Name) {
+				release()
+				if lastResp != nil {
+					return lastResp, nil
+				}
+				return nil, fmt.Errorf("%w after trying %d endpoints, last error: %w", ErrRetryBudgetExhausted, totalEndpointsAttempted, lastErr)
+			}
+
 			totalEndpointsAttempted++
 			endpointsTriedThisIteration++
 
@@ -120,22 +188,79 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 				groupName = "Default"
 			}
 
+			// Retry settings of the endpoint's group; attempts per endpoint (a streamed request
+			// body can only be sent once)
+			policy := rh.config.GetGroupRetry(groupName)
+			maxAttempts := rh.maxAttemptsFor(ctx, policy)
+
 			slog.InfoContext(ctxWithEndpoint, fmt.Sprintf("🎯 [请求转发] 选择端点: %s (组: %s, 总尝试 %d)",
 				ep.Config.Name, groupName, totalEndpointsAttempted))
 
+			// Set when the upstream rate limits us, so we move on without sleeping
+			endpointRateLimited := false
+			// Set when the body could not be transformed for this endpoint
+			transformFailed := false
+
 			// Retry logic for current endpoint
-			for attempt := 1; attempt <= rh.config.Retry.MaxAttempts; attempt++ {
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
 				select {
 				case <-ctx.Done():
+					if release != nil {
+						release()
+					}
 					if lastResp != nil {
 						lastResp.Body.Close()
 					}
-					return nil, ctx.Err()
+					return nil, contextError(ctx)
 				default:
 				}
 
-				// Execute operation
-				resp, err := operation(ep, connID)
+				// Every retry is another upstream request and needs its own concurrency slot
+				// and rate limit token
+				if release == nil {
+					if release, err = acquireSlot(ctxWithEndpoint, ep); err != nil {
+						if !errors.Is(err, endpoint.ErrEndpointSaturated) {
+							return nil, err
+						}
+						saturatedThisIteration[ep.Config.Name] = true
+						break
+					}
+					if ok, err := takeRateToken(ctxWithEndpoint, ep); err != nil {
+						release()
+						return nil, err
+					} else if !ok {
+						release()
+						release = nil
+						saturatedThisIteration[ep.Config.Name] = true
+						rateLimitedThisIteration = true
+						break
+					}
+				}
+
+				// Execute operation; the slot moves to the response body on success
+				attemptStart := time.Now()
+				resp, err := runWithSlot(operation, ep, connID, release)
+				release = nil
+				rh.recordIdempotentAttempt(ctx, connID)
+				if err == nil && resp != nil {
+					rh.recordAttempt(connID, ep.Config.Name, attemptStart, resp.StatusCode, nil, false)
+				} else {
+					rh.recordAttempt(connID, ep.Config.Name, attemptStart, 0, err, false)
+				}
+
+				// A rejected token: retry the endpoint with the next one, without using up an attempt
+				if err == nil && resp != nil && resp.Request != nil &&
+					rh.rotateToken(ctxWithEndpoint, ep, resp.StatusCode, resp.Header, bearerToken(resp.Request.Header)) {
+					resp.Body.Close()
+					lastResp = nil
+					lastErr = &RetryableError{
+						StatusCode:  resp.StatusCode,
+						IsRetryable: true,
+						Reason:      "token rejected",
+					}
+					attempt--
+					continue
+				}
 				if err == nil && resp != nil {
 					// Check if response status code indicates success or should be retried
 					retryDecision := rh.shouldRetryStatusCode(resp.StatusCode)
@@ -145,6 +270,11 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 						slog.InfoContext(ctxWithEndpoint, fmt.Sprintf("✅ [请求成功] 端点: %s (组: %s), 状态码: %d (总尝试 %d 个端点)",
 							ep.Config.Name, groupName, resp.StatusCode, totalEndpointsAttempted))
 
+						// An answer to a recovery probe ends the outage for this endpoint
+						if isRecoveryProbe(ctx) && resp.StatusCode < http.StatusInternalServerError {
+							rh.endpointManager.MarkRecovered(ep.Config.Name)
+						}
+
 						// Reset retry count for this group on success
 						if !groupsProcessedThisRequest[groupName] {
 							rh.endpointManager.GetGroupManager().ResetGroupRetry(groupName)
@@ -154,38 +284,90 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 						return resp, nil
 					}
 
+					// Upstream asked us to back off: deprioritize the endpoint and try the next one right away
+					if backoff, limited := rh.rateLimitBackoff(resp, policy); limited {
+						lastResp = keepResponse(resp)
+						until := time.Now().Add(backoff)
+						rh.endpointManager.SetEndpointRateLimited(ep.Config.Name, until)
+						upstreamLimitedThisIteration[ep.Config.Name] = true
+						if rh.monitoringMiddleware != nil && resp.StatusCode == http.StatusTooManyRequests {
+							if rl, ok := rh.monitoringMiddleware.(interface {
+								RecordRateLimit(connID string, endpoint string)
+							}); ok {
+								rl.RecordRateLimit(connID, ep.Config.Name)
+							}
+						}
+
+						slog.WarnContext(ctxWithEndpoint, fmt.Sprintf("🚦 [上游限流] 端点: %s (组: %s) - 状态码: %d，%s内跳过该端点，立即尝试下一个端点",
+							ep.Config.Name, groupName, resp.StatusCode, backoff.String()))
+
+						lastErr = &RetryableError{
+							StatusCode:  resp.StatusCode,
+							IsRetryable: true,
+							Reason:      retryDecision.Reason,
+						}
+						endpointRateLimited = true
+						break
+					}
+
 					// Status code indicates we should retry
 					slog.WarnContext(ctxWithEndpoint, fmt.Sprintf("🔄 [需要重试] 端点: %s (组: %s, 尝试 %d/%d) - 状态码: %d (%s)",
-						ep.Config.Name, groupName, attempt, rh.config.Retry.MaxAttempts, resp.StatusCode, retryDecision.Reason))
+						ep.Config.Name, groupName, attempt, maxAttempts, resp.StatusCode, retryDecision.Reason))
 
-					// Close the response body before retrying
-					resp.Body.Close()
+					// Close the response before retrying, keeping a copy for when no retry is left
+					lastResp = keepResponse(resp)
 					lastErr = &RetryableError{
 						StatusCode:  resp.StatusCode,
 						IsRetryable: true,
 						Reason:      retryDecision.Reason,
 					}
 				} else {
+					// The client aborted: stop here without retrying or blaming the endpoint
+					if clientCancelled(ctx) {
+						slog.InfoContext(ctxWithEndpoint, fmt.Sprintf("🚫 [客户端取消] 端点: %s (组: %s, 尝试 %d/%d) - 客户端已断开，停止重试",
+							ep.Config.Name, groupName, attempt, maxAttempts))
+						return nil, ErrClientCancelled
+					}
+					lastResp = nil
+
+					// The body could not be transformed for this endpoint: retrying it cannot help
+					var transformErr *TransformError
+					if errors.As(err, &transformErr) {
+						lastErr = err
+						transformFailed = true
+						slog.WarnContext(ctxWithEndpoint, fmt.Sprintf("🧩 [请求转换] 端点: %s (组: %s) - %s，尝试下一个端点",
+							ep.Config.Name, groupName, err.Error()))
+						break
+					}
+
 					// Network error or other failure
 					lastErr = err
 					if err != nil {
 						slog.WarnContext(ctxWithEndpoint, fmt.Sprintf("❌ [网络错误] 端点: %s (组: %s, 尝试 %d/%d) - 错误: %s",
-							ep.Config.Name, groupName, attempt, rh.config.Retry.MaxAttempts, err.Error()))
+							ep.Config.Name, groupName, attempt, maxAttempts, err.Error()))
 					}
 				}
 
 				// Don't wait after the last attempt on the current endpoint
-				if attempt == rh.config.Retry.MaxAttempts {
+				if attempt == maxAttempts {
 					break
 				}
 
+				if !rh.takeRetryBudget(ctxWithEndpoint, ep.Config.Name) {
+					if lastResp != nil {
+						return lastResp, nil
+					}
+					return nil, fmt.Errorf("%w after %d attempts on endpoint %s, last error: %w", ErrRetryBudgetExhausted, attempt, ep.Config.Name, lastErr)
+				}
+
 				// Record retry (we're about to retry)
 				if rh.monitoringMiddleware != nil && connID != "" {
 					rh.monitoringMiddleware.RecordRetry(connID, ep.Config.Name)
 				}
 
 				// Calculate delay with exponential backoff
-				delay := rh.calculateDelay(attempt)
+				delay := rh.calculateDelay(policy, attempt)
+				rh.recordBackoff(connID, delay)
 
 				slog.InfoContext(ctxWithEndpoint, fmt.Sprintf("⏳ [等待重试] 端点: %s (组: %s) - %s后进行第%d次尝试",
 					ep.Config.Name, groupName, delay.String(), attempt+1))
@@ -201,7 +383,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 						if lastResp != nil {
 							lastResp.Body.Close()
 						}
-						return nil, ctx.Err()
+						return nil, contextError(ctx)
 					case <-ticker.C:
 						// Check if configuration has been updated
 						currentConfigVersion := rh.endpointManager.GetConfigVersion()
@@ -217,17 +399,25 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 				}
 			waitCompleted:
 			}
+			if release != nil {
+				// No attempt used the slot reserved for this endpoint
+				release()
+			}
 
-			slog.ErrorContext(ctxWithEndpoint, fmt.Sprintf("💥 [端点失败] 端点 %s (组: %s) 所有 %d 次尝试均失败",
-				ep.Config.Name, groupName, rh.config.Retry.MaxAttempts))
+			lastEndpointRateLimited = endpointRateLimited
+			if !endpointRateLimited && !transformFailed && !saturatedThisIteration[ep.Config.Name] {
+				slog.ErrorContext(ctxWithEndpoint, fmt.Sprintf("💥 [端点失败] 端点 %s (组: %s) 所有 %d 次尝试均失败",
+					ep.Config.Name, groupName, maxAttempts))
+			}
 
 			// Check if all endpoints in this group have been tried and failed in this iteration
 			groupEndpointsCount := len(groupEndpoints[groupName])
 			failedEndpointsInGroup := 0
 			for _, groupEp := range groupEndpoints[groupName] {
 				// Count endpoints in this group that we've already tried in this iteration
+				// (saturated endpoints were not tried, so the group has not failed yet)
 				for i := 0; i <= endpointIndex; i++ {
-					if endpoints[i].Config.Name == groupEp.Config.Name {
+					if endpoints[i].Config.Name == groupEp.Config.Name && !saturatedThisIteration[groupEp.Config.Name] {
 						failedEndpointsInGroup++
 						break
 					}
@@ -240,8 +430,32 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 			}
 		}
 
+		// Every candidate was at its concurrency or rate limit: nothing was sent, report saturation
+		if len(saturatedThisIteration) == len(endpoints) && rateLimitedThisIteration {
+			slog.WarnContext(ctx, fmt.Sprintf("⏱️ [速率限制] 所有 %d 个候选端点均已达到速率或并发限制", len(endpoints)))
+			return nil, ErrEndpointsRateLimited
+		}
+		if len(saturatedThisIteration) == len(endpoints) {
+			slog.WarnContext(ctx, fmt.Sprintf("🚧 [并发限制] 所有 %d 个候选端点均已达到最大并发数", len(endpoints)))
+			return nil, ErrEndpointsSaturated
+		}
+
+		// A pinned request only says something about its endpoint, not the whole group
+		if pinnedEndpointFromContext(ctx) != nil {
+			break
+		}
+
 		// After trying all endpoints in current iteration, handle failed groups
 		for groupName := range groupsFailedThisIteration {
+			// A group the upstreams rate limited altogether steps aside until the first
+			// endpoint's window ends, instead of counting toward its retries
+			if until, ok := groupRateLimitedUntil(groupEndpoints[groupName], upstreamLimitedThisIteration); ok && !groupsSetToCooldownThisRequest[groupName] {
+				slog.WarnContext(ctx, fmt.Sprintf("🚦 [上游限流] 组 %s 的所有端点均被上游限流，冷却至 %s",
+					groupName, until.Format("15:04:05")))
+				rh.endpointManager.GetGroupManager().SetGroupRateLimited(groupName, until)
+				groupsSetToCooldownThisRequest[groupName] = true
+				continue
+			}
 			if !groupsSetToCooldownThisRequest[groupName] {
 				// Increment retry count for this group
 				shouldCooldown := rh.endpointManager.GetGroupManager().IncrementGroupRetry(groupName)
@@ -258,12 +472,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 
 		// Check if there are still active groups available after cooldown
 		// Get fresh endpoint list to see if any new groups became active
-		var newEndpoints []*endpoint.Endpoint
-		if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
-			newEndpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
-		} else {
-			newEndpoints = rh.endpointManager.GetHealthyEndpoints()
-		}
+		newEndpoints := rh.selectEndpoints(ctx, nil)
 
 		// If we have new endpoints available (from different groups), continue the retry loop
 		if len(newEndpoints) > 0 && len(groupsFailedThisIteration) > 0 {
@@ -301,20 +510,166 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 	return nil, fmt.Errorf("all active groups exhausted after trying %d endpoints, last error: %w", totalEndpointsAttempted, lastErr)
 }
 
-// calculateDelay calculates the delay for exponential backoff
-func (rh *RetryHandler) calculateDelay(attempt int) time.Duration {
+// maxKeptResponseBytes bounds the failed upstream response kept for when the retry budget runs out
+const maxKeptResponseBytes = 1 << 20
+
+// keepResponse reads a failed upstream response into memory and closes it, so it no longer holds
+// the connection or the endpoint's slot but can still be returned to the client. It returns nil
+// when the body cannot be read or is too large to keep.
+func keepResponse(resp *http.Response) *http.Response {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeptResponseBytes+1))
+	if err != nil || len(body) > maxKeptResponseBytes {
+		return nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp
+}
+
+// groupRateLimitedUntil reports whether every endpoint of a group was rate limited by its
+// upstream, and the earliest time one of them may be used again
+func groupRateLimitedUntil(endpoints []*endpoint.Endpoint, limited map[string]bool) (time.Time, bool) {
+	var earliest time.Time
+	for _, ep := range endpoints {
+		if !limited[ep.Config.Name] {
+			return time.Time{}, false
+		}
+		until := ep.GetStatus().RateLimitedUntil
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// selectEndpoints returns the endpoints to try in order. A request pinned to an endpoint only
+// uses that endpoint, and a request routed to a group (by a request rule or a routing override)
+// only uses its target group; otherwise endpoints come from the active groups. A non-nil trace
+// records the decisions without side effects (see endpoint.SelectionTrace).
+func (rh *RetryHandler) selectEndpoints(ctx context.Context, trace *endpoint.SelectionTrace) []*endpoint.Endpoint {
+	if ep := pinnedEndpointFromContext(ctx); ep != nil {
+		return []*endpoint.Endpoint{ep}
+	}
+	if group := routedGroupFromContext(ctx); group != "" {
+		return filterByModel(ctx, rh.endpointManager.SelectHealthyInGroup(group, trace), trace)
+	}
+	if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
+		return filterByModel(ctx, rh.endpointManager.SelectFastest(ctx, trace), trace)
+	}
+	return filterByModel(ctx, rh.endpointManager.SelectHealthy(trace), trace)
+}
+
+// recordIdempotentAttempt counts an upstream attempt that carried the request's idempotency key
+func (rh *RetryHandler) recordIdempotentAttempt(ctx context.Context, connID string) {
+	key := idempotencyKeyFromContext(ctx)
+	if key == "" || connID == "" || rh.monitoringMiddleware == nil {
+		return
+	}
+	if mm, ok := rh.monitoringMiddleware.(interface {
+		RecordIdempotentAttempt(connID string, key string)
+	}); ok {
+		mm.RecordIdempotentAttempt(connID, key)
+	}
+}
+
+// calculateDelay calculates the delay for exponential backoff under the given retry settings
+func (rh *RetryHandler) calculateDelay(policy config.RetryConfig, attempt int) time.Duration {
 	// Calculate exponential backoff: base_delay * (multiplier ^ (attempt - 1))
-	multiplier := math.Pow(rh.config.Retry.Multiplier, float64(attempt-1))
-	delay := time.Duration(float64(rh.config.Retry.BaseDelay) * multiplier)
+	multiplier := math.Pow(policy.Multiplier, float64(attempt-1))
+	delay := time.Duration(float64(policy.BaseDelay) * multiplier)
 
 	// Cap at maximum delay
-	if delay > rh.config.Retry.MaxDelay {
-		delay = rh.config.Retry.MaxDelay
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
 	}
 
 	return delay
 }
 
+// rateLimitBackoff reports whether the response is an upstream rate limit and how long
+// the endpoint should be skipped. 429 always counts (falling back to the base retry delay
+// when the upstream gives no wait time), 503 only when the upstream sends one.
+func (rh *RetryHandler) rateLimitBackoff(resp *http.Response, policy config.RetryConfig) (time.Duration, bool) {
+	retryAfter, hasRetryAfter := upstreamRetryAfter(resp.Header, time.Now())
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		if !hasRetryAfter {
+			return rh.calculateDelay(policy, 1), true
+		}
+		return retryAfter, true
+	case http.StatusServiceUnavailable:
+		return retryAfter, hasRetryAfter
+	default:
+		return 0, false
+	}
+}
+
+// upstreamRetryAfter returns how long the upstream asked us to wait: the longer of its
+// Retry-After header and the reset time of the anthropic-ratelimit-* limits it used up
+func upstreamRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	retryAfter, hasRetryAfter := parseRetryAfter(header.Get("Retry-After"), now)
+	reset, hasReset := anthropicRateLimitReset(header, now)
+	if hasReset && (!hasRetryAfter || reset > retryAfter) {
+		return reset, true
+	}
+	return retryAfter, hasRetryAfter
+}
+
+// anthropicRateLimitReset returns the time until the latest reset of the rate limits an
+// Anthropic-style upstream reports as exhausted, from anthropic-ratelimit-<limit>-reset
+// (RFC 3339) and the matching -remaining header. Without a -remaining header the limit
+// is assumed to be the one that was hit.
+func anthropicRateLimitReset(header http.Header, now time.Time) (time.Duration, bool) {
+	var wait time.Duration
+	found := false
+	for key, values := range header {
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, "anthropic-ratelimit-") || !strings.HasSuffix(name, "-reset") || len(values) == 0 {
+			continue
+		}
+		limit := strings.TrimSuffix(name, "-reset")
+		if remaining := strings.TrimSpace(header.Get(limit + "-remaining")); remaining != "" && remaining != "0" {
+			continue
+		}
+		reset, err := time.Parse(time.RFC3339, strings.TrimSpace(values[0]))
+		if err != nil {
+			continue
+		}
+		found = true
+		if d := reset.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, found
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of
+// seconds or an HTTP-date. Dates in the past yield a zero duration.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
 // shouldRetryStatusCode determines if an HTTP status code should trigger a retry
 func (rh *RetryHandler) shouldRetryStatusCode(statusCode int) *RetryableError {
 	switch {
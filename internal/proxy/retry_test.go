@@ -0,0 +1,371 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"seconds", "120", 120 * time.Second, true},
+		{"seconds with spaces", " 5 ", 5 * time.Second, true},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"http date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"empty", "", 0, false},
+		{"negative", "-3", 0, false},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("parseRetryAfter(%q) = (%v, %v), expected (%v, %v)", tt.value, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	rh := NewRetryHandler(&config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 3, BaseDelay: 2 * time.Second, MaxDelay: 10 * time.Second, Multiplier: 2},
+	})
+
+	newResp := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	if d, ok := rh.rateLimitBackoff(newResp(429, "7"), rh.config.Retry); !ok || d != 7*time.Second {
+		t.Errorf("Expected 429 with Retry-After to back off 7s, got (%v, %v)", d, ok)
+	}
+	if d, ok := rh.rateLimitBackoff(newResp(429, ""), rh.config.Retry); !ok || d != 2*time.Second {
+		t.Errorf("Expected 429 without Retry-After to fall back to base delay, got (%v, %v)", d, ok)
+	}
+	if d, ok := rh.rateLimitBackoff(newResp(503, "3"), rh.config.Retry); !ok || d != 3*time.Second {
+		t.Errorf("Expected 503 with Retry-After to back off 3s, got (%v, %v)", d, ok)
+	}
+	if _, ok := rh.rateLimitBackoff(newResp(503, ""), rh.config.Retry); ok {
+		t.Error("Expected 503 without Retry-After to use the generic retry path")
+	}
+	if _, ok := rh.rateLimitBackoff(newResp(500, "3"), rh.config.Retry); ok {
+		t.Error("Expected 500 not to be treated as rate limiting")
+	}
+}
+
+func TestRetryHandlerSkipsRateLimitedEndpoint(t *testing.T) {
+	var limitedHits int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&limitedHits, 1)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ok.Close()
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "limited", URL: limited.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "ok", URL: ok.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		},
+	}
+
+	manager := endpoint.NewManager(cfg)
+	rh := NewRetryHandler(cfg)
+	rh.SetEndpointManager(manager)
+
+	operation := func(ep *endpoint.Endpoint, connID string) (*http.Response, error) {
+		return http.Get(ep.Config.URL)
+	}
+
+	start := time.Now()
+	resp, err := rh.Execute(operation, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if strings.TrimSpace(string(body)) != "ok" {
+		t.Errorf("Expected response from 'ok' endpoint, got %q", body)
+	}
+	if hits := atomic.LoadInt32(&limitedHits); hits != 1 {
+		t.Errorf("Expected rate-limited endpoint to be hit once, got %d", hits)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected failover without backoff sleep, took %v", elapsed)
+	}
+
+	status := manager.GetEndpointByNameAny("limited").GetStatus()
+	if remaining := time.Until(status.RateLimitedUntil); remaining < 25*time.Second || remaining > 30*time.Second {
+		t.Errorf("Expected endpoint to be rate limited for ~30s, got %v", remaining)
+	}
+
+	// Subsequent requests skip the rate-limited endpoint entirely
+	resp, err = rh.Execute(operation, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if hits := atomic.LoadInt32(&limitedHits); hits != 1 {
+		t.Errorf("Expected rate-limited endpoint to be skipped, got %d hits", hits)
+	}
+}
+
+func TestUpstreamRetryAfterHeaders(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	reset := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected time.Duration
+		ok       bool
+	}{
+		{"retry-after seconds", map[string]string{"Retry-After": "30"}, 30 * time.Second, true},
+		{"retry-after date", map[string]string{"Retry-After": now.Add(45 * time.Second).Format(http.TimeFormat)}, 45 * time.Second, true},
+		{"exhausted limit", map[string]string{
+			"anthropic-ratelimit-tokens-remaining": "0",
+			"anthropic-ratelimit-tokens-reset":     reset(20 * time.Second),
+		}, 20 * time.Second, true},
+		{"only exhausted limits count", map[string]string{
+			"anthropic-ratelimit-requests-remaining": "12",
+			"anthropic-ratelimit-requests-reset":     reset(50 * time.Second),
+			"anthropic-ratelimit-tokens-remaining":   "0",
+			"anthropic-ratelimit-tokens-reset":       reset(20 * time.Second),
+		}, 20 * time.Second, true},
+		{"reset without remaining", map[string]string{"anthropic-ratelimit-output-tokens-reset": reset(15 * time.Second)}, 15 * time.Second, true},
+		{"longer of both", map[string]string{
+			"Retry-After":                          "5",
+			"anthropic-ratelimit-tokens-remaining": "0",
+			"anthropic-ratelimit-tokens-reset":     reset(20 * time.Second),
+		}, 20 * time.Second, true},
+		{"retry-after longer", map[string]string{
+			"Retry-After":                      "60",
+			"anthropic-ratelimit-tokens-reset": reset(20 * time.Second),
+		}, 60 * time.Second, true},
+		{"unparseable reset", map[string]string{"anthropic-ratelimit-tokens-reset": "soon"}, 0, false},
+		{"none", map[string]string{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+			got, ok := upstreamRetryAfter(header, now)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("upstreamRetryAfter(%v) = (%v, %v), expected (%v, %v)", tt.header, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRateLimitHeaderFormsRemoveEndpointFromSelection(t *testing.T) {
+	forms := map[string]func(h http.Header){
+		"seconds": func(h http.Header) { h.Set("Retry-After", "30") },
+		"http date": func(h http.Header) {
+			h.Set("Retry-After", time.Now().Add(30*time.Second).UTC().Format(http.TimeFormat))
+		},
+		"anthropic": func(h http.Header) {
+			h.Set("Anthropic-Ratelimit-Requests-Remaining", "0")
+			h.Set("Anthropic-Ratelimit-Requests-Reset", time.Now().Add(30*time.Second).UTC().Format(time.RFC3339))
+		},
+	}
+
+	for name, setHeader := range forms {
+		t.Run(name, func(t *testing.T) {
+			limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				setHeader(w.Header())
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer limited.Close()
+			ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			}))
+			defer ok.Close()
+
+			cfg := &config.Config{
+				Strategy: config.StrategyConfig{Type: "priority"},
+				Retry:    config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2},
+				Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+				Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+				Endpoints: []config.EndpointConfig{
+					{Name: "limited", URL: limited.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+					{Name: "ok", URL: ok.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second},
+				},
+			}
+			manager := endpoint.NewManager(cfg)
+			rh := NewRetryHandler(cfg)
+			rh.SetEndpointManager(manager)
+
+			resp, err := rh.Execute(func(ep *endpoint.Endpoint, connID string) (*http.Response, error) {
+				return http.Get(ep.Config.URL)
+			}, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			remaining := time.Until(manager.GetEndpointByNameAny("limited").GetStatus().RateLimitedUntil)
+			if remaining < 28*time.Second || remaining > 30*time.Second {
+				t.Errorf("Expected the endpoint to be rate limited for ~30s, got %v", remaining)
+			}
+			for _, ep := range manager.GetHealthyEndpoints() {
+				if ep.Config.Name == "limited" {
+					t.Error("Expected the rate limited endpoint to be removed from selection")
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitedGroupStepsAsideUntilWindowEnds(t *testing.T) {
+	var limitedHits int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&limitedHits, 1)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backup")
+	}))
+	defer backup.Close()
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: 10 * time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "limited", URL: limited.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "backup", URL: backup.URL, Priority: 1, Group: "backup", GroupPriority: 2, Timeout: time.Second},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	rh := NewRetryHandler(cfg)
+	rh.SetEndpointManager(manager)
+
+	// The first request already fails over, without waiting for main's retries to run out
+	resp, err := rh.Execute(func(ep *endpoint.Endpoint, connID string) (*http.Response, error) {
+		return http.Get(ep.Config.URL)
+	}, "")
+	if err != nil {
+		t.Fatalf("Expected failover to the backup group, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "backup" {
+		t.Errorf("Expected the backup group to answer, got %q", body)
+	}
+
+	gm := manager.GetGroupManager()
+	remaining := gm.GetGroupCooldownRemaining("main")
+	if remaining < 28*time.Second || remaining > 30*time.Second {
+		t.Errorf("Expected main to cool down until its rate limit ends (~30s, not the 10m group cooldown), got %v", remaining)
+	}
+	if count := gm.GetGroupRetryCount("main"); count != 0 {
+		t.Errorf("Expected the rate limit not to count toward main's retries, got %d", count)
+	}
+	if hits := atomic.LoadInt32(&limitedHits); hits != 1 {
+		t.Errorf("Expected the limited endpoint to be hit once, got %d", hits)
+	}
+
+	// A longer cooldown is never shortened by a rate limit
+	gm.SetGroupCooldown("main")
+	gm.SetGroupRateLimited("main", time.Now().Add(time.Second))
+	if remaining := gm.GetGroupCooldownRemaining("main"); remaining < 9*time.Minute {
+		t.Errorf("Expected the 10m cooldown to be kept, got %v", remaining)
+	}
+}
+
+func TestGroupRetryPolicies(t *testing.T) {
+	var freeHits, premiumHits int32
+	free := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&freeHits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer free.Close()
+	premium := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&premiumHits, 1) < 5 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "premium")
+	}))
+	defer premium.Close()
+
+	noRetries := 0
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: 10 * time.Minute, MaxRetries: 3},
+		Groups: map[string]config.GroupSettings{
+			"free":    {Retry: &config.GroupRetryConfig{MaxAttempts: 1}, Cooldown: 30 * time.Minute, MaxRetries: &noRetries},
+			"premium": {Retry: &config.GroupRetryConfig{MaxAttempts: 5, BaseDelay: 20 * time.Millisecond, Multiplier: 1}},
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "free", URL: free.URL, Priority: 1, Group: "free", GroupPriority: 1, Timeout: time.Second},
+			{Name: "premium", URL: premium.URL, Priority: 1, Group: "premium", GroupPriority: 2, Timeout: time.Second},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	rh := NewRetryHandler(cfg)
+	rh.SetEndpointManager(manager)
+
+	start := time.Now()
+	resp, err := rh.Execute(func(ep *endpoint.Endpoint, connID string) (*http.Response, error) {
+		return http.Get(ep.Config.URL)
+	}, "")
+	if err != nil {
+		t.Fatalf("Expected the premium group to answer on its fifth attempt, got %v", err)
+	}
+	resp.Body.Close()
+
+	if hits := atomic.LoadInt32(&freeHits); hits != 1 {
+		t.Errorf("Expected the free group to be tried once, got %d", hits)
+	}
+	if hits := atomic.LoadInt32(&premiumHits); hits != 5 {
+		t.Errorf("Expected the premium group to be tried 5 times, got %d", hits)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected 4 waits of the premium group's 20ms delay, not the global 1s, took %v", elapsed)
+	}
+
+	gm := manager.GetGroupManager()
+	if remaining := gm.GetGroupCooldownRemaining("free"); remaining < 29*time.Minute || remaining > 30*time.Minute {
+		t.Errorf("Expected the free group to cool down for its own 30m after one failure, got %v", remaining)
+	}
+	if delay := rh.calculateDelay(cfg.GetGroupRetry("premium"), 3); delay != 20*time.Millisecond {
+		t.Errorf("Expected the premium group's delay not to grow with multiplier 1, got %v", delay)
+	}
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+)
+
+// HeaderForwarderMaxRetries lets a client lower (or, up to retry.max_attempts_ceiling, raise)
+// the number of retries per endpoint for one request. It is never forwarded upstream.
+const HeaderForwarderMaxRetries = "X-Forwarder-Max-Retries"
+
+// maxAttemptsContextKey carries the per-endpoint attempt limit requested by the client
+const maxAttemptsContextKey = contextKey("max_attempts")
+
+// ErrRetryBudgetExhausted is returned when a request needed another attempt after the
+// server-wide retry budget (retry.budget_per_minute) ran out
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudgetWindow is the period retry.budget_per_minute is counted over
+const retryBudgetWindow = time.Minute
+
+// retryBudget counts the retries made across all requests in the current one-minute window
+type retryBudget struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	used        int
+}
+
+// take reserves one retry from a budget of limit retries per window (0 = unlimited)
+func (b *retryBudget) take(limit int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollWindow(now)
+	if limit > 0 && b.used >= limit {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// usage returns the retries made in the current window and when the window resets
+func (b *retryBudget) usage(now time.Time) (used int, resetIn time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollWindow(now)
+	return b.used, b.windowStart.Add(retryBudgetWindow).Sub(now)
+}
+
+// rollWindow starts a new window once the current one has passed
+func (b *retryBudget) rollWindow(now time.Time) {
+	if now.Sub(b.windowStart) >= retryBudgetWindow {
+		b.windowStart = now
+		b.used = 0
+	}
+}
+
+// takeRetryBudget reserves a retry for the request, logging a warning when the budget is spent
+func (rh *RetryHandler) takeRetryBudget(ctx context.Context, endpointName string) bool {
+	limit := rh.config.Retry.BudgetPerMinute
+	if rh.budget.take(limit, time.Now()) {
+		return true
+	}
+	slog.WarnContext(ctx, fmt.Sprintf("💸 [重试预算] 每分钟 %d 次重试的预算已用完，不再重试或切换端点 (端点: %s)", limit, endpointName))
+	return false
+}
+
+// RetryBudgetUsage returns the retries made in the current minute, the configured budget
+// (0 = unlimited) and the time until the count resets
+func (rh *RetryHandler) RetryBudgetUsage() (used, limit int, resetIn time.Duration) {
+	used, resetIn = rh.budget.usage(time.Now())
+	return used, rh.config.Retry.BudgetPerMinute, resetIn
+}
+
+// RetryBudgetUsage returns the retry budget consumption, see RetryHandler.RetryBudgetUsage
+func (h *Handler) RetryBudgetUsage() (used, limit int, resetIn time.Duration) {
+	return h.retryHandler.RetryBudgetUsage()
+}
+
+// applyRetryOverride handles the X-Forwarder-Max-Retries header: it strips the header and
+// limits the request to that many retries per endpoint, clamped to retry.max_attempts_ceiling.
+// It reports whether the request was already answered with an error.
+func (h *Handler) applyRetryOverride(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := r.Header[http.CanonicalHeaderKey(HeaderForwarderMaxRetries)]; !ok {
+		return false
+	}
+	value := strings.TrimSpace(r.Header.Get(HeaderForwarderMaxRetries))
+	r.Header.Del(HeaderForwarderMaxRetries)
+
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.TypeOverrideInvalid,
+			fmt.Sprintf("%s must be a non-negative integer, got %q", HeaderForwarderMaxRetries, value))
+		return true
+	}
+
+	attempts := retries + 1
+	if ceiling := h.config.Retry.MaxAttemptsCeiling; ceiling > 0 && attempts > ceiling {
+		attempts = ceiling
+	}
+	slog.DebugContext(r.Context(), fmt.Sprintf("🔁 [重试覆盖] 请求指定每个端点最多 %d 次重试，实际尝试上限: %d", retries, attempts))
+	*r = *r.WithContext(context.WithValue(r.Context(), maxAttemptsContextKey, attempts))
+	return false
+}
+
+// maxAttemptsFor returns the attempts per endpoint for the request: one for a streamed body,
+// the client's X-Forwarder-Max-Retries when it sent one, otherwise max_attempts of the
+// retry settings of the endpoint's group
+func (rh *RetryHandler) maxAttemptsFor(ctx context.Context, policy config.RetryConfig) int {
+	if bodyStreamed(ctx) {
+		return 1
+	}
+	if attempts, ok := ctx.Value(maxAttemptsContextKey).(int); ok {
+		return attempts
+	}
+	return policy.MaxAttempts
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+)
+
+// sendMaxRetriesTestRequest sends a request with the given X-Forwarder-Max-Retries value (none when empty)
+func sendMaxRetriesTestRequest(handler *Handler, maxRetries string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude"}`))
+	if maxRetries != "" {
+		req.Header.Set(HeaderForwarderMaxRetries, maxRetries)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMaxRetriesHeader(t *testing.T) {
+	upstream, hits, _ := newBodyRecordingUpstream(t, http.StatusInternalServerError)
+	handler, _ := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "failing", URL: upstream.URL, Priority: 1})
+	handler.config.Retry.MaxAttemptsCeiling = 4
+
+	tests := []struct {
+		header   string
+		expected int64
+	}{
+		{"", 1},   // retry.max_attempts
+		{"2", 3},  // 2 retries
+		{"10", 4}, // clamped to max_attempts_ceiling
+		{"0", 1},
+	}
+	for _, tt := range tests {
+		hits.Store(0)
+		sendMaxRetriesTestRequest(handler, tt.header)
+		if hits.Load() != tt.expected {
+			t.Errorf("Header %q: expected %d attempts, got %d", tt.header, tt.expected, hits.Load())
+		}
+	}
+
+	rec := sendMaxRetriesTestRequest(handler, "many")
+	if rec.Code != http.StatusBadRequest || errorType(t, rec) != apierror.TypeOverrideInvalid {
+		t.Errorf("Expected 400 %s for an invalid header, got %d: %s", apierror.TypeOverrideInvalid, rec.Code, rec.Body.String())
+	}
+}
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	failing, failingHits, _ := newBodyRecordingUpstream(t, http.StatusInternalServerError)
+	backup, backupHits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, _ := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "failing", URL: failing.URL, Priority: 1},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2},
+	)
+	handler.config.Retry.MaxAttempts = 3
+	handler.config.Retry.BudgetPerMinute = 3
+
+	// Two retries on the failing endpoint and the switch to the backup use up the budget
+	if rec := sendMaxRetriesTestRequest(handler, ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the backup to answer, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if failingHits.Load() != 3 || backupHits.Load() != 1 {
+		t.Fatalf("Expected 3 attempts on the failing endpoint and 1 on the backup, got %d and %d", failingHits.Load(), backupHits.Load())
+	}
+
+	// Now the request is forwarded once, without retries or failover, and the client gets the
+	// upstream's answer
+	rec := sendMaxRetriesTestRequest(handler, "")
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("Expected the upstream 500 response, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if failingHits.Load() != 4 || backupHits.Load() != 1 {
+		t.Errorf("Expected a single extra attempt on the failing endpoint, got %d and %d", failingHits.Load(), backupHits.Load())
+	}
+
+	used, limit, resetIn := handler.RetryBudgetUsage()
+	if used != 3 || limit != 3 || resetIn <= 0 || resetIn > time.Minute {
+		t.Errorf("Expected 3/3 retries used with a reset within a minute, got %d/%d in %s", used, limit, resetIn)
+	}
+}
+
+func TestRetryBudgetExhaustedAfterNetworkError(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	handler, _ := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "unreachable", URL: unreachable.URL, Priority: 1})
+	handler.config.Retry.MaxAttempts = 3
+	handler.config.Retry.BudgetPerMinute = 1
+
+	// Without an upstream response to pass on, the client is told the budget ran out
+	rec := sendMaxRetriesTestRequest(handler, "")
+	if rec.Code != http.StatusBadGateway || errorType(t, rec) != apierror.TypeRetryBudgetExhausted {
+		t.Fatalf("Expected 502 %s, got %d: %s", apierror.TypeRetryBudgetExhausted, rec.Code, rec.Body.String())
+	}
+}
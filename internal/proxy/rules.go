@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// routedGroupContextKey carries the group a request rule routed the request to
+const routedGroupContextKey = contextKey("routed_group")
+
+// requestRule is a compiled request rule
+type requestRule struct {
+	config.RuleConfig
+	regex     *regexp.Regexp
+	pathRegex *regexp.Regexp
+}
+
+// ruleSet is the compiled set of request rules for one configuration. A new set is built
+// on every config reload and swapped in atomically, so in-flight requests keep the old one.
+type ruleSet struct {
+	rules []requestRule
+}
+
+// compileRules builds the rule set for a configuration. Patterns were checked during
+// config validation, so rules that still fail to compile are skipped.
+func compileRules(cfgs []config.RuleConfig) *ruleSet {
+	rs := &ruleSet{}
+	for _, cfg := range cfgs {
+		rule := requestRule{RuleConfig: cfg}
+		if cfg.Match.Regex != "" {
+			re, err := regexp.Compile(cfg.Match.Regex)
+			if err != nil {
+				slog.Error(fmt.Sprintf("❌ [请求规则] 规则 %s 的正则表达式无效，已跳过: %v", cfg.Name, err))
+				continue
+			}
+			rule.regex = re
+		}
+		if cfg.Match.PathRegex != "" {
+			re, err := regexp.Compile(cfg.Match.PathRegex)
+			if err != nil {
+				slog.Error(fmt.Sprintf("❌ [请求规则] 规则 %s 的路径正则表达式无效，已跳过: %v", cfg.Name, err))
+				continue
+			}
+			rule.pathRegex = re
+		}
+		rs.rules = append(rs.rules, rule)
+	}
+	return rs
+}
+
+// match returns the first rule matching the request, or nil
+func (rs *ruleSet) match(r *http.Request, clientID string, bodyBytes []byte) *requestRule {
+	if rs == nil || len(rs.rules) == 0 {
+		return nil
+	}
+
+	// The body is decoded at most once, and only when a rule looks at a body field
+	var body map[string]json.RawMessage
+	bodyDecoded := false
+
+	for i := range rs.rules {
+		rule := &rs.rules[i]
+		m := rule.Match
+
+		if m.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, m.PathPrefix) {
+			continue
+		}
+		if rule.pathRegex != nil && !rule.pathRegex.MatchString(r.URL.Path) {
+			continue
+		}
+		if m.Method != "" && !strings.EqualFold(m.Method, r.Method) {
+			continue
+		}
+		if m.Client != "" {
+			if ok, _ := path.Match(m.Client, clientID); !ok {
+				continue
+			}
+		}
+		if m.Glob != "" || m.Regex != "" {
+			if !bodyDecoded {
+				bodyDecoded = true
+				if err := json.Unmarshal(bodyBytes, &body); err != nil {
+					body = nil
+				}
+			}
+			value, ok := bodyField(body, m.Field)
+			if !ok {
+				continue
+			}
+			if m.Glob != "" {
+				if matched, _ := path.Match(m.Glob, value); !matched {
+					continue
+				}
+			} else if !rule.regex.MatchString(value) {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+// bodyField returns a top-level JSON field as a string; non-string values use their JSON text
+func bodyField(body map[string]json.RawMessage, field string) (string, bool) {
+	raw, ok := body[field]
+	if !ok {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, true
+	}
+	return string(raw), true
+}
+
+// rewriteModel replaces the model field of a JSON request body
+func rewriteModel(bodyBytes []byte, model string) ([]byte, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return nil, fmt.Errorf("request body is not a JSON object: %w", err)
+	}
+	encoded, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	body["model"] = encoded
+	return json.Marshal(body)
+}
+
+// applyRules evaluates the request rules and applies the matching rule's action. It returns
+// the (possibly rewritten) body and whether the request was already answered.
+func (h *Handler) applyRules(w http.ResponseWriter, r *http.Request, bodyBytes []byte) ([]byte, bool) {
+	ctx := r.Context()
+	clientID := middleware.ClientIDFromContext(ctx)
+	if clientID == "" {
+		clientID = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			clientID = host
+		}
+	}
+
+	rule := h.rules.Load().match(r, clientID, bodyBytes)
+	if rule == nil {
+		return bodyBytes, false
+	}
+
+	connID, _ := ctx.Value("conn_id").(string)
+	if rh, ok := h.retryHandler.monitoringMiddleware.(interface {
+		RecordRuleHit(connID string, rule string)
+	}); ok {
+		rh.RecordRuleHit(connID, rule.Name)
+	}
+
+	switch rule.Action.Type {
+	case config.RuleActionDeny:
+		slog.WarnContext(ctx, fmt.Sprintf("🧱 [请求规则] 命中规则 %s，拒绝请求: %s %s (客户端: %s, 状态码: %d)",
+			rule.Name, r.Method, r.URL.Path, clientID, rule.Action.Status))
+		apierror.Write(w, rule.Action.Status, apierror.TypeRequestDenied, rule.Action.Message)
+		return nil, true
+
+	case config.RuleActionRewriteModel:
+		rewritten, err := rewriteModel(bodyBytes, rule.Action.Model)
+		if err != nil {
+			slog.WarnContext(ctx, fmt.Sprintf("⚠️ [请求规则] 命中规则 %s，但无法改写模型: %v", rule.Name, err))
+			return bodyBytes, false
+		}
+		slog.InfoContext(ctx, fmt.Sprintf("🧱 [请求规则] 命中规则 %s，模型改写为: %s (%s %s)",
+			rule.Name, rule.Action.Model, r.Method, r.URL.Path))
+		r.ContentLength = int64(len(rewritten))
+		r.Header.Set("Content-Length", strconv.Itoa(len(rewritten)))
+		return rewritten, false
+
+	case config.RuleActionRouteToGroup:
+		slog.InfoContext(ctx, fmt.Sprintf("🧱 [请求规则] 命中规则 %s，路由到组: %s (%s %s)",
+			rule.Name, rule.Action.Group, r.Method, r.URL.Path))
+		*r = *r.WithContext(context.WithValue(ctx, routedGroupContextKey, rule.Action.Group))
+	}
+
+	return bodyBytes, false
+}
+
+// routedGroupFromContext returns the group a request rule routed the request to, if any
+func routedGroupFromContext(ctx context.Context) string {
+	group, _ := ctx.Value(routedGroupContextKey).(string)
+	return group
+}
@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// bodyRecorder is an upstream that records the last request body and Content-Length
+type bodyRecorder struct {
+	mu            sync.Mutex
+	hits          int
+	body          []byte
+	contentLength int64
+}
+
+func newBodyRecorder(t *testing.T) (*httptest.Server, *bodyRecorder) {
+	t.Helper()
+	rec := &bodyRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.hits++
+		rec.body = body
+		rec.contentLength = r.ContentLength
+		rec.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, rec
+}
+
+func (br *bodyRecorder) last() (int, []byte, int64) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.hits, br.body, br.contentLength
+}
+
+func newRulesTestConfig(rules []config.RuleConfig, endpoints ...config.EndpointConfig) *config.Config {
+	return &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Retry:     config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1},
+		Health:    config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:     config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Rules:     rules,
+		Endpoints: endpoints,
+	}
+}
+
+func TestRuleDeny(t *testing.T) {
+	upstream, recorder := newBodyRecorder(t)
+	rules := []config.RuleConfig{{
+		Name:   "block-opus",
+		Match:  config.RuleMatchConfig{PathPrefix: "/v1/messages", Field: "model", Glob: "claude-3-opus*"},
+		Action: config.RuleActionConfig{Type: config.RuleActionDeny, Status: http.StatusForbidden, Message: "opus is not allowed"},
+	}}
+	cfg := newRulesTestConfig(rules,
+		config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	request := `{"model":"claude-3-opus-20240229","stream":true,"messages":[]}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(request)))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 from deny rule, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Type != apierror.TypeRequestDenied || body.Error.Message != "opus is not allowed" {
+		t.Errorf("Unexpected deny response: %s", rec.Body.String())
+	}
+	if hits, _, _ := recorder.last(); hits != 0 {
+		t.Errorf("Denied request must not reach the upstream, got %d hits", hits)
+	}
+
+	// Other models pass through
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-5-sonnet"}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected unmatched request to be forwarded, got %d", rec.Code)
+	}
+
+	// Reloading without rules stops denying
+	reloaded := *cfg
+	reloaded.Rules = nil
+	handler.UpdateConfig(&reloaded)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(request)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected request to be forwarded after rules were removed, got %d", rec.Code)
+	}
+}
+
+func TestRuleRewriteModel(t *testing.T) {
+	upstream, recorder := newBodyRecorder(t)
+	rules := []config.RuleConfig{{
+		Name:   "downgrade",
+		Match:  config.RuleMatchConfig{Field: "model", Regex: "^claude-3-opus"},
+		Action: config.RuleActionConfig{Type: config.RuleActionRewriteModel, Model: "claude-3-5-haiku-latest"},
+	}}
+	cfg := newRulesTestConfig(rules,
+		config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	request := `{"model":"claude-3-opus-20240229","max_tokens":16}`
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(request))
+	req.Header.Set("Content-Length", "48")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_, body, contentLength := recorder.last()
+	var forwarded map[string]interface{}
+	if err := json.Unmarshal(body, &forwarded); err != nil {
+		t.Fatalf("Upstream received invalid JSON: %s", body)
+	}
+	if forwarded["model"] != "claude-3-5-haiku-latest" {
+		t.Errorf("Expected rewritten model, got %v", forwarded["model"])
+	}
+	if forwarded["max_tokens"] != float64(16) {
+		t.Errorf("Expected other fields to be preserved, got %v", forwarded["max_tokens"])
+	}
+	if contentLength != int64(len(body)) {
+		t.Errorf("Expected Content-Length %d, got %d", len(body), contentLength)
+	}
+}
+
+func TestRuleRouteToGroup(t *testing.T) {
+	mainUpstream, mainRecorder := newBodyRecorder(t)
+	batchUpstream, batchRecorder := newBodyRecorder(t)
+	rules := []config.RuleConfig{{
+		Name:   "batch-clients",
+		Match:  config.RuleMatchConfig{Client: "batch-*"},
+		Action: config.RuleActionConfig{Type: config.RuleActionRouteToGroup, Group: "batch"},
+	}}
+	cfg := newRulesTestConfig(rules,
+		config.EndpointConfig{Name: "primary", URL: mainUpstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "batch-1", URL: batchUpstream.URL, Priority: 1, Group: "batch", GroupPriority: 2, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	// The batch group is not active, but the routed request still goes there
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-5-sonnet"}`))
+	req = req.WithContext(middleware.WithClientID(req.Context(), "batch-nightly"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits, _, _ := batchRecorder.last(); hits != 1 {
+		t.Errorf("Expected routed request on the batch group, got %d hits", hits)
+	}
+	if hits, _, _ := mainRecorder.last(); hits != 0 {
+		t.Errorf("Expected no requests on the active group, got %d hits", hits)
+	}
+
+	// Other clients use the active group
+	req = httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"claude-3-5-sonnet"}`))
+	req = req.WithContext(middleware.WithClientID(req.Context(), "interactive"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if hits, _, _ := mainRecorder.last(); hits != 1 {
+		t.Errorf("Expected unmatched request on the active group, got %d hits", hits)
+	}
+}
+
+func TestRuleRoutePathToGroup(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	backupUpstream, backupRecorder := newBodyRecorder(t)
+	embeddingsUpstream, embeddingsRecorder := newBodyRecorder(t)
+	rules := []config.RuleConfig{{
+		Name:   "embeddings",
+		Match:  config.RuleMatchConfig{PathRegex: "^/v1/embeddings$", Method: "post"},
+		Action: config.RuleActionConfig{Type: config.RuleActionRouteToGroup, Group: "embeddings"},
+	}}
+	cfg := newRulesTestConfig(rules,
+		config.EndpointConfig{Name: "main-1", URL: failing.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		config.EndpointConfig{Name: "backup-1", URL: backupUpstream.URL, Priority: 1, Group: "backup", GroupPriority: 2, Timeout: time.Second},
+		config.EndpointConfig{Name: "embed-1", URL: embeddingsUpstream.URL, Priority: 1, Group: "embeddings", GroupPriority: 3, Timeout: time.Second})
+	cfg.Group.MaxRetries = 1
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	serve := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(`{"input":"hello"}`)))
+		return rec.Code
+	}
+
+	if code := serve("POST", "/v1/embeddings"); code != http.StatusOK {
+		t.Fatalf("Expected the embeddings request to succeed, got %d", code)
+	}
+	if hits, _, _ := embeddingsRecorder.last(); hits != 1 {
+		t.Errorf("Expected the embeddings request on the embeddings group, got %d hits", hits)
+	}
+
+	// Messages fail over from main to backup as usual, never reaching the embeddings group
+	serve("POST", "/v1/messages")
+	if code := serve("POST", "/v1/messages"); code != http.StatusOK {
+		t.Fatalf("Expected messages to fail over to the backup group, got %d", code)
+	}
+	if hits, _, _ := backupRecorder.last(); hits != 1 {
+		t.Errorf("Expected one message on the backup group, got %d hits", hits)
+	}
+
+	// The method is part of the match: a GET is not routed and goes to the active backup group
+	serve("GET", "/v1/embeddings")
+	if hits, _, _ := embeddingsRecorder.last(); hits != 1 {
+		t.Errorf("Expected only the POST to reach the embeddings group, got %d hits", hits)
+	}
+	if hits, _, _ := backupRecorder.last(); hits != 2 {
+		t.Errorf("Expected the GET on the active group, got %d hits", hits)
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestSetupModeReturnsNotConfigured(t *testing.T) {
+	upstream, _ := newCountingUpstream(t)
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+	}
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 in setup mode, got %d", rec.Code)
+	}
+
+	var body struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Type != apierror.TypeNotConfigured {
+		t.Errorf("Expected forwarder_not_configured JSON error, got %s", rec.Body.String())
+	}
+
+	// Activating a config with endpoints starts forwarding
+	active := *cfg
+	active.Endpoints = []config.EndpointConfig{
+		{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+	}
+	handler.UpdateConfig(&active)
+	manager.UpdateConfig(&active)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected request to be forwarded after activation, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// recoveryProbeContextKey marks a request forwarded as a recovery probe while shedding load
+const recoveryProbeContextKey = contextKey("recovery_probe")
+
+// shedState tracks load shedding across requests
+type shedState struct {
+	mutex     sync.Mutex
+	active    bool      // Requests are being shed
+	lastProbe time.Time // When the last recovery probe was let through
+	probes    int       // Recovery probes sent, rotating through the candidates
+}
+
+// isRecoveryProbe reports whether the request was let through as a recovery probe
+func isRecoveryProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(recoveryProbeContextKey).(bool)
+	return probe
+}
+
+// shedLoad answers the request with 503 right away while no endpoint can take requests
+// (load_shedding), and reports whether it did. Once per recovery_probe_interval a request is
+// let through instead, pinned to the next recovery candidate, so recovery is noticed even
+// while health checks fail too.
+func (h *Handler) shedLoad(w http.ResponseWriter, r *http.Request) bool {
+	cfg := h.config.LoadShedding
+	if !cfg.Enabled || pinnedEndpointFromContext(r.Context()) != nil {
+		return false
+	}
+
+	ctx := r.Context()
+	down, recovery := h.endpointManager.Outage()
+	h.shedding.mutex.Lock()
+	if !down {
+		if h.shedding.active {
+			h.shedding.active = false
+			slog.InfoContext(ctx, "✅ [负载保护] 已有端点可用，恢复正常转发")
+		}
+		h.shedding.mutex.Unlock()
+		return false
+	}
+	if !h.shedding.active {
+		h.shedding.active = true
+		// The first request after the outage starts waits a full interval before probing
+		h.shedding.lastProbe = time.Now()
+		slog.WarnContext(ctx, fmt.Sprintf("⛔ [负载保护] 没有可用的端点，开始直接返回 503 (每 %v 放行一个恢复探测请求)",
+			cfg.RecoveryProbeInterval))
+	}
+
+	now := time.Now()
+	nextProbe := h.shedding.lastProbe.Add(cfg.RecoveryProbeInterval)
+	var probe *endpoint.Endpoint
+	if !now.Before(nextProbe) {
+		if candidates := h.endpointManager.RecoveryCandidates(); len(candidates) > 0 {
+			probe = candidates[h.shedding.probes%len(candidates)]
+			h.shedding.probes++
+			h.shedding.lastProbe = now
+		}
+	}
+	h.shedding.mutex.Unlock()
+
+	if probe != nil {
+		slog.InfoContext(ctx, fmt.Sprintf("🩺 [负载保护] 放行恢复探测请求到端点: %s (组: %s) - %s %s",
+			probe.Config.Name, endpointGroup(probe), r.Method, r.URL.Path))
+		ctx = context.WithValue(ctx, pinnedEndpointContextKey, probe)
+		*r = *r.WithContext(context.WithValue(ctx, recoveryProbeContextKey, true))
+		return false
+	}
+
+	// Retry once the soonest cooldown ends, or at the next probe when no recovery time is known
+	if recovery <= 0 {
+		recovery = time.Until(nextProbe)
+	}
+	seconds := int(math.Ceil(recovery.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	slog.DebugContext(ctx, fmt.Sprintf("⛔ [负载保护] 没有可用的端点，直接返回 503: %s %s (Retry-After: %ds)", r.Method, r.URL.Path, seconds))
+	connID, _ := ctx.Value("conn_id").(string)
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+		RecordRejected(connID string, reason string)
+	}); ok && connID != "" {
+		mm.RecordRejected(connID, monitor.RejectShed)
+	}
+	apierror.WriteRetryAfter(w, http.StatusServiceUnavailable, apierror.TypeShed,
+		"No endpoint can take requests right now: all are unhealthy or in cooldown", seconds)
+	return true
+}
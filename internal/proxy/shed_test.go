@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+)
+
+func TestLoadSheddingDuringOutage(t *testing.T) {
+	upstream, hits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1})
+	handler.config.Retry.MaxAttempts = 3
+	handler.config.LoadShedding = config.LoadSheddingConfig{Enabled: true, RecoveryProbeInterval: 100 * time.Millisecond}
+	manager.GetGroupManager().SetGroupCooldown("main")
+
+	start := time.Now()
+	rec := sendMaxRetriesTestRequest(handler, "")
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected a shed request to fail fast, took %v", elapsed)
+	}
+	if rec.Code != http.StatusServiceUnavailable || errorType(t, rec) != apierror.TypeShed {
+		t.Fatalf("Expected 503 %s, got %d: %s", apierror.TypeShed, rec.Code, rec.Body.String())
+	}
+	var body apierror.Envelope
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Header().Get("Retry-After") != "60" || body.Error.RetryAfterSeconds != 60 {
+		t.Errorf("Expected a retry after the 60s cooldown, got header %q and body %d",
+			rec.Header().Get("Retry-After"), body.Error.RetryAfterSeconds)
+	}
+	if hits.Load() != 0 {
+		t.Fatalf("Expected no upstream call while shedding, got %d", hits.Load())
+	}
+
+	// After the probe interval one request reaches the upstream and its success ends the outage
+	time.Sleep(120 * time.Millisecond)
+	if rec := sendMaxRetriesTestRequest(handler, ""); rec.Code != http.StatusOK || hits.Load() != 1 {
+		t.Fatalf("Expected the recovery probe to reach the upstream, got %d with %d hits", rec.Code, hits.Load())
+	}
+	if manager.GetGroupManager().IsGroupInCooldown("main") {
+		t.Error("Expected the probe's success to end the group cooldown")
+	}
+	if rec := sendMaxRetriesTestRequest(handler, ""); rec.Code != http.StatusOK || hits.Load() != 2 {
+		t.Errorf("Expected normal forwarding after recovery, got %d with %d hits", rec.Code, hits.Load())
+	}
+}
+
+func TestLoadSheddingDisabled(t *testing.T) {
+	upstream, hits, _ := newBodyRecordingUpstream(t, http.StatusOK)
+	handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1})
+	manager.GetGroupManager().SetGroupCooldown("main")
+
+	if rec := sendMaxRetriesTestRequest(handler, ""); errorType(t, rec) == apierror.TypeShed || hits.Load() != 0 {
+		t.Errorf("Expected the usual outage handling without load_shedding, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"bytes"
+	"strings"
+)
+
+// maxSSELineSize bounds a single pending SSE line so a misbehaving upstream cannot grow it forever
+const maxSSELineSize = 1 << 20
+
+// SSEEvent is a complete Server-Sent Event
+type SSEEvent struct {
+	Event string // Value of the "event:" field, empty when not sent
+	Data  string // All "data:" lines joined with "\n"
+}
+
+// SSEEventAssembler reassembles complete SSE events from arbitrarily chunked stream reads.
+// An event is dispatched when its terminating blank line arrives, so a data payload split
+// across reads (or across several data lines) is always delivered whole.
+type SSEEventAssembler struct {
+	line      []byte
+	event     string
+	data      strings.Builder
+	hasData   bool
+	skipLine  bool // Current line exceeded maxSSELineSize and is being discarded
+	lastWasCR bool // Previous chunk ended with '\r', so a leading '\n' belongs to the same line break
+}
+
+// NewSSEEventAssembler creates a new SSE event assembler
+func NewSSEEventAssembler() *SSEEventAssembler {
+	return &SSEEventAssembler{}
+}
+
+// Write feeds raw stream bytes and returns the events completed by them
+func (a *SSEEventAssembler) Write(chunk []byte) []SSEEvent {
+	var events []SSEEvent
+
+	for len(chunk) > 0 {
+		if a.lastWasCR {
+			a.lastWasCR = false
+			if chunk[0] == '\n' {
+				chunk = chunk[1:]
+				continue
+			}
+		}
+
+		i := bytes.IndexAny(chunk, "\r\n")
+		if i < 0 {
+			a.appendLine(chunk)
+			break
+		}
+
+		a.appendLine(chunk[:i])
+		if chunk[i] == '\r' {
+			if i+1 < len(chunk) && chunk[i+1] == '\n' {
+				i++
+			} else if i+1 == len(chunk) {
+				a.lastWasCR = true
+			}
+		}
+		chunk = chunk[i+1:]
+
+		if event, ok := a.processLine(); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// Flush returns the pending event when the stream ends without a trailing blank line
+func (a *SSEEventAssembler) Flush() []SSEEvent {
+	var events []SSEEvent
+	if len(a.line) > 0 {
+		if event, ok := a.processLine(); ok {
+			events = append(events, event)
+		}
+	}
+	if event, ok := a.dispatch(); ok {
+		events = append(events, event)
+	}
+	return events
+}
+
+// Pending reports whether part of an event has been received but not dispatched yet
+func (a *SSEEventAssembler) Pending() bool {
+	return len(a.line) > 0 || a.hasData || a.event != ""
+}
+
+// Reset discards any partially assembled event
+func (a *SSEEventAssembler) Reset() {
+	*a = SSEEventAssembler{}
+}
+
+func (a *SSEEventAssembler) appendLine(b []byte) {
+	if a.skipLine {
+		return
+	}
+	if len(a.line)+len(b) > maxSSELineSize {
+		a.line = a.line[:0]
+		a.skipLine = true
+		return
+	}
+	a.line = append(a.line, b...)
+}
+
+// processLine handles one complete line; a blank line dispatches the pending event
+func (a *SSEEventAssembler) processLine() (SSEEvent, bool) {
+	line := string(a.line)
+	skipped := a.skipLine
+	a.line = a.line[:0]
+	a.skipLine = false
+
+	if skipped {
+		return SSEEvent{}, false
+	}
+	if line == "" {
+		return a.dispatch()
+	}
+	if strings.HasPrefix(line, ":") {
+		// Comment (e.g. heartbeat)
+		return SSEEvent{}, false
+	}
+
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+
+	switch field {
+	case "event":
+		a.event = value
+	case "data":
+		if a.hasData {
+			a.data.WriteByte('\n')
+		}
+		a.data.WriteString(value)
+		a.hasData = true
+	}
+	return SSEEvent{}, false
+}
+
+func (a *SSEEventAssembler) dispatch() (SSEEvent, bool) {
+	if !a.hasData && a.event == "" {
+		return SSEEvent{}, false
+	}
+
+	event := SSEEvent{Event: a.event, Data: a.data.String()}
+	a.event = ""
+	a.data.Reset()
+	a.hasData = false
+	return event, true
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,26 +12,32 @@ import (
 	"strings"
 	"time"
 
+	"endpoint_forwarder/internal/apierror"
 	"endpoint_forwarder/internal/endpoint"
 	"endpoint_forwarder/internal/monitor"
-	"endpoint_forwarder/internal/transport"
 )
 
 // handleSSERequest handles Server-Sent Events streaming requests
 func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyBytes []byte) {
 	slog.InfoContext(r.Context(), "🚀 [SSE Handler] 开始处理SSE流式请求", "method", r.Method, "path", r.URL.Path, "bodySize", len(bodyBytes))
+
+	// A resumable stream outlives its client for streaming.replay_window (streaming.replay_buffer_kb)
+	ctx := r.Context()
+	if h.config.Streaming.ReplayEnabled() {
+		var finishReplay func()
+		w, ctx, finishReplay = h.startReplay(w, r)
+		defer finishReplay()
+	}
 	
 	// Set SSE headers immediately
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
 	// Enable flushing
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, apierror.TypeStreamingUnsupported, "Streaming unsupported")
 		return
 	}
 
@@ -41,17 +48,12 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 	}
 
 	// Get healthy endpoints with fast testing if enabled
-	ctx := r.Context()
-	var endpoints []*endpoint.Endpoint
-	if h.endpointManager.GetConfig().Strategy.Type == "fastest" && h.endpointManager.GetConfig().Strategy.FastTestEnabled {
-		endpoints = h.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
-	} else {
-		endpoints = h.endpointManager.GetHealthyEndpoints()
-	}
+	endpoints := h.retryHandler.selectEndpoints(ctx, nil)
 	
 	if len(endpoints) == 0 {
+		w.Header().Set(apierror.HeaderError, "true")
 		w.WriteHeader(http.StatusServiceUnavailable)
-		h.writeSSEError(w, "No healthy endpoints available", flusher)
+		h.writeSSEError(w, apierror.TypeNoHealthyEndpoints, "No healthy endpoints available")
 		return
 	}
 
@@ -61,7 +63,52 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 		endpoints[0].Config.Name, len(endpoints)))
 
 	// Try endpoints in order until one succeeds
+	saturated := 0
+	rateLimited := false
+	wroteEvents := false // Retry events were sent, so an upstream error can no longer be passed through
 	for i, ep := range endpoints {
+		// Take a rate limit token for the stream, queueing for it if the endpoint is configured to
+		if ok, err := takeRateToken(ctx, ep); err != nil {
+			slog.InfoContext(ctx, fmt.Sprintf("🚫 [客户端取消] SSE 客户端在等待速率限制时断开连接: 端点 %s", ep.Config.Name))
+			return
+		} else if !ok {
+			saturated++
+			rateLimited = true
+			if saturated == len(endpoints) {
+				h.writeSSEError(w, apierror.TypeRateLimited, "⏱️ 所有端点均已达到速率或并发限制，请稍后重试")
+				return
+			}
+			if i == len(endpoints)-1 {
+				h.writeSSEError(w, apierror.TypeAllEndpointsFailed, "💥 所有端点连接失败或已达到速率限制")
+				return
+			}
+			continue
+		}
+
+		// Hold a concurrency slot for the entire stream; skip endpoints at their limit, or queue
+		// for them with max_queue_wait
+		release, err := acquireSlot(ctx, ep)
+		if err != nil && !errors.Is(err, endpoint.ErrEndpointSaturated) {
+			slog.InfoContext(ctx, fmt.Sprintf("🚫 [客户端取消] SSE 客户端在等待空闲槽位时断开连接: 端点 %s", ep.Config.Name))
+			return
+		}
+		if err != nil {
+			saturated++
+			if saturated == len(endpoints) && rateLimited {
+				h.writeSSEError(w, apierror.TypeRateLimited, "⏱️ 所有端点均已达到速率或并发限制，请稍后重试")
+				return
+			}
+			if saturated == len(endpoints) {
+				h.writeSSEError(w, apierror.TypeEndpointsSaturated, "🚧 所有端点均已达到最大并发数，请稍后重试")
+				return
+			}
+			if i == len(endpoints)-1 {
+				h.writeSSEError(w, apierror.TypeAllEndpointsFailed, "💥 所有端点连接失败或已达到最大并发数")
+				return
+			}
+			continue
+		}
+
 		// Update connection endpoint in monitoring
 		if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
 			UpdateConnectionEndpoint(connID, endpoint string)
@@ -69,22 +116,59 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 			mm.UpdateConnectionEndpoint(connID, ep.Config.Name)
 		}
 		
-		err := h.streamFromEndpoint(ctx, w, r, ep, bodyBytes, flusher, connID)
+		err = func() error {
+			defer release()
+			for {
+				attemptStart := time.Now()
+				err := h.streamFromEndpoint(ctx, w, r, ep, bodyBytes, flusher, connID)
+				h.retryHandler.recordAttempt(connID, ep.Config.Name, attemptStart, 0, err, true)
+
+				// A rejected token: retry the endpoint with the next one
+				var statusErr *upstreamStatusError
+				if !errors.As(err, &statusErr) || !h.retryHandler.rotateToken(ctx, ep, statusErr.StatusCode, h.responseHeaders(statusErr.Header), statusErr.Token) {
+					return err
+				}
+			}
+		}()
 		if err == nil {
 			// Success
 			return
 		}
 
+		// The client went away: there is nobody to fail over for
+		if clientCancelled(ctx) {
+			slog.InfoContext(ctx, fmt.Sprintf("🚫 [客户端取消] SSE 客户端已断开连接，停止流式传输: 端点 %s", ep.Config.Name))
+			return
+		}
+
+		// Non-retryable upstream errors are the provider's answer: pass them through untouched
+		var statusErr *upstreamStatusError
+		if errors.As(err, &statusErr) && !statusErr.Retryable && !wroteEvents {
+			writeUpstreamError(ctx, w, statusErr.StatusCode, h.responseHeaders(statusErr.Header), bytes.NewReader(statusErr.Body), ep.Config.Name)
+			return
+		}
+
 		slog.ErrorContext(ctx, fmt.Sprintf("❌ [SSE 流式传输] 端点连接失败: %s - 错误: %s", ep.Config.Name, err.Error()))
 
-		// If this isn't the last endpoint, try the next one
+		// Large non-idempotent requests must not be resent to a different endpoint
+		if failoverDisabled(ctx) {
+			h.writeSSEError(w, apierror.TypeFailoverDisabled, fmt.Sprintf("🔒 已禁用非幂等请求的跨端点重试，错误: %v", err))
+			return
+		}
+
+		// If this isn't the last endpoint, try the next one (switching endpoints uses the retry budget)
+		if i < len(endpoints)-1 && !h.retryHandler.takeRetryBudget(ctx, endpoints[i+1].Config.Name) {
+			h.writeSSEError(w, apierror.TypeRetryBudgetExhausted, fmt.Sprintf("💸 重试预算已用完，不再切换端点，错误: %v", err))
+			return
+		}
 		if i < len(endpoints)-1 {
 			h.writeSSEEvent(w, "retry", fmt.Sprintf("🔄 切换到备用端点: %s", endpoints[i+1].Config.Name), flusher)
+			wroteEvents = true
 			continue
 		}
 
 		// All endpoints failed
-		h.writeSSEError(w, fmt.Sprintf("💥 所有端点连接失败，最后错误: %v", err), flusher)
+		h.writeSSEError(w, apierror.TypeAllEndpointsFailed, fmt.Sprintf("💥 所有端点连接失败，最后错误: %v", err))
 		return
 	}
 }
@@ -92,14 +176,25 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 // streamFromEndpoint streams response from a specific endpoint
 func (h *Handler) streamFromEndpoint(ctx context.Context, w http.ResponseWriter, r *http.Request, ep *endpoint.Endpoint, bodyBytes []byte, flusher http.Flusher, connID string) error {
 	// Create request to target endpoint
-	targetURL := ep.Config.URL + r.URL.Path
+	targetURL := ep.Config.BaseURL() + r.URL.Path
 	if r.URL.RawQuery != "" {
 		targetURL += "?" + r.URL.RawQuery
 	}
 
-	// Create a context without timeout for streaming requests
-	streamCtx := context.WithoutCancel(ctx)
-	req, err := http.NewRequestWithContext(streamCtx, r.Method, targetURL, bytes.NewReader(bodyBytes))
+	// Create a context without timeout for streaming requests, cancelled only when the client aborts
+	streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		if clientCancelled(ctx) {
+			cancel()
+		}
+	})
+	defer stop()
+	endpointBody, err := transformBody(ep.Config, bodyBytes)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(streamCtx, r.Method, targetURL, bytes.NewReader(endpointBody))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -107,26 +202,12 @@ func (h *Handler) streamFromEndpoint(ctx context.Context, w http.ResponseWriter,
 	// Copy headers
 	h.copyHeaders(r, req, ep)
 
-	// Create HTTP client optimized for real-time streaming with proxy support
-	httpTransport, err := transport.CreateTransport(h.config)
+	// Use the endpoint's pooled transport optimized for real-time streaming
+	httpTransport, err := h.transports.GetStreaming(h.config, ep.Config)
 	if err != nil {
 		return fmt.Errorf("failed to create transport: %w", err)
 	}
-	
-	// Optimize transport for streaming
-	httpTransport.DisableKeepAlives = false
-	httpTransport.MaxIdleConns = 10
-	httpTransport.MaxIdleConnsPerHost = 2
-	httpTransport.IdleConnTimeout = 0 // No idle timeout
-	httpTransport.TLSHandshakeTimeout = 10 * time.Second
-	httpTransport.ExpectContinueTimeout = 1 * time.Second
-	httpTransport.ResponseHeaderTimeout = 15 * time.Second // Reduced for faster response
-	// Critical: Disable compression to prevent buffering delays
-	httpTransport.DisableCompression = true
-	// Set smaller buffer sizes for lower latency
-	httpTransport.WriteBufferSize = 4096 // Smaller write buffer
-	httpTransport.ReadBufferSize = 4096  // Smaller read buffer
-	
+
 	client := &http.Client{
 		Timeout:   0, // No timeout for streaming
 		Transport: httpTransport,
@@ -141,11 +222,18 @@ func (h *Handler) streamFromEndpoint(ctx context.Context, w http.ResponseWriter,
 
 	// Check if response is successful
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("endpoint returned error: %d", resp.StatusCode)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBody))
+		return &upstreamStatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       body,
+			Retryable:  h.retryHandler.shouldRetryStatusCode(resp.StatusCode).IsRetryable,
+			Token:      bearerToken(req.Header),
+		}
 	}
 
-	// Start streaming the response - use ultra-simple copy first
-	return h.streamResponseUltraSimple(ctx, w, resp, flusher, connID, ep.Config.Name)
+	// Forward the stream as received, without per-line processing
+	return h.streamResponsePassthrough(ctx, w, resp, flusher, connID, ep.Config.Name)
 }
 
 // streamResponse streams the HTTP response to the client
@@ -155,7 +243,7 @@ func (h *Handler) streamResponse(ctx context.Context, w http.ResponseWriter, res
 		"status_code", resp.StatusCode)
 
 	// Copy response headers first
-	for key, values := range resp.Header {
+	for key, values := range h.responseHeaders(resp.Header) {
 		// Skip hop-by-hop headers and headers we set manually
 		if key == "Connection" || key == "Transfer-Encoding" || key == "Content-Length" {
 			continue
@@ -218,7 +306,7 @@ func (h *Handler) streamResponse(ctx context.Context, w http.ResponseWriter, res
 					slog.ErrorContext(ctx, "❌ Stream read error",
 						"error", err.Error(),
 						"lines_sent", lineCount)
-					return fmt.Errorf("error reading response: %w", err)
+					return &streamInterruptedError{err}
 				}
 				// End of stream
 				slog.InfoContext(ctx, "✅ Stream completed normally",
@@ -253,6 +341,16 @@ func (h *Handler) streamResponse(ctx context.Context, w http.ResponseWriter, res
 	}
 }
 
+// recordStreamTokenUsage records the token usage increment found in a stream
+func (h *Handler) recordStreamTokenUsage(ctx context.Context, connID, endpointName string, tokenUsage *monitor.TokenUsage) {
+	if h.recordTokenUsage(connID, endpointName, tokenUsage) {
+		slog.InfoContext(ctx, fmt.Sprintf("✅ [令牌统计] 记录令牌使用 - 端点: %s, 输入: %d, 输出: %d, 缓存创建: %d, 缓存读取: %d",
+			endpointName, tokenUsage.InputTokens, tokenUsage.OutputTokens, tokenUsage.CacheCreationTokens, tokenUsage.CacheReadTokens))
+	} else {
+		slog.DebugContext(ctx, fmt.Sprintf("⚠️ [Token Parser] Monitoring middleware not available or no connID - connID: %s, hasMiddleware: %t", connID, h.retryHandler.monitoringMiddleware != nil))
+	}
+}
+
 // writeSSEEvent writes a Server-Sent Event to the client
 func (h *Handler) writeSSEEvent(w http.ResponseWriter, eventType, data string, flusher http.Flusher) {
 	if eventType != "" {
@@ -269,9 +367,9 @@ func (h *Handler) writeSSEEvent(w http.ResponseWriter, eventType, data string, f
 	flusher.Flush()
 }
 
-// writeSSEError writes an error event to the client
-func (h *Handler) writeSSEError(w http.ResponseWriter, message string, flusher http.Flusher) {
-	h.writeSSEEvent(w, "error", message, flusher)
+// writeSSEError ends the stream with a forwarder error event
+func (h *Handler) writeSSEError(w http.ResponseWriter, errorType, message string) {
+	apierror.WriteSSE(w, errorType, message)
 }
 
 // streamResponseByBytes streams the HTTP response byte-by-byte for maximum real-time performance
@@ -281,7 +379,7 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 
 	// Copy response headers first, preserving original content type
 	originalContentType := ""
-	for key, values := range resp.Header {
+	for key, values := range h.responseHeaders(resp.Header) {
 		// Skip hop-by-hop headers and headers we set manually
 		if key == "Connection" || key == "Transfer-Encoding" || key == "Content-Length" {
 			continue
@@ -319,8 +417,8 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 	lineBuffer := make([]byte, 0, 1024)
 
 	// Initialize token parser for extracting usage statistics
-	tokenParser := NewTokenParser()
-	slog.InfoContext(ctx, "🔧 [Token Parser] 初始化完成，准备解析Claude API的令牌使用统计", "endpoint", endpointName, "connID", connID)
+	tokenParser := NewTokenParserFor(h.responseFormat(endpointName))
+	slog.InfoContext(ctx, "🔧 [Token Parser] 初始化完成，准备解析令牌使用统计", "endpoint", endpointName, "connID", connID)
 	
 	// Initialize debug accumulator for SSE events
 	var accumulatedEvents strings.Builder
@@ -354,6 +452,12 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 				lastActivity = time.Now()
 				bytesTransferred += int64(n)
 
+				// Feed the raw bytes to the token parser: it reassembles whole SSE events
+				// no matter where the read or the 512-byte flush split them
+				if tokenUsage := tokenParser.ParseChunk(buffer[:n]); tokenUsage != nil {
+					h.recordStreamTokenUsage(ctx, connID, endpointName, tokenUsage)
+				}
+
 				// Process each byte to detect line endings and flush immediately
 				for i := 0; i < n; i++ {
 					b := buffer[i]
@@ -387,21 +491,6 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 							}
 						}
 						
-						// Always try to parse each line, with detailed logging
-						slog.Debug(fmt.Sprintf("🔍 [Stream Parser] Processing line - line: %s, lineLength: %d", line, len(line)))
-						if tokenUsage := tokenParser.ParseSSELine(line); tokenUsage != nil {
-							// Record token usage if we have monitoring middleware
-							if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
-								RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage)
-							}); ok && connID != "" {
-								mm.RecordTokenUsage(connID, endpointName, tokenUsage)
-								slog.InfoContext(ctx, fmt.Sprintf("✅ [令牌统计] 记录令牌使用 - 端点: %s, 输入: %d, 输出: %d, 缓存创建: %d, 缓存读取: %d",
-									endpointName, tokenUsage.InputTokens, tokenUsage.OutputTokens, tokenUsage.CacheCreationTokens, tokenUsage.CacheReadTokens))
-							} else {
-								slog.Debug(fmt.Sprintf("⚠️ [Token Parser] Monitoring middleware not available or no connID - connID: %s, hasMiddleware: %t", connID, h.retryHandler.monitoringMiddleware != nil))
-							}
-						}
-						
 						_, writeErr := w.Write(lineBuffer)
 						if writeErr != nil {
 						slog.ErrorContext(ctx, fmt.Sprintf("❌ [实时流传输] 写入客户端失败 - 错误: %s, 已传输: %d字节", 
@@ -437,7 +526,7 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 					if len(lineBuffer) > 0 {
 						// Try to parse the final line for tokens
 						line := string(lineBuffer)
-						slog.Debug(fmt.Sprintf("🔍 [Stream Parser] Processing final line - line: %s, lineLength: %d", line, len(line)))
+						slog.DebugContext(ctx, fmt.Sprintf("🔍 [Stream Parser] Processing final line - line: %s, lineLength: %d", line, len(line)))
 						
 						// Add final line to accumulated events and log final summary
 						eventCounter++
@@ -452,20 +541,14 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 								endpointName, eventCounter, len(finalAccumulatedContent), debugContent))
 						}
 						
-						if tokenUsage := tokenParser.ParseSSELine(line); tokenUsage != nil {
-							// Record token usage if we have monitoring middleware
-							if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
-								RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage)
-							}); ok && connID != "" {
-								mm.RecordTokenUsage(connID, endpointName, tokenUsage)
-								slog.InfoContext(ctx, fmt.Sprintf("✅ [令牌统计] 记录最终令牌使用 - 端点: %s, 输入: %d, 输出: %d",
-									endpointName, tokenUsage.InputTokens, tokenUsage.OutputTokens))
-							}
-						}
-						
 						w.Write(lineBuffer)
 						flusher.Flush()
 					}
+
+					// An event without a trailing blank line is still counted
+					if tokenUsage := tokenParser.Finish(); tokenUsage != nil {
+						h.recordStreamTokenUsage(ctx, connID, endpointName, tokenUsage)
+					}
 					
 					slog.InfoContext(ctx, fmt.Sprintf("✅ [实时流传输] 传输完成 - 总计: %d字节, 耗时: %v", 
 						bytesTransferred, time.Since(lastActivity)))
@@ -474,7 +557,7 @@ func (h *Handler) streamResponseByBytes(ctx context.Context, w http.ResponseWrit
 				
 				slog.ErrorContext(ctx, fmt.Sprintf("❌ [实时流传输] 读取错误 - 错误: %s, 已传输: %d字节", 
 					err.Error(), bytesTransferred))
-				return fmt.Errorf("error reading response: %w", err)
+				return &streamInterruptedError{err}
 			}
 		}
 	}
@@ -485,7 +568,7 @@ func (h *Handler) streamResponseSimple(ctx context.Context, w http.ResponseWrite
 	slog.InfoContext(ctx, "🚀 [简单流转发] 开始转发", "statusCode", resp.StatusCode, "contentType", resp.Header.Get("Content-Type"))
 
 	// Copy response headers
-	for key, values := range resp.Header {
+	for key, values := range h.responseHeaders(resp.Header) {
 		// Skip hop-by-hop headers
 		if key == "Connection" || key == "Transfer-Encoding" || key == "Content-Length" {
 			continue
@@ -499,9 +582,8 @@ func (h *Handler) streamResponseSimple(ctx context.Context, w http.ResponseWrite
 	w.WriteHeader(resp.StatusCode)
 	flusher.Flush()
 
-	// Initialize token parser for background parsing
-	tokenParser := NewTokenParser()
-	lineBuffer := make([]byte, 0, 4096)
+	// Initialize token parser for inline parsing
+	tokenParser := NewTokenParserFor(h.responseFormat(endpointName))
 	
 	// Simple copy with line-by-line token parsing
 	buffer := make([]byte, 4096)
@@ -525,28 +607,17 @@ func (h *Handler) streamResponseSimple(ctx context.Context, w http.ResponseWrite
 				}
 				flusher.Flush()
 				
-				// Background token parsing (non-blocking)
-				go func(data []byte) {
-					for _, b := range data {
-						lineBuffer = append(lineBuffer, b)
-						if b == '\n' {
-							line := string(lineBuffer)
-							if tokenUsage := tokenParser.ParseSSELine(line); tokenUsage != nil {
-								if mm, ok := h.retryHandler.monitoringMiddleware.(interface{
-									RecordTokenUsage(connID string, endpoint string, tokens *monitor.TokenUsage)
-								}); ok && connID != "" {
-									mm.RecordTokenUsage(connID, endpointName, tokenUsage)
-									slog.InfoContext(context.Background(), "✅ [简单流转发] 记录令牌使用", "endpoint", endpointName, "inputTokens", tokenUsage.InputTokens, "outputTokens", tokenUsage.OutputTokens)
-								}
-							}
-							lineBuffer = lineBuffer[:0]
-						}
-					}
-				}(buffer[:n])
+				// Token parsing on the already-forwarded bytes
+				if tokenUsage := tokenParser.ParseChunk(buffer[:n]); tokenUsage != nil {
+					h.recordStreamTokenUsage(ctx, connID, endpointName, tokenUsage)
+				}
 			}
 			
 			if err != nil {
 				if err.Error() == "EOF" {
+					if tokenUsage := tokenParser.Finish(); tokenUsage != nil {
+						h.recordStreamTokenUsage(ctx, connID, endpointName, tokenUsage)
+					}
 					slog.InfoContext(ctx, "✅ [简单流转发] 转发完成", "bytesTransferred", bytesTransferred)
 					return nil
 				}
@@ -562,7 +633,7 @@ func (h *Handler) streamResponseUltraSimple(ctx context.Context, w http.Response
 	slog.InfoContext(ctx, "🚀 [超简单流转发] 开始纯转发", "statusCode", resp.StatusCode)
 
 	// Copy response headers as-is
-	for key, values := range resp.Header {
+	for key, values := range h.responseHeaders(resp.Header) {
 		// Skip hop-by-hop headers
 		if key == "Connection" || key == "Transfer-Encoding" || key == "Content-Length" {
 			continue
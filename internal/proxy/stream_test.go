@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestClientAbortSSE(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // lets the server notice the proxy going away
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {}\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer primary.Close()
+	var backupHits atomic.Int64
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupHits.Add(1)
+	}))
+	defer backup.Close()
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: primary.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: 10 * time.Second})
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+	sse := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handler.handleSSERequest(w, r, body)
+	})
+	server, mm := newAbortTestServer(manager, handler, sse)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "POST", server.URL+"/v1/messages", bytes.NewBufferString(`{"stream":true}`))
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	// Abort once the stream is established
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected the stream to start before aborting, got %d %q", resp.StatusCode, ct)
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the upstream stream to be cancelled")
+	}
+	assertCancelledNotFailed(t, mm, manager, backupHits.Load)
+}
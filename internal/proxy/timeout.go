@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// HeaderForwarderTimeout lets a client set the timeout of one non-streaming request, up to
+// global_timeout_ceiling. It is never forwarded upstream.
+const HeaderForwarderTimeout = "X-Forwarder-Timeout"
+
+// timeoutContextKey carries the per-attempt timeout requested by the client
+const timeoutContextKey = contextKey("timeout")
+
+// applyTimeoutOverride handles the X-Forwarder-Timeout header: it strips the header and gives
+// the request's attempts that timeout instead of the endpoint's, clamped to
+// global_timeout_ceiling. It reports whether the request was already answered with an error.
+func (h *Handler) applyTimeoutOverride(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := r.Header[http.CanonicalHeaderKey(HeaderForwarderTimeout)]; !ok {
+		return false
+	}
+	value := strings.TrimSpace(r.Header.Get(HeaderForwarderTimeout))
+	r.Header.Del(HeaderForwarderTimeout)
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.TypeOverrideInvalid,
+			fmt.Sprintf("%s must be a positive duration such as 90s or 12m, got %q", HeaderForwarderTimeout, value))
+		return true
+	}
+
+	requested := timeout
+	if ceiling := h.config.GlobalTimeoutCeiling; ceiling > 0 && timeout > ceiling {
+		timeout = ceiling
+	}
+	slog.DebugContext(r.Context(), fmt.Sprintf("⏱️ [超时覆盖] 请求指定超时: %v，实际超时: %v", requested, timeout))
+	*r = *r.WithContext(context.WithValue(r.Context(), timeoutContextKey, timeout))
+	return false
+}
+
+// attemptTimeout returns the timeout of a non-streaming attempt on ep: the client's
+// X-Forwarder-Timeout when it sent one, otherwise the endpoint's timeout
+func attemptTimeout(ctx context.Context, ep *endpoint.Endpoint) time.Duration {
+	if timeout, ok := ctx.Value(timeoutContextKey).(time.Duration); ok {
+		return timeout
+	}
+	return ep.Config.Timeout
+}
+
+// isEventStream reports whether a response is an SSE stream
+func isEventStream(resp *http.Response) bool {
+	return strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// attemptDeadline bounds a non-streaming attempt, from sending the request to reading the
+// last byte of the response. Unlike a context deadline it can be disarmed, for responses
+// that turn out to be streams.
+type attemptDeadline struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer // nil without a timeout
+	expired atomic.Bool
+}
+
+// startAttemptDeadline returns a context that is cancelled once timeout has passed (never
+// for 0) and the deadline controlling it
+func startAttemptDeadline(ctx context.Context, timeout time.Duration) (context.Context, *attemptDeadline) {
+	ctx, cancel := context.WithCancel(ctx)
+	d := &attemptDeadline{timeout: timeout, cancel: cancel}
+	if timeout > 0 {
+		d.timer = time.AfterFunc(timeout, func() {
+			d.expired.Store(true)
+			cancel()
+		})
+	}
+	return ctx, d
+}
+
+// disarm keeps the attempt running past its timeout
+func (d *attemptDeadline) disarm() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
+
+// release ends the attempt's context once it is no longer needed
+func (d *attemptDeadline) release() {
+	d.disarm()
+	d.cancel()
+}
+
+// err reports an error caused by the expired deadline as a timeout rather than as the
+// cancellation it looks like, which would be taken for the client going away
+func (d *attemptDeadline) err(err error) error {
+	if err != nil && d.expired.Load() {
+		return fmt.Errorf("timeout after %v: %w", d.timeout, context.DeadlineExceeded)
+	}
+	return err
+}
+
+// body wraps a response body so reads report the deadline and closing it releases the attempt
+func (d *attemptDeadline) body(body io.ReadCloser) io.ReadCloser {
+	return &deadlineBody{ReadCloser: body, deadline: d}
+}
+
+type deadlineBody struct {
+	io.ReadCloser
+	deadline *attemptDeadline
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.deadline.err(err)
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.deadline.release()
+	return err
+}
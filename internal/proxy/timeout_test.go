@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+)
+
+func TestTimeoutHeader(t *testing.T) {
+	var leaked atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderForwarderTimeout) != "" {
+			leaked.Store(true)
+		}
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+	handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "slow", URL: upstream.URL, Priority: 1})
+	manager.GetEndpointByNameAny("slow").Config.Timeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		header   string
+		ceiling  time.Duration
+		wantCode int
+		wantType string
+	}{
+		{"endpoint timeout", "", time.Second, http.StatusBadGateway, apierror.TypeAllEndpointsFailed},
+		{"longer timeout", "1s", 2 * time.Second, http.StatusOK, ""},
+		{"clamped to ceiling", "1s", 200 * time.Millisecond, http.StatusBadGateway, apierror.TypeAllEndpointsFailed},
+		{"invalid duration", "soon", time.Second, http.StatusBadRequest, apierror.TypeOverrideInvalid},
+		{"zero duration", "0s", time.Second, http.StatusBadRequest, apierror.TypeOverrideInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.config.GlobalTimeoutCeiling = tt.ceiling
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude"}`))
+			if tt.header != "" {
+				req.Header.Set(HeaderForwarderTimeout, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantType != "" && errorType(t, rec) != tt.wantType {
+				t.Errorf("Expected error type %s, got %s", tt.wantType, rec.Body.String())
+			}
+		})
+	}
+	if leaked.Load() {
+		t.Error("Expected the timeout header not to be forwarded upstream")
+	}
+}
+
+func TestUnrequestedStreamOutlivesTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, "event: ping\ndata: {\"n\":%d}\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+
+	for _, passthrough := range []bool{false, true} {
+		t.Run(fmt.Sprintf("passthrough=%v", passthrough), func(t *testing.T) {
+			handler, manager := newConcurrencyTestHandler(t, 0, config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1})
+			handler.config.Streaming.PassthroughMode = passthrough
+			manager.GetEndpointByNameAny("primary").Config.Timeout = 100 * time.Millisecond
+
+			// The request does not ask for a stream, so the 100ms non-streaming timeout applies until the response arrives
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude"}`))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if events := strings.Count(rec.Body.String(), "event: ping"); events != 5 {
+				t.Errorf("Expected all 5 events despite the timeout, got %d: %q", events, rec.Body.String())
+			}
+		})
+	}
+}
@@ -5,108 +5,220 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
-	
+
+	"endpoint_forwarder/config"
 	"endpoint_forwarder/internal/monitor"
 )
 
 // UsageData represents the usage field in Claude API SSE events
 type UsageData struct {
-	InputTokens            int64 `json:"input_tokens"`
-	OutputTokens           int64 `json:"output_tokens"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
 	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
-	CacheReadInputTokens    int64 `json:"cache_read_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
 }
 
 // MessageDelta represents the structure of message_delta events
 type MessageDelta struct {
-	Type      string     `json:"type"`
-	Delta     interface{} `json:"delta"`
-	Usage     *UsageData  `json:"usage,omitempty"`
+	Type  string      `json:"type"`
+	Delta interface{} `json:"delta"`
+	Usage *UsageData  `json:"usage,omitempty"`
+}
+
+// OpenAIUsageData represents the usage field of OpenAI-compatible responses and final stream chunks
+type OpenAIUsageData struct {
+	PromptTokens        int64 `json:"prompt_tokens"`
+	CompletionTokens    int64 `json:"completion_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
 }
 
-// TokenParser handles parsing of SSE events for token usage extraction
+// MessageStart represents the structure of message_start events
+type MessageStart struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage *UsageData `json:"usage,omitempty"`
+	} `json:"message"`
+}
+
+// TokenParser extracts token usage from an SSE stream. For Claude API streams, input and
+// cache tokens come from message_start, output tokens are summed over message_delta events;
+// OpenAI-compatible streams report their totals in the usage of a final chunk. Every parse
+// call returns only the usage not reported before, so callers can record each result
+// additively and end up with the stream's totals.
 type TokenParser struct {
-	// Buffer to collect multi-line JSON data
-	eventBuffer     strings.Builder
-	currentEvent    string
-	collectingData  bool
+	assembler *SSEEventAssembler
+	format    string // Response format (config.APIFormat*); usage of unknown formats is not parsed
+	totals    monitor.TokenUsage
+	done      bool // message_stop or [DONE] seen
 }
 
-// NewTokenParser creates a new token parser instance
+// NewTokenParser creates a new token parser instance for Claude API responses
 func NewTokenParser() *TokenParser {
-	return &TokenParser{}
+	return NewTokenParserFor(config.APIFormatAnthropic)
+}
+
+// NewTokenParserFor creates a token parser for responses in the given format
+func NewTokenParserFor(format string) *TokenParser {
+	return &TokenParser{assembler: NewSSEEventAssembler(), format: format}
+}
+
+// ParseChunk feeds raw stream bytes (split anywhere) and returns newly found token usage, if any
+func (tp *TokenParser) ParseChunk(chunk []byte) *monitor.TokenUsage {
+	return tp.parseEvents(tp.assembler.Write(chunk))
 }
 
 // ParseSSELine processes a single line from SSE stream and extracts token usage if found
 func (tp *TokenParser) ParseSSELine(line string) *monitor.TokenUsage {
-	line = strings.TrimSpace(line)
-	
-	
-	// Handle event type lines
-	if strings.HasPrefix(line, "event: ") {
-		eventType := strings.TrimPrefix(line, "event: ")
-		tp.currentEvent = eventType
-		tp.collectingData = (eventType == "message_delta")
-		tp.eventBuffer.Reset()
+	line = strings.TrimRight(line, "\r\n")
+	return tp.ParseChunk([]byte(line + "\n"))
+}
+
+// Finish processes an event left pending when the stream ended without a blank line
+func (tp *TokenParser) Finish() *monitor.TokenUsage {
+	return tp.parseEvents(tp.assembler.Flush())
+}
+
+// Totals returns the token usage accumulated so far
+func (tp *TokenParser) Totals() monitor.TokenUsage {
+	return tp.totals
+}
+
+// Done reports whether the end of the message (message_stop or [DONE]) has been seen
+func (tp *TokenParser) Done() bool {
+	return tp.done
+}
+
+func (tp *TokenParser) parseEvents(events []SSEEvent) *monitor.TokenUsage {
+	var increment *monitor.TokenUsage
+	for _, event := range events {
+		usage := tp.ParseEvent(event)
+		if usage == nil {
+			continue
+		}
+		if increment == nil {
+			increment = &monitor.TokenUsage{}
+		}
+		increment.InputTokens += usage.InputTokens
+		increment.OutputTokens += usage.OutputTokens
+		increment.CacheCreationTokens += usage.CacheCreationTokens
+		increment.CacheReadTokens += usage.CacheReadTokens
+	}
+	return increment
+}
+
+// ParseEvent processes one complete SSE event and returns the usage it adds, if any
+func (tp *TokenParser) ParseEvent(event SSEEvent) *monitor.TokenUsage {
+	data := strings.TrimSpace(event.Data)
+	if data == "[DONE]" {
+		tp.done = true
 		return nil
 	}
-	
-	// Handle data lines for message_delta events
-	if strings.HasPrefix(line, "data: ") && tp.collectingData {
-		dataContent := strings.TrimPrefix(line, "data: ")
-		tp.eventBuffer.WriteString(dataContent)
+	if data == "" {
 		return nil
 	}
-	
-	// Handle empty lines that signal end of SSE event
-	if line == "" && tp.collectingData && tp.eventBuffer.Len() > 0 {
-		return tp.parseMessageDelta()
+
+	switch tp.format {
+	case config.APIFormatAnthropic:
+	case config.APIFormatOpenAI:
+		return tp.parseOpenAIEvent(data)
+	default:
+		return nil
+	}
+
+	eventType := event.Event
+	if eventType == "" {
+		// Fall back to the payload's own type field
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(data), &typed); err != nil {
+			return nil
+		}
+		eventType = typed.Type
+	}
+
+	switch eventType {
+	case "message_start":
+		var messageStart MessageStart
+		if err := json.Unmarshal([]byte(data), &messageStart); err != nil || messageStart.Message.Usage == nil {
+			return nil
+		}
+		return tp.apply(messageStart.Message.Usage, false)
+
+	case "message_delta":
+		var messageDelta MessageDelta
+		if err := json.Unmarshal([]byte(data), &messageDelta); err != nil || messageDelta.Usage == nil {
+			return nil
+		}
+		return tp.apply(messageDelta.Usage, true)
+
+	case "message_stop":
+		tp.done = true
 	}
-	
 	return nil
 }
 
-// parseMessageDelta parses the collected message_delta JSON data
-func (tp *TokenParser) parseMessageDelta() *monitor.TokenUsage {
-	defer func() {
-		tp.eventBuffer.Reset()
-		tp.collectingData = false
-		tp.currentEvent = ""
-	}()
-	
-	jsonData := tp.eventBuffer.String()
-	if jsonData == "" {
-		return nil
+// parseOpenAIEvent reads the usage of an OpenAI-compatible chunk or response. Prompt tokens
+// count as input (cached ones as cache reads) and completion tokens as output; both are totals.
+func (tp *TokenParser) parseOpenAIEvent(data string) *monitor.TokenUsage {
+	var chunk struct {
+		Usage *OpenAIUsageData `json:"usage"`
 	}
-	
-	// Parse the JSON data
-	var messageDelta MessageDelta
-	if err := json.Unmarshal([]byte(jsonData), &messageDelta); err != nil {
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil || chunk.Usage == nil {
 		return nil
 	}
-	
-	// Check if this message_delta contains usage information
-	if messageDelta.Usage == nil {
-		return nil
+
+	usage := &UsageData{InputTokens: chunk.Usage.PromptTokens}
+	if details := chunk.Usage.PromptTokensDetails; details != nil && details.CachedTokens <= usage.InputTokens {
+		usage.InputTokens -= details.CachedTokens
+		usage.CacheReadInputTokens = details.CachedTokens
 	}
-	
-	// Convert to our TokenUsage format
-	tokenUsage := &monitor.TokenUsage{
-		InputTokens:            messageDelta.Usage.InputTokens,
-		OutputTokens:           messageDelta.Usage.OutputTokens,
-		CacheCreationTokens:    messageDelta.Usage.CacheCreationInputTokens,
-		CacheReadTokens:        messageDelta.Usage.CacheReadInputTokens,
+	// apply sums output tokens, so pass only the growth of the completion total
+	if chunk.Usage.CompletionTokens > tp.totals.OutputTokens {
+		usage.OutputTokens = chunk.Usage.CompletionTokens - tp.totals.OutputTokens
 	}
+	return tp.apply(usage, true)
+}
+
+// apply merges a usage block into the totals and returns the increment. Input and cache
+// counts are totals (only growth is reported); output tokens from message_delta are summed.
+func (tp *TokenParser) apply(usage *UsageData, countOutput bool) *monitor.TokenUsage {
+	increment := &monitor.TokenUsage{}
+
+	if usage.InputTokens > tp.totals.InputTokens {
+		increment.InputTokens = usage.InputTokens - tp.totals.InputTokens
+	}
+	if usage.CacheCreationInputTokens > tp.totals.CacheCreationTokens {
+		increment.CacheCreationTokens = usage.CacheCreationInputTokens - tp.totals.CacheCreationTokens
+	}
+	if usage.CacheReadInputTokens > tp.totals.CacheReadTokens {
+		increment.CacheReadTokens = usage.CacheReadInputTokens - tp.totals.CacheReadTokens
+	}
+	if countOutput && usage.OutputTokens > 0 {
+		increment.OutputTokens = usage.OutputTokens
+	}
+
+	if increment.InputTokens == 0 && increment.OutputTokens == 0 &&
+		increment.CacheCreationTokens == 0 && increment.CacheReadTokens == 0 {
+		return nil
+	}
+
+	tp.totals.InputTokens += increment.InputTokens
+	tp.totals.OutputTokens += increment.OutputTokens
+	tp.totals.CacheCreationTokens += increment.CacheCreationTokens
+	tp.totals.CacheReadTokens += increment.CacheReadTokens
 
 	slog.Debug(fmt.Sprintf("🪙 [Token Parser] 从SSE流中提取令牌使用情况 - 输入: %d, 输出: %d, 缓存创建: %d, 缓存读取: %d",
-		tokenUsage.InputTokens, tokenUsage.OutputTokens, tokenUsage.CacheCreationTokens, tokenUsage.CacheReadTokens))
+		increment.InputTokens, increment.OutputTokens, increment.CacheCreationTokens, increment.CacheReadTokens))
 
-	return tokenUsage
+	return increment
 }
 
 // Reset clears the parser state
 func (tp *TokenParser) Reset() {
-	tp.eventBuffer.Reset()
-	tp.currentEvent = ""
-	tp.collectingData = false
-}
\ No newline at end of file
+	tp.assembler.Reset()
+	tp.totals = monitor.TokenUsage{}
+	tp.done = false
+}
@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"strings"
 	"testing"
+	"endpoint_forwarder/config"
 	"endpoint_forwarder/internal/monitor"
 )
 
@@ -83,4 +85,153 @@ func TestTokenParserOtherEvents(t *testing.T) {
 	if result != nil {
 		t.Error("Expected nil for non-message_delta events, got result")
 	}
-}
\ No newline at end of file
+}
+// syntheticTranscript is a Claude-style stream with usage split across message_start and
+// two message_delta events, a multi-line data payload and a trailing [DONE]
+const syntheticTranscript = "event: message_start\n" +
+	"data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":120,\"cache_creation_input_tokens\":30,\"cache_read_input_tokens\":900,\"output_tokens\":1}}}\n" +
+	"\n" +
+	": heartbeat\n" +
+	"\n" +
+	"event: content_block_delta\n" +
+	"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello, this is a long generation\"}}\n" +
+	"\n" +
+	"event: message_delta\n" +
+	"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":null},\n" +
+	"data: \"usage\":{\"output_tokens\":250}}\n" +
+	"\n" +
+	"event: message_delta\n" +
+	"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":87}}\n" +
+	"\n" +
+	"event: message_stop\n" +
+	"data: {\"type\":\"message_stop\"}\n" +
+	"\n" +
+	"data: [DONE]\n" +
+	"\n"
+
+func assertTotals(t *testing.T, totals monitor.TokenUsage) {
+	t.Helper()
+
+	expected := monitor.TokenUsage{InputTokens: 120, OutputTokens: 337, CacheCreationTokens: 30, CacheReadTokens: 900}
+	if totals != expected {
+		t.Errorf("Expected totals %+v, got %+v", expected, totals)
+	}
+}
+
+func TestTokenParserChunkBoundaries(t *testing.T) {
+	// Split the transcript at every possible chunk size, including inside JSON payloads
+	for chunkSize := 1; chunkSize <= len(syntheticTranscript); chunkSize++ {
+		parser := NewTokenParser()
+		var recorded monitor.TokenUsage
+
+		data := []byte(syntheticTranscript)
+		for start := 0; start < len(data); start += chunkSize {
+			end := start + chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			if usage := parser.ParseChunk(data[start:end]); usage != nil {
+				recorded.InputTokens += usage.InputTokens
+				recorded.OutputTokens += usage.OutputTokens
+				recorded.CacheCreationTokens += usage.CacheCreationTokens
+				recorded.CacheReadTokens += usage.CacheReadTokens
+			}
+		}
+		parser.Finish()
+
+		if recorded != parser.Totals() {
+			t.Fatalf("Chunk size %d: recorded increments %+v differ from totals %+v", chunkSize, recorded, parser.Totals())
+		}
+		assertTotals(t, parser.Totals())
+		if !parser.Done() {
+			t.Fatalf("Chunk size %d: expected end of message to be detected", chunkSize)
+		}
+	}
+}
+
+func TestTokenParserCRLFAndMissingTrailingBlankLine(t *testing.T) {
+	parser := NewTokenParser()
+
+	transcript := strings.ReplaceAll(syntheticTranscript, "\n", "\r\n")
+	transcript = strings.TrimSuffix(transcript, "data: [DONE]\r\n\r\n")
+	// Final usage event is not terminated by a blank line
+	transcript = strings.Replace(transcript, "event: message_stop\r\ndata: {\"type\":\"message_stop\"}\r\n\r\n", "", 1)
+	transcript = strings.TrimSuffix(transcript, "\r\n")
+
+	// Split the CRLF pairs across chunks
+	data := []byte(transcript)
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		parser.ParseChunk(data[i:end])
+	}
+
+	if parser.Totals().OutputTokens != 250 {
+		t.Fatalf("Expected only the first delta before flush, got %+v", parser.Totals())
+	}
+	if usage := parser.Finish(); usage == nil || usage.OutputTokens != 87 {
+		t.Fatalf("Expected Finish to report the pending delta, got %+v", usage)
+	}
+	assertTotals(t, parser.Totals())
+}
+
+func TestSSEEventAssemblerMultiLineData(t *testing.T) {
+	assembler := NewSSEEventAssembler()
+
+	events := assembler.Write([]byte("event: custom\ndata: line one\ndata:line two\n\n: comment\n\ndata: tail"))
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 complete event, got %d", len(events))
+	}
+	if events[0].Event != "custom" || events[0].Data != "line one\nline two" {
+		t.Errorf("Unexpected event: %+v", events[0])
+	}
+
+	events = assembler.Flush()
+	if len(events) != 1 || events[0].Data != "tail" {
+		t.Errorf("Expected pending event to be flushed, got %+v", events)
+	}
+}
+
+// openAITestStream is an OpenAI-compatible stream that reports usage in its final chunk
+const openAITestStream = `data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"}}],"usage":null}` + "\n\n" +
+	`data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":null}` + "\n\n" +
+	`data: {"id":"c1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":120,"completion_tokens":35,"total_tokens":155,"prompt_tokens_details":{"cached_tokens":20}}}` + "\n\n" +
+	"data: [DONE]\n\n"
+
+func TestTokenParserOpenAIFormat(t *testing.T) {
+	parser := NewTokenParserFor(config.APIFormatOpenAI)
+	got := addUsage(parser.ParseChunk([]byte(openAITestStream)), parser.Finish())
+
+	want := monitor.TokenUsage{InputTokens: 100, OutputTokens: 35, CacheReadTokens: 20}
+	if got == nil || *got != want {
+		t.Fatalf("Expected usage %+v, got %+v", want, got)
+	}
+	if !parser.Done() {
+		t.Error("Expected [DONE] to end the stream")
+	}
+
+	// A repeated usage block reports nothing new
+	if again := parser.ParseEvent(SSEEvent{Data: `{"usage":{"prompt_tokens":120,"completion_tokens":35,"prompt_tokens_details":{"cached_tokens":20}}}`}); again != nil {
+		t.Errorf("Expected no increment for repeated totals, got %+v", again)
+	}
+
+	// Claude events are not read as OpenAI usage, and vice versa
+	if usage := parser.ParseEvent(SSEEvent{Event: "message_delta", Data: `{"type":"message_delta","usage":{"output_tokens":9}}`}); usage != nil {
+		t.Errorf("Expected Claude usage to be ignored in openai format, got %+v", usage)
+	}
+	claude := NewTokenParser()
+	if usage := addUsage(claude.ParseChunk([]byte(openAITestStream)), claude.Finish()); usage != nil {
+		t.Errorf("Expected OpenAI usage to be ignored in anthropic format, got %+v", usage)
+	}
+}
+
+func TestTokenParserUnknownFormat(t *testing.T) {
+	parser := NewTokenParserFor("gemini")
+	for _, stream := range []string{openAITestStream, strings.ReplaceAll(openAITestStream, "[DONE]", "")} {
+		if usage := addUsage(parser.ParseChunk([]byte(stream)), parser.Finish()); usage != nil {
+			t.Errorf("Expected an unknown format to skip parsing, got %+v", usage)
+		}
+	}
+}
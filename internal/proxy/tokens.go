@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// rotateToken takes a token the upstream answered with 401 or 429 out of the rotation of an
+// endpoint configured with a tokens list, and reports whether the request can be retried on
+// the same endpoint with another token. A 429's Retry-After (or
+// anthropic-ratelimit-* reset) extends the token's cooldown.
+func (rh *RetryHandler) rotateToken(ctx context.Context, ep *endpoint.Endpoint, statusCode int, header http.Header, token string) bool {
+	if statusCode != http.StatusUnauthorized && statusCode != http.StatusTooManyRequests {
+		return false
+	}
+	if len(ep.Config.Tokens) == 0 || bodyStreamed(ctx) || rh.endpointManager == nil {
+		return false
+	}
+
+	var cooldown time.Duration
+	if statusCode == http.StatusTooManyRequests {
+		if retryAfter, ok := upstreamRetryAfter(header, time.Now()); ok {
+			cooldown = retryAfter
+		}
+	}
+	return rh.endpointManager.RejectEndpointToken(ep, token, statusCode, cooldown)
+}
+
+// bearerToken returns the bearer token of a request's Authorization header
+func bearerToken(header http.Header) string {
+	return strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+}
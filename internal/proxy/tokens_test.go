@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// tokenUpstream answers 401 for revoked tokens, 429 for exhausted ones and 200 otherwise,
+// counting the requests made with each token
+type tokenUpstream struct {
+	mu        sync.Mutex
+	revoked   map[string]bool
+	exhausted map[string]bool
+	hits      map[string]int
+}
+
+func newTokenUpstream(t *testing.T, revoked, exhausted []string) (*httptest.Server, *tokenUpstream) {
+	u := &tokenUpstream{revoked: make(map[string]bool), exhausted: make(map[string]bool), hits: make(map[string]int)}
+	for _, token := range revoked {
+		u.revoked[token] = true
+	}
+	for _, token := range exhausted {
+		u.exhausted[token] = true
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		u.mu.Lock()
+		u.hits[token]++
+		u.mu.Unlock()
+		switch {
+		case u.revoked[token]:
+			w.WriteHeader(http.StatusUnauthorized)
+		case u.exhausted[token]:
+			w.Header().Set("Retry-After", "600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.Header.Get("Accept") == "text/event-stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message_stop\ndata: {}\n\n"))
+		default:
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, u
+}
+
+func (u *tokenUpstream) count(token string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.hits[token]
+}
+
+func TestTokenRotationSkipsRevokedToken(t *testing.T) {
+	upstream, tokens := newTokenUpstream(t, []string{"sk-revoked-0002"}, nil)
+	backup, backupTokens := newTokenUpstream(t, nil, nil)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "multi", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Tokens: []string{"sk-valid-00001", "sk-revoked-0002", "sk-valid-00003"}, TokenCooldown: time.Minute},
+		config.EndpointConfig{Name: "backup", URL: backup.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second, Token: "backup"})
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+
+	for i := 0; i < 6; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"messages":[]}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	if hits := tokens.count("sk-revoked-0002"); hits != 1 {
+		t.Errorf("Expected the revoked token to be tried once, got %d", hits)
+	}
+	if a, c := tokens.count("sk-valid-00001"), tokens.count("sk-valid-00003"); a+c != 6 || a < 2 || c < 2 {
+		t.Errorf("Expected the remaining tokens to share the traffic, got %d and %d", a, c)
+	}
+	if hits := backupTokens.count("backup"); hits != 0 {
+		t.Errorf("Expected no failover to the backup endpoint, got %d requests", hits)
+	}
+
+	ep := manager.GetEndpointByNameAny("multi")
+	if !ep.IsHealthy() || ep.IsRateLimited() {
+		t.Error("Expected the endpoint to stay healthy and not rate limited")
+	}
+	states := ep.TokenStates()
+	if len(states) != 3 || states[1].Available || states[1].StatusCode != http.StatusUnauthorized || !states[0].Available || !states[2].Available {
+		t.Fatalf("Expected only the second token to be disabled with 401, got %+v", states)
+	}
+	if strings.Contains(states[1].Token, "revoked") {
+		t.Errorf("Expected the token to be masked, got %q", states[1].Token)
+	}
+}
+
+func TestTokenRotationOnRateLimitedStream(t *testing.T) {
+	upstream, tokens := newTokenUpstream(t, nil, []string{"sk-exhausted-01"})
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "multi", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Tokens: []string{"sk-exhausted-01", "sk-fresh-000002"}, TokenCooldown: time.Second})
+	manager := endpoint.NewManager(cfg)
+	handler := NewHandler(manager, cfg)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"stream":true,"messages":[]}`))
+	req.Header.Set("Accept", "text/event-stream")
+	handler.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "message_stop") {
+		t.Fatalf("Expected the stream from the second token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if tokens.count("sk-exhausted-01") != 1 || tokens.count("sk-fresh-000002") != 1 {
+		t.Errorf("Expected one attempt per token, got %v", tokens.hits)
+	}
+
+	// Retry-After (600s) outlasts the 1s token_cooldown
+	state := manager.GetEndpointByNameAny("multi").TokenStates()[0]
+	if state.Available || state.StatusCode != http.StatusTooManyRequests || time.Until(state.DisabledUntil) < 5*time.Minute {
+		t.Errorf("Expected the exhausted token disabled for Retry-After, got %+v", state)
+	}
+	if manager.GetEndpointByNameAny("multi").IsRateLimited() {
+		t.Error("Expected the endpoint itself not to be rate limited")
+	}
+}
+
+func TestTokenRotationAllTokensRejected(t *testing.T) {
+	upstream, tokens := newTokenUpstream(t, []string{"sk-revoked-0001", "sk-revoked-0002"}, nil)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "multi", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Tokens: []string{"sk-revoked-0001", "sk-revoked-0002"}, TokenCooldown: time.Minute})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"messages":[]}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected the upstream 401 once every token is rejected, got %d", rec.Code)
+	}
+	if a, b := tokens.count("sk-revoked-0001"), tokens.count("sk-revoked-0002"); a != 1 || b != 1 {
+		t.Errorf("Expected each token to be tried once, got %d and %d", a, b)
+	}
+}
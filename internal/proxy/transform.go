@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"endpoint_forwarder/config"
+)
+
+// TransformError reports that an endpoint's request transform could not be applied. The
+// attempt fails without reaching the endpoint, and the request moves on to the next one.
+type TransformError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("transform for endpoint %s failed: %v", e.Endpoint, e.Err)
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// transformBody applies an endpoint's transform to a request body. Bodies of endpoints
+// without a transform, empty bodies and bodies that are not JSON are returned unchanged.
+func transformBody(ep config.EndpointConfig, bodyBytes []byte) ([]byte, error) {
+	t := ep.Transform
+	if t.IsEmpty() || len(bodyBytes) == 0 || !json.Valid(bodyBytes) {
+		return bodyBytes, nil
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &body); err != nil || body == nil {
+		return nil, &TransformError{Endpoint: ep.Name, Err: errors.New("request body is not a JSON object")}
+	}
+
+	for _, field := range t.RemoveFields {
+		delete(body, field)
+	}
+	for from, to := range t.RenameFields {
+		value, ok := body[from]
+		if !ok {
+			continue
+		}
+		if _, exists := body[to]; exists {
+			return nil, &TransformError{Endpoint: ep.Name, Err: fmt.Errorf("cannot rename %s to %s: the body already has %s", from, to, to)}
+		}
+		delete(body, from)
+		body[to] = value
+	}
+	for field, value := range t.DefaultFields {
+		if _, ok := body[field]; ok {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, &TransformError{Endpoint: ep.Name, Err: fmt.Errorf("cannot encode default for %s: %w", field, err)}
+		}
+		body[field] = encoded
+	}
+
+	return json.Marshal(body)
+}
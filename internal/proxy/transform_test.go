@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func decodeBody(t *testing.T, body []byte) map[string]any {
+	t.Helper()
+	var decoded map[string]any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Failed to decode upstream body %q: %v", body, err)
+	}
+	return decoded
+}
+
+func TestTransformAppliedPerEndpointOnFailover(t *testing.T) {
+	var mu sync.Mutex
+	var strictBody []byte
+	strict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		strictBody = body
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(strict.Close)
+	lenient, lenientRecorder := newBodyRecorder(t)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "strict", URL: strict.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Transform: &config.TransformConfig{
+				RemoveFields:  []string{"metadata"},
+				RenameFields:  map[string]string{"stop_sequences": "stop"},
+				DefaultFields: map[string]any{"max_tokens": 4096},
+			}},
+		config.EndpointConfig{Name: "lenient", URL: lenient.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	request := `{"model":"claude-3-5-sonnet-20241022","metadata":{"user_id":"u1"},"stop_sequences":["END"],"messages":[]}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(request)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the request to succeed on the lenient endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	mu.Lock()
+	sent := decodeBody(t, strictBody)
+	mu.Unlock()
+	if _, ok := sent["metadata"]; ok {
+		t.Errorf("Expected metadata to be removed for the strict endpoint, got %v", sent)
+	}
+	if _, ok := sent["stop_sequences"]; ok {
+		t.Errorf("Expected stop_sequences to be renamed for the strict endpoint, got %v", sent)
+	}
+	if _, ok := sent["stop"]; !ok {
+		t.Errorf("Expected stop for the strict endpoint, got %v", sent)
+	}
+	if sent["max_tokens"] != float64(4096) {
+		t.Errorf("Expected max_tokens 4096 for the strict endpoint, got %v", sent["max_tokens"])
+	}
+
+	// The lenient endpoint gets the client's body, not the strict endpoint's rewrite
+	_, body, contentLength := lenientRecorder.last()
+	if string(body) != request {
+		t.Errorf("Expected the lenient endpoint to get the original body, got %s", body)
+	}
+	if contentLength != int64(len(request)) {
+		t.Errorf("Expected Content-Length %d, got %d", len(request), contentLength)
+	}
+}
+
+func TestTransformKeepsExistingDefaults(t *testing.T) {
+	upstream, recorder := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "strict", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Transform: &config.TransformConfig{DefaultFields: map[string]any{"max_tokens": 4096}}})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"max_tokens":100,"messages":[]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	_, body, contentLength := recorder.last()
+	if sent := decodeBody(t, body); sent["max_tokens"] != float64(100) {
+		t.Errorf("Expected the client's max_tokens to be kept, got %v", sent["max_tokens"])
+	}
+	if contentLength != int64(len(body)) {
+		t.Errorf("Expected Content-Length %d to match the rewritten body, got %d", len(body), contentLength)
+	}
+}
+
+func TestTransformErrorMovesToNextEndpoint(t *testing.T) {
+	strict, strictRecorder := newBodyRecorder(t)
+	lenient, lenientRecorder := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "strict", URL: strict.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Transform: &config.TransformConfig{RenameFields: map[string]string{"stop_sequences": "stop"}}},
+		config.EndpointConfig{Name: "lenient", URL: lenient.URL, Priority: 2, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	cfg.Retry.MaxAttempts = 3
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	// Renaming would overwrite the existing stop field
+	request := `{"stop_sequences":["END"],"stop":["x"],"messages":[]}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(request)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the request to succeed on the lenient endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits, _, _ := strictRecorder.last(); hits != 0 {
+		t.Errorf("Expected no request to the endpoint whose transform failed, got %d", hits)
+	}
+	if hits, body, _ := lenientRecorder.last(); hits != 1 || string(body) != request {
+		t.Errorf("Expected one untouched request on the lenient endpoint, got %d: %s", hits, body)
+	}
+}
+
+func TestTransformLeavesNonJSONBodies(t *testing.T) {
+	upstream, recorder := newBodyRecorder(t)
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "strict", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second,
+			Transform: &config.TransformConfig{RemoveFields: []string{"metadata"}}})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/upload", bytes.NewBufferString("plain text metadata")))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, body, _ := recorder.last(); string(body) != "plain text metadata" {
+		t.Errorf("Expected the non-JSON body to be forwarded untouched, got %q", body)
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestTransportReusedAcrossRequests(t *testing.T) {
+	var connections atomic.Int64
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	upstream.Start()
+	t.Cleanup(upstream.Close)
+
+	cfg := newRulesTestConfig(nil,
+		config.EndpointConfig{Name: "primary", URL: upstream.URL, Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second})
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	send := func() {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{"model":"test"}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		send()
+	}
+	if got := connections.Load(); got != 1 {
+		t.Errorf("Expected sequential requests to share one upstream connection, got %d", got)
+	}
+	if got := handler.transports.Builds(); got != 1 {
+		t.Errorf("Expected the transport to be built once, got %d builds", got)
+	}
+
+	// A reload with the same settings keeps the pool
+	same := *cfg
+	handler.UpdateConfig(&same)
+	send()
+	if got := handler.transports.Builds(); got != 1 {
+		t.Errorf("Expected an unchanged config to keep the transport, got %d builds", got)
+	}
+
+	// Changing the pool settings rebuilds the transport and opens a new connection
+	changed := *cfg
+	changed.Transport = config.TransportConfig{MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Minute}
+	handler.UpdateConfig(&changed)
+	send()
+	if got := handler.transports.Builds(); got != 2 {
+		t.Errorf("Expected changed transport settings to rebuild the transport, got %d builds", got)
+	}
+	if got := connections.Load(); got != 2 {
+		t.Errorf("Expected the rebuilt pool to open a new connection, got %d connections", got)
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// newUnixSocketUpstream serves handler on a Unix domain socket and returns the socket path
+func newUnixSocketUpstream(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	// Keep the path short: socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "ef-sock")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socketPath := filepath.Join(dir, "upstream.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return socketPath
+}
+
+func TestUnixSocketUpstream(t *testing.T) {
+	var gotPath, gotHost string
+	socketPath := newUnixSocketUpstream(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotHost = r.Host
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "socket", URL: "unix://" + socketPath, UnixPathPrefix: "/gateway", Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+		},
+	}
+	handler := NewHandler(endpoint.NewManager(cfg), cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/messages", bytes.NewBufferString(`{}`)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 over the unix socket, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"ok":true`) {
+		t.Errorf("Unexpected body: %s", rec.Body.String())
+	}
+	if gotPath != "/gateway/v1/messages" {
+		t.Errorf("Expected prefixed path /gateway/v1/messages, got %s", gotPath)
+	}
+	if gotHost != "localhost" {
+		t.Errorf("Expected Host localhost, got %s", gotHost)
+	}
+}
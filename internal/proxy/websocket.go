@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/transport"
+)
+
+// isWebSocketUpgrade reports whether the client asks to upgrade the request to a WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(strings.TrimSpace(r.Header.Get("Upgrade")), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleWebSocket performs the upgrade handshake with an endpoint chosen by the retry handler
+// (failing over when the handshake fails) and then tunnels the connection in both directions.
+// Frames, including close frames, are forwarded unchanged.
+func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	connID, _ := ctx.Value("conn_id").(string)
+
+	// The tunnel outlives the retry loop, so the upstream connection does not use the request context
+	tunnelCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	var selectedEndpointName string
+	var upstream io.ReadWriteCloser
+	operation := func(ep *endpoint.Endpoint, connectionID string) (*http.Response, error) {
+		selectedEndpointName = ep.Config.Name
+
+		if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+			UpdateConnectionEndpoint(connID, endpoint string)
+		}); ok && connectionID != "" {
+			mm.UpdateConnectionEndpoint(connectionID, ep.Config.Name)
+		}
+
+		resp, err := h.dialWebSocket(tunnelCtx, r, ep)
+		if err != nil {
+			return nil, err
+		}
+		upstream = resp.Body.(io.ReadWriteCloser)
+		return resp, nil
+	}
+
+	resp, err := h.retryHandler.ExecuteWithContext(ctx, operation, connID)
+	if selectedEndpointName != "" {
+		*r = *r.WithContext(context.WithValue(r.Context(), "selected_endpoint", selectedEndpointName))
+	}
+	if err != nil {
+		// The last endpoint refused the upgrade: pass its answer through
+		var statusErr *upstreamStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 {
+			writeUpstreamError(ctx, w, statusErr.StatusCode, h.responseHeaders(statusErr.Header), bytes.NewReader(statusErr.Body), selectedEndpointName)
+			return
+		}
+		h.writeRetryError(ctx, w, r, err)
+		return
+	}
+	// Closing the body closes the upstream connection and frees the endpoint's concurrency slot
+	defer resp.Body.Close()
+
+	clientConn, clientBuf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("❌ [WebSocket] 无法接管客户端连接: %s", err.Error()))
+		apierror.Write(w, http.StatusInternalServerError, apierror.TypeStreamingUnsupported, "WebSocket upgrade unsupported")
+		return
+	}
+	defer clientConn.Close()
+
+	// Complete the client's handshake with the endpoint's 101 response
+	header := h.responseHeaders(resp.Header)
+	header.Set(apierror.HeaderUpstream, selectedEndpointName)
+	var handshake bytes.Buffer
+	fmt.Fprintf(&handshake, "HTTP/1.1 %s\r\n", resp.Status)
+	header.Write(&handshake)
+	handshake.WriteString("\r\n")
+	if _, err := clientConn.Write(handshake.Bytes()); err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("⚠️ [WebSocket] 向客户端发送握手响应失败: %s", err.Error()))
+		return
+	}
+
+	if mm, ok := h.retryHandler.monitoringMiddleware.(interface {
+		MarkWebSocketConnection(connID string)
+	}); ok && connID != "" {
+		mm.MarkWebSocketConnection(connID)
+	}
+
+	start := time.Now()
+	slog.InfoContext(ctx, fmt.Sprintf("🔌 [WebSocket] 隧道已建立: 端点 %s, 路径 %s", selectedEndpointName, r.URL.Path))
+	sent, received := tunnelWebSocket(clientConn, clientBuf.Reader, upstream)
+	slog.InfoContext(ctx, fmt.Sprintf("🔌 [WebSocket] 隧道已关闭: 端点 %s, 持续 %s, 上行 %d字节, 下行 %d字节",
+		selectedEndpointName, time.Since(start).Round(time.Millisecond), sent, received))
+}
+
+// dialWebSocket sends the upgrade request to an endpoint with the usual header and token
+// injection. Any answer other than 101 is returned as an upstreamStatusError so the retry
+// handler moves on to the next endpoint.
+func (h *Handler) dialWebSocket(ctx context.Context, r *http.Request, ep *endpoint.Endpoint) (*http.Response, error) {
+	targetURL := ep.Config.BaseURL() + r.URL.Path
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	h.copyHeaders(r, req, ep)
+
+	// copyHeaders strips hop-by-hop headers, which the upgrade needs
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	httpTransport, err := transport.CreateEndpointTransport(h.config, ep.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport: %w", err)
+	}
+	// The endpoint timeout bounds the handshake only; the tunnel stays open until closed
+	httpTransport.ResponseHeaderTimeout = ep.Config.Timeout
+
+	resp, err := httpTransport.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("websocket handshake failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBody))
+		resp.Body.Close()
+		return nil, &upstreamStatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       body,
+			Retryable:  true,
+		}
+	}
+	if _, ok := resp.Body.(io.ReadWriteCloser); !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("websocket handshake failed: upstream connection is not writable")
+	}
+	return resp, nil
+}
+
+// tunnelWebSocket copies bytes between the client and the endpoint until either side closes
+// its connection, then closes the other one. Close frames travel like any other frame, so the
+// close handshake completes end to end before the connections are torn down.
+func tunnelWebSocket(client net.Conn, clientReader io.Reader, upstream io.ReadWriteCloser) (sent, received int64) {
+	type copyResult struct {
+		n        int64
+		upstream bool
+	}
+	results := make(chan copyResult, 2)
+	go func() {
+		n, _ := io.Copy(upstream, clientReader)
+		results <- copyResult{n: n, upstream: true}
+	}()
+	go func() {
+		n, _ := io.Copy(client, upstream)
+		results <- copyResult{n: n}
+	}()
+
+	for i := 0; i < 2; i++ {
+		result := <-results
+		if i == 0 {
+			client.Close()
+			upstream.Close()
+		}
+		if result.upstream {
+			sent = result.n
+		} else {
+			received = result.n
+		}
+	}
+	return sent, received
+}
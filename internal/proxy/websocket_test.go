@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/apierror"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// newWebSocketEchoUpstream accepts WebSocket upgrades and echoes everything sent over the
+// tunnel; it records the Authorization header of the handshake
+func newWebSocketEchoUpstream(t *testing.T, auth *atomic.Value) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		auth.Store(r.Header.Get("Authorization"))
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+		io.Copy(conn, buf)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// dialWebSocketTest opens a raw connection to the server and completes an upgrade handshake
+func dialWebSocketTest(t *testing.T, serverURL string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	conn.Write([]byte("GET /v1/stream HTTP/1.1\r\nHost: forwarder\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nAuthorization: Bearer client\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	return conn, reader, resp
+}
+
+// waitForConnection polls until the monitor reports a connection matching check
+func waitForConnection(t *testing.T, mm *middleware.MonitoringMiddleware, check func(*monitor.Metrics) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if check(mm.GetMetrics().GetMetrics()) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the connection state")
+}
+
+func TestWebSocketTunnelWithFailover(t *testing.T) {
+	var refusedHits atomic.Int64
+	refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refusedHits.Add(1)
+		http.Error(w, "no websockets here", http.StatusNotFound)
+	}))
+	defer refusing.Close()
+	var auth atomic.Value
+	echo := newWebSocketEchoUpstream(t, &auth)
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "refusing", URL: refusing.URL, Priority: 1},
+		config.EndpointConfig{Name: "echo", URL: echo.URL, Priority: 2, Token: "ws-token"},
+	)
+	server, mm := newAbortTestServer(manager, handler, handler)
+	defer server.Close()
+
+	conn, reader, resp := dialWebSocketTest(t, server.URL)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if upstream := resp.Header.Get(apierror.HeaderUpstream); upstream != "echo" {
+		t.Errorf("Expected the handshake to fail over to echo, got %q", upstream)
+	}
+	if refusedHits.Load() != 1 {
+		t.Errorf("Expected one handshake on the refusing endpoint, got %d", refusedHits.Load())
+	}
+	if got, _ := auth.Load().(string); got != "Bearer ws-token" {
+		t.Errorf("Expected the endpoint token to be injected, got %q", got)
+	}
+
+	// The tunnel shows up as a live websocket connection
+	waitForConnection(t, mm, func(m *monitor.Metrics) bool {
+		for _, c := range m.ActiveConnections {
+			if c.Status == "websocket" && c.Endpoint == "echo" {
+				return true
+			}
+		}
+		return false
+	})
+
+	conn.Write([]byte("ping"))
+	echoed := make([]byte, 4)
+	if _, err := io.ReadFull(reader, echoed); err != nil || string(echoed) != "ping" {
+		t.Fatalf("Expected the echo to come back through the tunnel, got %q (%v)", echoed, err)
+	}
+
+	// Closing the client tears down the tunnel and completes the connection
+	conn.Close()
+	waitForConnection(t, mm, func(m *monitor.Metrics) bool {
+		history := mm.GetMetrics().RecentConnections(0)
+		return len(m.ActiveConnections) == 0 && len(history) == 1 && history[0].Status == "completed"
+	})
+}
+
+func TestWebSocketHandshakeRefusedEverywhere(t *testing.T) {
+	refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no websockets here", http.StatusNotFound)
+	}))
+	defer refusing.Close()
+
+	handler, manager := newConcurrencyTestHandler(t, 0,
+		config.EndpointConfig{Name: "refusing", URL: refusing.URL, Priority: 1},
+	)
+	server, _ := newAbortTestServer(manager, handler, handler)
+	defer server.Close()
+
+	// The endpoint's answer is passed through
+	_, _, resp := dialWebSocketTest(t, server.URL)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the upstream 404 to be passed through, got %d", resp.StatusCode)
+	}
+}
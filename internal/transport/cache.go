@@ -0,0 +1,116 @@
+package transport
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+// Cache keeps one transport per endpoint and variant so requests reuse pooled upstream
+// connections. A transport is rebuilt when the settings it was built from change.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry // Keyed by endpoint name and variant
+	builds  atomic.Int64
+}
+
+// cacheEntry is a cached transport with the settings it was built from
+type cacheEntry struct {
+	endpoint  string
+	settings  string
+	transport *http.Transport
+}
+
+// NewCache creates an empty transport cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]*cacheEntry)}
+}
+
+// Get returns the transport for requests to an endpoint
+func (c *Cache) Get(cfg *config.Config, ep config.EndpointConfig) (*http.Transport, error) {
+	return c.get(cfg, ep, false)
+}
+
+// GetStreaming returns the transport for streaming requests to an endpoint
+func (c *Cache) GetStreaming(cfg *config.Config, ep config.EndpointConfig) (*http.Transport, error) {
+	return c.get(cfg, ep, true)
+}
+
+func (c *Cache) get(cfg *config.Config, ep config.EndpointConfig, streaming bool) (*http.Transport, error) {
+	key := ep.Name
+	variant := "常规"
+	if streaming {
+		key += "/stream"
+		variant = "流式"
+	}
+	settings := transportSettings(cfg, ep)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok && entry.settings == settings {
+		return entry.transport, nil
+	}
+
+	transport, err := CreateEndpointTransport(cfg, ep)
+	if err != nil {
+		return nil, err
+	}
+	if streaming {
+		tuneForStreaming(transport)
+	}
+
+	previous, rebuilt := c.entries[key]
+	if rebuilt {
+		previous.transport.CloseIdleConnections()
+	}
+	c.entries[key] = &cacheEntry{endpoint: ep.Name, settings: settings, transport: transport}
+	builds := c.builds.Add(1)
+	if rebuilt {
+		slog.Debug(fmt.Sprintf("🔌 [连接池] 端点 %s 的配置已变更，重建%s传输 (累计构建 %d 次)", ep.Name, variant, builds))
+	} else {
+		slog.Debug(fmt.Sprintf("🔌 [连接池] 为端点 %s 构建%s传输 (累计构建 %d 次)", ep.Name, variant, builds))
+	}
+	return transport, nil
+}
+
+// Prune drops the transports of endpoints that are no longer configured and closes their idle connections
+func (c *Cache) Prune(endpoints []config.EndpointConfig) {
+	names := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		names[ep.Name] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if !names[entry.endpoint] {
+			entry.transport.CloseIdleConnections()
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Builds returns how many transports the cache has built, including rebuilds
+func (c *Cache) Builds() int64 {
+	return c.builds.Load()
+}
+
+// transportSettings describes everything a transport for an endpoint is built from
+func transportSettings(cfg *config.Config, ep config.EndpointConfig) string {
+	return fmt.Sprintf("%s|%+v|%+v", ep.URL, cfg.Proxy, cfg.Transport)
+}
+
+// tuneForStreaming adjusts a transport for real-time streaming responses
+func tuneForStreaming(transport *http.Transport) {
+	transport.ResponseHeaderTimeout = 15 * time.Second
+	// Critical: Disable compression to prevent buffering delays
+	transport.DisableCompression = true
+	// Smaller buffers for lower latency
+	transport.WriteBufferSize = 4096
+	transport.ReadBufferSize = 4096
+}
@@ -13,20 +13,69 @@ import (
 	"golang.org/x/net/proxy"
 )
 
-// CreateTransport creates an HTTP transport with optional proxy support
-func CreateTransport(cfg *config.Config) (*http.Transport, error) {
+// newBaseTransport creates the default transport shared by all transport variants,
+// with the connection pool settings of the transport config section
+func newBaseTransport(cfg *config.Config) *http.Transport {
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
-		ForceAttemptHTTP2:     false, // 禁用HTTP/2强制尝试，避免协议兼容性问题
+		ForceAttemptHTTP2:     false, // 禁用HTTP/2强制尝试，避免协议兼容性问题（可通过 transport.enable_http2 开启）
 		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
+	// Unset values (configs built without defaults) keep the values above
+	pool := cfg.Transport
+	if pool.MaxIdleConns > 0 {
+		transport.MaxIdleConns = pool.MaxIdleConns
+	}
+	if pool.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	}
+	if pool.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = pool.IdleConnTimeout
+	}
+	if pool.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = pool.TLSHandshakeTimeout
+	}
+	transport.MaxConnsPerHost = pool.MaxConnsPerHost
+	// A custom dialer turns off Go's automatic HTTP/2, so it has to be requested explicitly
+	transport.ForceAttemptHTTP2 = pool.EnableHTTP2
+	return transport
+}
+
+// CreateEndpointTransport creates the transport used to reach a specific endpoint.
+// unix:// endpoints dial their socket directly (bypassing any proxy); all others
+// use CreateTransport.
+func CreateEndpointTransport(cfg *config.Config, ep config.EndpointConfig) (*http.Transport, error) {
+	if !ep.IsUnixSocket() {
+		return CreateTransport(cfg)
+	}
+
+	socketPath := ep.SocketPath()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := newBaseTransport(cfg)
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// The URL host is only used for the Host header; always connect to the socket
+		return dialer.DialContext(ctx, "unix", socketPath)
+	}
+	return transport, nil
+}
+
+// CreateTransport creates an HTTP transport with optional proxy support
+func CreateTransport(cfg *config.Config) (*http.Transport, error) {
+	transport := newBaseTransport(cfg)
+
 	// If proxy is not enabled, return default transport
 	if !cfg.Proxy.Enabled {
 		return transport, nil
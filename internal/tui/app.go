@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/diagnostics"
 	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/logging"
 	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/notify"
 )
 
 // TUIApp represents the main TUI application
@@ -23,9 +27,10 @@ type TUIApp struct {
 	startTime            time.Time
 	
 	// UI components
-	pages     *tview.Pages
-	tabBar    *tview.TextView
-	statusBar *tview.TextView
+	pages       *tview.Pages
+	headerTitle *tview.TextView
+	tabBar      *tview.TextView
+	statusBar   *tview.TextView
 	
 	// Views
 	overviewView    *OverviewView
@@ -33,7 +38,21 @@ type TUIApp struct {
 	connectionsView *ConnectionsView
 	logsView        *LogsView
 	configView      *ConfigView
+	groupsView      *GroupsView
+
+	// Data collection runs off the UI goroutine; views render from its snapshots
+	collector   *collector
+	lastRender  []time.Time // Last render time per tab, for per-view refresh intervals
+	updating    atomic.Bool // A render is queued on the UI goroutine
+
+	// Self-diagnostics modal (Ctrl+D)
+	diagnostics     *diagnostics.Collector
+	diagnosticsOpen bool
 	
+	// Responsive layout (tui.narrow_width)
+	screenWidth int  // Terminal width at the last draw
+	narrow      bool // Views are stacked for a narrow terminal
+
 	// State
 	currentTab int
 	tabs       []Tab
@@ -42,13 +61,21 @@ type TUIApp struct {
 	running    bool
 	configPath string                 // Configuration file path
 	
-	// Edit mode state for priority editing
+	// Edit mode state for endpoint editing
 	editMode        bool                // Whether we're in edit mode
 	tempPriorities  map[string]int      // Temporary priority changes in memory
+	tempEdits       map[string]config.EndpointEdit // Temporary timeout, group and URL changes
+	editColumn      editColumn          // Field edited with Enter, cycled with Tab
+	fieldEditorOpen bool                // The inline field editor has focus
 	isDirty         bool                // Whether there are unsaved changes
 	editMutex       sync.RWMutex        // Protects edit mode state
 }
 
+// setupModeHint replaces empty endpoint views while no endpoints are configured
+const setupModeHint = "[yellow::b]🛠️ Setup mode[white::-] - no endpoints configured\n\n" +
+	"[gray]Requests are answered with 503 until a config with at least one endpoint is activated.\n" +
+	"Edit the config file or import a config through the WebUI.[white]"
+
 // Tab represents a tab in the TUI
 type Tab struct {
 	Name string
@@ -72,8 +99,10 @@ func NewTUIApp(cfg *config.Config, endpointManager *endpoint.Manager, monitoring
 		running:              false,
 		configPath:           configPath,
 		tempPriorities:       make(map[string]int),
+		tempEdits:            make(map[string]config.EndpointEdit),
 		editMode:             false,
 		isDirty:              false,
+		collector:            newCollector(monitoringMiddleware, endpointManager),
 	}
 
 	// Create UI components
@@ -93,7 +122,8 @@ func (t *TUIApp) setupUI() {
 	t.endpointsView.SetTUIApp(t)  // Set reference for edit mode functionality
 	t.connectionsView = NewConnectionsView(t.monitoringMiddleware, t.endpointManager, t.cfg)
 	t.logsView = NewLogsView()
-	t.configView = NewConfigView(t.cfg)
+	t.configView = NewConfigView(t.cfg, t.endpointManager)
+	t.groupsView = NewGroupsView(t.monitoringMiddleware, t.endpointManager)
 
 	// Define tabs
 	t.tabs = []Tab{
@@ -102,7 +132,9 @@ func (t *TUIApp) setupUI() {
 		{"Connections", t.connectionsView.GetPrimitive()},
 		{"Logs", t.logsView.GetPrimitive()},
 		{"Config", t.configView.GetPrimitive()},
+		{"Groups", t.groupsView.GetPrimitive()},
 	}
+	t.lastRender = make([]time.Time, len(t.tabs))
 
 	// Create tab bar
 	t.tabBar = tview.NewTextView().
@@ -135,20 +167,23 @@ func (t *TUIApp) setupUI() {
 	// Set input capture for tab navigation
 	t.app.SetInputCapture(t.handleInput)
 
+	// Re-flow the views when the terminal is resized
+	t.watchScreenWidth()
+
 	// Set root and focus
 	t.app.SetRoot(mainFlex, true).SetFocus(t.pages)
 }
 
 // createHeaderFlex creates the header section
 func (t *TUIApp) createHeaderFlex() *tview.Flex {
-	title := tview.NewTextView().
+	t.headerTitle = tview.NewTextView().
 		SetDynamicColors(true).
-		SetTextAlign(tview.AlignCenter).
-		SetText(fmt.Sprintf("[blue::b]🚀 Claude EndPoints Forwarder TUI[white::-]"))
+		SetTextAlign(tview.AlignCenter)
+	t.updateHeader()
 
 	headerFlex := tview.NewFlex().
 		AddItem(tview.NewTextView(), 1, 1, false).
-		AddItem(title, 0, 1, false).
+		AddItem(t.headerTitle, 0, 1, false).
 		AddItem(tview.NewTextView(), 1, 1, false)
 	
 	headerFlex.SetBorder(true).SetTitle(" Claude EndPoints Forwarder TUI ").SetTitleAlign(tview.AlignCenter)
@@ -156,8 +191,27 @@ func (t *TUIApp) createHeaderFlex() *tview.Flex {
 	return headerFlex
 }
 
+// updateHeader shows the title, or a setup mode banner while no endpoints are configured
+func (t *TUIApp) updateHeader() {
+	if t.cfg.IsSetupMode() {
+		t.headerTitle.SetText(fmt.Sprintf("[black:yellow:b] 🛠️ SETUP MODE [-:-:-] [yellow]No endpoints configured - edit %s or import a config in the WebUI[white]", t.configPath))
+		return
+	}
+	t.headerTitle.SetText("[blue::b]🚀 Claude EndPoints Forwarder TUI[white::-]")
+}
+
 // handleInput handles keyboard input for navigation
 func (t *TUIApp) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	// The diagnostics modal takes all keys while open
+	if t.handleDiagnosticsKey(event) == nil {
+		return nil
+	}
+
+	// The field editor gets every key except Ctrl+C while open
+	if t.fieldEditorOpen && event.Key() != tcell.KeyCtrlC {
+		return event
+	}
+
 	// Handle edit mode specific keys first (only in Endpoints tab)
 	if t.currentTab == 1 { // Endpoints tab
 		if t.IsInEditMode() {
@@ -169,13 +223,24 @@ func (t *TUIApp) handleInput(event *tcell.EventKey) *tcell.EventKey {
 				return nil
 			case tcell.KeyCtrlS:
 				// Save changes to config
-				if err := t.SavePrioritiesToConfig(); err != nil {
+				if err := t.SaveEditsToConfig(); err != nil {
 					t.AddLog("ERROR", fmt.Sprintf("保存配置失败: %v", err), "TUI")
 				}
 				return nil
+			case tcell.KeyTab:
+				// Cycle the edited field instead of switching tabs
+				t.cycleEditColumn(1)
+				return nil
+			case tcell.KeyBacktab:
+				t.cycleEditColumn(-1)
+				return nil
+			case tcell.KeyEnter:
+				// Edit the selected endpoint's field in an input overlay
+				t.openFieldEditor()
+				return nil
 			}
 			
-			// Handle number keys for priority setting in edit mode
+			// Handle number keys for quick priority setting in edit mode
 			if event.Rune() >= '1' && event.Rune() <= '9' {
 				priority := int(event.Rune() - '0')
 				t.setSelectedEndpointPriority(priority)
@@ -193,9 +258,48 @@ func (t *TUIApp) handleInput(event *tcell.EventKey) *tcell.EventKey {
 				t.EnterEditMode()
 				return nil
 			}
+
+			// Toggle maintenance mode for the selected endpoint
+			if event.Rune() == 'd' || event.Rune() == 'D' {
+				t.toggleSelectedEndpointMaintenance()
+				return nil
+			}
+
+			// Health check the selected endpoint, or its whole group
+			if event.Rune() == 't' || event.Rune() == 'T' {
+				t.checkSelectedEndpoints(event.Rune() == 'T')
+				return nil
+			}
+
+			// Revert runtime priority overrides to the config file values
+			if event.Rune() == 'o' || event.Rune() == 'O' {
+				t.revertPriorityOverrides()
+				return nil
+			}
+		}
+	}
+	
+	// Connections tab: select a connection to show its upstream attempts
+	if t.currentTab == 2 && t.connectionsView != nil {
+		if t.connectionsView.HandleKey(event) == nil {
+			return nil
+		}
+	}
+
+	// Logs tab: level filters, search and pause (search input also swallows digits)
+	if t.currentTab == 3 && t.logsView != nil {
+		if t.logsView.HandleKey(event) == nil {
+			return nil
 		}
 	}
 	
+	// Groups tab: activate or cool down the selected group
+	if t.currentTab == 5 && t.groupsView != nil {
+		if t.groupsView.HandleKey(event) == nil {
+			return nil
+		}
+	}
+
 	// Handle global navigation keys
 	switch event.Key() {
 	case tcell.KeyTab:
@@ -250,6 +354,26 @@ func (t *TUIApp) setSelectedEndpointPriority(priority int) {
 	t.SetEndpointPriority(selectedEndpointName, priority)
 }
 
+// toggleSelectedEndpointMaintenance toggles maintenance mode for the currently selected endpoint
+func (t *TUIApp) toggleSelectedEndpointMaintenance() {
+	ep := t.getSelectedEndpoint()
+	if ep == nil {
+		t.AddLog("WARN", "没有选中的端点", "TUI")
+		return
+	}
+
+	disabled := !ep.IsDisabled()
+	if err := t.endpointManager.SetEndpointMaintenance(ep.Config.Name, disabled, "tui"); err != nil {
+		t.AddLog("ERROR", fmt.Sprintf("切换维护模式失败: %v", err), "TUI")
+		return
+	}
+
+	if t.endpointsView != nil {
+		t.endpointsView.MarkDirty()
+		t.endpointsView.Update(t.collector.Collect())
+	}
+}
+
 // getSelectedEndpointName returns the name of the currently selected endpoint
 func (t *TUIApp) getSelectedEndpointName() string {
 	if t.endpointsView == nil {
@@ -285,9 +409,12 @@ func (t *TUIApp) switchToTab(tabIndex int) {
 	if tabIndex >= 0 && tabIndex < len(t.tabs) {
 		t.pages.SwitchToPage(t.tabs[tabIndex].Name)
 		
-		// Force update logs view when switching to it to show any missed logs
+		// Hidden tabs are not refreshed, so bring the newly visible one up to date
 		if tabIndex == 3 && t.logsView != nil {
+			// Force update logs view when switching to it to show any missed logs
 			t.logsView.ForceUpdate()
+		} else {
+			t.renderView(tabIndex, t.collector.Latest(), true)
 		}
 	}
 }
@@ -302,13 +429,15 @@ func (t *TUIApp) updateTabBar() {
 			tabText += fmt.Sprintf(` [gray]%d: %s[white] `, i+1, tab.Name)
 		}
 	}
-	tabText += `   [gray]Tab/Shift+Tab: Navigate  Ctrl+C: Quit[white]`
+	if !t.narrow {
+		tabText += `   [gray]Tab/Shift+Tab: Navigate  Ctrl+D: Diagnostics  Ctrl+C: Quit[white]`
+	}
 	t.tabBar.SetText(tabText)
 }
 
 // updateStatusBar updates the status bar
 func (t *TUIApp) updateStatusBar() {
-	metrics := t.monitoringMiddleware.GetMetrics().GetMetrics()
+	metrics := t.collector.Latest().Metrics
 	
 	// Basic status text
 	statusText := fmt.Sprintf("Requests: %d | Success: %.1f%% | Connections: %d",
@@ -325,10 +454,29 @@ func (t *TUIApp) updateStatusBar() {
 		}
 		statusText += fmt.Sprintf(" | [编辑模式%s]", isDirty)
 	}
+
+	statusText += primaryStatusText(t.endpointManager.PrimaryStatus(), time.Now())
 	
 	t.statusBar.SetText(statusText)
 }
 
+// primaryNoticeDuration is how long the status bar reports an ended primary endpoint override
+const primaryNoticeDuration = time.Minute
+
+// primaryStatusText renders the -p primary endpoint override for the status bar
+func primaryStatusText(status endpoint.PrimaryStatus, now time.Time) string {
+	switch {
+	case status.Name != "" && status.Until.IsZero():
+		return fmt.Sprintf(" | 📌 Primary: [cyan]%s[white]", status.Name)
+	case status.Name != "":
+		return fmt.Sprintf(" | 📌 Primary: [cyan]%s[white] (reverts in %s)", status.Name, status.Until.Sub(now).Round(time.Second))
+	case status.Ended != "" && now.Sub(status.EndedAt) < primaryNoticeDuration:
+		return fmt.Sprintf(" | [yellow]↩️ Primary %s %s at %s, priorities reverted[white]",
+			status.Ended, status.Reason, status.EndedAt.Format("15:04:05"))
+	}
+	return ""
+}
+
 // Run starts the TUI application
 func (t *TUIApp) Run() error {
 	t.running = true
@@ -340,86 +488,157 @@ func (t *TUIApp) Run() error {
 	return t.app.Run()
 }
 
-// refreshLoop runs the background refresh routine
+// viewInterval returns the refresh interval of a tab
+func (t *TUIApp) viewInterval(tabIndex int) time.Duration {
+	switch tabIndex {
+	case 0:
+		return t.cfg.TUI.OverviewInterval
+	case 2:
+		return t.cfg.TUI.ConnectionsInterval
+	}
+	return t.cfg.TUI.UpdateInterval
+}
+
+// collectInterval returns how often snapshots are collected: the fastest view interval
+func (t *TUIApp) collectInterval() time.Duration {
+	interval := t.cfg.TUI.UpdateInterval
+	for _, d := range []time.Duration{t.cfg.TUI.OverviewInterval, t.cfg.TUI.ConnectionsInterval} {
+		if d > 0 && d < interval {
+			interval = d
+		}
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return interval
+}
+
+// refreshLoop collects snapshots in the background and renders only the visible view
 func (t *TUIApp) refreshLoop() {
-	ticker := time.NewTicker(t.cfg.TUI.UpdateInterval)
+	interval := t.collectInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
-	
-	// Add a flag to prevent overlapping updates
-	updating := false
 
 	for t.running {
 		select {
 		case <-t.ctx.Done():
 			return
 		case <-ticker.C:
-			if !t.running || updating {
+			if !t.running {
+				return
+			}
+
+			// Pick up interval changes from config reloads
+			if next := t.collectInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+
+			// Collect off the UI goroutine; skip rendering while the previous render is queued
+			snapshot := t.collector.Collect()
+			if !t.updating.CompareAndSwap(false, true) {
 				continue
 			}
-			
-			updating = true
+
 			// Use QueueUpdateDraw to ensure thread-safe UI updates
 			t.app.QueueUpdateDraw(func() {
-				defer func() { 
-					updating = false 
-					t.app.Sync()
-				}()
-				
+				defer t.updating.Store(false)
+
 				// Check if app is still running before updating
 				if !t.running {
 					return
 				}
-				
-				// Update endpoint health in metrics first
-				t.monitoringMiddleware.UpdateEndpointHealthStatus()
-				
-				// Update status bar
+
+				// Update header and status bar
+				t.updateHeader()
 				t.updateStatusBar()
-				
-				// Update only the currently active view to reduce UI conflicts
-				if t.currentTab >= 0 && t.currentTab < len(t.tabs) {
-					switch t.currentTab {
-					case 0:
-						if t.overviewView != nil {
-							t.overviewView.Update()
-						}
-					case 1:
-						if t.endpointsView != nil {
-							t.endpointsView.Update()
-						}
-					case 2:
-						if t.connectionsView != nil {
-							t.connectionsView.Update()
-						}
-					case 3:
-						// Only update logs view when it's the active tab
-						if t.logsView != nil {
-							t.logsView.Update()
-						}
-					case 4:
-						if t.configView != nil {
-							t.configView.Update()
-						}
-					}
+
+				// Update only the currently active view, at that view's own interval
+				if t.renderView(t.currentTab, snapshot, false) {
+					t.app.Sync()
 				}
 			})
 		}
 	}
 }
 
-// AddLog adds a log entry to the logs view (thread-safe)
+// renderView renders a tab from a snapshot and reports whether it did. Unless forced, a view
+// is skipped until its refresh interval has passed; views skip unchanged data on their own.
+// Must run on the UI goroutine.
+func (t *TUIApp) renderView(tabIndex int, snapshot *Snapshot, force bool) bool {
+	if tabIndex < 0 || tabIndex >= len(t.tabs) {
+		return false
+	}
+	// Allow half a tick of jitter so a view with the collection interval renders every tick
+	due := t.viewInterval(tabIndex) - t.collectInterval()/2
+	if !force && snapshot.CollectedAt.Sub(t.lastRender[tabIndex]) < due {
+		return false
+	}
+	t.lastRender[tabIndex] = snapshot.CollectedAt
+
+	switch tabIndex {
+	case 0:
+		if t.overviewView != nil {
+			t.overviewView.Update(snapshot)
+		}
+	case 1:
+		if t.endpointsView != nil {
+			t.endpointsView.Update(snapshot)
+		}
+	case 2:
+		if t.connectionsView != nil {
+			t.connectionsView.Update(snapshot)
+		}
+	case 3:
+		// Only update logs view when it's the active tab
+		if t.logsView != nil {
+			t.logsView.Update()
+		}
+	case 4:
+		if t.configView != nil {
+			t.configView.Update()
+		}
+	case 5:
+		if t.groupsView != nil {
+			t.groupsView.Update(snapshot)
+		}
+	}
+	return true
+}
+
+// markViewsDirty makes every snapshot-driven view re-render on its next update
+func (t *TUIApp) markViewsDirty() {
+	if t.overviewView != nil {
+		t.overviewView.MarkDirty()
+	}
+	if t.endpointsView != nil {
+		t.endpointsView.MarkDirty()
+	}
+	if t.connectionsView != nil {
+		t.connectionsView.MarkDirty()
+	}
+	if t.groupsView != nil {
+		t.groupsView.MarkDirty()
+	}
+}
+
+// AddLog adds a log entry to the log buffer shown in the logs view (thread-safe)
 func (t *TUIApp) AddLog(level, message, source string) {
 	if t.logsView != nil {
-		// Only add log if logs tab is currently active to avoid unnecessary UI updates
-		if t.currentTab == 3 {
-			t.logsView.AddLog(level, message, source)
-		} else {
-			// Still add log to buffer but don't trigger UI update
-			t.logsView.AddLogSilent(level, message, source)
-		}
+		t.logsView.AddLog(level, message, source)
 	}
 }
 
+// SetNotifier sets the event source of the overview's last event ticker
+func (t *TUIApp) SetNotifier(notifier *notify.Dispatcher) {
+	t.collector.notifier = notifier
+}
+
+// SetLogBuffer makes the logs view show the shared log buffer
+func (t *TUIApp) SetLogBuffer(buffer *logging.Buffer) {
+	t.logsView.SetBuffer(buffer)
+}
+
 // GetLogsView returns the logs view instance
 func (t *TUIApp) GetLogsView() *LogsView {
 	return t.logsView
@@ -437,9 +656,9 @@ func (t *TUIApp) IsRunning() bool {
 	return t.running
 }
 
-// Edit mode methods for priority editing
+// Edit mode methods for endpoint editing
 
-// EnterEditMode enters the priority edit mode
+// EnterEditMode enters the endpoint edit mode
 func (t *TUIApp) EnterEditMode() {
 	t.editMutex.Lock()
 	defer t.editMutex.Unlock()
@@ -450,10 +669,12 @@ func (t *TUIApp) EnterEditMode() {
 	
 	t.editMode = true
 	t.isDirty = false
+	t.editColumn = editColumnPriority
+	t.endpointsView.MarkDirty()
 	
-	// Initialize temp priorities with current config values
+	// Initialize temp priorities with the priorities in effect, including runtime edits
 	// Use endpoint@group keys for same-name endpoints
-	for _, endpoint := range t.cfg.Endpoints {
+	for _, endpoint := range t.endpointManager.GetConfig().Endpoints {
 		groupName := endpoint.Group
 		if groupName == "" {
 			groupName = "Default"
@@ -463,10 +684,10 @@ func (t *TUIApp) EnterEditMode() {
 	}
 	
 	// Add log entry
-	t.AddLog("INFO", "进入优先级编辑模式", "TUI")
+	t.AddLog("INFO", "进入端点编辑模式", "TUI")
 }
 
-// ExitEditMode exits the priority edit mode without saving changes
+// ExitEditMode exits the endpoint edit mode without saving changes
 func (t *TUIApp) ExitEditMode() {
 	t.editMutex.Lock()
 	defer t.editMutex.Unlock()
@@ -477,12 +698,14 @@ func (t *TUIApp) ExitEditMode() {
 	
 	t.editMode = false
 	t.isDirty = false
+	t.endpointsView.MarkDirty()
 	
-	// Clear temp priorities
+	// Clear temp priorities and field edits
 	t.tempPriorities = make(map[string]int)
+	t.tempEdits = make(map[string]config.EndpointEdit)
 	
 	// Add log entry
-	t.AddLog("INFO", "退出优先级编辑模式", "TUI")
+	t.AddLog("INFO", "退出端点编辑模式", "TUI")
 }
 
 // IsInEditMode returns whether we're currently in edit mode
@@ -529,6 +752,7 @@ func (t *TUIApp) SetEndpointPriority(endpointName string, priority int) {
 	
 	t.tempPriorities[endpointKey] = priority
 	t.isDirty = true
+	t.endpointsView.MarkDirty()
 	
 	t.AddLog("INFO", fmt.Sprintf("端点 %s (组: %s) 优先级: %d -> %d", 
 		endpointName, groupName, oldPriority, priority), "TUI")
@@ -563,8 +787,8 @@ func (t *TUIApp) GetEffectivePriority(endpointName string) int {
 		}
 	}
 	
-	// Return original config priority
-	for _, endpoint := range t.cfg.Endpoints {
+	// Return the priority in effect
+	for _, endpoint := range t.endpointManager.GetConfig().Endpoints {
 		if endpoint.Name == endpointName {
 			return endpoint.Priority
 		}
@@ -607,11 +831,12 @@ func (t *TUIApp) HasUnsavedChanges() bool {
 
 // IsSaveEnabled returns whether saving to config file is enabled
 func (t *TUIApp) IsSaveEnabled() bool {
-	return t.cfg.TUI.SavePriorityEdits
+	return t.cfg.TUI.SaveEditsEnabled()
 }
 
-// SavePrioritiesToConfig saves the temporary priorities to the config file
-func (t *TUIApp) SavePrioritiesToConfig() error {
+// SaveEditsToConfig applies the temporary priorities and field edits and, when enabled,
+// saves them to the config file
+func (t *TUIApp) SaveEditsToConfig() error {
 	t.editMutex.Lock()
 	defer t.editMutex.Unlock()
 	
@@ -619,44 +844,83 @@ func (t *TUIApp) SavePrioritiesToConfig() error {
 		return nil // Nothing to save
 	}
 	
-	// Apply temp priorities to the config
-	for i := range t.cfg.Endpoints {
-		endpoint := &t.cfg.Endpoints[i]
+	// Collect changed temp priorities
+	changed := make(map[string]int)
+	current := t.endpointManager.GetConfig()
+	for i := range current.Endpoints {
+		endpoint := &current.Endpoints[i]
 		groupName := endpoint.Group
 		if groupName == "" {
 			groupName = "Default"
 		}
 		endpointKey := fmt.Sprintf("%s@%s", endpoint.Name, groupName)
 		
-		if newPriority, exists := t.tempPriorities[endpointKey]; exists {
-			endpoint.Priority = newPriority
-		}
+		newPriority, exists := t.tempPriorities[endpointKey]
 		// Also check for simple name key for backward compatibility
-		if newPriority, exists := t.tempPriorities[endpoint.Name]; exists {
-			endpoint.Priority = newPriority
+		if simplePriority, ok := t.tempPriorities[endpoint.Name]; ok {
+			newPriority, exists = simplePriority, true
+		}
+		if exists && newPriority != endpoint.Priority {
+			changed[endpoint.Name] = newPriority
 		}
 	}
 	
-	// **关键修复**: 同步配置到EndpointManager
-	t.endpointManager.UpdateConfig(t.cfg)
+	// Timeout, group and URL edits replace the endpoint manager's config; priorities are
+	// collected first because a group change also changes the endpoint's key
+	fieldEdits := t.applyFieldEdits()
+	
+	// **关键修复**: 同步配置到EndpointManager（同时记录到运行时状态文件）
+	if err := t.endpointManager.SetEndpointPriorities(changed, "tui"); err != nil {
+		t.AddLog("ERROR", fmt.Sprintf("应用优先级失败: %v", err), "TUI")
+		return err
+	}
+	// The manager applied the edits to a copy of its config, which is the one to save
+	t.cfg = t.endpointManager.GetConfig()
 	
 	// 检查是否允许保存到配置文件
-	if t.cfg.TUI.SavePriorityEdits {
+	if t.cfg.TUI.SaveEditsEnabled() {
 		// 保存到配置文件（保留注释）
-		if err := config.SavePriorityConfigWithComments(t.cfg, t.configPath); err != nil {
+		var err error
+		if len(fieldEdits) > 0 {
+			err = config.SaveEndpointEditsWithComments(t.cfg, t.configPath, fieldEdits)
+		} else {
+			err = config.SavePriorityConfigWithComments(t.cfg, t.configPath)
+		}
+		if err != nil {
 			t.AddLog("ERROR", fmt.Sprintf("保存配置文件失败: %v", err), "TUI")
 			return err
 		}
-		t.AddLog("INFO", "配置已保存到文件并同步到路由系统，优先级更改已生效", "TUI")
+		// Priorities now live in the config file, no need to keep them as runtime overrides
+		t.endpointManager.ClearPriorityOverrides()
+		t.AddLog("INFO", "配置已保存到文件并同步到路由系统，端点更改已生效", "TUI")
 	} else {
-		t.AddLog("INFO", "优先级更改已应用到内存（配置文件保存已禁用）", "TUI")
+		t.AddLog("INFO", "端点更改已应用（配置文件保存已禁用，优先级覆盖保存在运行时状态文件中，按 o 可清除）", "TUI")
 	}
 	
+	// Applied edits are now part of the config
+	t.tempEdits = make(map[string]config.EndpointEdit)
 	t.isDirty = false
+	t.endpointsView.MarkDirty()
 	
 	return nil
 }
 
+// revertPriorityOverrides drops the runtime priority overrides kept in the state file, so
+// the priorities from the config file apply again
+func (t *TUIApp) revertPriorityOverrides() {
+	reverted := t.endpointManager.RevertPriorityOverrides("tui")
+	t.editMutex.Lock()
+	t.cfg = t.endpointManager.GetConfig()
+	t.editMutex.Unlock()
+	if reverted > 0 {
+		t.AddLog("INFO", fmt.Sprintf("已清除 %d 个运行时优先级覆盖，恢复配置文件中的优先级", reverted), "TUI")
+	} else {
+		t.AddLog("INFO", "没有需要清除的运行时优先级覆盖", "TUI")
+	}
+	t.markViewsDirty()
+	t.renderView(t.currentTab, t.collector.Latest(), true)
+}
+
 // UpdateConfig updates the TUI configuration when config is reloaded
 func (t *TUIApp) UpdateConfig(newCfg *config.Config) {
 	t.editMutex.Lock()
@@ -666,12 +930,14 @@ func (t *TUIApp) UpdateConfig(newCfg *config.Config) {
 	oldCfg := t.cfg
 	t.cfg = newCfg
 	
-	// Clear temporary priorities when config changes to prevent stale data
+	// Clear temporary priorities and field edits when config changes to prevent stale data
 	t.tempPriorities = make(map[string]int)
+	t.tempEdits = make(map[string]config.EndpointEdit)
 	t.isDirty = false
 	
 	// Update endpoint manager with new config
 	t.endpointManager.UpdateConfig(newCfg)
+	t.markViewsDirty()
 	
 	// Log configuration update
 	t.AddLog("INFO", fmt.Sprintf("配置已重载 - 端点数量: %d -> %d", 
@@ -0,0 +1,83 @@
+package tui
+
+import (
+	"sync/atomic"
+	"time"
+
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+	"endpoint_forwarder/internal/notify"
+)
+
+// Snapshot is one collection of monitoring data, shared read-only by all views
+type Snapshot struct {
+	Metrics          *monitor.Metrics          // Copy taken with Metrics.GetMetrics, without the connection history
+	Cancelled        []*monitor.ConnectionInfo // Connections cancelled by their client within monitor.CancelledDisplayWindow, newest first
+	HealthGeneration uint64                    // endpoint.Manager.StatusGeneration at collection time
+	LastEvent        *notify.Event             // Most recent failover event, nil when none happened or no notifier is set
+	CollectedAt      time.Time
+}
+
+// collector gathers snapshots off the UI goroutine so rendering never waits on metric locks
+type collector struct {
+	monitoringMiddleware *middleware.MonitoringMiddleware
+	endpointManager      *endpoint.Manager
+	notifier             *notify.Dispatcher // Source of the last event ticker, set before the TUI runs
+	latest               atomic.Pointer[Snapshot]
+}
+
+func newCollector(monitoringMiddleware *middleware.MonitoringMiddleware, endpointManager *endpoint.Manager) *collector {
+	return &collector{
+		monitoringMiddleware: monitoringMiddleware,
+		endpointManager:      endpointManager,
+	}
+}
+
+// Collect takes a fresh snapshot and makes it the latest one
+func (c *collector) Collect() *Snapshot {
+	c.monitoringMiddleware.UpdateEndpointHealthStatus()
+
+	metrics := c.monitoringMiddleware.GetMetrics()
+	now := time.Now()
+	snapshot := &Snapshot{
+		Metrics:          metrics.GetMetrics(),
+		Cancelled:        metrics.RecentlyCancelled(now),
+		HealthGeneration: c.endpointManager.StatusGeneration(),
+		CollectedAt:      now,
+	}
+	if c.notifier != nil {
+		if events := c.notifier.History(1); len(events) > 0 {
+			snapshot.LastEvent = &events[0]
+		}
+	}
+	c.latest.Store(snapshot)
+	return snapshot
+}
+
+// Latest returns the most recent snapshot, collecting one if none exists yet
+func (c *collector) Latest() *Snapshot {
+	if snapshot := c.latest.Load(); snapshot != nil {
+		return snapshot
+	}
+	return c.Collect()
+}
+
+// requestCounters are the request totals that drive most dirty checks
+type requestCounters struct {
+	total      int64
+	successful int64
+	failed     int64
+	cancelled  int64
+	tokens     monitor.TokenUsage
+}
+
+func countersOf(m *monitor.Metrics) requestCounters {
+	return requestCounters{
+		total:      m.TotalRequests,
+		successful: m.SuccessfulRequests,
+		failed:     m.FailedRequests,
+		cancelled:  m.CancelledRequests,
+		tokens:     m.TotalTokenUsage,
+	}
+}
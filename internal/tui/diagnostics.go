@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"endpoint_forwarder/internal/diagnostics"
+)
+
+// diagnosticsPage is the page name of the diagnostics modal
+const diagnosticsPage = "Diagnostics"
+
+// SetDiagnostics sets the self-diagnostics collector shown with Ctrl+D
+func (t *TUIApp) SetDiagnostics(collector *diagnostics.Collector) {
+	t.diagnostics = collector
+}
+
+// handleDiagnosticsKey opens the diagnostics modal on Ctrl+D and, while it is open,
+// closes it on Esc or Ctrl+D and swallows every other key except Ctrl+C
+func (t *TUIApp) handleDiagnosticsKey(event *tcell.EventKey) *tcell.EventKey {
+	if t.diagnosticsOpen {
+		switch event.Key() {
+		case tcell.KeyEscape, tcell.KeyCtrlD:
+			t.hideDiagnostics()
+		case tcell.KeyCtrlC:
+			return event
+		case tcell.KeyRune:
+			if event.Rune() == 'r' || event.Rune() == 'R' {
+				t.showDiagnostics()
+			}
+		}
+		return nil
+	}
+
+	if event.Key() == tcell.KeyCtrlD && t.diagnostics != nil && !t.IsInEditMode() {
+		t.showDiagnostics()
+		return nil
+	}
+	return event
+}
+
+// showDiagnostics collects a report and shows it in a modal over the current tab
+func (t *TUIApp) showDiagnostics() {
+	text := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(true)
+	text.SetBorder(true).
+		SetTitle(" Diagnostics (R: Refresh, Esc: Close) ").
+		SetTitleAlign(tview.AlignLeft)
+	text.SetText(formatDiagnostics(t.diagnostics.Collect()))
+
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(text, 24, 1, true).
+			AddItem(nil, 0, 1, false), 90, 1, true).
+		AddItem(nil, 0, 1, false)
+
+	t.pages.RemovePage(diagnosticsPage)
+	t.pages.AddPage(diagnosticsPage, modal, true, true)
+	t.app.SetFocus(text)
+	t.diagnosticsOpen = true
+}
+
+// hideDiagnostics closes the diagnostics modal
+func (t *TUIApp) hideDiagnostics() {
+	t.pages.RemovePage(diagnosticsPage)
+	t.app.SetFocus(t.pages)
+	t.diagnosticsOpen = false
+}
+
+// formatDiagnostics renders a diagnostics report as colored text
+func formatDiagnostics(report diagnostics.Report) string {
+	var b strings.Builder
+
+	build := report.Build
+	fmt.Fprintf(&b, "[yellow::b]Build[-::-]\n")
+	fmt.Fprintf(&b, "  Version: %s  Commit: %s\n", build.Version, build.Commit)
+	fmt.Fprintf(&b, "  Built: %s  Go: %s  Uptime: %s\n\n", build.Date, build.GoVersion, build.Uptime)
+
+	fmt.Fprintf(&b, "[yellow::b]Checks[-::-] (overall: %s)\n", colorStatus(report.Status))
+	for _, check := range report.Checks {
+		fmt.Fprintf(&b, "  %-15s %s  %s\n", check.Name, colorStatus(check.Status), tview.Escape(check.Message))
+	}
+
+	mem := report.Memory
+	fmt.Fprintf(&b, "\n[yellow::b]Runtime[-::-]\n")
+	fmt.Fprintf(&b, "  Goroutines: %d\n", report.Goroutines)
+	fmt.Fprintf(&b, "  Heap: %s in use, %s allocated  Sys: %s\n",
+		formatBytes(int64(mem.HeapInuseBytes)), formatBytes(int64(mem.HeapAllocBytes)), formatBytes(int64(mem.SysBytes)))
+	fmt.Fprintf(&b, "  GC: %d cycles, %.1fms total pause\n", mem.NumGC, mem.GCPauseTotalMs)
+
+	fmt.Fprintf(&b, "\n[yellow::b]Error Counters[-::-]\n")
+	names := make([]string, 0, len(report.ErrorCounters))
+	for name := range report.ErrorCounters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-15s %d\n", name, report.ErrorCounters[name])
+	}
+
+	return b.String()
+}
+
+// formatBytes formats a byte count with binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// colorStatus colors a diagnostics status
+func colorStatus(status string) string {
+	switch status {
+	case diagnostics.StatusOK:
+		return "[green]OK[white]"
+	case diagnostics.StatusWarn:
+		return "[yellow]WARN[white]"
+	case diagnostics.StatusFail:
+		return "[red]FAIL[white]"
+	default:
+		return "[gray]" + strings.ToUpper(status) + "[white]"
+	}
+}
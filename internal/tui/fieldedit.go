@@ -0,0 +1,297 @@
+package tui
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// editColumn is the endpoint field edited in edit mode; Tab cycles through them
+type editColumn int
+
+const (
+	editColumnPriority editColumn = iota
+	editColumnTimeout
+	editColumnGroup
+	editColumnURL
+	editColumnCount
+)
+
+var editColumnNames = [editColumnCount]string{"Priority", "Timeout", "Group", "URL"}
+
+func (c editColumn) String() string {
+	return editColumnNames[c]
+}
+
+// fieldEditorPage is the page name of the inline field editor
+const fieldEditorPage = "FieldEditor"
+
+// endpointEditKey identifies an endpoint in the temp edit maps; same-name endpoints in
+// different groups get different keys
+func endpointEditKey(name, group string) string {
+	if group == "" {
+		group = "Default"
+	}
+	return fmt.Sprintf("%s@%s", name, group)
+}
+
+// EditColumn returns the field Enter edits in edit mode
+func (t *TUIApp) EditColumn() editColumn {
+	t.editMutex.RLock()
+	defer t.editMutex.RUnlock()
+	return t.editColumn
+}
+
+// cycleEditColumn moves the edited field forward or backward
+func (t *TUIApp) cycleEditColumn(delta int) {
+	t.editMutex.Lock()
+	t.editColumn = (t.editColumn + editColumn(delta) + editColumnCount) % editColumnCount
+	t.editMutex.Unlock()
+	t.endpointsView.MarkDirty()
+	t.endpointsView.Update(t.collector.Latest())
+}
+
+// openFieldEditor shows an input field over the endpoints table for the selected
+// endpoint's current edit column. Enter validates and keeps the value, Esc cancels.
+func (t *TUIApp) openFieldEditor() {
+	ep := t.getSelectedEndpoint()
+	if ep == nil {
+		t.AddLog("WARN", "没有选中的端点", "TUI")
+		return
+	}
+	column := t.EditColumn()
+	value, _ := t.EffectiveField(ep, column)
+
+	input := tview.NewInputField().
+		SetLabel(column.String() + ": ").
+		SetText(value).
+		SetFieldWidth(0)
+	input.SetBorder(true).
+		SetTitle(fmt.Sprintf(" %s (Enter: OK, Esc: Cancel) ", ep.Config.Name)).
+		SetTitleAlign(tview.AlignLeft)
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			if err := t.SetEndpointField(ep, column, input.GetText()); err != nil {
+				input.SetTitle(fmt.Sprintf(" [red]%s[white] ", err.Error()))
+				return
+			}
+			t.closeFieldEditor()
+		case tcell.KeyEscape:
+			t.closeFieldEditor()
+		}
+	})
+
+	width := 60
+	if column == editColumnURL {
+		width = 90
+	}
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(input, 3, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+
+	t.pages.RemovePage(fieldEditorPage)
+	t.pages.AddPage(fieldEditorPage, modal, true, true)
+	t.app.SetFocus(input)
+	t.fieldEditorOpen = true
+}
+
+// closeFieldEditor removes the field editor and refreshes the table
+func (t *TUIApp) closeFieldEditor() {
+	t.pages.RemovePage(fieldEditorPage)
+	t.app.SetFocus(t.pages)
+	t.fieldEditorOpen = false
+	t.endpointsView.Update(t.collector.Latest())
+}
+
+// SetEndpointField validates a typed value and keeps it as a pending edit of the endpoint
+func (t *TUIApp) SetEndpointField(ep *endpoint.Endpoint, column editColumn, value string) error {
+	t.editMutex.Lock()
+	defer t.editMutex.Unlock()
+
+	if !t.editMode {
+		return fmt.Errorf("not in edit mode")
+	}
+	key := endpointEditKey(ep.Config.Name, ep.Config.Group)
+	original, ok := t.configEndpoint(key)
+	if !ok {
+		return fmt.Errorf("endpoint %s no longer exists", ep.Config.Name)
+	}
+
+	value = strings.TrimSpace(value)
+	edit := t.tempEdits[key]
+	switch column {
+	case editColumnPriority:
+		priority, err := strconv.Atoi(value)
+		if err != nil || priority < 1 || priority > 99 {
+			return fmt.Errorf("priority must be a number from 1 to 99")
+		}
+		t.tempPriorities[key] = priority
+	case editColumnTimeout:
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("timeout must be a positive duration, e.g. 30s or 2m")
+		}
+		edit.Timeout = timeout
+		if timeout == original.Timeout {
+			edit.Timeout = 0
+		}
+	case editColumnGroup:
+		if value == "" {
+			return fmt.Errorf("group must not be empty")
+		}
+		edit.Group = value
+		if value == original.Group {
+			edit.Group = ""
+		}
+	case editColumnURL:
+		if err := validateEndpointURL(value); err != nil {
+			return err
+		}
+		edit.URL = value
+		if value == original.URL {
+			edit.URL = ""
+		}
+	}
+
+	if edit == (config.EndpointEdit{}) {
+		delete(t.tempEdits, key)
+	} else {
+		t.tempEdits[key] = edit
+	}
+	t.isDirty = true
+	t.endpointsView.MarkDirty()
+
+	t.AddLog("INFO", fmt.Sprintf("端点 %s (组: %s) %s: %s", ep.Config.Name, original.Group, column, value), "TUI")
+	return nil
+}
+
+// validateEndpointURL accepts http(s) URLs with a host and unix:// URLs with an absolute path
+func validateEndpointURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		if parsed.Host == "" {
+			return fmt.Errorf("URL must include a host")
+		}
+	case "unix":
+		if !filepath.IsAbs(strings.TrimPrefix(value, "unix://")) {
+			return fmt.Errorf("unix socket URL must use an absolute path")
+		}
+	default:
+		return fmt.Errorf("URL must start with http://, https:// or unix://")
+	}
+	return nil
+}
+
+// EffectiveField returns an endpoint field as it would be saved, and whether it has a pending edit
+func (t *TUIApp) EffectiveField(ep *endpoint.Endpoint, column editColumn) (string, bool) {
+	t.editMutex.RLock()
+	defer t.editMutex.RUnlock()
+
+	key := endpointEditKey(ep.Config.Name, ep.Config.Group)
+	edit := t.tempEdits[key]
+	if !t.editMode {
+		edit = config.EndpointEdit{}
+	}
+	switch column {
+	case editColumnPriority:
+		if priority, exists := t.tempPriorities[key]; exists && t.editMode {
+			return strconv.Itoa(priority), priority != ep.Config.Priority
+		}
+		return strconv.Itoa(ep.Config.Priority), false
+	case editColumnTimeout:
+		if edit.Timeout > 0 {
+			return edit.Timeout.String(), true
+		}
+		return ep.Config.Timeout.String(), false
+	case editColumnGroup:
+		if edit.Group != "" {
+			return edit.Group, true
+		}
+		return ep.Config.Group, false
+	default:
+		if edit.URL != "" {
+			return edit.URL, true
+		}
+		return ep.Config.URL, false
+	}
+}
+
+// configEndpoint finds an endpoint of the current config by edit key (caller holds the lock)
+func (t *TUIApp) configEndpoint(key string) (config.EndpointConfig, bool) {
+	for _, ep := range t.cfg.Endpoints {
+		if endpointEditKey(ep.Name, ep.Group) == key {
+			return ep, true
+		}
+	}
+	return config.EndpointConfig{}, false
+}
+
+// applyFieldEdits applies pending timeout, group and URL edits to a copy of the config and
+// hands it to the endpoint manager. It returns the edits by endpoint index for the config
+// file saver (caller holds the lock).
+func (t *TUIApp) applyFieldEdits() map[int]config.EndpointEdit {
+	if len(t.tempEdits) == 0 {
+		return nil
+	}
+
+	newCfg := *t.cfg
+	newCfg.Endpoints = append([]config.EndpointConfig(nil), t.cfg.Endpoints...)
+	edits := make(map[int]config.EndpointEdit)
+	for i := range newCfg.Endpoints {
+		ep := &newCfg.Endpoints[i]
+		edit, ok := t.tempEdits[endpointEditKey(ep.Name, ep.Group)]
+		if !ok {
+			continue
+		}
+		if edit.Timeout > 0 {
+			ep.Timeout = edit.Timeout
+		}
+		if edit.URL != "" {
+			ep.URL = edit.URL
+		}
+		if edit.Group != "" {
+			edit.GroupPriority = groupPriorityFor(newCfg.Endpoints, edit.Group)
+			ep.Group, ep.GroupPriority = edit.Group, edit.GroupPriority
+		}
+		edits[i] = edit
+		t.AddLog("INFO", fmt.Sprintf("端点 %s 已更新 - 超时: %v, 组: %s, URL: %s",
+			ep.Name, ep.Timeout, ep.Group, ep.DisplayURL()), "TUI")
+	}
+
+	t.endpointManager.UpdateConfig(&newCfg)
+	t.cfg = &newCfg
+	return edits
+}
+
+// groupPriorityFor returns the priority of an existing group, or places a new group after
+// all existing ones
+func groupPriorityFor(endpoints []config.EndpointConfig, group string) int {
+	maxPriority := 0
+	for _, ep := range endpoints {
+		if ep.Group == group {
+			return ep.GroupPriority
+		}
+		if ep.GroupPriority > maxPriority {
+			maxPriority = ep.GroupPriority
+		}
+	}
+	return maxPriority + 1
+}
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+const fieldEditTestConfig = `# TUI edit test
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+    priority: 1
+    group: "main"
+    group-priority: 1
+    timeout: "30s"
+  - name: "secondary"  # inherits group and timeout
+    url: "https://secondary.example.com"
+    priority: 2
+tui:
+  save_edits: true
+`
+
+func newFieldEditTestApp(t *testing.T) (*TUIApp, string) {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(fieldEditTestConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	manager := endpoint.NewManager(cfg)
+	app := NewTUIApp(cfg, manager, middleware.NewMonitoringMiddleware(manager), time.Now(), configPath)
+	return app, configPath
+}
+
+func findEndpoint(app *TUIApp, name string) *endpoint.Endpoint {
+	for _, ep := range app.endpointManager.GetAllEndpoints() {
+		if ep.Config.Name == name {
+			return ep
+		}
+	}
+	return nil
+}
+
+func TestSetEndpointFieldValidation(t *testing.T) {
+	app, _ := newFieldEditTestApp(t)
+	ep := findEndpoint(app, "secondary")
+
+	if err := app.SetEndpointField(ep, editColumnTimeout, "45s"); err == nil {
+		t.Error("Expected edits outside edit mode to be rejected")
+	}
+	app.EnterEditMode()
+
+	invalid := []struct {
+		column editColumn
+		value  string
+	}{
+		{editColumnPriority, "0"},
+		{editColumnPriority, "high"},
+		{editColumnTimeout, "soon"},
+		{editColumnTimeout, "-5s"},
+		{editColumnGroup, "  "},
+		{editColumnURL, "ftp://example.com"},
+		{editColumnURL, "https://"},
+		{editColumnURL, "unix://relative.sock"},
+	}
+	for _, tc := range invalid {
+		if err := app.SetEndpointField(ep, tc.column, tc.value); err == nil {
+			t.Errorf("Expected %s %q to be rejected", tc.column, tc.value)
+		}
+	}
+	if app.HasUnsavedChanges() {
+		t.Error("Expected rejected values not to mark the edit dirty")
+	}
+
+	if err := app.SetEndpointField(ep, editColumnTimeout, "45s"); err != nil {
+		t.Fatalf("Expected a valid timeout to be accepted, got %v", err)
+	}
+	if value, changed := app.EffectiveField(ep, editColumnTimeout); value != "45s" || !changed {
+		t.Errorf("Expected a pending 45s timeout, got %q (changed=%v)", value, changed)
+	}
+	// Setting the original value again clears the pending edit
+	if err := app.SetEndpointField(ep, editColumnTimeout, "30s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, changed := app.EffectiveField(ep, editColumnTimeout); changed {
+		t.Error("Expected the original timeout to clear the pending edit")
+	}
+
+	// Esc discards pending field edits
+	app.SetEndpointField(ep, editColumnGroup, "backup")
+	app.ExitEditMode()
+	if value, changed := app.EffectiveField(ep, editColumnGroup); value != "main" || changed {
+		t.Errorf("Expected the group edit to be discarded, got %q (changed=%v)", value, changed)
+	}
+}
+
+func TestSaveEditsToConfig(t *testing.T) {
+	app, configPath := newFieldEditTestApp(t)
+	app.EnterEditMode()
+
+	primary := findEndpoint(app, "primary")
+	edits := []struct {
+		column editColumn
+		value  string
+	}{
+		{editColumnPriority, "3"},
+		{editColumnTimeout, "1m"},
+		{editColumnGroup, "backup"},
+		{editColumnURL, "https://primary2.example.com"},
+	}
+	for _, edit := range edits {
+		if err := app.SetEndpointField(primary, edit.column, edit.value); err != nil {
+			t.Fatalf("Failed to set %s: %v", edit.column, err)
+		}
+	}
+	if err := app.SaveEditsToConfig(); err != nil {
+		t.Fatalf("Failed to save edits: %v", err)
+	}
+	if app.HasUnsavedChanges() {
+		t.Error("Expected no unsaved changes after saving")
+	}
+
+	// The endpoint manager runs with the edited endpoint
+	primary = findEndpoint(app, "primary")
+	if primary.Config.Group != "backup" || primary.Config.GroupPriority != 2 || primary.Config.Timeout != time.Minute ||
+		primary.Config.URL != "https://primary2.example.com" || primary.Config.Priority != 3 {
+		t.Errorf("Expected the manager to use the edited endpoint, got %+v", primary.Config)
+	}
+	if secondary := findEndpoint(app, "secondary"); secondary.Config.Group != "main" || secondary.Config.Timeout != 30*time.Second {
+		t.Errorf("Expected secondary to be unchanged, got %+v", secondary.Config)
+	}
+
+	// The file keeps its comments and reloads to the same endpoints
+	data, _ := os.ReadFile(configPath)
+	saved, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to reload saved config: %v\n%s", err, data)
+	}
+	if saved.Endpoints[0].Group != "backup" || saved.Endpoints[0].Timeout != time.Minute || saved.Endpoints[0].Priority != 3 {
+		t.Errorf("Expected the edits in the saved file, got %+v\n%s", saved.Endpoints[0], data)
+	}
+	if saved.Endpoints[1].Group != "main" || saved.Endpoints[1].Timeout != 30*time.Second {
+		t.Errorf("Expected secondary to keep its inherited values, got %+v\n%s", saved.Endpoints[1], data)
+	}
+}
@@ -0,0 +1,315 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/monitor"
+)
+
+// GroupsView represents the groups tab: one row per group with its state and traffic
+type GroupsView struct {
+	container            *tview.Flex
+	table                *tview.Table
+	detailBox            *tview.TextView
+	monitoringMiddleware *middleware.MonitoringMiddleware
+	endpointManager      *endpoint.Manager
+
+	// Selection is kept by name so it follows the group when the order changes
+	selectedGroup string
+	rowGroups     []string // Group name of each table row after the header
+
+	// Dirty tracking: the table is rebuilt only when the text it shows has changed
+	snapshot       *Snapshot
+	dirty          atomic.Bool
+	rendered       bool
+	lastState      groupsViewState
+	lastTableHash  string // Table text last shown
+	lastDetailHash string // Detail text last shown; unchanged text keeps the scroll position
+	timed          bool   // A cooldown countdown is shown
+}
+
+// groupsViewState is the data the groups table depends on
+type groupsViewState struct {
+	counters         requestCounters
+	healthGeneration uint64
+	second           int64 // Collection second while a cooldown counts down
+}
+
+// groupRow is one rendered row of the groups table
+type groupRow struct {
+	group    *endpoint.GroupInfo
+	state    string
+	cooldown time.Duration
+	healthy  int
+	requests int64
+	success  int64
+	tokens   monitor.TokenUsage
+}
+
+func NewGroupsView(monitoringMiddleware *middleware.MonitoringMiddleware, endpointManager *endpoint.Manager) *GroupsView {
+	view := &GroupsView{
+		monitoringMiddleware: monitoringMiddleware,
+		endpointManager:      endpointManager,
+	}
+	view.setupUI()
+	return view
+}
+
+func (v *GroupsView) setupUI() {
+	v.table = tview.NewTable().SetBorders(true).SetSelectable(true, false).SetFixed(1, 0)
+	v.table.SetBorder(true).SetTitle(" 📂 Groups [A: Activate / C: Cooldown] ").SetTitleAlign(tview.AlignLeft)
+	v.table.SetSelectionChangedFunc(func(row, column int) {
+		if row > 0 && row <= len(v.rowGroups) {
+			v.selectedGroup = v.rowGroups[row-1]
+			v.updateDetails()
+		}
+	})
+
+	v.detailBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+	v.detailBox.SetBorder(true).SetTitle(" 📊 Group Details ").SetTitleAlign(tview.AlignLeft)
+
+	v.container = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(v.table, 0, 3, true).
+		AddItem(v.detailBox, 0, 2, false)
+}
+
+func (v *GroupsView) GetPrimitive() tview.Primitive {
+	return v.container
+}
+
+// MarkDirty forces the next Update to rebuild the table
+func (v *GroupsView) MarkDirty() {
+	v.dirty.Store(true)
+}
+
+// HandleKey runs the group actions on the selected group: a force-activates it, c puts it
+// into cooldown. It returns nil when the key was consumed.
+func (v *GroupsView) HandleKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune || v.selectedGroup == "" {
+		return event
+	}
+
+	groupManager := v.endpointManager.GetGroupManager()
+	switch event.Rune() {
+	case 'a', 'A':
+		groupManager.ActivateGroup(v.selectedGroup)
+	case 'c', 'C':
+		groupManager.StartGroupCooldown(v.selectedGroup)
+	default:
+		return event
+	}
+
+	if v.snapshot != nil {
+		v.MarkDirty()
+		v.Update(v.snapshot)
+	}
+	return nil
+}
+
+// Update rebuilds the table and details when the snapshot differs from the last render
+func (v *GroupsView) Update(snapshot *Snapshot) {
+	v.snapshot = snapshot
+	state := groupsViewState{
+		counters:         countersOf(snapshot.Metrics),
+		healthGeneration: snapshot.HealthGeneration,
+	}
+	if v.timed {
+		state.second = snapshot.CollectedAt.Unix()
+	}
+	if !v.dirty.Swap(false) && v.rendered && state == v.lastState {
+		return
+	}
+	v.rendered = true
+	v.lastState = state
+
+	if len(v.endpointManager.GetAllEndpoints()) == 0 {
+		v.rowGroups = nil
+		v.selectedGroup = ""
+		v.lastTableHash = ""
+		v.lastDetailHash = ""
+		v.table.Clear()
+		v.table.SetCell(0, 0, tview.NewTableCell("[yellow::b]🛠️ Setup mode[white::-] [gray]no endpoints configured[white]").
+			SetSelectable(false).
+			SetExpansion(1))
+		v.detailBox.SetText(setupModeHint)
+		return
+	}
+
+	rows := v.collectRows(snapshot.Metrics)
+	v.updateTable(rows)
+	v.updateDetails()
+}
+
+// collectRows gathers the state and aggregated traffic of every group, in priority order
+func (v *GroupsView) collectRows(metrics *monitor.Metrics) []groupRow {
+	groupManager := v.endpointManager.GetGroupManager()
+	preferred := groupManager.PreferredGroup()
+
+	v.timed = false
+	var rows []groupRow
+	for _, group := range groupManager.GetAllGroups() {
+		row := groupRow{group: group}
+		if remaining := groupManager.GetGroupCooldownRemaining(group.Name); remaining > 0 {
+			row.state = "[red::b]❄️ Cooldown[white::-]"
+			row.cooldown = remaining
+			v.timed = true
+		} else if group.IsActive && group.Name == preferred {
+			row.state = "[green::b]⭐ Active (forced)[white::-]"
+		} else if group.IsActive {
+			row.state = "[green::b]🟢 Active[white::-]"
+		} else {
+			row.state = "[gray::b]⚫ Standby[white::-]"
+		}
+
+		for _, ep := range group.Endpoints {
+			if ep.IsHealthy() {
+				row.healthy++
+			}
+			if stats := metrics.EndpointStats[ep.Config.Name]; stats != nil {
+				row.requests += stats.TotalRequests
+				row.success += stats.SuccessfulRequests
+				row.tokens.InputTokens += stats.TokenUsage.InputTokens
+				row.tokens.OutputTokens += stats.TokenUsage.OutputTokens
+				row.tokens.CacheCreationTokens += stats.TokenUsage.CacheCreationTokens
+				row.tokens.CacheReadTokens += stats.TokenUsage.CacheReadTokens
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// updateTable renders the rows, skipping the redraw when the text is unchanged
+func (v *GroupsView) updateTable(rows []groupRow) {
+	headers := []string{"Group", "Priority", "State", "Cooldown", "Healthy", "Reqs", "Success", "Tokens"}
+	cells := make([][]string, len(rows))
+	names := make([]string, len(rows))
+	var hash strings.Builder
+	for i, row := range rows {
+		cooldown := "-"
+		if row.cooldown > 0 {
+			cooldown = fmt.Sprintf("%ds", int(math.Ceil(row.cooldown.Seconds())))
+		}
+		success := "-"
+		if row.requests > 0 {
+			success = fmt.Sprintf("%.1f%%", float64(row.success)/float64(row.requests)*100)
+		}
+		cells[i] = []string{
+			row.group.Name,
+			fmt.Sprintf("%d", row.group.Priority),
+			row.state,
+			cooldown,
+			fmt.Sprintf("%d/%d", row.healthy, len(row.group.Endpoints)),
+			formatLargeNumber(row.requests),
+			success,
+			formatLargeNumber(row.tokens.InputTokens + row.tokens.OutputTokens),
+		}
+		names[i] = row.group.Name
+		hash.WriteString(strings.Join(cells[i], "\t"))
+		hash.WriteByte('\n')
+	}
+
+	if hash.String() == v.lastTableHash {
+		return
+	}
+	v.lastTableHash = hash.String()
+	v.rowGroups = names
+
+	v.table.Clear()
+	for col, header := range headers {
+		cell := tview.NewTableCell(fmt.Sprintf("[white::b]%s[white::-]", header)).
+			SetAlign(tview.AlignLeft).
+			SetSelectable(false)
+		if col == 0 {
+			cell.SetExpansion(1)
+		}
+		v.table.SetCell(0, col, cell)
+	}
+	selectedRow := 0
+	for i, rowCells := range cells {
+		for col, text := range rowCells {
+			v.table.SetCell(i+1, col, tview.NewTableCell(text).SetAlign(tview.AlignLeft))
+		}
+		if names[i] == v.selectedGroup {
+			selectedRow = i + 1
+		}
+	}
+
+	// Keep the selection on the same group, or select the first one
+	if selectedRow == 0 && len(names) > 0 {
+		selectedRow = 1
+		v.selectedGroup = names[0]
+	}
+	if selectedRow > 0 {
+		v.table.Select(selectedRow, 0)
+	}
+}
+
+// updateDetails shows the selected group's retry state, endpoints and token breakdown
+func (v *GroupsView) updateDetails() {
+	if v.snapshot == nil || v.selectedGroup == "" {
+		return
+	}
+	groupManager := v.endpointManager.GetGroupManager()
+	var group *endpoint.GroupInfo
+	for _, g := range groupManager.GetAllGroups() {
+		if g.Name == v.selectedGroup {
+			group = g
+			break
+		}
+	}
+	if group == nil {
+		v.detailBox.SetText("[gray]Group information not available[white]")
+		return
+	}
+	metrics := v.snapshot.Metrics
+
+	var detailText strings.Builder
+	detailText.WriteString(fmt.Sprintf("[blue::b]📂 Group: %s[white::-]  [gray](A: activate, C: cooldown)[white]\n", group.Name))
+	detailText.WriteString(fmt.Sprintf("Priority: [cyan]%d[white] | Strategy: [cyan]%s[white] | Retries: [cyan]%d/%d[white]\n",
+		group.Priority, groupManager.GetGroupStrategy(group.Name),
+		groupManager.GetGroupRetryCount(group.Name), groupManager.GetGroupMaxRetries(group.Name)))
+	retry := groupManager.GetGroupRetry(group.Name)
+	detailText.WriteString(fmt.Sprintf("Retry: [cyan]%d attempts, %v-%v x%g[white] | Cooldown: [cyan]%v[white]\n",
+		retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay, retry.Multiplier, groupManager.GetGroupCooldownDuration(group.Name)))
+
+	var tokens monitor.TokenUsage
+	detailText.WriteString("\n[yellow::b]📋 Endpoints[white::-]\n")
+	for _, ep := range group.Endpoints {
+		healthIcon := "🔴"
+		if ep.IsDisabled() {
+			healthIcon = "⏸️"
+		} else if ep.IsHealthy() {
+			healthIcon = "🟢"
+		}
+		var requests int64
+		if stats := metrics.EndpointStats[ep.Config.Name]; stats != nil {
+			requests = stats.TotalRequests
+			tokens.InputTokens += stats.TokenUsage.InputTokens
+			tokens.OutputTokens += stats.TokenUsage.OutputTokens
+			tokens.CacheCreationTokens += stats.TokenUsage.CacheCreationTokens
+			tokens.CacheReadTokens += stats.TokenUsage.CacheReadTokens
+		}
+		detailText.WriteString(fmt.Sprintf("%s %s (P:%d) - %s reqs\n",
+			healthIcon, ep.Config.Name, ep.Config.Priority, formatLargeNumber(requests)))
+	}
+
+	detailText.WriteString("\n[yellow::b]🪙 Tokens[white::-]\n")
+	detailText.WriteString(fmt.Sprintf("Input: [cyan]%s[white] | Output: [cyan]%s[white] | Cache create: [cyan]%s[white] | Cache read: [cyan]%s[white]\n",
+		formatLargeNumber(tokens.InputTokens), formatLargeNumber(tokens.OutputTokens),
+		formatLargeNumber(tokens.CacheCreationTokens), formatLargeNumber(tokens.CacheReadTokens)))
+
+	if newContent := detailText.String(); newContent != v.lastDetailHash {
+		v.lastDetailHash = newContent
+		v.detailBox.SetText(newContent)
+	}
+}
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+func TestGroupsViewActions(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		Endpoints: []config.EndpointConfig{
+			{Name: "main-1", URL: "https://main.example.com", Priority: 1, Group: "main", GroupPriority: 1, Timeout: time.Second},
+			{Name: "backup-1", URL: "https://backup.example.com", Priority: 1, Group: "backup", GroupPriority: 2, Timeout: time.Second},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(manager)
+	view := NewGroupsView(mm, manager)
+	view.Update(newCollector(mm, manager).Collect())
+
+	stateOf := func(row int) string {
+		return view.table.GetCell(row, 2).Text
+	}
+	if view.rowGroups[0] != "main" || !strings.Contains(stateOf(1), "Active") || !strings.Contains(stateOf(2), "Standby") {
+		t.Fatalf("Expected main active and backup on standby, got %v: %q, %q", view.rowGroups, stateOf(1), stateOf(2))
+	}
+
+	// Select the backup group and force-activate it
+	view.table.Select(2, 0)
+	if view.selectedGroup != "backup" {
+		t.Fatalf("Expected backup to be selected, got %q", view.selectedGroup)
+	}
+	view.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone))
+	if healthy := manager.GetHealthyEndpoints(); len(healthy) == 0 || healthy[0].Config.Name != "backup-1" {
+		t.Errorf("Expected traffic to move to the backup group")
+	}
+	if !strings.Contains(stateOf(2), "forced") || !strings.Contains(stateOf(1), "Standby") {
+		t.Errorf("Expected backup shown as force-activated, got %q, %q", stateOf(1), stateOf(2))
+	}
+
+	// Cooling the backup group down shows the countdown and moves traffic back
+	view.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'c', tcell.ModNone))
+	if !strings.Contains(stateOf(2), "Cooldown") || view.table.GetCell(2, 3).Text != "60s" {
+		t.Errorf("Expected backup in cooldown with a countdown, got %q, %q", stateOf(2), view.table.GetCell(2, 3).Text)
+	}
+	if view.selectedGroup != "backup" {
+		t.Errorf("Expected the selection to stay on backup, got %q", view.selectedGroup)
+	}
+	if healthy := manager.GetHealthyEndpoints(); len(healthy) == 0 || healthy[0].Config.Name != "main-1" {
+		t.Errorf("Expected traffic back on the main group")
+	}
+}
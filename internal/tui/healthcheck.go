@@ -0,0 +1,222 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"endpoint_forwarder/internal/endpoint"
+)
+
+// checkSpinnerFrames animate the details panel while a manual health check is in flight
+var checkSpinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// checkSpinnerInterval is how often the details panel is redrawn while checks run
+const checkSpinnerInterval = 120 * time.Millisecond
+
+// endpointCheck is the state of the last manual health check of an endpoint
+type endpointCheck struct {
+	running  bool
+	started  time.Time
+	result   endpoint.ProbeResult
+	err      error // The check could not run, e.g. the endpoint is in maintenance mode
+	finished time.Time
+}
+
+// healthChecks tracks manual health checks started from the endpoints view
+type healthChecks struct {
+	mu     sync.Mutex
+	checks map[string]*endpointCheck
+}
+
+// start marks the endpoint's check as running; false when one is already in flight
+func (h *healthChecks) start(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checks == nil {
+		h.checks = make(map[string]*endpointCheck)
+	}
+	if check := h.checks[name]; check != nil && check.running {
+		return false
+	}
+	h.checks[name] = &endpointCheck{running: true, started: time.Now()}
+	return true
+}
+
+// finish records the outcome of the endpoint's check
+func (h *healthChecks) finish(name string, result endpoint.ProbeResult, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = &endpointCheck{result: result, err: err, finished: time.Now()}
+}
+
+// running reports whether any check is in flight
+func (h *healthChecks) running() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, check := range h.checks {
+		if check.running {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns a copy of the endpoint's last check, if any
+func (h *healthChecks) get(name string) (endpointCheck, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	check := h.checks[name]
+	if check == nil {
+		return endpointCheck{}, false
+	}
+	return *check, true
+}
+
+// checkLine renders the endpoint's manual check for the details panel; empty when none was run
+func (h *healthChecks) checkLine(name string, now time.Time) string {
+	check, ok := h.get(name)
+	if !ok {
+		return ""
+	}
+	if check.running {
+		elapsed := now.Sub(check.started)
+		frame := checkSpinnerFrames[int(elapsed/checkSpinnerInterval)%len(checkSpinnerFrames)]
+		return fmt.Sprintf("[yellow]%s checking...[white] [gray](%.1fs)[white]", frame, elapsed.Seconds())
+	}
+
+	at := check.finished.Format("15:04:05")
+	switch {
+	case check.err != nil:
+		return fmt.Sprintf("[gray]%s[white] [red]%s[white]", at, tview.Escape(check.err.Error()))
+	case check.result.Error != nil:
+		return fmt.Sprintf("[gray]%s[white] 🔴 [red]%s[white] in [cyan]%dms[white]",
+			at, tview.Escape(truncateString(check.result.Error.Error(), 60)), check.result.ResponseTime.Milliseconds())
+	case check.result.Healthy:
+		return fmt.Sprintf("[gray]%s[white] 🟢 status [green]%d[white] in [cyan]%dms[white]",
+			at, check.result.StatusCode, check.result.ResponseTime.Milliseconds())
+	default:
+		return fmt.Sprintf("[gray]%s[white] 🔴 status [red]%d[white] in [cyan]%dms[white]",
+			at, check.result.StatusCode, check.result.ResponseTime.Milliseconds())
+	}
+}
+
+// checkDetail renders the manual check section of an endpoint's details
+func (v *EndpointsView) checkDetail(name string) string {
+	line := v.checks.checkLine(name, time.Now())
+	if line == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n[yellow::b]🩺 Manual Check[white::-]\n%s\n", line)
+}
+
+// groupCheckDetail renders the manual checks of a group's endpoints; empty when none was run
+func (v *EndpointsView) groupCheckDetail(endpoints []*endpoint.Endpoint) string {
+	var lines strings.Builder
+	now := time.Now()
+	for _, ep := range endpoints {
+		if line := v.checks.checkLine(ep.Config.Name, now); line != "" {
+			lines.WriteString(fmt.Sprintf("%s: %s\n", ep.Config.Name, line))
+		}
+	}
+	if lines.Len() == 0 {
+		return ""
+	}
+	return "\n[yellow::b]🩺 Manual Check[white::-]\n" + lines.String()
+}
+
+// checkSelectedEndpoints runs an on-demand health check on the selected endpoint, or on every
+// endpoint of the selected row's group when wholeGroup is set
+func (t *TUIApp) checkSelectedEndpoints(wholeGroup bool) {
+	if t.endpointsView == nil {
+		return
+	}
+
+	var names []string
+	if wholeGroup {
+		rowInfo, exists := t.endpointsView.groupRowMap[t.endpointsView.selectedRow]
+		if !exists {
+			t.AddLog("WARN", "没有选中的组", "TUI")
+			return
+		}
+		for _, ep := range t.endpointManager.GetAllEndpoints() {
+			group := ep.Config.Group
+			if group == "" {
+				group = "Default"
+			}
+			if group == rowInfo.GroupName {
+				names = append(names, ep.Config.Name)
+			}
+		}
+	} else if ep := t.getSelectedEndpoint(); ep != nil {
+		names = append(names, ep.Config.Name)
+	}
+	if len(names) == 0 {
+		t.AddLog("WARN", "没有选中的端点", "TUI")
+		return
+	}
+
+	t.runHealthChecks(names)
+}
+
+// runHealthChecks checks the endpoints concurrently, animating the details panel until all
+// of them have finished and refreshing the view with their new status
+func (t *TUIApp) runHealthChecks(names []string) {
+	view := t.endpointsView
+	var started []string
+	for _, name := range names {
+		if view.checks.start(name) {
+			started = append(started, name)
+		}
+	}
+	if len(started) == 0 {
+		return
+	}
+	t.refreshEndpointsView()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(checkSpinnerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.ctx.Done():
+				return
+			case <-ticker.C:
+				t.app.QueueUpdateDraw(view.updateDetails)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, name := range started {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			result, err := t.endpointManager.CheckEndpointNow(name)
+			view.checks.finish(name, result, err)
+			if err != nil {
+				t.AddLog("WARN", fmt.Sprintf("健康检查失败: %v", err), "TUI")
+			}
+		}(name)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+		t.app.QueueUpdateDraw(t.refreshEndpointsView)
+	}()
+}
+
+// refreshEndpointsView re-renders the endpoints view with a fresh snapshot
+func (t *TUIApp) refreshEndpointsView() {
+	if t.endpointsView != nil {
+		t.endpointsView.MarkDirty()
+		t.endpointsView.Update(t.collector.Collect())
+	}
+}
@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/internal/endpoint"
+)
+
+func TestHealthCheckStates(t *testing.T) {
+	var checks healthChecks
+	if line := checks.checkLine("primary", time.Now()); line != "" {
+		t.Fatalf("Expected no line before any check, got %q", line)
+	}
+
+	if !checks.start("primary") {
+		t.Fatal("Expected first check to start")
+	}
+	if checks.start("primary") {
+		t.Error("Expected a second check of the same endpoint to wait for the first")
+	}
+	if !checks.running() || !strings.Contains(checks.checkLine("primary", time.Now()), "checking...") {
+		t.Errorf("Expected running check to show progress, got %q", checks.checkLine("primary", time.Now()))
+	}
+
+	checks.finish("primary", endpoint.ProbeResult{Healthy: true, StatusCode: 200, ResponseTime: 42 * time.Millisecond}, nil)
+	if checks.running() {
+		t.Error("Expected no check in flight after it finished")
+	}
+	if line := checks.checkLine("primary", time.Now()); !strings.Contains(line, "200") || !strings.Contains(line, "42ms") {
+		t.Errorf("Expected status and latency in result, got %q", line)
+	}
+
+	checks.start("backup")
+	checks.finish("backup", endpoint.ProbeResult{Error: errors.New("connection refused"), ResponseTime: time.Millisecond}, nil)
+	if line := checks.checkLine("backup", time.Now()); !strings.Contains(line, "connection refused") {
+		t.Errorf("Expected probe error in result, got %q", line)
+	}
+}
@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// defaultNarrowWidth is the narrow layout threshold when tui.narrow_width is not set
+const defaultNarrowWidth = 120
+
+// minFitWidth is the fewest columns a truncated value gets, however narrow its panel
+const minFitWidth = 16
+
+// narrowWidth returns the terminal width below which views stack their panels vertically
+func (t *TUIApp) narrowWidth() int {
+	if t.cfg.TUI.NarrowWidth > 0 {
+		return t.cfg.TUI.NarrowWidth
+	}
+	return defaultNarrowWidth
+}
+
+// watchScreenWidth re-flows the views whenever the terminal width changes. The check runs
+// before every draw, which tview triggers on resize events, so the layout follows live.
+func (t *TUIApp) watchScreenWidth() {
+	t.app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		width, _ := screen.Size()
+		if t.applyScreenWidth(width) {
+			// Text is cut to the panel widths, so render the visible view again for the new size
+			go t.app.QueueUpdateDraw(func() {
+				if t.running {
+					t.renderView(t.currentTab, t.collector.Latest(), true)
+				}
+			})
+		}
+		return false
+	})
+}
+
+// applyScreenWidth switches the views between the wide and the narrow layout for a terminal
+// width and reports whether the layout changed. A reloaded tui.narrow_width is picked up on
+// the next draw. Must run on the UI goroutine.
+func (t *TUIApp) applyScreenWidth(width int) bool {
+	narrow := width < t.narrowWidth()
+	if width == t.screenWidth && narrow == t.narrow {
+		return false
+	}
+	t.screenWidth = width
+	if narrow != t.narrow {
+		t.narrow = narrow
+		t.overviewView.SetNarrow(narrow)
+		t.updateTabBar()
+	}
+	t.endpointsView.SetLayout(width, narrow)
+	t.markViewsDirty()
+	return true
+}
+
+// SetNarrow switches the overview between two columns and a single column
+func (v *OverviewView) SetNarrow(narrow bool) {
+	v.container.Clear()
+	if narrow {
+		v.container.
+			AddItem(v.metricsBox, 14, 0, false).
+			AddItem(v.chartBox, 12, 0, false).
+			AddItem(v.endpointsBox, 10, 0, false).
+			AddItem(v.systemBox, 0, 1, false).
+			AddItem(v.eventLine, 1, 0, false)
+		return
+	}
+
+	topFlex := tview.NewFlex().
+		AddItem(v.metricsBox, 0, 1, false).
+		AddItem(v.chartBox, 0, 1, false)
+
+	bottomFlex := tview.NewFlex().
+		AddItem(v.endpointsBox, 0, 1, false).
+		AddItem(v.systemBox, 0, 1, false)
+
+	v.container.
+		AddItem(topFlex, 14, 0, false).   // Increased height for top section (Request Metrics + Historical Token Usage)
+		AddItem(bottomFlex, 0, 1, false). // Remaining space for bottom (Endpoints Status + System Info)
+		AddItem(v.eventLine, 1, 0, false) // Last failover event
+}
+
+// SetLayout sizes the endpoints view for a terminal width: narrow terminals get the table
+// stacked above the details box instead of side by side
+func (v *EndpointsView) SetLayout(width int, narrow bool) {
+	v.width = width
+	if narrow != v.narrow {
+		v.narrow = narrow
+		if narrow {
+			v.container.SetDirection(tview.FlexRow)
+		} else {
+			v.container.SetDirection(tview.FlexColumn)
+		}
+	}
+	v.MarkDirty()
+}
+
+// panelWidths returns the inner widths of the table and the details box, derived from the
+// terminal width and the 3:2 split, or zero before the terminal size is known
+func (v *EndpointsView) panelWidths() (table, details int) {
+	if v.width <= 0 {
+		return 0, 0
+	}
+	if v.narrow {
+		return v.width - 2, v.width - 2
+	}
+	table = v.width * 3 / 5
+	return table - 2, v.width - table - 2
+}
+
+// fitWidth returns how many columns a value may use in a panel of the given inner width
+// after used columns of labels, or fallback while the width is unknown
+func fitWidth(width, used, fallback int) int {
+	if width <= 0 {
+		return fallback
+	}
+	if width-used < minFitWidth {
+		return minFitWidth
+	}
+	return width - used
+}
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+func TestResponsiveLayout(t *testing.T) {
+	url := "https://gateway.example.com/providers/anthropic/" + strings.Repeat("segment/", 10) + "v1"
+	cfg := &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Health:    config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:     config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		TUI:       config.TUIConfig{UpdateInterval: time.Second, NarrowWidth: 120},
+		Endpoints: []config.EndpointConfig{{Name: "primary", URL: url, Group: "main", GroupPriority: 1, Priority: 1, Timeout: time.Second}},
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(manager)
+	app := NewTUIApp(cfg, manager, mm, time.Now(), "config.yaml")
+	view := app.endpointsView
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+
+	// draw lays out and draws the overview and endpoints views for a terminal width, then
+	// returns the URL line of the selected endpoint's details
+	draw := func(width int) string {
+		screen.SetSize(width, 40)
+		app.applyScreenWidth(width)
+		view.Update(newCollector(mm, manager).Collect())
+		for row, info := range view.groupRowMap {
+			if info.Endpoint != nil {
+				view.selectedRow = row
+			}
+		}
+		view.updateDetails()
+		for _, primitive := range []tview.Primitive{app.overviewView.GetPrimitive(), view.GetPrimitive()} {
+			primitive.SetRect(0, 0, width, 40)
+			primitive.Draw(screen)
+		}
+		for _, line := range strings.Split(view.detailBox.GetText(true), "\n") {
+			if strings.HasPrefix(line, "URL: ") {
+				return line
+			}
+		}
+		t.Fatalf("Expected a URL line in the details at width %d", width)
+		return ""
+	}
+
+	for _, width := range []int{200, 80, 130} {
+		urlLine := draw(width)
+		narrow := width < cfg.TUI.NarrowWidth
+
+		tableX, tableY, _, _ := view.table.GetRect()
+		detailsX, detailsY, _, _ := view.detailBox.GetRect()
+		if stacked := detailsX == tableX && detailsY > tableY; stacked != narrow {
+			t.Errorf("Width %d: expected stacked endpoints panels %t, table at (%d,%d), details at (%d,%d)",
+				width, narrow, tableX, tableY, detailsX, detailsY)
+		}
+		metricsX, metricsY, _, _ := app.overviewView.metricsBox.GetRect()
+		chartX, chartY, _, _ := app.overviewView.chartBox.GetRect()
+		if singleColumn := chartX == metricsX && chartY > metricsY; singleColumn != narrow {
+			t.Errorf("Width %d: expected a single-column overview %t, metrics at (%d,%d), chart at (%d,%d)",
+				width, narrow, metricsX, metricsY, chartX, chartY)
+		}
+
+		// The URL uses the room the details box has instead of a fixed 35 characters
+		_, _, innerWidth, _ := view.detailBox.GetInnerRect()
+		if len(urlLine) > innerWidth || len(urlLine) <= 40 {
+			t.Errorf("Width %d: expected the URL line to fill up to %d columns, got %d: %q", width, innerWidth, len(urlLine), urlLine)
+		}
+	}
+}
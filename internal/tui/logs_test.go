@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// logLines returns the lines the logs view displays
+func logLines(view *LogsView) []string {
+	return strings.Split(strings.TrimSuffix(view.logText.GetText(true), "\n"), "\n")
+}
+
+func typeKeys(view *LogsView, keys string) {
+	for _, r := range keys {
+		view.HandleKey(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+}
+
+func TestLogsViewFilterSearchPause(t *testing.T) {
+	view := NewLogsView()
+	view.AddLog("INFO", "request forwarded", "proxy")
+	view.AddLog("WARN", "endpoint slow", "health")
+	view.AddLog("ERROR", "request failed on primary", "proxy")
+	view.AddLog("DEBUG", "probe details", "health")
+	view.AddLog("INFO", "health check failed for backup", "health")
+	view.Update()
+	if lines := logLines(view); len(lines) != 5 {
+		t.Fatalf("Expected all 5 entries, got %q", lines)
+	}
+
+	// Minimum level: e shows errors only, w warnings and above, i everything again
+	typeKeys(view, "e")
+	if lines := logLines(view); len(lines) != 1 || !strings.Contains(lines[0], "[ERR]") {
+		t.Errorf("Expected only the error, got %q", lines)
+	}
+	typeKeys(view, "w")
+	if lines := logLines(view); len(lines) != 2 {
+		t.Errorf("Expected the warning and the error, got %q", lines)
+	}
+	typeKeys(view, "i")
+
+	// Pausing keeps the display while new entries are counted
+	typeKeys(view, " ")
+	view.AddLog("ERROR", "another request failed", "proxy")
+	view.Update()
+	if lines := logLines(view); len(lines) != 5 || !strings.Contains(view.footer.GetText(true), "Paused, 1 new") {
+		t.Errorf("Expected the frozen display with 1 new entry, got %q and footer %q", lines, view.footer.GetText(true))
+	}
+
+	// Search filters the entries by source and message and counts the matches
+	typeKeys(view, "/failed")
+	view.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	lines := logLines(view)
+	if len(lines) != 3 || view.matchCount != 3 {
+		t.Fatalf("Expected the 3 entries containing 'failed', got %q (%d matches)", lines, view.matchCount)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "failed") {
+			t.Errorf("Expected only matching entries, got %q", line)
+		}
+	}
+	typeKeys(view, "e")
+	if lines := logLines(view); len(lines) != 2 {
+		t.Errorf("Expected search and level filter to combine, got %q", lines)
+	}
+
+	// Resuming follows new entries again
+	typeKeys(view, "i ")
+	view.HandleKey(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	view.AddLog("INFO", "request forwarded", "proxy")
+	view.Update()
+	if lines := logLines(view); len(lines) != 7 || strings.Contains(view.footer.GetText(true), "Paused") {
+		t.Errorf("Expected all 7 entries after resuming, got %q", lines)
+	}
+}
@@ -3,9 +3,11 @@ package tui
 import (
 	"fmt"
 	"math"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -13,8 +15,10 @@ import (
 	
 	"endpoint_forwarder/config"
 	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/logging"
 	"endpoint_forwarder/internal/middleware"
 	"endpoint_forwarder/internal/monitor"
+	"endpoint_forwarder/internal/notify"
 )
 
 // OverviewView represents the overview tab
@@ -24,13 +28,28 @@ type OverviewView struct {
 	chartBox            *tview.TextView
 	endpointsBox        *tview.TextView
 	systemBox           *tview.TextView
+	eventLine           *tview.TextView // One-line ticker with the last failover event
 	monitoringMiddleware *middleware.MonitoringMiddleware
 	endpointManager     *endpoint.Manager
 	responseTimeHistory []time.Duration
-	lastMetricsHash     string // Track metrics content changes
-	lastEndpointsHash   string // Track endpoints content changes  
-	lastSystemHash      string // Track system content changes
 	startTime           time.Time // App start time for uptime calculation
+
+	// Dirty tracking: each box re-renders only when the counters it shows have changed
+	dirty            atomic.Bool         // Forces the next Update to re-render every box
+	rendered         bool                // Whether the view has been rendered at least once
+	lastCounters     requestCounters     // Drives the metrics and chart boxes
+	lastChartMinute  int64               // Chart buckets shift once a minute
+	lastHealthGen    uint64              // Drives the endpoints box
+	endpointsTimed   bool                // Endpoints box shows cooldowns that expire over time
+	lastSystemState  overviewSystemState // Drives the system box
+	lastEvent        *notify.Event       // Drives the event line
+}
+
+// overviewSystemState is what the system box shows besides the request counters
+type overviewSystemState struct {
+	activeConnections  int
+	historyConnections int
+	uptimeSeconds      int64
 }
 
 // NewOverviewView creates a new overview view
@@ -58,27 +77,87 @@ func (v *OverviewView) setupUI() {
 	v.systemBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(false)
 	v.systemBox.SetBorder(true).SetTitle(" 💻 System Info ").SetTitleAlign(tview.AlignLeft)
 
-	topFlex := tview.NewFlex().
-		AddItem(v.metricsBox, 0, 1, false).
-		AddItem(v.chartBox, 0, 1, false)
+	v.eventLine = tview.NewTextView().SetDynamicColors(true).SetScrollable(false)
 
-	bottomFlex := tview.NewFlex().
-		AddItem(v.endpointsBox, 0, 1, false).
-		AddItem(v.systemBox, 0, 1, false)
-
-	v.container = tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(topFlex, 12, 0, false).   // Increased height for top section (Request Metrics + Historical Token Usage)  
-		AddItem(bottomFlex, 0, 1, false)  // Remaining space for bottom (Endpoints Status + System Info)
+	v.container = tview.NewFlex().SetDirection(tview.FlexRow)
+	v.SetNarrow(false)
 }
 
 func (v *OverviewView) GetPrimitive() tview.Primitive {
 	return v.container
 }
 
-func (v *OverviewView) Update() {
-	metrics := v.monitoringMiddleware.GetMetrics().GetMetrics()
-	
-	// Update metrics with content change detection
+// MarkDirty forces the next Update to re-render the whole view
+func (v *OverviewView) MarkDirty() {
+	v.dirty.Store(true)
+}
+
+// Update renders the parts of the overview whose data changed since the last render
+func (v *OverviewView) Update(snapshot *Snapshot) {
+	metrics := snapshot.Metrics
+	force := v.dirty.Swap(false) || !v.rendered
+	v.rendered = true
+
+	counters := countersOf(metrics)
+	countersChanged := force || counters != v.lastCounters
+	v.lastCounters = counters
+	if countersChanged {
+		v.renderMetrics(metrics)
+	}
+
+	if minute := snapshot.CollectedAt.Unix() / 60; countersChanged || minute != v.lastChartMinute {
+		v.lastChartMinute = minute
+		v.renderChart()
+	}
+
+	if force || snapshot.HealthGeneration != v.lastHealthGen || v.endpointsTimed {
+		v.lastHealthGen = snapshot.HealthGeneration
+		v.renderEndpoints()
+	}
+
+	systemState := overviewSystemState{
+		activeConnections:  len(metrics.ActiveConnections),
+		historyConnections: metrics.HistoricalConnections,
+		uptimeSeconds:      int64(snapshot.CollectedAt.Sub(v.startTime).Seconds()),
+	}
+	if countersChanged || systemState != v.lastSystemState {
+		v.lastSystemState = systemState
+		v.renderSystem(metrics, snapshot.CollectedAt.Sub(v.startTime))
+	}
+
+	if force || !sameEvent(snapshot.LastEvent, v.lastEvent) {
+		v.lastEvent = snapshot.LastEvent
+		v.renderEventLine(snapshot.LastEvent)
+	}
+}
+
+// sameEvent reports whether two events from the notifier history are the same one
+func sameEvent(a, b *notify.Event) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Time.Equal(b.Time) && a.Type == b.Type && a.Subject == b.Subject
+}
+
+// renderEventLine renders the last failover event ticker
+func (v *OverviewView) renderEventLine(event *notify.Event) {
+	if event == nil {
+		v.eventLine.SetText(" [gray]🔔 Last event: none[white]")
+		return
+	}
+	color := "yellow"
+	switch event.Type {
+	case config.NotifyEventEndpointHealthy, config.NotifyEventGroupCooldownExit:
+		color = "green"
+	case config.NotifyEventEndpointUnhealthy, config.NotifyEventAllEndpointsDown, config.NotifyEventConfigReloadFailed:
+		color = "red"
+	}
+	v.eventLine.SetText(fmt.Sprintf(" 🔔 Last event: [gray]%s[white] [%s]%s[white] %s",
+		event.Time.Format("15:04:05"), color, event.Type, tview.Escape(event.Message)))
+}
+
+// renderMetrics renders request and token totals
+func (v *OverviewView) renderMetrics(metrics *monitor.Metrics) {
 	avgTime := formatDurationShort(metrics.GetAverageResponseTime())
 	successRate := metrics.GetSuccessRate()
 	
@@ -96,6 +175,7 @@ func (v *OverviewView) Update() {
 	metricsText := fmt.Sprintf(`[white::b]Total Requests:[white::-] [cyan]%8d[white]
 [white::b]Successful:[white::-] [green]%8d[white] ([green]%5.1f%%[white])
 [white::b]Failed:[white::-] [red]%8d[white] ([red]%5.1f%%[white])
+[white::b]Errors Local/Upstream:[white::-] [yellow]%d[white] / [red]%d[white]
 [white::b]Avg Response Time:[white::-] [cyan]%8s[white]
 
 [yellow::b]🪙 Token Usage[white::-]
@@ -107,6 +187,7 @@ func (v *OverviewView) Update() {
 		metrics.TotalRequests,
 		metrics.SuccessfulRequests, successRate,
 		metrics.FailedRequests, 100-successRate,
+		metrics.LocalErrors, metrics.UpstreamErrors,
 		avgTime,
 		tokenStats.InputTokens,
 		tokenStats.OutputTokens,
@@ -114,74 +195,59 @@ func (v *OverviewView) Update() {
 		tokenStats.CacheReadTokens,
 		totalTokens)
 
-	// Only update metrics if content changed
-	if metricsText != v.lastMetricsHash {
-		v.lastMetricsHash = metricsText
-		v.metricsBox.SetText(metricsText)
-	}
-	
-	// Historical token usage from past connections
-	connectionHistory := metrics.ConnectionHistory
+	v.metricsBox.SetText(metricsText)
+}
+
+// renderChart renders historical token usage as a compact per-minute bar chart
+func (v *OverviewView) renderChart() {
+	buckets, interval := v.monitoringMiddleware.GetMetrics().GetTokenHistoryBuckets(8*time.Minute, time.Minute)
 	
-	// Show token usage for the last 3 connections that have token data
 	var chartText strings.Builder
-	chartText.WriteString("[yellow::b]🪙 Historical Token Usage[white::-]\n")
-	chartText.WriteString("[gray]Past connections with token consumption:[white]\n\n")
+	chartText.WriteString(fmt.Sprintf("[yellow::b]🪙 Token Usage[white::-] [gray](per %s, last %d)[white]\n", formatDurationShort(interval), len(buckets)))
 	
-	// Filter connections that have token usage and get the most recent 3
-	var connectionsWithTokens []*monitor.ConnectionInfo
-	for i := len(connectionHistory) - 1; i >= 0 && len(connectionsWithTokens) < 3; i-- {
-		conn := connectionHistory[i]
-		totalTokens := conn.TokenUsage.InputTokens + conn.TokenUsage.OutputTokens + 
-					   conn.TokenUsage.CacheCreationTokens + conn.TokenUsage.CacheReadTokens
-		if totalTokens > 0 {
-			connectionsWithTokens = append(connectionsWithTokens, conn)
+	maxTokens := int64(0)
+	for _, b := range buckets {
+		if b.TotalTokens > maxTokens {
+			maxTokens = b.TotalTokens
 		}
 	}
 	
-	if len(connectionsWithTokens) > 0 {
-		for i, conn := range connectionsWithTokens {
-			totalTokens := conn.TokenUsage.InputTokens + conn.TokenUsage.OutputTokens
-			totalCacheTokens := conn.TokenUsage.CacheCreationTokens + conn.TokenUsage.CacheReadTokens
-			
-			// Format connection info
-			clientIP := truncateString(conn.ClientIP, 12)
-			endpoint := truncateString(conn.Endpoint, 10)
-			if endpoint == "" || endpoint == "unknown" {
-				endpoint = "pending"
-			}
-			
-			// Status color
-			statusColor := "green"
-			statusText := "✓"
-			if conn.Status == "failed" {
-				statusColor = "red" 
-				statusText = "✗"
+	const barWidth = 20
+	for _, b := range buckets {
+		filled := 0
+		if maxTokens > 0 {
+			filled = int(b.TotalTokens * barWidth / maxTokens)
+			if filled == 0 && b.TotalTokens > 0 {
+				filled = 1
 			}
-			
-			chartText.WriteString(fmt.Sprintf("%d. [%s]%s[white] [cyan]%-12s[white] -> [yellow]%-10s[white]\n",
-				i+1, statusColor, statusText, clientIP, endpoint))
-			chartText.WriteString(fmt.Sprintf("   📥[cyan]%4d[white] 📤[cyan]%4d[white] 🆕[cyan]%3d[white] 📖[cyan]%3d[white] 🔢[magenta]%5d[white]\n\n",
-				conn.TokenUsage.InputTokens, conn.TokenUsage.OutputTokens,
-				conn.TokenUsage.CacheCreationTokens, conn.TokenUsage.CacheReadTokens,
-				totalTokens + totalCacheTokens))
 		}
-
-		// Fill remaining lines if fewer than 3 connections
-		for i := len(connectionsWithTokens); i < 3; i++ {
-			chartText.WriteString(fmt.Sprintf("%d. [gray]─[white]\n\n", i+1))
+		
+		// Split the bar into input/output portions
+		inputCells := 0
+		if b.TotalTokens > 0 {
+			inputCells = int(int64(filled) * b.InputTokens / b.TotalTokens)
 		}
+		outputCells := filled - inputCells
+		
+		chartText.WriteString(fmt.Sprintf("[gray]%s[white] [cyan]%s[green]%s[gray]%s[white] [magenta]%6s[white]\n",
+			b.Timestamp.Format("15:04"),
+			strings.Repeat("█", inputCells),
+			strings.Repeat("█", outputCells),
+			strings.Repeat("·", barWidth-filled),
+			formatLargeNumber(b.TotalTokens)))
+	}
+	
+	if maxTokens == 0 {
+		chartText.WriteString("[gray]No token usage in this window yet[white]")
 	} else {
-		chartText.WriteString("[gray]No connections with token usage yet...\n")
-		chartText.WriteString("Token consumption history will appear here\n")
-		chartText.WriteString("after processing Claude API requests.\n\n")
-		for i := 0; i < 3; i++ {
-			chartText.WriteString(fmt.Sprintf("%d. [gray]─[white]\n\n", i+1))
-		}
+		chartText.WriteString("[cyan]█[white] Input  [green]█[white] Output")
 	}
 	
 	v.chartBox.SetText(chartText.String())
-	
+}
+
+// renderEndpoints renders endpoint and group status
+func (v *OverviewView) renderEndpoints() {
 	// Endpoints status - maintain consistent formatting with group info
 	endpoints := v.endpointManager.GetAllEndpoints()
 	var statusText strings.Builder
@@ -204,13 +270,25 @@ func (v *OverviewView) Update() {
 		}
 	}
 	
+	// Cooldowns expire without a status change, so keep re-rendering while any is running
+	v.endpointsTimed = cooledGroupsCount > 0
+
 	statusText.WriteString(fmt.Sprintf("[white::b]Total:[white::-] [cyan]%3d[white] | [white::b]Healthy:[white::-] [green]%3d[white]\n", len(endpoints), healthyCount))
 	
-	// Show current active group with priority
+	// Show current active group with priority, success rate and tokens (same numbers as /api/groups)
 	if len(activeGroups) > 0 {
 		activeGroup := activeGroups[0] // First active group (highest priority)
-		statusText.WriteString(fmt.Sprintf("[white::b]Active Group:[white::-] [green]%s[white] (P:%d) | [cyan]%d[white]总组 ([red]%d冷却[white])\n\n", 
-			activeGroup.Name, activeGroup.Priority, len(allGroups), cooledGroupsCount))
+		groupStats := &monitor.GroupMetrics{}
+		for _, stats := range v.monitoringMiddleware.GetGroupStats() {
+			if stats.Name == activeGroup.Name {
+				groupStats = stats
+				break
+			}
+		}
+		statusText.WriteString(fmt.Sprintf("[white::b]Active Group:[white::-] [green]%s[white] (P:%d) [green]%.1f%%[white] [magenta]%s[white]tok | [cyan]%d[white]总组 ([red]%d冷却[white])\n\n", 
+			activeGroup.Name, activeGroup.Priority, groupStats.SuccessRate(),
+			formatLargeNumber(groupStats.TokenUsage.InputTokens+groupStats.TokenUsage.OutputTokens),
+			len(allGroups), cooledGroupsCount))
 	} else {
 		statusText.WriteString(fmt.Sprintf("[white::b]Groups:[white::-] [cyan]%2d[white] ([yellow]无活跃[white], [red]%d冷却[white])\n\n", 
 			len(allGroups), cooledGroupsCount))
@@ -268,27 +346,36 @@ func (v *OverviewView) Update() {
 		statusText.WriteString("[gray]... and more[white]")
 	}
 	
-	// Only update endpoints if content changed
 	endpointsContent := statusText.String()
-	if endpointsContent != v.lastEndpointsHash {
-		v.lastEndpointsHash = endpointsContent
-		v.endpointsBox.SetText(endpointsContent)
+	if len(endpoints) == 0 {
+		endpointsContent = setupModeHint
 	}
-	
+	v.endpointsBox.SetText(endpointsContent)
+}
+
+// renderSystem renders connection counts, uptime and top clients
+func (v *OverviewView) renderSystem(metrics *monitor.Metrics, uptime time.Duration) {
 	// System info - fixed width formatting
-	uptime := time.Since(v.startTime)
 	systemText := fmt.Sprintf(`[white::b]Active Connections:[white::-] [cyan]%6d[white]
 [white::b]Total Connections:[white::-] [cyan]%7d[white]
 [white::b]Uptime:[white::-] [cyan]%8s[white]`,
 		len(metrics.ActiveConnections),
-		len(metrics.ActiveConnections)+len(metrics.ConnectionHistory),
+		len(metrics.ActiveConnections)+metrics.HistoricalConnections,
 		formatUptimeShort(uptime))
 
-	// Only update system info if content changed
-	if systemText != v.lastSystemHash {
-		v.lastSystemHash = systemText
-		v.systemBox.SetText(systemText)
+	// Top clients by token usage
+	topClients := v.monitoringMiddleware.GetMetrics().GetTopClients(3)
+	if len(topClients) > 0 {
+		systemText += "\n\n[yellow::b]👥 Top Clients[white::-]"
+		for _, client := range topClients {
+			systemText += fmt.Sprintf("\n[cyan]%-18s[white] %5d req [green]%s[white] tok",
+				truncateString(client.ID, 18),
+				client.TotalRequests,
+				formatLargeNumber(client.TokenUsage.InputTokens+client.TokenUsage.OutputTokens))
+		}
 	}
+
+	v.systemBox.SetText(systemText)
 }
 
 // GroupRowInfo tracks information about each row in the grouped table
@@ -307,8 +394,27 @@ type EndpointsView struct {
 	endpointManager     *endpoint.Manager
 	tuiApp              *TUIApp  // Reference to main TUI app for edit mode
 	selectedRow         int
-	lastDetailHash      string // Track detail content changes
+	lastDetailHash      string // Detail text last shown; unchanged text keeps the scroll position
 	groupRowMap         map[int]GroupRowInfo // Track which rows are groups vs endpoints
+
+	// Dirty tracking: the table is rebuilt only when the data it shows has changed
+	snapshot  *Snapshot          // Latest snapshot, also used when the selection changes
+	dirty     atomic.Bool        // Forces the next Update to rebuild the table
+	rendered  bool               // Whether the view has been rendered at least once
+	lastState endpointsViewState // Counters the table was last rendered with
+	timed     bool               // Cooldowns or rate limits are shown and expire over time
+	checks    healthChecks       // Manual health checks started with t / T
+
+	// Responsive layout, set from the terminal width before each draw
+	width  int  // Terminal width, 0 until the first draw
+	narrow bool // Table stacked above the details box
+}
+
+// endpointsViewState is the data the endpoints table and details depend on
+type endpointsViewState struct {
+	counters          requestCounters
+	activeConnections int
+	healthGeneration  uint64
 }
 
 func NewEndpointsView(monitoringMiddleware *middleware.MonitoringMiddleware, endpointManager *endpoint.Manager) *EndpointsView {
@@ -347,18 +453,31 @@ func (v *EndpointsView) setupUI() {
 	v.setupTableHeaders()
 }
 
-// setupTableHeaders sets up the fixed table headers
+// setupTableHeaders sets up the fixed table headers; edit mode shows the editable
+// fields instead of the request stats
 func (v *EndpointsView) setupTableHeaders() {
 	headers := []string{"Status", "Name", "Priority", "Resp", "Reqs", "Fails"}
+	editing := v.tuiApp != nil && v.tuiApp.IsInEditMode()
+	expandCol := 2 // Priority column
+	activeCol := -1
+	if editing {
+		headers = []string{"Status", "Name", "Priority", "Timeout", "Group", "URL"}
+		expandCol = 5 // URL column
+		activeCol = 2 + int(v.tuiApp.EditColumn())
+	}
 	
 	for col, header := range headers {
-		cell := tview.NewTableCell(fmt.Sprintf("[white::b]%s[white::-]", header)).
+		text := fmt.Sprintf("[white::b]%s[white::-]", header)
+		if col == activeCol {
+			text = fmt.Sprintf("[yellow::b]▶ %s[white::-]", header)
+		}
+		cell := tview.NewTableCell(text).
 			SetTextColor(tcell.ColorWhite).
 			SetAlign(tview.AlignLeft).
 			SetSelectable(false)
 		
-		// Only Priority column should expand
-		if col == 2 { // Priority column
+		// Only one column should expand
+		if col == expandCol {
 			cell.SetExpansion(1)
 		}
 		
@@ -381,9 +500,10 @@ func (v *EndpointsView) updateTableTitle() {
 			saveHint = "Ctrl+S Save (No File)"
 		}
 		
-		title = fmt.Sprintf(" 🎯 Endpoints [Edit Mode%s - ESC to Exit %s] ", isDirty, saveHint)
+		title = fmt.Sprintf(" 🎯 Endpoints [Edit Mode%s - Tab: Field / Enter: Edit %s / ESC to Exit %s] ",
+			isDirty, v.tuiApp.EditColumn(), saveHint)
 	} else {
-		title = " 🎯 Endpoints [Enter to Edit / Number Keys for Priority] "
+		title = " 🎯 Endpoints [Enter to Edit / Number Keys for Priority / D: Maintenance / t/T: Check / O: Clear Overrides] "
 	}
 	v.table.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
 }
@@ -397,13 +517,33 @@ func (v *EndpointsView) SetTUIApp(app *TUIApp) {
 	v.tuiApp = app
 }
 
-func (v *EndpointsView) Update() {
+// MarkDirty forces the next Update to rebuild the table, e.g. after an edit mode change
+func (v *EndpointsView) MarkDirty() {
+	v.dirty.Store(true)
+}
+
+// Update rebuilds the table and details when the snapshot differs from the last render
+func (v *EndpointsView) Update(snapshot *Snapshot) {
+	v.snapshot = snapshot
+	state := endpointsViewState{
+		counters:          countersOf(snapshot.Metrics),
+		activeConnections: len(snapshot.Metrics.ActiveConnections),
+		healthGeneration:  snapshot.HealthGeneration,
+	}
+	if !v.dirty.Swap(false) && v.rendered && !v.timed && state == v.lastState {
+		return
+	}
+	v.rendered = true
+	v.lastState = state
+
 	// Update table title first
 	v.updateTableTitle()
 	
 	v.updateTable()
 	// Update details for currently selected row
-	if v.selectedRow > 0 {
+	if len(v.endpointManager.GetAllEndpoints()) == 0 {
+		v.detailBox.SetText(setupModeHint)
+	} else if v.selectedRow > 0 {
 		v.updateDetails()
 	} else {
 		v.detailBox.SetText("[gray]Select an endpoint to view details[white]\n\n[yellow]Use arrow keys to navigate[white]")
@@ -413,7 +553,7 @@ func (v *EndpointsView) Update() {
 // updateTable updates the endpoints table efficiently with grouped format
 func (v *EndpointsView) updateTable() {
 	endpoints := v.endpointManager.GetAllEndpoints()
-	metrics := v.monitoringMiddleware.GetMetrics().GetMetrics()
+	metrics := v.snapshot.Metrics
 	
 	// Group endpoints by group name
 	groupedEndpoints := make(map[string][]*endpoint.Endpoint)
@@ -428,10 +568,41 @@ func (v *EndpointsView) updateTable() {
 	// Get groups sorted by priority
 	groupManager := v.endpointManager.GetGroupManager()
 	allGroups := groupManager.GetAllGroups()
+
+	// Cooldown countdowns and rate limits expire without a status change, so keep
+	// re-rendering while any is shown
+	now := time.Now()
+	v.timed = v.checks.running()
+	for _, group := range allGroups {
+		if groupManager.IsGroupInCooldown(group.Name) {
+			v.timed = true
+			break
+		}
+	}
+	for _, ep := range endpoints {
+		if status := ep.GetStatus(); status.IsRateLimited(now) || status.BreakerState(now) != endpoint.BreakerClosed {
+			v.timed = true
+			break
+		}
+		// Rate limit buckets refill without a status change
+		if tokens, burst := ep.RateTokens(); ep.RateLimit() != nil && tokens < float64(burst) {
+			v.timed = true
+			break
+		}
+	}
 	
 	// Clear existing table content but preserve headers
 	v.table.Clear()
 	v.setupTableHeaders()
+
+	if len(endpoints) == 0 {
+		v.groupRowMap = make(map[int]GroupRowInfo)
+		v.selectedRow = 0
+		v.table.SetCell(1, 0, tview.NewTableCell("[yellow::b]🛠️ Setup mode[white::-] [gray]no endpoints configured[white]").
+			SetSelectable(false).
+			SetExpansion(1))
+		return
+	}
 	
 	currentRow := 1 // Start from row 1 (row 0 is headers)
 	v.groupRowMap = make(map[int]GroupRowInfo) // Track which rows are groups vs endpoints
@@ -502,7 +673,8 @@ func (v *EndpointsView) addGroupHeaderRow(row int, group *endpoint.GroupInfo, gr
 	
 	// Create multi-line group header with full group name
 	groupLine1 := fmt.Sprintf("%s %s P%d[white::-]", groupColor, group.Name, group.Priority)
-	groupLine2 := fmt.Sprintf("%s %s %d/%d[white::-]", groupColor, groupStatusText, healthyCount, len(groupEndpoints))
+	groupLine2 := fmt.Sprintf("%s %s %d/%d[white::-] [gray]%s[white]", groupColor, groupStatusText, healthyCount, len(groupEndpoints),
+		groupManager.GetGroupStrategy(group.Name))
 	
 	// Set group header cell spanning first 2 columns (Status, Name) with multi-line content
 	groupHeaderText := fmt.Sprintf("%s\n%s", groupLine1, groupLine2)
@@ -529,7 +701,18 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 	
 	// Status icon
 	statusIcon := "🔴"
-	if status.Healthy {
+	if status.Disabled {
+		statusIcon = "⏸️"
+	} else if status.IsRateLimited(time.Now()) {
+		statusIcon = "🚦"
+	} else if state := status.BreakerState(time.Now()); status.Healthy && state != endpoint.BreakerClosed {
+		statusIcon = "🔌"
+		if state == endpoint.BreakerHalfOpen {
+			statusIcon = "🟡"
+		}
+	} else if tokens, _ := ep.RateTokens(); status.Healthy && ep.RateLimit() != nil && tokens < 1 {
+		statusIcon = "⏱️"
+	} else if status.Healthy {
 		statusIcon = "🟢"
 	}
 	
@@ -573,12 +756,10 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 	}
 	
 	// Priority text with edit mode indicator
+	editing := v.tuiApp != nil && v.tuiApp.IsInEditMode()
 	priorityText := fmt.Sprintf("%d", effectivePriority)
-	if v.tuiApp != nil && v.tuiApp.IsInEditMode() {
-		priorityText += " [Edit]"
-		if isHighestPriority {
-			priorityText = fmt.Sprintf("[red::b]%d [Edit][white::-]", effectivePriority)
-		}
+	if editing && isHighestPriority {
+		priorityText = fmt.Sprintf("[red::b]%d[white::-]", effectivePriority)
 	} else if isHighestPriority {
 		priorityText = fmt.Sprintf("[green::b]%d[white::-]", effectivePriority)
 	}
@@ -591,7 +772,22 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 		priorityText,                                                      // Priority
 		fmt.Sprintf("%dms", status.ResponseTime.Milliseconds()),           // Response time
 		fmt.Sprintf("%d", totalReqs),                                      // Requests
-		fmt.Sprintf("%d", v.getEndpointFailedRequests(ep.Config.Name)),   // API Request Failures
+		fmt.Sprintf("%d", endpointFailedRequests(metrics, ep.Config.Name)), // API Request Failures
+	}
+	if editing {
+		// Editable fields replace the stats; pending edits are highlighted
+		for column := editColumnTimeout; column < editColumnCount; column++ {
+			value, changed := v.tuiApp.EffectiveField(ep, column)
+			if column == editColumnURL {
+				tableWidth, _ := v.panelWidths()
+				value = smartTruncateURL(value, fitWidth(tableWidth, editTableFixedWidth, 30))
+			}
+			if changed {
+				value = fmt.Sprintf("[yellow]%s*[white]", value)
+			}
+			cells[2+int(column)] = value
+		}
+		cells[2+int(v.tuiApp.EditColumn())] += " [Edit]"
 	}
 	
 	for col, text := range cells {
@@ -603,6 +799,10 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 	}
 }
 
+// editTableFixedWidth is roughly what the edit-mode table needs besides the URL column:
+// the status, name, priority, timeout and group columns, the [Edit] marker and the borders
+const editTableFixedWidth = 60
+
 // addSeparatorRow adds a separator row between groups
 func (v *EndpointsView) addSeparatorRow(row int) {
 	for col := 0; col < 7; col++ {
@@ -614,7 +814,10 @@ func (v *EndpointsView) addSeparatorRow(row int) {
 
 // updateDetails updates the detail view for the selected endpoint
 func (v *EndpointsView) updateDetails() {
-	metrics := v.monitoringMiddleware.GetMetrics().GetMetrics()
+	if v.snapshot == nil {
+		return
+	}
+	metrics := v.snapshot.Metrics
 	
 	// Check if selected row is valid and get the row info
 	rowInfo, exists := v.groupRowMap[v.selectedRow]
@@ -642,9 +845,17 @@ func (v *EndpointsView) updateDetails() {
 	detailText.WriteString(fmt.Sprintf("[yellow::b]📋 Group Info[white::-]\n"))
 	detailText.WriteString(fmt.Sprintf("Group: [cyan]%s[white] | Priority: [cyan]%d[white]\n", groupName, endpoint.Config.GroupPriority))
 	
-	// Basic Info - Use smart URL truncation
+	// Basic Info - Use smart URL truncation, sized to the details box
+	_, detailsWidth := v.panelWidths()
 	detailText.WriteString("\n[yellow::b]📋 Basic Info[white::-]\n")
-	detailText.WriteString(fmt.Sprintf("URL: [cyan]%s[white]\n", smartTruncateURL(endpoint.Config.URL, 35)))
+	if endpoint.Config.IsUnixSocket() {
+		detailText.WriteString(fmt.Sprintf("Socket: [cyan]%s[white]\n", truncateString(endpoint.Config.SocketPath(), fitWidth(detailsWidth, len("Socket: "), 35))))
+		if endpoint.Config.UnixPathPrefix != "" {
+			detailText.WriteString(fmt.Sprintf("Path Prefix: [cyan]%s[white]\n", endpoint.Config.UnixPathPrefix))
+		}
+	} else {
+		detailText.WriteString(fmt.Sprintf("URL: [cyan]%s[white]\n", smartTruncateURL(endpoint.Config.URL, fitWidth(detailsWidth, len("URL: "), 35))))
+	}
 	detailText.WriteString(fmt.Sprintf("Priority: [cyan]%d[white] | Timeout: [cyan]%v[white]\n", 
 		endpoint.Config.Priority, endpoint.Config.Timeout))
 	
@@ -652,13 +863,67 @@ func (v *EndpointsView) updateDetails() {
 	detailText.WriteString("\n[yellow::b]❤️ Health[white::-]\n")
 	healthStatus := "[red]Unhealthy[white]"
 	healthIcon := "🔴"
-	if status.Healthy {
+	if status.Disabled {
+		healthStatus = "[yellow]Maintenance[white]"
+		healthIcon = "⏸️"
+	} else if status.Healthy {
 		healthStatus = "[green]Healthy[white]"
 		healthIcon = "🟢"
 	}
 	detailText.WriteString(fmt.Sprintf("%s %s | [cyan]%dms[white] | Fails: [red]%d[white]\n", 
-		healthIcon, healthStatus, status.ResponseTime.Milliseconds(), v.getEndpointFailedRequests(endpoint.Config.Name)))
+		healthIcon, healthStatus, status.ResponseTime.Milliseconds(), endpointFailedRequests(metrics, endpoint.Config.Name)))
 	detailText.WriteString(fmt.Sprintf("Last Check: [cyan]%v[white]\n", status.LastCheck.Format("15:04:05")))
+	healthyThreshold, unhealthyThreshold := v.endpointManager.HealthThresholds()
+	detailText.WriteString(fmt.Sprintf("In a row: [green]%d ok[white] / [red]%d failed[white] (healthy after %d, unhealthy after %d)\n",
+		status.ConsecutiveOKs, status.ConsecutiveFails, healthyThreshold, unhealthyThreshold))
+	if status.LatencySamples > 0 {
+		detailText.WriteString(fmt.Sprintf("Latency EWMA: [cyan]%dms[white] (%d samples, last [cyan]%dms[white])\n",
+			status.LatencyEWMA.Milliseconds(), status.LatencySamples, status.LastLatency.Milliseconds()))
+	}
+	if status.IsRateLimited(time.Now()) {
+		detailText.WriteString(fmt.Sprintf("🚦 Rate limited for [yellow]%ds[white] (until %s)\n",
+			int(math.Ceil(time.Until(status.RateLimitedUntil).Seconds())), status.RateLimitedUntil.Format("15:04:05")))
+	}
+	if breaker := v.endpointManager.GetConfig().CircuitBreaker; breaker.Enabled {
+		detailText.WriteString(breakerDetail(endpoint, breaker))
+	}
+	if endpoint.Config.IsOAuth2() {
+		expiry := "[red]no token[white]"
+		if !status.AuthExpiry.IsZero() {
+			expiry = fmt.Sprintf("[cyan]%s[white]", status.AuthExpiry.Format("15:04:05"))
+		}
+		detailText.WriteString(fmt.Sprintf("🔑 OAuth2 Token Expires: %s\n", expiry))
+		if status.AuthError != "" {
+			detailText.WriteString(fmt.Sprintf("[red]OAuth2 Error: %s[white]\n", tview.Escape(truncateString(status.AuthError, 60))))
+		}
+	}
+	for i, token := range endpoint.TokenStates() {
+		if token.Available {
+			detailText.WriteString(fmt.Sprintf("🔑 Token %d: [cyan]%s[white] [green]active[white]\n", i+1, tview.Escape(token.Token)))
+		} else {
+			detailText.WriteString(fmt.Sprintf("🔑 Token %d: [cyan]%s[white] [red]%d until %s[white]\n",
+				i+1, tview.Escape(token.Token), token.StatusCode, token.DisabledUntil.Format("15:04:05")))
+		}
+	}
+	if limit := endpoint.MaxConcurrent(); limit > 0 {
+		inFlightColor := "cyan"
+		if endpoint.InFlight() >= int64(limit) {
+			inFlightColor = "yellow"
+		}
+		detailText.WriteString(fmt.Sprintf("In-flight: [%s]%d/%d[white]\n", inFlightColor, endpoint.InFlight(), limit))
+	} else {
+		detailText.WriteString(fmt.Sprintf("In-flight: [cyan]%d[white]\n", endpoint.InFlight()))
+	}
+	if limit := endpoint.RateLimit(); limit != nil {
+		tokens, burst := endpoint.RateTokens()
+		tokenColor := "cyan"
+		if tokens < 1 {
+			tokenColor = "yellow"
+		}
+		detailText.WriteString(fmt.Sprintf("⏱️ Rate Limit: [%s]%.1f/%d[white] tokens (%d/min, %s)\n",
+			tokenColor, math.Max(tokens, 0), burst, limit.RequestsPerMinute, limit.OnExceeded))
+	}
+	detailText.WriteString(v.checkDetail(endpoint.Config.Name))
 	
 	// Performance Metrics - Only show if there's data
 	if endpointStats := metrics.EndpointStats[endpoint.Config.Name]; endpointStats != nil && endpointStats.TotalRequests > 0 {
@@ -668,6 +933,9 @@ func (v *EndpointsView) updateDetails() {
 		successRate := float64(endpointStats.SuccessfulRequests) / float64(endpointStats.TotalRequests) * 100
 		detailText.WriteString(fmt.Sprintf("Requests: [cyan]%s[white] | Success: [green]%.1f%%[white] | Retries: [yellow]%s[white]\n",
 			formatLargeNumber(endpointStats.TotalRequests), successRate, formatLargeNumber(int64(endpointStats.RetryCount))))
+		if endpointStats.RateLimitCount > 0 {
+			detailText.WriteString(fmt.Sprintf("429 Responses: [yellow]%s[white]\n", formatLargeNumber(endpointStats.RateLimitCount)))
+		}
 		
 		// Response time metrics
 		avgResponseTime := endpointStats.TotalResponseTime / time.Duration(endpointStats.TotalRequests)
@@ -675,6 +943,12 @@ func (v *EndpointsView) updateDetails() {
 			formatDurationShort(avgResponseTime),
 			formatDurationShort(endpointStats.MinResponseTime),
 			formatDurationShort(endpointStats.MaxResponseTime)))
+		if endpointStats.Latency.Count() > 0 {
+			p50, p95, p99 := endpointStats.LatencyPercentiles()
+			detailText.WriteString(fmt.Sprintf("P50: [cyan]%s[white]\n", formatDurationShort(p50)))
+			detailText.WriteString(fmt.Sprintf("P95: [yellow]%s[white]\n", formatDurationShort(p95)))
+			detailText.WriteString(fmt.Sprintf("P99: [red]%s[white]\n", formatDurationShort(p99)))
+		}
 		
 		// Last used info
 		if !endpointStats.LastUsed.IsZero() {
@@ -733,9 +1007,8 @@ func (v *EndpointsView) updateDetails() {
 	}
 }
 
-// getEndpointFailedRequests returns the number of failed API requests for an endpoint
-func (v *EndpointsView) getEndpointFailedRequests(endpointName string) int64 {
-	metrics := v.monitoringMiddleware.GetMetrics().GetMetrics()
+// endpointFailedRequests returns the number of failed API requests for an endpoint
+func endpointFailedRequests(metrics *monitor.Metrics, endpointName string) int64 {
 	if endpointStats := metrics.EndpointStats[endpointName]; endpointStats != nil {
 		return endpointStats.FailedRequests
 	}
@@ -774,6 +1047,12 @@ func (v *EndpointsView) showGroupDetails(groupName string) {
 	}
 	
 	detailText.WriteString(fmt.Sprintf("Priority: [cyan]%d[white]\n", selectedGroup.Priority))
+	detailText.WriteString(fmt.Sprintf("Strategy: [cyan]%s[white]\n", groupManager.GetGroupStrategy(selectedGroup.Name)))
+	retry := groupManager.GetGroupRetry(selectedGroup.Name)
+	detailText.WriteString(fmt.Sprintf("Retry: [cyan]%d attempts, %v-%v x%g[white]\n",
+		retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay, retry.Multiplier))
+	detailText.WriteString(fmt.Sprintf("Cooldown: [cyan]%v after %d retries[white]\n",
+		groupManager.GetGroupCooldownDuration(selectedGroup.Name), groupManager.GetGroupMaxRetries(selectedGroup.Name)))
 	detailText.WriteString(fmt.Sprintf("Endpoints: [cyan]%d[white]\n\n", len(selectedGroup.Endpoints)))
 	
 	// List endpoints in this group
@@ -788,6 +1067,7 @@ func (v *EndpointsView) showGroupDetails(groupName string) {
 		detailText.WriteString(fmt.Sprintf("%d. %s %s (P:%d, %dms)\n", 
 			i+1, healthIcon, ep.Config.Name, ep.Config.Priority, status.ResponseTime.Milliseconds()))
 	}
+	detailText.WriteString(v.groupCheckDetail(selectedGroup.Endpoints))
 	
 	v.detailBox.SetText(detailText.String())
 }
@@ -796,11 +1076,31 @@ func (v *EndpointsView) showGroupDetails(groupName string) {
 type ConnectionsView struct {
 	container           *tview.Flex
 	statsBox            *tview.TextView
+	attemptsBox         *tview.TextView // Upstream attempt timeline of the selected connection
 	monitoringMiddleware *middleware.MonitoringMiddleware
 	endpointManager     *endpoint.Manager  // Add endpoint manager reference
 	config              *config.Config
-	lastDisplayHash     string // Track content changes to avoid unnecessary updates
-	needsUpdate         bool   // Flag to indicate if data has changed since last display
+
+	// Dirty tracking: the list is re-rendered only when the data it shows has changed
+	dirty     atomic.Bool          // Forces the next Update to re-render
+	rendered  bool                 // Whether the view has been rendered at least once
+	lastState connectionsViewState // Counters the list was last rendered with
+
+	// Selection (only changed from the UI goroutine); kept by ID so it follows the connection
+	selectedID   string
+	lastSnapshot *Snapshot // Snapshot of the last Update, re-rendered when the selection moves
+}
+
+// connectionsViewState is the data the connections list depends on
+type connectionsViewState struct {
+	counters           requestCounters
+	activeConnections  int
+	historyConnections int
+	retries            int
+	second             int64 // Collection second while connections are active (durations tick)
+	cancelledShown     int   // Recently cancelled connections still listed
+	selectedID         string
+	selectedAttempts   int
 }
 
 func NewConnectionsView(monitoringMiddleware *middleware.MonitoringMiddleware, endpointManager *endpoint.Manager, cfg *config.Config) *ConnectionsView {
@@ -816,43 +1116,157 @@ func NewConnectionsView(monitoringMiddleware *middleware.MonitoringMiddleware, e
 func (v *ConnectionsView) setupUI() {
 	v.statsBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
 	v.statsBox.SetBorder(true).SetTitle(" 🔌 Active Connections ").SetTitleAlign(tview.AlignLeft)
-	
-	v.container = tview.NewFlex().AddItem(v.statsBox, 0, 1, true)
+
+	v.attemptsBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+	v.attemptsBox.SetBorder(true).SetTitle(" 🔁 Upstream Attempts ").SetTitleAlign(tview.AlignLeft)
+
+	v.container = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(v.statsBox, 0, 1, true).
+		AddItem(v.attemptsBox, v.config.Retry.MaxAttempts+4, 0, false)
 }
 
 func (v *ConnectionsView) GetPrimitive() tview.Primitive {
 	return v.container
 }
 
-func (v *ConnectionsView) Update() {
-	metrics := v.monitoringMiddleware.GetMetrics().GetMetrics()
-	
-	// Build display text
-	var stats strings.Builder
-	stats.WriteString(fmt.Sprintf("[blue::b]📊 Connection Statistics[white::-]\n"))
-	stats.WriteString(fmt.Sprintf("Active: [cyan]%3d[white] | Historical: [cyan]%4d[white]\n\n", 
-		len(metrics.ActiveConnections), len(metrics.ConnectionHistory)))
-	
-	stats.WriteString("[blue::b]🔗 Active Connections[white::-]\n")
-	
-	// Convert map to slice for stable sorting
+// MarkDirty forces the next Update to re-render the list
+func (v *ConnectionsView) MarkDirty() {
+	v.dirty.Store(true)
+}
+
+// HandleKey moves the connection selection with the arrow keys (or j/k) and clears it
+// with Escape; it returns nil when the key was consumed
+func (v *ConnectionsView) HandleKey(event *tcell.EventKey) *tcell.EventKey {
+	if v.lastSnapshot == nil {
+		return event
+	}
+
+	direction := 0
+	switch event.Key() {
+	case tcell.KeyUp:
+		direction = -1
+	case tcell.KeyDown:
+		direction = 1
+	case tcell.KeyEscape:
+		if v.selectedID == "" {
+			return event
+		}
+		v.selectedID = ""
+		v.MarkDirty()
+		v.Update(v.lastSnapshot)
+		return nil
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'k':
+			direction = -1
+		case 'j':
+			direction = 1
+		default:
+			return event
+		}
+	default:
+		return event
+	}
+
+	connections := v.visibleConnections(v.lastSnapshot)
+	if len(connections) == 0 {
+		return nil
+	}
+	index := -1
+	for i, conn := range connections {
+		if conn.ID == v.selectedID {
+			index = i
+		}
+	}
+	switch {
+	case index < 0 && direction > 0:
+		index = 0
+	case index < 0:
+		index = len(connections) - 1
+	default:
+		index = (index + direction + len(connections)) % len(connections)
+	}
+	v.selectedID = connections[index].ID
+	v.MarkDirty()
+	v.Update(v.lastSnapshot)
+	return nil
+}
+
+// visibleConnections returns the active connections shown in the list, with the ones the
+// client cancelled recently, newest first
+func (v *ConnectionsView) visibleConnections(snapshot *Snapshot) []*monitor.ConnectionInfo {
+	metrics := snapshot.Metrics
 	connections := make([]*monitor.ConnectionInfo, 0, len(metrics.ActiveConnections))
 	for _, conn := range metrics.ActiveConnections {
 		connections = append(connections, conn)
 	}
-	
+	connections = append(connections, snapshot.Cancelled...)
+
 	// Sort connections by start time (newest first) for stable ordering
 	sort.Slice(connections, func(i, j int) bool {
 		return connections[i].StartTime.After(connections[j].StartTime)
 	})
+	if len(connections) > 15 {
+		connections = connections[:15]
+	}
+	return connections
+}
+
+// findConnection looks a connection up among the active ones of the snapshot, then among the
+// finished ones kept by the monitoring middleware
+func (v *ConnectionsView) findConnection(snapshot *Snapshot, connID string) (*monitor.ConnectionInfo, bool) {
+	if conn, ok := snapshot.Metrics.ActiveConnections[connID]; ok {
+		return conn, true
+	}
+	if connID == "" {
+		return nil, false
+	}
+	return v.monitoringMiddleware.GetMetrics().GetConnection(connID)
+}
+
+// Update re-renders the connections list when the snapshot differs from the last render
+func (v *ConnectionsView) Update(snapshot *Snapshot) {
+	metrics := snapshot.Metrics
+	v.lastSnapshot = snapshot
+
+	state := connectionsViewState{
+		counters:           countersOf(metrics),
+		activeConnections:  len(metrics.ActiveConnections),
+		historyConnections: metrics.HistoricalConnections,
+		selectedID:         v.selectedID,
+	}
+	for _, conn := range metrics.ActiveConnections {
+		state.retries += conn.RetryCount
+	}
+	selected, found := v.findConnection(snapshot, v.selectedID)
+	if found {
+		state.selectedAttempts = len(selected.Attempts)
+	}
+	if state.activeConnections > 0 {
+		state.second = snapshot.CollectedAt.Unix()
+	}
+	state.cancelledShown = len(snapshot.Cancelled)
+	if !v.dirty.Swap(false) && v.rendered && state == v.lastState {
+		return
+	}
+	v.rendered = true
+	v.lastState = state
+	
+	// Build display text
+	var stats strings.Builder
+	stats.WriteString(fmt.Sprintf("[blue::b]📊 Connection Statistics[white::-]\n"))
+	stats.WriteString(fmt.Sprintf("Active: [cyan]%3d[white] | Historical: [cyan]%4d[white] | Cancelled by client: [gray]%4d[white] | Coalesced: [gray]%4d[white]\n\n", 
+		len(metrics.ActiveConnections), metrics.HistoricalConnections, metrics.CancelledRequests, metrics.CoalescedRequests))
+	
+	stats.WriteString("[blue::b]🔗 Active Connections[white::-] [gray](↑/↓ select, Esc clear)[white]\n")
 	
 	// Always show exactly 15 lines to maintain consistent height
 	connCount := 0
-	for _, conn := range connections {
-		if connCount >= 15 {
-			break
+	for _, conn := range v.visibleConnections(snapshot) {
+		duration := snapshot.CollectedAt.Sub(conn.StartTime)
+		if conn.Status == "cancelled" {
+			duration = conn.LastActivity.Sub(conn.StartTime)
 		}
-		duration := time.Since(conn.StartTime)
 		
 		// Display endpoint name and find its group
 		endpointDisplay := conn.Endpoint
@@ -876,8 +1290,28 @@ func (v *ConnectionsView) Update() {
 			maxAttempts := v.config.Retry.MaxAttempts
 			retryDisplay = fmt.Sprintf(" (%d/%d retry)", conn.RetryCount, maxAttempts)
 		}
+		if reasons := monitor.RetryReasons(conn.Attempts); reasons != "" {
+			retryDisplay += " [red]" + reasons + "[white]"
+		}
+		if conn.Pinned {
+			retryDisplay += " [blue]pinned: yes[white]"
+		}
+		if conn.CoalescedWith != "" {
+			retryDisplay += " [blue]🔗 coalesced[white]"
+		}
+		if conn.Status == "websocket" {
+			retryDisplay += " [green]websocket[white]"
+		}
+		if conn.Status == "cancelled" {
+			retryDisplay += " [gray]🚫 cancelled[white]"
+		}
 		
-		stats.WriteString(fmt.Sprintf("  [cyan]%-12s[white] %-6s %-18s -> [yellow]%s[white]/[magenta]%s[white]%s [gray](%8s)[white]\n",
+		marker := "  "
+		if conn.ID == v.selectedID {
+			marker = "[yellow]▶[white] "
+		}
+		stats.WriteString(fmt.Sprintf("%s[cyan]%-12s[white] %-6s %-18s -> [yellow]%s[white]/[magenta]%s[white]%s [gray](%8s)[white]\n",
+			marker,
 			truncateString(conn.ClientIP, 12),
 			conn.Method,
 			truncateString(conn.Path, 18),
@@ -898,47 +1332,98 @@ func (v *ConnectionsView) Update() {
 		connCount++
 	}
 	
-	// Only update if content has changed
-	newContent := stats.String()
-	if newContent != v.lastDisplayHash {
-		v.lastDisplayHash = newContent
-		v.statsBox.SetText(newContent)
-	}
+	v.statsBox.SetText(stats.String())
+	v.attemptsBox.SetText(renderAttempts(selected, v.selectedID, monitor.MaxConnectionAttempts))
 }
 
-// LogEntry represents a log entry
-type LogEntry struct {
-	Timestamp time.Time
-	Level     string
-	Message   string
-	Source    string
+// renderAttempts renders the upstream attempt timeline of the selected connection, nil when
+// it is no longer tracked
+func renderAttempts(conn *monitor.ConnectionInfo, connID string, attemptsKept int) string {
+	if connID == "" {
+		return "[gray]Select a connection to see its upstream attempts[white]"
+	}
+	if conn == nil {
+		return "[gray]The selected connection is no longer tracked[white]"
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("[white::b]%s %s[white::-] [gray](%s, last %d attempts kept)[white]\n",
+		conn.Method, truncateString(conn.Path, 30), conn.Status, attemptsKept))
+	if len(conn.Attempts) == 0 {
+		text.WriteString("[gray]No upstream attempts yet[white]\n")
+		return text.String()
+	}
+	for i, attempt := range conn.Attempts {
+		color := "red"
+		if attempt.Outcome == "success" {
+			color = "green"
+		}
+		outcome := attempt.Outcome
+		if attempt.StatusCode != 0 {
+			outcome = fmt.Sprintf("%s %d", attempt.Outcome, attempt.StatusCode)
+		}
+		streaming := ""
+		if attempt.Streaming {
+			streaming = " [blue]stream[white]"
+		}
+		if attempt.Backoff > 0 {
+			streaming += fmt.Sprintf(" [gray]backoff %s[white]", formatDurationShort(attempt.Backoff))
+		}
+		text.WriteString(fmt.Sprintf("  #%d %s [yellow]%-12s[white] [%s]%-18s[white] [gray](%8s)[white]%s\n",
+			i+1,
+			attempt.StartTime.Format("15:04:05.000"),
+			truncateString(attempt.Endpoint, 12),
+			color,
+			outcome,
+			formatDurationShort(attempt.EndTime.Sub(attempt.StartTime)),
+			streaming))
+	}
+	return text.String()
 }
 
+// logLevelRanks orders the levels for the logs view's minimum level filter; other levels
+// (DEBUG) rank lowest and are only shown when all levels are
+var logLevelRanks = map[string]int{"INFO": 1, "WARN": 2, "ERROR": 3}
+
 // LogsView represents the logs tab
 type LogsView struct {
 	container       *tview.Flex
 	logText         *tview.TextView
-	logs            []LogEntry
+	footer          *tview.TextView
+	buffer          *logging.Buffer // Shared log buffer the view renders from
 	mutex           sync.RWMutex
-	maxLogs         int
 	lastDisplayHash string // Track content changes to avoid unnecessary updates
-	needsUpdate     bool   // Flag to indicate if logs have changed since last display
+	needsUpdate     bool   // Flag to force a refresh regardless of new entries
+	renderedTotal   int64  // buffer.Total() at the last refresh
+
+	// Filtering, search and pause state (only changed from the UI goroutine)
+	minLevel     string // Lowest level shown, set with e/w/i ("" shows all levels)
+	searchQuery  string // Active search: only entries matching it are shown, case-insensitively
+	searching    bool   // Whether "/" search input is being typed
+	matchCount   int    // Number of matches in the rendered text
+	currentMatch int    // Index of the highlighted match
+	paused       bool   // Freeze the display while new entries accumulate
+	pausedAt     int64  // buffer.Total() when the view was paused
 }
 
 func NewLogsView() *LogsView {
 	view := &LogsView{
-		logs:    make([]LogEntry, 0),
-		maxLogs: 500,
+		buffer: logging.NewBuffer(logging.DefaultBufferSize),
 	}
 	view.setupUI()
 	return view
 }
 
 func (v *LogsView) setupUI() {
-	v.logText = tview.NewTextView().SetDynamicColors(false).SetScrollable(true).SetWrap(true)
+	v.logText = tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetScrollable(true).SetWrap(true)
 	v.logText.SetBorder(true).SetTitle(" System Logs ").SetTitleAlign(tview.AlignLeft)
-	
-	v.container = tview.NewFlex().AddItem(v.logText, 0, 1, true)
+
+	v.footer = tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+
+	v.container = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(v.logText, 0, 1, true).
+		AddItem(v.footer, 1, 0, false)
+	v.updateFooter()
 }
 
 func (v *LogsView) GetPrimitive() tview.Primitive {
@@ -956,72 +1441,200 @@ func (v *LogsView) ForceUpdate() {
 	v.refreshLogDisplay()
 }
 
-func (v *LogsView) AddLog(level, message, source string) {
+// SetBuffer makes the view render the shared log buffer, including entries
+// logged before the TUI started
+func (v *LogsView) SetBuffer(buffer *logging.Buffer) {
 	v.mutex.Lock()
-	defer v.mutex.Unlock()
-	
-	entry := LogEntry{
-		Timestamp: time.Now(),
-		Level:     level,
-		Message:   message,
-		Source:    source,
+	v.buffer = buffer
+	v.needsUpdate = true
+	v.mutex.Unlock()
+}
+
+// AddLog adds an entry to the log buffer; the view picks it up on its next refresh
+func (v *LogsView) AddLog(level, message, source string) {
+	v.mutex.RLock()
+	buffer := v.buffer
+	v.mutex.RUnlock()
+	buffer.AddLog(level, message, source)
+}
+
+// HandleKey processes Logs tab key bindings and returns nil when the key was consumed
+func (v *LogsView) HandleKey(event *tcell.EventKey) *tcell.EventKey {
+	if v.searching {
+		return v.handleSearchKey(event)
 	}
-	
-	v.logs = append(v.logs, entry)
-	if len(v.logs) > v.maxLogs {
-		v.logs = v.logs[len(v.logs)-v.maxLogs:]
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		if v.searchQuery == "" {
+			return event
+		}
+		v.searchQuery = ""
+		v.render(false)
+		return nil
+	case tcell.KeyRune:
+	default:
+		return event
 	}
-	v.needsUpdate = true
+
+	switch event.Rune() {
+	case 'e', 'E':
+		v.setMinLevel("ERROR")
+	case 'w', 'W':
+		v.setMinLevel("WARN")
+	case 'i', 'I':
+		v.setMinLevel("")
+	case '/':
+		v.searching = true
+		v.searchQuery = ""
+		v.render(false)
+	case 'n':
+		v.jumpToMatch(1)
+	case 'N':
+		v.jumpToMatch(-1)
+	case ' ':
+		v.togglePause()
+	default:
+		return event
+	}
+	return nil
+}
+
+// handleSearchKey handles input while the "/" search prompt is active
+func (v *LogsView) handleSearchKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyEnter:
+		v.searching = false
+		v.updateFooter()
+		return nil
+	case tcell.KeyEscape:
+		v.searching = false
+		v.searchQuery = ""
+		v.render(false)
+		return nil
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if runes := []rune(v.searchQuery); len(runes) > 0 {
+			v.searchQuery = string(runes[:len(runes)-1])
+		}
+	case tcell.KeyRune:
+		v.searchQuery += string(event.Rune())
+	case tcell.KeyCtrlC:
+		return event
+	default:
+		return nil
+	}
+
+	// Incremental search: re-render and jump to the first match as the query changes
+	v.currentMatch = 0
+	v.render(true)
+	return nil
+}
+
+// setMinLevel shows only entries of the given level and above ("" shows all levels)
+func (v *LogsView) setMinLevel(level string) {
+	v.minLevel = level
+	v.render(false)
+}
+
+// shownLevel reports whether entries of a level pass the minimum level filter
+func (v *LogsView) shownLevel(level string) bool {
+	return v.minLevel == "" || logLevelRanks[level] >= logLevelRanks[v.minLevel]
 }
 
-func (v *LogsView) AddLogSilent(level, message, source string) {
+// togglePause freezes or resumes the display; entries keep accumulating while paused
+func (v *LogsView) togglePause() {
 	v.mutex.Lock()
-	defer v.mutex.Unlock()
-	
-	entry := LogEntry{
-		Timestamp: time.Now(),
-		Level:     level,
-		Message:   message,
-		Source:    source,
+	v.paused = !v.paused
+	v.pausedAt = v.buffer.Total()
+	paused := v.paused
+	v.mutex.Unlock()
+
+	if paused {
+		// Stop tracking the end so the current position stays put
+		row, _ := v.logText.GetScrollOffset()
+		v.logText.ScrollTo(row, 0)
+		v.updateFooter()
+		return
 	}
-	
-	v.logs = append(v.logs, entry)
-	if len(v.logs) > v.maxLogs {
-		v.logs = v.logs[len(v.logs)-v.maxLogs:]
+	v.render(false)
+}
+
+// jumpToMatch moves the highlight to the next (1) or previous (-1) search match
+func (v *LogsView) jumpToMatch(direction int) {
+	if v.searchQuery == "" || v.matchCount == 0 {
+		return
 	}
-	// Don't set needsUpdate=true to avoid triggering UI refresh
+	v.currentMatch = (v.currentMatch + direction + v.matchCount) % v.matchCount
+	v.logText.Highlight(fmt.Sprintf("match-%d", v.currentMatch)).ScrollToHighlight()
+	v.updateFooter()
 }
 
 func (v *LogsView) refreshLogDisplay() {
-	v.mutex.RLock()
-	needsUpdate := v.needsUpdate
-	v.mutex.RUnlock()
-	
+	v.mutex.Lock()
+	total := v.buffer.Total()
+	needsUpdate := v.needsUpdate || total != v.renderedTotal
+	paused := v.paused
+	v.needsUpdate = false
+	v.renderedTotal = total
+	v.mutex.Unlock()
+
 	// Only update if there are new logs
 	if !needsUpdate {
 		return
 	}
-	
+
+	// Keep the frozen text while paused, only the new-entry counter changes
+	if paused {
+		v.updateFooter()
+		return
+	}
+	v.render(false)
+}
+
+// render rebuilds the log text from the full buffer, applying level filters and
+// search highlighting. jumpToMatch scrolls to the current match instead of the end.
+func (v *LogsView) render(jumpToMatch bool) {
 	v.mutex.Lock()
-	defer v.mutex.Unlock()
-	
-	v.needsUpdate = false
-	
+	logs := v.buffer.GetLogs()
+	paused := v.paused
+	if paused {
+		// A filter change while paused shows the entries that came in since
+		v.pausedAt = v.buffer.Total()
+	}
+	v.mutex.Unlock()
+
+	var matcher *regexp.Regexp
+	if v.searchQuery != "" {
+		matcher = regexp.MustCompile("(?i)" + regexp.QuoteMeta(v.searchQuery))
+	}
+
 	// Build display text
 	var displayText strings.Builder
+	hidden := 0
+	matches := 0
 	
-	start := 0
-	if len(v.logs) > 50 {
-		start = len(v.logs) - 50
-	}
-	
-	for i := start; i < len(v.logs); i++ {
-		entry := v.logs[i]
-		timeStr := entry.Timestamp.Format("15:04:05")
+	for _, entry := range logs {
+		level := strings.ToUpper(entry.Level)
+		if !v.shownLevel(level) {
+			hidden++
+			continue
+		}
+
+		// Search matches the source and message, not the time or level tag
+		text := fmt.Sprintf("%s: %s", entry.Source, entry.Message)
+		var locs [][]int
+		if matcher != nil {
+			if locs = matcher.FindAllStringIndex(text, -1); locs == nil {
+				hidden++
+				continue
+			}
+		}
+
+		timeStr := entry.At.Format("15:04:05")
 		
 		// Simplified log display without emojis and complex formatting
 		var levelStr string
-		switch strings.ToUpper(entry.Level) {
+		switch level {
 		case "ERROR":
 			levelStr = "[ERR]"
 		case "WARN":
@@ -1031,9 +1644,23 @@ func (v *LogsView) refreshLogDisplay() {
 		default:
 			levelStr = "[LOG]"
 		}
-		
-		displayText.WriteString(fmt.Sprintf("%s %s %s: %s\n",
-			timeStr, levelStr, entry.Source, entry.Message))
+
+		displayText.WriteString(tview.Escape(fmt.Sprintf("%s %s ", timeStr, levelStr)))
+		// Wrap each match in its own region so n/N can highlight and scroll to it
+		last := 0
+		for _, loc := range locs {
+			displayText.WriteString(tview.Escape(text[last:loc[0]]))
+			displayText.WriteString(fmt.Sprintf(`["match-%d"][yellow]%s[-][""]`, matches, tview.Escape(text[loc[0]:loc[1]])))
+			last = loc[1]
+			matches++
+		}
+		displayText.WriteString(tview.Escape(text[last:]))
+		displayText.WriteString("\n")
+	}
+
+	v.matchCount = matches
+	if v.currentMatch >= matches {
+		v.currentMatch = 0
 	}
 	
 	// Only update if content has changed
@@ -1041,21 +1668,84 @@ func (v *LogsView) refreshLogDisplay() {
 	if newContent != v.lastDisplayHash {
 		v.lastDisplayHash = newContent
 		v.logText.SetText(newContent)
+	}
+
+	switch {
+	case matches > 0:
+		v.logText.Highlight(fmt.Sprintf("match-%d", v.currentMatch))
+		if jumpToMatch {
+			v.logText.ScrollToHighlight()
+		}
+	case !paused:
+		v.logText.Highlight()
 		// Scroll to end after setting new text
 		v.logText.ScrollToEnd()
+	default:
+		v.logText.Highlight()
+	}
+
+	v.updateTitle(hidden, len(logs))
+	v.updateFooter()
+}
+
+// updateTitle shows the active level filter and search and how many entries they hide
+func (v *LogsView) updateTitle(hidden, total int) {
+	title := " System Logs "
+	if v.minLevel != "" {
+		title += fmt.Sprintf("| Level: %s+ ", v.minLevel)
+	}
+	if v.searchQuery != "" {
+		title += fmt.Sprintf("| Search: %s ", tview.Escape(v.searchQuery))
+	}
+	if hidden > 0 {
+		title += fmt.Sprintf("| Hidden: %d/%d ", hidden, total)
+	}
+	v.logText.SetTitle(title)
+}
+
+// updateFooter shows the key bindings, search prompt/progress and pause state
+func (v *LogsView) updateFooter() {
+	var footer strings.Builder
+
+	v.mutex.RLock()
+	paused := v.paused
+	pending := v.buffer.Total() - v.pausedAt
+	v.mutex.RUnlock()
+
+	pauseKey := "Space: Pause"
+	if paused {
+		footer.WriteString(fmt.Sprintf("[black:yellow] Paused, %d new [-:-] ", pending))
+		pauseKey = "Space: Resume"
+	}
+
+	switch {
+	case v.searching:
+		footer.WriteString(fmt.Sprintf("[yellow]/%s_[white]  Enter: Confirm  Esc: Cancel", tview.Escape(v.searchQuery)))
+	case v.searchQuery != "":
+		current := 0
+		if v.matchCount > 0 {
+			current = v.currentMatch + 1
+		}
+		footer.WriteString(fmt.Sprintf("[yellow]/%s[white] (%d/%d)  [gray]n/N: Next/Prev  Esc: Clear search  e/w/i: Errors/Warn+/All  %s[white]",
+			tview.Escape(v.searchQuery), current, v.matchCount, pauseKey))
+	default:
+		footer.WriteString(fmt.Sprintf("[gray]e/w/i: Errors/Warn+/All  /: Search  n/N: Next/Prev match  %s  ↑/↓ PgUp/PgDn: Scroll[white]", pauseKey))
 	}
+
+	v.footer.SetText(footer.String())
 }
 
 
 // ConfigView represents the config tab
 type ConfigView struct {
-	container  *tview.Flex
-	configText *tview.TextView
-	cfg        *config.Config
+	container       *tview.Flex
+	configText      *tview.TextView
+	cfg             *config.Config
+	endpointManager *endpoint.Manager // Source of the runtime priority overrides
 }
 
-func NewConfigView(cfg *config.Config) *ConfigView {
-	view := &ConfigView{cfg: cfg}
+func NewConfigView(cfg *config.Config, endpointManager *endpoint.Manager) *ConfigView {
+	view := &ConfigView{cfg: cfg, endpointManager: endpointManager}
 	view.setupUI()
 	return view
 }
@@ -1075,7 +1765,10 @@ func (v *ConfigView) Update() {
 	var details strings.Builder
 	
 	details.WriteString("[blue::b]🌐 Server[white::-]\n")
-	details.WriteString(fmt.Sprintf("Host: [cyan]%s[white] | Port: [cyan]%d[white]\n\n", v.cfg.Server.Host, v.cfg.Server.Port))
+	for _, listener := range v.cfg.Server.GetListeners() {
+		details.WriteString(fmt.Sprintf("Listener: [cyan]%s[white]\n", listener.URL()))
+	}
+	details.WriteString(fmt.Sprintf("Require All Listeners: [yellow]%t[white]\n\n", v.cfg.Server.RequireAllListeners))
 	
 	details.WriteString("[blue::b]🎯 Strategy[white::-]\n")
 	details.WriteString(fmt.Sprintf("Type: [yellow]%s[white] | Fast Test: [yellow]%t[white]\n\n", 
@@ -1084,6 +1777,9 @@ func (v *ConfigView) Update() {
 	details.WriteString("[blue::b]🔐 Authentication[white::-]\n")
 	if v.cfg.Auth.Enabled {
 		details.WriteString("Status: [green]Enabled[white]\n")
+		if len(v.cfg.Auth.Tokens) > 0 {
+			details.WriteString(fmt.Sprintf("Named Clients: [cyan]%d[white]\n", len(v.cfg.Auth.Tokens)))
+		}
 	} else {
 		details.WriteString("Status: [red]Disabled[white]\n")
 	}
@@ -1092,15 +1788,20 @@ func (v *ConfigView) Update() {
 	details.WriteString("[blue::b]🖥️ TUI Settings[white::-]\n")
 	details.WriteString(fmt.Sprintf("Update Interval: [cyan]%v[white]\n", v.cfg.TUI.UpdateInterval))
 	
+	stateFile := v.endpointManager.GetStateFilePath()
 	saveStatus := "[red]Disabled[white]"
 	saveHint := "Changes are applied to memory only"
-	if v.cfg.TUI.SavePriorityEdits {
+	if stateFile != "" {
+		saveHint = fmt.Sprintf("Priority edits are kept in %s, the config file is not changed", stateFile)
+	}
+	if v.cfg.TUI.SaveEditsEnabled() {
 		saveStatus = "[green]Enabled[white]"
-		saveHint = "Priority edits are saved to config file"
+		saveHint = "Endpoint edits are saved to config file"
 	}
-	details.WriteString(fmt.Sprintf("Save Priority Edits: %s\n", saveStatus))
+	details.WriteString(fmt.Sprintf("Save Edits: %s\n", saveStatus))
 	details.WriteString(fmt.Sprintf("[gray]%s[white]\n\n", saveHint))
 	
+	endpointOverrides, groupOverrides := v.endpointManager.PriorityOverrides()
 	details.WriteString("[blue::b]🎯 Endpoints[white::-]\n")
 	details.WriteString(fmt.Sprintf("Total: [cyan]%d[white]\n", len(v.cfg.Endpoints)))
 	for i, ep := range v.cfg.Endpoints {
@@ -1108,13 +1809,44 @@ func (v *ConfigView) Update() {
 			details.WriteString("[gray]... and more[white]\n")
 			break
 		}
-		details.WriteString(fmt.Sprintf("  • [cyan]%s[white] ([yellow]%s[white]) P:%d\n",
-			ep.Name, truncateString(ep.URL, 25), ep.Priority))
+		priority := fmt.Sprintf("P:%d", ep.Priority)
+		if override, ok := endpointOverrides[ep.Name]; ok {
+			priority = fmt.Sprintf("P:%d [yellow]⚡ override, config: %d[white]", override.Priority, override.Config)
+		}
+		details.WriteString(fmt.Sprintf("  • [cyan]%s[white] ([yellow]%s[white]) %s\n",
+			ep.Name, truncateString(ep.DisplayURL(), 25), priority))
+	}
+	groups := make([]string, 0, len(groupOverrides))
+	for group := range groupOverrides {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		override := groupOverrides[group]
+		details.WriteString(fmt.Sprintf("  • Group [cyan]%s[white] GP:%d [yellow]⚡ override, config: %d[white]\n",
+			group, override.Priority, override.Config))
+	}
+	if count := len(endpointOverrides) + len(groupOverrides); count > 0 {
+		details.WriteString(fmt.Sprintf("[gray]%d runtime priority override(s) from %s; press o in the Endpoints tab to clear them[white]\n",
+			count, stateFile))
 	}
 	
 	v.configText.SetText(details.String())
 }
 
+// breakerDetail renders the circuit breaker line of the endpoint details
+func breakerDetail(ep *endpoint.Endpoint, breaker config.CircuitBreakerConfig) string {
+	state, remaining, failures := ep.BreakerInfo()
+	switch state {
+	case endpoint.BreakerOpen:
+		return fmt.Sprintf("🔌 Circuit: [red]open[white] (half-open in %s)\n", remaining.Round(time.Second))
+	case endpoint.BreakerHalfOpen:
+		return fmt.Sprintf("🔌 Circuit: [yellow]half-open[white] (probing, %d at a time)\n", breaker.HalfOpenMaxRequests)
+	default:
+		return fmt.Sprintf("🔌 Circuit: [green]closed[white] (%d/%d fails)\n", failures, breaker.FailureThreshold)
+	}
+}
+
 // Helper functions
 func formatDurationShort(d time.Duration) string {
 	if d == 0 {
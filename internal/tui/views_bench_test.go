@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/middleware"
+)
+
+func newBenchEnv(b *testing.B) (*config.Config, *endpoint.Manager, *middleware.MonitoringMiddleware) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 3},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, MaxRetries: 3},
+		TUI:      config.TUIConfig{UpdateInterval: time.Second},
+	}
+	for i := 0; i < 24; i++ {
+		cfg.Endpoints = append(cfg.Endpoints, config.EndpointConfig{
+			Name: fmt.Sprintf("ep-%02d", i), URL: fmt.Sprintf("https://ep%d.example.com", i),
+			Group: fmt.Sprintf("group-%d", i/6), GroupPriority: i/6 + 1, Priority: i%6 + 1, Timeout: time.Second,
+		})
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(manager)
+	metrics := mm.GetMetrics()
+	for i := 0; i < 200; i++ {
+		connID := metrics.RecordRequest("unknown", fmt.Sprintf("client-%d", i%5), "127.0.0.1", "bench", "POST", "/v1/messages")
+		if i%20 != 0 {
+			metrics.RecordResponse(connID, 200, 50*time.Millisecond, 1024, cfg.Endpoints[i%24].Name)
+		}
+	}
+	return cfg, manager, mm
+}
+
+// The Unchanged benchmarks measure a refresh tick when no request or health data changed,
+// which is the common case the dirty flags are meant to make cheap.
+
+func BenchmarkOverviewUpdateUnchanged(b *testing.B) {
+	_, manager, mm := newBenchEnv(b)
+	view := NewOverviewView(mm, manager, time.Now())
+	snapshot := newCollector(mm, manager).Collect()
+	view.Update(snapshot)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		view.Update(snapshot)
+	}
+}
+
+func BenchmarkEndpointsUpdateUnchanged(b *testing.B) {
+	_, manager, mm := newBenchEnv(b)
+	view := NewEndpointsView(mm, manager)
+	snapshot := newCollector(mm, manager).Collect()
+	view.Update(snapshot)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		view.Update(snapshot)
+	}
+}
+
+func BenchmarkEndpointsUpdateChanged(b *testing.B) {
+	_, manager, mm := newBenchEnv(b)
+	view := NewEndpointsView(mm, manager)
+	snapshot := newCollector(mm, manager).Collect()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		view.MarkDirty()
+		view.Update(snapshot)
+	}
+}
+
+func BenchmarkConnectionsUpdateUnchanged(b *testing.B) {
+	cfg, manager, mm := newBenchEnv(b)
+	view := NewConnectionsView(mm, manager, cfg)
+	snapshot := newCollector(mm, manager).Collect()
+	view.Update(snapshot)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		view.Update(snapshot)
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	_, manager, mm := newBenchEnv(b)
+	c := newCollector(mm, manager)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Collect()
+	}
+}
@@ -0,0 +1,67 @@
+package webui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+)
+
+// assetFiles holds the WebUI page, stylesheet and script
+//
+//go:embed assets
+var assetFiles embed.FS
+
+// staticAsset is an embedded file ready to be served, with an ETag derived from its content
+type staticAsset struct {
+	name        string
+	contentType string
+	body        []byte
+	etag        string
+}
+
+// staticAssets maps request paths to the embedded files: "/" is the page, everything else is
+// under /static/. The hashes are computed once at startup.
+var staticAssets = loadStaticAssets()
+
+func loadStaticAssets() map[string]*staticAsset {
+	assets := make(map[string]*staticAsset)
+	entries, err := fs.ReadDir(assetFiles, "assets")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		body, err := assetFiles.ReadFile(path.Join("assets", name))
+		if err != nil {
+			panic(err)
+		}
+		sum := sha256.Sum256(body)
+		asset := &staticAsset{
+			name:        name,
+			contentType: mime.TypeByExtension(path.Ext(name)),
+			body:        body,
+			etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		}
+		if name == "index.html" {
+			assets["/"] = asset
+		} else {
+			assets["/static/"+name] = asset
+		}
+	}
+	return assets
+}
+
+// serve writes the asset, or 304 Not Modified when the client's copy has the same ETag.
+// Browsers revalidate on every load, so a new build is picked up without a stale cache.
+func (a *staticAsset) serve(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", a.contentType)
+	rw.Header().Set("Cache-Control", "private, no-cache")
+	rw.Header().Set("ETag", a.etag)
+	http.ServeContent(rw, r, a.name, time.Time{}, bytes.NewReader(a.body))
+}
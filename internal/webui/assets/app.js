@@ -0,0 +1,2332 @@
+class WebUIApp {
+    constructor() {
+        this.currentTab = 'overview';
+        this.selectedEndpoint = null;
+        this.eventSource = null;
+        this.logEventSource = null;
+
+        // Edit mode state
+        this.editMode = false;
+        this.originalPriorities = {};
+        this.currentPriorities = {};
+        this.originalGroupPriorities = {};
+        this.currentGroupPriorities = {};
+        this.hasUnsavedChanges = false;
+        this.editingConfigName = null; // for config editor
+
+        // Log search state (live log updates are paused while showing results)
+        this.logSearchActive = false;
+
+        this.init();
+    }
+
+    init() {
+        this.setupTabs();
+        this.setupEventSource();
+        this.setupLogStream();
+        this.setupEditMode();
+        this.setupResetControl();
+        this.setupNotifyTestControl();
+        this.setupThemeToggle();
+        this.loadAllData();
+
+        // Refresh data every 5 seconds as fallback
+        setInterval(() => this.loadAllData(), 5000);
+    }
+
+    // Adds the CSRF token issued at login; required on every state-changing API request
+    csrfHeaders(headers = {}) {
+        const match = document.cookie.match(/(?:^|;\s*)webui_csrf=([^;]+)/);
+        if (match) {
+            headers['X-CSRF-Token'] = decodeURIComponent(match[1]);
+        }
+        return headers;
+    }
+
+    setupResetControl() {
+        const btn = document.getElementById('reset-state-btn');
+        if (!btn) return;
+        btn.addEventListener('click', async () => {
+            btn.disabled = true;
+            const oldText = btn.textContent;
+            btn.textContent = '⏳';
+            try {
+                const resp = await fetch('/api/reset-state', { method: 'POST', headers: this.csrfHeaders() });
+                if (!resp.ok) throw new Error('请求失败');
+                const data = await resp.json();
+                console.log('Reset state:', data);
+                this.addLogToUI({ timestamp: new Date().toLocaleTimeString(), level: 'INFO', source: 'webui', message: '状态已重置，已触发健康检查' });
+                this.loadAllData();
+            } catch (e) {
+                console.error('重置状态失败', e);
+                alert('重置状态失败，请查看服务端日志');
+            } finally {
+                btn.disabled = false;
+                btn.textContent = oldText;
+            }
+        });
+    }
+
+    setupNotifyTestControl() {
+        const btn = document.getElementById('notify-test-btn');
+        if (!btn) return;
+        btn.addEventListener('click', async () => {
+            btn.disabled = true;
+            const oldText = btn.textContent;
+            btn.textContent = '⏳';
+            try {
+                const resp = await fetch('/api/notifications/test', { method: 'POST', headers: this.csrfHeaders() });
+                if (!resp.ok) throw new Error((await resp.text()).trim() || '请求失败');
+                alert('测试通知已发送到所有通知渠道');
+            } catch (e) {
+                console.error('发送测试通知失败', e);
+                alert('发送测试通知失败: ' + e.message);
+            } finally {
+                btn.disabled = false;
+                btn.textContent = oldText;
+            }
+        });
+    }
+
+    // Switches between the dark and light themes; the choice is kept in localStorage and
+    // applied by index.html before the page renders
+    setupThemeToggle() {
+        const btn = document.getElementById('theme-toggle-btn');
+        if (!btn) return;
+        btn.addEventListener('click', () => {
+            const theme = document.documentElement.dataset.theme === 'light' ? 'dark' : 'light';
+            document.documentElement.dataset.theme = theme;
+            try {
+                localStorage.setItem('webui-theme', theme);
+            } catch (e) {
+                console.warn('无法保存主题设置', e);
+            }
+        });
+    }
+
+    setupTabs() {
+        const tabButtons = document.querySelectorAll('.tab-button');
+        const tabContents = document.querySelectorAll('.tab-content');
+
+        tabButtons.forEach(button => {
+            button.addEventListener('click', () => {
+                const tabName = button.dataset.tab;
+
+                // Update active tab button
+                tabButtons.forEach(b => b.classList.remove('active'));
+                button.classList.add('active');
+
+                // Update active tab content
+                tabContents.forEach(content => content.classList.remove('active'));
+                document.getElementById(tabName).classList.add('active');
+
+                this.currentTab = tabName;
+                this.loadTabData(tabName);
+            });
+        });
+    }
+
+    setupEventSource() {
+        if (this.eventSource) {
+            this.eventSource.close();
+        }
+
+        this.eventSource = new EventSource('/api/events');
+
+        this.eventSource.onmessage = (event) => {
+            try {
+                const data = JSON.parse(event.data);
+                this.updateStatusBar(data);
+            } catch (e) {
+                console.error('Error parsing SSE data:', e);
+            }
+        };
+
+        this.eventSource.onerror = (error) => {
+            console.error('SSE connection error:', error);
+            // Reconnect after 5 seconds
+            setTimeout(() => this.setupEventSource(), 5000);
+        };
+    }
+
+    setupLogStream() {
+        if (this.logEventSource) {
+            this.logEventSource.close();
+        }
+
+        this.logEventSource = new EventSource('/api/log-stream');
+
+        this.logEventSource.onmessage = (event) => {
+            try {
+                const logEntry = JSON.parse(event.data);
+                this.addLogToUI(logEntry);
+            } catch (e) {
+                console.error('Error parsing log stream data:', e);
+            }
+        };
+
+        this.logEventSource.onerror = (error) => {
+            console.error('Log stream connection error:', error);
+            // Reconnect after 3 seconds
+            setTimeout(() => this.setupLogStream(), 3000);
+        };
+    }
+
+    setupEditMode() {
+        // Edit mode button
+        const editModeBtn = document.getElementById('edit-mode-btn');
+        const saveConfigBtn = document.getElementById('save-config-btn');
+        const cancelEditBtn = document.getElementById('cancel-edit-btn');
+
+        editModeBtn.addEventListener('click', () => this.enterEditMode());
+        saveConfigBtn.addEventListener('click', () => this.saveConfiguration());
+        cancelEditBtn.addEventListener('click', () => this.cancelEditMode());
+
+        // Keyboard shortcuts (similar to TUI)
+        document.addEventListener('keydown', (event) => {
+            this.handleGlobalKeyboard(event);
+        });
+    }
+
+    handleGlobalKeyboard(event) {
+        // Don't handle shortcuts if user is typing in an input field
+        if (event.target.tagName === 'INPUT' || event.target.tagName === 'TEXTAREA') {
+            // Only handle specific shortcuts in input fields
+            if (event.key === 'Escape') {
+                event.target.blur(); // Remove focus from input
+                if (this.editMode) {
+                    event.preventDefault();
+                    this.cancelEditMode();
+                }
+            } else if (event.ctrlKey && event.key === 's' && this.editMode) {
+                event.preventDefault();
+                this.saveConfiguration();
+            }
+            return;
+        }
+
+        // Global tab switching shortcuts (similar to TUI)
+        if (event.key >= '1' && event.key <= '5') {
+            event.preventDefault();
+            const tabIndex = parseInt(event.key) - 1;
+            const tabs = ['overview', 'endpoints', 'connections', 'logs', 'config'];
+            if (tabs[tabIndex]) {
+                this.switchToTab(tabs[tabIndex]);
+            }
+        }
+
+        // Tab navigation with Tab/Shift+Tab
+        else if (event.key === 'Tab' && !event.ctrlKey && !event.altKey) {
+            event.preventDefault();
+            const tabs = ['overview', 'endpoints', 'connections', 'logs', 'config'];
+            const currentIndex = tabs.indexOf(this.currentTab);
+
+            if (event.shiftKey) {
+                // Previous tab
+                const prevIndex = currentIndex > 0 ? currentIndex - 1 : tabs.length - 1;
+                this.switchToTab(tabs[prevIndex]);
+            } else {
+                // Next tab
+                const nextIndex = currentIndex < tabs.length - 1 ? currentIndex + 1 : 0;
+                this.switchToTab(tabs[nextIndex]);
+            }
+        }
+
+        // Endpoints tab specific shortcuts
+        else if (this.currentTab === 'endpoints') {
+            if (event.key === 'Enter' && !this.editMode) {
+                event.preventDefault();
+                this.enterEditMode();
+            } else if (event.key === 'Escape' && this.editMode) {
+                event.preventDefault();
+                this.cancelEditMode();
+            } else if (event.ctrlKey && event.key === 's' && this.editMode) {
+                event.preventDefault();
+                this.saveConfiguration();
+            }
+            // Priority shortcuts in edit mode (1-9 keys)
+            else if (this.editMode && event.key >= '1' && event.key <= '9' && this.selectedEndpoint) {
+                event.preventDefault();
+                const priority = parseInt(event.key);
+                this.setPriorityForSelected(priority);
+            }
+        }
+
+        // Global shortcuts
+        else if (event.key === 'F5') {
+            event.preventDefault();
+            this.loadAllData();
+        }
+    }
+
+    switchToTab(tabName) {
+        // Find and click the corresponding tab button
+        const tabButton = document.querySelector('[data-tab="' + tabName + '"]');
+        if (tabButton) {
+            tabButton.click();
+        }
+    }
+
+    setPriorityForSelected(priority) {
+        if (!this.selectedEndpoint || !this.editMode) return;
+
+        const endpointName = this.selectedEndpoint.name;
+        const input = document.querySelector('input[data-endpoint="' + endpointName + '"]');
+
+        if (input) {
+            input.value = priority;
+            input.dispatchEvent(new Event('input')); // Trigger the change handler
+        }
+    }
+
+    enterEditMode() {
+        this.editMode = true;
+        this.hasUnsavedChanges = false;
+
+        // Store original priorities
+        this.originalPriorities = {};
+        this.currentPriorities = {};
+        this.originalGroupPriorities = {};
+        this.currentGroupPriorities = {};
+
+        document.querySelectorAll('#endpoints-table tbody tr.group-header').forEach(row => {
+            const groupName = row.dataset.group;
+            const priorityCell = row.querySelector('.group-priority-cell');
+            const priority = parseInt(priorityCell.textContent);
+            this.originalGroupPriorities[groupName] = priority;
+            this.currentGroupPriorities[groupName] = priority;
+
+            priorityCell.innerHTML = '<input type="number" class="priority-input group-priority-input" value="' + priority + '" min="1" max="999">';
+            const input = priorityCell.querySelector('.priority-input');
+            input.dataset.group = groupName;
+            input.addEventListener('click', (e) => e.stopPropagation());
+            input.addEventListener('input', (e) => this.onGroupPriorityChange(groupName, parseInt(e.target.value)));
+        });
+
+        const rows = document.querySelectorAll('#endpoints-table tbody tr');
+        rows.forEach(row => {
+            const nameCell = row.querySelector('td:nth-child(2)');
+            const priorityCell = row.querySelector('td:nth-child(4)');
+
+            if (nameCell && priorityCell) {
+                const endpointName = nameCell.textContent;
+                const priority = parseInt(priorityCell.textContent);
+                this.originalPriorities[endpointName] = priority;
+                this.currentPriorities[endpointName] = priority;
+
+                // Replace priority text with input
+                priorityCell.innerHTML = '<input type="number" class="priority-input" value="' + priority + '" min="0" max="999" data-endpoint="' + endpointName + '">';
+
+                // Add event listener for changes
+                const input = priorityCell.querySelector('.priority-input');
+                input.addEventListener('input', (e) => this.onPriorityChange(endpointName, parseInt(e.target.value)));
+            }
+        });
+
+        // Update UI
+        document.querySelector('#endpoints-table').classList.add('edit-mode');
+        this.updateEditModeUI();
+    }
+
+    onPriorityChange(endpointName, newPriority) {
+        this.currentPriorities[endpointName] = newPriority;
+        this.updateUnsavedChanges();
+    }
+
+    onGroupPriorityChange(groupName, newPriority) {
+        this.currentGroupPriorities[groupName] = newPriority;
+
+        // Two groups with the same priority are rejected by the server, flag them right away
+        const conflicts = this.groupPriorityConflicts();
+        document.querySelectorAll('.group-priority-input').forEach(input => {
+            input.classList.toggle('priority-conflict', conflicts.includes(input.dataset.group));
+        });
+
+        this.updateUnsavedChanges();
+    }
+
+    // groupPriorityConflicts returns the groups sharing their priority with another group
+    groupPriorityConflicts() {
+        const groups = Object.keys(this.currentGroupPriorities);
+        return groups.filter(name => groups.some(other =>
+            other !== name && this.currentGroupPriorities[other] === this.currentGroupPriorities[name]
+        ));
+    }
+
+    updateUnsavedChanges() {
+        // Check if there are unsaved changes
+        this.hasUnsavedChanges = Object.keys(this.originalPriorities).some(name =>
+            this.originalPriorities[name] !== this.currentPriorities[name]
+        ) || Object.keys(this.originalGroupPriorities).some(name =>
+            this.originalGroupPriorities[name] !== this.currentGroupPriorities[name]
+        );
+
+        this.updateEditModeUI();
+    }
+
+    updateEditModeUI() {
+        const title = document.getElementById('endpoints-title');
+        const editModeBtn = document.getElementById('edit-mode-btn');
+        const saveConfigBtn = document.getElementById('save-config-btn');
+        const cancelEditBtn = document.getElementById('cancel-edit-btn');
+
+        if (this.editMode) {
+            let titleText = '🎯 Endpoints [Edit Mode';
+            if (this.hasUnsavedChanges) {
+                titleText += ' *';
+            }
+            titleText += ']';
+            title.innerHTML = titleText + '<span class="edit-mode-indicator">ESC to Exit | Ctrl+S to Save</span>';
+
+            editModeBtn.style.display = 'none';
+            saveConfigBtn.style.display = 'inline-flex';
+            cancelEditBtn.style.display = 'inline-flex';
+
+            // Update save button state
+            if (this.hasUnsavedChanges) {
+                saveConfigBtn.classList.remove('btn-secondary');
+                saveConfigBtn.classList.add('btn-success');
+                saveConfigBtn.textContent = '💾 Save Changes';
+            } else {
+                saveConfigBtn.classList.remove('btn-success');
+                saveConfigBtn.classList.add('btn-secondary');
+                saveConfigBtn.textContent = '💾 No Changes';
+            }
+        } else {
+            title.textContent = '🎯 Endpoints';
+            editModeBtn.style.display = 'inline-flex';
+            saveConfigBtn.style.display = 'none';
+            cancelEditBtn.style.display = 'none';
+        }
+    }
+
+    async saveConfiguration() {
+        if (!this.hasUnsavedChanges) {
+            return;
+        }
+
+        const conflicts = this.groupPriorityConflicts();
+        if (conflicts.length > 0) {
+            this.showMessage('❌ Groups ' + conflicts.join(', ') + ' have the same priority', 'error');
+            return;
+        }
+
+        try {
+            // Changed group priorities are sent together so groups can swap priorities
+            const groupPriorities = {};
+            for (const groupName of Object.keys(this.currentGroupPriorities)) {
+                if (this.originalGroupPriorities[groupName] !== this.currentGroupPriorities[groupName]) {
+                    groupPriorities[groupName] = this.currentGroupPriorities[groupName];
+                }
+            }
+            if (Object.keys(groupPriorities).length > 0) {
+                const response = await fetch('/api/groups/priority', {
+                    method: 'POST',
+                    headers: this.csrfHeaders({
+                        'Content-Type': 'application/json',
+                    }),
+                    body: JSON.stringify({ priorities: groupPriorities })
+                });
+
+                if (!response.ok) {
+                    throw new Error('Failed to update group priorities: ' + (await response.text()).trim());
+                }
+            }
+
+            // Save each changed priority
+            for (const endpointName of Object.keys(this.currentPriorities)) {
+                if (this.originalPriorities[endpointName] !== this.currentPriorities[endpointName]) {
+                    const response = await fetch('/api/endpoints/priority', {
+                        method: 'POST',
+                        headers: this.csrfHeaders({
+                            'Content-Type': 'application/json',
+                        }),
+                        body: JSON.stringify({
+                            endpointName: endpointName,
+                            priority: this.currentPriorities[endpointName]
+                        })
+                    });
+
+                    if (!response.ok) {
+                        throw new Error('Failed to update priority for ' + endpointName);
+                    }
+                }
+            }
+
+            // Save configuration to file
+            const saveResponse = await fetch('/api/config/save', {
+                method: 'POST',
+                headers: this.csrfHeaders({
+                    'Content-Type': 'application/json',
+                }),
+                body: JSON.stringify({})
+            });
+
+            if (!saveResponse.ok) {
+                throw new Error('Failed to save configuration');
+            }
+
+            const saveResult = await saveResponse.json();
+
+            // Show success message
+            this.showMessage('✅ Configuration saved successfully' + (saveResult.savedToFile ? ' to file' : ' to memory'), 'success');
+
+            // Update original priorities to current ones
+            this.originalPriorities = { ...this.currentPriorities };
+            this.originalGroupPriorities = { ...this.currentGroupPriorities };
+            this.hasUnsavedChanges = false;
+
+            // Exit edit mode
+            this.exitEditMode();
+
+            // Reload endpoints to reflect changes
+            await this.loadEndpoints();
+
+        } catch (error) {
+            console.error('Error saving configuration:', error);
+            this.showMessage('❌ Failed to save configuration: ' + error.message, 'error');
+        }
+    }
+
+    cancelEditMode() {
+        // Restore original priorities
+        this.currentPriorities = { ...this.originalPriorities };
+        this.currentGroupPriorities = { ...this.originalGroupPriorities };
+        this.hasUnsavedChanges = false;
+
+        this.exitEditMode();
+    }
+
+    exitEditMode() {
+        this.editMode = false;
+
+        // Remove edit mode class
+        document.querySelector('#endpoints-table').classList.remove('edit-mode');
+
+        // Restore priority cells to text
+        document.querySelectorAll('#endpoints-table tbody tr.group-header').forEach(row => {
+            const priorityCell = row.querySelector('.group-priority-cell');
+            priorityCell.textContent = this.originalGroupPriorities[row.dataset.group] || 0;
+        });
+
+        const rows = document.querySelectorAll('#endpoints-table tbody tr');
+        rows.forEach(row => {
+            const nameCell = row.querySelector('td:nth-child(2)');
+            const priorityCell = row.querySelector('td:nth-child(4)');
+
+            if (nameCell && priorityCell) {
+                const endpointName = nameCell.textContent;
+                const priority = this.originalPriorities[endpointName] || 0;
+                priorityCell.textContent = priority;
+            }
+        });
+
+        this.updateEditModeUI();
+    }
+
+    showMessage(message, type = 'info') {
+        // Create a temporary message element
+        const messageDiv = document.createElement('div');
+        messageDiv.className = 'message-toast message-' + type;
+        messageDiv.textContent = message;
+
+        // Add to page
+        document.body.appendChild(messageDiv);
+
+        // Remove after 3 seconds
+        setTimeout(() => {
+            if (messageDiv.parentNode) {
+                messageDiv.parentNode.removeChild(messageDiv);
+            }
+        }, 3000);
+    }
+
+    updateStatusBar(data) {
+        document.getElementById('status-requests').textContent = 'Requests: ' + data.totalRequests;
+        document.getElementById('status-success').textContent = 'Success: ' + data.successRate.toFixed(1) + '%';
+        document.getElementById('status-connections').textContent = 'Connections: ' + data.activeConnections;
+        document.getElementById('last-update').textContent = 'Last Update: ' + new Date().toLocaleTimeString();
+    }
+
+    addLogToUI(logEntry) {
+        // Only update if we're on the logs tab and not showing search results
+        if (this.currentTab !== 'logs' || this.logSearchActive) {
+            return;
+        }
+
+        const logsContent = document.getElementById('logs-content');
+        if (!logsContent) {
+            return;
+        }
+
+        // Create new log entry element
+        const logDiv = document.createElement('div');
+        logDiv.className = 'log-entry';
+
+        const levelClass = logEntry.level.toLowerCase();
+        const levelText = logEntry.level.substring(0, 3);
+
+        logDiv.innerHTML = 
+            '<span class="log-time">' + logEntry.timestamp + '</span>' +
+            '<span class="log-level ' + levelClass + '">[' + levelText + ']</span>' +
+            '<span class="log-source">' + logEntry.source + '</span>' +
+            '<span class="log-message">' + logEntry.message + '</span>';
+
+        // Insert at the top (most recent first)
+        const firstChild = logsContent.firstChild;
+        if (firstChild) {
+            logsContent.insertBefore(logDiv, firstChild);
+        } else {
+            logsContent.appendChild(logDiv);
+        }
+
+        // Keep only latest 500 log entries in UI to prevent memory issues
+        const logEntries = logsContent.querySelectorAll('.log-entry');
+        if (logEntries.length > 500) {
+            for (let i = 500; i < logEntries.length; i++) {
+                logEntries[i].remove();
+            }
+        }
+
+        // Auto-scroll to top if user is already at the top
+        if (logsContent.scrollTop < 50) {
+            logsContent.scrollTop = 0;
+        }
+    }
+
+    async loadAllData() {
+        await this.loadTabData(this.currentTab);
+    }
+
+    async loadTabData(tabName) {
+        switch (tabName) {
+            case 'overview':
+                await this.loadOverview();
+                break;
+            case 'endpoints':
+                await this.loadEndpoints();
+                break;
+            case 'connections':
+                await this.loadConnections();
+                break;
+            case 'inspector':
+                await this.loadInspector();
+                break;
+            case 'logs':
+                await this.loadLogs();
+                break;
+            case 'config':
+                await this.loadConfig();
+                break;
+        }
+    }
+
+    async loadOverview() {
+        try {
+            const response = await fetch('/api/overview');
+            const data = await response.json();
+
+            document.getElementById('setup-banner').style.display = data.setupMode ? 'block' : 'none';
+
+            // Update metrics
+            document.getElementById('total-requests').textContent = data.metrics.totalRequests;
+            document.getElementById('successful-requests').textContent =
+                data.metrics.successfulRequests + ' (' + data.metrics.successRate.toFixed(1) + '%)';
+            document.getElementById('failed-requests').textContent =
+                data.metrics.failedRequests + ' (' + (100 - data.metrics.successRate).toFixed(1) + '%)';
+            document.getElementById('error-origins').textContent =
+                (data.metrics.localErrors || 0) + ' / ' + (data.metrics.upstreamErrors || 0);
+            document.getElementById('avg-response-time').textContent = data.metrics.averageResponseTime + 'ms';
+            if (data.retryBudget) {
+                const budget = data.retryBudget;
+                const budgetEl = document.getElementById('retry-budget');
+                budgetEl.textContent = budget.used + ' / ' + (budget.limit > 0 ? budget.limit : '∞');
+                budgetEl.className = 'value' + (budget.limit > 0 && budget.used >= budget.limit ? ' error' : '');
+                budgetEl.title = '重置倒计时: ' + budget.resetSeconds + 's';
+            }
+
+            // Update token usage
+            document.getElementById('input-tokens').textContent = data.tokens.inputTokens.toLocaleString();
+            document.getElementById('output-tokens').textContent = data.tokens.outputTokens.toLocaleString();
+            document.getElementById('cache-creation-tokens').textContent = data.tokens.cacheCreationTokens.toLocaleString();
+            document.getElementById('cache-read-tokens').textContent = data.tokens.cacheReadTokens.toLocaleString();
+            document.getElementById('total-tokens').textContent = data.tokens.totalTokens.toLocaleString();
+
+            // Update endpoints status
+            document.getElementById('endpoints-total').textContent = data.endpoints.total;
+            document.getElementById('endpoints-healthy').textContent = data.endpoints.healthy;
+
+            const endpointsList = document.getElementById('endpoints-list');
+            endpointsList.innerHTML = '';
+            data.endpoints.statuses.slice(0, 6).forEach(ep => {
+                const div = document.createElement('div');
+                div.className = 'metric';
+                div.innerHTML =
+                    '<span class="status-icon">' + (ep.disabled ? '⏸️' : (ep.healthy ? '🟢' : '🔴')) + '</span>' +
+                    '<span class="label">' + ep.name + '</span>' +
+                    '<span class="value">(' + ep.responseTime + 'ms)</span>';
+                endpointsList.appendChild(div);
+            });
+
+            // Update system info
+            document.getElementById('active-connections').textContent = data.system.activeConnections;
+            document.getElementById('total-connections').textContent = data.system.totalConnections;
+            document.getElementById('uptime').textContent = this.formatUptime(data.system.uptime);
+
+            // Update recent failover events
+            this.renderRecentEvents(data.recentEvents || []);
+
+            // Update token budget progress
+            this.renderBudgets(data.budgets || []);
+
+            // Load and update token history chart
+            await this.loadTokenHistoryChart();
+
+            // Load per-client statistics
+            await this.loadClients();
+
+            // Load per-group statistics
+            await this.loadGroupStats();
+
+        } catch (error) {
+            console.error('Error loading overview:', error);
+        }
+    }
+
+    renderBudgets(budgets) {
+        document.getElementById('budgets-card').style.display = budgets.length > 0 ? '' : 'none';
+        const budgetsList = document.getElementById('budgets-list');
+        budgetsList.innerHTML = '';
+
+        budgets.forEach(budget => {
+            const percent = budget.percent;
+            const level = percent >= 100 ? 'exceeded' : (percent >= 80 ? 'warning' : '');
+            const reset = budget.resetSeconds > 0 ? ' · ' + this.formatUptime(budget.resetSeconds) + ' 后重置' : '';
+            const div = document.createElement('div');
+            div.className = 'budget-item';
+            div.title = budget.used.toLocaleString() + ' / ' + budget.limit.toLocaleString() + ' 令牌 (窗口 ' + budget.window + ')' + reset;
+            div.innerHTML =
+                '<div style="display: flex; justify-content: space-between; align-items: center;">' +
+                '<span>' + (budget.blocking ? '⛔' : (level ? '⚠️' : '💰')) + ' <span style="color: var(--accent)">' +
+                this.escapeHtml(budget.subject) + '</span> <span style="color: var(--text-muted); font-size: 0.85rem">' +
+                this.escapeHtml(budget.window) + ' · ' + this.escapeHtml(budget.action) + '</span></span>' +
+                '<span style="font-weight: 600; color: ' + (level === 'exceeded' ? 'var(--danger)' : 'var(--accent)') + '">' + percent.toFixed(1) + '%</span>' +
+                '</div>' +
+                '<div class="budget-bar"><div class="budget-bar-fill ' + level + '" style="width: ' + Math.min(percent, 100) + '%"></div></div>';
+            budgetsList.appendChild(div);
+        });
+    }
+
+    renderRecentEvents(events) {
+        const eventsList = document.getElementById('events-list');
+        eventsList.innerHTML = '';
+
+        if (events.length === 0) {
+            const div = document.createElement('div');
+            div.className = 'history-item';
+            div.innerHTML = '<span class="history-placeholder">暂无事件...</span>';
+            eventsList.appendChild(div);
+            return;
+        }
+
+        const icons = {
+            endpoint_unhealthy: '🔴',
+            endpoint_healthy: '🟢',
+            group_cooldown_entered: '❄️',
+            group_cooldown_exited: '🔄',
+            all_endpoints_down: '🚨',
+            success_rate_low: '📉',
+            config_reload_failed: '⚠️',
+            config_switched: '🔀',
+            budget_warning: '💰',
+            budget_exceeded: '💸'
+        };
+        events.forEach(event => {
+            const div = document.createElement('div');
+            div.className = 'history-item';
+            div.title = event.message;
+            div.innerHTML =
+                '<div style="display: flex; justify-content: space-between; align-items: center;">' +
+                '<span>' + (icons[event.type] || '🔔') + ' ' + this.escapeHtml(event.type) +
+                (event.subject ? ' <span style="color: var(--accent)">' + this.escapeHtml(event.subject) + '</span>' : '') + '</span>' +
+                '<span style="font-size: 0.9rem; color: var(--text-muted)">🕒' + new Date(event.time).toLocaleTimeString() + '</span>' +
+                '</div>';
+            eventsList.appendChild(div);
+        });
+    }
+
+    async loadClients() {
+        try {
+            const response = await fetch('/api/clients?limit=10');
+            const data = await response.json();
+
+            const clientsList = document.getElementById('clients-list');
+            clientsList.innerHTML = '';
+
+            if (!data.clients || data.clients.length === 0) {
+                const div = document.createElement('div');
+                div.className = 'history-item';
+                div.innerHTML = '<span class="history-placeholder">暂无客户端记录...</span>';
+                clientsList.appendChild(div);
+                return;
+            }
+
+            data.clients.forEach(client => {
+                const div = document.createElement('div');
+                div.className = 'history-item';
+                div.innerHTML =
+                    '<div style="display: flex; justify-content: space-between; align-items: center;">' +
+                    '<div>' +
+                    '<span style="color: var(--accent)">' + this.escapeHtml(client.id) + '</span> ' +
+                    '<span style="font-size: 0.8rem; color: var(--text-muted)">(' + client.totalRequests + ' req, ' +
+                    '<span style="color: var(--success)">' + client.successfulRequests + '</span>/' +
+                    '<span style="color: var(--danger)">' + client.failedRequests + '</span>)</span>' +
+                    '</div>' +
+                    '<div style="font-size: 0.9rem; color: var(--text-muted)">' +
+                    '📥' + client.tokenUsage.inputTokens.toLocaleString() + ' 📤' + client.tokenUsage.outputTokens.toLocaleString() + ' ' +
+                    '🕒' + new Date(client.lastSeen).toLocaleTimeString() +
+                    '</div>' +
+                    '</div>';
+                clientsList.appendChild(div);
+            });
+        } catch (error) {
+            console.error('Error loading clients:', error);
+        }
+    }
+
+    async loadGroupStats() {
+        try {
+            const response = await fetch('/api/groups');
+            const data = await response.json();
+
+            const groupsList = document.getElementById('groups-list');
+            groupsList.innerHTML = '';
+
+            if (!data.groups || data.groups.length === 0) {
+                const div = document.createElement('div');
+                div.className = 'history-item';
+                div.innerHTML = '<span class="history-placeholder">暂无分组...</span>';
+                groupsList.appendChild(div);
+                return;
+            }
+
+            data.groups.forEach(group => {
+                const stats = group.stats;
+                let icon = group.active ? '🟢' : '⚪';
+                if (group.inCooldown) {
+                    icon = '❄️';
+                } else if (!group.configured) {
+                    icon = '🗄️';
+                }
+                const rateColor = stats.totalRequests === 0 ? 'var(--text-muted)' : (stats.successRate >= 90 ? 'var(--success)' : 'var(--danger)');
+                const div = document.createElement('div');
+                div.className = 'history-item';
+                div.title = group.configured ? '' : '已不在当前配置中（保留历史统计）';
+                div.innerHTML =
+                    '<div style="display: flex; justify-content: space-between; align-items: center;">' +
+                    '<div>' +
+                    icon + ' <span style="color: var(--accent)">' + this.escapeHtml(group.name) + '</span> ' +
+                    '<span style="font-size: 0.8rem; color: var(--text-muted)">(' + stats.totalRequests + ' req, ' +
+                    '<span style="color: ' + rateColor + '">' + stats.successRate.toFixed(1) + '%</span>)</span>' +
+                    '</div>' +
+                    '<div style="font-size: 0.9rem; color: var(--text-muted)">' +
+                    '📥' + stats.tokenUsage.inputTokens.toLocaleString() + ' 📤' + stats.tokenUsage.outputTokens.toLocaleString() + ' ' +
+                    '❄️' + this.formatUptime(stats.cooldownSeconds) +
+                    '</div>' +
+                    '</div>';
+                groupsList.appendChild(div);
+            });
+        } catch (error) {
+            console.error('Error loading group stats:', error);
+        }
+    }
+
+    updateTokenHistory(history) {
+        const historyList = document.getElementById('token-history-list');
+        historyList.innerHTML = '';
+
+        if (history && history.length > 0) {
+            history.forEach((conn, index) => {
+                const div = document.createElement('div');
+                div.className = 'history-item';
+                let statusIcon = conn.status === 'success' ? '✓' : '✗';
+                let statusColor = conn.status === 'success' ? 'var(--success)' : 'var(--danger)';
+                if (conn.status === 'cancelled') {
+                    statusIcon = '⊘';
+                    statusColor = 'var(--text-muted)';
+                }
+
+                div.innerHTML =
+                    '<div style="display: flex; justify-content: space-between; align-items: center;">' +
+                    '<div>' +
+                    '<span style="color: ' + statusColor + '">' + statusIcon + '</span> ' +
+                    '<span style="color: var(--accent)">' + conn.clientIP + '</span> → ' +
+                    '<span style="color: var(--warning)">' + conn.endpoint + '</span>' +
+                    (conn.retryReasons ? ' <span style="color: var(--danger-soft)" title="重试原因">' + this.escapeHtml(conn.retryReasons) + '</span>' : '') +
+                    '</div>' +
+                    '<div style="font-size: 0.9rem; color: var(--text-muted)">' +
+                    '📥' + conn.tokenUsage.inputTokens + ' 📤' + conn.tokenUsage.outputTokens + ' ' +
+                    '🔢' + conn.tokenUsage.totalTokens +
+                    '</div>' +
+                    '</div>';
+                historyList.appendChild(div);
+            });
+        } else {
+            const div = document.createElement('div');
+            div.className = 'history-item';
+            div.innerHTML = '<span class="history-placeholder">暂无令牌使用记录...</span>';
+            historyList.appendChild(div);
+        }
+    }
+
+    async loadTokenHistoryChart() {
+        try {
+            const response = await fetch('/api/overview/token-history?window=20m&interval=1m');
+            const data = await response.json();
+
+            this.renderTokenChart(data);
+        } catch (error) {
+            console.error('Error loading token history:', error);
+            document.getElementById('token-chart').innerHTML =
+                '<div style="color: var(--danger); text-align: center; padding: 20px;">加载令牌历史失败</div>';
+        }
+    }
+
+    renderTokenChart(data) {
+        const chartContainer = document.getElementById('token-chart');
+
+        if (!data.history || data.history.length === 0) {
+            chartContainer.innerHTML =
+                '<div style="color: var(--text-faint); text-align: center; padding: 20px;">No token usage data available</div>';
+            return;
+        }
+
+        // Simple ASCII-style chart rendering (similar to TUI)
+        let chartHtml = '<div style="font-family: monospace; font-size: 0.8rem; line-height: 1.2;">';
+
+        // Get the last 20 data points for display
+        const displayData = data.history.slice(-20);
+        const maxTokens = Math.max(...displayData.map(d => d.totalTokens));
+
+        if (maxTokens === 0) {
+            chartContainer.innerHTML =
+                '<div style="color: var(--text-faint); text-align: center; padding: 20px;">No token usage recorded</div>';
+            return;
+        }
+
+        // Chart header
+        chartHtml += '<div style="color: var(--accent); margin-bottom: 10px; text-align: center;">令牌使用时间趋势</div>';
+
+        // Simple bar chart
+        displayData.forEach((point, index) => {
+            const percentage = (point.totalTokens / maxTokens) * 100;
+            const barWidth = Math.max(1, Math.floor(percentage / 2)); // Scale to fit
+
+            const inputPerc = point.totalTokens > 0 ? (point.inputTokens / point.totalTokens) * barWidth : 0;
+            const outputPerc = point.totalTokens > 0 ? (point.outputTokens / point.totalTokens) * barWidth : 0;
+            const cachePerc = point.totalTokens > 0 ? ((point.cacheCreationTokens + point.cacheReadTokens) / point.totalTokens) * barWidth : 0;
+
+            chartHtml += '<div style="display: flex; align-items: center; margin: 2px 0;">';
+            const label = new Date(point.timestamp).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+            chartHtml += '<span style="color: var(--text-faint); width: 60px; font-size: 0.7rem;">' + label + '</span>';
+            chartHtml += '<div style="display: flex; margin-left: 10px;">';
+
+            // Input tokens (blue)
+            if (inputPerc > 0) {
+                chartHtml += '<div style="background: var(--accent); height: 12px; width: ' + Math.floor(inputPerc) + 'px;"></div>';
+            }
+            // Output tokens (green)
+            if (outputPerc > 0) {
+                chartHtml += '<div style="background: var(--success-soft); height: 12px; width: ' + Math.floor(outputPerc) + 'px;"></div>';
+            }
+            // Cache tokens (yellow)
+            if (cachePerc > 0) {
+                chartHtml += '<div style="background: var(--warning); height: 12px; width: ' + Math.floor(cachePerc) + 'px;"></div>';
+            }
+
+            chartHtml += '</div>';
+            chartHtml += '<span style="color: var(--text-muted); margin-left: 10px; font-size: 0.7rem;">' + point.totalTokens.toLocaleString() + '</span>';
+            chartHtml += '</div>';
+        });
+
+        chartHtml += '</div>';
+        chartContainer.innerHTML = chartHtml;
+    }
+
+    async loadEndpoints() {
+        try {
+            const response = await fetch('/api/endpoints');
+            const data = await response.json();
+
+            const tbody = document.getElementById('endpoints-table-body');
+            tbody.innerHTML = '';
+
+            // Endpoints are listed under a header per group, in failover order like in the TUI
+            const groups = [];
+            data.endpoints.forEach(endpoint => {
+                let group = groups.find(g => g.name === endpoint.group);
+                if (!group) {
+                    group = { name: endpoint.group, priority: endpoint.groupPriority, endpoints: [] };
+                    groups.push(group);
+                }
+                group.endpoints.push(endpoint);
+            });
+            groups.sort((a, b) => a.priority - b.priority);
+
+            let index = 0;
+            groups.forEach(group => {
+                const header = document.createElement('tr');
+                header.className = 'group-header';
+                header.dataset.group = group.name;
+                header.innerHTML = '<td colspan="8">📁 ' + this.escapeHtml(group.name) +
+                    ' · 组优先级 <span class="group-priority-cell">' + group.priority + '</span></td>';
+                tbody.appendChild(header);
+
+                group.endpoints.forEach(endpoint => this.appendEndpointRow(tbody, endpoint, index++));
+            });
+
+            // Auto-select first endpoint if none selected
+            if (data.endpoints.length > 0 && !this.selectedEndpoint) {
+                this.selectEndpoint(data.endpoints[0]);
+            }
+
+        } catch (error) {
+            console.error('Error loading endpoints:', error);
+        }
+    }
+
+    // appendEndpointRow adds the table row of an endpoint
+    appendEndpointRow(tbody, endpoint, index) {
+        const row = document.createElement('tr');
+        row.dataset.index = index;
+        row.addEventListener('click', () => this.selectEndpoint(endpoint));
+
+        let statusIcon = endpoint.disabled ? '⏸️' : (endpoint.healthy ? '🟢' : '🔴');
+        if (!endpoint.disabled && endpoint.rateLimited) {
+            statusIcon = '🚦';
+        } else if (!endpoint.disabled && endpoint.healthy && endpoint.circuitBreaker && endpoint.circuitBreaker.state !== 'closed') {
+            statusIcon = endpoint.circuitBreaker.state === 'open' ? '🔌' : '🟡';
+        } else if (!endpoint.disabled && endpoint.healthy && endpoint.rateLimit && endpoint.rateLimit.tokens < 1) {
+            statusIcon = '⏱️';
+        }
+        const requests = endpoint.stats ? endpoint.stats.totalRequests : 0;
+        const failedRequests = endpoint.failedRequests || 0;  // Use new failedRequests field
+
+        row.innerHTML =
+            '<td><span class="status-icon">' + statusIcon + '</span></td>' +
+            '<td>' + endpoint.name + '</td>' +
+            '<td>' + this.truncateUrl(endpoint.url, 25) + '</td>' +
+            '<td>' + endpoint.priority + '</td>' +
+            '<td>' + endpoint.responseTime + 'ms</td>' +
+            '<td>' + requests + '</td>' +
+            '<td>' + failedRequests + '</td>' +
+            '<td><button class="btn btn-secondary btn-row">' + (endpoint.disabled ? '▶️ 启用' : '⏸️ 禁用') + '</button></td>';
+        row.querySelector('.btn-row').addEventListener('click', (event) => {
+            event.stopPropagation();
+            this.toggleMaintenance(endpoint.name, !endpoint.disabled);
+        });
+
+        tbody.appendChild(row);
+    }
+
+    selectEndpoint(endpoint) {
+        this.selectedEndpoint = endpoint;
+
+        // Update table selection
+        document.querySelectorAll('#endpoints-table-body tr').forEach(row => {
+            row.classList.remove('selected');
+        });
+
+        // Find and highlight the selected row
+        const rows = document.querySelectorAll('#endpoints-table-body tr');
+        rows.forEach(row => {
+            if (row.querySelector('td:nth-child(2)') &&
+                row.querySelector('td:nth-child(2)').textContent === endpoint.name) {
+                row.classList.add('selected');
+            }
+        });
+
+        // Update details panel (now async)
+        this.updateEndpointDetails(endpoint);
+    }
+
+    async updateEndpointDetails(endpoint) {
+        const detailsContent = document.getElementById('endpoint-details-content');
+
+        // Show loading state
+        detailsContent.innerHTML = '<div class="loading">正在加载端点详情...</div>';
+
+        try {
+            // Fetch detailed endpoint information from new API
+            const response = await fetch('/api/endpoints/details?name=' + encodeURIComponent(endpoint.name));
+            const details = await response.json();
+
+            this.renderEndpointDetails(details);
+        } catch (error) {
+            console.error('Error loading endpoint details:', error);
+            // Fallback to basic details if API fails
+            this.renderBasicEndpointDetails(endpoint);
+        }
+    }
+
+    renderEndpointDetails(details) {
+        const detailsContent = document.getElementById('endpoint-details-content');
+
+        let html = '<h4 style="color: var(--accent); margin-bottom: 15px;">🎯 ' + details.name + '</h4>';
+
+        // Basic Info
+        if (details.socketPath) {
+            html += '<div class="metric"><span class="label">Socket:</span><span class="value">🔌 ' + this.escapeHtml(details.socketPath) + '</span></div>';
+        } else {
+            html += '<div class="metric"><span class="label">URL:</span><span class="value">' + details.url + '</span></div>';
+        }
+        html += '<div class="metric"><span class="label">Priority:</span><span class="value">' + details.priority + '</span></div>';
+
+        // Group information (similar to TUI)
+        if (details.group) {
+            html += '<div class="metric"><span class="label">Group:</span><span class="value">' + details.group + '</span></div>';
+            if (details.groupPriority !== undefined) {
+                html += '<div class="metric"><span class="label">Group Priority:</span><span class="value">' + details.groupPriority + '</span></div>';
+            }
+            if (details.groupCooldown > 0) {
+                html += '<div class="metric"><span class="label">Group Cooldown:</span><span class="value" style="color: var(--warning)">' + details.groupCooldown + 's ' +
+                    '<button class="btn btn-secondary btn-row" onclick="app.resetGroupCooldown(' + "'" + this.escapeHtml(details.group) + "'" + ')">🔄 重置冷却</button></span></div>';
+            }
+        }
+
+        html += '<div class="metric"><span class="label">Timeout:</span><span class="value">' + details.timeout + '</span></div>';
+
+        // Health Status
+        let healthStatus = details.healthy ? 'Healthy' : 'Unhealthy';
+        let healthColor = details.healthy ? 'var(--success)' : 'var(--danger)';
+        if (details.disabled) {
+            healthStatus = '⏸️ Maintenance';
+            healthColor = 'var(--warning)';
+        }
+        html += '<div class="metric"><span class="label">Status:</span><span class="value" style="color: ' + healthColor + '">' + healthStatus + '</span></div>';
+        if (details.rateLimited && details.rateLimitedUntil) {
+            const until = new Date(details.rateLimitedUntil).toLocaleTimeString();
+            html += '<div class="metric"><span class="label">Rate Limited:</span><span class="value" style="color: var(--warning)">🚦 rate limited for ' + details.rateLimitedFor + 's (until ' + until + ')</span></div>';
+        }
+        if (details.authType === 'oauth2') {
+            const expiry = details.tokenExpiry ? new Date(details.tokenExpiry).toLocaleString() : 'no token';
+            const expiryColor = details.authError ? 'var(--danger)' : 'var(--text)';
+            html += '<div class="metric"><span class="label">OAuth2 Token Expires:</span><span class="value" style="color: ' + expiryColor + '">🔑 ' + expiry + '</span></div>';
+            if (details.authError) {
+                html += '<div class="metric"><span class="label">OAuth2 Error:</span><span class="value" style="color: var(--danger)">' + this.escapeHtml(details.authError) + '</span></div>';
+            }
+        }
+        html += '<div class="metric"><span class="label">Response Time:</span><span class="value">' + details.responseTime + 'ms</span></div>';
+        html += '<div class="metric"><span class="label">Last Check:</span><span class="value">' + details.lastCheck + '</span></div>';
+        if (details.healthStreak) {
+            const hs = details.healthStreak;
+            html += '<div class="metric"><span class="label">In a Row:</span><span class="value">' + hs.successes + ' ok / ' + hs.failures +
+                ' failed (healthy after ' + hs.healthyThreshold + ', unhealthy after ' + hs.unhealthyThreshold + ')</span></div>';
+        }
+        const inFlightLimit = details.maxConcurrent > 0 ? details.maxConcurrent : '∞';
+        const inFlightColor = details.maxConcurrent > 0 && details.inFlight >= details.maxConcurrent ? 'var(--warning)' : 'var(--text)';
+        html += '<div class="metric"><span class="label">In-flight:</span><span class="value" style="color: ' + inFlightColor + '">' + (details.inFlight || 0) + '/' + inFlightLimit + '</span></div>';
+        if (details.rateLimit) {
+            const rl = details.rateLimit;
+            const rateColor = rl.tokens < 1 ? 'var(--warning)' : 'var(--text)';
+            html += '<div class="metric"><span class="label">Rate Limit:</span><span class="value" style="color: ' + rateColor + '">⏱️ ' +
+                Math.max(rl.tokens, 0).toFixed(1) + '/' + rl.burst + ' tokens (' + rl.requestsPerMinute + '/min, ' + rl.onExceeded + ')</span></div>';
+        }
+        if (details.tokens) {
+            details.tokens.forEach((t, i) => {
+                const tokenState = t.available ? '🟢 active' : '🔴 ' + t.statusCode + ' until ' + new Date(t.disabledUntil).toLocaleTimeString();
+                const tokenColor = t.available ? 'var(--success)' : 'var(--danger)';
+                html += '<div class="metric"><span class="label">Token ' + (i + 1) + ':</span><span class="value" style="color: ' + tokenColor + '">' +
+                    this.escapeHtml(t.token) + ' ' + tokenState + '</span></div>';
+            });
+        }
+        if (details.circuitBreaker) {
+            const cb = details.circuitBreaker;
+            let circuit = '🔌 closed (' + cb.failures + '/' + cb.failureThreshold + ' fails)';
+            let circuitColor = 'var(--success)';
+            if (cb.state === 'open') {
+                circuit = '🔌 open (half-open in ' + cb.remainingSeconds + 's)';
+                circuitColor = 'var(--danger)';
+            } else if (cb.state === 'half-open') {
+                circuit = '🔌 half-open (probing, ' + cb.halfOpenMaxRequests + ' at a time)';
+                circuitColor = 'var(--warning)';
+            }
+            html += '<div class="metric"><span class="label">Circuit:</span><span class="value" style="color: ' + circuitColor + '">' + circuit + '</span></div>';
+        }
+
+        // Maintenance mode toggle
+        const maintenanceLabel = details.disabled ? '▶️ 退出维护模式' : '⏸️ 进入维护模式';
+        html += '<div style="margin: 10px 0;"><button class="btn btn-secondary" onclick="app.toggleMaintenance(' +
+            "'" + this.escapeHtml(details.name) + "', " + (!details.disabled) + ')">' + maintenanceLabel + '</button> ' +
+            '<button class="btn btn-secondary" onclick="app.testEndpoint(' + "'" + this.escapeHtml(details.name) + "'" + ')">🧪 Test</button></div>';
+        html += '<div id="endpoint-test-result"></div>';
+
+        // Performance Metrics (enhanced with detailed stats)
+        if (details.stats && details.stats.totalRequests > 0) {
+            html += '<h5 style="color: var(--warning); margin: 15px 0 10px 0;">📊 Performance</h5>';
+            html += '<div class="metric"><span class="label">Total Requests:</span><span class="value">' + details.stats.totalRequests.toLocaleString() + '</span></div>';
+            html += '<div class="metric"><span class="label">Successful:</span><span class="value success">' + details.stats.successfulRequests.toLocaleString() + '</span></div>';
+            html += '<div class="metric"><span class="label">Failed:</span><span class="value error">' + details.stats.failedRequests.toLocaleString() + '</span></div>';
+            if (details.stats.rateLimitCount > 0) {
+                html += '<div class="metric"><span class="label">429 Responses:</span><span class="value error">' + details.stats.rateLimitCount.toLocaleString() + '</span></div>';
+            }
+
+            const successRate = details.stats.totalRequests > 0 ? (details.stats.successfulRequests / details.stats.totalRequests * 100) : 0;
+            html += '<div class="metric"><span class="label">Success Rate:</span><span class="value success">' + successRate.toFixed(1) + '%</span></div>';
+
+            html += '<div class="metric"><span class="label">Avg Response:</span><span class="value">' + details.stats.averageResponseTime + 'ms</span></div>';
+            html += '<div class="metric"><span class="label">Min Response:</span><span class="value">' + details.stats.minResponseTime + 'ms</span></div>';
+            html += '<div class="metric"><span class="label">Max Response:</span><span class="value">' + details.stats.maxResponseTime + 'ms</span></div>';
+            if (details.stats.latencySamples > 0) {
+                html += '<div class="metric"><span class="label">P50 / P95 / P99:</span><span class="value">' +
+                    details.stats.p50ResponseTime + 'ms / ' + details.stats.p95ResponseTime + 'ms / ' + details.stats.p99ResponseTime + 'ms</span></div>';
+            }
+
+            // Token Usage (enhanced)
+            const tokenUsage = details.stats.tokenUsage;
+            const hasTokens = tokenUsage.inputTokens > 0 || tokenUsage.outputTokens > 0 || tokenUsage.cacheCreationTokens > 0 || tokenUsage.cacheReadTokens > 0;
+            if (hasTokens) {
+                html += '<h5 style="color: var(--purple); margin: 15px 0 10px 0;">🪙 Token Usage</h5>';
+                html += '<div class="metric"><span class="label">📥 Input:</span><span class="value">' + tokenUsage.inputTokens.toLocaleString() + '</span></div>';
+                html += '<div class="metric"><span class="label">📤 Output:</span><span class="value">' + tokenUsage.outputTokens.toLocaleString() + '</span></div>';
+                if (tokenUsage.cacheCreationTokens > 0 || tokenUsage.cacheReadTokens > 0) {
+                    html += '<div class="metric"><span class="label">🆕 Cache Create:</span><span class="value">' + tokenUsage.cacheCreationTokens.toLocaleString() + '</span></div>';
+                    html += '<div class="metric"><span class="label">📖 Cache Read:</span><span class="value">' + tokenUsage.cacheReadTokens.toLocaleString() + '</span></div>';
+                }
+                const totalTokens = tokenUsage.inputTokens + tokenUsage.outputTokens;
+                html += '<div class="metric"><span class="label">🔢 Total:</span><span class="value highlight">' + totalTokens.toLocaleString() + '</span></div>';
+            }
+        } else {
+            html += '<h5 style="color: var(--warning); margin: 15px 0 10px 0;">📊 Performance</h5>';
+            html += '<p style="color: var(--text-faint); font-style: italic;">No requests processed yet</p>';
+        }
+
+        // Headers (if any)
+        if (details.headers && Object.keys(details.headers).length > 0) {
+            html += '<h5 style="color: var(--cyan); margin: 15px 0 10px 0;">📋 Headers</h5>';
+            Object.entries(details.headers).forEach(([key, value]) => {
+                html += '<div class="metric"><span class="label">' + key + ':</span><span class="value" style="font-family: monospace; font-size: 0.9rem;">' + value + '</span></div>';
+            });
+        }
+
+        detailsContent.innerHTML = html;
+    }
+
+    async toggleMaintenance(name, enabled) {
+        try {
+            const response = await fetch(enabled ? '/api/endpoints/disable' : '/api/endpoints/enable', {
+                method: 'POST',
+                headers: this.csrfHeaders({ 'Content-Type': 'application/json' }),
+                body: JSON.stringify({ name: name })
+            });
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            await this.loadEndpoints();
+            if (this.selectedEndpoint && this.selectedEndpoint.name === name) {
+                this.updateEndpointDetails(this.selectedEndpoint);
+            }
+        } catch (error) {
+            console.error('Error toggling maintenance mode:', error);
+            alert('切换维护模式失败: ' + error.message);
+        }
+    }
+
+    async resetGroupCooldown(group) {
+        try {
+            const response = await fetch('/api/groups/reset-cooldown', {
+                method: 'POST',
+                headers: this.csrfHeaders({ 'Content-Type': 'application/json' }),
+                body: JSON.stringify({ name: group })
+            });
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            await this.loadEndpoints();
+            if (this.selectedEndpoint) {
+                this.updateEndpointDetails(this.selectedEndpoint);
+            }
+        } catch (error) {
+            console.error('Error resetting group cooldown:', error);
+            alert('重置组冷却失败: ' + error.message);
+        }
+    }
+
+    // Sends a dry-run request to the endpoint; it is not counted in request or token statistics
+    async testEndpoint(name) {
+        const resultDiv = document.getElementById('endpoint-test-result');
+        if (!resultDiv) return;
+        resultDiv.innerHTML = '<div class="loading">正在发送测试请求...</div>';
+
+        try {
+            const response = await fetch('/api/test-request', {
+                method: 'POST',
+                headers: this.csrfHeaders({ 'Content-Type': 'application/json' }),
+                body: JSON.stringify({
+                    endpoint: name,
+                    path: '/v1/messages',
+                    method: 'POST',
+                    body: { model: 'claude-3-5-haiku-latest', max_tokens: 1, messages: [{ role: 'user', content: 'ping' }] },
+                    stream: false
+                })
+            });
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            this.renderTestResult(await response.json());
+        } catch (error) {
+            console.error('Error sending test request:', error);
+            resultDiv.innerHTML = '<div class="metric"><span class="label">Test:</span><span class="value error">' + this.escapeHtml(error.message) + '</span></div>';
+        }
+    }
+
+    renderTestResult(report) {
+        const resultDiv = document.getElementById('endpoint-test-result');
+        if (!resultDiv) return;
+
+        let html = '<h5 style="color: var(--cyan); margin: 15px 0 10px 0;">🧪 Test Request</h5>';
+        html += '<div class="metric"><span class="label">Endpoint:</span><span class="value">' + this.escapeHtml(report.endpoint) + '</span></div>';
+        html += '<div class="metric"><span class="label">Selection:</span><span class="value">' + this.escapeHtml(report.selectionReason) + '</span></div>';
+        html += '<div class="metric"><span class="label">Request:</span><span class="value">' + this.escapeHtml(report.method + ' ' + report.url) + '</span></div>';
+        html += '<div class="metric"><span class="label">Transport:</span><span class="value">' + this.escapeHtml(report.transport) + '</span></div>';
+        if (report.error) {
+            html += '<div class="metric"><span class="label">Error:</span><span class="value error">' + this.escapeHtml(report.error) + '</span></div>';
+        } else {
+            const statusClass = report.statusCode < 400 ? 'success' : 'error';
+            html += '<div class="metric"><span class="label">Status:</span><span class="value ' + statusClass + '">' + report.statusCode + '</span></div>';
+        }
+        html += '<div class="metric"><span class="label">Latency:</span><span class="value">' + report.latencyMs + 'ms</span></div>';
+        if (report.tokenUsage) {
+            html += '<div class="metric"><span class="label">Tokens:</span><span class="value">📥 ' + report.tokenUsage.InputTokens + ' / 📤 ' + report.tokenUsage.OutputTokens + '</span></div>';
+        }
+
+        html += '<div style="color: var(--text-muted); margin-top: 8px;">Outbound headers</div>';
+        Object.keys(report.requestHeaders || {}).sort().forEach(key => {
+            html += '<div class="metric"><span class="label">' + this.escapeHtml(key) + ':</span><span class="value" style="font-family: monospace; font-size: 0.9rem;">' + this.escapeHtml(report.requestHeaders[key]) + '</span></div>';
+        });
+        if (report.responsePreview) {
+            html += '<div style="color: var(--text-muted); margin-top: 8px;">Response (' + report.responseBytes + ' bytes' + (report.truncated ? ', truncated' : '') + ')</div>';
+            html += '<pre style="white-space: pre-wrap; word-break: break-all; font-size: 0.8rem; max-height: 200px; overflow: auto;">' + this.escapeHtml(report.responsePreview) + '</pre>';
+        }
+
+        resultDiv.innerHTML = html;
+    }
+
+    renderBasicEndpointDetails(endpoint) {
+        // Fallback method using basic endpoint data (original implementation)
+        const detailsContent = document.getElementById('endpoint-details-content');
+
+        let html = '<h4 style="color: var(--accent); margin-bottom: 15px;">🎯 ' + endpoint.name + '</h4>';
+        html += '<div class="metric"><span class="label">URL:</span><span class="value">' + endpoint.url + '</span></div>';
+        html += '<div class="metric"><span class="label">Priority:</span><span class="value">' + endpoint.priority + '</span></div>';
+
+        const healthStatus = endpoint.healthy ? 'Healthy' : 'Unhealthy';
+        const healthColor = endpoint.healthy ? 'var(--success)' : 'var(--danger)';
+        html += '<div class="metric"><span class="label">Status:</span><span class="value" style="color: ' + healthColor + '">' + healthStatus + '</span></div>';
+        html += '<div class="metric"><span class="label">Response Time:</span><span class="value">' + endpoint.responseTime + 'ms</span></div>';
+
+        html += '<p style="color: var(--danger); font-style: italic; margin-top: 15px;">⚠️ Detailed information unavailable</p>';
+
+        detailsContent.innerHTML = html;
+    }
+
+    toggleConnectionDetail(connId) {
+        this.expandedConnectionId = this.expandedConnectionId === connId ? null : connId;
+        this.loadConnections();
+    }
+
+    async loadConnectionDetail(connId, container) {
+        try {
+            const response = await fetch('/api/connections/detail?id=' + encodeURIComponent(connId));
+            if (!response.ok) {
+                container.innerHTML = '<div class="attempt-empty">连接已结束或不存在</div>';
+                return;
+            }
+            const detail = await response.json();
+            const requestId = detail.requestId ? '<div class="attempt-title">请求ID: ' + this.escapeHtml(detail.requestId) + '</div>' : '';
+            if (detail.rejectReason) {
+                container.innerHTML = requestId + '<div class="attempt-empty">转发器已拒绝请求，未转发: ' + this.escapeHtml(detail.rejectReason) + '</div>';
+                return;
+            }
+            if (detail.coalescedWith) {
+                container.innerHTML = requestId + '<div class="attempt-empty">与相同请求合并，未单独转发，响应来自连接 ' + this.escapeHtml(detail.coalescedWith) + '</div>';
+                return;
+            }
+            if (!detail.attempts || detail.attempts.length === 0) {
+                container.innerHTML = requestId + '<div class="attempt-empty">尚无上游尝试</div>';
+                return;
+            }
+
+            let html = requestId + '<div class="attempt-title">上游尝试 (最多保留 ' + detail.attemptsKept + ' 条)' +
+                (detail.retryReasons ? ' - 重试原因: ' + this.escapeHtml(detail.retryReasons) : '') + '</div>';
+            detail.attempts.forEach((attempt, index) => {
+                const outcome = attempt.statusCode > 0 ? attempt.outcome + ' ' + attempt.statusCode : attempt.outcome;
+                html += '<div class="attempt-row">' +
+                    '<span class="attempt-index">#' + (index + 1) + '</span>' +
+                    '<span class="attempt-time">' + attempt.startTime + ' → ' + attempt.endTime + '</span>' +
+                    '<span class="attempt-endpoint">' + this.escapeHtml(attempt.endpoint) + (attempt.streaming ? ' 🌊' : '') + '</span>' +
+                    '<span class="attempt-outcome outcome-' + attempt.outcome + '">' + this.escapeHtml(outcome) + '</span>' +
+                    '<span class="attempt-duration">' + attempt.durationMs + 'ms' + (attempt.backoffMs > 0 ? ' (退避 ' + attempt.backoffMs + 'ms)' : '') + '</span>' +
+                    '</div>';
+            });
+            container.innerHTML = html;
+        } catch (error) {
+            console.error('Error loading connection detail:', error);
+        }
+    }
+
+    toggleCapture(id) {
+        this.expandedCaptureId = this.expandedCaptureId === id ? null : id;
+        this.renderInspector();
+    }
+
+    async loadInspector() {
+        try {
+            const response = await fetch('/api/inspector');
+            this.inspector = await response.json();
+            this.renderInspector();
+        } catch (error) {
+            console.error('Error loading inspector:', error);
+        }
+    }
+
+    renderInspector() {
+        const data = this.inspector;
+        const status = document.getElementById('inspector-status');
+        const body = document.getElementById('inspector-table-body');
+        if (!data || !status || !body) return;
+
+        status.textContent = data.enabled
+            ? '保留最近 ' + data.maxRequests + ' 个请求，请求体和响应体各保留前 ' + data.maxBodyBytes + ' 字节，凭据请求头已脱敏。点击一行查看详情。'
+            : '请求捕获未启用，在配置中设置 webui.capture_enabled: true 后开始记录。';
+
+        body.innerHTML = '';
+        if (!data.captures || data.captures.length === 0) {
+            body.innerHTML = '<div class="placeholder">暂无捕获的请求</div>';
+            return;
+        }
+
+        data.captures.forEach(capture => {
+            const row = document.createElement('div');
+            row.className = 'inspector-row expandable';
+            const failed = capture.statusCode === 0 || capture.statusCode >= 400;
+            row.innerHTML =
+                '<div>' + new Date(capture.time).toLocaleTimeString() + '</div>' +
+                '<div>' + this.escapeHtml(capture.method) + '</div>' +
+                '<div title="' + this.escapeHtml(capture.path) + '">' + this.escapeHtml(this.truncateString(capture.path, 40)) + '</div>' +
+                '<div>' + this.escapeHtml(capture.endpoint || '-') + '</div>' +
+                '<div>' + capture.attempts.length + '</div>' +
+                '<div class="' + (failed ? 'status-failed' : 'status-completed') + '">' + (capture.statusCode || '-') + '</div>' +
+                '<div>' + capture.durationMs + 'ms</div>';
+            row.addEventListener('click', () => this.toggleCapture(capture.id));
+            body.appendChild(row);
+
+            if (capture.id === this.expandedCaptureId) {
+                row.classList.add('expanded');
+                const detail = document.createElement('div');
+                detail.className = 'inspector-detail';
+                detail.innerHTML = this.renderCaptureDetail(capture);
+                body.appendChild(detail);
+            }
+        });
+    }
+
+    renderCaptureDetail(capture) {
+        const headers = (h) => Object.keys(h || {}).sort()
+            .map(name => this.escapeHtml(name) + ': ' + this.escapeHtml(h[name])).join('\n') || '(无)';
+        const section = (title, content) => '<div class="attempt-title">' + title + '</div><pre class="inspector-pre">' + content + '</pre>';
+
+        let attempts = capture.requestId ? '<div class="attempt-title">请求ID: ' + this.escapeHtml(capture.requestId) + '</div>' : '';
+        attempts += '<div class="attempt-title">上游尝试</div>';
+        if (capture.coalescedWith) {
+            attempts += '<div class="attempt-empty">没有上游尝试（与相同请求合并，响应来自连接 ' + this.escapeHtml(capture.coalescedWith) + '）</div>';
+        } else if (capture.attempts.length === 0) {
+            attempts += '<div class="attempt-empty">没有上游尝试（请求被转发器直接响应）</div>';
+        }
+        capture.attempts.forEach((attempt, index) => {
+            const outcome = attempt.statusCode > 0 ? attempt.outcome + ' ' + attempt.statusCode : attempt.outcome;
+            attempts += '<div class="attempt-row">' +
+                '<span class="attempt-index">#' + (index + 1) + '</span>' +
+                '<span class="attempt-endpoint">' + this.escapeHtml(attempt.endpoint) + (attempt.streaming ? ' 🌊' : '') + '</span>' +
+                '<span class="attempt-outcome outcome-' + attempt.outcome + '">' + this.escapeHtml(outcome) + '</span>' +
+                '<span class="attempt-duration">' + attempt.durationMs + 'ms' + (attempt.backoffMs > 0 ? ' (退避 ' + attempt.backoffMs + 'ms)' : '') + '</span>' +
+                '</div>';
+        });
+
+        return attempts +
+            section('请求头', headers(capture.requestHeaders)) +
+            section('请求体' + (capture.requestTruncated ? '（已截断）' : ''), this.escapeHtml(capture.requestBody || '(空)')) +
+            section('响应头', headers(capture.responseHeaders)) +
+            section('响应体' + (capture.responseTruncated ? '（已截断）' : ''), this.escapeHtml(capture.responseBody || '(空)'));
+    }
+
+    async loadConnections() {
+        try {
+            const response = await fetch('/api/connections');
+            const data = await response.json();
+
+            document.getElementById('connections-active').textContent = data.activeCount;
+            document.getElementById('connections-historical').textContent = data.historicalCount;
+            document.getElementById('connections-cancelled').textContent = data.cancelledCount || 0;
+            document.getElementById('connections-coalesced').textContent = data.coalescedCount || 0;
+
+            const connectionsTableBody = document.getElementById('connections-table-body');
+            connectionsTableBody.innerHTML = '';
+
+            if (data.activeConnections && data.activeConnections.length > 0) {
+                // Sort connections by start time (most recent first)
+                const sortedConnections = data.activeConnections.sort((a, b) =>
+                    new Date(b.startTime) - new Date(a.startTime)
+                );
+
+                // Show up to 15 connections (similar to TUI)
+                sortedConnections.slice(0, 15).forEach(conn => {
+                    const row = document.createElement('div');
+                    row.className = 'connection-row';
+
+                    // Determine connection status and styling
+                    let statusClass = 'active';
+                    if (conn.status === 'completed') statusClass = 'completed';
+                    else if (conn.status === 'failed') statusClass = 'failed';
+                    else if (conn.status === 'cancelled') statusClass = 'cancelled';
+                    else if (conn.status === 'websocket') statusClass = 'websocket';
+                    else if (conn.isStreaming) statusClass = 'streaming';
+
+                    // Calculate duration
+                    const duration = this.calculateConnectionDuration(conn.startTime);
+
+                    // Get endpoint group information
+                    const endpointDisplay = conn.endpoint || 'pending';
+                    const groupName = this.getEndpointGroup(endpointDisplay);
+
+                    // Format retry information, with the failed attempts by class (e.g. "timeouts:2")
+                    let retryDisplay = '-';
+                    if (conn.retryReasons) {
+                        retryDisplay = conn.retryReasons;
+                    } else if (conn.retryCount > 0) {
+                        retryDisplay = String(conn.retryCount);
+                    }
+
+                    row.innerHTML =
+                        '<div class="conn-col-client"' + (conn.requestId ? ' title="请求ID: ' + this.escapeHtml(conn.requestId) + '"' : '') + '>' +
+                        '<span class="connection-status ' + statusClass + '"></span> ' +
+                        (conn.status === 'cancelled' ? '🚫 ' : '') + this.truncateString(conn.clientIP, 12) +
+                        '</div>' +
+                        '<div class="conn-col-method">' + conn.method + '</div>' +
+                        '<div class="conn-col-path">' + this.truncateString(conn.path, 18) + '</div>' +
+                        '<div class="conn-col-endpoint"' + (conn.pinned ? ' title="pinned: yes"' : '') + '>' +
+                        (conn.pinned ? '📌 ' : '') + this.truncateString(endpointDisplay, 8) + '</div>' +
+                        '<div class="conn-col-group">' + this.truncateString(groupName, 12) + '</div>' +
+                        '<div class="conn-col-retry" title="' + this.escapeHtml([conn.retryInfo, conn.retryReasons].filter(Boolean).join(' ')) + '">' + this.escapeHtml(retryDisplay) + '</div>' +
+                        '<div class="conn-col-duration">' + this.formatDurationShort(duration) + '</div>';
+
+                    // Click to expand the upstream attempt timeline
+                    row.classList.add('expandable');
+                    row.title = '点击查看上游尝试记录';
+                    row.addEventListener('click', () => this.toggleConnectionDetail(conn.id));
+                    connectionsTableBody.appendChild(row);
+
+                    if (conn.id && conn.id === this.expandedConnectionId) {
+                        row.classList.add('expanded');
+                        const detail = document.createElement('div');
+                        detail.className = 'connection-attempts';
+                        detail.id = 'connection-attempts';
+                        connectionsTableBody.appendChild(detail);
+                        this.loadConnectionDetail(conn.id, detail);
+                    }
+                });
+
+                // Fill remaining rows to maintain consistent height (similar to TUI)
+                const remainingRows = Math.max(0, 15 - sortedConnections.length);
+                for (let i = 0; i < remainingRows; i++) {
+                    const emptyRow = document.createElement('div');
+                    emptyRow.className = 'connection-row';
+                    emptyRow.innerHTML =
+                        '<div class="conn-col-client"></div>' +
+                        '<div class="conn-col-method"></div>' +
+                        '<div class="conn-col-path"></div>' +
+                        '<div class="conn-col-endpoint"></div>' +
+                        '<div class="conn-col-group"></div>' +
+                        '<div class="conn-col-retry"></div>' +
+                        '<div class="conn-col-duration"></div>';
+                    connectionsTableBody.appendChild(emptyRow);
+                }
+            } else {
+                // Show "No active connections" message
+                const emptyRow = document.createElement('div');
+                emptyRow.className = 'connection-row';
+                emptyRow.innerHTML = '<div style="grid-column: 1 / -1; text-align: center; color: var(--text-faint); font-style: italic;">无活动连接</div>';
+                connectionsTableBody.appendChild(emptyRow);
+
+                // Fill remaining rows
+                for (let i = 0; i < 14; i++) {
+                    const emptyRow = document.createElement('div');
+                    emptyRow.className = 'connection-row';
+                    emptyRow.innerHTML =
+                        '<div class="conn-col-client"></div>' +
+                        '<div class="conn-col-method"></div>' +
+                        '<div class="conn-col-path"></div>' +
+                        '<div class="conn-col-endpoint"></div>' +
+                        '<div class="conn-col-group"></div>' +
+                        '<div class="conn-col-retry"></div>' +
+                        '<div class="conn-col-duration"></div>';
+                    connectionsTableBody.appendChild(emptyRow);
+                }
+            }
+
+        } catch (error) {
+            console.error('Error loading connections:', error);
+        }
+    }
+
+    calculateConnectionDuration(startTime) {
+        const start = new Date(startTime);
+        const now = new Date();
+        return now - start;
+    }
+
+    getEndpointGroup(endpointName) {
+        // This would ideally come from the endpoint data
+        // For now, return a default group name
+        if (endpointName === 'pending' || endpointName === 'unknown') {
+            return 'Unknown';
+        }
+        // In a real implementation, you'd look up the endpoint's group
+        return 'Default';
+    }
+
+    formatDurationShort(milliseconds) {
+        if (milliseconds < 1000) {
+            return milliseconds + 'ms';
+        } else if (milliseconds < 60000) {
+            return Math.floor(milliseconds / 1000) + 's';
+        } else if (milliseconds < 3600000) {
+            const minutes = Math.floor(milliseconds / 60000);
+            const seconds = Math.floor((milliseconds % 60000) / 1000);
+            return minutes + 'm' + (seconds > 0 ? seconds + 's' : '');
+        } else {
+            const hours = Math.floor(milliseconds / 3600000);
+            const minutes = Math.floor((milliseconds % 3600000) / 60000);
+            return hours + 'h' + (minutes > 0 ? minutes + 'm' : '');
+        }
+    }
+
+    async loadLogs() {
+        if (this.logSearchActive) {
+            return;
+        }
+        try {
+            const response = await fetch('/api/logs');
+            const data = await response.json();
+
+            const logsContent = document.getElementById('logs-content');
+            logsContent.innerHTML = '';
+
+            if (data.logs && data.logs.length > 0) {
+                // Display logs in reverse order (most recent first)
+                const reversedLogs = data.logs.slice().reverse();
+                
+                reversedLogs.forEach(log => {
+                    const div = document.createElement('div');
+                    div.className = 'log-entry';
+
+                    const levelClass = log.level.toLowerCase();
+                    const levelText = log.level.substring(0, 3);
+
+                    div.innerHTML =
+                        '<span class="log-time">' + log.timestamp + '</span>' +
+                        '<span class="log-level ' + levelClass + '">[' + levelText + ']</span>' +
+                        '<span class="log-source">' + log.source + '</span>' +
+                        '<span class="log-message">' + log.message + '</span>';
+
+                    logsContent.appendChild(div);
+                });
+            } else {
+                const div = document.createElement('div');
+                div.innerHTML = '<p class="placeholder">暂无日志...</p>';
+                logsContent.appendChild(div);
+            }
+
+        } catch (error) {
+            console.error('Error loading logs:', error);
+            const logsContent = document.getElementById('logs-content');
+            logsContent.innerHTML = '<p class="placeholder" style="color: var(--danger);">加载日志失败: ' + error.message + '</p>';
+        }
+    }
+
+    async searchLogs() {
+        const query = document.getElementById('log-search-input').value.trim();
+        const level = document.getElementById('log-search-level').value;
+        const since = document.getElementById('log-search-since').value;
+
+        if (!query && !level && !since) {
+            this.clearLogSearch();
+            return;
+        }
+
+        const params = new URLSearchParams();
+        if (query) params.set('q', query);
+        if (level) params.set('level', level);
+        if (since) params.set('since', since);
+
+        try {
+            const response = await fetch('/api/logs/search?' + params.toString());
+            if (!response.ok) {
+                this.showMessage('搜索日志失败: ' + (await response.text()), 'error');
+                return;
+            }
+            const data = await response.json();
+
+            this.logSearchActive = true;
+            document.getElementById('log-search-clear').style.display = '';
+
+            // Prefer file results (they cover rotated logs), fall back to the in-memory buffer
+            const useFiles = data.fileEnabled && data.files;
+            const results = useFiles ? data.files : (data.memory || []);
+            const source = useFiles ? '文件' : '内存';
+            const truncated = useFiles && data.filesTruncated ? ' (仅显示最近 ' + data.limit + ' 条)' : '';
+            document.getElementById('logs-title').textContent =
+                '📝 系统日志 - 搜索结果: ' + results.length + ' 条 [' + source + ']' + truncated;
+
+            const logsContent = document.getElementById('logs-content');
+            logsContent.innerHTML = '';
+
+            if (data.fileError) {
+                this.showMessage('搜索日志文件失败: ' + data.fileError, 'error');
+            }
+
+            if (results.length === 0) {
+                logsContent.innerHTML = '<p class="placeholder">没有匹配的日志</p>';
+                return;
+            }
+
+            results.slice().reverse().forEach(log => {
+                const div = document.createElement('div');
+                div.className = 'log-entry';
+
+                const level = log.level || 'INFO';
+                div.innerHTML =
+                    '<span class="log-time">' + this.escapeHtml(log.timestamp || '--') + '</span>' +
+                    '<span class="log-level ' + this.escapeHtml(level.toLowerCase()) + '">[' + this.escapeHtml(level.substring(0, 3)) + ']</span>' +
+                    (log.file ? '<span class="log-file">' + this.escapeHtml(log.file) + '</span>' : '<span class="log-source">' + this.escapeHtml(log.source || '') + '</span>') +
+                    '<span class="log-message">' + this.escapeHtml(log.message) + '</span>';
+
+                logsContent.appendChild(div);
+            });
+        } catch (error) {
+            this.showMessage('搜索日志失败: ' + error.message, 'error');
+        }
+    }
+
+    clearLogSearch() {
+        this.logSearchActive = false;
+        document.getElementById('log-search-input').value = '';
+        document.getElementById('log-search-level').value = '';
+        document.getElementById('log-search-since').value = '';
+        document.getElementById('log-search-clear').style.display = 'none';
+        document.getElementById('logs-title').textContent = '📝 系统日志';
+        this.loadLogs();
+    }
+
+    downloadLogs(rotated) {
+        // Navigate via a link so the browser streams the file to disk instead of buffering it
+        const a = document.createElement('a');
+        a.href = '/api/logs/download' + (rotated ? '?rotated=true' : '');
+        a.download = '';
+        document.body.appendChild(a);
+        a.click();
+        a.remove();
+    }
+
+    async loadConfig() {
+        try {
+            const response = await fetch('/api/config');
+            const data = await response.json();
+
+            // Server config
+            const listeners = data.server.listeners || [];
+            document.getElementById('config-server').innerHTML =
+                listeners.map(l =>
+                    '<div class="metric"><span class="label">Listener:</span><span class="value">' + this.escapeHtml(l.url) + '</span></div>'
+                ).join('') +
+                '<div class="metric"><span class="label">Require All Listeners:</span><span class="value">' + (data.server.requireAllListeners ? 'Yes' : 'No') + '</span></div>';
+
+            // Strategy config
+            document.getElementById('config-strategy').innerHTML =
+                '<div class="metric"><span class="label">Type:</span><span class="value">' + data.strategy.type + '</span></div>' +
+                '<div class="metric"><span class="label">Fast Test:</span><span class="value">' + (data.strategy.fastTestEnabled ? 'Enabled' : 'Disabled') + '</span></div>';
+
+            // Auth config
+            const authStatus = data.auth.enabled ? 'Enabled' : 'Disabled';
+            const authColor = data.auth.enabled ? 'var(--success)' : 'var(--danger)';
+            document.getElementById('config-auth').innerHTML =
+                '<div class="metric"><span class="label">Status:</span><span class="value" style="color: ' + authColor + '">' + authStatus + '</span></div>' +
+                (data.auth.enabled && data.auth.clients && data.auth.clients.length > 0
+                    ? '<div class="metric"><span class="label">Named Clients:</span><span class="value">' + this.escapeHtml(data.auth.clients.join(', ')) + '</span></div>'
+                    : '');
+
+            // Interface config
+            document.getElementById('config-interface').innerHTML =
+                '<div class="metric"><span class="label">TUI Update Interval:</span><span class="value">' + data.tui.updateInterval + '</span></div>' +
+                (data.webui.listen
+                    ? '<div class="metric"><span class="label">WebUI Listen:</span><span class="value">' + this.escapeHtml(data.webui.listen) + '</span></div>'
+                    : '<div class="metric"><span class="label">WebUI Host:</span><span class="value">' + data.webui.host + '</span></div>' +
+                      '<div class="metric"><span class="label">WebUI Port:</span><span class="value">' + data.webui.port + '</span></div>');
+
+            // Endpoints config
+            let endpointsHtml = '';
+            data.endpoints.forEach((ep, index) => {
+                const overridden = ep.configPriority !== undefined;
+                endpointsHtml +=
+                    '<div class="metric">' +
+                    '<span class="label">' + (index + 1) + '. ' + ep.name + ':</span>' +
+                    '<span class="value">' + this.truncateUrl(ep.url, 30) + ' (P:' + ep.priority + ')' +
+                    (overridden ? ' <span style="color: var(--warning-strong)" title="运行时覆盖，保存在状态文件中，配置文件中为 ' + ep.configPriority + '">⚡ 覆盖 (配置: ' + ep.configPriority + ')</span>' : '') +
+                    '</span></div>';
+            });
+            const groupOverrides = Object.entries((data.overrides && data.overrides.groups) || {});
+            groupOverrides.forEach(([name, override]) => {
+                endpointsHtml +=
+                    '<div class="metric">' +
+                    '<span class="label">组 ' + this.escapeHtml(name) + ':</span>' +
+                    '<span class="value">(GP:' + override.priority + ') <span style="color: var(--warning-strong)">⚡ 覆盖 (配置: ' + override.configPriority + ')</span></span>' +
+                    '</div>';
+            });
+            const overrideCount = Object.keys((data.overrides && data.overrides.endpoints) || {}).length + groupOverrides.length;
+            if (overrideCount > 0) {
+                endpointsHtml +=
+                    '<div class="metric"><span class="label">⚡ ' + overrideCount + ' 个运行时优先级覆盖保存在 ' + this.escapeHtml(data.overrides.file || '内存') + '</span>' +
+                    '<span class="value"><button onclick="app.clearOverrides()">♻️ 清除覆盖</button></span></div>';
+            }
+            document.getElementById('config-endpoints').innerHTML = endpointsHtml;
+
+            // Load configuration management data
+            await this.loadConfigs();
+
+        } catch (error) {
+            console.error('Error loading config:', error);
+        }
+    }
+
+    // Utility functions
+    formatUptime(seconds) {
+        if (seconds < 60) {
+            return Math.floor(seconds) + 's';
+        } else if (seconds < 3600) {
+            const minutes = Math.floor(seconds / 60);
+            const secs = Math.floor(seconds % 60);
+            return minutes + 'm' + secs + 's';
+        } else if (seconds < 86400) {
+            const hours = Math.floor(seconds / 3600);
+            const minutes = Math.floor((seconds % 3600) / 60);
+            return hours + 'h' + minutes + 'm';
+        } else {
+            const days = Math.floor(seconds / 86400);
+            const hours = Math.floor((seconds % 86400) / 3600);
+            return days + 'd' + hours + 'h';
+        }
+    }
+
+    formatDuration(seconds) {
+        if (seconds < 1) {
+            return Math.floor(seconds * 1000) + 'ms';
+        } else if (seconds < 60) {
+            return seconds.toFixed(1) + 's';
+        } else {
+            const minutes = Math.floor(seconds / 60);
+            const secs = Math.floor(seconds % 60);
+            return minutes + 'm' + secs + 's';
+        }
+    }
+
+    truncateString(str, maxLen) {
+        if (str.length <= maxLen) {
+            return str;
+        }
+        return str.substring(0, maxLen - 3) + '...';
+    }
+
+    truncateUrl(url, maxLen) {
+        if (url.length <= maxLen) {
+            return url;
+        }
+
+        // Try to preserve protocol and domain
+        const protocolEnd = url.indexOf('://');
+        if (protocolEnd === -1) {
+            return this.truncateString(url, maxLen);
+        }
+
+        const domainStart = protocolEnd + 3;
+        const pathStart = url.indexOf('/', domainStart);
+        if (pathStart === -1) {
+            return this.truncateString(url, maxLen);
+        }
+
+        const domain = url.substring(0, pathStart);
+        const path = url.substring(pathStart);
+
+        if (domain.length >= maxLen - 3) {
+            return this.truncateString(url, maxLen);
+        }
+
+        const remaining = maxLen - domain.length - 3;
+        if (remaining <= 0) {
+            return domain + '...';
+        }
+
+        if (path.length <= remaining) {
+            return url;
+        }
+
+        return domain + this.truncateString(path, remaining);
+    }
+
+    // Configuration Management Methods
+    async loadConfigs() {
+        try {
+            // Load all configurations
+            const configsResponse = await fetch('/api/configs');
+            const configsData = await configsResponse.json();
+
+            // Load active configuration
+            const activeResponse = await fetch('/api/configs/active');
+            const activeData = await activeResponse.json();
+
+            // Update current config display
+            const currentConfigName = document.getElementById('current-config-name');
+            if (activeData.activeConfig) {
+                currentConfigName.textContent = activeData.activeConfig.name;
+                currentConfigName.style.color = 'var(--success)';
+            } else {
+                currentConfigName.textContent = '未知';
+                currentConfigName.style.color = 'var(--danger)';
+            }
+
+            // Render config list
+            this.renderConfigList(configsData.configs, activeData.activeConfig);
+
+        } catch (error) {
+            console.error('Error loading configs:', error);
+            document.getElementById('current-config-name').textContent = '加载失败';
+            document.getElementById('current-config-name').style.color = 'var(--danger)';
+        }
+    }
+
+    renderConfigList(configs, activeConfig) {
+        const configList = document.getElementById('config-list');
+
+        if (!configs || configs.length === 0) {
+            configList.innerHTML = '<p style="color: var(--text-muted); text-align: center; padding: 20px;">暂无配置文件</p>';
+            return;
+        }
+
+        let html = '';
+        configs.forEach(config => {
+            const isActive = activeConfig && activeConfig.name === config.name;
+            const createdAt = new Date(config.createdAt).toLocaleString('zh-CN');
+
+            html += `
+                <div class="config-item ${isActive ? 'active' : ''}">
+                    <div class="config-info">
+                        <div class="config-name">${this.escapeHtml(config.name)} ${isActive ? '(当前)' : ''}</div>
+                        <div class="config-details">
+                            ${this.escapeHtml(config.description)} • 创建于 ${createdAt}
+                        </div>
+                    </div>
+                    <div class="config-actions">
+                        <button class="switch-btn" onclick="app.switchConfig('${this.escapeHtml(config.name)}')"
+                                ${isActive ? 'disabled' : ''}>
+                            ${isActive ? '当前配置' : '切换'}
+                        </button>
+                        <button class="rename-btn" onclick="app.openConfigEditor('${this.escapeHtml(config.name)}')">编辑</button>
+                        <button class="rename-btn" onclick="app.exportConfig('${this.escapeHtml(config.name)}')">导出</button>
+                        <button class="rename-btn" onclick="app.renameConfig('${this.escapeHtml(config.name)}')">
+                            重命名
+                        </button>
+                        <button class="delete-btn" onclick="app.deleteConfig('${this.escapeHtml(config.name)}')"
+                                ${isActive ? 'disabled' : ''}>
+                            删除
+                        </button>
+                    </div>
+                </div>
+            `;
+        });
+
+        configList.innerHTML = html;
+    }
+
+    async importConfig() {
+        const fileInput = document.getElementById('config-file');
+        const nameInput = document.getElementById('config-name');
+
+        const file = fileInput.files[0];
+        const configName = nameInput.value.trim();
+
+        if (!file) {
+            this.showMessage('❌ 请选择配置文件', 'error');
+            return;
+        }
+
+        if (!configName) {
+            this.showMessage('❌ 请输入配置名称', 'error');
+            return;
+        }
+
+        try {
+            const formData = new FormData();
+            formData.append('configFile', file);
+            formData.append('configName', configName);
+
+            const response = await fetch('/api/configs/import', {
+                method: 'POST',
+                headers: this.csrfHeaders(),
+                body: formData
+            });
+
+            const result = await response.json();
+
+            if (response.ok) {
+                this.showMessage('✅ 配置导入成功', 'success');
+                fileInput.value = '';
+                nameInput.value = '';
+                await this.loadConfigs();
+                if (result.warnings && result.warnings.length > 0 &&
+                    confirm('配置 "' + configName + '" 中有需要填写的凭据，切换到该配置前请先补全：\n\n' +
+                        this.formatCredentialWarnings(result.warnings) + '\n\n现在打开编辑器？')) {
+                    await this.openConfigEditor(configName);
+                }
+            } else {
+                this.showMessage('❌ 导入失败: ' + result.message, 'error');
+            }
+
+        } catch (error) {
+            console.error('Error importing config:', error);
+            this.showMessage('❌ 导入失败: ' + error.message, 'error');
+        }
+    }
+
+    async switchConfig(configName) {
+        if (!confirm('确定要切换到配置 "' + configName + '" 吗？')) {
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/configs/switch', {
+                method: 'POST',
+                headers: this.csrfHeaders({
+                    'Content-Type': 'application/json',
+                }),
+                body: JSON.stringify({ configName: configName })
+            });
+
+            const result = await response.json();
+
+            if (response.ok) {
+                this.showMessage('✅ 配置切换成功', 'success');
+                await this.loadConfigs();
+
+                // Wait a moment for backend configuration to fully switch
+                await new Promise(resolve => setTimeout(resolve, 1000));
+
+                // Force reload all tab data to reflect new configuration
+                await this.loadOverview();
+                await this.loadEndpoints();
+                await this.loadConfig();
+
+                // Also reload current tab data
+                await this.loadTabData(this.currentTab);
+
+                this.showMessage('🔄 数据已更新', 'success');
+            } else {
+                this.showMessage('❌ 切换失败: ' + result.message, 'error');
+            }
+
+        } catch (error) {
+            console.error('Error switching config:', error);
+            this.showMessage('❌ 切换失败: ' + error.message, 'error');
+        }
+    }
+
+    async clearOverrides() {
+        if (!confirm('确定要清除运行时优先级覆盖并恢复配置文件中的优先级吗？')) {
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/overrides', { method: 'DELETE', headers: this.csrfHeaders() });
+            if (!response.ok) throw new Error('请求失败');
+            const result = await response.json();
+            this.showMessage('✅ ' + result.message, 'success');
+            await this.loadConfig();
+        } catch (error) {
+            console.error('Error clearing overrides:', error);
+            this.showMessage('❌ 清除失败: ' + error.message, 'error');
+        }
+    }
+
+    async deleteConfig(configName) {
+        if (!confirm('确定要删除配置 "' + configName + '" 吗？此操作不可撤销。')) {
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/configs/delete', {
+                method: 'DELETE',
+                headers: this.csrfHeaders({
+                    'Content-Type': 'application/json',
+                }),
+                body: JSON.stringify({ configName: configName })
+            });
+
+            const result = await response.json();
+
+            if (response.ok) {
+                this.showMessage('✅ 配置删除成功', 'success');
+                await this.loadConfigs();
+            } else {
+                this.showMessage('❌ 删除失败: ' + result.message, 'error');
+            }
+
+        } catch (error) {
+            console.error('Error deleting config:', error);
+            this.showMessage('❌ 删除失败: ' + error.message, 'error');
+        }
+    }
+
+    async renameConfig(oldName) {
+        const newName = prompt('请输入新的配置名称:', oldName);
+        if (!newName || newName.trim() === '' || newName === oldName) {
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/configs/rename', {
+                method: 'PUT',
+                headers: this.csrfHeaders({
+                    'Content-Type': 'application/json',
+                }),
+                body: JSON.stringify({
+                    oldName: oldName,
+                    newName: newName.trim()
+                })
+            });
+
+            const result = await response.json();
+
+            if (response.ok) {
+                this.showMessage('✅ 配置重命名成功', 'success');
+                await this.loadConfigs();
+            } else {
+                this.showMessage('❌ 重命名失败: ' + result.message, 'error');
+            }
+
+        } catch (error) {
+            console.error('Error renaming config:', error);
+            this.showMessage('❌ 重命名失败: ' + error.message, 'error');
+        }
+    }
+
+    async openConfigEditor(name) {
+        try {
+            const resp = await fetch('/api/configs/content?name=' + encodeURIComponent(name));
+            if (!resp.ok) {
+                const t = await resp.text();
+                this.showMessage('读取配置失败: ' + t, 'error');
+                return;
+            }
+            const data = await resp.json();
+            this.editingConfigName = name;
+            document.getElementById('config-editor-title').textContent = '编辑配置: ' + name;
+            const editor = document.getElementById('config-editor-content');
+            editor.value = data.content || '';
+            // Any edit after a preview needs a new preview before saving
+            editor.oninput = () => this.resetConfigPreview();
+            this.resetConfigPreview();
+            document.getElementById('config-editor-error').style.display = 'none';
+            document.getElementById('config-editor-modal').style.display = 'flex';
+        } catch (e) {
+            this.showMessage('读取配置失败: ' + e.message, 'error');
+        }
+    }
+
+    closeConfigEditor() {
+        document.getElementById('config-editor-modal').style.display = 'none';
+        this.editingConfigName = null;
+    }
+
+    resetConfigPreview() {
+        this.configPreviewed = false;
+        document.getElementById('config-editor-preview').style.display = 'none';
+        document.getElementById('config-editor-save').textContent = '🔍 预览更改';
+    }
+
+    // showConfigFieldError shows a validation error and selects the failing line in the editor
+    showConfigFieldError(error) {
+        const errorBox = document.getElementById('config-editor-error');
+        let location = '';
+        if (error.line > 0) {
+            location += '第 ' + error.line + ' 行';
+        }
+        if (error.path) {
+            location += (location ? ' ' : '') + error.path;
+        }
+        errorBox.textContent = '❌ ' + (location ? location + ': ' : '') + error.message;
+        errorBox.style.display = 'block';
+        if (error.line > 0) {
+            const editor = document.getElementById('config-editor-content');
+            const lines = editor.value.split('\n');
+            const start = lines.slice(0, error.line - 1).reduce((sum, line) => sum + line.length + 1, 0);
+            const end = start + (lines[error.line - 1] || '').length;
+            editor.focus();
+            editor.setSelectionRange(start, end);
+            // Scroll the selected line into view
+            const lineHeight = editor.scrollHeight / Math.max(lines.length, 1);
+            editor.scrollTop = Math.max(0, (error.line - 3) * lineHeight);
+        }
+    }
+
+    // previewConfigEditor shows the diff against the saved file and the semantic changes
+    async previewConfigEditor() {
+        const name = this.editingConfigName;
+        const content = document.getElementById('config-editor-content').value;
+        const resp = await fetch('/api/configs/diff', {
+            method: 'POST',
+            headers: this.csrfHeaders({ 'Content-Type': 'application/json' }),
+            body: JSON.stringify({ name, content })
+        });
+        if (!resp.ok) {
+            throw new Error(await resp.text());
+        }
+        const result = await resp.json();
+        if (result.error) {
+            this.showConfigFieldError(result.error);
+            return;
+        }
+
+        const colors = { '+': '#22c55e', '-': 'var(--danger)', '@': 'var(--accent)' };
+        document.getElementById('config-editor-diff').innerHTML = result.changed
+            ? result.diff.split('\n').map(line =>
+                '<span style="color:' + (colors[line.charAt(0)] || 'var(--text-muted)') + '">' + this.escapeHtml(line) + '</span>').join('\n')
+            : '无更改';
+        document.getElementById('config-editor-changes').textContent = result.changes && result.changes.length > 0
+            ? '📋 生效后的变更: ' + result.changes.join(', ')
+            : '';
+        document.getElementById('config-editor-preview').style.display = 'block';
+        document.getElementById('config-editor-save').textContent = '💾 确认保存并应用';
+        this.configPreviewed = true;
+    }
+
+    async saveConfigEditor() {
+        const name = this.editingConfigName;
+        const content = document.getElementById('config-editor-content').value;
+        const errorBox = document.getElementById('config-editor-error');
+        errorBox.style.display = 'none';
+        try {
+            if (!this.configPreviewed) {
+                await this.previewConfigEditor();
+                return;
+            }
+            const resp = await fetch('/api/configs/content', {
+                method: 'PUT',
+                headers: this.csrfHeaders({ 'Content-Type': 'application/json' }),
+                body: JSON.stringify({ name, content })
+            });
+            if (!resp.ok) {
+                const text = await resp.text();
+                let result = null;
+                try { result = JSON.parse(text); } catch (e) {}
+                this.resetConfigPreview();
+                if (result && result.error) {
+                    this.showConfigFieldError(result.error);
+                } else {
+                    errorBox.textContent = text;
+                    errorBox.style.display = 'block';
+                }
+                return;
+            }
+            const result = await resp.json();
+            if (result.warnings && result.warnings.length > 0) {
+                // Saved, but keep the editor open so the missing credentials can be filled in
+                errorBox.textContent = '⚠️ 已保存，但以下凭据仍需填写：\n' + this.formatCredentialWarnings(result.warnings);
+                errorBox.style.display = 'block';
+                await this.loadConfigs();
+                return;
+            }
+            const changes = result.changes && result.changes.length > 0 ? ': ' + result.changes.join(', ') : '';
+            this.showMessage('配置保存成功' + (result.active ? '（已实时生效）' : '') + changes, 'success');
+            this.closeConfigEditor();
+            await this.loadConfigs();
+        } catch (e) {
+            errorBox.textContent = '保存失败: ' + e.message;
+            errorBox.style.display = 'block';
+        }
+    }
+
+    // formatCredentialWarnings lists redacted or empty credentials, one per line
+    formatCredentialWarnings(warnings) {
+        return warnings.map(w => '• ' + w.path + ' (第 ' + w.line + ' 行): ' +
+            (w.reason === 'redacted' ? '已脱敏，请填写真实值' : '为空字符串')).join('\n');
+    }
+
+    // exportRedactParam returns the redact query parameter from the export toggle
+    exportRedactParam() {
+        const toggle = document.getElementById('export-redact');
+        return 'redact=' + (toggle && !toggle.checked ? 'false' : 'true');
+    }
+
+    async exportConfig(name) {
+        try {
+            const redactParam = this.exportRedactParam();
+            const resp = await fetch('/api/configs/export?name=' + encodeURIComponent(name) + '&' + redactParam);
+            if (!resp.ok) {
+                // Exporting secrets is refused while the WebUI has no login
+                this.showMessage('导出失败' + (resp.status === 403 ? ': ' + (await resp.text()).trim() : ''), 'error');
+                return;
+            }
+            const blob = await resp.blob();
+            const a = document.createElement('a');
+            a.href = URL.createObjectURL(blob);
+            a.download = name + (redactParam === 'redact=true' ? '.redacted.yaml' : '.yaml');
+            document.body.appendChild(a);
+            a.click();
+            a.remove();
+            URL.revokeObjectURL(a.href);
+        } catch (e) {
+            this.showMessage('导出失败: ' + e.message, 'error');
+        }
+    }
+
+    async exportAllConfigs() {
+        try {
+            const redactParam = this.exportRedactParam();
+            const resp = await fetch('/api/configs/export-all?' + redactParam);
+            if (!resp.ok) {
+                this.showMessage('批量导出失败' + (resp.status === 403 ? ': ' + (await resp.text()).trim() : ''), 'error');
+                return;
+            }
+            const blob = await resp.blob();
+            const a = document.createElement('a');
+            a.href = URL.createObjectURL(blob);
+            a.download = 'configs_' + Date.now() + (redactParam === 'redact=true' ? '.redacted' : '') + '.zip';
+            document.body.appendChild(a);
+            a.click();
+            a.remove();
+            URL.revokeObjectURL(a.href);
+        } catch (e) {
+            this.showMessage('批量导出失败: ' + e.message, 'error');
+        }
+    }
+
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
+}
+
+// Initialize the app when DOM is loaded
+let app;
+document.addEventListener('DOMContentLoaded', () => {
+    app = new WebUIApp();
+});
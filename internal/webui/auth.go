@@ -2,15 +2,35 @@ package webui
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/clientip"
+)
+
+const (
+	sessionCookieName = "webui_session"
+	csrfCookieName    = "webui_csrf"
+	csrfHeaderName    = "X-CSRF-Token"
+
+	// loginAttemptWindow is the window in which failed logins are counted
+	loginAttemptWindow = time.Minute
 )
 
 // Session represents a user session
 type Session struct {
 	ID        string
+	User      string // Name of the webui.users entry that logged in, empty for the shared password
+	CSRFToken string // Required in the X-CSRF-Token header of state-changing API requests
 	CreatedAt time.Time
 	LastSeen  time.Time
 }
@@ -20,6 +40,7 @@ type SessionManager struct {
 	sessions map[string]*Session
 	mutex    sync.RWMutex
 	ttl      time.Duration
+	maxAge   time.Duration // Lifetime since login regardless of activity, 0 = unlimited
 }
 
 // NewSessionManager creates a new session manager
@@ -35,46 +56,56 @@ func NewSessionManager(ttl time.Duration) *SessionManager {
 	return sm
 }
 
-// CreateSession creates a new session
-func (sm *SessionManager) CreateSession() string {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
-
-	// Generate random session ID
+// randomToken returns a random hex token
+func randomToken() string {
 	bytes := make([]byte, 16)
 	rand.Read(bytes)
-	sessionID := hex.EncodeToString(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// CreateSession creates a new session for a user
+func (sm *SessionManager) CreateSession(user string) Session {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 
 	// Create session
 	session := &Session{
-		ID:        sessionID,
+		ID:        randomToken(),
+		User:      user,
+		CSRFToken: randomToken(),
 		CreatedAt: time.Now(),
 		LastSeen:  time.Now(),
 	}
 
-	sm.sessions[sessionID] = session
-	return sessionID
+	sm.sessions[session.ID] = session
+	return *session
 }
 
-// ValidateSession validates a session and updates last seen time
-func (sm *SessionManager) ValidateSession(sessionID string) bool {
+// GetSession validates a session, renews its expiry and returns a copy of it
+func (sm *SessionManager) GetSession(sessionID string) (Session, bool) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
 	session, exists := sm.sessions[sessionID]
 	if !exists {
-		return false
+		return Session{}, false
 	}
 
 	// Check if session has expired
-	if time.Since(session.LastSeen) > sm.ttl {
+	if sm.expired(session, time.Now()) {
 		delete(sm.sessions, sessionID)
-		return false
+		return Session{}, false
 	}
 
 	// Update last seen time
 	session.LastSeen = time.Now()
-	return true
+	return *session, true
+}
+
+// ValidateSession validates a session and updates last seen time
+func (sm *SessionManager) ValidateSession(sessionID string) bool {
+	_, ok := sm.GetSession(sessionID)
+	return ok
 }
 
 // DeleteSession deletes a session
@@ -85,6 +116,62 @@ func (sm *SessionManager) DeleteSession(sessionID string) {
 	delete(sm.sessions, sessionID)
 }
 
+// DeleteUserSessions deletes every session of a user and returns how many were deleted
+func (sm *SessionManager) DeleteUserSessions(user string) int {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	deleted := 0
+	for id, session := range sm.sessions {
+		if session.User == user {
+			delete(sm.sessions, id)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Clear deletes all sessions
+func (sm *SessionManager) Clear() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.sessions = make(map[string]*Session)
+}
+
+// SetTTL changes the session TTL; existing sessions are checked against the new value
+func (sm *SessionManager) SetTTL(ttl time.Duration) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.ttl = ttl
+}
+
+// SetMaxAge changes the session lifetime since login; existing sessions are checked against the new value
+func (sm *SessionManager) SetMaxAge(maxAge time.Duration) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.maxAge = maxAge
+}
+
+// expired reports whether a session has been idle longer than the TTL or has outlived
+// the max age. The caller must hold the lock.
+func (sm *SessionManager) expired(session *Session, now time.Time) bool {
+	if now.Sub(session.LastSeen) > sm.ttl {
+		return true
+	}
+	return sm.maxAge > 0 && now.Sub(session.CreatedAt) > sm.maxAge
+}
+
+// TTL returns the session TTL
+func (sm *SessionManager) TTL() time.Duration {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.ttl
+}
+
 // cleanup removes expired sessions
 func (sm *SessionManager) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
@@ -94,7 +181,7 @@ func (sm *SessionManager) cleanup() {
 		sm.mutex.Lock()
 		now := time.Now()
 		for id, session := range sm.sessions {
-			if now.Sub(session.LastSeen) > sm.ttl {
+			if sm.expired(session, now) {
 				delete(sm.sessions, id)
 			}
 		}
@@ -102,49 +189,234 @@ func (sm *SessionManager) cleanup() {
 	}
 }
 
+// loginAttempts tracks failed logins from one IP
+type loginAttempts struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// LoginLimiter locks out IPs that fail to log in too often
+type LoginLimiter struct {
+	attempts    map[string]*loginAttempts
+	mutex       sync.Mutex
+	maxAttempts int
+	lockout     time.Duration
+}
+
+// NewLoginLimiter creates a login limiter allowing maxAttempts failures per minute per IP
+func NewLoginLimiter(maxAttempts int, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		attempts:    make(map[string]*loginAttempts),
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+	}
+}
+
+// UpdateConfig changes the limits; current lockouts are kept
+func (ll *LoginLimiter) UpdateConfig(maxAttempts int, lockout time.Duration) {
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+
+	ll.maxAttempts = maxAttempts
+	ll.lockout = lockout
+}
+
+// Locked returns how long the IP remains locked out, or zero if it may try to log in
+func (ll *LoginLimiter) Locked(ip string) time.Duration {
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+
+	attempts, exists := ll.attempts[ip]
+	if !exists {
+		return 0
+	}
+	if remaining := time.Until(attempts.lockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RecordFailure records a failed login and reports whether the IP is now locked out
+func (ll *LoginLimiter) RecordFailure(ip string) bool {
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+
+	now := time.Now()
+	attempts, exists := ll.attempts[ip]
+	if !exists || now.Sub(attempts.windowStart) > loginAttemptWindow {
+		attempts = &loginAttempts{windowStart: now}
+		ll.attempts[ip] = attempts
+	}
+
+	attempts.failures++
+	if attempts.failures >= ll.maxAttempts {
+		attempts.lockedUntil = now.Add(ll.lockout)
+		attempts.failures = 0
+		attempts.windowStart = now
+		return true
+	}
+
+	// Drop stale entries so the map does not grow with every scanning IP
+	for key, other := range ll.attempts {
+		if now.Sub(other.windowStart) > loginAttemptWindow && now.After(other.lockedUntil) {
+			delete(ll.attempts, key)
+		}
+	}
+	return false
+}
+
+// RecordSuccess forgets the failures of an IP after a successful login
+func (ll *LoginLimiter) RecordSuccess(ip string) {
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+
+	delete(ll.attempts, ip)
+}
+
 // AuthMiddleware provides authentication for WebUI
 type AuthMiddleware struct {
 	password       string
+	users          map[string]string // webui.users passwords keyed by name
+	mutex          sync.RWMutex
 	sessionManager *SessionManager
+	loginLimiter   *LoginLimiter
+	clientIPs      atomic.Pointer[clientip.Resolver] // Trusted proxies (server.trusted_proxies), nil trusts none
 }
 
 // NewAuthMiddleware creates a new auth middleware
-func NewAuthMiddleware(password string) *AuthMiddleware {
-	return &AuthMiddleware{
-		password:       password,
-		sessionManager: NewSessionManager(24 * time.Hour), // 24 hour session
+func NewAuthMiddleware(cfg config.WebUIConfig) *AuthMiddleware {
+	am := &AuthMiddleware{
+		password:       cfg.Password,
+		users:          userPasswords(cfg.Users),
+		sessionManager: NewSessionManager(cfg.SessionTTL),
+		loginLimiter:   NewLoginLimiter(cfg.LoginMaxAttempts, cfg.LoginLockout),
 	}
+	am.sessionManager.SetMaxAge(cfg.SessionMaxAge)
+	return am
 }
 
-// UpdateConfig updates the auth middleware configuration
-func (am *AuthMiddleware) UpdateConfig(password string) {
-	am.password = password
+// userPasswords indexes webui.users by name
+func userPasswords(users []config.WebUIUser) map[string]string {
+	passwords := make(map[string]string, len(users))
+	for _, user := range users {
+		passwords[user.Name] = user.Password
+	}
+	return passwords
+}
+
+// UpdateConfig updates the auth middleware configuration. Changing the shared password logs
+// out every session; changing or removing a user logs out that user's sessions.
+func (am *AuthMiddleware) UpdateConfig(cfg config.WebUIConfig) {
+	users := userPasswords(cfg.Users)
+	am.mutex.Lock()
+	passwordChanged := am.password != cfg.Password
+	var changedUsers []string
+	for name, password := range am.users {
+		if newPassword, ok := users[name]; !ok || newPassword != password {
+			changedUsers = append(changedUsers, name)
+		}
+	}
+	am.password = cfg.Password
+	am.users = users
+	am.mutex.Unlock()
+
+	if passwordChanged {
+		am.sessionManager.Clear()
+		slog.Info("🔐 [WebUI认证] 密码已变更，所有会话已失效")
+	} else {
+		for _, name := range changedUsers {
+			if am.sessionManager.DeleteUserSessions(name) > 0 {
+				slog.Info(fmt.Sprintf("🔐 [WebUI认证] 用户 %s 的密码已变更或已删除，其会话已失效", name))
+			}
+		}
+	}
+	am.sessionManager.SetTTL(cfg.SessionTTL)
+	am.sessionManager.SetMaxAge(cfg.SessionMaxAge)
+	am.loginLimiter.UpdateConfig(cfg.LoginMaxAttempts, cfg.LoginLockout)
+}
+
+// authEnabled reports whether a login is required
+func (am *AuthMiddleware) authEnabled() bool {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	return am.password != "" || len(am.users) > 0
+}
+
+// hasUsers reports whether named users are configured, so the login page asks for a username
+func (am *AuthMiddleware) hasUsers() bool {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	return len(am.users) > 0
+}
+
+// authenticate checks a login. A username selects a webui.users entry; without one the
+// shared password is checked.
+func (am *AuthMiddleware) authenticate(username, password string) bool {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	expected := am.password
+	if username != "" {
+		var ok bool
+		if expected, ok = am.users[username]; !ok {
+			return false
+		}
+	}
+	return expected != "" && subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+}
+
+// requiresCSRF reports whether a request changes state and must carry the CSRF token
+func requiresCSRF(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
 }
 
 // RequireAuth checks if authentication is required and validates session
 func (am *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// If no password is set, no authentication required
-		if am.password == "" {
+		// If no password or user is set, no authentication required
+		if !am.authEnabled() {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		// Check for session cookie
-		cookie, err := r.Cookie("webui_session")
-		if err != nil || !am.sessionManager.ValidateSession(cookie.Value) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		session, ok := am.sessionManager.GetSession(cookie.Value)
+		if !ok {
 			// Redirect to login page
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
 
+		// Cookies are sent with cross-site requests too, so state changes also need the token
+		if requiresCSRF(r) {
+			token := r.Header.Get(csrfHeaderName)
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+				slog.Warn(fmt.Sprintf("🛡️ [WebUI认证] 拒绝缺少有效CSRF令牌的请求: %s %s (来源: %s)", r.Method, r.URL.Path, am.clientIP(r)))
+				http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+
 		next.ServeHTTP(w, r)
 	}
 }
 
 // HandleLogin handles login requests
 func (am *AuthMiddleware) HandleLogin(w http.ResponseWriter, r *http.Request) {
-	if am.password == "" {
+	if !am.authEnabled() {
 		// No authentication required, redirect to main page
 		http.Redirect(w, r, "/", http.StatusFound)
 		return
@@ -153,39 +425,68 @@ func (am *AuthMiddleware) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		// Show login page
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte(loginHTML))
+		w.Write([]byte(am.loginPage(loginHTML)))
 		return
 	}
 
 	if r.Method == "POST" {
+		ip := am.clientIP(r)
+		if remaining := am.loginLimiter.Locked(ip); remaining > 0 {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Round(time.Second).Seconds())))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(am.loginPage(loginHTMLLocked)))
+			return
+		}
+
 		// Process login
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Invalid form data", http.StatusBadRequest)
 			return
 		}
 
-		password := r.FormValue("password")
-		if password != am.password {
+		username := strings.TrimSpace(r.FormValue("username"))
+		if !am.authenticate(username, r.FormValue("password")) {
+			if am.loginLimiter.RecordFailure(ip) {
+				slog.Warn(fmt.Sprintf("🔒 [WebUI认证] 登录失败次数过多，已锁定 %s", ip))
+			} else {
+				slog.Warn(fmt.Sprintf("⚠️ [WebUI认证] 登录失败: 密码错误 (来源: %s)", ip))
+			}
 			// Show login page with error
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write([]byte(loginHTMLWithError))
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(am.loginPage(loginHTMLWithError)))
 			return
 		}
+		am.loginLimiter.RecordSuccess(ip)
+		if username != "" {
+			slog.Info(fmt.Sprintf("🔐 [WebUI认证] 用户 %s 登录成功 (来源: %s)", username, ip))
+		}
 
 		// Create session
-		sessionID := am.sessionManager.CreateSession()
+		session := am.sessionManager.CreateSession(username)
+		maxAge := int(am.sessionManager.TTL().Seconds())
+		secure := r.TLS != nil
 
 		// Set session cookie
-		cookie := &http.Cookie{
-			Name:     "webui_session",
-			Value:    sessionID,
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    session.ID,
 			Path:     "/",
 			HttpOnly: true,
-			Secure:   false, // Set to true if using HTTPS
-			SameSite: http.SameSiteLaxMode,
-			MaxAge:   24 * 60 * 60, // 24 hours
-		}
-		http.SetCookie(w, cookie)
+			Secure:   secure,
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   maxAge,
+		})
+		// The CSRF token is readable by the page script, which echoes it in a header
+		http.SetCookie(w, &http.Cookie{
+			Name:     csrfCookieName,
+			Value:    session.CSRFToken,
+			Path:     "/",
+			Secure:   secure,
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   maxAge,
+		})
 
 		// Redirect to main page
 		http.Redirect(w, r, "/", http.StatusFound)
@@ -198,22 +499,48 @@ func (am *AuthMiddleware) HandleLogin(w http.ResponseWriter, r *http.Request) {
 // HandleLogout handles logout requests
 func (am *AuthMiddleware) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	// Get session cookie
-	cookie, err := r.Cookie("webui_session")
+	cookie, err := r.Cookie(sessionCookieName)
 	if err == nil {
 		// Delete session
 		am.sessionManager.DeleteSession(cookie.Value)
 	}
 
-	// Clear session cookie
-	clearCookie := &http.Cookie{
-		Name:     "webui_session",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1, // Delete cookie
+	// Clear session cookies
+	for _, name := range []string{sessionCookieName, csrfCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			HttpOnly: name == sessionCookieName,
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   -1, // Delete cookie
+		})
 	}
-	http.SetCookie(w, clearCookie)
 
 	// Redirect to login page
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
+
+// loginPage adds the username field to a login page when named users are configured
+func (am *AuthMiddleware) loginPage(page string) string {
+	if !am.hasUsers() {
+		return page
+	}
+	page = strings.Replace(page, loginPasswordField, loginUsernameField+loginPasswordField, 1)
+	return strings.Replace(page, `name="password" required autofocus>`, `name="password" required>`, 1)
+}
+
+// SetTrustedProxies sets the proxies whose forwarding headers give the client IP used for
+// login lockouts and logs
+func (am *AuthMiddleware) SetTrustedProxies(cidrs []string) {
+	resolver, err := clientip.New(cidrs)
+	if err != nil {
+		resolver = nil
+	}
+	am.clientIPs.Store(resolver)
+}
+
+// clientIP returns the IP of the client, honoring forwarding headers from trusted proxies
+func (am *AuthMiddleware) clientIP(r *http.Request) string {
+	return am.clientIPs.Load().ClientIP(r)
+}
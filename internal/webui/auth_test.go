@@ -0,0 +1,231 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+)
+
+func newTestAuth(password string) *AuthMiddleware {
+	return NewAuthMiddleware(config.WebUIConfig{
+		Password:         password,
+		SessionTTL:       time.Hour,
+		LoginMaxAttempts: 3,
+		LoginLockout:     time.Minute,
+	})
+}
+
+func login(t *testing.T, am *AuthMiddleware, remoteAddr, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"password": {password}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	am.HandleLogin(rec, req)
+	return rec
+}
+
+func cookieValue(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestSessionExpiry(t *testing.T) {
+	am := newTestAuth("secret")
+	rec := login(t, am, "10.0.0.1:1234", "secret")
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected redirect after login, got %d", rec.Code)
+	}
+	session := cookieValue(rec, sessionCookieName)
+	if session == nil {
+		t.Fatal("Expected session cookie")
+	}
+	if !session.HttpOnly || session.SameSite != http.SameSiteStrictMode || session.MaxAge != int(time.Hour.Seconds()) {
+		t.Errorf("Unexpected session cookie attributes: %+v", session)
+	}
+
+	// Sliding renewal: using the session moves LastSeen forward
+	am.sessionManager.sessions[session.Value].LastSeen = time.Now().Add(-50 * time.Minute)
+	if !am.sessionManager.ValidateSession(session.Value) {
+		t.Fatal("Expected session within TTL to be valid")
+	}
+	if since := time.Since(am.sessionManager.sessions[session.Value].LastSeen); since > time.Second {
+		t.Errorf("Expected LastSeen to be renewed, still %v old", since)
+	}
+
+	// Idle beyond the TTL expires the session
+	am.sessionManager.sessions[session.Value].LastSeen = time.Now().Add(-61 * time.Minute)
+	req := httptest.NewRequest("GET", "/api/overview", nil)
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	am.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expired session must not reach the handler")
+	})(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login" {
+		t.Errorf("Expected redirect to login for expired session, got %d", rec.Code)
+	}
+
+	// Changing the password on reload logs out every session
+	other := cookieValue(login(t, am, "10.0.0.1:1234", "secret"), sessionCookieName)
+	am.UpdateConfig(config.WebUIConfig{Password: "changed", SessionTTL: time.Hour, LoginMaxAttempts: 3, LoginLockout: time.Minute})
+	if am.sessionManager.ValidateSession(other.Value) {
+		t.Error("Expected sessions to be invalidated after password change")
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	am := newTestAuth("secret")
+
+	for i := 0; i < 3; i++ {
+		if rec := login(t, am, "10.0.0.2:1234", "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i+1, rec.Code)
+		}
+	}
+
+	// Locked out, even with the right password
+	rec := login(t, am, "10.0.0.2:5678", "secret")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 while locked out, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header while locked out")
+	}
+	if cookieValue(rec, sessionCookieName) != nil {
+		t.Error("Locked out login must not create a session")
+	}
+
+	// Other IPs are unaffected
+	if rec := login(t, am, "10.0.0.3:1234", "secret"); rec.Code != http.StatusFound {
+		t.Errorf("Expected login from another IP to succeed, got %d", rec.Code)
+	}
+
+	// The lockout ends after the configured duration
+	am.loginLimiter.attempts["10.0.0.2"].lockedUntil = time.Now().Add(-time.Second)
+	if rec := login(t, am, "10.0.0.2:1234", "secret"); rec.Code != http.StatusFound {
+		t.Errorf("Expected login after lockout to succeed, got %d", rec.Code)
+	}
+}
+
+func TestCSRFRequiredForStateChanges(t *testing.T) {
+	am := newTestAuth("secret")
+	rec := login(t, am, "10.0.0.4:1234", "secret")
+	session := cookieValue(rec, sessionCookieName)
+	csrf := cookieValue(rec, csrfCookieName)
+	if session == nil || csrf == nil {
+		t.Fatal("Expected session and CSRF cookies")
+	}
+	if csrf.HttpOnly {
+		t.Error("CSRF cookie must be readable by the page script")
+	}
+
+	called := 0
+	handler := am.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	})
+
+	// A cross-site form post carries the cookie but not the header
+	req := httptest.NewRequest("POST", "/api/config/save", strings.NewReader("{}"))
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden || called != 0 {
+		t.Fatalf("Expected 403 without CSRF header, got %d", rec.Code)
+	}
+
+	// A wrong token is rejected as well
+	req = httptest.NewRequest("DELETE", "/api/configs/delete", nil)
+	req.AddCookie(session)
+	req.Header.Set(csrfHeaderName, "not-the-token")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden || called != 0 {
+		t.Fatalf("Expected 403 with wrong CSRF token, got %d", rec.Code)
+	}
+
+	// Reads do not need the token
+	req = httptest.NewRequest("GET", "/api/overview", nil)
+	req.AddCookie(session)
+	handler(httptest.NewRecorder(), req)
+	if called != 1 {
+		t.Fatalf("Expected GET to pass without CSRF header")
+	}
+
+	// The same-origin app sends the token
+	req = httptest.NewRequest("POST", "/api/config/save", strings.NewReader("{}"))
+	req.AddCookie(session)
+	req.Header.Set(csrfHeaderName, csrf.Value)
+	handler(httptest.NewRecorder(), req)
+	if called != 2 {
+		t.Errorf("Expected POST with CSRF token to pass")
+	}
+}
+
+func TestNamedUsers(t *testing.T) {
+	cfg := config.WebUIConfig{
+		Users:            []config.WebUIUser{{Name: "alice", Password: "alice-pw"}, {Name: "bob", Password: "bob-pw"}},
+		SessionTTL:       time.Hour,
+		SessionMaxAge:    8 * time.Hour,
+		LoginMaxAttempts: 3,
+		LoginLockout:     time.Minute,
+	}
+	am := NewAuthMiddleware(cfg)
+	loginAs := func(username, password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "10.0.0.4:1234"
+		rec := httptest.NewRecorder()
+		am.HandleLogin(rec, req)
+		return rec
+	}
+
+	// The login page asks for a username
+	page := httptest.NewRecorder()
+	am.HandleLogin(page, httptest.NewRequest("GET", "/login", nil))
+	if !strings.Contains(page.Body.String(), `name="username"`) {
+		t.Error("Expected the login page to have a username field")
+	}
+
+	// Each user logs in with their own password only
+	if rec := loginAs("alice", "bob-pw"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected another user's password to be rejected, got %d", rec.Code)
+	}
+	if rec := loginAs("", "alice-pw"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a login without username to be rejected when no shared password is set, got %d", rec.Code)
+	}
+	alice := cookieValue(loginAs("alice", "alice-pw"), sessionCookieName)
+	bob := cookieValue(loginAs("bob", "bob-pw"), sessionCookieName)
+	if alice == nil || bob == nil {
+		t.Fatal("Expected both users to log in")
+	}
+	if session, _ := am.sessionManager.GetSession(alice.Value); session.User != "alice" {
+		t.Errorf("Expected the session to belong to alice, got %q", session.User)
+	}
+
+	// Sessions expire after session_max_age even while in use
+	am.sessionManager.sessions[bob.Value].CreatedAt = time.Now().Add(-9 * time.Hour)
+	if am.sessionManager.ValidateSession(bob.Value) {
+		t.Error("Expected a session older than session_max_age to expire")
+	}
+	bob = cookieValue(loginAs("bob", "bob-pw"), sessionCookieName)
+
+	// Changing one user's password only logs out that user
+	cfg.Users = []config.WebUIUser{{Name: "alice", Password: "new-pw"}, {Name: "bob", Password: "bob-pw"}}
+	am.UpdateConfig(cfg)
+	if am.sessionManager.ValidateSession(alice.Value) {
+		t.Error("Expected alice's session to end when that user's password changed")
+	}
+	if !am.sessionManager.ValidateSession(bob.Value) {
+		t.Error("Expected bob's session to survive another user's password change")
+	}
+}
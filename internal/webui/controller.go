@@ -0,0 +1,94 @@
+package webui
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"endpoint_forwarder/config"
+)
+
+// Controller owns the WebUI server across config reloads: it starts the server when
+// webui.enabled is switched on, stops it when switched off and restarts it when the
+// listen address or TLS settings change
+type Controller struct {
+	mutex     sync.Mutex
+	server    *WebUIServer
+	newServer func(cfg *config.Config) *WebUIServer
+}
+
+// NewController creates a controller that builds servers with newServer
+func NewController(newServer func(cfg *config.Config) *WebUIServer) *Controller {
+	return &Controller{newServer: newServer}
+}
+
+// Apply brings the WebUI server in line with cfg
+func (c *Controller) Apply(cfg *config.Config) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.server != nil && cfg.WebUI.Enabled && c.server.IsRunning() && c.server.server.Addr == listenAddress(cfg) && c.server.tls == cfg.WebUI.TLS {
+		c.server.UpdateConfig(cfg)
+		return nil
+	}
+
+	if c.server != nil {
+		if cfg.WebUI.Enabled {
+			slog.Info(fmt.Sprintf("🌐 WebUI监听地址或TLS配置已变更，正在重启WebUI服务器 - 新地址: %s", listenAddress(cfg)))
+		} else {
+			slog.Info("🌐 WebUI已在配置中禁用，正在停止WebUI服务器")
+		}
+		c.stopInBackground(c.server)
+		c.server = nil
+	}
+	if !cfg.WebUI.Enabled {
+		return nil
+	}
+
+	server := c.newServer(cfg)
+	if err := server.Start(); err != nil {
+		return err
+	}
+	c.server = server
+	return nil
+}
+
+// stopInBackground shuts a server down without waiting for its open requests. The reload
+// may have been triggered by a request to that very server (switching configs in the WebUI),
+// which Shutdown would otherwise wait for. Listeners are closed right away either way.
+func (c *Controller) stopInBackground(server *WebUIServer) {
+	go func() {
+		if err := server.Stop(); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ 停止WebUI服务器失败: %v", err))
+		}
+	}()
+}
+
+// Server returns the running WebUI server, or nil when the WebUI is disabled
+func (c *Controller) Server() *WebUIServer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.server
+}
+
+// Stop stops the WebUI server if it is running
+func (c *Controller) Stop() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.server == nil {
+		return nil
+	}
+	err := c.server.Stop()
+	c.server = nil
+	return err
+}
+
+// listenAddress returns the address the WebUI server listens on for cfg, the listen setting
+// when it serves on a Unix socket or systemd sockets
+func listenAddress(cfg *config.Config) string {
+	if cfg.WebUI.Listen != "" {
+		return cfg.WebUI.Listen
+	}
+	return fmt.Sprintf("%s:%d", cfg.WebUI.Host, cfg.WebUI.Port)
+}
@@ -0,0 +1,160 @@
+package webui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/logging"
+	"endpoint_forwarder/internal/middleware"
+)
+
+// freePort returns a local port that is currently unused
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// newTestController builds servers reading the shared buffer, without touching the config directory
+func newTestController(buffer *logging.Buffer) *Controller {
+	return NewController(func(cfg *config.Config) *WebUIServer {
+		return &WebUIServer{
+			cfg:            cfg,
+			startTime:      time.Now(),
+			logger:         slog.Default(),
+			logBuffer:      buffer,
+			authMiddleware: NewAuthMiddleware(cfg.WebUI),
+			corsMiddleware: middleware.NewCORSMiddleware(cfg.Server.CORS),
+		}
+	})
+}
+
+func webUIConfig(enabled bool, port int) *config.Config {
+	return &config.Config{WebUI: config.WebUIConfig{Enabled: enabled, Host: "127.0.0.1", Port: port}}
+}
+
+// fetchLogs returns the messages served by /api/logs, or an error if the WebUI is not reachable
+func fetchLogs(port int) ([]string, error) {
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/api/logs", port))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Logs []logging.Entry `json:"logs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	var messages []string
+	for _, entry := range payload.Logs {
+		messages = append(messages, entry.Message)
+	}
+	return messages, nil
+}
+
+// waitUnreachable waits for the WebUI listener to close
+func waitUnreachable(t *testing.T, port int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := fetchLogs(port); err != nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected the WebUI on port %d to be stopped", port)
+}
+
+func TestControllerEnableDisable(t *testing.T) {
+	buffer := logging.NewBuffer(logging.DefaultBufferSize)
+	controller := newTestController(buffer)
+	defer controller.Stop()
+	port := freePort(t)
+
+	// Disabled at startup: nothing listens, but logs are still collected
+	if err := controller.Apply(webUIConfig(false, port)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if controller.Server() != nil {
+		t.Fatal("Expected no WebUI server while disabled")
+	}
+	buffer.AddLog("INFO", "logged before the WebUI started", "system")
+
+	// Enabled by a reload: the late-started WebUI shows the earlier history
+	if err := controller.Apply(webUIConfig(true, port)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	server := controller.Server()
+	if server == nil || !server.IsRunning() {
+		t.Fatal("Expected the WebUI server to be started")
+	}
+	messages, err := fetchLogs(port)
+	if err != nil {
+		t.Fatalf("Expected the WebUI to be reachable: %v", err)
+	}
+	if !strings.Contains(strings.Join(messages, "\n"), "logged before the WebUI started") {
+		t.Errorf("Expected earlier logs in the late-started WebUI, got %v", messages)
+	}
+
+	// Reloading with unchanged settings keeps the same server
+	if err := controller.Apply(webUIConfig(true, port)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if controller.Server() != server {
+		t.Error("Expected the running server to be kept when the address is unchanged")
+	}
+
+	// Disabled by a reload: the server stops
+	if err := controller.Apply(webUIConfig(false, port)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if controller.Server() != nil {
+		t.Error("Expected no WebUI server after disabling")
+	}
+	waitUnreachable(t, port)
+
+	// Enabled again on the same port, still with the full history
+	buffer.AddLog("WARN", "logged while the WebUI was disabled", "system")
+	if err := controller.Apply(webUIConfig(true, port)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	messages, err = fetchLogs(port)
+	if err != nil {
+		t.Fatalf("Expected the re-enabled WebUI to be reachable: %v", err)
+	}
+	joined := strings.Join(messages, "\n")
+	if !strings.Contains(joined, "logged before the WebUI started") || !strings.Contains(joined, "logged while the WebUI was disabled") {
+		t.Errorf("Expected the full history after re-enabling, got %v", messages)
+	}
+}
+
+func TestControllerRestartsOnAddressChange(t *testing.T) {
+	controller := newTestController(logging.NewBuffer(logging.DefaultBufferSize))
+	defer controller.Stop()
+	oldPort, newPort := freePort(t), freePort(t)
+
+	if err := controller.Apply(webUIConfig(true, oldPort)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := controller.Apply(webUIConfig(true, newPort)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := fetchLogs(newPort); err != nil {
+		t.Fatalf("Expected the WebUI on the new port: %v", err)
+	}
+	waitUnreachable(t, oldPort)
+}
@@ -0,0 +1,146 @@
+package webui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is how many unchanged lines surround each change in a unified diff
+const diffContextLines = 3
+
+// maxDiffCells bounds the line comparison table; larger inputs are shown as fully replaced
+const maxDiffCells = 4_000_000
+
+// diffLine is one line of a diff: ' ' unchanged, '-' removed or '+' added
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns a unified diff from oldText to newText, empty when they are equal
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(lines); {
+		// Find the next change and extend the hunk while changes are close together
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first; i < len(lines) && i <= last+2*diffContextLines; i++ {
+			if lines[i].op != ' ' {
+				last = i
+			}
+		}
+		from := max(first-diffContextLines, start)
+		to := min(last+diffContextLines+1, len(lines))
+		writeHunk(&b, lines, from, to)
+		start = to
+	}
+	return b.String()
+}
+
+// writeHunk writes lines[from:to] with its @@ header
+func writeHunk(b *strings.Builder, lines []diffLine, from, to int) {
+	oldLine, newLine := 1, 1
+	for _, line := range lines[:from] {
+		if line.op != '+' {
+			oldLine++
+		}
+		if line.op != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, line := range lines[from:to] {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+	// An empty side is numbered after the line it follows
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	for _, line := range lines[from:to] {
+		b.WriteByte(line.op)
+		b.WriteString(line.text)
+		b.WriteByte('\n')
+	}
+}
+
+// diffLines aligns two line lists on their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	if len(a)*len(b) > maxDiffCells {
+		lines := make([]diffLine, 0, len(a)+len(b))
+		for _, text := range a {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range b {
+			lines = append(lines, diffLine{'+', text})
+		}
+		return lines
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+// splitLines splits text into lines without their line breaks
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+}
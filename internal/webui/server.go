@@ -8,111 +8,28 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/certs"
+	"endpoint_forwarder/internal/diagnostics"
 	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/listen"
+	"endpoint_forwarder/internal/logging"
 	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/notify"
 	"endpoint_forwarder/internal/monitor"
+	"endpoint_forwarder/internal/proxy"
 
-	yaml "gopkg.in/yaml.v3"
 )
 
-// LogEntry represents a log entry for WebUI
-type LogEntry struct {
-	Timestamp string `json:"timestamp"`
-	Level     string `json:"level"`
-	Source    string `json:"source"`
-	Message   string `json:"message"`
-}
-
-// LogCollector collects and manages logs for WebUI display
-type LogCollector struct {
-	logs        []LogEntry
-	maxLogs     int
-	mutex       sync.RWMutex
-	subscribers []chan LogEntry
-}
-
-// NewLogCollector creates a new log collector
-func NewLogCollector(maxLogs int) *LogCollector {
-	return &LogCollector{
-		logs:        make([]LogEntry, 0, maxLogs),
-		maxLogs:     maxLogs,
-		subscribers: make([]chan LogEntry, 0),
-	}
-}
-
-// AddLog adds a new log entry and notifies subscribers
-func (lc *LogCollector) AddLog(level, message, source string) {
-	lc.mutex.Lock()
-	defer lc.mutex.Unlock()
-
-	entry := LogEntry{
-		Timestamp: time.Now().Format("15:04:05"),
-		Level:     level,
-		Source:    source,
-		Message:   message,
-	}
-
-	// Add to logs buffer
-	lc.logs = append(lc.logs, entry)
-
-	// Keep only the latest maxLogs entries
-	if len(lc.logs) > lc.maxLogs {
-		lc.logs = lc.logs[1:]
-	}
-
-	// Notify all subscribers
-	for _, subscriber := range lc.subscribers {
-		select {
-		case subscriber <- entry:
-		default:
-			// Skip if subscriber's channel is full
-		}
-	}
-}
-
-// GetLogs returns all current logs
-func (lc *LogCollector) GetLogs() []LogEntry {
-	lc.mutex.RLock()
-	defer lc.mutex.RUnlock()
-
-	// Return a copy of logs
-	result := make([]LogEntry, len(lc.logs))
-	copy(result, lc.logs)
-	return result
-}
-
-// Subscribe adds a new subscriber for real-time log updates
-func (lc *LogCollector) Subscribe() chan LogEntry {
-	lc.mutex.Lock()
-	defer lc.mutex.Unlock()
-
-	subscriber := make(chan LogEntry, 100) // Buffer for 100 log entries
-	lc.subscribers = append(lc.subscribers, subscriber)
-	return subscriber
-}
-
-// Unsubscribe removes a subscriber
-func (lc *LogCollector) Unsubscribe(subscriber chan LogEntry) {
-	lc.mutex.Lock()
-	defer lc.mutex.Unlock()
-
-	for i, sub := range lc.subscribers {
-		if sub == subscriber {
-			lc.subscribers = append(lc.subscribers[:i], lc.subscribers[i+1:]...)
-			close(subscriber)
-			break
-		}
-	}
-}
-
 // WebUIServer represents the WebUI server
 type WebUIServer struct {
 	cfg                  *config.Config
@@ -120,14 +37,20 @@ type WebUIServer struct {
 	monitoringMiddleware *middleware.MonitoringMiddleware
 	startTime            time.Time
 	server               *http.Server
+	tls                  config.ListenerTLSConfig // TLS settings the server was started with
+	certs                *certs.Reloader          // Reloads the certificate when its files change, nil without TLS
 	logger               *slog.Logger
-	logCollector         *LogCollector
+	logBuffer            *logging.Buffer
 	authMiddleware       *AuthMiddleware
+	corsMiddleware       *middleware.CORSMiddleware
 	running              bool
 	configRegistry       *config.ConfigRegistry
 	configDir            string
 	registryPath         string
 	configWatcher        *config.ConfigWatcher
+	diagnostics          *diagnostics.Collector
+	proxyHandler         *proxy.Handler
+	notifier             *notify.Dispatcher
 }
 
 // NewWebUIServer creates a new WebUI server
@@ -147,14 +70,18 @@ func NewWebUIServer(cfg *config.Config, endpointManager *endpoint.Manager, monit
 		configRegistry = config.NewConfigRegistry()
 	}
 
+	authMiddleware := NewAuthMiddleware(cfg.WebUI)
+	authMiddleware.SetTrustedProxies(cfg.Server.TrustedProxies)
+
 	return &WebUIServer{
 		cfg:                  cfg,
 		endpointManager:      endpointManager,
 		monitoringMiddleware: monitoringMiddleware,
 		startTime:            startTime,
 		logger:               logger,
-		logCollector:         NewLogCollector(500), // Keep consistent with TUI (500 logs)
-		authMiddleware:       NewAuthMiddleware(cfg.WebUI.Password),
+		logBuffer:            logging.NewBuffer(logging.DefaultBufferSize),
+		authMiddleware:       authMiddleware,
+		corsMiddleware:       middleware.NewCORSMiddleware(cfg.Server.CORS),
 		running:              false,
 		configRegistry:       configRegistry,
 		configDir:            configDir,
@@ -169,17 +96,40 @@ func (w *WebUIServer) SetConfigWatcher(configWatcher *config.ConfigWatcher) {
 	w.configRegistry = configWatcher.GetRegistry()
 }
 
+// SetDiagnostics sets the self-diagnostics collector served on /api/diagnostics
+func (w *WebUIServer) SetDiagnostics(collector *diagnostics.Collector) {
+	w.diagnostics = collector
+}
+
+// SetProxyHandler sets the proxy handler used for dry-run test requests
+func (w *WebUIServer) SetProxyHandler(proxyHandler *proxy.Handler) {
+	w.proxyHandler = proxyHandler
+}
+
+// SetNotifier sets the notification dispatcher used by /api/notifications/test
+func (w *WebUIServer) SetNotifier(notifier *notify.Dispatcher) {
+	w.notifier = notifier
+}
+
 // UpdateConfig updates the WebUI server configuration
 func (w *WebUIServer) UpdateConfig(cfg *config.Config) {
 	w.cfg = cfg
 	// Update auth middleware with new config
-	w.authMiddleware.UpdateConfig(cfg.WebUI.Password)
+	w.authMiddleware.UpdateConfig(cfg.WebUI)
+	w.authMiddleware.SetTrustedProxies(cfg.Server.TrustedProxies)
+	w.corsMiddleware.UpdateConfig(cfg.Server.CORS)
 }
 
-// AddLog allows external systems to add logs to the collector
+// SetLogBuffer makes the WebUI read logs from the shared log buffer, so logs written
+// before the WebUI started are shown as well
+func (w *WebUIServer) SetLogBuffer(buffer *logging.Buffer) {
+	w.logBuffer = buffer
+}
+
+// AddLog allows external systems to add logs to the log buffer
 func (w *WebUIServer) AddLog(level, message, source string) {
-	if w.logCollector != nil {
-		w.logCollector.AddLog(level, message, source)
+	if w.logBuffer != nil {
+		w.logBuffer.AddLog(level, message, source)
 	}
 }
 
@@ -194,6 +144,7 @@ func (w *WebUIServer) Start() error {
 	// Authentication endpoints (no auth required)
 	mux.HandleFunc("/login", w.authMiddleware.HandleLogin)
 	mux.HandleFunc("/logout", w.authMiddleware.HandleLogout)
+	mux.HandleFunc("/api/version", diagnostics.VersionHandler(w.startTime, w.authMiddleware.clientIP))
 
     // Protected endpoints (require authentication if password is set)
     mux.HandleFunc("/", w.authMiddleware.RequireAuth(w.handleIndex))
@@ -202,12 +153,18 @@ func (w *WebUIServer) Start() error {
 	// Protected API endpoints
 	mux.HandleFunc("/api/overview", w.authMiddleware.RequireAuth(w.handleOverview))
 	mux.HandleFunc("/api/endpoints", w.authMiddleware.RequireAuth(w.handleEndpoints))
+	mux.HandleFunc("/api/groups", w.authMiddleware.RequireAuth(w.handleGroups))
 	mux.HandleFunc("/api/connections", w.authMiddleware.RequireAuth(w.handleConnections))
+	mux.HandleFunc("/api/connections/detail", w.authMiddleware.RequireAuth(w.handleConnectionDetail))
 	mux.HandleFunc("/api/logs", w.authMiddleware.RequireAuth(w.handleLogs))
+	mux.HandleFunc("/api/logs/search", w.authMiddleware.RequireAuth(w.handleLogSearch))
+	mux.HandleFunc("/api/logs/download", w.authMiddleware.RequireAuth(w.handleLogDownload))
 	mux.HandleFunc("/api/config", w.authMiddleware.RequireAuth(w.handleConfig))
+	mux.HandleFunc("/api/diagnostics", w.authMiddleware.RequireAuth(w.handleDiagnostics))
 
 	// Protected Server-Sent Events for real-time updates
 	mux.HandleFunc("/api/events", w.authMiddleware.RequireAuth(w.handleEvents))
+	mux.HandleFunc("/api/events/history", w.authMiddleware.RequireAuth(w.handleEventHistory))
 
 	// Protected Server-Sent Events for real-time log updates
 	mux.HandleFunc("/api/log-stream", w.authMiddleware.RequireAuth(w.handleLogStream))
@@ -216,7 +173,17 @@ func (w *WebUIServer) Start() error {
 	mux.HandleFunc("/api/endpoints/priority", w.authMiddleware.RequireAuth(w.handleEndpointPriority))
 	mux.HandleFunc("/api/config/save", w.authMiddleware.RequireAuth(w.handleConfigSave))
 	mux.HandleFunc("/api/endpoints/details", w.authMiddleware.RequireAuth(w.handleEndpointDetails))
+	mux.HandleFunc("/api/endpoints/maintenance", w.authMiddleware.RequireAuth(w.handleEndpointMaintenance))
+	mux.HandleFunc("/api/endpoints/disable", w.authMiddleware.RequireAuth(w.handleEndpointDisable))
+	mux.HandleFunc("/api/endpoints/enable", w.authMiddleware.RequireAuth(w.handleEndpointEnable))
+	mux.HandleFunc("/api/endpoints/primary", w.authMiddleware.RequireAuth(w.handleEndpointPrimary))
+	mux.HandleFunc("/api/groups/reset-cooldown", w.authMiddleware.RequireAuth(w.handleGroupResetCooldown))
+	mux.HandleFunc("/api/groups/priority", w.authMiddleware.RequireAuth(w.handleGroupPriority))
+	mux.HandleFunc("/api/test-request", w.authMiddleware.RequireAuth(w.handleTestRequest))
+	mux.HandleFunc("/api/route/explain", w.authMiddleware.RequireAuth(w.handleRouteExplain))
 	mux.HandleFunc("/api/overview/token-history", w.authMiddleware.RequireAuth(w.handleTokenHistory))
+	mux.HandleFunc("/api/clients", w.authMiddleware.RequireAuth(w.handleClients))
+	mux.HandleFunc("/api/inspector", w.authMiddleware.RequireAuth(w.handleInspector))
 
 	// Protected Configuration management endpoints
 	mux.HandleFunc("/api/configs", w.authMiddleware.RequireAuth(w.handleConfigs))
@@ -227,47 +194,93 @@ func (w *WebUIServer) Start() error {
 	mux.HandleFunc("/api/configs/active", w.authMiddleware.RequireAuth(w.handleActiveConfig))
 	// New: config file content + export endpoints
 	mux.HandleFunc("/api/configs/content", w.authMiddleware.RequireAuth(w.handleConfigContent))
+	mux.HandleFunc("/api/configs/diff", w.authMiddleware.RequireAuth(w.handleConfigDiff))
 	mux.HandleFunc("/api/configs/export", w.authMiddleware.RequireAuth(w.handleConfigExport))
     mux.HandleFunc("/api/configs/export-all", w.authMiddleware.RequireAuth(w.handleConfigExportAll))
     // State reset endpoint
     mux.HandleFunc("/api/reset-state", w.authMiddleware.RequireAuth(w.handleResetState))
+	// Persisted runtime state (priority overrides, maintenance flags, group priorities and cooldowns)
+	mux.HandleFunc("/api/state", w.authMiddleware.RequireAuth(w.handleRuntimeState))
+	mux.HandleFunc("/api/state/reset", w.authMiddleware.RequireAuth(w.handleRuntimeStateReset))
+	// Runtime priority overrides kept in the state file instead of the config file
+	mux.HandleFunc("/api/overrides", w.authMiddleware.RequireAuth(w.handleOverrides))
+	mux.HandleFunc("/api/notifications/test", w.authMiddleware.RequireAuth(w.handleNotificationTest))
 
 	w.server = &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", w.cfg.WebUI.Host, w.cfg.WebUI.Port),
-		Handler:      mux,
+		Addr:         listenAddress(w.cfg),
+		Handler:      w.corsMiddleware.Wrap(mux),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	w.running = true
-	w.logger.Info("🌐 WebUI服务器启动中...", "address", w.server.Addr)
-
-	// Start server in a goroutine
-	serverErr := make(chan error, 1)
-	go func() {
-		w.logger.Debug("WebUI服务器开始监听...", "address", w.server.Addr)
-		if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			w.logger.Error("WebUI服务器监听失败", "error", err, "address", w.server.Addr)
-			serverErr <- err
-		} else {
-			w.logger.Debug("WebUI服务器监听结束", "address", w.server.Addr)
-		}
-	}()
+	w.logger.Info("🌐 WebUI服务器启动中...", "address", w.server.Addr, "tls", w.cfg.WebUI.TLS.Enabled)
 
-	// Give server a moment to start
-	time.Sleep(200 * time.Millisecond)
+	// Load the certificate before binding so missing or unreadable files fail the start
+	w.tls = w.cfg.WebUI.TLS
+	if w.tls.Enabled {
+		reloader, tlsConfig, err := certs.New(w.tls, "WebUI")
+		if err != nil {
+			w.logger.Error("WebUI服务器启动失败", "error", err, "address", w.server.Addr)
+			return fmt.Errorf("WebUI服务器启动失败: %w", err)
+		}
+		w.certs = reloader
+		w.server.TLSConfig = tlsConfig
+	}
 
-	// Check if server started successfully
-	select {
-	case err := <-serverErr:
-		w.running = false
+	// Bind before returning so address conflicts and socket errors are reported as startup failures
+	listeners, err := w.listen()
+	if err != nil {
+		if w.certs != nil {
+			w.certs.Close()
+		}
 		w.logger.Error("WebUI服务器启动失败", "error", err, "address", w.server.Addr)
 		return fmt.Errorf("WebUI服务器启动失败: %w", err)
-	default:
-		w.logger.Info("✅ WebUI服务器启动成功！", "url", fmt.Sprintf("http://%s", w.server.Addr))
-		return nil
 	}
+	w.running = true
+
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			w.logger.Debug("WebUI服务器开始监听...", "address", ln.Addr().String())
+			var err error
+			if w.certs != nil {
+				err = w.server.ServeTLS(ln, "", "")
+			} else {
+				err = w.server.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				w.logger.Error("WebUI服务器监听失败", "error", err, "address", w.server.Addr)
+			} else {
+				w.logger.Debug("WebUI服务器监听结束", "address", w.server.Addr)
+			}
+		}(ln)
+	}
+
+	if addr, err := config.ParseListen(w.cfg.WebUI.Listen); err == nil && addr.SocketPath != "" {
+		w.logger.Info("✅ WebUI服务器启动成功！", "socket", addr.SocketPath)
+	} else if err == nil {
+		w.logger.Info("✅ WebUI服务器启动成功！", "socket", addr.String())
+	} else {
+		scheme := "http"
+		if w.certs != nil {
+			scheme = "https"
+		}
+		w.logger.Info("✅ WebUI服务器启动成功！", "url", fmt.Sprintf("%s://%s", scheme, w.server.Addr))
+	}
+	return nil
+}
+
+// listen opens the WebUI's TCP address, Unix socket or systemd sockets. Unix socket
+// files are removed when Stop shuts the server down and closes them.
+func (w *WebUIServer) listen() ([]net.Listener, error) {
+	if w.cfg.WebUI.Listen != "" {
+		return listen.Listen(w.cfg.WebUI.Listen, w.cfg.WebUI.SocketFileMode())
+	}
+	ln, err := net.Listen("tcp", w.server.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{ln}, nil
 }
 
 // handleResetState resets group cooldown/retry, clears fast-test cache and endpoint statuses
@@ -291,6 +304,109 @@ func (w *WebUIServer) handleResetState(rw http.ResponseWriter, r *http.Request)
     })
 }
 
+// handleRuntimeState returns the persisted runtime state
+func (w *WebUIServer) handleRuntimeState(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"file":  w.endpointManager.GetStateFilePath(),
+		"state": w.endpointManager.GetRuntimeState(),
+	})
+}
+
+// handleOverrides lists (GET) or reverts (DELETE) the runtime priority overrides that are
+// applied on top of the config file
+func (w *WebUIServer) handleOverrides(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		endpoints, groups := w.endpointManager.PriorityOverrides()
+		w.writeJSON(rw, map[string]interface{}{
+			"file":      w.endpointManager.GetStateFilePath(),
+			"endpoints": endpoints,
+			"groups":    groups,
+		})
+	case http.MethodDelete:
+		w.logger.Info("♻️ WebUI: 收到清除优先级覆盖请求")
+		reverted := w.endpointManager.RevertPriorityOverrides("webui")
+		w.writeJSON(rw, map[string]interface{}{
+			"success":  true,
+			"reverted": reverted,
+			"message":  fmt.Sprintf("已清除 %d 个优先级覆盖，恢复配置文件中的优先级", reverted),
+		})
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRuntimeStateReset clears the runtime state and deletes the state file
+func (w *WebUIServer) handleRuntimeStateReset(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.logger.Info("♻️ WebUI: 收到运行时状态清除请求")
+	if err := w.endpointManager.ResetRuntimeState(); err != nil {
+		http.Error(rw, fmt.Sprintf("Failed to reset runtime state: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"success": true,
+		"message": "运行时状态已清除",
+	})
+}
+
+// handleEventHistory returns recent failover events (endpoint health, group cooldowns,
+// config changes), newest first. ?limit=N returns at most N events.
+func (w *WebUIServer) handleEventHistory(rw http.ResponseWriter, r *http.Request) {
+	if w.notifier == nil {
+		http.Error(rw, "Events are not available", http.StatusServiceUnavailable)
+		return
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(rw, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	events := w.notifier.History(limit)
+	w.writeJSON(rw, map[string]interface{}{
+		"events": events,
+		"total":  len(events),
+	})
+}
+
+// handleNotificationTest sends a test event to every configured notification sink
+func (w *WebUIServer) handleNotificationTest(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if w.notifier == nil {
+		http.Error(rw, "Notifications are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.logger.Info("🔔 WebUI: 收到测试通知请求")
+	if err := w.notifier.SendTest("Test notification sent from the WebUI"); err != nil {
+		http.Error(rw, fmt.Sprintf("Failed to send test notification: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"success": true,
+		"message": "测试通知已发送",
+	})
+}
+
 // Stop stops the WebUI server
 func (w *WebUIServer) Stop() error {
 	if w.server == nil || !w.running {
@@ -302,6 +418,9 @@ func (w *WebUIServer) Stop() error {
 	defer cancel()
 
 	w.logger.Info("🛑 正在关闭WebUI服务器...")
+	if w.certs != nil {
+		defer w.certs.Close()
+	}
 	return w.server.Shutdown(ctx)
 }
 
@@ -317,24 +436,111 @@ func (w *WebUIServer) handleIndex(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
-	rw.Write([]byte(indexHTML))
+	staticAssets["/"].serve(rw, r)
+}
+
+// handleDiagnostics returns the self-diagnostics report
+func (w *WebUIServer) handleDiagnostics(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if w.diagnostics == nil {
+		http.Error(rw, "Diagnostics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.writeJSON(rw, w.diagnostics.Collect())
+}
+
+// handleTestRequest sends a dry-run request through the proxy pipeline and returns the report
+func (w *WebUIServer) handleTestRequest(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if w.proxyHandler == nil {
+		http.Error(rw, "Proxy handler not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Endpoint string          `json:"endpoint"`
+		Path     string          `json:"path"`
+		Method   string          `json:"method"`
+		Body     json.RawMessage `json:"body"` // A JSON value, or a string sent as-is
+		Stream   bool            `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	body := []byte(req.Body)
+	var text string
+	if err := json.Unmarshal(req.Body, &text); err == nil {
+		body = []byte(text)
+	}
+
+	report, err := w.proxyHandler.DryRun(r.Context(), proxy.DryRunRequest{
+		Endpoint: req.Endpoint,
+		Path:     req.Path,
+		Method:   req.Method,
+		Body:     body,
+		Stream:   req.Stream,
+	})
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.writeJSON(rw, report)
+}
+
+// handleRouteExplain returns the endpoint selection trace for a described request
+func (w *WebUIServer) handleRouteExplain(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if w.proxyHandler == nil {
+		http.Error(rw, "Proxy handler not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	stream := false
+	if raw := query.Get("stream"); raw != "" {
+		var err error
+		if stream, err = strconv.ParseBool(raw); err != nil {
+			http.Error(rw, "Invalid stream value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	explanation, err := w.proxyHandler.ExplainRoute(r.Context(), proxy.RouteQuery{
+		Path:   query.Get("path"),
+		Method: query.Get("method"),
+		Model:  query.Get("model"),
+		Stream: stream,
+		Client: query.Get("client"),
+	})
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.writeJSON(rw, explanation)
 }
 
-// handleStatic serves static files
+// handleStatic serves the embedded stylesheet and script
 func (w *WebUIServer) handleStatic(rw http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-
-	switch path {
-	case "/static/style.css":
-		rw.Header().Set("Content-Type", "text/css")
-		rw.Write([]byte(styleCSS))
-	case "/static/app.js":
-		rw.Header().Set("Content-Type", "application/javascript")
-		rw.Write([]byte(appJS))
-	default:
+	asset, ok := staticAssets[r.URL.Path]
+	if !ok {
 		http.NotFound(rw, r)
+		return
 	}
+	asset.serve(rw, r)
 }
 
 // handleOverview returns overview data
@@ -357,6 +563,7 @@ func (w *WebUIServer) handleOverview(rw http.ResponseWriter, r *http.Request) {
 		endpointStatuses = append(endpointStatuses, map[string]interface{}{
 			"name":         ep.Config.Name,
 			"healthy":      status.Healthy,
+			"disabled":     status.Disabled,
 			"responseTime": status.ResponseTime.Milliseconds(),
 		})
 	}
@@ -370,6 +577,9 @@ func (w *WebUIServer) handleOverview(rw http.ResponseWriter, r *http.Request) {
 			"totalRequests":       metrics.TotalRequests,
 			"successfulRequests":  metrics.SuccessfulRequests,
 			"failedRequests":      metrics.FailedRequests,
+			"localErrors":         metrics.LocalErrors,
+			"upstreamErrors":      metrics.UpstreamErrors,
+			"rejectedRequests":    metrics.RejectedRequests,
 			"successRate":         metrics.GetSuccessRate(),
 			"averageResponseTime": metrics.GetAverageResponseTime().Milliseconds(),
 		},
@@ -387,12 +597,44 @@ func (w *WebUIServer) handleOverview(rw http.ResponseWriter, r *http.Request) {
 		},
 		"system": map[string]interface{}{
 			"activeConnections": len(metrics.ActiveConnections),
-			"totalConnections":  len(metrics.ActiveConnections) + len(metrics.ConnectionHistory),
+			"totalConnections":  len(metrics.ActiveConnections) + metrics.HistoricalConnections,
 			"uptime":            uptime.Seconds(),
 		},
-		"connectionHistory": w.getRecentConnectionHistory(metrics.ConnectionHistory, 3),
+		"connectionHistory": w.getRecentConnectionHistory(w.monitoringMiddleware.GetMetrics().RecentConnections(recentHistoryScan), 3),
+		"setupMode":         w.cfg.IsSetupMode(),
+	}
+	if w.notifier != nil {
+		data["recentEvents"] = w.notifier.History(overviewEvents)
 	}
 
+	// Retries made across all requests in the current minute (limit 0 = unlimited)
+	if w.proxyHandler != nil {
+		used, limit, resetIn := w.proxyHandler.RetryBudgetUsage()
+		data["retryBudget"] = map[string]interface{}{
+			"used":         used,
+			"limit":        limit,
+			"resetSeconds": int(resetIn.Seconds()),
+		}
+	}
+
+	// Token budget consumption in each budget's current window
+	budgets := w.endpointManager.BudgetStatuses()
+	budgetData := make([]map[string]interface{}, 0, len(budgets))
+	for _, budget := range budgets {
+		budgetData = append(budgetData, map[string]interface{}{
+			"subject":      budget.Subject,
+			"action":       budget.Action,
+			"window":       budget.Window.String(),
+			"limit":        budget.Limit,
+			"used":         budget.Used,
+			"percent":      budget.Percent(),
+			"exceeded":     budget.Exceeded,
+			"blocking":     budget.Blocking,
+			"resetSeconds": int(budget.ResetIn.Seconds()),
+		})
+	}
+	data["budgets"] = budgetData
+
 	w.writeJSON(rw, data)
 }
 
@@ -415,14 +657,39 @@ func (w *WebUIServer) handleEndpoints(rw http.ResponseWriter, r *http.Request) {
 
 		data := map[string]interface{}{
 			"name":             ep.Config.Name,
-			"url":              ep.Config.URL,
+			"url":              ep.Config.DisplayURL(),
 			"priority":         ep.Config.Priority,
+			"group":            groupName(ep),
+			"groupPriority":    ep.Config.GroupPriority,
 			"timeout":          ep.Config.Timeout.String(),
 			"healthy":          status.Healthy,
+			"disabled":         status.Disabled,
 			"responseTime":     status.ResponseTime.Milliseconds(),
+			"latencyEwma":      status.LatencyEWMA.Milliseconds(),
+			"latencySamples":   status.LatencySamples,
 			"consecutiveFails": status.ConsecutiveFails, // Keep for backward compatibility
 			"failedRequests":   failedRequests,          // Add actual failed requests count
 			"lastCheck":        status.LastCheck.Format("15:04:05"),
+			"rateLimited":      status.IsRateLimited(time.Now()),
+			"rateLimitedUntil": formatRateLimitedUntil(status.RateLimitedUntil),
+			"rateLimitedFor":   rateLimitedSeconds(status.RateLimitedUntil),
+			"inFlight":         ep.InFlight(),
+			"maxConcurrent":    ep.MaxConcurrent(),
+		}
+		if rateLimit := rateLimitData(ep); rateLimit != nil {
+			data["rateLimit"] = rateLimit
+		}
+		if tokens := tokenData(ep); tokens != nil {
+			data["tokens"] = tokens
+		}
+		if breaker := circuitBreakerData(ep, w.endpointManager.GetConfig().CircuitBreaker); breaker != nil {
+			data["circuitBreaker"] = breaker
+		}
+		if ep.Config.IsOAuth2() {
+			// Only the expiry is exposed, never the token itself
+			data["authType"] = config.AuthTypeOAuth2
+			data["tokenExpiry"] = formatTokenExpiry(status.AuthExpiry)
+			data["authError"] = status.AuthError
 		}
 
 		if endpointStats != nil {
@@ -441,6 +708,7 @@ func (w *WebUIServer) handleEndpoints(rw http.ResponseWriter, r *http.Request) {
 				"successfulRequests": endpointStats.SuccessfulRequests,
 				"successRate":        successRate,
 				"retryCount":         endpointStats.RetryCount,
+				"rateLimitCount":     endpointStats.RateLimitCount,
 				"avgResponseTime":    avgResponseTime.Milliseconds(),
 				"minResponseTime":    endpointStats.MinResponseTime.Milliseconds(),
 				"maxResponseTime":    endpointStats.MaxResponseTime.Milliseconds(),
@@ -462,14 +730,143 @@ func (w *WebUIServer) handleEndpoints(rw http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleGroups returns endpoint groups with their status and selection strategy
+func (w *WebUIServer) handleGroups(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupManager := w.endpointManager.GetGroupManager()
+	groups := groupManager.GetAllGroups()
+
+	// Per-group request and token totals, including groups that no longer exist after a reload
+	allStats := w.monitoringMiddleware.GetGroupStats()
+	stats := make(map[string]*monitor.GroupMetrics, len(allStats))
+	for _, groupStats := range allStats {
+		stats[groupStats.Name] = groupStats
+	}
+
+	groupData := make([]map[string]interface{}, 0, len(groups))
+	for _, group := range groups {
+		endpointNames := make([]string, 0, len(group.Endpoints))
+		healthyCount := 0
+		for _, ep := range group.Endpoints {
+			endpointNames = append(endpointNames, ep.Config.Name)
+			if ep.IsHealthy() {
+				healthyCount++
+			}
+		}
+
+		groupData = append(groupData, map[string]interface{}{
+			"name":              group.Name,
+			"priority":          group.Priority,
+			"active":            group.IsActive,
+			"inCooldown":        groupManager.IsGroupInCooldown(group.Name),
+			"cooldownRemaining": int(groupManager.GetGroupCooldownRemaining(group.Name).Seconds()),
+			"strategy":          groupManager.GetGroupStrategy(group.Name),
+			"retry":             groupRetryData(groupManager.GetGroupRetry(group.Name)),
+			"cooldown":          groupManager.GetGroupCooldownDuration(group.Name).String(),
+			"maxRetries":        groupManager.GetGroupMaxRetries(group.Name),
+			"endpoints":         endpointNames,
+			"healthyEndpoints":  healthyCount,
+			"configured":        true,
+			"stats":             groupStatsData(stats[group.Name]),
+		})
+		delete(stats, group.Name)
+	}
+	for _, groupStats := range allStats {
+		if stats[groupStats.Name] == nil {
+			continue // Listed above
+		}
+		groupData = append(groupData, map[string]interface{}{
+			"name":       groupStats.Name,
+			"configured": false,
+			"stats":      groupStatsData(groupStats),
+		})
+	}
+
+	// Requests not attributed to any group: still in flight, cancelled by the client, or
+	// refused before an endpoint was selected (taken from one snapshot so the numbers add up)
+	metrics := w.monitoringMiddleware.GetMetrics().GetMetrics()
+	var grouped monitor.GroupMetrics
+	for _, groupStats := range metrics.GroupStats {
+		grouped.TotalRequests += groupStats.TotalRequests
+		grouped.SuccessfulRequests += groupStats.SuccessfulRequests
+		grouped.FailedRequests += groupStats.FailedRequests
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"groups": groupData,
+		"totals": map[string]interface{}{
+			"totalRequests":      metrics.TotalRequests,
+			"successfulRequests": metrics.SuccessfulRequests,
+			"failedRequests":     metrics.FailedRequests,
+		},
+		"unattributed": map[string]interface{}{
+			"totalRequests":      metrics.TotalRequests - grouped.TotalRequests,
+			"successfulRequests": metrics.SuccessfulRequests - grouped.SuccessfulRequests,
+			"failedRequests":     metrics.FailedRequests - grouped.FailedRequests,
+		},
+	})
+}
+
+// groupRetryData returns the effective retry settings of a group for /api/groups
+func groupRetryData(retry config.RetryConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"maxAttempts": retry.MaxAttempts,
+		"baseDelay":   retry.BaseDelay.String(),
+		"maxDelay":    retry.MaxDelay.String(),
+		"multiplier":  retry.Multiplier,
+	}
+}
+
+// groupStatsData formats a group's aggregated metrics for the API
+func groupStatsData(stats *monitor.GroupMetrics) map[string]interface{} {
+	if stats == nil {
+		stats = &monitor.GroupMetrics{}
+	}
+	lastUsed := ""
+	if !stats.LastUsed.IsZero() {
+		lastUsed = stats.LastUsed.Format("15:04:05")
+	}
+	return map[string]interface{}{
+		"totalRequests":      stats.TotalRequests,
+		"successfulRequests": stats.SuccessfulRequests,
+		"failedRequests":     stats.FailedRequests,
+		"successRate":        stats.SuccessRate(),
+		"avgResponseTime":    stats.AverageResponseTime().Milliseconds(),
+		"retryCount":         stats.RetryCount,
+		"rateLimitCount":     stats.RateLimitCount,
+		"cooldownSeconds":    int(stats.CooldownTime.Seconds()),
+		"lastUsed":           lastUsed,
+		"tokenUsage": map[string]interface{}{
+			"inputTokens":         stats.TokenUsage.InputTokens,
+			"outputTokens":        stats.TokenUsage.OutputTokens,
+			"cacheCreationTokens": stats.TokenUsage.CacheCreationTokens,
+			"cacheReadTokens":     stats.TokenUsage.CacheReadTokens,
+			"totalTokens":         stats.TokenUsage.InputTokens + stats.TokenUsage.OutputTokens,
+		},
+	}
+}
+
 // handleConnections returns connections data
 func (w *WebUIServer) handleConnections(rw http.ResponseWriter, r *http.Request) {
 	metrics := w.monitoringMiddleware.GetMetrics().GetMetrics()
 
-	// Convert active connections to JSON-friendly format
-	activeConnections := make([]map[string]interface{}, 0, len(metrics.ActiveConnections))
+	// Convert active connections to JSON-friendly format, followed by the ones the client cancelled recently
+	connections := make([]*monitor.ConnectionInfo, 0, len(metrics.ActiveConnections))
 	for _, conn := range metrics.ActiveConnections {
+		connections = append(connections, conn)
+	}
+	connections = append(connections, w.monitoringMiddleware.GetMetrics().RecentlyCancelled(time.Now())...)
+
+	activeConnections := make([]map[string]interface{}, 0, len(connections))
+	for _, conn := range connections {
 		duration := time.Since(conn.StartTime)
+		if conn.Status == "cancelled" {
+			duration = conn.LastActivity.Sub(conn.StartTime)
+		}
 		endpoint := conn.Endpoint
 		if endpoint == "" || endpoint == "unknown" {
 			endpoint = "pending"
@@ -481,53 +878,333 @@ func (w *WebUIServer) handleConnections(rw http.ResponseWriter, r *http.Request)
 		}
 
 		activeConnections = append(activeConnections, map[string]interface{}{
-			"clientIP":  conn.ClientIP,
-			"method":    conn.Method,
-			"path":      conn.Path,
-			"endpoint":  endpoint,
-			"retryInfo": retryInfo,
-			"duration":  duration.Seconds(),
-			"startTime": conn.StartTime.Format("15:04:05"),
+			"id":         conn.ID,
+			"requestId":  conn.RequestID,
+			"clientIP":   conn.ClientIP,
+			"method":     conn.Method,
+			"path":       conn.Path,
+			"endpoint":   endpoint,
+			"retryInfo":  retryInfo,
+			"retryCount": conn.RetryCount,
+			"status":     conn.Status,
+			"duration":   duration.Seconds(),
+			"startTime":  conn.StartTime.Format("15:04:05"),
+			// Failed upstream attempts by class, e.g. "timeouts:2 5xx:1", and the attempts themselves
+			"retryReasons": monitor.RetryReasons(conn.Attempts),
+			"attempts":     attemptsJSON(conn.Attempts),
+			// Upstream attempts that carried the same idempotency key
+			"idempotencyKey": conn.IdempotencyKey,
+			"keyedAttempts":  conn.KeyedAttempts,
+			"pinned":         conn.Pinned,
+			"coalescedWith":  conn.CoalescedWith,
 		})
 	}
 
 	data := map[string]interface{}{
 		"activeCount":       len(metrics.ActiveConnections),
-		"historicalCount":   len(metrics.ConnectionHistory),
+		"historicalCount":   metrics.HistoricalConnections,
+		"cancelledCount":    metrics.CancelledRequests,
+		"coalescedCount":    metrics.CoalescedRequests,
 		"activeConnections": activeConnections,
 	}
 
 	w.writeJSON(rw, data)
 }
 
-// handleLogs returns logs data
-func (w *WebUIServer) handleLogs(rw http.ResponseWriter, r *http.Request) {
-	logs := w.logCollector.GetLogs()
-
-	// Convert LogEntry to the format expected by the frontend
-	logData := make([]map[string]interface{}, 0, len(logs))
-	for _, log := range logs {
-		logData = append(logData, map[string]interface{}{
-			"timestamp": log.Timestamp,
-			"level":     log.Level,
-			"source":    log.Source,
-			"message":   log.Message,
+// handleConnectionDetail returns an active or recent connection with its upstream attempt timeline
+func (w *WebUIServer) handleConnectionDetail(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	connID := r.URL.Query().Get("id")
+	if connID == "" {
+		http.Error(rw, "Connection id is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, ok := w.monitoringMiddleware.GetMetrics().GetConnection(connID)
+	if !ok {
+		http.Error(rw, "Connection not found", http.StatusNotFound)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"id":          conn.ID,
+		"requestId":   conn.RequestID,
+		"clientIP":    conn.ClientIP,
+		"method":      conn.Method,
+		"path":        conn.Path,
+		"endpoint":    conn.Endpoint,
+		"status":      conn.Status,
+		"retryCount":  conn.RetryCount,
+		"isStreaming": conn.IsStreaming,
+		"startTime":   conn.StartTime.Format("15:04:05"),
+		"maxAttempts": w.cfg.Retry.MaxAttempts,
+		"attempts":    attemptsJSON(conn.Attempts),
+		// How many attempts a connection keeps
+		"attemptsKept": monitor.MaxConnectionAttempts,
+		// Failed upstream attempts by class, e.g. "timeouts:2 5xx:1"
+		"retryReasons": monitor.RetryReasons(conn.Attempts),
+		// Set when the forwarder refused the request without trying any endpoint
+		"rejectReason": conn.RejectReason,
+		// Set when the request received the response of an identical one instead of being forwarded
+		"coalescedWith": conn.CoalescedWith,
+	})
+}
+
+// attemptsJSON converts an upstream attempt timeline to the JSON-friendly format
+func attemptsJSON(attempts []monitor.AttemptInfo) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(attempts))
+	for _, attempt := range attempts {
+		result = append(result, map[string]interface{}{
+			"endpoint":     attempt.Endpoint,
+			"startTime":    attempt.StartTime.Format("15:04:05.000"),
+			"endTime":      attempt.EndTime.Format("15:04:05.000"),
+			"durationMs":   attempt.EndTime.Sub(attempt.StartTime).Milliseconds(),
+			"outcome":      attempt.Outcome,
+			"failureClass": attempt.FailureClass(),
+			"statusCode":   attempt.StatusCode,
+			"streaming":    attempt.Streaming,
+			"backoffMs":    attempt.Backoff.Milliseconds(),
 		})
 	}
+	return result
+}
+
+// handleLogs returns logs data. With any of the search parameters (q, level, since, limit)
+// only the matching entries of the in-memory buffer are returned.
+func (w *WebUIServer) handleLogs(rw http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if !params.Has("q") && !params.Has("level") && !params.Has("since") && !params.Has("limit") {
+		logs := w.logBuffer.GetLogs()
+
+		// Convert log entries to the format expected by the frontend
+		logData := make([]map[string]interface{}, 0, len(logs))
+		for _, log := range logs {
+			logData = append(logData, logEntryData(log))
+		}
+		w.writeJSON(rw, map[string]interface{}{"logs": logData})
+		return
+	}
+
+	query, err := parseLogSearchQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.writeJSON(rw, map[string]interface{}{
+		"logs":  w.searchLogBuffer(query),
+		"query": query.Text,
+		"limit": query.Limit,
+	})
+}
+
+// logEntryData converts a buffered log entry to the format expected by the frontend
+func logEntryData(log logging.Entry) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp": log.Timestamp,
+		"level":     log.Level,
+		"source":    log.Source,
+		"message":   log.Message,
+	}
+}
+
+const (
+	defaultLogSearchLimit = 200
+	maxLogSearchLimit     = 1000
+)
+
+// searchLogBuffer returns the newest entries of the in-memory log buffer matching a query,
+// at most query.Limit, in chronological order
+func (w *WebUIServer) searchLogBuffer(query logging.SearchQuery) []map[string]interface{} {
+	matches := make([]map[string]interface{}, 0)
+	logs := w.logBuffer.GetLogs()
+	for i := len(logs) - 1; i >= 0 && len(matches) < query.Limit; i-- {
+		if log := logs[i]; query.Matches(log.At, log.Level, log.Message) {
+			matches = append(matches, logEntryData(log))
+		}
+	}
+	// Restore chronological order
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
+
+// handleLogSearch searches the in-memory log buffer and, when file logging is enabled,
+// the current and rotated log files
+func (w *WebUIServer) handleLogSearch(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseLogSearchQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	data := map[string]interface{}{
-		"logs": logData,
+		"query":       query.Text,
+		"limit":       query.Limit,
+		"memory":      w.searchLogBuffer(query),
+		"fileEnabled": w.cfg.Logging.FileEnabled,
+	}
+
+	if w.cfg.Logging.FileEnabled {
+		fileMatches, truncated, err := logging.SearchFiles(w.cfg.Logging.FilePath, query)
+		if err != nil {
+			w.logger.Warn("Failed to search log files", "error", err)
+			data["fileError"] = err.Error()
+		}
+		if fileMatches == nil {
+			fileMatches = []logging.LogLine{}
+		}
+		data["files"] = fileMatches
+		data["filesTruncated"] = truncated
+	}
+
+	w.writeJSON(rw, data)
+}
+
+// parseLogSearchQuery builds a search query from q, level, since and limit parameters
+func parseLogSearchQuery(r *http.Request) (logging.SearchQuery, error) {
+	params := r.URL.Query()
+	query := logging.SearchQuery{
+		Text:  strings.TrimSpace(params.Get("q")),
+		Limit: defaultLogSearchLimit,
+	}
+
+	if level := params.Get("level"); level != "" {
+		for _, l := range strings.Split(level, ",") {
+			l = strings.ToUpper(strings.TrimSpace(l))
+			if l == "WARNING" {
+				l = "WARN"
+			}
+			if l != "" {
+				query.Levels = append(query.Levels, l)
+			}
+		}
+	}
+
+	// since accepts an RFC3339 timestamp or a duration relative to now (e.g. 30m)
+	if since := params.Get("since"); since != "" {
+		if ts, err := time.Parse(time.RFC3339, since); err == nil {
+			query.Since = ts
+		} else if d, err := time.ParseDuration(since); err == nil && d > 0 {
+			query.Since = time.Now().Add(-d)
+		} else {
+			return query, fmt.Errorf("invalid since: %s", since)
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf("invalid limit: %s", limit)
+		}
+		if n > maxLogSearchLimit {
+			n = maxLogSearchLimit
+		}
+		query.Limit = n
+	}
+
+	return query, nil
+}
+
+// handleLogDownload streams the current log file, or a ZIP of it and its rotations
+// when rotated=true
+func (w *WebUIServer) handleLogDownload(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !w.cfg.Logging.FileEnabled {
+		http.Error(rw, "File logging is not enabled", http.StatusNotFound)
+		return
+	}
+
+	logPath := w.cfg.Logging.FilePath
+	if r.URL.Query().Get("rotated") != "true" {
+		file, err := os.Open(logPath)
+		if err != nil {
+			http.Error(rw, "Log file not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(logPath)))
+		rw.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(rw, file); err != nil {
+			w.logger.Warn("Log download interrupted", "error", err)
+		}
+		return
+	}
+
+	files, err := logging.LogFiles(logPath)
+	if err != nil || len(files) == 0 {
+		http.Error(rw, "Log file not found", http.StatusNotFound)
+		return
+	}
+
+	// Stream the ZIP directly to the client instead of buffering it
+	fileName := fmt.Sprintf("logs_%d.zip", time.Now().Unix())
+	rw.Header().Set("Content-Type", "application/zip")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	rw.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(rw)
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			w.logger.Warn("Skip log file in download due to open error", "file", path, "error", err)
+			continue
+		}
+
+		f, err := zw.Create(filepath.Base(path))
+		if err != nil {
+			file.Close()
+			w.logger.Warn("Failed to add log file to zip", "file", path, "error", err)
+			break
+		}
+		_, err = io.Copy(f, file)
+		file.Close()
+		if err != nil {
+			w.logger.Warn("Failed writing log file to zip", "file", path, "error", err)
+			break
+		}
 	}
-
-	w.writeJSON(rw, data)
+	_ = zw.Close()
 }
 
 // handleConfig returns configuration data
 func (w *WebUIServer) handleConfig(rw http.ResponseWriter, r *http.Request) {
+	// Priorities edited at runtime are marked so they can be told apart from the config file
+	endpointOverrides, groupOverrides := w.endpointManager.PriorityOverrides()
 	data := map[string]interface{}{
 		"server": map[string]interface{}{
-			"host": w.cfg.Server.Host,
-			"port": w.cfg.Server.Port,
+			"host":                w.cfg.Server.Host,
+			"port":                w.cfg.Server.Port,
+			"requireAllListeners": w.cfg.Server.RequireAllListeners,
+			"listeners": func() []map[string]interface{} {
+				listeners := make([]map[string]interface{}, 0, len(w.cfg.Server.GetListeners()))
+				for _, l := range w.cfg.Server.GetListeners() {
+					listeners = append(listeners, map[string]interface{}{
+						"host":    l.Host,
+						"port":    l.Port,
+						"listen":  l.Listen,
+						"address": l.Address(),
+						"tls":     l.TLS.Enabled,
+						"url":     l.URL(),
+					})
+				}
+				return listeners
+			}(),
 		},
 		"strategy": map[string]interface{}{
 			"type":            w.cfg.Strategy.Type,
@@ -535,6 +1212,13 @@ func (w *WebUIServer) handleConfig(rw http.ResponseWriter, r *http.Request) {
 		},
 		"auth": map[string]interface{}{
 			"enabled": w.cfg.Auth.Enabled,
+			"clients": func() []string {
+				names := make([]string, 0, len(w.cfg.Auth.Tokens))
+				for _, token := range w.cfg.Auth.Tokens {
+					names = append(names, token.Name)
+				}
+				return names
+			}(),
 		},
 		"tui": map[string]interface{}{
 			"updateInterval": w.cfg.TUI.UpdateInterval.String(),
@@ -543,19 +1227,42 @@ func (w *WebUIServer) handleConfig(rw http.ResponseWriter, r *http.Request) {
 			"enabled": w.cfg.WebUI.Enabled,
 			"host":    w.cfg.WebUI.Host,
 			"port":    w.cfg.WebUI.Port,
+			"listen":  w.cfg.WebUI.Listen,
 		},
 		"endpoints": func() []map[string]interface{} {
 			endpoints := make([]map[string]interface{}, 0, len(w.cfg.Endpoints))
 			for _, ep := range w.cfg.Endpoints {
-				endpoints = append(endpoints, map[string]interface{}{
+				entry := map[string]interface{}{
 					"name":     ep.Name,
-					"url":      ep.URL,
+					"url":      ep.DisplayURL(),
 					"priority": ep.Priority,
 					"timeout":  ep.Timeout.String(),
-				})
+					"group":    ep.Group,
+					"token":    maskedToken(ep.Token),
+				}
+				if override, ok := endpointOverrides[ep.Name]; ok {
+					entry["priority"] = override.Priority
+					entry["configPriority"] = override.Config
+				}
+				endpoints = append(endpoints, entry)
 			}
 			return endpoints
 		}(),
+		"overrides": map[string]interface{}{
+			"file":      w.endpointManager.GetStateFilePath(),
+			"endpoints": endpointOverrides,
+			"groups":    groupOverrides,
+		},
+		"groups": func() map[string]interface{} {
+			groups := make(map[string]interface{}, len(w.cfg.Groups))
+			for name, settings := range w.cfg.Groups {
+				groups[name] = map[string]interface{}{
+					"strategy": settings.Strategy,
+					"token":    maskedToken(settings.Token),
+				}
+			}
+			return groups
+		}(),
 	}
 
 	w.writeJSON(rw, data)
@@ -567,7 +1274,6 @@ func (w *WebUIServer) handleEvents(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Set("Content-Type", "text/event-stream")
 	rw.Header().Set("Cache-Control", "no-cache")
 	rw.Header().Set("Connection", "keep-alive")
-	rw.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// Create a channel to signal when the client disconnects
 	clientGone := r.Context().Done()
@@ -607,17 +1313,15 @@ func (w *WebUIServer) handleLogStream(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Set("Content-Type", "text/event-stream")
 	rw.Header().Set("Cache-Control", "no-cache")
 	rw.Header().Set("Connection", "keep-alive")
-	rw.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// Create a channel to signal when the client disconnects
 	clientGone := r.Context().Done()
 
-	// Subscribe to log updates
-	logChannel := w.logCollector.Subscribe()
-	defer w.logCollector.Unsubscribe(logChannel)
+	// Subscribe to log updates, starting with the buffered history
+	initialLogs, logChannel := w.logBuffer.SubscribeWithHistory()
+	defer w.logBuffer.Unsubscribe(logChannel)
 
 	// Send initial logs
-	initialLogs := w.logCollector.GetLogs()
 	for _, log := range initialLogs {
 		jsonData, _ := json.Marshal(log)
 		fmt.Fprintf(rw, "data: %s\n\n", jsonData)
@@ -642,10 +1346,93 @@ func (w *WebUIServer) handleLogStream(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// formatRateLimitedUntil formats a rate-limit deadline, returning "" when none is active
+func formatRateLimitedUntil(until time.Time) string {
+	if !until.After(time.Now()) {
+		return ""
+	}
+	return until.Format(time.RFC3339)
+}
+
+// rateLimitedSeconds returns the seconds left of a rate-limit deadline, 0 when none is active
+func rateLimitedSeconds(until time.Time) int {
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		return 0
+	}
+	return int(math.Ceil(remaining.Seconds()))
+}
+
+// rateLimitData returns the endpoint's rate limit and current bucket fill, or nil when it is unlimited
+func rateLimitData(ep *endpoint.Endpoint) map[string]interface{} {
+	limit := ep.RateLimit()
+	if limit == nil {
+		return nil
+	}
+	tokens, burst := ep.RateTokens()
+	return map[string]interface{}{
+		"tokens":            math.Round(tokens*10) / 10,
+		"burst":             burst,
+		"requestsPerMinute": limit.RequestsPerMinute,
+		"onExceeded":        limit.OnExceeded,
+	}
+}
+
+// tokenData returns the masked state of the endpoint's rotated tokens, or nil without a tokens list
+func tokenData(ep *endpoint.Endpoint) []map[string]interface{} {
+	states := ep.TokenStates()
+	if states == nil {
+		return nil
+	}
+	tokens := make([]map[string]interface{}, 0, len(states))
+	for _, state := range states {
+		token := map[string]interface{}{
+			"token":     state.Token,
+			"available": state.Available,
+		}
+		if !state.Available {
+			token["disabledUntil"] = state.DisabledUntil.Format(time.RFC3339)
+			token["statusCode"] = state.StatusCode
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// circuitBreakerData returns the endpoint's circuit breaker state, or nil when breakers are disabled
+func circuitBreakerData(ep *endpoint.Endpoint, cfg config.CircuitBreakerConfig) map[string]interface{} {
+	if !cfg.Enabled {
+		return nil
+	}
+	state, remaining, failures := ep.BreakerInfo()
+	return map[string]interface{}{
+		"state":               state,
+		"remainingSeconds":    math.Ceil(remaining.Seconds()),
+		"failures":            failures,
+		"failureThreshold":    cfg.FailureThreshold,
+		"halfOpenMaxRequests": cfg.HalfOpenMaxRequests,
+	}
+}
+
+// maskedToken masks a configured token for display, keeping "" for an unset one
+func maskedToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	return config.MaskSecret(token)
+}
+
+// formatTokenExpiry returns the OAuth2 token expiry in RFC3339, or "" if no token was obtained
+func formatTokenExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return ""
+	}
+	return expiry.Format(time.RFC3339)
+}
+
 // writeJSON writes JSON response
 func (w *WebUIServer) writeJSON(rw http.ResponseWriter, data interface{}) {
 	rw.Header().Set("Content-Type", "application/json")
-	rw.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if err := json.NewEncoder(rw).Encode(data); err != nil {
 		w.logger.Error("Failed to encode JSON response", "error", err)
@@ -653,6 +1440,13 @@ func (w *WebUIServer) writeJSON(rw http.ResponseWriter, data interface{}) {
 	}
 }
 
+// recentHistoryScan is how many of the latest finished connections the overview searches
+// for ones with token usage
+const recentHistoryScan = 100
+
+// overviewEvents is how many recent events the overview shows
+const overviewEvents = 5
+
 // getRecentConnectionHistory returns recent connection history with token data
 func (w *WebUIServer) getRecentConnectionHistory(history []*monitor.ConnectionInfo, limit int) []map[string]interface{} {
 	// Filter connections that have token usage and get the most recent ones
@@ -670,14 +1464,15 @@ func (w *WebUIServer) getRecentConnectionHistory(history []*monitor.ConnectionIn
 			}
 
 			status := "success"
-			if conn.Status == "failed" {
-				status = "failed"
+			if conn.Status == "failed" || conn.Status == "cancelled" {
+				status = conn.Status
 			}
 
 			connectionsWithTokens = append(connectionsWithTokens, map[string]interface{}{
-				"clientIP": conn.ClientIP,
-				"endpoint": endpoint,
-				"status":   status,
+				"clientIP":     conn.ClientIP,
+				"endpoint":     endpoint,
+				"status":       status,
+				"retryReasons": monitor.RetryReasons(conn.Attempts),
 				"tokenUsage": map[string]interface{}{
 					"inputTokens":         conn.TokenUsage.InputTokens,
 					"outputTokens":        conn.TokenUsage.OutputTokens,
@@ -709,24 +1504,12 @@ func (w *WebUIServer) handleEndpointPriority(rw http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Find and update the endpoint priority in config
-	found := false
-	for i := range w.cfg.Endpoints {
-		if w.cfg.Endpoints[i].Name == request.EndpointName {
-			w.cfg.Endpoints[i].Priority = request.Priority
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	// Update the endpoint priority in config and record it in the runtime state
+	if err := w.endpointManager.SetEndpointPriorities(map[string]int{request.EndpointName: request.Priority}, "webui"); err != nil {
 		http.Error(rw, "Endpoint not found", http.StatusNotFound)
 		return
 	}
 
-	// Update endpoint manager with new config (same as TUI)
-	w.endpointManager.UpdateConfig(w.cfg)
-
 	w.logger.Info("WebUI: 端点优先级已更新", "endpoint", request.EndpointName, "priority", request.Priority)
 
 	rw.Header().Set("Content-Type", "application/json")
@@ -736,6 +1519,194 @@ func (w *WebUIServer) handleEndpointPriority(rw http.ResponseWriter, r *http.Req
 	})
 }
 
+// handleEndpointMaintenance toggles maintenance mode for an endpoint at runtime
+func (w *WebUIServer) handleEndpointMaintenance(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"` // true puts the endpoint into maintenance mode
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(rw, "Endpoint name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.endpointManager.SetEndpointMaintenance(request.Name, request.Enabled, "webui"); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"success":     true,
+		"name":        request.Name,
+		"maintenance": request.Enabled,
+	})
+}
+
+// handleEndpointDisable takes an endpoint out of rotation (maintenance mode) until it is enabled
+// again. The flag survives config reloads and restarts.
+func (w *WebUIServer) handleEndpointDisable(rw http.ResponseWriter, r *http.Request) {
+	w.setEndpointDisabled(rw, r, true)
+}
+
+// handleEndpointEnable puts an endpoint disabled with handleEndpointDisable back into rotation
+func (w *WebUIServer) handleEndpointEnable(rw http.ResponseWriter, r *http.Request) {
+	w.setEndpointDisabled(rw, r, false)
+}
+
+// setEndpointDisabled handles the disable and enable requests for the endpoint named in the body
+func (w *WebUIServer) setEndpointDisabled(rw http.ResponseWriter, r *http.Request, disabled bool) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(rw, "Endpoint name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.endpointManager.SetEndpointMaintenance(request.Name, disabled, "webui"); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"success":  true,
+		"name":     request.Name,
+		"disabled": disabled,
+	})
+}
+
+// handleEndpointPrimary reports the -p primary endpoint override (GET) or cancels it so the
+// priorities revert right away (DELETE)
+func (w *WebUIServer) handleEndpointPrimary(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		if err := w.endpointManager.CancelPrimaryEndpoint(); err != nil {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.logger.Info("WebUI: 主端点优先级覆盖已取消")
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := w.endpointManager.PrimaryStatus()
+	data := map[string]interface{}{
+		"active": status.Name != "",
+		"name":   status.Name,
+	}
+	if !status.Until.IsZero() {
+		data["until"] = status.Until.Format(time.RFC3339)
+		data["remainingSeconds"] = int(math.Ceil(time.Until(status.Until).Seconds()))
+	}
+	if status.Ended != "" {
+		data["last"] = map[string]interface{}{
+			"name":    status.Ended,
+			"endedAt": status.EndedAt.Format(time.RFC3339),
+			"reason":  status.Reason,
+		}
+	}
+	w.writeJSON(rw, data)
+}
+
+// groupName returns the name of the group an endpoint belongs to
+func groupName(ep *endpoint.Endpoint) string {
+	if ep.Config.Group == "" {
+		return "Default"
+	}
+	return ep.Config.Group
+}
+
+// handleGroupResetCooldown ends a group's cooldown early and resets its retry count
+func (w *WebUIServer) handleGroupResetCooldown(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(rw, "Group name is required", http.StatusBadRequest)
+		return
+	}
+
+	if !w.endpointManager.GetGroupManager().ClearGroupCooldown(request.Name) {
+		http.Error(rw, fmt.Sprintf("Group '%s' not found", request.Name), http.StatusNotFound)
+		return
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"success": true,
+		"name":    request.Name,
+	})
+}
+
+// handleGroupPriority updates group priorities, which decide the order groups take over traffic.
+// All changed groups are sent together so two groups can swap their priorities.
+func (w *WebUIServer) handleGroupPriority(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Priorities map[string]int `json:"priorities"` // Group name -> priority
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(request.Priorities) == 0 {
+		http.Error(rw, "Group priorities are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.endpointManager.SetGroupPriorities(request.Priorities, "webui"); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.logger.Info("WebUI: 组优先级已更新", "priorities", request.Priorities)
+
+	w.writeJSON(rw, map[string]interface{}{
+		"success":    true,
+		"priorities": request.Priorities,
+	})
+}
+
 // handleConfigSave handles configuration save requests
 func (w *WebUIServer) handleConfigSave(rw http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -759,13 +1730,16 @@ func (w *WebUIServer) handleConfigSave(rw http.ResponseWriter, r *http.Request)
 	}
 
 	// Check if saving is enabled (same logic as TUI)
-	if w.cfg.TUI.SavePriorityEdits {
+	if w.cfg.TUI.SaveEditsEnabled() {
 		// Save to config file (preserve comments) - reuse TUI logic
-		if err := config.SavePriorityConfigWithComments(w.cfg, configPath); err != nil {
+		// Runtime edits are applied to a copy of the config held by the endpoint manager
+		if err := config.SavePriorityConfigWithComments(w.endpointManager.GetConfig(), configPath); err != nil {
 			w.logger.Error("WebUI: 保存配置文件失败", "error", err)
 			http.Error(rw, fmt.Sprintf("Failed to save config: %v", err), http.StatusInternalServerError)
 			return
 		}
+		// Priorities now live in the config file, no need to keep them as runtime overrides
+		w.endpointManager.ClearPriorityOverrides()
 		w.logger.Info("WebUI: 配置已保存到文件并同步到路由系统，优先级更改已生效")
 	} else {
 		w.logger.Info("WebUI: 优先级更改已应用到内存（配置文件保存已禁用）")
@@ -775,7 +1749,7 @@ func (w *WebUIServer) handleConfigSave(rw http.ResponseWriter, r *http.Request)
 	json.NewEncoder(rw).Encode(map[string]interface{}{
 		"success":     true,
 		"message":     "Configuration saved successfully",
-		"savedToFile": w.cfg.TUI.SavePriorityEdits,
+		"savedToFile": w.cfg.TUI.SaveEditsEnabled(),
 	})
 }
 
@@ -813,16 +1787,40 @@ func (w *WebUIServer) handleEndpointDetails(rw http.ResponseWriter, r *http.Requ
 
 	// Build detailed response similar to TUI details panel
 	details := map[string]interface{}{
-		"name":          targetEndpoint.Config.Name,
-		"url":           targetEndpoint.Config.URL,
-		"priority":      targetEndpoint.Config.Priority,
-		"group":         targetEndpoint.Config.Group,
-		"groupPriority": targetEndpoint.Config.GroupPriority,
-		"timeout":       targetEndpoint.Config.Timeout.String(),
-		"healthy":       status.Healthy,
-		"lastCheck":     status.LastCheck.Format("15:04:05"),
-		"responseTime":  status.ResponseTime.Milliseconds(),
-		"headers":       targetEndpoint.Config.Headers,
+		"name":             targetEndpoint.Config.Name,
+		"url":              targetEndpoint.Config.DisplayURL(),
+		"socketPath":       targetEndpoint.Config.SocketPath(),
+		"priority":         targetEndpoint.Config.Priority,
+		"group":            targetEndpoint.Config.Group,
+		"groupPriority":    targetEndpoint.Config.GroupPriority,
+		"groupCooldown":    int(math.Ceil(w.endpointManager.GetGroupManager().GetGroupCooldownRemaining(groupName(targetEndpoint)).Seconds())),
+		"timeout":          targetEndpoint.Config.Timeout.String(),
+		"healthy":          status.Healthy,
+		"disabled":         status.Disabled,
+		"rateLimited":      status.IsRateLimited(time.Now()),
+		"rateLimitedUntil": formatRateLimitedUntil(status.RateLimitedUntil),
+		"rateLimitedFor":   rateLimitedSeconds(status.RateLimitedUntil),
+		"lastCheck":        status.LastCheck.Format("15:04:05"),
+		"responseTime":     status.ResponseTime.Milliseconds(),
+		"headers":          targetEndpoint.Config.Headers,
+		"inFlight":         targetEndpoint.InFlight(),
+		"maxConcurrent":    targetEndpoint.MaxConcurrent(),
+	}
+	if rateLimit := rateLimitData(targetEndpoint); rateLimit != nil {
+		details["rateLimit"] = rateLimit
+	}
+	if tokens := tokenData(targetEndpoint); tokens != nil {
+		details["tokens"] = tokens
+	}
+	healthyThreshold, unhealthyThreshold := w.endpointManager.HealthThresholds()
+	details["healthStreak"] = map[string]interface{}{
+		"successes":          status.ConsecutiveOKs,
+		"failures":           status.ConsecutiveFails,
+		"healthyThreshold":   healthyThreshold,
+		"unhealthyThreshold": unhealthyThreshold,
+	}
+	if breaker := circuitBreakerData(targetEndpoint, w.endpointManager.GetConfig().CircuitBreaker); breaker != nil {
+		details["circuitBreaker"] = breaker
 	}
 
 	if endpointStats != nil {
@@ -832,10 +1830,17 @@ func (w *WebUIServer) handleEndpointDetails(rw http.ResponseWriter, r *http.Requ
 			avgResponseTime = endpointStats.TotalResponseTime.Milliseconds() / endpointStats.TotalRequests
 		}
 
+		// Percentiles of the requests of the last few minutes
+		p50, p95, p99 := endpointStats.LatencyPercentiles()
 		details["stats"] = map[string]interface{}{
+			"p50ResponseTime":     p50.Milliseconds(),
+			"p95ResponseTime":     p95.Milliseconds(),
+			"p99ResponseTime":     p99.Milliseconds(),
+			"latencySamples":      endpointStats.Latency.Count(),
 			"totalRequests":       endpointStats.TotalRequests,
 			"successfulRequests":  endpointStats.SuccessfulRequests,
 			"failedRequests":      endpointStats.FailedRequests,
+			"rateLimitCount":      endpointStats.RateLimitCount,
 			"averageResponseTime": avgResponseTime,
 			"minResponseTime":     endpointStats.MinResponseTime.Milliseconds(),
 			"maxResponseTime":     endpointStats.MaxResponseTime.Milliseconds(),
@@ -852,56 +1857,129 @@ func (w *WebUIServer) handleEndpointDetails(rw http.ResponseWriter, r *http.Requ
 	json.NewEncoder(rw).Encode(details)
 }
 
-// handleTokenHistory returns historical token usage data (similar to TUI chart)
+// handleTokenHistory returns bucketed token usage history.
+// Optional query params: window (e.g. "1h") and interval (e.g. "5m").
 func (w *WebUIServer) handleTokenHistory(rw http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	metrics := w.monitoringMiddleware.GetMetrics().GetMetrics()
-
-	// Get token history from metrics (similar to TUI chart data)
-	tokenHistory := make([]map[string]interface{}, 0)
-
-	// If we have token history points, use them
-	if len(metrics.TokenHistory) > 0 {
-		for _, point := range metrics.TokenHistory {
-			tokenHistory = append(tokenHistory, map[string]interface{}{
-				"timestamp":           point.Timestamp.Format("15:04:05"),
-				"inputTokens":         point.InputTokens,
-				"outputTokens":        point.OutputTokens,
-				"cacheCreationTokens": point.CacheCreationTokens,
-				"cacheReadTokens":     point.CacheReadTokens,
-				"totalTokens":         point.TotalTokens,
-			})
+	var window, interval time.Duration
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(rw, "Invalid window", http.StatusBadRequest)
+			return
 		}
-	} else {
-		// If no history, provide current totals as single point
-		totalTokens := metrics.TotalTokenUsage.InputTokens + metrics.TotalTokenUsage.OutputTokens
+		window = d
+	}
+	if v := r.URL.Query().Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(rw, "Invalid interval", http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	metrics := w.monitoringMiddleware.GetMetrics()
+	buckets, effectiveInterval := metrics.GetTokenHistoryBuckets(window, interval)
+
+	tokenHistory := make([]map[string]interface{}, 0, len(buckets))
+	for _, point := range buckets {
 		tokenHistory = append(tokenHistory, map[string]interface{}{
-			"timestamp":           time.Now().Format("15:04:05"),
-			"inputTokens":         metrics.TotalTokenUsage.InputTokens,
-			"outputTokens":        metrics.TotalTokenUsage.OutputTokens,
-			"cacheCreationTokens": metrics.TotalTokenUsage.CacheCreationTokens,
-			"cacheReadTokens":     metrics.TotalTokenUsage.CacheReadTokens,
-			"totalTokens":         totalTokens,
+			"timestamp":           point.Timestamp.Format(time.RFC3339),
+			"inputTokens":         point.InputTokens,
+			"outputTokens":        point.OutputTokens,
+			"cacheCreationTokens": point.CacheCreationTokens,
+			"cacheReadTokens":     point.CacheReadTokens,
+			"totalTokens":         point.TotalTokens,
 		})
 	}
 
-	response := map[string]interface{}{
-		"history": tokenHistory,
+	totals := metrics.GetTotalTokenStats()
+	w.writeJSON(rw, map[string]interface{}{
+		"history":  tokenHistory,
+		"interval": effectiveInterval.String(),
+		"window":   (effectiveInterval * time.Duration(len(buckets))).String(),
 		"current": map[string]interface{}{
-			"inputTokens":         metrics.TotalTokenUsage.InputTokens,
-			"outputTokens":        metrics.TotalTokenUsage.OutputTokens,
-			"cacheCreationTokens": metrics.TotalTokenUsage.CacheCreationTokens,
-			"cacheReadTokens":     metrics.TotalTokenUsage.CacheReadTokens,
-			"totalTokens":         metrics.TotalTokenUsage.InputTokens + metrics.TotalTokenUsage.OutputTokens,
+			"inputTokens":         totals.InputTokens,
+			"outputTokens":        totals.OutputTokens,
+			"cacheCreationTokens": totals.CacheCreationTokens,
+			"cacheReadTokens":     totals.CacheReadTokens,
+			"totalTokens":         totals.InputTokens + totals.OutputTokens,
 		},
+	})
+}
+
+// handleClients returns per-client usage statistics sorted by token usage
+func (w *WebUIServer) handleClients(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(response)
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	metrics := w.monitoringMiddleware.GetMetrics()
+	clients := metrics.GetTopClients(limit)
+
+	clientData := make([]map[string]interface{}, 0, len(clients))
+	for _, client := range clients {
+		successRate := float64(0)
+		if client.TotalRequests > 0 {
+			successRate = float64(client.SuccessfulRequests) / float64(client.TotalRequests) * 100
+		}
+
+		clientData = append(clientData, map[string]interface{}{
+			"id":                 client.ID,
+			"clientIP":           client.ClientIP,
+			"totalRequests":      client.TotalRequests,
+			"successfulRequests": client.SuccessfulRequests,
+			"failedRequests":     client.FailedRequests,
+			"successRate":        successRate,
+			"tokenUsage": map[string]interface{}{
+				"inputTokens":         client.TokenUsage.InputTokens,
+				"outputTokens":        client.TokenUsage.OutputTokens,
+				"cacheCreationTokens": client.TokenUsage.CacheCreationTokens,
+				"cacheReadTokens":     client.TokenUsage.CacheReadTokens,
+				"totalTokens":         client.TokenUsage.InputTokens + client.TokenUsage.OutputTokens,
+			},
+			"firstSeen": client.FirstSeen.Format(time.RFC3339),
+			"lastSeen":  client.LastSeen.Format(time.RFC3339),
+		})
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"clients":    clientData,
+		"maxClients": w.cfg.Monitoring.MaxClients,
+	})
+}
+
+// handleInspector returns the requests captured for the inspector (webui.capture_enabled), newest first
+func (w *WebUIServer) handleInspector(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	captures := []*proxy.Capture{}
+	if w.proxyHandler != nil {
+		captures = w.proxyHandler.Captures()
+	}
+
+	w.writeJSON(rw, map[string]interface{}{
+		"enabled":      w.cfg.WebUI.CaptureEnabled,
+		"maxRequests":  w.cfg.WebUI.CaptureMaxRequests,
+		"maxBodyBytes": w.cfg.WebUI.CaptureMaxBodyBytes(),
+		"captures":     captures,
+	})
 }
 
 // handleConfigs returns all available configurations
@@ -973,7 +2051,7 @@ func (w *WebUIServer) handleConfigImport(rw http.ResponseWriter, r *http.Request
 	}
 
 	// Import configuration
-	filePath, err := config.ImportConfigFile(w.configDir, configName, configData, w.configRegistry)
+	filePath, warnings, err := config.ImportConfigFile(w.configDir, configName, configData, w.configRegistry)
 	if err != nil {
 		w.logger.Error("Failed to import config", "error", err, "name", configName)
 		http.Error(rw, fmt.Sprintf("Failed to import config: %v", err), http.StatusBadRequest)
@@ -988,12 +2066,16 @@ func (w *WebUIServer) handleConfigImport(rw http.ResponseWriter, r *http.Request
 	}
 
 	w.logger.Info("Config imported successfully", "name", configName, "path", filePath)
+	if len(warnings) > 0 {
+		w.logger.Warn("Imported config has credentials to fill in", "name", configName, "count", len(warnings))
+	}
 
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(map[string]interface{}{
 		"success":  true,
 		"message":  "Configuration imported successfully",
 		"filePath": filePath,
+		"warnings": warnings,
 	})
 }
 
@@ -1032,6 +2114,13 @@ func (w *WebUIServer) handleConfigSwitch(rw http.ResponseWriter, r *http.Request
 	}
 
 	w.logger.Info("Config switched successfully", "name", request.ConfigName)
+	if w.notifier != nil {
+		w.notifier.Publish(notify.Event{
+			Type:    config.NotifyEventConfigSwitched,
+			Subject: request.ConfigName,
+			Message: fmt.Sprintf("Configuration switched to %s from the WebUI", request.ConfigName),
+		})
+	}
 
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(map[string]interface{}{
@@ -1166,7 +2255,10 @@ func (w *WebUIServer) handleConfigRename(rw http.ResponseWriter, r *http.Request
 
 // handleConfigContent supports getting and updating raw YAML of a configuration
 // GET  /api/configs/content?name={configName} -> { success, name, content }
-// PUT  /api/configs/content { name, content } -> { success }
+// PUT  /api/configs/content { name, content } -> { success, changes } or 400 { success: false, error }
+//
+// Content is validated like a config load before it is written, so a saved config never
+// fails the reload it triggers. error locates the failing field: { path, line, message }.
 func (w *WebUIServer) handleConfigContent(rw http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -1183,6 +2275,10 @@ func (w *WebUIServer) handleConfigContent(rw http.ResponseWriter, r *http.Reques
 		}
 
 		data, err := os.ReadFile(meta.FilePath)
+		if os.IsNotExist(err) && meta.IsActive {
+			// Setup mode without a config file yet: offer the template as a starting point
+			data, err = []byte(config.Template()), nil
+		}
 		if err != nil {
 			w.logger.Error("Failed to read config file", "error", err, "path", meta.FilePath)
 			http.Error(rw, "Failed to read config", http.StatusInternalServerError)
@@ -1217,10 +2313,16 @@ func (w *WebUIServer) handleConfigContent(rw http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		// Validate YAML syntax by unmarshalling
-		var syntaxCheck any
-		if err := yaml.Unmarshal([]byte(req.Content), &syntaxCheck); err != nil {
-			http.Error(rw, fmt.Sprintf("Invalid YAML: %v", err), http.StatusBadRequest)
+		// Validate the whole config (syntax, defaults and validation) before writing it
+		newCfg, fieldErr := config.ValidateContent([]byte(req.Content))
+		if fieldErr != nil {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(rw).Encode(map[string]any{
+				"success": false,
+				"message": fieldErr.Message,
+				"error":   fieldErr,
+			})
 			return
 		}
 
@@ -1260,11 +2362,17 @@ func (w *WebUIServer) handleConfigContent(rw http.ResponseWriter, r *http.Reques
 		}
 
 		// If this is the active config, the file watcher will reload automatically
+		changes := make([]string, 0)
+		if meta.IsActive {
+			changes = config.DescribeChanges(w.cfg, newCfg)
+		}
 		rw.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(rw).Encode(map[string]any{
-			"success": true,
-			"message": "Configuration saved",
-			"active":  meta.IsActive,
+			"success":  true,
+			"message":  "Configuration saved",
+			"active":   meta.IsActive,
+			"changes":  changes,
+			"warnings": config.CheckCredentials([]byte(req.Content)),
 		})
 		return
 
@@ -1274,6 +2382,55 @@ func (w *WebUIServer) handleConfigContent(rw http.ResponseWriter, r *http.Reques
 	}
 }
 
+// handleConfigDiff previews an edit without saving it
+// POST /api/configs/diff { name, content } -> { success, diff, changed, changes, error }
+//
+// diff is a unified diff from the stored file to the content. changes lists the semantic
+// changes to the running config when name is the active config, and error is the
+// validation failure the content would be rejected with, null when it is valid.
+func (w *WebUIServer) handleConfigDiff(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	meta, err := w.configRegistry.GetConfig(req.Name)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Configuration not found: %s", req.Name), http.StatusNotFound)
+		return
+	}
+
+	stored, err := os.ReadFile(meta.FilePath)
+	if err != nil && !os.IsNotExist(err) {
+		w.logger.Error("Failed to read config file", "error", err, "path", meta.FilePath)
+		http.Error(rw, "Failed to read config", http.StatusInternalServerError)
+		return
+	}
+
+	diff := unifiedDiff(req.Name+" (saved)", req.Name+" (edited)", string(stored), req.Content)
+	changes := make([]string, 0)
+	newCfg, fieldErr := config.ValidateContent([]byte(req.Content))
+	if fieldErr == nil && meta.IsActive {
+		changes = config.DescribeChanges(w.cfg, newCfg)
+	}
+	w.writeJSON(rw, map[string]any{
+		"success": true,
+		"name":    req.Name,
+		"diff":    diff,
+		"changed": diff != "",
+		"changes": changes,
+		"error":   fieldErr,
+	})
+}
+
 // handleConfigExport streams a single YAML config file to the client
 func (w *WebUIServer) handleConfigExport(rw http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -1299,14 +2456,48 @@ func (w *WebUIServer) handleConfigExport(rw http.ResponseWriter, r *http.Request
 		http.Error(rw, "Failed to read config", http.StatusInternalServerError)
 		return
 	}
-
+	redact, allowed := w.exportRedaction(rw, r)
+	if !allowed {
+		return
+	}
 	fileName := fmt.Sprintf("%s.yaml", strings.TrimSpace(name))
+	if redact {
+		if data, err = config.RedactSecrets(data); err != nil {
+			// Never fall back to the raw file: it would leak the secrets
+			w.logger.Error("Failed to redact config for export", "error", err, "path", meta.FilePath)
+			http.Error(rw, "Failed to redact config; export with redact=false to download it as is", http.StatusInternalServerError)
+			return
+		}
+		fileName = fmt.Sprintf("%s.redacted.yaml", strings.TrimSpace(name))
+	}
+
 	rw.Header().Set("Content-Type", "application/x-yaml")
 	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
 	rw.WriteHeader(http.StatusOK)
 	rw.Write(data)
 }
 
+// exportRedacted reports whether an export should replace secrets with config.RedactedPlaceholder
+// (the redact query parameter, default true)
+func exportRedacted(r *http.Request) bool {
+	redact, err := strconv.ParseBool(r.URL.Query().Get("redact"))
+	return err != nil || redact
+}
+
+// exportRedaction returns whether an export is redacted. Exporting the secrets (redact=false)
+// needs a logged-in session, so it is refused while the WebUI has no password or users.
+func (w *WebUIServer) exportRedaction(rw http.ResponseWriter, r *http.Request) (redact, allowed bool) {
+	if exportRedacted(r) {
+		return true, true
+	}
+	if !w.authMiddleware.authEnabled() {
+		w.logger.Warn("Refused config export with secrets: WebUI authentication is disabled", "remote", w.authMiddleware.clientIP(r))
+		http.Error(rw, "Exporting secrets requires WebUI authentication: set webui.password or webui.users, or export with redact=true", http.StatusForbidden)
+		return false, false
+	}
+	return false, true
+}
+
 // handleConfigExportAll exports all known configuration YAMLs in a single ZIP
 func (w *WebUIServer) handleConfigExportAll(rw http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -1320,6 +2511,10 @@ func (w *WebUIServer) handleConfigExportAll(rw http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	redact, allowed := w.exportRedaction(rw, r)
+	if !allowed {
+		return
+	}
 	var buf bytes.Buffer
 	zw := zip.NewWriter(&buf)
 
@@ -1334,8 +2529,17 @@ func (w *WebUIServer) handleConfigExportAll(rw http.ResponseWriter, r *http.Requ
 			w.logger.Warn("Skip config in export due to read error", "name", meta.Name, "error", err)
 			continue
 		}
+		if redact {
+			if data, err = config.RedactSecrets(data); err != nil {
+				w.logger.Warn("Skip config in export due to redaction error", "name", meta.Name, "error", err)
+				continue
+			}
+		}
 
 		entryName := fmt.Sprintf("config_%s.yaml", strings.TrimSpace(meta.Name))
+		if redact {
+			entryName = fmt.Sprintf("config_%s.redacted.yaml", strings.TrimSpace(meta.Name))
+		}
 		f, err := zw.Create(entryName)
 		if err != nil {
 			w.logger.Warn("Failed to add file to zip", "name", meta.Name, "error", err)
@@ -1360,6 +2564,9 @@ func (w *WebUIServer) handleConfigExportAll(rw http.ResponseWriter, r *http.Requ
 	_ = zw.Close()
 
 	fileName := fmt.Sprintf("configs_%d.zip", time.Now().Unix())
+	if redact {
+		fileName = fmt.Sprintf("configs_%d.redacted.zip", time.Now().Unix())
+	}
 	rw.Header().Set("Content-Type", "application/zip")
 	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
 	rw.WriteHeader(http.StatusOK)
@@ -0,0 +1,364 @@
+package webui
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/logging"
+	"endpoint_forwarder/internal/notify"
+)
+
+func TestLogsFilteredOnServer(t *testing.T) {
+	buffer := logging.NewBuffer(logging.DefaultBufferSize)
+	buffer.AddLog("INFO", "🔄 [组管理] 组 main 进入冷却状态 (cooldown 10m)", "endpoint")
+	buffer.AddLog("ERROR", "❌ 端点网络错误: timeout", "health")
+	buffer.AddLog("WARN", "⚠️ 组 backup cooldown ended", "endpoint")
+	buffer.AddLog("INFO", "Request completed", "proxy")
+	w := &WebUIServer{cfg: &config.Config{}, logger: slog.Default(), logBuffer: buffer}
+
+	fetch := func(target string) (int, []map[string]interface{}) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		w.handleLogs(rec, httptest.NewRequest("GET", target, nil))
+		var data struct {
+			Logs []map[string]interface{} `json:"logs"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, data.Logs
+	}
+
+	if _, logs := fetch("/api/logs"); len(logs) != 4 {
+		t.Errorf("Expected the whole buffer without filters, got %d entries", len(logs))
+	}
+	if _, logs := fetch("/api/logs?q=COOLDOWN"); len(logs) != 2 {
+		t.Errorf("Expected a case-insensitive text match on 2 entries, got %v", logs)
+	}
+	if _, logs := fetch("/api/logs?q=cooldown&level=warn"); len(logs) != 1 || logs[0]["level"] != "WARN" {
+		t.Errorf("Expected only the WARN cooldown entry, got %v", logs)
+	}
+	if _, logs := fetch("/api/logs?limit=1"); len(logs) != 1 || logs[0]["message"] != "Request completed" {
+		t.Errorf("Expected the newest entry with limit=1, got %v", logs)
+	}
+	if code, _ := fetch("/api/logs?since=yesterday"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid since, got %d", code)
+	}
+}
+
+func TestConfigExportRedaction(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config_team.yaml")
+	raw := "# Team config\nwebui:\n  password: hunter2\nendpoints:\n  - name: primary\n    url: https://api.example.com\n    token: sk-primary-secret # keep private\n"
+	if err := os.WriteFile(configPath, []byte(raw), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry := config.NewConfigRegistry()
+	registry.AddConfig(config.ConfigMetadata{Name: "team", FilePath: configPath})
+	w := &WebUIServer{cfg: &config.Config{}, logger: slog.Default(), configRegistry: registry,
+		authMiddleware: NewAuthMiddleware(config.WebUIConfig{})}
+
+	export := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := export(w.handleConfigExport, "/api/configs/export?name=team")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || strings.Contains(body, "hunter2") || strings.Contains(body, "sk-primary-secret") {
+		t.Fatalf("Expected secrets to be redacted by default, got %d:\n%s", rec.Code, body)
+	}
+	if !strings.Contains(body, "# keep private") || !strings.Contains(rec.Header().Get("Content-Disposition"), "team.redacted.yaml") {
+		t.Errorf("Expected a redacted file keeping comments, got %q:\n%s", rec.Header().Get("Content-Disposition"), body)
+	}
+
+	rec = export(w.handleConfigExportAll, "/api/configs/export-all")
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a ZIP archive, got %d: %v", rec.Code, err)
+	}
+	if len(archive.File) == 0 || archive.File[0].Name != "config_team.redacted.yaml" {
+		t.Errorf("Expected a redacted entry name in the archive, got %v", archive.File)
+	}
+
+	// Without a WebUI login nobody can be trusted with the raw file
+	for _, target := range []string{"/api/configs/export?name=team&redact=false", "/api/configs/export-all?redact=false"} {
+		handler := w.handleConfigExport
+		if strings.HasPrefix(target, "/api/configs/export-all") {
+			handler = w.handleConfigExportAll
+		}
+		if rec := export(handler, target); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 without WebUI authentication, got %d", target, rec.Code)
+		}
+	}
+
+	// With a password the route is behind RequireAuth, so the raw file may be exported
+	w.authMiddleware = NewAuthMiddleware(config.WebUIConfig{Password: "hunter2"})
+	rec = export(w.handleConfigExport, "/api/configs/export?name=team&redact=false")
+	if rec.Code != http.StatusOK || rec.Body.String() != raw || !strings.Contains(rec.Header().Get("Content-Disposition"), `"team.yaml"`) {
+		t.Errorf("Expected the raw file for an authenticated session, got %d %q", rec.Code, rec.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestEventHistoryEndpoint(t *testing.T) {
+	notifier := notify.NewDispatcher(config.NotificationsConfig{})
+	notifier.Publish(notify.Event{Type: config.NotifyEventEndpointUnhealthy, Subject: "primary"})
+	notifier.Publish(notify.Event{Type: config.NotifyEventAllEndpointsDown, Subject: "main"})
+	w := &WebUIServer{cfg: &config.Config{}, logger: slog.Default(), notifier: notifier}
+
+	rec := httptest.NewRecorder()
+	w.handleEventHistory(rec, httptest.NewRequest("GET", "/api/events/history?limit=1", nil))
+	var data struct {
+		Events []notify.Event `json:"events"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Events) != 1 || data.Events[0].Type != config.NotifyEventAllEndpointsDown || data.Events[0].Subject != "main" {
+		t.Errorf("Expected the newest event only, got %+v", data.Events)
+	}
+
+	rec = httptest.NewRecorder()
+	w.handleEventHistory(rec, httptest.NewRequest("GET", "/api/events/history?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", rec.Code)
+	}
+}
+
+func TestPriorityOverridesEndpoint(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://a.example.com", Priority: 1, Group: "main", GroupPriority: 1},
+			{Name: "backup", URL: "https://b.example.com", Priority: 2, Group: "main", GroupPriority: 1},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	if err := manager.SetEndpointPriorities(map[string]int{"backup": 9}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	w := &WebUIServer{cfg: manager.GetConfig(), logger: slog.Default(), endpointManager: manager}
+
+	rec := httptest.NewRecorder()
+	w.handleConfig(rec, httptest.NewRequest("GET", "/api/config", nil))
+	var data struct {
+		Endpoints []struct {
+			Name           string `json:"name"`
+			Priority       int    `json:"priority"`
+			ConfigPriority *int   `json:"configPriority"`
+		} `json:"endpoints"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Endpoints) != 2 || data.Endpoints[0].ConfigPriority != nil ||
+		data.Endpoints[1].Priority != 9 || data.Endpoints[1].ConfigPriority == nil || *data.Endpoints[1].ConfigPriority != 2 {
+		t.Errorf("Expected only backup to be marked as overridden, got %+v", data.Endpoints)
+	}
+
+	rec = httptest.NewRecorder()
+	w.handleOverrides(rec, httptest.NewRequest("DELETE", "/api/overrides", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reverted":1`) {
+		t.Errorf("Expected one override to be reverted, got %d %s", rec.Code, rec.Body.String())
+	}
+	if got := manager.GetConfig().Endpoints[1].Priority; got != 2 {
+		t.Errorf("Expected the config priority 2 back, got %d", got)
+	}
+
+	rec = httptest.NewRecorder()
+	w.handleOverrides(rec, httptest.NewRequest("PUT", "/api/overrides", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for PUT, got %d", rec.Code)
+	}
+}
+
+func TestConfigEditorValidationAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	stored := "endpoints:\n  - name: primary\n    url: https://a.example.com\n"
+	if err := os.WriteFile(configPath, []byte(stored), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := config.NewConfigRegistry()
+	registry.AddConfig(config.ConfigMetadata{Name: "main", FilePath: configPath, IsActive: true})
+	w := &WebUIServer{cfg: cfg, logger: slog.Default(), configRegistry: registry, registryPath: filepath.Join(dir, "registry.yaml")}
+
+	send := func(handler http.HandlerFunc, method, content string) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"name": "main", "content": content})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/api/configs", bytes.NewReader(body)))
+		var result map[string]any
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec, result
+	}
+
+	invalid := stored + "  - name: backup\n    url: https://b.example.com\n    priority: -1\n"
+	rec, result := send(w.handleConfigContent, "PUT", invalid)
+	fieldErr, _ := result["error"].(map[string]any)
+	if rec.Code != http.StatusBadRequest || fieldErr["path"] != "endpoints[1].priority" || fieldErr["line"] != float64(6) {
+		t.Fatalf("Expected the invalid priority to be rejected with its field, got %d %v", rec.Code, result)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != stored {
+		t.Errorf("Expected the rejected content not to be written")
+	}
+
+	valid := stored + "  - name: backup\n    url: https://b.example.com\nstrategy:\n  type: fastest\n"
+	rec, result = send(w.handleConfigDiff, "POST", valid)
+	diff, _ := result["diff"].(string)
+	if rec.Code != http.StatusOK || result["error"] != nil || result["changed"] != true {
+		t.Fatalf("Expected a valid preview, got %d %v", rec.Code, result)
+	}
+	if !strings.Contains(diff, "@@ -1,3 +1,7 @@\n") || !strings.Contains(diff, "+  - name: backup\n") || !strings.Contains(diff, "+  type: fastest\n") {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+	if changes := fmt.Sprint(result["changes"]); changes != "[endpoints 1→2 endpoints added: backup strategy priority→fastest]" {
+		t.Errorf("Unexpected changes: %s", changes)
+	}
+
+	rec, result = send(w.handleConfigContent, "PUT", valid)
+	if rec.Code != http.StatusOK || fmt.Sprint(result["changes"]) != "[endpoints 1→2 endpoints added: backup strategy priority→fastest]" {
+		t.Errorf("Expected the save to report its changes, got %d %v", rec.Code, result)
+	}
+	if _, result = send(w.handleConfigDiff, "POST", valid); result["changed"] != false || result["diff"] != "" {
+		t.Errorf("Expected no diff against the saved content, got %v", result)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	newText := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	want := "--- old\n+++ new\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -10,3 +10,4 @@\n j\n k\n l\n+m\n"
+	if got := unifiedDiff("old", "new", oldText, newText); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff("old", "new", "", "a\n"); got != "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n" {
+		t.Errorf("Unexpected diff for a new file: %q", got)
+	}
+}
+
+func TestStaticAssetsRevalidate(t *testing.T) {
+	w := &WebUIServer{cfg: &config.Config{}, logger: slog.Default()}
+
+	for _, target := range []string{"/", "/static/style.css", "/static/app.js"} {
+		handle := w.handleStatic
+		if target == "/" {
+			handle = w.handleIndex
+		}
+		rec := httptest.NewRecorder()
+		handle(rec, httptest.NewRequest("GET", target, nil))
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || etag == "" || rec.Body.Len() == 0 {
+			t.Fatalf("Expected %s with an ETag, got %d (ETag %q)", target, rec.Code, etag)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "private, no-cache" {
+			t.Errorf("Expected %s to be revalidated on every load, got Cache-Control %q", target, cc)
+		}
+
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		handle(rec, req)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("Expected 304 for an unchanged %s, got %d with %d bytes", target, rec.Code, rec.Body.Len())
+		}
+	}
+
+	if staticAssets["/static/style.css"].etag == staticAssets["/static/app.js"].etag {
+		t.Error("Expected ETags to differ between assets")
+	}
+	rec := httptest.NewRecorder()
+	w.handleStatic(rec, httptest.NewRequest("GET", "/static/missing.js", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown asset, got %d", rec.Code)
+	}
+}
+
+func TestEndpointDisableEnable(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{CheckInterval: time.Minute, Timeout: time.Second, HealthPath: "/v1/models"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://a.example.com", Priority: 1, Group: "main", GroupPriority: 1},
+			{Name: "backup", URL: "https://b.example.com", Priority: 2, Group: "main", GroupPriority: 1},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	w := &WebUIServer{cfg: cfg, logger: slog.Default(), endpointManager: manager}
+	am := newTestAuth("secret")
+	disable := am.RequireAuth(w.handleEndpointDisable)
+	enable := am.RequireAuth(w.handleEndpointEnable)
+
+	post := func(handler http.HandlerFunc, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/endpoints/disable", strings.NewReader(body))
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+			if cookie.Name == csrfCookieName {
+				req.Header.Set(csrfHeaderName, cookie.Value)
+			}
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// Without a session the endpoint stays in rotation
+	if rec := post(disable, `{"name":"primary"}`); rec.Code == http.StatusOK {
+		t.Fatal("Expected an unauthenticated request to be rejected")
+	}
+	if manager.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Fatal("Expected the endpoint to stay enabled")
+	}
+
+	rec := login(t, am, "10.0.0.5:1234", "secret")
+	session, csrf := cookieValue(rec, sessionCookieName), cookieValue(rec, csrfCookieName)
+
+	if rec := post(disable, `{"name":"primary"}`, session, csrf); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"disabled":true`) {
+		t.Fatalf("Expected the endpoint to be disabled, got %d %s", rec.Code, rec.Body.String())
+	}
+	if healthy := manager.GetHealthyEndpoints(); len(healthy) != 1 || healthy[0].Config.Name != "backup" {
+		t.Errorf("Expected traffic to move to backup right away, got %d endpoints", len(healthy))
+	}
+
+	if rec := post(enable, `{"name":"primary"}`, session, csrf); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"disabled":false`) {
+		t.Fatalf("Expected the endpoint to be enabled, got %d %s", rec.Code, rec.Body.String())
+	}
+	if manager.GetEndpointByNameAny("primary").IsDisabled() {
+		t.Error("Expected the endpoint to be back in rotation")
+	}
+
+	if rec := post(disable, `{"name":"missing"}`, session, csrf); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown endpoint, got %d", rec.Code)
+	}
+	if rec := post(disable, `{}`, session, csrf); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a name, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/api/endpoints/enable", nil)
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	enable(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+}
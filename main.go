@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"endpoint_forwarder/config"
+	"endpoint_forwarder/internal/admin"
+	"endpoint_forwarder/internal/certs"
+	"endpoint_forwarder/internal/diagnostics"
 	"endpoint_forwarder/internal/endpoint"
+	"endpoint_forwarder/internal/listen"
 	"endpoint_forwarder/internal/logging"
 	"endpoint_forwarder/internal/middleware"
+	"endpoint_forwarder/internal/notify"
 	"endpoint_forwarder/internal/proxy"
 	"endpoint_forwarder/internal/transport"
 	"endpoint_forwarder/internal/tui"
@@ -26,7 +36,9 @@ var (
 	showVersion     = flag.Bool("version", false, "Show version information")
 	enableTUI       = flag.Bool("tui", true, "Enable TUI interface (default: true)")
 	disableTUI      = flag.Bool("no-tui", false, "Disable TUI interface")
-	primaryEndpoint = flag.String("p", "", "Set primary endpoint with highest priority (endpoint name)")
+	primaryEndpoint = flag.String("p", "", "Set primary endpoint with highest priority (endpoint name, or name=duration such as backup=2h to revert after that time)")
+	initConfig      = flag.Bool("init", false, "Write a commented config template to the -config path and exit")
+	checkConfig     = checkConfigFlag("check-config", "Check the -config file, print a report and exit non-zero on errors; --check-config=online also probes each endpoint")
 
 	// Build-time variables (set via ldflags)
 	version = "dev"
@@ -34,12 +46,16 @@ var (
 	date    = "unknown"
 
 	// Runtime variables
-	startTime         = time.Now()
-	currentLogHandler *SimpleHandler // Track current log handler for cleanup
+	startTime = time.Now()
+
+	// logBuffer keeps recent logs for the TUI and WebUI, whether or not they are running yet
+	logBuffer = logging.NewBuffer(logging.DefaultBufferSize)
 )
 
 func main() {
-	flag.Parse()
+	flag.Usage = usage
+	flag.CommandLine.Parse(runCommand(os.Args[1:]))
+	diagnostics.SetBuildInfo(version, commit, date)
 
 	// Handle version flag
 	if *showVersion {
@@ -50,11 +66,27 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Handle check-config flag: validate the config without starting the server
+	if *checkConfig != "" {
+		os.Exit(runConfigCheck(*configPath, *checkConfig == checkConfigOnline, defaultCheckTimeout, os.Stdout))
+	}
+
+	// Handle init flag: write a config template for a fresh install
+	if *initConfig {
+		if err := config.WriteTemplate(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write config template: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Config template written to %s\n", *configPath)
+		fmt.Printf("Add endpoints to it, or start the forwarder and import a config through the WebUI (setup mode)\n")
+		os.Exit(0)
+	}
+
 	// Determine TUI mode
 	tuiEnabled := *enableTUI && !*disableTUI
 
-	// Setup initial logger (will be updated when config is loaded)
-	logger := setupLogger(config.LoggingConfig{Level: "info", Format: "text"}, nil, nil)
+	// Setup the logger; its settings are updated once the config is loaded and on every reload
+	logger := setupLogger(config.LoggingConfig{Level: "info", Format: "text"}, true)
 	slog.SetDefault(logger)
 
 	// Create configuration watcher
@@ -68,14 +100,17 @@ func main() {
 	// Get initial configuration
 	cfg := configWatcher.GetConfig()
 
-	// Apply command line primary endpoint override
-	if *primaryEndpoint != "" {
-		cfg.PrimaryEndpoint = *primaryEndpoint
-		if err := cfg.ApplyPrimaryEndpoint(logger); err != nil {
-			logger.Error(fmt.Sprintf("❌ 主端点配置失败: %v", err))
-			os.Exit(1)
-		}
+	// Parse the command line primary endpoint override, applied once the endpoint manager exists
+	primaryName, primaryDuration, err := config.ParsePrimaryEndpoint(*primaryEndpoint)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ 主端点配置失败: %v", err))
+		os.Exit(1)
+	}
+	if primaryName != "" && cfg.IsSetupMode() {
+		logger.Warn(fmt.Sprintf("⚠️ [设置模式] 尚未配置端点，忽略主端点参数: %s", primaryName))
+		primaryName = ""
 	}
+	cfg.PrimaryEndpoint = primaryName
 
 	// Apply TUI configuration from config file and command line
 	if cfg.TUI.UpdateInterval == 0 {
@@ -90,9 +125,8 @@ func main() {
 		tuiEnabled = cfg.TUI.Enabled
 	}
 
-	// Update logger with config settings (TUI will be added later)
-	logger = setupLogger(cfg.Logging, nil, nil)
-	slog.SetDefault(logger)
+	// Apply the config's logging settings (console output stops once the TUI starts)
+	configureLogger(cfg.Logging, true)
 
 	if tuiEnabled {
 		logger.Info("🖥️ TUI模式已启用，启动图形化监控界面")
@@ -106,6 +140,15 @@ func main() {
 			"strategy", cfg.Strategy.Type)
 	}
 
+	if cfg.IsSetupMode() {
+		logger.Warn(fmt.Sprintf("🛠️ [设置模式] 未找到包含端点的有效配置，转发请求将返回 503: %s", *configPath))
+		if cfg.WebUI.Enabled {
+			logger.Warn("🛠️ [设置模式] 请通过 WebUI 导入或粘贴配置，激活包含端点的配置后立即开始转发")
+		} else {
+			logger.Warn("🛠️ [设置模式] WebUI 未启用，请编辑配置文件添加端点（可使用 --init 生成启用 WebUI 的模板）")
+		}
+	}
+
 	// Display proxy configuration (only in non-TUI mode)
 	if !tuiEnabled {
 		if cfg.Proxy.Enabled {
@@ -120,14 +163,32 @@ func main() {
 			logger.Info("🔐 鉴权已启用，访问需要Bearer Token验证")
 		} else {
 			logger.Info("🔓 鉴权已禁用，所有请求将直接转发")
-			if cfg.Server.Host != "127.0.0.1" && cfg.Server.Host != "localhost" && cfg.Server.Host != "::1" {
-				logger.Warn("⚠️  注意：将在非本地地址启动但未启用鉴权，请确保网络环境安全")
+			for _, listener := range cfg.Server.GetListeners() {
+				if !listener.IsLocal() {
+					logger.Warn("⚠️  注意：将在非本地地址启动但未启用鉴权，请确保网络环境安全")
+					break
+				}
 			}
 		}
 	}
 
 	// Create endpoint manager
 	endpointManager := endpoint.NewManager(cfg)
+	endpointManager.SetStateStore(endpoint.NewStateStore(cfg.StateFilePath(*configPath), cfg.State.SaveDelay))
+
+	// Apply command line primary endpoint override; it is re-applied on reloads until it expires
+	if primaryName != "" {
+		if err := endpointManager.SetPrimaryEndpoint(primaryName, primaryDuration); err != nil {
+			logger.Error(fmt.Sprintf("❌ 主端点配置失败: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	// Health and failure notifications
+	notifier := notify.NewDispatcher(cfg.Notifications)
+	notifier.Start()
+	defer notifier.Stop()
+	endpointManager.SetEventPublisher(notifier.Publisher())
 	endpointManager.Start()
 	defer endpointManager.Stop()
 
@@ -138,23 +199,48 @@ func main() {
 	loggingMiddleware := middleware.NewLoggingMiddleware(logger)
 	monitoringMiddleware := middleware.NewMonitoringMiddleware(endpointManager)
 	authMiddleware := middleware.NewAuthMiddleware(cfg.Auth)
+	corsMiddleware := middleware.NewCORSMiddleware(cfg.Server.CORS)
 
 	// Connect logging and monitoring middlewares
 	loggingMiddleware.SetMonitoringMiddleware(monitoringMiddleware)
+	loggingMiddleware.SetAuthMiddleware(authMiddleware)
+	monitoringMiddleware.SetAuthMiddleware(authMiddleware)
+	loggingMiddleware.SetAccessLog(accessLogWriter(cfg.Logging, !tuiEnabled), cfg.Logging.AccessLogFields)
+	loggingMiddleware.SetRequestIDHeader(cfg.Forwarding.RequestIDHeader)
+	loggingMiddleware.SetTrustedProxies(cfg.Server.TrustedProxies)
+	monitoringMiddleware.UpdateConfig(cfg.Monitoring)
 	proxyHandler.SetMonitoringMiddleware(monitoringMiddleware)
+	notifier.SetSuccessRateSource(monitoringMiddleware.GetMetrics().GetRequestCounts)
+
+	// Self-diagnostics for the WebUI and TUI
+	diagnosticsCollector := diagnostics.NewCollector(startTime, configWatcher, endpointManager, monitoringMiddleware, logHandler.Rotator)
+
+	// The WebUI is started and stopped as webui.enabled changes across reloads
+	webUIController := webui.NewController(func(webCfg *config.Config) *webui.WebUIServer {
+		server := webui.NewWebUIServer(webCfg, endpointManager, monitoringMiddleware, startTime, slog.Default())
+		server.SetLogBuffer(logBuffer)
+		// Set config watcher reference for configuration switching
+		server.SetConfigWatcher(configWatcher)
+		server.SetDiagnostics(diagnosticsCollector)
+		server.SetProxyHandler(proxyHandler)
+		server.SetNotifier(notifier)
+		return server
+	})
+
+	// The admin socket is opened, moved or closed as admin.socket_path changes across reloads
+	adminServer := admin.NewServer(endpointManager, monitoringMiddleware, configWatcher)
 
-	// Store tuiApp and webUIServer references for configuration reloads
+	// Store tuiApp reference for configuration reloads
 	var tuiApp *tui.TUIApp
-	var webUIServer *webui.WebUIServer
 
 	// Setup configuration reload callback to update components
+	setupMode := cfg.IsSetupMode()
 	configWatcher.AddReloadCallback(func(newCfg *config.Config) {
-		// Update logger (pass current tuiApp and webUIServer)
-		newLogger := setupLogger(newCfg.Logging, tuiApp, webUIServer)
-		slog.SetDefault(newLogger)
-
-		// Update config watcher's logger too
-		configWatcher.UpdateLogger(newLogger)
+		// Update logging in place, so every logger handed out so far follows (console output only without TUI)
+		configureLogger(newCfg.Logging, tuiApp == nil)
+		loggingMiddleware.SetAccessLog(accessLogWriter(newCfg.Logging, tuiApp == nil), newCfg.Logging.AccessLogFields)
+		loggingMiddleware.SetRequestIDHeader(newCfg.Forwarding.RequestIDHeader)
+		loggingMiddleware.SetTrustedProxies(newCfg.Server.TrustedProxies)
 
 		// Update endpoint manager
 		endpointManager.UpdateConfig(newCfg)
@@ -164,9 +250,24 @@ func main() {
 
 		// Update auth middleware
 		authMiddleware.UpdateConfig(newCfg.Auth)
-		// Update WebUI server
-		if webUIServer != nil {
-			webUIServer.UpdateConfig(newCfg)
+
+		// Update CORS settings
+		corsMiddleware.UpdateConfig(newCfg.Server.CORS)
+
+		// Update monitoring settings
+		monitoringMiddleware.UpdateConfig(newCfg.Monitoring)
+
+		// Update notification sinks and triggers
+		notifier.UpdateConfig(newCfg.Notifications)
+
+		// Update WebUI server, starting or stopping it when webui.enabled changed
+		if err := webUIController.Apply(newCfg); err != nil {
+			logger.Error(fmt.Sprintf("❌ WebUI服务器启动失败: %v", err))
+		}
+
+		// Update the admin socket
+		if err := adminServer.Apply(newCfg); err != nil {
+			logger.Error(fmt.Sprintf("❌ 管理套接字启动失败: %v", err))
 		}
 
 		// Update TUI if enabled
@@ -175,8 +276,21 @@ func main() {
 		}
 
 		if !tuiEnabled {
-			newLogger.Info("🔄 所有组件已更新为新配置")
+			logger.Info("🔄 所有组件已更新为新配置")
 		}
+
+		if setupMode && !newCfg.IsSetupMode() {
+			logger.Info(fmt.Sprintf("✅ [设置模式] 配置已激活，开始转发 - 端点数量: %d", len(newCfg.Endpoints)))
+		}
+		setupMode = newCfg.IsSetupMode()
+	})
+
+	configWatcher.AddReloadErrorCallback(func(err error) {
+		notifier.Publish(notify.Event{
+			Type:    config.NotifyEventConfigReloadFailed,
+			Subject: configWatcher.Status().ConfigPath,
+			Message: fmt.Sprintf("Reloading the configuration failed; the previous configuration stays active: %v", err),
+		})
 	})
 
 	if !tuiEnabled {
@@ -188,86 +302,107 @@ func main() {
 
 	// Register monitoring endpoints
 	monitoringMiddleware.RegisterHealthEndpoint(mux)
+	mux.HandleFunc("/api/version", diagnostics.VersionHandler(startTime, loggingMiddleware.ClientIP))
 
 	// Register proxy handler for all other requests with middleware chain
-	mux.Handle("/", loggingMiddleware.Wrap(authMiddleware.Wrap(proxyHandler)))
-
-	server := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      mux,
-		ReadTimeout:  60 * time.Second,
-		WriteTimeout: 0, // No write timeout for streaming
-		IdleTimeout:  120 * time.Second,
-	}
+	// CORS goes first so preflight requests are answered before auth and logging
+	mux.Handle("/", corsMiddleware.Wrap(loggingMiddleware.Wrap(authMiddleware.Wrap(proxyHandler))))
 
-	// Start server in a goroutine
-	serverErr := make(chan error, 1)
-	go func() {
-		if !tuiEnabled {
+	// Start one HTTP server per listener, all sharing the same handler chain
+	listeners := cfg.Server.GetListeners()
+	serverErr := make(chan error, len(listeners))
+	if !tuiEnabled {
+		for _, listener := range listeners {
 			logger.Info("🌐 HTTP 服务器启动中...",
-				"address", server.Addr,
+				"address", listener.Address(),
+				"tls", listener.TLS.Enabled,
 				"endpoints_count", len(cfg.Endpoints))
 		}
+	}
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErr <- err
-		}
-	}()
-
-	// Give server a moment to start
-	time.Sleep(100 * time.Millisecond)
-
-	// Check if server started successfully
-	select {
-	case err := <-serverErr:
+	servers, bindErrs, err := startListeners(listeners, mux, serverErr)
+	if err != nil {
 		logger.Error(fmt.Sprintf("❌ 服务器启动失败: %v", err))
 		os.Exit(1)
-	default:
-		// Server started successfully
-		baseURL := fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.Port)
+	}
+	for _, err := range bindErrs {
+		logger.Error(fmt.Sprintf("❌ 监听器启动失败: %v", err))
+	}
 
-		if !tuiEnabled {
-			logger.Info("✅ 服务器启动成功！")
-			logger.Info("📋 配置说明：请在 Claude Code 的 settings.json 中设置")
-			logger.Info("🔧 ANTHROPIC_BASE_URL: " + baseURL)
-			logger.Info("📡 服务器地址: " + baseURL)
-
-			// Security warning for non-localhost addresses
-			if cfg.Server.Host != "127.0.0.1" && cfg.Server.Host != "localhost" && cfg.Server.Host != "::1" {
-				if !cfg.Auth.Enabled {
-					logger.Warn("⚠️  安全警告：服务器绑定到非本地地址但未启用鉴权！")
-					logger.Warn("🔒 强烈建议启用鉴权以保护您的端点访问")
-					logger.Warn("📝 在配置文件中设置 auth.enabled: true 和 auth.token 来启用鉴权")
-				} else {
-					logger.Info("🔒 已启用鉴权保护，服务器可安全对外开放")
-				}
+	// Check if servers started successfully
+	if len(servers) == 0 || (len(bindErrs) > 0 && cfg.Server.RequireAllListeners) {
+		if len(servers) > 0 {
+			logger.Error("❌ 服务器启动失败: server.require_all_listeners 已启用，部分监听器未能启动")
+			shutdownServers(context.Background(), servers, logger)
+		} else {
+			logger.Error("❌ 服务器启动失败: 所有监听器均未能启动")
+		}
+		os.Exit(1)
+	}
+	if len(bindErrs) > 0 {
+		logger.Warn(fmt.Sprintf("⚠️ %d 个监听器启动失败，继续使用其余 %d 个监听器运行", len(bindErrs), len(servers)))
+	}
+
+	// Server started successfully
+	if !tuiEnabled {
+		logger.Info("✅ 服务器启动成功！")
+		// Sockets have no URL clients could use directly, they are reached through a reverse proxy
+		for _, srv := range servers {
+			if srv.listener.Listen == "" {
+				logger.Info("📋 配置说明：请在 Claude Code 的 settings.json 中设置")
+				logger.Info("🔧 ANTHROPIC_BASE_URL: " + srv.listener.URL())
+				break
+			}
+		}
+		exposed := false
+		for _, srv := range servers {
+			if addr, ok := srv.listener.ListenAddress(); ok && addr.SocketPath != "" {
+				logger.Info("🔌 Unix 套接字: " + addr.SocketPath)
+			} else if ok {
+				logger.Info("🔌 systemd 套接字: " + addr.String())
+			} else {
+				logger.Info("📡 服务器地址: " + srv.listener.URL())
+			}
+			if !srv.listener.IsLocal() {
+				exposed = true
+			}
+		}
+
+		// Security warning for non-localhost addresses
+		if exposed {
+			if !cfg.Auth.Enabled {
+				logger.Warn("⚠️  安全警告：服务器绑定到非本地地址但未启用鉴权！")
+				logger.Warn("🔒 强烈建议启用鉴权以保护您的端点访问")
+				logger.Warn("📝 在配置文件中设置 auth.enabled: true 和 auth.token (或 auth.tokens) 来启用鉴权")
+			} else {
+				logger.Info("🔒 已启用鉴权保护，服务器可安全对外开放")
 			}
 		}
 	}
 
 	// Start WebUI if enabled
-	if cfg.WebUI.Enabled {
-		webUIServer = webui.NewWebUIServer(cfg, endpointManager, monitoringMiddleware, startTime, logger)
-		// Set config watcher reference for configuration switching
-		webUIServer.SetConfigWatcher(configWatcher)
-		if err := webUIServer.Start(); err != nil {
-			logger.Error("❌ WebUI服务器启动失败", "error", err)
-		} else {
-			// Update logger to include WebUI after WebUI server is initialized
-			logger = setupLogger(cfg.Logging, tuiApp, webUIServer)
-			slog.SetDefault(logger)
+	if err := webUIController.Apply(cfg); err != nil {
+		logger.Error("❌ WebUI服务器启动失败", "error", err)
+		// Unusable certificates are a configuration error, not a transient one
+		if errors.Is(err, certs.ErrLoad) {
+			os.Exit(1)
 		}
 	}
 
+	// Open the admin socket if configured
+	if err := adminServer.Apply(cfg); err != nil {
+		logger.Error("❌ 管理套接字启动失败", "error", err)
+	}
+
 	// Start TUI if enabled
 	if tuiEnabled {
 		tuiApp = tui.NewTUIApp(cfg, endpointManager, monitoringMiddleware, startTime, *configPath)
-		// Update logger to send logs to TUI as well
-		logger = setupLogger(cfg.Logging, tuiApp, webUIServer)
-		slog.SetDefault(logger)
-
-		// Update config watcher's logger to use TUI-enabled logger
-		configWatcher.UpdateLogger(logger)
+		tuiApp.SetDiagnostics(diagnosticsCollector)
+		tuiApp.SetNotifier(notifier)
+		tuiApp.SetLogBuffer(logBuffer)
+		// Stop console output now that the TUI shows the logs
+		configureLogger(cfg.Logging, false)
+		loggingMiddleware.SetAccessLog(accessLogWriter(cfg.Logging, false), cfg.Logging.AccessLogFields)
 
 		// Run TUI in a goroutine
 		tuiErr := make(chan error, 1)
@@ -310,22 +445,50 @@ func main() {
 	}
 
 	// Close WebUI server if running
-	if webUIServer != nil && webUIServer.IsRunning() {
-		if err := webUIServer.Stop(); err != nil {
+	if webUIController.Server() != nil {
+		if err := webUIController.Stop(); err != nil {
 			logger.Error("❌ WebUI服务器关闭失败", "error", err)
 		}
 	}
 
-	// Close log file handler before shutdown
-	if currentLogHandler != nil {
-		currentLogHandler.Close()
+	// Close the admin socket, removing the socket file
+	if err := adminServer.Stop(); err != nil {
+		logger.Error("❌ 管理套接字关闭失败", "error", err)
+	}
+
+	// Drain in-flight requests: streaming clients receive an "event: shutdown" SSE event and
+	// get up to server.shutdown_drain_timeout to finish before their connections are closed
+	drainTimeout := configWatcher.GetConfig().Server.ShutdownDrainTimeout
+	inFlight := proxyHandler.InFlight()
+	notified := proxyHandler.BeginDrain()
+	if inFlight > 0 {
+		logger.Info(fmt.Sprintf("⏳ 等待进行中的请求完成 - 请求: %d, 流式连接: %d (已发送 shutdown 事件), 最长等待: %v",
+			inFlight, notified, drainTimeout))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error(fmt.Sprintf("❌ 服务器关闭失败: %v", err))
+	ok := shutdownServers(ctx, servers, logger)
+	if inFlight > 0 {
+		cut := proxyHandler.InFlight()
+		finished := inFlight - cut
+		if finished < 0 {
+			finished = 0
+		}
+		if cut > 0 {
+			logger.Warn(fmt.Sprintf("⚠️ 排空超时，已强制关闭连接 - 已完成: %d, 被中断: %d", finished, cut))
+		} else {
+			logger.Info(fmt.Sprintf("✅ 进行中的请求已全部完成 - 已完成: %d", finished))
+		}
+	}
+
+	// Close the log file after the drain so its results reach the log file
+	if logHandler != nil {
+		logHandler.Close()
+	}
+
+	if !ok {
 		os.Exit(1)
 	}
 
@@ -334,137 +497,201 @@ func main() {
 	}
 }
 
-// setupLogger configures the structured logger
-func setupLogger(cfg config.LoggingConfig, tuiApp *tui.TUIApp, webUIServer *webui.WebUIServer) *slog.Logger {
-	var level slog.Level
-	switch cfg.Level {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
-	}
+// listenerServer pairs a configured listener with the HTTP server serving it
+type listenerServer struct {
+	listener config.ListenerConfig
+	server   *http.Server
+	certs    *certs.Reloader // Reloads the listener's certificate when its files change, nil without TLS
+}
 
-	var fileRotator *logging.FileRotator
-	// Setup file logging if enabled
-	if cfg.FileEnabled {
-		maxSize, err := logging.ParseSize(cfg.MaxFileSize)
+// startListeners binds every listener and serves handler on each of them.
+// Binding failures are returned per listener; errors after startup are sent to serverErr.
+// Certificates are loaded before anything is bound: a TLS listener whose files cannot be
+// loaded fails the whole startup with the returned error.
+func startListeners(listeners []config.ListenerConfig, handler http.Handler, serverErr chan<- error) ([]*listenerServer, []error, error) {
+	reloaders := make([]*certs.Reloader, len(listeners))
+	tlsConfigs := make([]*tls.Config, len(listeners))
+	for i, listener := range listeners {
+		if !listener.TLS.Enabled {
+			continue
+		}
+		reloader, tlsConfig, err := certs.New(listener.TLS, listener.Address())
 		if err != nil {
-			fmt.Printf("警告：无法解析日志文件大小配置 '%s'，使用默认值 100MB: %v\n", cfg.MaxFileSize, err)
-			maxSize = 100 * 1024 * 1024 // 100MB
+			for _, loaded := range reloaders[:i] {
+				if loaded != nil {
+					loaded.Close()
+				}
+			}
+			return nil, nil, fmt.Errorf("%s: %w", listener.Address(), err)
+		}
+		reloaders[i], tlsConfigs[i] = reloader, tlsConfig
+	}
+
+	servers := make([]*listenerServer, 0, len(listeners))
+	var bindErrs []error
+
+	for i, listener := range listeners {
+		server := &http.Server{
+			Addr:         listener.Address(),
+			Handler:      handler,
+			ReadTimeout:  60 * time.Second,
+			WriteTimeout: 0, // No write timeout for streaming
+			IdleTimeout:  120 * time.Second,
+			TLSConfig:    tlsConfigs[i],
 		}
 
-		fileRotator, err = logging.NewFileRotator(cfg.FilePath, maxSize, cfg.MaxFiles, cfg.CompressRotated)
+		lns, err := openListener(listener)
 		if err != nil {
-			fmt.Printf("警告：无法创建日志文件轮转器: %v\n", err)
-			fileRotator = nil
+			bindErrs = append(bindErrs, fmt.Errorf("%s: %w", listener.Address(), err))
+			if reloaders[i] != nil {
+				reloaders[i].Close()
+			}
+			continue
 		}
-	}
 
-	var handler slog.Handler
-	// Create a custom handler that only outputs the message
-	handler = &SimpleHandler{
-		level:                    level,
-		tuiApp:                   tuiApp,
-		webUIServer:              webUIServer,
-		fileRotator:              fileRotator,
-		disableFileResponseLimit: cfg.FileEnabled && cfg.DisableResponseLimit,
-	}
-	currentLogHandler = handler.(*SimpleHandler) // Store reference for cleanup
+		// A systemd listener may have been passed several sockets, all served by the same server
+		for _, ln := range lns {
+			go func(listener config.ListenerConfig, ln net.Listener) {
+				var err error
+				if listener.TLS.Enabled {
+					err = server.ServeTLS(ln, "", "")
+				} else {
+					err = server.Serve(ln)
+				}
+				if err != nil && err != http.ErrServerClosed {
+					serverErr <- fmt.Errorf("%s: %w", listener.Address(), err)
+				}
+			}(listener, ln)
+		}
 
-	// Debug: print file logging configuration
-	if cfg.FileEnabled {
-		fmt.Printf("🔧 文件日志已启用: 路径=%s, 禁用响应限制=%v\n", cfg.FilePath, cfg.DisableResponseLimit)
+		servers = append(servers, &listenerServer{listener: listener, server: server, certs: reloaders[i]})
 	}
 
-	return slog.New(handler)
-}
-
-// SimpleHandler only outputs the log message without any metadata
-type SimpleHandler struct {
-	level                    slog.Level
-	tuiApp                   *tui.TUIApp
-	webUIServer              *webui.WebUIServer
-	fileRotator              *logging.FileRotator
-	disableFileResponseLimit bool // Whether to disable response limit for file output
+	return servers, bindErrs, nil
 }
 
-func (h *SimpleHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= h.level
+// openListener binds a listener's TCP address, Unix socket or systemd sockets. Unix socket
+// files are removed when the server shuts down and closes them.
+func openListener(listener config.ListenerConfig) ([]net.Listener, error) {
+	if listener.Listen != "" {
+		return listen.Listen(listener.Listen, listener.SocketFileMode())
+	}
+	ln, err := net.Listen("tcp", listener.Address())
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{ln}, nil
 }
 
-func (h *SimpleHandler) Handle(_ context.Context, r slog.Record) error {
-	message := r.Message
-
-	// Format log message with timestamp for file output
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	level := "INFO"
-	switch r.Level {
-	case slog.LevelDebug:
-		level = "DEBUG"
-	case slog.LevelWarn:
-		level = "WARN"
-	case slog.LevelError:
-		level = "ERROR"
-	}
-
-	// For file output - use full message if response limit is disabled
-	if h.fileRotator != nil {
-		fileMessage := message
-		// If disable file response limit is TRUE, don't truncate; if FALSE, truncate
-		if !h.disableFileResponseLimit && len(message) > 500 {
-			fileMessage = message[:500] + "... (文件日志截断)"
-		}
-		// When disableFileResponseLimit is true, fileMessage = message (no truncation)
-		formattedMessage := fmt.Sprintf("[%s] [%s] %s\n", timestamp, level, fileMessage)
-		h.fileRotator.Write([]byte(formattedMessage))
+// shutdownServers gracefully shuts down all listeners, returning false if any failed. Listeners
+// stop accepting at once; connections still open when ctx expires are closed.
+func shutdownServers(ctx context.Context, servers []*listenerServer, logger *slog.Logger) bool {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := true
+
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *listenerServer) {
+			defer wg.Done()
+			if srv.certs != nil {
+				defer srv.certs.Close()
+			}
+			err := srv.server.Shutdown(ctx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				// Drain timeout: close the connections that are still open
+				err = srv.server.Close()
+			}
+			if err != nil {
+				logger.Error(fmt.Sprintf("❌ 服务器关闭失败: %v - 地址: %s", err, srv.listener.Address()))
+				mu.Lock()
+				ok = false
+				mu.Unlock()
+			}
+		}(srv)
 	}
 
-	// For UI/console output - always limit message length
-	displayMessage := message
-	if len(displayMessage) > 500 {
-		displayMessage = displayMessage[:500] + "... (显示截断)"
-	}
+	wg.Wait()
+	return ok
+}
 
-	// Send to TUI if available
-	if h.tuiApp != nil {
-		h.tuiApp.AddLog(level, displayMessage, "system")
-	}
+// logHandler is the single log handler of the run; reloads update it in place
+var logHandler *logging.Handler
 
-	// Send to WebUI if available
-	if h.webUIServer != nil {
-		h.webUIServer.AddLog(level, displayMessage, "system")
+// setupLogger creates the log handler and returns a logger writing to it
+func setupLogger(cfg config.LoggingConfig, consoleOutput bool) *slog.Logger {
+	var err error
+	logHandler, err = logging.NewHandler(logBuffer, middleware.RequestIDFromContext, loggerOptions(cfg, consoleOutput))
+	if err != nil {
+		fmt.Printf("警告：无法创建日志文件轮转器: %v\n", err)
 	}
+	return slog.New(logHandler)
+}
 
-	// Only output to console when neither TUI nor WebUI is capturing logs
-	if h.tuiApp == nil {
-		// Include timestamp and level for console output
-		fmt.Printf("[%s] [%s] %s\n", timestamp, level, displayMessage)
+// configureLogger applies logging settings to the running log handler: the level takes effect
+// right away and a changed log file is switched to, closing the previous one shortly after
+func configureLogger(cfg config.LoggingConfig, consoleOutput bool) {
+	if err := logHandler.UpdateConfig(loggerOptions(cfg, consoleOutput)); err != nil {
+		fmt.Printf("警告：无法创建日志文件轮转器: %v\n", err)
 	}
 
-	return nil
+	// Debug: print file logging configuration
+	if cfg.FileEnabled && consoleOutput {
+		fmt.Printf("🔧 文件日志已启用: 路径=%s, 禁用响应限制=%v\n", cfg.FilePath, cfg.DisableResponseLimit)
+	}
 }
 
-func (h *SimpleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	// Return the same handler since we don't use attributes
-	return h
-}
+// loggerOptions converts logging settings to log handler options
+func loggerOptions(cfg config.LoggingConfig, consoleOutput bool) logging.HandlerOptions {
+	var level slog.Level
+	switch cfg.Level {
+	case "debug":
+		level = slog.LevelDebug
+	case "info":
+		level = slog.LevelInfo
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		level = slog.LevelInfo
+	}
 
-func (h *SimpleHandler) WithGroup(name string) slog.Handler {
-	// Return the same handler since we don't use groups
-	return h
+	opts := logging.HandlerOptions{
+		Level:         level,
+		ConsoleOutput: consoleOutput,
+	}
+	if cfg.FileEnabled {
+		maxSize, err := logging.ParseSize(cfg.MaxFileSize)
+		if err != nil {
+			fmt.Printf("警告：无法解析日志文件大小配置 '%s'，使用默认值 100MB: %v\n", cfg.MaxFileSize, err)
+			maxSize = 100 * 1024 * 1024 // 100MB
+		}
+		opts.FilePath = cfg.FilePath
+		opts.Rotation = logging.RotatorOptions{
+			MaxSize:         maxSize,
+			MaxFiles:        cfg.MaxFiles,
+			Compress:        cfg.CompressRotated,
+			RotateInterval:  cfg.RotateInterval,
+			RotateOnStartup: cfg.RotateOnStartup,
+		}
+		opts.DisableFileResponseLimit = cfg.DisableResponseLimit
+	}
+	return opts
 }
 
-// Close gracefully closes the handler and syncs any buffered data
-func (h *SimpleHandler) Close() error {
-	if h.fileRotator != nil {
-		h.fileRotator.Sync()
-		return h.fileRotator.Close()
+// accessLogWriter returns where the JSON access log goes with logging.format: json: the log
+// file when file logging is enabled, otherwise stdout unless the TUI owns the terminal.
+// nil disables the access log.
+func accessLogWriter(cfg config.LoggingConfig, consoleOutput bool) io.Writer {
+	if cfg.Format != "json" {
+		return nil
+	}
+	if cfg.FileEnabled && logHandler.Rotator() != nil {
+		return logHandler.FileWriter()
+	}
+	if consoleOutput {
+		return os.Stdout
 	}
 	return nil
 }